@@ -0,0 +1,14 @@
+CREATE TABLE `eventbox_outbox`
+(
+    `id`              bigint(20) unsigned NOT NULL AUTO_INCREMENT COMMENT 'primary key',
+    `idempotency_key` varchar(191) NOT NULL DEFAULT '' COMMENT 'messages with the same key are enqueued once',
+    `message`         mediumtext   NOT NULL COMMENT 'eventbox message in json',
+    `status`          varchar(16)  NOT NULL DEFAULT 'pending' COMMENT 'pending or acked',
+    `attempts`        int(11)      NOT NULL DEFAULT '0' COMMENT 'times the message was leased',
+    `visible_at`      datetime     NOT NULL COMMENT 'the message can be leased after it',
+    `created_at`      datetime     NOT NULL DEFAULT CURRENT_TIMESTAMP COMMENT 'created time',
+    `updated_at`      datetime     NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP COMMENT 'updated time',
+    PRIMARY KEY (`id`),
+    UNIQUE KEY `uk_idempotency_key` (`idempotency_key`),
+    KEY `idx_status_visible_at` (`status`, `visible_at`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='durable message queue of eventbox';
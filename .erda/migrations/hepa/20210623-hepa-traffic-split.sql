@@ -0,0 +1,14 @@
+CREATE TABLE `tb_gateway_traffic_split`
+(
+    `id`               varchar(32)  NOT NULL DEFAULT '' COMMENT '唯一id',
+    `package_id`       varchar(32)  NOT NULL DEFAULT '' COMMENT '所属的流量入口',
+    `api_id`           varchar(32)  NOT NULL DEFAULT '' COMMENT '所属的流量入口api',
+    `kong_upstream_id` varchar(128) NOT NULL DEFAULT '' COMMENT 'kong的upstream_id',
+    `config`           text         NOT NULL COMMENT '分流配置',
+    `kong_routes`      text         NOT NULL COMMENT '按规则分流的kong路由和服务',
+    `is_deleted`       tinyint(1)   NOT NULL DEFAULT '0' COMMENT '逻辑删除',
+    `created_at`       datetime     NOT NULL DEFAULT CURRENT_TIMESTAMP COMMENT '创建时间',
+    `updated_at`       datetime     NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP COMMENT '更新时间',
+    PRIMARY KEY (`id`),
+    KEY `idx_api_id` (`api_id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='API 网关流量入口 API 的分流配置';
@@ -0,0 +1,13 @@
+CREATE TABLE `tb_gateway_mock_spec`
+(
+    `id`               varchar(32)  NOT NULL DEFAULT '' COMMENT '唯一id',
+    `az`               varchar(128) NOT NULL DEFAULT '' COMMENT '集群名',
+    `head_key`         varchar(191) NOT NULL DEFAULT '' COMMENT 'mock标识',
+    `spec`             mediumtext   NOT NULL COMMENT 'OpenAPI 接口规范',
+    `is_skip_validate` tinyint(1)   NOT NULL DEFAULT '0' COMMENT '是否跳过请求校验',
+    `is_deleted`       tinyint(1)   NOT NULL DEFAULT '0' COMMENT '逻辑删除',
+    `created_at`       datetime     NOT NULL DEFAULT CURRENT_TIMESTAMP COMMENT '创建时间',
+    `updated_at`       datetime     NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP COMMENT '更新时间',
+    PRIMARY KEY (`id`),
+    UNIQUE KEY `uk_head_key` (`head_key`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='API 网关基于 OpenAPI 规范的 mock 配置';
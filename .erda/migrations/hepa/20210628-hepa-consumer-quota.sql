@@ -0,0 +1,14 @@
+CREATE TABLE `tb_gateway_consumer_quota`
+(
+    `id`          varchar(32)  NOT NULL DEFAULT '' COMMENT '唯一id',
+    `consumer_id` varchar(32)  NOT NULL DEFAULT '' COMMENT '消费者id',
+    `period`      varchar(16)  NOT NULL DEFAULT '' COMMENT '计费周期',
+    `quota`       bigint(20)   NOT NULL DEFAULT '0' COMMENT '周期内请求配额',
+    `burst`       int(11)      NOT NULL DEFAULT '0' COMMENT '每秒突发请求数',
+    `plugin_id`   varchar(128) NOT NULL DEFAULT '' COMMENT 'kong的插件id',
+    `is_deleted`  tinyint(1)   NOT NULL DEFAULT '0' COMMENT '逻辑删除',
+    `created_at`  datetime     NOT NULL DEFAULT CURRENT_TIMESTAMP COMMENT '创建时间',
+    `updated_at`  datetime     NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP COMMENT '更新时间',
+    PRIMARY KEY (`id`),
+    KEY `idx_consumer_id` (`consumer_id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='API 网关调用方的请求配额';
@@ -0,0 +1,13 @@
+CREATE TABLE `tb_gateway_upstream_resilience`
+(
+    `id`               varchar(32)  NOT NULL DEFAULT '' COMMENT '唯一id',
+    `package_id`       varchar(32)  NOT NULL DEFAULT '' COMMENT '所属的流量入口',
+    `api_id`           varchar(32)  NOT NULL DEFAULT '' COMMENT '所属的流量入口api',
+    `kong_upstream_id` varchar(128) NOT NULL DEFAULT '' COMMENT '未分流时独占的kong upstream_id',
+    `config`           text         NOT NULL COMMENT '容错配置',
+    `is_deleted`       tinyint(1)   NOT NULL DEFAULT '0' COMMENT '逻辑删除',
+    `created_at`       datetime     NOT NULL DEFAULT CURRENT_TIMESTAMP COMMENT '创建时间',
+    `updated_at`       datetime     NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP COMMENT '更新时间',
+    PRIMARY KEY (`id`),
+    KEY `idx_api_id` (`api_id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='API 网关流量入口 API 的后端容错配置';
@@ -0,0 +1,3 @@
+ALTER TABLE `tb_gateway_package_api`
+    ADD COLUMN `protocol` varchar(16) NOT NULL DEFAULT 'http' COMMENT '路由协议',
+    ADD COLUMN `protocol_config` mediumtext NOT NULL COMMENT '协议相关配置，如grpc的proto描述和websocket的空闲超时';
@@ -0,0 +1,49 @@
+CREATE TABLE `sp_alert_silence`
+(
+    `id`         bigint(20) unsigned NOT NULL AUTO_INCREMENT COMMENT 'primary key',
+    `scope`      varchar(64)   NOT NULL DEFAULT '' COMMENT 'alert scope, org or micro_service',
+    `scope_id`   varchar(128)  NOT NULL DEFAULT '' COMMENT 'alert scope id',
+    `matchers`   varchar(4096) NOT NULL DEFAULT '' COMMENT 'tag matchers in json',
+    `starts_at`  datetime      NOT NULL COMMENT 'silence start time',
+    `ends_at`    datetime      NOT NULL COMMENT 'silence end time',
+    `creator`    varchar(255)  NOT NULL DEFAULT '' COMMENT 'user who created the silence',
+    `remark`     varchar(1024) NOT NULL DEFAULT '' COMMENT 'silence remark',
+    `created_at` datetime      NOT NULL DEFAULT CURRENT_TIMESTAMP COMMENT 'created time',
+    `updated_at` datetime      NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP COMMENT 'updated time',
+    PRIMARY KEY (`id`),
+    KEY `idx_scope` (`scope`, `scope_id`),
+    KEY `idx_ends_at` (`ends_at`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='time-bounded alert silences';
+
+CREATE TABLE `sp_alert_inhibit_rule`
+(
+    `id`              bigint(20) unsigned NOT NULL AUTO_INCREMENT COMMENT 'primary key',
+    `scope`           varchar(64)   NOT NULL DEFAULT '' COMMENT 'alert scope, org or micro_service',
+    `scope_id`        varchar(128)  NOT NULL DEFAULT '' COMMENT 'alert scope id',
+    `name`            varchar(255)  NOT NULL DEFAULT '' COMMENT 'rule name',
+    `source_matchers` varchar(4096) NOT NULL DEFAULT '' COMMENT 'matchers of the inhibiting alerts in json',
+    `target_matchers` varchar(4096) NOT NULL DEFAULT '' COMMENT 'matchers of the inhibited alerts in json',
+    `equal_tags`      varchar(1024) NOT NULL DEFAULT '' COMMENT 'tags must be equal in source and target, split by comma',
+    `is_enabled`      tinyint(1)    NOT NULL DEFAULT '1' COMMENT 'rule switch',
+    `created_at`      datetime      NOT NULL DEFAULT CURRENT_TIMESTAMP COMMENT 'created time',
+    `updated_at`      datetime      NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP COMMENT 'updated time',
+    PRIMARY KEY (`id`),
+    KEY `idx_scope` (`scope`, `scope_id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='alert inhibition rules';
+
+CREATE TABLE `sp_alert_group_rule`
+(
+    `id`             bigint(20) unsigned NOT NULL AUTO_INCREMENT COMMENT 'primary key',
+    `scope`          varchar(64)   NOT NULL DEFAULT '' COMMENT 'alert scope, org or micro_service',
+    `scope_id`       varchar(128)  NOT NULL DEFAULT '' COMMENT 'alert scope id',
+    `name`           varchar(255)  NOT NULL DEFAULT '' COMMENT 'rule name',
+    `matchers`       varchar(4096) NOT NULL DEFAULT '' COMMENT 'matchers of the grouped alerts in json',
+    `group_by`       varchar(1024) NOT NULL DEFAULT '' COMMENT 'group by tags, split by comma',
+    `group_wait`     int(11)       NOT NULL DEFAULT '30' COMMENT 'seconds to wait before the first notification of a group',
+    `group_interval` int(11)       NOT NULL DEFAULT '300' COMMENT 'minimum seconds between notifications of a group',
+    `is_enabled`     tinyint(1)    NOT NULL DEFAULT '1' COMMENT 'rule switch',
+    `created_at`     datetime      NOT NULL DEFAULT CURRENT_TIMESTAMP COMMENT 'created time',
+    `updated_at`     datetime      NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP COMMENT 'updated time',
+    PRIMARY KEY (`id`),
+    KEY `idx_scope` (`scope`, `scope_id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='alert notification grouping rules';
@@ -122,6 +122,8 @@ type GroupNotifyContent struct {
 	Label                 string               `json:"label"`
 	ClusterName           string               `json:"clusterName"`
 	CalledShowNumber      string               `json:"calledShowNumber"`
+	// 渲染 eventbox 消息模板所用的语言
+	Locale string `json:"locale,omitempty"`
 }
 
 type GroupNotifyChannel struct {
@@ -130,6 +132,15 @@ type GroupNotifyChannel struct {
 	Type     string            `json:"type"` // 用于mail模式渲染 值为markdown会二次渲染html
 	Tag      string            `json:"tag"`  //  用于webhook的附加信息
 	Params   map[string]string `json:"params"`
+	// 邮件附件
+	Attachments []*GroupNotifyAttachment `json:"attachments,omitempty"`
+}
+
+// GroupNotifyAttachment 邮件附件, Encoding 为 base64 时 Content 为 base64 编码后的内容
+type GroupNotifyAttachment struct {
+	Filename string `json:"filename"`
+	Content  string `json:"content"`
+	Encoding string `json:"encoding"`
 }
 
 // ExtensionPushEvent 扩展更新事件
@@ -30,3 +30,77 @@ type EventBoxGroupNotifyRequest struct {
 	NotifyContent *GroupNotifyContent
 	Params        map[string]string
 }
+
+// MessageTemplate eventbox 消息模板，按 event、channel、locale 唯一确定
+type MessageTemplate struct {
+	// 事件类型，如通知项名字、webhook 事件
+	Event string `json:"event"`
+
+	// 通知渠道: dingding, email, sms, vms, mbox, slack, teams, feishu, wecom; default 对所有渠道生效
+	Channel string `json:"channel"`
+
+	// 语言，如 zh-CN, en; 为空代表默认
+	Locale string `json:"locale"`
+
+	// 标题模板，Go template 语法; sms, vms 渠道为服务商的模板编号
+	Subject string `json:"subject"`
+
+	// 内容模板，Go template 语法; sms, vms 渠道渲染结果为服务商模板参数的 JSON
+	Body string `json:"body"`
+
+	// 内容格式: text, markdown, html; html 会对变量做转义
+	Format string `json:"format"`
+
+	CreatedAt string `json:"createdAt"`
+	UpdatedAt string `json:"updatedAt"`
+}
+
+// OrgAlertNotifySampleTemplateEvent 企业自定义告警通知模板样例的内置消息模板，样例消息的 params 为告警模板的占位符
+const OrgAlertNotifySampleTemplateEvent = "org_alert_notify_sample"
+
+// MessageTemplateLabel 消息的 TEMPLATE label，指定渲染消息所用的模板
+type MessageTemplateLabel struct {
+	Event  string            `json:"event"`
+	Locale string            `json:"locale"`
+	Params map[string]string `json:"params"`
+}
+
+// MessageTemplateListResponse 消息模板列表
+// Path:         "/api/message-templates",
+// BackendPath:  "/api/dice/eventbox/message_templates",
+type MessageTemplateListResponse struct {
+	Header
+	Data []MessageTemplate `json:"data"`
+}
+
+// MessageTemplateInspectResponse 消息模板详情
+// Path:         "/api/message-templates/<event>/<channel>/<locale>",
+// BackendPath:  "/api/dice/eventbox/message_templates/<event>/<channel>/<locale>",
+type MessageTemplateInspectResponse struct {
+	Header
+	Data MessageTemplate `json:"data"`
+}
+
+// MessageTemplatePreviewRequest 用样例消息渲染模板
+// Path:         "/api/message-templates/actions/preview",
+// BackendPath:  "/api/dice/eventbox/message_templates/actions/preview",
+type MessageTemplatePreviewRequest struct {
+	// 要预览的模板，subject 和 body 为空时使用已保存的 event、channel、locale 对应的模板
+	Template MessageTemplate `json:"template"`
+
+	// 样例消息
+	Message EventBoxRequest `json:"message"`
+}
+
+// MessageTemplatePreviewResponse 预览结果
+type MessageTemplatePreviewResponse struct {
+	Header
+	Data MessageTemplateRendered `json:"data"`
+}
+
+// MessageTemplateRendered 模板渲染结果
+type MessageTemplateRendered struct {
+	Subject string `json:"subject"`
+	Body    string `json:"body"`
+	Format  string `json:"format"`
+}
@@ -45,6 +45,8 @@ type EventHeader struct {
 	ProjectID     string `json:"projectID"`
 	ApplicationID string `json:"applicationID"`
 	Env           string `json:"env"`
+	// content 的版本, 见 eventbox webhook_events 的 schemas, 为空即 v1
+	Version string `json:"version,omitempty"`
 	// Content   PlaceHolder `json:"content"`
 	TimeStamp string `json:"timestamp"`
 }
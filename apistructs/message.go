@@ -50,11 +50,26 @@ const (
 
 	// MySQLLabel "MYSQL": "<table-name>"
 	MySQLLabel MessageLabel = "MYSQL"
+
+	// AlertDispatchedLabel "/ALERT-DISPATCHED": true, the alert message has been dispatched by monitor
+	AlertDispatchedLabel MessageLabel = "/ALERT-DISPATCHED"
 )
 
+// AlertNotifySender is the sender of alert notifications
+const AlertNotifySender = "analyzer-alert"
+
 // MessageCreateRequest see also `bundle/messages.go'
 type MessageCreateRequest struct {
 	Sender  string                       `json:"sender"`
 	Content interface{}                  `json:"content"`
 	Labels  map[MessageLabel]interface{} `json:"labels"`
 }
+
+// AlertNotification the alert notification forwarded by eventbox to the alert dispatcher of monitor,
+// Message is sent back with AlertDispatchedLabel unless it is muted by silences or inhibit rules.
+type AlertNotification struct {
+	Tags       map[string]string     `json:"tags"`
+	AlertState string                `json:"alertState"`
+	Timestamp  int64                 `json:"timestamp"` // milliseconds
+	Message    *MessageCreateRequest `json:"message"`
+}
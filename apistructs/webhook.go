@@ -13,6 +13,8 @@
 
 package apistructs
 
+import "encoding/json"
+
 //go:generate go run ../pkg/structparser/comment/comment.go -pkg-name apistructs
 
 // WebhookListResponse webhook 列表
@@ -135,6 +137,15 @@ type WebhookUpdateRequestBody struct {
 
 	// 是否激活，如果没有该参数，默认为false
 	Active bool `json:"active"`
+
+	// 更新签名密钥，为空则不修改
+	Secret string `json:"secret"`
+
+	// 更新过滤表达式，为 nil 则不修改，为空则清除
+	Filter *string `json:"filter"`
+
+	// 更新事件的 payload 版本，为 nil 则不修改
+	EventVersions map[string]string `json:"eventVersions"`
 }
 
 // WebhookUpdateResponseData WebhookUpdateResponse 的 Data
@@ -179,6 +190,122 @@ type WebhookListEventsResponseData []struct {
 	Desc string `json:"desc"`
 }
 
+// WebhookListDeliveriesResponse webhook 最近的投递记录
+// Path:         "/api/webhooks/<id>/deliveries",
+// BackendPath:  "/api/dice/eventbox/webhooks/<id>/deliveries",
+type WebhookListDeliveriesResponse struct {
+	Header
+	Data []WebhookDelivery `json:"data"`
+}
+
+// WebhookInspectDeliveryResponse webhook 投递记录详情
+// Path:         "/api/webhooks/<id>/deliveries/<deliveryID>",
+// BackendPath:  "/api/dice/eventbox/webhooks/<id>/deliveries/<deliveryID>",
+type WebhookInspectDeliveryResponse struct {
+	Header
+	Data WebhookDelivery `json:"data"`
+}
+
+// WebhookRedeliverResponse 重新投递，返回新的投递记录
+// Path:         "/api/webhooks/<id>/deliveries/<deliveryID>/actions/redeliver",
+// BackendPath:  "/api/dice/eventbox/webhooks/<id>/deliveries/<deliveryID>/actions/redeliver",
+type WebhookRedeliverResponse struct {
+	Header
+	Data WebhookDelivery `json:"data"`
+}
+
+// WebhookListDeadLettersResponse 重试耗尽后仍失败的投递
+// Path:         "/api/webhooks/<id>/deadletters",
+// BackendPath:  "/api/dice/eventbox/webhooks/<id>/deadletters",
+type WebhookListDeadLettersResponse struct {
+	Header
+	Data []WebhookDelivery `json:"data"`
+}
+
+// WebhookListEventSchemasResponse 事件各个版本的 payload schema
+// Path:         "/api/webhook_events/<event>/schemas",
+// BackendPath:  "/api/dice/eventbox/webhook_events/<event>/schemas",
+type WebhookListEventSchemasResponse struct {
+	Header
+	Data []WebhookEventSchema `json:"data"`
+}
+
+// WebhookInspectEventSchemaResponse 事件某个版本的 payload schema, version 为 latest 时返回最新版本
+// Path:         "/api/webhook_events/<event>/schemas/<version>",
+// BackendPath:  "/api/dice/eventbox/webhook_events/<event>/schemas/<version>",
+type WebhookInspectEventSchemaResponse struct {
+	Header
+	Data WebhookEventSchema `json:"data"`
+}
+
+// WebhookEventSampleResponse 事件某个版本的完整 payload 示例
+// Path:         "/api/webhook_events/<event>/schemas/<version>/sample",
+// BackendPath:  "/api/dice/eventbox/webhook_events/<event>/schemas/<version>/sample",
+type WebhookEventSampleResponse struct {
+	Header
+	Data json.RawMessage `json:"data"`
+}
+
+// WebhookEventSchema 事件某个版本的 payload content 的 JSON Schema (OpenAPI 3.0 schema)
+type WebhookEventSchema struct {
+	Event   string `json:"event"`
+	Version string `json:"version"`
+	Desc    string `json:"desc"`
+
+	// 已废弃的版本仍然可以固定，但不建议使用
+	Deprecated bool `json:"deprecated,omitempty"`
+
+	Schema json.RawMessage `json:"schema"`
+	// content 的示例
+	Sample json.RawMessage `json:"sample"`
+}
+
+// WebhookDelivery 一次事件投递的记录，包含最后一次尝试的请求和响应
+type WebhookDelivery struct {
+	ID     string `json:"id"`
+	HookID string `json:"hookID"`
+	Event  string `json:"event"`
+	URL    string `json:"url"`
+
+	// 投递状态: pending(投递中或等待重试), succeeded, dead(放弃重试，已进入死信)
+	Status string `json:"status"`
+
+	// 已尝试次数
+	Attempts int `json:"attempts"`
+
+	// 下次重试时间，仅 pending 状态有效
+	NextAttemptAt string `json:"nextAttemptAt,omitempty"`
+
+	// 由哪个投递重新投递而来
+	RedeliveryOf string `json:"redeliveryOf,omitempty"`
+
+	Request  WebhookDeliveryRequest   `json:"request"`
+	Response *WebhookDeliveryResponse `json:"response,omitempty"`
+
+	// 死信中请求体超长被截断，不能重新投递
+	Truncated bool `json:"truncated,omitempty"`
+
+	// 最后一次尝试的耗时，单位毫秒
+	Latency int64  `json:"latency"`
+	Error   string `json:"error,omitempty"`
+
+	CreatedAt string `json:"createdAt"`
+	UpdatedAt string `json:"updatedAt"`
+}
+
+// WebhookDeliveryRequest 投递请求
+type WebhookDeliveryRequest struct {
+	Headers map[string]string `json:"headers"`
+	Body    string            `json:"body"`
+}
+
+// WebhookDeliveryResponse 投递响应，body 超长会被截断
+type WebhookDeliveryResponse struct {
+	StatusCode int               `json:"statusCode"`
+	Headers    map[string]string `json:"headers"`
+	Body       string            `json:"body"`
+}
+
 // Hook 代表 webhook 的结构
 type Hook struct {
 	// webhook ID
@@ -186,7 +313,8 @@ type Hook struct {
 	UpdatedAt string `json:"updatedAt"`
 	CreatedAt string `json:"createdAt"`
 
-	// 用于计算后续发送的事件内容的sha值，目前没有用
+	// 签名密钥，投递时以 HMAC-SHA256 对请求体签名，放在 X-Erda-Signature 头中，为空则不签名；
+	// 由创建或更新请求提供，查询接口只返回掩码
 	Secret string `json:"secret"`
 
 	CreateHookRequest
@@ -202,6 +330,11 @@ type CreateHookRequest struct {
 	URL string `json:"url"`
 	// 是否激活
 	Active bool `json:"active"`
+	// 过滤表达式，只有满足表达式的事件才会投递，为空则投递所有事件，语法见 modules/eventbox/register/doc.go
+	// e.g. content.status == "Failed" && event.env in ["PROD", "STAGING"]
+	Filter string `json:"filter"`
+	// 固定事件 payload 的版本, e.g. {"pipeline": "v1"}, 未固定的事件以产生时的版本投递
+	EventVersions map[string]string `json:"eventVersions,omitempty"`
 	HookLocation
 }
 
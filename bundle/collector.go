@@ -59,3 +59,23 @@ func (b *Bundle) CollectMetrics(metrics *apistructs.Metrics) error {
 	}
 	return nil
 }
+
+// CollectAlertNotification 转发告警通知到告警分发
+func (b *Bundle) CollectAlertNotification(n *apistructs.AlertNotification) error {
+	host, err := b.urls.Collector()
+	if err != nil {
+		return err
+	}
+	hc := b.hc
+	resp, err := hc.Post(host).Path("/collect/alert-notify").
+		Header("Internal-Client", "bundle").
+		JSONBody(map[string][]*apistructs.AlertNotification{"alert-notify": {n}}).
+		Do().DiscardBody()
+	if err != nil {
+		return apierrors.ErrInvoke.InternalError(err)
+	}
+	if !resp.IsOK() {
+		return apierrors.ErrInvoke.InternalError(fmt.Errorf("failed to call monitor status %d", resp.StatusCode()))
+	}
+	return nil
+}
@@ -45,3 +45,24 @@ func (b *Bundle) CreateMessage(message *apistructs.MessageCreateRequest) error {
 	}
 	return nil
 }
+
+// PreviewMessageTemplate 用样例消息渲染 eventbox 消息模板，模板的 subject 和 body 为空时使用已注册或内置的模板
+func (b *Bundle) PreviewMessageTemplate(req *apistructs.MessageTemplatePreviewRequest) (*apistructs.MessageTemplateRendered, error) {
+	host, err := b.urls.EventBox()
+	if err != nil {
+		return nil, err
+	}
+	hc := b.hc
+
+	var resp apistructs.MessageTemplatePreviewResponse
+	r, err := hc.Post(host).Path("/api/dice/eventbox/message_templates/actions/preview").
+		Header("Accept", "application/json").
+		JSONBody(req).Do().JSON(&resp)
+	if err != nil {
+		return nil, apierrors.ErrInvoke.InternalError(err)
+	}
+	if !r.IsOK() || !resp.Success {
+		return nil, toAPIError(r.StatusCode(), resp.Error)
+	}
+	return &resp.Data, nil
+}
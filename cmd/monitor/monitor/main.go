@@ -32,6 +32,7 @@ import (
 	_ "github.com/erda-project/erda/modules/monitor/core/metrics/index"
 	_ "github.com/erda-project/erda/modules/monitor/core/metrics/metricq"
 	_ "github.com/erda-project/erda/modules/monitor/core/metrics/metricq-example"
+	_ "github.com/erda-project/erda/modules/monitor/core/metrics/rollup"
 	_ "github.com/erda-project/erda/modules/monitor/dashboard/chart-block"
 	_ "github.com/erda-project/erda/modules/monitor/dashboard/node-topo"
 	_ "github.com/erda-project/erda/modules/monitor/dashboard/org-apis"
@@ -33,6 +33,7 @@ import (
 	_ "github.com/erda-project/erda-infra/providers/pprof"
 
 	//storage record
+	_ "github.com/erda-project/erda/modules/monitor/alert/dispatcher"
 	_ "github.com/erda-project/erda/modules/monitor/alert/storage/alert-record"
 	_ "github.com/erda-project/erda/modules/monitor/notify/storage/notify-record"
 )
@@ -19,6 +19,7 @@ import (
 	"strconv"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/erda-project/erda/pkg/discover"
 )
@@ -268,3 +269,137 @@ func Proxy() string {
 func BundleUserID() string {
 	return "1101"
 }
+
+// WebhookRetryInterval is the delay before the first retry of a failed webhook delivery, doubled on every retry.
+func WebhookRetryInterval() time.Duration {
+	return durationFromEnv("WEBHOOK_RETRY_INTERVAL", 10*time.Second)
+}
+
+// WebhookRetryMaxInterval caps the delay between two retries.
+func WebhookRetryMaxInterval() time.Duration {
+	return durationFromEnv("WEBHOOK_RETRY_MAX_INTERVAL", 10*time.Minute)
+}
+
+// WebhookRetryMaxAttempts is the max number of attempts of a webhook delivery, including the first one.
+func WebhookRetryMaxAttempts() int {
+	return intFromEnv("WEBHOOK_RETRY_MAX_ATTEMPTS", 8)
+}
+
+// WebhookRetryMaxAge is the max age of a webhook delivery, it goes to the dead letters when no attempt succeeds within it.
+func WebhookRetryMaxAge() time.Duration {
+	return durationFromEnv("WEBHOOK_RETRY_MAX_AGE", 24*time.Hour)
+}
+
+// WebhookDeliveryTimeout is the timeout of a single attempt.
+func WebhookDeliveryTimeout() time.Duration {
+	return durationFromEnv("WEBHOOK_DELIVERY_TIMEOUT", 10*time.Second)
+}
+
+// WebhookDeliveryHistory is the number of recent deliveries kept for each webhook.
+func WebhookDeliveryHistory() int {
+	return intFromEnv("WEBHOOK_DELIVERY_HISTORY", 50)
+}
+
+// WebhookStrictSchema makes the webhook deliveries whose content breaks the schema of the event dead,
+// or they are logged and delivered.
+func WebhookStrictSchema() bool {
+	return os.Getenv("WEBHOOK_STRICT_SCHEMA") == "true"
+}
+
+// WebhookDeadLetters is the max number of dead letters kept for each webhook, the oldest ones are removed beyond it.
+func WebhookDeadLetters() int {
+	return intFromEnv("WEBHOOK_DEAD_LETTERS", 100)
+}
+
+// WebhookDeadLetterMaxAge is how long a dead letter is kept.
+func WebhookDeadLetterMaxAge() time.Duration {
+	return durationFromEnv("WEBHOOK_DEAD_LETTER_MAX_AGE", 7*24*time.Hour)
+}
+
+// Queue is the backend of the durable queue input: file, mysql, or empty to dispatch messages in memory.
+func Queue() string {
+	return os.Getenv("EVENTBOX_QUEUE")
+}
+
+// QueueDir is the directory of the file queue.
+func QueueDir() string {
+	if dir := os.Getenv("EVENTBOX_QUEUE_DIR"); dir != "" {
+		return dir
+	}
+	return "/data/eventbox/queue"
+}
+
+// QueueBatchSize is the max number of queued messages dispatched at the same time.
+func QueueBatchSize() int {
+	return intFromEnv("EVENTBOX_QUEUE_BATCH_SIZE", 32)
+}
+
+// QueueLeaseTTL is how long a queued message is invisible to other instances after leased, it is redelivered after that if not acked.
+func QueueLeaseTTL() time.Duration {
+	return durationFromEnv("EVENTBOX_QUEUE_LEASE_TTL", 5*time.Minute)
+}
+
+// QueuePollInterval is the interval of polling an empty queue.
+func QueuePollInterval() time.Duration {
+	return durationFromEnv("EVENTBOX_QUEUE_POLL_INTERVAL", time.Second)
+}
+
+// QueueRetention is how long the idempotency keys of dispatched messages are kept.
+func QueueRetention() time.Duration {
+	return durationFromEnv("EVENTBOX_QUEUE_RETENTION", 24*time.Hour)
+}
+
+// QueueLagAlert is the lag of the queue to alert on.
+func QueueLagAlert() time.Duration {
+	return durationFromEnv("EVENTBOX_QUEUE_LAG_ALERT", time.Minute)
+}
+
+// DigestWindow is the window of batching the messages to a recipient, the digest is disabled if it is not set.
+func DigestWindow() time.Duration {
+	return durationFromEnv("EVENTBOX_DIGEST_WINDOW", 0)
+}
+
+// DigestLimit is the number of messages delivered directly to a recipient in a digest window.
+func DigestLimit() int {
+	return intFromEnv("EVENTBOX_DIGEST_LIMIT", 3)
+}
+
+// DigestChannels are the subscribers delivering messages through the digest.
+func DigestChannels() []string {
+	channels := os.Getenv("EVENTBOX_DIGEST_CHANNELS")
+	if channels == "" {
+		channels = "DINGDING,EMAIL,MBOX,SLACK,TEAMS,FEISHU,WECOM"
+	}
+	var r []string
+	for _, c := range strings.Split(channels, ",") {
+		if c = strings.TrimSpace(c); c != "" {
+			r = append(r, strings.ToUpper(c))
+		}
+	}
+	return r
+}
+
+// AlertDispatch forwards the alert notifications to the alert dispatcher of monitor to apply silences, inhibit rules and grouping.
+func AlertDispatch() bool {
+	enable, err := boolFromString(os.Getenv("EVENTBOX_ALERT_DISPATCH"))
+	if err != nil {
+		return true
+	}
+	return enable
+}
+
+func durationFromEnv(key string, def time.Duration) time.Duration {
+	d, err := time.ParseDuration(os.Getenv(key))
+	if err != nil || d <= 0 {
+		return def
+	}
+	return d
+}
+
+func intFromEnv(key string, def int) int {
+	i, err := strconv.Atoi(os.Getenv(key))
+	if err != nil || i <= 0 {
+		return def
+	}
+	return i
+}
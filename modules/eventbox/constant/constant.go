@@ -32,8 +32,27 @@ var (
 	// register
 	RegisterDir      = filepath.Join(EventboxDir, "register")
 	RegisterLabelKey = "/REGISTERED_LABEL"
+	// filter expression of a registration, see package expr
+	FilterLabelKey = "/FILTER"
 
 	// webhook
 	WebhookLabelKey = "/WEBHOOK"
 	WebhookDir      = filepath.Join(EventboxDir, "webhook")
+
+	// webhook delivery
+	WebhookDeliveryLabelKey = "/WEBHOOK-DELIVERY"
+	WebhookDeliveryDir      = filepath.Join(EventboxDir, "webhook-deliveries")
+	WebhookDeadLetterDir    = filepath.Join(EventboxDir, "webhook-deadletters")
+
+	// message template
+	TemplateLabelKey = "/TEMPLATE"
+	TemplateDir      = filepath.Join(EventboxDir, "templates")
+
+	// durable queue
+	IdempotencyLabelKey = "/IDEMPOTENCY-KEY"
+
+	// digest
+	DigestDir = filepath.Join(EventboxDir, "digest")
+	// urgent messages bypass the digest
+	UrgentLabelKey = "/URGENT"
 )
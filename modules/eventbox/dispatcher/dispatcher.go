@@ -15,6 +15,7 @@ package dispatcher
 
 import (
 	"context"
+	"fmt"
 	"net/http"
 	"sync"
 
@@ -24,20 +25,28 @@ import (
 	"github.com/erda-project/erda/modules/eventbox/input"
 	etcdinput "github.com/erda-project/erda/modules/eventbox/input/etcd"
 	httpinput "github.com/erda-project/erda/modules/eventbox/input/http"
+	queueinput "github.com/erda-project/erda/modules/eventbox/input/queue"
 	"github.com/erda-project/erda/modules/eventbox/monitor"
+	"github.com/erda-project/erda/modules/eventbox/msgtemplate"
 	"github.com/erda-project/erda/modules/eventbox/register"
 	"github.com/erda-project/erda/modules/eventbox/server"
 	stypes "github.com/erda-project/erda/modules/eventbox/server/types"
 	"github.com/erda-project/erda/modules/eventbox/subscriber"
+	"github.com/erda-project/erda/modules/eventbox/subscriber/digest"
 	dingdingsubscriber "github.com/erda-project/erda/modules/eventbox/subscriber/dingding"
 	dingdingworknoticesubscriber "github.com/erda-project/erda/modules/eventbox/subscriber/dingding_worknotice"
 	emailsubscriber "github.com/erda-project/erda/modules/eventbox/subscriber/email"
 	fakesubscriber "github.com/erda-project/erda/modules/eventbox/subscriber/fake"
+	feishusubscriber "github.com/erda-project/erda/modules/eventbox/subscriber/feishu"
 	groupsubscriber "github.com/erda-project/erda/modules/eventbox/subscriber/group"
 	httpsubscriber "github.com/erda-project/erda/modules/eventbox/subscriber/http"
 	mbox "github.com/erda-project/erda/modules/eventbox/subscriber/mbox"
+	slacksubscriber "github.com/erda-project/erda/modules/eventbox/subscriber/slack"
 	smssubscriber "github.com/erda-project/erda/modules/eventbox/subscriber/sms"
+	teamssubscriber "github.com/erda-project/erda/modules/eventbox/subscriber/teams"
 	vmssubscriber "github.com/erda-project/erda/modules/eventbox/subscriber/vms"
+	webhooksubscriber "github.com/erda-project/erda/modules/eventbox/subscriber/webhook"
+	wecomsubscriber "github.com/erda-project/erda/modules/eventbox/subscriber/wecom"
 	"github.com/erda-project/erda/modules/eventbox/webhook"
 	"github.com/erda-project/erda/modules/eventbox/websocket"
 	"github.com/erda-project/erda/pkg/goroutinepool"
@@ -62,6 +71,11 @@ type DispatcherImpl struct {
 	register        register.Register
 	inputs          []input.Input
 	httpserver      *server.Server
+	deliverer       *webhook.Deliverer
+	// queue is the durable input, the other inputs enqueue messages to it if it is enabled
+	queue *queueinput.QueueInput
+	// digest batches the messages to the recipients of some subscribers, nil if it is disabled
+	digest *digest.Digest
 
 	runningWg sync.WaitGroup
 }
@@ -93,26 +107,43 @@ func New() (Dispatcher, error) {
 	if err != nil {
 		return nil, err
 	}
+	templates, err := msgtemplate.New()
+	if err != nil {
+		return nil, err
+	}
 	httpS := httpsubscriber.New()
 	bundleS := bundle.New(bundle.WithCMDB())
-	dingdingS := dingdingsubscriber.New(conf.Proxy())
+	dingdingS := dingdingsubscriber.New(conf.Proxy(), dingdingsubscriber.WithTemplates(templates))
 	dingdingWorknoticeS := dingdingworknoticesubscriber.New(conf.Proxy())
-	mboxS := mbox.New(bundle.New(bundle.WithCMDB()))
+	mboxS := mbox.New(bundle.New(bundle.WithCMDB()), mbox.WithTemplates(templates))
 	emailS := emailsubscriber.New(conf.SmtpHost(), conf.SmtpPort(), conf.SmtpUser(), conf.SmtpPassword(),
-		conf.SmtpDisplayUser(), conf.SmtpIsSSL(), conf.SMTPInsecureSkipVerify(), bundleS)
+		conf.SmtpDisplayUser(), conf.SmtpIsSSL(), conf.SMTPInsecureSkipVerify(), bundleS, emailsubscriber.WithTemplates(templates))
 	smsS := smssubscriber.New(
 		conf.AliyunAccessKeyID(),
 		conf.AliyunAccessKeySecret(),
 		conf.AliyunSmsSignName(),
-		conf.AliyunSmsMonitorTemplateCode(), bundleS)
+		conf.AliyunSmsMonitorTemplateCode(), bundleS, smssubscriber.WithTemplates(templates))
 	vmsS := vmssubscriber.New(conf.AliyunAccessKeyID(), conf.AliyunAccessKeySecret(), conf.AliyunVmsMonitorTtsCode(),
-		conf.AliyunVmsMonitorCalledShowNumber(), bundleS)
+		conf.AliyunVmsMonitorCalledShowNumber(), bundleS, vmssubscriber.WithTemplates(templates))
 	groupS := groupsubscriber.New(bundleS)
+	slackS := slacksubscriber.New(conf.Proxy(), slacksubscriber.WithTemplates(templates))
+	teamsS := teamssubscriber.New(conf.Proxy(), teamssubscriber.WithTemplates(templates))
+	feishuS := feishusubscriber.New(conf.Proxy(), feishusubscriber.WithTemplates(templates))
+	wecomS := wecomsubscriber.New(conf.Proxy(), wecomsubscriber.WithTemplates(templates))
 	if err != nil {
 		return nil, err
 	}
 
-	wh, err := webhook.NewWebHookHTTP()
+	deliverer, err := webhook.NewDeliverer()
+	if err != nil {
+		return nil, err
+	}
+	dispatcher.deliverer = deliverer
+	if err := deliverer.Resume(); err != nil {
+		logrus.Errorf("webhook delivery: resume pending deliveries, err: %v", err)
+	}
+	webhookS := webhooksubscriber.New(deliverer)
+	wh, err := webhook.NewWebHookHTTP(deliverer)
 	if err != nil {
 		return nil, err
 	}
@@ -124,6 +155,15 @@ func New() (Dispatcher, error) {
 	dispatcher.RegisterInput(etcdi)
 	dispatcher.RegisterInput(httpi)
 	dispatcher.RegisterInput(wsi)
+	// register the queue last, so that it stops after the inputs enqueuing to it
+	queue, err := newQueueInput()
+	if err != nil {
+		return nil, err
+	}
+	if queue != nil {
+		dispatcher.queue = queue
+		dispatcher.RegisterInput(queue)
+	}
 
 	dispatcher.RegisterSubscriber(fakeS)
 	dispatcher.RegisterSubscriber(httpS)
@@ -134,6 +174,21 @@ func New() (Dispatcher, error) {
 	dispatcher.RegisterSubscriber(vmsS)
 	dispatcher.RegisterSubscriber(mboxS)
 	dispatcher.RegisterSubscriber(groupS)
+	dispatcher.RegisterSubscriber(webhookS)
+	dispatcher.RegisterSubscriber(slackS)
+	dispatcher.RegisterSubscriber(teamsS)
+	dispatcher.RegisterSubscriber(feishuS)
+	dispatcher.RegisterSubscriber(wecomS)
+
+	if window := conf.DigestWindow(); window > 0 {
+		dg := digest.New(js, digest.Config{Window: window, Limit: conf.DigestLimit()})
+		for _, name := range conf.DigestChannels() {
+			if s, ok := dispatcher.subscribers[name]; ok {
+				dispatcher.subscribers[name] = dg.Wrap(s)
+			}
+		}
+		dispatcher.digest = dg
+	}
 
 	for name := range dispatcher.subscribers {
 		dispatcher.subscriberspool[name] = goroutinepool.New(conf.PoolSize())
@@ -156,6 +211,10 @@ func New() (Dispatcher, error) {
 	server.AddEndPoints([]stypes.Endpoint{{"/version", http.MethodGet, getVersion}})
 	server.AddEndPoints(wh.GetHTTPEndPoints())
 	server.AddEndPoints(mon.GetHTTPEndPoints())
+	server.AddEndPoints(msgtemplate.NewHTTP(templates).GetHTTPEndPoints())
+	if queue != nil {
+		server.AddEndPoints(queue.GetHTTPEndPoints())
+	}
 	// add router for Websocket
 	server.Router().PathPrefix("/api/dice/eventbox").Path("/ws/{any:.*}").
 		Handler(sockjs.NewHandler("/api/dice/eventbox/ws", sockjs.DefaultOptions, wsi.HTTPHandle))
@@ -197,15 +256,24 @@ func (d *DispatcherImpl) Start() {
 	for _, pool := range d.subscriberspool {
 		pool.Start()
 	}
+	if d.digest != nil {
+		if err := d.digest.Start(); err != nil {
+			logrus.Errorf("dispatcher: start digest: %v", err)
+		}
+	}
 	d.runningWg.Add(len(d.inputs) + 1)
 	for _, i := range d.inputs {
-		go func(i input.Input) {
-			err = i.Start(d.router.Route)
+		handler := d.router.Route
+		if d.queue != nil && i != input.Input(d.queue) {
+			handler = d.queue.Enqueue
+		}
+		go func(i input.Input, handler input.Handler) {
+			err = i.Start(handler)
 			if err != nil {
 				logrus.Errorf("dispatcher: start %s err:%v", i.Name(), err)
 			}
 			d.runningWg.Done()
-		}(i)
+		}(i, handler)
 	}
 	// start httpserver
 	go func() {
@@ -221,6 +289,8 @@ func (d *DispatcherImpl) Start() {
 // 2. 等待 pool 里的所有消息发送完
 // 3. 关闭 pool
 // 4. 关闭 register
+// 5. 停止 webhook 重试
+// 6. 停止 digest, 未发送的摘要在重启后发送
 func (d *DispatcherImpl) Stop() {
 	logrus.Info("Dispatcher: stopping")
 	defer logrus.Info("Dispatcher: stopped")
@@ -239,6 +309,37 @@ func (d *DispatcherImpl) Stop() {
 	for _, pool := range d.subscriberspool {
 		pool.Stop()
 	}
+	// drop the pending webhook retries, they are recorded as pending deliveries
+	d.deliverer.Close()
+	if d.digest != nil {
+		d.digest.Stop()
+	}
+}
+
+// newQueueInput returns the durable queue input, or nil if it is disabled.
+func newQueueInput() (*queueinput.QueueInput, error) {
+	var q queueinput.Queue
+	var err error
+	switch conf.Queue() {
+	case "":
+		return nil, nil
+	case "file":
+		q, err = queueinput.NewFileQueue(conf.QueueDir())
+	case "mysql":
+		q, err = queueinput.NewMySQLQueue()
+	default:
+		return nil, fmt.Errorf("unknown queue: %s", conf.Queue())
+	}
+	if err != nil {
+		return nil, err
+	}
+	return queueinput.New(q, queueinput.Config{
+		BatchSize:    conf.QueueBatchSize(),
+		LeaseTTL:     conf.QueueLeaseTTL(),
+		PollInterval: conf.QueuePollInterval(),
+		Retention:    conf.QueueRetention(),
+		LagAlert:     conf.QueueLagAlert(),
+	})
 }
 
 func getVersion(ctx context.Context, req *http.Request, vars map[string]string) (stypes.Responser, error) {
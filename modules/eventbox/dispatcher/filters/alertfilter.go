@@ -0,0 +1,90 @@
+// Copyright (c) 2021 Terminus, Inc.
+//
+// This program is free software: you can use, redistribute, and/or modify
+// it under the terms of the GNU Affero General Public License, version 3
+// or later ("AGPL"), as published by the Free Software Foundation.
+//
+// This program is distributed in the hope that it will be useful, but WITHOUT
+// ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+// FITNESS FOR A PARTICULAR PURPOSE.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package filters
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/erda-project/erda/apistructs"
+	"github.com/erda-project/erda/modules/eventbox/constant"
+	"github.com/erda-project/erda/modules/eventbox/dispatcher/errors"
+	"github.com/erda-project/erda/modules/eventbox/msgtemplate"
+	"github.com/erda-project/erda/modules/eventbox/types"
+
+	"github.com/sirupsen/logrus"
+)
+
+// alertStateTag 告警通知参数中的告警状态: alert 或 recover
+const alertStateTag = "trigger"
+
+type collector interface {
+	CollectAlertNotification(n *apistructs.AlertNotification) error
+}
+
+// AlertFilter 将告警通知转发给 monitor 的告警分发, 由其按静默、抑制规则过滤并分组后带上 ALERT-DISPATCHED 标签再发回,
+// 转发失败时直接投递, 不丢失告警
+type AlertFilter struct {
+	collector collector
+}
+
+func NewAlertFilter(c collector) Filter {
+	return &AlertFilter{collector: c}
+}
+
+func (*AlertFilter) Name() string {
+	return "AlertFilter"
+}
+
+func (f *AlertFilter) Filter(m *types.Message) *errors.DispatchError {
+	derr := errors.New()
+	if m.Sender != apistructs.AlertNotifySender {
+		return derr
+	}
+	dispatched := types.LabelKey(apistructs.AlertDispatchedLabel).NormalizeLabelKey()
+	if _, ok := m.Labels[dispatched]; ok {
+		delete(m.Labels, dispatched)
+		return derr
+	}
+	labels := make(map[apistructs.MessageLabel]interface{}, len(m.Labels)+1)
+	for k, v := range m.Labels {
+		if k.Equal(constant.IdempotencyLabelKey) {
+			continue
+		}
+		labels[string(k)] = v
+	}
+	labels[apistructs.AlertDispatchedLabel] = true
+	params := msgtemplate.MessageParams(m)
+	n := &apistructs.AlertNotification{
+		Tags:       params,
+		AlertState: params[alertStateTag],
+		Timestamp:  m.Time / int64(time.Millisecond),
+		Message: &apistructs.MessageCreateRequest{
+			Sender:  m.Sender,
+			Content: m.Content,
+			Labels:  labels,
+		},
+	}
+	if n.Timestamp <= 0 {
+		n.Timestamp = time.Now().UnixNano() / int64(time.Millisecond)
+	}
+	if err := f.collector.CollectAlertNotification(n); err != nil {
+		logrus.Errorf("AlertFilter: forward alert notification failed, deliver it directly: %v", err)
+		derr.FilterInfo = fmt.Sprintf("AlertFilter: forward failed: %v", err)
+		return derr
+	}
+	// 由告警分发发回后再投递
+	m.Labels = map[types.LabelKey]interface{}{}
+	return derr
+}
@@ -0,0 +1,90 @@
+// Copyright (c) 2021 Terminus, Inc.
+//
+// This program is free software: you can use, redistribute, and/or modify
+// it under the terms of the GNU Affero General Public License, version 3
+// or later ("AGPL"), as published by the Free Software Foundation.
+//
+// This program is distributed in the hope that it will be useful, but WITHOUT
+// ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+// FITNESS FOR A PARTICULAR PURPOSE.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package filters
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/erda-project/erda/apistructs"
+	"github.com/erda-project/erda/modules/eventbox/constant"
+	"github.com/erda-project/erda/modules/eventbox/types"
+)
+
+type fakeCollector struct {
+	notifications []*apistructs.AlertNotification
+	err           error
+}
+
+func (c *fakeCollector) CollectAlertNotification(n *apistructs.AlertNotification) error {
+	if c.err != nil {
+		return c.err
+	}
+	c.notifications = append(c.notifications, n)
+	return nil
+}
+
+func alertMessage() *types.Message {
+	return &types.Message{
+		Sender: apistructs.AlertNotifySender,
+		Content: map[string]interface{}{
+			"params": map[string]interface{}{"alert_group": "g1", "alert_scope": "org", "alert_scope_id": "1", "trigger": "alert"},
+		},
+		Labels: map[types.LabelKey]interface{}{
+			"/GROUP": "1",
+			types.LabelKey(constant.IdempotencyLabelKey): "key",
+		},
+		Time: 1624000000000000000,
+	}
+}
+
+func TestAlertFilter(t *testing.T) {
+	c := &fakeCollector{}
+	f := NewAlertFilter(c)
+
+	m := alertMessage()
+	assert.True(t, f.Filter(m).IsOK())
+	assert.Empty(t, m.Labels, "forwarded message should not be delivered")
+	assert.Len(t, c.notifications, 1)
+	n := c.notifications[0]
+	assert.Equal(t, "g1", n.Tags["alert_group"])
+	assert.Equal(t, "alert", n.AlertState)
+	assert.Equal(t, int64(1624000000000), n.Timestamp)
+	assert.Equal(t, true, n.Message.Labels[apistructs.AlertDispatchedLabel])
+	assert.Equal(t, "1", n.Message.Labels["/GROUP"])
+	assert.NotContains(t, n.Message.Labels, constant.IdempotencyLabelKey)
+
+	// sent back by the dispatcher
+	m = alertMessage()
+	m.Labels[types.LabelKey(apistructs.AlertDispatchedLabel)] = true
+	assert.True(t, f.Filter(m).IsOK())
+	assert.Len(t, c.notifications, 1)
+	assert.Contains(t, m.Labels, types.LabelKey("/GROUP"))
+	assert.NotContains(t, m.Labels, types.LabelKey(apistructs.AlertDispatchedLabel))
+
+	// delivered directly if failed to forward
+	c.err = fmt.Errorf("unavailable")
+	m = alertMessage()
+	assert.True(t, f.Filter(m).IsOK())
+	assert.Contains(t, m.Labels, types.LabelKey("/GROUP"))
+
+	// not alert
+	c.err = nil
+	m = alertMessage()
+	m.Sender = "other"
+	assert.True(t, f.Filter(m).IsOK())
+	assert.Len(t, c.notifications, 1)
+}
@@ -19,6 +19,7 @@ import (
 
 	"github.com/erda-project/erda/modules/eventbox/constant"
 	"github.com/erda-project/erda/modules/eventbox/dispatcher/errors"
+	"github.com/erda-project/erda/modules/eventbox/expr"
 	"github.com/erda-project/erda/modules/eventbox/register"
 	"github.com/erda-project/erda/modules/eventbox/types"
 
@@ -52,6 +53,8 @@ func (r *RegisterFilter) Filter(m *types.Message) *errors.DispatchError {
 		derr.FilterErr = errStr
 		return derr
 	}
+	// evaluate the filters over the message as it came
+	var env map[string]interface{}
 	for _, key := range keys_ {
 		keyLabels := r.reg.PrefixGet(key)
 		if keyLabels == nil {
@@ -59,14 +62,34 @@ func (r *RegisterFilter) Filter(m *types.Message) *errors.DispatchError {
 			logrus.Warn(infoStr)
 			derr.FilterInfo = infoStr
 		}
-		for _, labels := range keyLabels {
+		for registered, labels := range keyLabels {
+			if filter, ok := labels[types.LabelKey(constant.FilterLabelKey)]; ok {
+				if env == nil {
+					env = expr.MessageEnv(m)
+				}
+				if !matchFilter(registered, filter, env) {
+					continue
+				}
+			}
 			m.Labels = mergeLabels(labels, m.Labels)
 		}
 	}
+	delete(m.Labels, types.LabelKey(constant.FilterLabelKey))
 	return derr
 
 }
 
+func matchFilter(registered types.LabelKey, filter interface{}, env map[string]interface{}) bool {
+	s, _ := filter.(string)
+	e, err := expr.CachedFilter(s)
+	if err != nil {
+		// validated when registered, so err != nil is a bug
+		logrus.Errorf("[alert][BUG] RegisterFilter: registered: %s, %v", registered, err)
+		return false
+	}
+	return e.Eval(env)
+}
+
 // l2 has higher priority
 func mergeLabels(l1, l2 map[types.LabelKey]interface{}) map[types.LabelKey]interface{} {
 	l := make(map[types.LabelKey]interface{})
@@ -13,6 +13,15 @@
 
 package filters
 
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/erda-project/erda/modules/eventbox/constant"
+	"github.com/erda-project/erda/modules/eventbox/types"
+)
+
 // func TestRegisterFilter(t *testing.T) {
 // 	r, err := register.New()
 // 	assert.Nil(t, err)
@@ -43,3 +52,51 @@ package filters
 // 	assert.Equal(t, []string{"aaa"}, m.Labels[types.LabelKey(constant.RegisterLabelKey)])
 
 // }
+
+type fakeRegister map[string]map[types.LabelKey]map[types.LabelKey]interface{}
+
+func (r fakeRegister) PrefixGet(key string) map[types.LabelKey]map[types.LabelKey]interface{} {
+	return r[key]
+}
+
+func (r fakeRegister) Put(key string, labels map[types.LabelKey]interface{}) error {
+	return nil
+}
+
+func (r fakeRegister) Del(key string) error {
+	return nil
+}
+
+func TestRegisterFilterWithFilter(t *testing.T) {
+	reg := fakeRegister{
+		"alert": {
+			"/alert/all": {"/MBOX": []string{"1"}},
+			"/alert/prod-failed": {
+				"/DINGDING":                             []string{"https://oapi.dingtalk.com/robot/send?access_token=x"},
+				types.LabelKey(constant.FilterLabelKey): `[content.status] == "Failed" && [labels.workspace] in ("PROD", "STAGING")`,
+			},
+		},
+	}
+	f := NewRegisterFilter(reg)
+	newMessage := func(status, workspace string) *types.Message {
+		return &types.Message{
+			Content: map[string]interface{}{"status": status},
+			Labels: map[types.LabelKey]interface{}{
+				types.LabelKey(constant.RegisterLabelKey): "alert",
+				"/workspace": workspace,
+			},
+		}
+	}
+
+	m := newMessage("Failed", "PROD")
+	assert.True(t, f.Filter(m).IsOK())
+	assert.NotNil(t, m.Labels["/MBOX"])
+	assert.NotNil(t, m.Labels["/DINGDING"])
+	_, ok := m.Labels[types.LabelKey(constant.FilterLabelKey)]
+	assert.False(t, ok)
+
+	m = newMessage("Failed", "DEV")
+	assert.True(t, f.Filter(m).IsOK())
+	assert.NotNil(t, m.Labels["/MBOX"])
+	assert.Nil(t, m.Labels["/DINGDING"])
+}
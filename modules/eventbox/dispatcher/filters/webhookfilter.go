@@ -21,6 +21,7 @@ import (
 	"github.com/erda-project/erda/apistructs"
 	"github.com/erda-project/erda/modules/eventbox/constant"
 	"github.com/erda-project/erda/modules/eventbox/dispatcher/errors"
+	"github.com/erda-project/erda/modules/eventbox/expr"
 	"github.com/erda-project/erda/modules/eventbox/types"
 	"github.com/erda-project/erda/modules/eventbox/webhook"
 
@@ -65,20 +66,13 @@ func (w *WebhookFilter) Filter(m *types.Message) *errors.DispatchError {
 		Application: eventLabel.ApplicationID,
 		Env:         []string{eventLabel.Env},
 	}, eventLabel.Event)
-	if len(hs)+len(internalHs) == 0 {
+	hooks := matchHooks(m, append(hs, internalHs...))
+	if len(hooks) == 0 {
 		logrus.Warnf("no webhook care event: %v", eventLabel)
 		return derr
 	}
 
-	urls := []string{}
-	for _, h := range hs {
-		urls = append(urls, h.URL)
-	}
-	for _, h := range internalHs {
-		urls = append(urls, h.URL)
-	}
-
-	if err := replaceLabel(m, urls); err != nil {
+	if err := replaceLabel(m, hooks); err != nil {
 		derr.FilterErr = err
 		return derr
 	}
@@ -89,6 +83,31 @@ func (w *WebhookFilter) Filter(m *types.Message) *errors.DispatchError {
 	return derr
 }
 
+// matchHooks returns the hooks without filters, or whose filters the message satisfies.
+func matchHooks(m *types.Message, hooks []webhook.Hook) []webhook.Hook {
+	var env map[string]interface{}
+	matched := make([]webhook.Hook, 0, len(hooks))
+	for _, h := range hooks {
+		if h.Filter == "" {
+			matched = append(matched, h)
+			continue
+		}
+		e, err := expr.CachedFilter(h.Filter)
+		if err != nil {
+			// 在 webhook 创建的时候应该检查过了 filter, 所以err!=nil一定是bug
+			logrus.Errorf("[alert][BUG] webhook filter: hook: %v, %v", h.ID, err)
+			continue
+		}
+		if env == nil {
+			env = expr.MessageEnv(m)
+		}
+		if e.Eval(env) {
+			matched = append(matched, h)
+		}
+	}
+	return matched
+}
+
 func decodeWebhookLabel(l interface{}) (*webhook.EventLabel, error) {
 	raw, err := json.Marshal(l)
 	if err != nil {
@@ -114,40 +133,34 @@ func replaceContent(m *types.Message, eventLabel webhook.EventLabel) error {
 	return nil
 }
 
-func replaceLabel(m *types.Message, urls []string) error {
-	httpLabel := m.Labels[types.LabelKey("HTTP").NormalizeLabelKey()]
-	httpraw, err := json.Marshal(httpLabel)
-	if err != nil {
-		return err
-	}
+// replaceLabel sends dingding hooks by the DINGDING label,
+// and other hooks by the WEBHOOK-DELIVERY label, which signs and retries the deliveries.
+func replaceLabel(m *types.Message, hooks []webhook.Hook) error {
 	dingdingLabel := m.Labels[types.LabelKey("DINGDING").NormalizeLabelKey()]
 	dingdingraw, err := json.Marshal(dingdingLabel)
 	if err != nil {
 		return err
 	}
 
-	httpdest := []string{}
-	if err := json.Unmarshal(httpraw, &httpdest); err != nil {
-		return err
-	}
 	dingdingdest := []string{}
 	if err := json.Unmarshal(dingdingraw, &dingdingdest); err != nil {
 		return err
 	}
-	for _, u := range urls {
-		parsed, err := url.Parse(u)
+	hookdest := []string{}
+	for _, h := range hooks {
+		parsed, err := url.Parse(h.URL)
 		if err != nil {
 			// 在 webhook 创建的时候应该检查过了url， 所以err!=nil一定是bug
-			logrus.Errorf("[alert][BUG]replace label: bad url: %v, message: %+v, urls: %v", u, m, urls)
+			logrus.Errorf("[alert][BUG]replace label: bad url: %v, message: %+v, hook: %v", h.URL, m, h.ID)
 		}
 		switch urltype(parsed) {
 		case dingdingURL:
-			dingdingdest = append(dingdingdest, u)
+			dingdingdest = append(dingdingdest, h.URL)
 		case normalURL:
-			httpdest = append(httpdest, u)
+			hookdest = append(hookdest, h.ID)
 		}
 	}
-	m.Labels[types.LabelKey("HTTP").NormalizeLabelKey()] = httpdest
+	m.Labels[types.LabelKey(constant.WebhookDeliveryLabelKey)] = hookdest
 	m.Labels[types.LabelKey("DINGDING").NormalizeLabelKey()] = dingdingdest
 	return nil
 }
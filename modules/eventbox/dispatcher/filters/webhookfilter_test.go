@@ -42,8 +42,8 @@ func testWebhookFilter(t *testing.T, f Filter) {
 	derr := f.Filter(&m)
 	assert.True(t, derr.IsOK())
 
-	http := m.Labels[types.LabelKey("/HTTP")]
-	assert.NotNil(t, http, fmt.Sprintf("%+v", m))
+	hooks := m.Labels[types.LabelKey(constant.WebhookDeliveryLabelKey)]
+	assert.NotNil(t, hooks, fmt.Sprintf("%+v", m))
 
 	raw, err := json.Marshal(m.Content)
 	assert.Nil(t, err)
@@ -71,8 +71,8 @@ func testWebhookFilterWithEnv(t *testing.T, f Filter) {
 	derr := f.Filter(&m)
 	assert.True(t, derr.IsOK())
 
-	http := m.Labels[types.LabelKey("/HTTP")]
-	assert.NotNil(t, http, fmt.Sprintf("%+v", m))
+	hooks := m.Labels[types.LabelKey(constant.WebhookDeliveryLabelKey)]
+	assert.NotNil(t, hooks, fmt.Sprintf("%+v", m))
 
 	raw, err := json.Marshal(m.Content)
 	assert.Nil(t, err)
@@ -149,3 +149,23 @@ func testWebhookFilterDINGDINGURL(t *testing.T, f Filter) {
 // 		testWebhookFilterDINGDINGURL(t, f)
 // 	})
 // }
+
+func TestMatchHooks(t *testing.T) {
+	m := &types.Message{
+		Content: map[string]interface{}{"status": "Failed"},
+		Labels: map[types.LabelKey]interface{}{
+			types.LabelKey(constant.WebhookLabelKey): webhook.EventLabel{Event: "pipeline", Env: "PROD"},
+		},
+	}
+	hooks := []webhook.Hook{
+		{ID: "all"},
+		{ID: "failed", CreateHookRequest: webhook.CreateHookRequest{Filter: `[content.status] == "Failed" && [event.env] in ("PROD", "STAGING")`}},
+		{ID: "succeeded", CreateHookRequest: webhook.CreateHookRequest{Filter: `[content.status] == "Success"`}},
+		{ID: "broken", CreateHookRequest: webhook.CreateHookRequest{Filter: `[content.status] ==`}},
+	}
+	var ids []string
+	for _, h := range matchHooks(m, hooks) {
+		ids = append(ids, h.ID)
+	}
+	assert.Equal(t, []string{"all", "failed"}, ids)
+}
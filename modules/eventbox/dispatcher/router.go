@@ -16,6 +16,8 @@ package dispatcher
 import (
 	"fmt"
 
+	"github.com/erda-project/erda/bundle"
+	"github.com/erda-project/erda/modules/eventbox/conf"
 	"github.com/erda-project/erda/modules/eventbox/dispatcher/errors"
 	"github.com/erda-project/erda/modules/eventbox/dispatcher/filters"
 	"github.com/erda-project/erda/modules/eventbox/types"
@@ -37,10 +39,10 @@ import (
 // A: []filter
 //
 // []filter:
-//     +---------------+  +---------------+  +----------------+	 +-----------------+
-//     | unifylabels   +--> registerlabel +--> webhookfilter  +-->  lastfilter     |
-//     |               |  |               |  |                |	 |                 |
-//     +---------------+  +---------------+  +----------------+	 +-----------------+
+//     +---------------+  +---------------+  +---------------+  +----------------+	 +-----------------+
+//     | unifylabels   +--> alertfilter   +--> registerlabel +--> webhookfilter  +-->  lastfilter     |
+//     |               |  |               |  |               |  |                |	 |                 |
+//     +---------------+  +---------------+  +---------------+  +----------------+	 +-----------------+
 //
 //
 type Router struct {
@@ -63,6 +65,9 @@ func NewRouter(dispatcher *DispatcherImpl) (*Router, error) {
 	lastFilter := filters.NewLastFilter(dispatcher.GetSubscribersPool(), dispatcher.GetSubscribers())
 
 	r.RegisterFilter(unifyLabelsFilter)
+	if conf.AlertDispatch() {
+		r.RegisterFilter(filters.NewAlertFilter(bundle.New(bundle.WithCollector())))
+	}
 	r.RegisterFilter(registerFilter)
 	r.RegisterFilter(webhookFilter)
 	r.RegisterFilter(lastFilter)
@@ -0,0 +1,101 @@
+// Copyright (c) 2021 Terminus, Inc.
+//
+// This program is free software: you can use, redistribute, and/or modify
+// it under the terms of the GNU Affero General Public License, version 3
+// or later ("AGPL"), as published by the Free Software Foundation.
+//
+// This program is distributed in the hope that it will be useful, but WITHOUT
+// ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+// FITNESS FOR A PARTICULAR PURPOSE.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package expr
+
+import (
+	"encoding/json"
+	"strings"
+	"sync"
+
+	"github.com/erda-project/erda/modules/eventbox/constant"
+	"github.com/erda-project/erda/modules/eventbox/types"
+)
+
+// roots of message filters
+const (
+	// Labels are the labels of the message, without the leading /
+	Labels = "labels"
+	// Content is the content of the message
+	Content = "content"
+	// Event is the WEBHOOK label of webhook events, e.g. event.name, event.env
+	Event = "event"
+)
+
+// CompileFilter compiles the filter expression of subscriptions.
+func CompileFilter(src string) (*Expr, error) {
+	return Compile(src, Labels, Content, Event)
+}
+
+const maxCached = 1024
+
+var (
+	cacheLock sync.Mutex
+	cache     = make(map[string]*Expr)
+)
+
+// CachedFilter returns the compiled filter of src, the filters are compiled once.
+func CachedFilter(src string) (*Expr, error) {
+	cacheLock.Lock()
+	defer cacheLock.Unlock()
+	if e, ok := cache[src]; ok {
+		return e, nil
+	}
+	e, err := CompileFilter(src)
+	if err != nil {
+		return nil, err
+	}
+	if len(cache) >= maxCached {
+		cache = make(map[string]*Expr)
+	}
+	cache[src] = e
+	return e, nil
+}
+
+// MessageEnv returns the env to evaluate filters over m.
+func MessageEnv(m *types.Message) map[string]interface{} {
+	labels := make(map[string]interface{}, len(m.Labels))
+	for k, v := range m.Labels {
+		labels[strings.TrimPrefix(string(k), "/")] = normalize(v)
+	}
+	env := map[string]interface{}{
+		Labels:  labels,
+		Content: normalize(m.Content),
+	}
+	if v, ok := m.Labels[types.LabelKey(constant.WebhookLabelKey)]; ok {
+		event, _ := normalize(v).(map[string]interface{})
+		if event != nil {
+			// the event name is in the "event" field of the label
+			event["name"] = event["event"]
+		}
+		env[Event] = event
+	}
+	return env
+}
+
+// normalize converts v to the json types, i.e. map[string]interface{}, []interface{}, string, float64, bool and nil.
+func normalize(v interface{}) interface{} {
+	switch v.(type) {
+	case nil, string, float64, bool:
+		return v
+	}
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return nil
+	}
+	var n interface{}
+	if err := json.Unmarshal(raw, &n); err != nil {
+		return nil
+	}
+	return n
+}
@@ -0,0 +1,310 @@
+// Copyright (c) 2021 Terminus, Inc.
+//
+// This program is free software: you can use, redistribute, and/or modify
+// it under the terms of the GNU Affero General Public License, version 3
+// or later ("AGPL"), as published by the Free Software Foundation.
+//
+// This program is distributed in the hope that it will be useful, but WITHOUT
+// ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+// FITNESS FOR A PARTICULAR PURPOSE.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+// Package expr is the filter expression of eventbox subscriptions, it is evaluated by govaluate over the labels,
+// the content and the webhook event of messages, e.g.
+//
+//	content.status == "Failed" && labels.workspace in ["PROD", "STAGING"]
+//
+// Parameters are paths, the root of a path must be one of the roots of the expression, and the segments
+// are the keys of objects or the indexes of lists, e.g. content.pipeline.tags.0, a missing path is nil.
+// A path with other characters than letters, digits and _ is in brackets, e.g. [labels.some-key].
+// Lists are in brackets, e.g. ["PROD"], the right operand of in must be a list or a path,
+// a single path in brackets is the path but not a list of it.
+// The govaluate syntax is accepted too, i.e. paths in brackets and lists of two items at least in parentheses.
+// There are no function calls, the pattern of =~ must be a literal, and the size is limited,
+// so user defined expressions are safe to evaluate.
+package expr
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"gopkg.in/Knetic/govaluate.v3"
+)
+
+const maxLength = 4096
+
+// Expr is a compiled expression.
+type Expr struct {
+	src string
+	e   *govaluate.EvaluableExpression
+}
+
+// Compile parses src, the roots of parameters must be in roots.
+func Compile(src string, roots ...string) (*Expr, error) {
+	if len(src) > maxLength {
+		return nil, fmt.Errorf("filter expression is longer than %d", maxLength)
+	}
+	if strings.TrimSpace(src) == "" {
+		return nil, fmt.Errorf("bad filter expression: empty")
+	}
+	rewritten, err := rewrite(src, roots)
+	if err != nil {
+		return nil, fmt.Errorf("bad filter expression: %v", err)
+	}
+	// no functions are given, so calls fail to parse
+	e, err := govaluate.NewEvaluableExpression(rewritten)
+	if err != nil {
+		return nil, fmt.Errorf("bad filter expression: %v", err)
+	}
+	for _, v := range e.Vars() {
+		if !hasRoot(v, roots) {
+			return nil, fmt.Errorf("bad filter expression: unknown parameter [%s], the root must be one of %v", v, roots)
+		}
+	}
+	tokens := e.Tokens()
+	for i, t := range tokens {
+		if t.Kind == govaluate.COMPARATOR && (t.Value == "=~" || t.Value == "!~") &&
+			(i+1 >= len(tokens) || tokens[i+1].Kind != govaluate.PATTERN) {
+			return nil, fmt.Errorf("bad filter expression: the pattern of %s must be a string", t.Value)
+		}
+		// a single value in parentheses is not a list, in is always false with it
+		if t.Kind == govaluate.COMPARATOR && t.Value == "in" && !isListOperand(tokens[i+1:]) {
+			return nil, fmt.Errorf("bad filter expression: the right operand of in must be a list or a path, e.g. [\"PROD\"]")
+		}
+	}
+	return &Expr{src: src, e: e}, nil
+}
+
+// isListOperand returns whether tokens start with a parameter, or a clause with items separated at its top level.
+func isListOperand(tokens []govaluate.ExpressionToken) bool {
+	if len(tokens) <= 0 {
+		return false
+	}
+	if tokens[0].Kind == govaluate.VARIABLE {
+		return true
+	}
+	if tokens[0].Kind != govaluate.CLAUSE {
+		return false
+	}
+	depth := 0
+	for _, t := range tokens {
+		switch t.Kind {
+		case govaluate.CLAUSE:
+			depth++
+		case govaluate.CLAUSE_CLOSE:
+			depth--
+			if depth == 0 {
+				return false
+			}
+		case govaluate.SEPARATOR:
+			if depth == 1 {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// rewrite converts the paths and the lists to the govaluate syntax, e.g.
+// content.status == "Failed" && labels.workspace in ["PROD"] is rewritten to
+// [content.status] == "Failed" && [labels.workspace] in ("PROD", "PROD"),
+// the single item is repeated, because a single item in parentheses is not a list in govaluate.
+func rewrite(src string, roots []string) (string, error) {
+	var sb strings.Builder
+	for i := 0; i < len(src); {
+		c := src[i]
+		switch {
+		case c == '"' || c == '\'':
+			j := skipString(src, i)
+			sb.WriteString(src[i:j])
+			i = j
+		case c == '[':
+			j := closeBracket(src, i)
+			if j < 0 {
+				// govaluate reports the unclosed bracket
+				sb.WriteString(src[i:])
+				return sb.String(), nil
+			}
+			inner := src[i+1 : j]
+			if !strings.ContainsAny(inner, "\"',") && hasRoot(strings.TrimSpace(inner), roots) {
+				sb.WriteString(src[i : j+1])
+			} else {
+				list, err := rewriteList(inner, roots)
+				if err != nil {
+					return "", err
+				}
+				sb.WriteString(list)
+			}
+			i = j + 1
+		case isIdentStart(c):
+			j := i + 1
+			for j < len(src) && (isIdentStart(src[j]) || isDigit(src[j]) || src[j] == '.') {
+				j++
+			}
+			if word := src[i:j]; hasRoot(word, roots) && isPath(word) {
+				sb.WriteString("[" + word + "]")
+			} else {
+				sb.WriteString(word)
+			}
+			i = j
+		case isDigit(c):
+			// the rest of a number is not a path, e.g. 12.5
+			j := i + 1
+			for j < len(src) && (isIdentStart(src[j]) || isDigit(src[j]) || src[j] == '.') {
+				j++
+			}
+			sb.WriteString(src[i:j])
+			i = j
+		default:
+			sb.WriteByte(c)
+			i++
+		}
+	}
+	return sb.String(), nil
+}
+
+// rewriteList converts the items of a list in brackets to a list in parentheses.
+func rewriteList(inner string, roots []string) (string, error) {
+	var items []string
+	start := 0
+	for i := 0; i <= len(inner); {
+		if i < len(inner) && (inner[i] == '"' || inner[i] == '\'') {
+			i = skipString(inner, i)
+			continue
+		}
+		if i == len(inner) || inner[i] == ',' {
+			item, err := rewrite(strings.TrimSpace(inner[start:i]), roots)
+			if err != nil {
+				return "", err
+			}
+			if item == "" {
+				return "", fmt.Errorf("empty item in list [%s]", inner)
+			}
+			items = append(items, item)
+			start = i + 1
+		}
+		i++
+	}
+	if len(items) == 1 {
+		items = append(items, items[0])
+	}
+	return "(" + strings.Join(items, ", ") + ")", nil
+}
+
+// skipString returns the index after the string literal starting at i, or the end of s if it is unterminated.
+func skipString(s string, i int) int {
+	quote := s[i]
+	for j := i + 1; j < len(s); j++ {
+		if s[j] == '\\' {
+			j++
+		} else if s[j] == quote {
+			return j + 1
+		}
+	}
+	return len(s)
+}
+
+// closeBracket returns the index of the bracket closing the one at i, -1 if it is not closed.
+func closeBracket(s string, i int) int {
+	for j := i + 1; j < len(s); {
+		switch s[j] {
+		case '"', '\'':
+			j = skipString(s, j)
+			continue
+		case ']':
+			return j
+		}
+		j++
+	}
+	return -1
+}
+
+// isPath returns whether s is segments of letters, digits and _ separated by dots.
+func isPath(s string) bool {
+	for _, seg := range strings.Split(s, ".") {
+		if seg == "" {
+			return false
+		}
+		for i := 0; i < len(seg); i++ {
+			if !isIdentStart(seg[i]) && !isDigit(seg[i]) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func isIdentStart(c byte) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func hasRoot(path string, roots []string) bool {
+	root := strings.SplitN(path, ".", 2)[0]
+	for _, r := range roots {
+		if root == r {
+			return true
+		}
+	}
+	return false
+}
+
+// Eval evaluates the expression over env, mismatched types are false.
+func (e *Expr) Eval(env map[string]interface{}) bool {
+	v, err := e.e.Eval(params(env))
+	if err != nil {
+		return false
+	}
+	return truthy(v)
+}
+
+func (e *Expr) String() string {
+	return e.src
+}
+
+// params is the restricted parameter accessor, it only walks the json values of env by paths.
+type params map[string]interface{}
+
+func (p params) Get(name string) (interface{}, error) {
+	keys := strings.Split(name, ".")
+	v := p[keys[0]]
+	for _, k := range keys[1:] {
+		switch c := v.(type) {
+		case map[string]interface{}:
+			v = c[k]
+		case []interface{}:
+			i, err := strconv.Atoi(k)
+			if err != nil || i < 0 || i >= len(c) {
+				return nil, nil
+			}
+			v = c[i]
+		default:
+			return nil, nil
+		}
+	}
+	return v, nil
+}
+
+func truthy(v interface{}) bool {
+	switch v := v.(type) {
+	case nil:
+		return false
+	case bool:
+		return v
+	case string:
+		return v != ""
+	case float64:
+		return v != 0
+	case []interface{}:
+		return len(v) > 0
+	case map[string]interface{}:
+		return len(v) > 0
+	}
+	return true
+}
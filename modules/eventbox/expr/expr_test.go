@@ -0,0 +1,109 @@
+// Copyright (c) 2021 Terminus, Inc.
+//
+// This program is free software: you can use, redistribute, and/or modify
+// it under the terms of the GNU Affero General Public License, version 3
+// or later ("AGPL"), as published by the Free Software Foundation.
+//
+// This program is distributed in the hope that it will be useful, but WITHOUT
+// ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+// FITNESS FOR A PARTICULAR PURPOSE.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package expr
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/erda-project/erda/modules/eventbox/types"
+)
+
+func TestEval(t *testing.T) {
+	env := map[string]interface{}{
+		"labels": map[string]interface{}{"workspace": "PROD", "DINGDING": []interface{}{"https://x"}},
+		"content": map[string]interface{}{
+			"status":   "Failed",
+			"cost":     float64(12),
+			"pipeline": map[string]interface{}{"branch": "release/1.0", "tags": []interface{}{"a", "b"}},
+		},
+	}
+	for _, c := range []struct {
+		src  string
+		want bool
+	}{
+		{`[content.status] == "Failed" && [labels.workspace] in ("PROD", "STAGING")`, true},
+		{`content.status == "Failed" && labels.workspace in ["PROD"]`, true},
+		{`content.status == "Failed" && labels.workspace in ['DEV', 'TEST']`, false},
+		{`"a" in content.pipeline.tags && content.pipeline.tags.1 == "b" && [labels.DINGDING.0] == "https://x"`, true},
+		{`content.cost in [12, 13.5] && content.status in [content.status, "x"]`, true},
+		{`content.status == "labels.workspace in [x]"`, false},
+		{`[content.status] == "Failed" && [labels.workspace] in ("DEV", "TEST")`, false},
+		{`!([labels.workspace] in ("DEV", "TEST"))`, true},
+		{`[content.status] != 'Failed' || [content.cost] > 10`, true},
+		{`[content.cost] >= 12 && [content.cost] < 12.5 && [content.cost] <= 12`, true},
+		{`[content.cost] > "10"`, false},
+		{`[content.pipeline.branch] =~ "^release/"`, true},
+		{`[content.pipeline.branch] !~ "^feature/"`, true},
+		{`"b" in [content.pipeline.tags] && [content.pipeline.tags.0] == "a"`, true},
+		{`[content.pipeline.tags.5] == "a"`, false},
+		{`[content.missing.field] == "x"`, false},
+		{`!([content.status] == "Failed")`, false},
+		{`[labels.DINGDING.0] == "https://x"`, true},
+		{`[content.cost] == 12 && true && !false`, true},
+		{`[content.status]`, true},
+		{`[content.nothing]`, false},
+	} {
+		e, err := CompileFilter(c.src)
+		if !assert.Nil(t, err, c.src) {
+			continue
+		}
+		assert.Equal(t, c.want, e.Eval(env), c.src)
+	}
+}
+
+func TestCompileErrors(t *testing.T) {
+	for _, src := range []string{
+		``,
+		`[content.status] ==`,
+		`[content.status] == "Failed" &&`,
+		`[secrets.token] == "x"`,
+		`token == "x"`,
+		`[content.status] =~ [content.pattern]`,
+		`[content.status] =~ "("`,
+		`[content.status] == "unterminated`,
+		`([content.status] == "x"`,
+		`[content.status] == "x")`,
+		`len([content.tags]) > 1`,
+		`[labels.workspace] in ("PROD")`,
+		`labels.workspace in "PROD"`,
+		`labels.workspace in []`,
+		`labels.workspace in ["PROD",]`,
+		`labels.workspace in [PROD]`,
+		`secrets.token == "x"`,
+		`[content.x] == "` + strings.Repeat("a", maxLength) + `"`,
+	} {
+		_, err := CompileFilter(src)
+		assert.NotNil(t, err, src)
+	}
+}
+
+func TestMessageEnv(t *testing.T) {
+	m := &types.Message{
+		Labels: map[types.LabelKey]interface{}{
+			"/WEBHOOK":  map[string]interface{}{"event": "pipeline", "env": "PROD", "orgID": "1"},
+			"/DINGDING": []string{"https://x"},
+		},
+		Content: struct {
+			Status string `json:"status"`
+		}{"Failed"},
+	}
+	e, err := CachedFilter(`[event.name] == "pipeline" && [event.env] == "PROD" && [content.status] == "Failed" && [labels.DINGDING.0] == "https://x"`)
+	assert.Nil(t, err)
+	assert.True(t, e.Eval(MessageEnv(m)))
+	cached, _ := CachedFilter(e.String())
+	assert.True(t, e == cached)
+}
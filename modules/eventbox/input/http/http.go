@@ -18,6 +18,7 @@ import (
 	"encoding/json"
 	"net/http"
 
+	"github.com/erda-project/erda/modules/eventbox/constant"
 	"github.com/erda-project/erda/modules/eventbox/input"
 	"github.com/erda-project/erda/modules/eventbox/monitor"
 	stypes "github.com/erda-project/erda/modules/eventbox/server/types"
@@ -65,6 +66,13 @@ func (h *HttpInput) createMessage(ctx context.Context, req *http.Request, vars m
 		return stypes.HTTPResponse{Status: http.StatusBadRequest, Content: "unmarshal message failed!"}, err
 	}
 	logrus.Debugf("%s input message timestamp:%d", h.Name(), m.Time)
+	// dedupe the retries of clients when the durable queue is enabled
+	if key := req.Header.Get("Idempotency-Key"); key != "" {
+		if m.Labels == nil {
+			m.Labels = make(map[types.LabelKey]interface{})
+		}
+		m.Labels[types.LabelKey(constant.IdempotencyLabelKey)] = key
+	}
 
 	monitor.Notify(monitor.MonitorInfo{Tp: monitor.HTTPInput})
 	e := h.handler(&m)
@@ -0,0 +1,295 @@
+// Copyright (c) 2021 Terminus, Inc.
+//
+// This program is free software: you can use, redistribute, and/or modify
+// it under the terms of the GNU Affero General Public License, version 3
+// or later ("AGPL"), as published by the Free Software Foundation.
+//
+// This program is distributed in the hope that it will be useful, but WITHOUT
+// ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+// FITNESS FOR A PARTICULAR PURPOSE.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package queue
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/erda-project/erda/modules/eventbox/types"
+)
+
+// FileQueue keeps the queue in local files, it is the stand-in of the mysql queue in tests and single instance deployments.
+// Leases are kept in memory, so all the records not acked are redelivered after a restart.
+//
+// layout:
+//
+//	<dir>/records/<id>.json	pending records
+//	<dir>/acked/<id>.json	keys of the acked records, kept until purged
+type FileQueue struct {
+	dir string
+	now func() time.Time
+
+	lock    sync.Mutex
+	lastID  int64
+	pending map[string]*fileRecord
+	// key -> id, of both pending and acked records
+	keys  map[string]string
+	acked map[string]ackedRecord
+}
+
+// fileRecord keeps the message encoded, so that every lease gets a copy of the message as enqueued.
+type fileRecord struct {
+	Record
+	message   []byte
+	visibleAt time.Time
+}
+
+func newFileRecord(r Record) (*fileRecord, error) {
+	message, err := json.Marshal(r.Message)
+	if err != nil {
+		return nil, err
+	}
+	r.Message = nil
+	return &fileRecord{Record: r, message: message}, nil
+}
+
+type ackedRecord struct {
+	Key     string    `json:"key"`
+	AckedAt time.Time `json:"ackedAt"`
+}
+
+// NewFileQueue opens the queue in dir, or creates it.
+func NewFileQueue(dir string) (*FileQueue, error) {
+	for _, sub := range []string{"records", "acked"} {
+		if err := os.MkdirAll(filepath.Join(dir, sub), 0755); err != nil {
+			return nil, errors.Wrap(err, "file queue")
+		}
+	}
+	q := &FileQueue{
+		dir:     dir,
+		now:     time.Now,
+		pending: make(map[string]*fileRecord),
+		keys:    make(map[string]string),
+		acked:   make(map[string]ackedRecord),
+	}
+	if err := q.load(); err != nil {
+		return nil, err
+	}
+	return q, nil
+}
+
+func (q *FileQueue) load() error {
+	if err := readDir(filepath.Join(q.dir, "records"), func(id string, data []byte) error {
+		var r Record
+		if err := json.Unmarshal(data, &r); err != nil {
+			return err
+		}
+		fr, err := newFileRecord(r)
+		if err != nil {
+			return err
+		}
+		q.pending[id] = fr
+		if r.Key != "" {
+			q.keys[r.Key] = id
+		}
+		return nil
+	}); err != nil {
+		return err
+	}
+	return readDir(filepath.Join(q.dir, "acked"), func(id string, data []byte) error {
+		var a ackedRecord
+		if err := json.Unmarshal(data, &a); err != nil {
+			return err
+		}
+		q.acked[id] = a
+		if a.Key != "" {
+			q.keys[a.Key] = id
+		}
+		return nil
+	})
+}
+
+func readDir(dir string, f func(id string, data []byte) error) error {
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return errors.Wrap(err, "file queue")
+	}
+	for _, file := range files {
+		if !strings.HasSuffix(file.Name(), ".json") {
+			continue
+		}
+		data, err := ioutil.ReadFile(filepath.Join(dir, file.Name()))
+		if err != nil {
+			return errors.Wrap(err, "file queue")
+		}
+		if err := f(strings.TrimSuffix(file.Name(), ".json"), data); err != nil {
+			return errors.Wrapf(err, "file queue: illegal file: %s", file.Name())
+		}
+	}
+	return nil
+}
+
+// writeFile replaces the file atomically.
+func writeFile(path string, v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	f, err := os.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// genID returns increasing ids, which sort records in the enqueued order.
+func (q *FileQueue) genID(now time.Time) string {
+	id := now.UnixNano()
+	if id <= q.lastID {
+		id = q.lastID + 1
+	}
+	q.lastID = id
+	return fmt.Sprintf("%020d", id)
+}
+
+func (q *FileQueue) Enqueue(key string, m *types.Message) error {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	if _, ok := q.keys[key]; ok && key != "" {
+		return ErrDuplicated
+	}
+	now := q.now()
+	r := Record{ID: q.genID(now), Key: key, Message: m, EnqueuedAt: now}
+	fr, err := newFileRecord(r)
+	if err != nil {
+		return err
+	}
+	if err := writeFile(filepath.Join(q.dir, "records", r.ID+".json"), r); err != nil {
+		return errors.Wrap(err, "file queue: enqueue")
+	}
+	q.pending[r.ID] = fr
+	if key != "" {
+		q.keys[key] = r.ID
+	}
+	return nil
+}
+
+func (q *FileQueue) Lease(n int, ttl time.Duration) ([]*Record, error) {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	now := q.now()
+	var ids []string
+	for id, r := range q.pending {
+		if !r.visibleAt.After(now) {
+			ids = append(ids, id)
+		}
+	}
+	sort.Strings(ids)
+	if len(ids) > n {
+		ids = ids[:n]
+	}
+	records := make([]*Record, 0, len(ids))
+	for _, id := range ids {
+		r := q.pending[id]
+		var m types.Message
+		if err := json.Unmarshal(r.message, &m); err != nil {
+			return nil, errors.Wrapf(err, "file queue: illegal record: %s", id)
+		}
+		r.visibleAt = now.Add(ttl)
+		r.Attempts++
+		rec := r.Record
+		rec.Message = &m
+		records = append(records, &rec)
+	}
+	return records, nil
+}
+
+func (q *FileQueue) Ack(r *Record) error {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	if _, ok := q.pending[r.ID]; !ok {
+		return nil
+	}
+	if r.Key != "" {
+		a := ackedRecord{Key: r.Key, AckedAt: q.now()}
+		if err := writeFile(filepath.Join(q.dir, "acked", r.ID+".json"), a); err != nil {
+			return errors.Wrap(err, "file queue: ack")
+		}
+		q.acked[r.ID] = a
+	}
+	if err := os.Remove(filepath.Join(q.dir, "records", r.ID+".json")); err != nil && !os.IsNotExist(err) {
+		return errors.Wrap(err, "file queue: ack")
+	}
+	delete(q.pending, r.ID)
+	return nil
+}
+
+func (q *FileQueue) Nack(r *Record, delay time.Duration) error {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	if p, ok := q.pending[r.ID]; ok {
+		p.visibleAt = q.now().Add(delay)
+	}
+	return nil
+}
+
+func (q *FileQueue) Purge(t time.Time) error {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	for id, a := range q.acked {
+		if !a.AckedAt.Before(t) {
+			continue
+		}
+		if err := os.Remove(filepath.Join(q.dir, "acked", id+".json")); err != nil && !os.IsNotExist(err) {
+			return errors.Wrap(err, "file queue: purge")
+		}
+		delete(q.acked, id)
+		if q.keys[a.Key] == id {
+			delete(q.keys, a.Key)
+		}
+	}
+	return nil
+}
+
+func (q *FileQueue) Stats() (Stats, error) {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	now := q.now()
+	s := Stats{Pending: len(q.pending)}
+	for _, r := range q.pending {
+		if r.visibleAt.After(now) {
+			s.Inflight++
+		}
+		if lag := now.Sub(r.EnqueuedAt); lag > s.Lag {
+			s.Lag = lag
+		}
+	}
+	return s, nil
+}
+
+func (q *FileQueue) Close() error {
+	return nil
+}
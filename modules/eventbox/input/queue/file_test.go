@@ -0,0 +1,91 @@
+// Copyright (c) 2021 Terminus, Inc.
+//
+// This program is free software: you can use, redistribute, and/or modify
+// it under the terms of the GNU Affero General Public License, version 3
+// or later ("AGPL"), as published by the Free Software Foundation.
+//
+// This program is distributed in the hope that it will be useful, but WITHOUT
+// ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+// FITNESS FOR A PARTICULAR PURPOSE.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package queue
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/erda-project/erda/modules/eventbox/types"
+)
+
+func newTestFileQueue(t *testing.T) (*FileQueue, string) {
+	dir, err := ioutil.TempDir("", "eventbox-queue")
+	assert.Nil(t, err)
+	q, err := NewFileQueue(dir)
+	assert.Nil(t, err)
+	return q, dir
+}
+
+func TestFileQueue(t *testing.T) {
+	q, dir := newTestFileQueue(t)
+	defer os.RemoveAll(dir)
+	now := time.Unix(1000, 0)
+	q.now = func() time.Time { return now }
+
+	assert.Nil(t, q.Enqueue("a", &types.Message{Content: "1"}))
+	assert.Nil(t, q.Enqueue("", &types.Message{Content: "2"}))
+	assert.Nil(t, q.Enqueue("", &types.Message{Content: "3"}))
+	assert.Equal(t, ErrDuplicated, q.Enqueue("a", &types.Message{Content: "1"}))
+
+	records, err := q.Lease(2, time.Minute)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(records))
+	assert.Equal(t, "1", records[0].Message.Content)
+	assert.Equal(t, "2", records[1].Message.Content)
+	assert.Equal(t, 1, records[0].Attempts)
+
+	// leased records are invisible
+	rest, err := q.Lease(10, time.Minute)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(rest))
+	assert.Equal(t, "3", rest[0].Message.Content)
+	s, _ := q.Stats()
+	assert.Equal(t, Stats{Pending: 3, Inflight: 3}, s)
+
+	assert.Nil(t, q.Ack(records[0]))
+	assert.Nil(t, q.Nack(records[1], 0))
+	again, err := q.Lease(10, time.Minute)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(again))
+	assert.Equal(t, records[1].ID, again[0].ID)
+	assert.Equal(t, 2, again[0].Attempts)
+
+	// the key is kept after acked
+	assert.Equal(t, ErrDuplicated, q.Enqueue("a", &types.Message{Content: "1"}))
+
+	// leases expire
+	now = now.Add(2 * time.Minute)
+	s, _ = q.Stats()
+	assert.Equal(t, Stats{Pending: 2, Inflight: 0, Lag: 2 * time.Minute}, s)
+	expired, err := q.Lease(10, time.Minute)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(expired))
+
+	// the records not acked are redelivered after reopened
+	q2, err := NewFileQueue(dir)
+	assert.Nil(t, err)
+	reopened, err := q2.Lease(10, time.Minute)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(reopened))
+	assert.Equal(t, ErrDuplicated, q2.Enqueue("a", &types.Message{Content: "1"}))
+
+	// the keys are forgot after purged
+	assert.Nil(t, q2.Purge(now.Add(time.Second)))
+	assert.Nil(t, q2.Enqueue("a", &types.Message{Content: "1"}))
+}
@@ -0,0 +1,222 @@
+// Copyright (c) 2021 Terminus, Inc.
+//
+// This program is free software: you can use, redistribute, and/or modify
+// it under the terms of the GNU Affero General Public License, version 3
+// or later ("AGPL"), as published by the Free Software Foundation.
+//
+// This program is distributed in the hope that it will be useful, but WITHOUT
+// ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+// FITNESS FOR A PARTICULAR PURPOSE.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package queue
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/erda-project/erda/modules/eventbox/constant"
+	"github.com/erda-project/erda/modules/eventbox/dispatcher/errors"
+	"github.com/erda-project/erda/modules/eventbox/input"
+	"github.com/erda-project/erda/modules/eventbox/monitor"
+	stypes "github.com/erda-project/erda/modules/eventbox/server/types"
+	"github.com/erda-project/erda/modules/eventbox/types"
+	"github.com/erda-project/erda/pkg/jsonstore"
+)
+
+// Config of QueueInput.
+type Config struct {
+	// BatchSize is the max number of messages dispatched at the same time
+	BatchSize int
+	// LeaseTTL is how long a message is invisible to other consumers after leased,
+	// it should be longer than the slowest dispatch
+	LeaseTTL time.Duration
+	// PollInterval is the interval of polling when the queue is empty
+	PollInterval time.Duration
+	// Retention is how long the idempotency keys of acked messages are kept
+	Retention time.Duration
+	// LagAlert alerts when the oldest pending message is older than it
+	LagAlert time.Duration
+}
+
+// QueueInput dispatches the messages in the queue, its Enqueue is the handler of the other inputs.
+type QueueInput struct {
+	q       Queue
+	cfg     Config
+	handler input.Handler
+	// lru of the dispatched record ids, acks them without dispatching again if the last ack failed
+	dispatched jsonstore.JsonStore
+	// wakes up the poll loop when a message is enqueued
+	notify chan struct{}
+
+	stopCh    chan struct{}
+	runningWg sync.WaitGroup
+}
+
+func New(q Queue, cfg Config) (*QueueInput, error) {
+	dispatched, err := jsonstore.New(jsonstore.UseLruStore(1000), jsonstore.UseMemStore())
+	if err != nil {
+		return nil, err
+	}
+	return &QueueInput{
+		q:          q,
+		cfg:        cfg,
+		dispatched: dispatched,
+		notify:     make(chan struct{}, 1),
+		stopCh:     make(chan struct{}),
+	}, nil
+}
+
+func (i *QueueInput) Name() string {
+	return "QUEUE"
+}
+
+// Enqueue persists m to the queue, the message is accepted once it returns ok.
+// The idempotency key is taken from the IDEMPOTENCY-KEY label of m.
+func (i *QueueInput) Enqueue(m *types.Message) *errors.DispatchError {
+	derr := errors.New()
+	var key string
+	for k, v := range m.Labels {
+		if k.Equal(constant.IdempotencyLabelKey) {
+			key = fmt.Sprint(v)
+			delete(m.Labels, k)
+		}
+	}
+	if err := i.q.Enqueue(key, m); err != nil {
+		if err == ErrDuplicated {
+			monitor.Notify(monitor.MonitorInfo{Tp: monitor.QueueInputDup})
+			derr.FilterInfo = fmt.Sprintf("duplicated message, key: %s", key)
+			return derr
+		}
+		derr.BackendErrs[i.Name()] = []error{err}
+		return derr
+	}
+	monitor.Notify(monitor.MonitorInfo{Tp: monitor.QueueInput})
+	select {
+	case i.notify <- struct{}{}:
+	default:
+	}
+	return derr
+}
+
+func (i *QueueInput) Start(handler input.Handler) error {
+	i.handler = handler
+	i.runningWg.Add(2)
+	go i.housekeep()
+	defer i.runningWg.Done()
+	for {
+		select {
+		case <-i.stopCh:
+			return nil
+		default:
+		}
+		records, err := i.q.Lease(i.cfg.BatchSize, i.cfg.LeaseTTL)
+		if err != nil {
+			logrus.Errorf("Queueinput: %v", err)
+		}
+		if len(records) == 0 {
+			select {
+			case <-i.stopCh:
+				return nil
+			case <-i.notify:
+			case <-time.After(i.cfg.PollInterval):
+			}
+			continue
+		}
+		var wg sync.WaitGroup
+		wg.Add(len(records))
+		for _, r := range records {
+			go func(r *Record) {
+				defer wg.Done()
+				i.dispatch(r)
+			}(r)
+		}
+		wg.Wait()
+	}
+}
+
+// dispatch acks r after the handler returned, the handler returns after all the subscribers of the message returned.
+// The errors of subscribers are not retried here, the subscribers retry by themselves, such as webhooks.
+func (i *QueueInput) dispatch(r *Record) {
+	ctx := context.Background()
+	var t int64
+	if err := i.dispatched.Get(ctx, r.ID, &t); err == nil {
+		monitor.Notify(monitor.MonitorInfo{Tp: monitor.QueueInputDup})
+	} else {
+		if r.Attempts > 1 {
+			monitor.Notify(monitor.MonitorInfo{Tp: monitor.QueueRedeliver})
+			logrus.Warnf("Queueinput: redeliver message: %s, attempts: %d", r.ID, r.Attempts)
+		}
+		derr := i.handler(r.Message)
+		if derr != nil && !derr.IsOK() {
+			logrus.Errorf("Queueinput: message: %s, %v", r.ID, derr)
+		}
+		if err := i.dispatched.Put(ctx, r.ID, time.Now().UnixNano()); err != nil {
+			logrus.Errorf("Queueinput: %v", err)
+		}
+	}
+	if err := i.q.Ack(r); err != nil {
+		logrus.Errorf("Queueinput: message: %s, %v", r.ID, err)
+	}
+}
+
+// housekeep purges the expired idempotency keys, and alerts on the lag.
+func (i *QueueInput) housekeep() {
+	defer i.runningWg.Done()
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-i.stopCh:
+			return
+		case <-ticker.C:
+		}
+		if err := i.q.Purge(time.Now().Add(-i.cfg.Retention)); err != nil {
+			logrus.Errorf("Queueinput: %v", err)
+		}
+		s, err := i.q.Stats()
+		if err != nil {
+			logrus.Errorf("Queueinput: %v", err)
+			continue
+		}
+		if s.Lag > i.cfg.LagAlert {
+			logrus.Errorf("[alert] eventbox queue lags %v, pending: %d, inflight: %d", s.Lag, s.Pending, s.Inflight)
+		}
+	}
+}
+
+func (i *QueueInput) Stop() error {
+	close(i.stopCh)
+	logrus.Info("Queueinput: stopping")
+	i.runningWg.Wait()
+	logrus.Info("Queueinput: stopped")
+	return i.q.Close()
+}
+
+func (i *QueueInput) GetHTTPEndPoints() []stypes.Endpoint {
+	return []stypes.Endpoint{
+		{Path: "/queue/stat", Method: http.MethodGet, Handler: i.stat},
+	}
+}
+
+func (i *QueueInput) stat(ctx context.Context, req *http.Request, vars map[string]string) (stypes.Responser, error) {
+	s, err := i.q.Stats()
+	if err != nil {
+		return stypes.ErrorResp("QUEUE500", err.Error()), nil
+	}
+	return stypes.HTTPResponse{
+		Compose: true,
+		Content: map[string]interface{}{
+			"pending":    s.Pending,
+			"inflight":   s.Inflight,
+			"lagSeconds": int64(s.Lag / time.Second),
+		},
+	}, nil
+}
@@ -0,0 +1,117 @@
+// Copyright (c) 2021 Terminus, Inc.
+//
+// This program is free software: you can use, redistribute, and/or modify
+// it under the terms of the GNU Affero General Public License, version 3
+// or later ("AGPL"), as published by the Free Software Foundation.
+//
+// This program is distributed in the hope that it will be useful, but WITHOUT
+// ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+// FITNESS FOR A PARTICULAR PURPOSE.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package queue
+
+import (
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/erda-project/erda/modules/eventbox/dispatcher/errors"
+	"github.com/erda-project/erda/modules/eventbox/types"
+)
+
+func newTestInput(t *testing.T) (*QueueInput, *FileQueue, string) {
+	q, dir := newTestFileQueue(t)
+	i, err := New(q, Config{
+		BatchSize:    4,
+		LeaseTTL:     time.Minute,
+		PollInterval: 10 * time.Millisecond,
+		Retention:    time.Hour,
+		LagAlert:     time.Minute,
+	})
+	assert.Nil(t, err)
+	return i, q, dir
+}
+
+func TestQueueInputEnqueue(t *testing.T) {
+	i, q, dir := newTestInput(t)
+	defer os.RemoveAll(dir)
+
+	m := &types.Message{Labels: map[types.LabelKey]interface{}{"IDEMPOTENCY-KEY": "k1", "/DINGDING": []string{"x"}}}
+	assert.True(t, i.Enqueue(m).IsOK())
+	derr := i.Enqueue(&types.Message{Labels: map[types.LabelKey]interface{}{"/IDEMPOTENCY-KEY": "k1"}})
+	assert.True(t, derr.IsOK())
+	assert.Contains(t, derr.FilterInfo, "duplicated")
+
+	records, err := q.Lease(10, time.Minute)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(records))
+	assert.Equal(t, "k1", records[0].Key)
+	// the idempotency key is not routed
+	assert.Equal(t, map[types.LabelKey]interface{}{"/DINGDING": []interface{}{"x"}}, records[0].Message.Labels)
+}
+
+func TestQueueInputDispatch(t *testing.T) {
+	i, q, dir := newTestInput(t)
+	defer os.RemoveAll(dir)
+
+	release := make(chan struct{})
+	var lock sync.Mutex
+	var handled []interface{}
+	handler := func(m *types.Message) *errors.DispatchError {
+		<-release
+		lock.Lock()
+		handled = append(handled, m.Content)
+		lock.Unlock()
+		return errors.New()
+	}
+	go i.Start(handler)
+	defer i.Stop()
+
+	assert.True(t, i.Enqueue(&types.Message{Content: "1"}).IsOK())
+	// not acked until the handler returns
+	time.Sleep(50 * time.Millisecond)
+	s, _ := q.Stats()
+	assert.Equal(t, 1, s.Pending)
+	assert.Equal(t, 1, s.Inflight)
+
+	close(release)
+	waitFor(t, func() bool {
+		s, _ := q.Stats()
+		return s.Pending == 0
+	})
+	assert.Equal(t, []interface{}{"1"}, handled)
+}
+
+func TestQueueInputDedupeRedelivery(t *testing.T) {
+	i, q, dir := newTestInput(t)
+	defer os.RemoveAll(dir)
+
+	var count int
+	i.handler = func(m *types.Message) *errors.DispatchError {
+		count++
+		return errors.New()
+	}
+	assert.True(t, i.Enqueue(&types.Message{Content: "1"}).IsOK())
+	records, _ := q.Lease(1, time.Minute)
+	i.dispatch(records[0])
+	// redelivered, as if the last ack was lost
+	i.dispatch(records[0])
+	assert.Equal(t, 1, count)
+	s, _ := q.Stats()
+	assert.Equal(t, 0, s.Pending)
+}
+
+func waitFor(t *testing.T, f func() bool) {
+	for start := time.Now(); time.Since(start) < 2*time.Second; time.Sleep(10 * time.Millisecond) {
+		if f() {
+			return
+		}
+	}
+	t.Fatal("timeout")
+}
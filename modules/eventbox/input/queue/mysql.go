@@ -0,0 +1,183 @@
+// Copyright (c) 2021 Terminus, Inc.
+//
+// This program is free software: you can use, redistribute, and/or modify
+// it under the terms of the GNU Affero General Public License, version 3
+// or later ("AGPL"), as published by the Free Software Foundation.
+//
+// This program is distributed in the hope that it will be useful, but WITHOUT
+// ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+// FITNESS FOR A PARTICULAR PURPOSE.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package queue
+
+import (
+	"encoding/json"
+	"strconv"
+	"time"
+
+	"github.com/jinzhu/gorm"
+	"github.com/pkg/errors"
+
+	"github.com/erda-project/erda/modules/eventbox/types"
+	"github.com/erda-project/erda/modules/pkg/mysql"
+	"github.com/erda-project/erda/pkg/crypto/uuid"
+	"github.com/erda-project/erda/pkg/database/dbengine"
+)
+
+// record status
+const (
+	statusPending = "pending"
+	statusAcked   = "acked"
+)
+
+// OutboxRecord is a row of the outbox table. Services sharing the database may insert into it
+// in the transaction of their own changes, so that the events are published if and only if the changes are committed.
+type OutboxRecord struct {
+	ID             uint64    `gorm:"primary_key"`
+	IdempotencyKey string    `gorm:"column:idempotency_key"`
+	Message        string    `gorm:"column:message"`
+	Status         string    `gorm:"column:status"`
+	Attempts       int       `gorm:"column:attempts"`
+	VisibleAt      time.Time `gorm:"column:visible_at"`
+	CreatedAt      time.Time `gorm:"column:created_at"`
+	UpdatedAt      time.Time `gorm:"column:updated_at"`
+}
+
+func (OutboxRecord) TableName() string {
+	return "eventbox_outbox"
+}
+
+// MySQLQueue keeps the queue in the outbox table, it can be shared by eventbox instances.
+type MySQLQueue struct {
+	db  *dbengine.DBEngine
+	now func() time.Time
+}
+
+// NewMySQLQueue opens the queue with the mysql config from env.
+func NewMySQLQueue() (*MySQLQueue, error) {
+	db, err := dbengine.Open()
+	if err != nil {
+		return nil, errors.Wrap(err, "mysql queue")
+	}
+	return &MySQLQueue{db: db, now: time.Now}, nil
+}
+
+func (q *MySQLQueue) Enqueue(key string, m *types.Message) error {
+	content, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+	if key == "" {
+		key = uuid.UUID()
+	}
+	now := q.now()
+	r := OutboxRecord{
+		IdempotencyKey: key,
+		Message:        string(content),
+		Status:         statusPending,
+		VisibleAt:      now,
+		CreatedAt:      now,
+		UpdatedAt:      now,
+	}
+	if err := q.db.Create(&r).Error; err != nil {
+		if mysql.IsUniqueConstraintError(err) {
+			return ErrDuplicated
+		}
+		return errors.Wrap(err, "mysql queue: enqueue")
+	}
+	return nil
+}
+
+// Lease locks the visible rows in a transaction, so that the eventbox instances lease different records.
+func (q *MySQLQueue) Lease(n int, ttl time.Duration) ([]*Record, error) {
+	var rows []OutboxRecord
+	now := q.now()
+	err := q.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Set("gorm:query_option", "FOR UPDATE").
+			Where("status = ? AND visible_at <= ?", statusPending, now).
+			Order("id").Limit(n).Find(&rows).Error; err != nil {
+			return err
+		}
+		if len(rows) == 0 {
+			return nil
+		}
+		ids := make([]uint64, 0, len(rows))
+		for _, r := range rows {
+			ids = append(ids, r.ID)
+		}
+		return tx.Model(&OutboxRecord{}).Where("id IN (?)", ids).Updates(map[string]interface{}{
+			"visible_at": now.Add(ttl),
+			"attempts":   gorm.Expr("attempts + 1"),
+			"updated_at": now,
+		}).Error
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "mysql queue: lease")
+	}
+	records := make([]*Record, 0, len(rows))
+	for _, row := range rows {
+		var m types.Message
+		if err := json.Unmarshal([]byte(row.Message), &m); err != nil {
+			// never redeliver a broken record
+			q.db.Model(&OutboxRecord{}).Where("id = ?", row.ID).Updates(map[string]interface{}{"status": statusAcked, "updated_at": now})
+			continue
+		}
+		records = append(records, &Record{
+			ID:         idString(row.ID),
+			Key:        row.IdempotencyKey,
+			Message:    &m,
+			Attempts:   row.Attempts + 1,
+			EnqueuedAt: row.CreatedAt,
+		})
+	}
+	return records, nil
+}
+
+func (q *MySQLQueue) Ack(r *Record) error {
+	err := q.db.Model(&OutboxRecord{}).Where("id = ?", r.ID).
+		Updates(map[string]interface{}{"status": statusAcked, "updated_at": q.now()}).Error
+	return errors.Wrap(err, "mysql queue: ack")
+}
+
+func (q *MySQLQueue) Nack(r *Record, delay time.Duration) error {
+	now := q.now()
+	err := q.db.Model(&OutboxRecord{}).Where("id = ? AND status = ?", r.ID, statusPending).
+		Updates(map[string]interface{}{"visible_at": now.Add(delay), "updated_at": now}).Error
+	return errors.Wrap(err, "mysql queue: nack")
+}
+
+func (q *MySQLQueue) Purge(t time.Time) error {
+	err := q.db.Where("status = ? AND updated_at < ?", statusAcked, t).Delete(&OutboxRecord{}).Error
+	return errors.Wrap(err, "mysql queue: purge")
+}
+
+func (q *MySQLQueue) Stats() (Stats, error) {
+	now := q.now()
+	var s Stats
+	var result struct {
+		Pending  int
+		Inflight int
+		Oldest   *time.Time
+	}
+	if err := q.db.Model(&OutboxRecord{}).
+		Select("COUNT(*) AS pending, COALESCE(SUM(visible_at > ?), 0) AS inflight, MIN(created_at) AS oldest", now).
+		Where("status = ?", statusPending).Scan(&result).Error; err != nil {
+		return s, errors.Wrap(err, "mysql queue: stats")
+	}
+	s.Pending, s.Inflight = result.Pending, result.Inflight
+	if result.Oldest != nil {
+		s.Lag = now.Sub(*result.Oldest)
+	}
+	return s, nil
+}
+
+func (q *MySQLQueue) Close() error {
+	return q.db.Close()
+}
+
+func idString(id uint64) string {
+	return strconv.FormatUint(id, 10)
+}
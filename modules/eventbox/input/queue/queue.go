@@ -0,0 +1,66 @@
+// Copyright (c) 2021 Terminus, Inc.
+//
+// This program is free software: you can use, redistribute, and/or modify
+// it under the terms of the GNU Affero General Public License, version 3
+// or later ("AGPL"), as published by the Free Software Foundation.
+//
+// This program is distributed in the hope that it will be useful, but WITHOUT
+// ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+// FITNESS FOR A PARTICULAR PURPOSE.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+// Package queue is the durable input of eventbox. When it is enabled, the other inputs enqueue
+// messages instead of dispatching them, and the queue input dispatches them at least once:
+// a message is acked only after all of its subscribers returned, and is redelivered if eventbox
+// crashes before that.
+package queue
+
+import (
+	"errors"
+	"time"
+
+	"github.com/erda-project/erda/modules/eventbox/types"
+)
+
+// ErrDuplicated is returned by Enqueue if a message with the same idempotency key is already in the queue,
+// or was acked within the retention.
+var ErrDuplicated = errors.New("duplicated message")
+
+// Record is a message in the queue.
+type Record struct {
+	ID string
+	// Key is the idempotency key of the message
+	Key        string
+	Message    *types.Message
+	Attempts   int
+	EnqueuedAt time.Time
+}
+
+// Stats is the backlog of the queue.
+type Stats struct {
+	// Pending is the number of messages not acked, including the inflight ones
+	Pending int
+	// Inflight is the number of messages leased and not acked yet
+	Inflight int
+	// Lag is the age of the oldest pending message
+	Lag time.Duration
+}
+
+// Queue is a durable message queue, messages are redelivered until they are acked.
+type Queue interface {
+	// Enqueue persists m with the idempotency key, an empty key never duplicates.
+	Enqueue(key string, m *types.Message) error
+	// Lease returns at most n pending records in order, they are invisible to other consumers
+	// until they are acked, nacked, or ttl passed.
+	Lease(n int, ttl time.Duration) ([]*Record, error)
+	// Ack removes r from the queue.
+	Ack(r *Record) error
+	// Nack makes r visible again after delay.
+	Nack(r *Record, delay time.Duration) error
+	// Purge forgets the keys of the messages acked before t.
+	Purge(t time.Time) error
+	Stats() (Stats, error)
+	Close() error
+}
@@ -84,6 +84,6 @@ func (w *MonitorHTTP) Stat(ctx context.Context, req *http.Request, vars map[stri
 
 func (w *MonitorHTTP) GetHTTPEndPoints() []stypes.Endpoint {
 	return []stypes.Endpoint{
-		{"/stat", http.MethodGet, w.Stat},
+		{Path: "/stat", Method: http.MethodGet, Handler: w.Stat},
 	}
 }
@@ -41,6 +41,11 @@ const (
 	DINGDINGWorkNoticeOutput
 	MYSQLOutput
 	HTTPOutput
+	QueueInput
+	QueueInputDup
+	QueueRedeliver
+	DigestBuffered
+	DigestFlushed
 	LastType
 )
 
@@ -53,6 +58,11 @@ func infoTypeList() []InfoType {
 		DINGDINGWorkNoticeOutput,
 		MYSQLOutput,
 		HTTPOutput,
+		QueueInput,
+		QueueInputDup,
+		QueueRedeliver,
+		DigestBuffered,
+		DigestFlushed,
 	}
 }
 
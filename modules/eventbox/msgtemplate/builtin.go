@@ -0,0 +1,76 @@
+// Copyright (c) 2021 Terminus, Inc.
+//
+// This program is free software: you can use, redistribute, and/or modify
+// it under the terms of the GNU Affero General Public License, version 3
+// or later ("AGPL"), as published by the Free Software Foundation.
+//
+// This program is distributed in the hope that it will be useful, but WITHOUT
+// ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+// FITNESS FOR A PARTICULAR PURPOSE.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package msgtemplate
+
+import "github.com/erda-project/erda/apistructs"
+
+// builtinTemplates are used when no template of the same event, channel and locale is registered,
+// a registered one overrides the built-in one, and deleting it restores the built-in one.
+var builtinTemplates = map[string]Template{}
+
+func registerBuiltin(t Template) {
+	if err := Validate(&t); err != nil {
+		panic(err)
+	}
+	builtinTemplates[mkTemplateKey(t.Event, t.Channel, t.Locale)] = t
+}
+
+func init() {
+	// the sample shown when editing the notify template of an org custom alert
+	registerBuiltin(Template{
+		Event:   apistructs.OrgAlertNotifySampleTemplateEvent,
+		Channel: ChannelDefault,
+		Locale:  "zh",
+		Format:  FormatMarkdown,
+		Body: `
+    【机器负载异常告警】
+
+    Load5: {{.Params.load5_avg}}
+
+    集群: {{.Params.cluster_name}}
+
+    机器: {{.Params.host_ip}}
+
+    时间: {{.Params.timestamp}}
+
+    [查看详情]({{.Params.display_url}})
+
+    [查看记录]({{.Params.record_url}})
+
+    [确认告警]({{.Params.ack_url}})
+`,
+	})
+	registerBuiltin(Template{
+		Event:   apistructs.OrgAlertNotifySampleTemplateEvent,
+		Channel: ChannelDefault,
+		Format:  FormatMarkdown,
+		Body: `
+    【System load average alarm】
+
+    Load5: {{.Params.load5_avg}}
+
+    Cluster: {{.Params.cluster_name}}
+
+    IP: {{.Params.host_ip}}
+
+    Time: {{.Params.timestamp}}
+
+    [Details]({{.Params.display_url}})
+
+    [History]({{.Params.record_url}})
+
+    [Acknowledge]({{.Params.ack_url}})
+`,
+	})
+}
@@ -0,0 +1,150 @@
+// Copyright (c) 2021 Terminus, Inc.
+//
+// This program is free software: you can use, redistribute, and/or modify
+// it under the terms of the GNU Affero General Public License, version 3
+// or later ("AGPL"), as published by the Free Software Foundation.
+//
+// This program is distributed in the hope that it will be useful, but WITHOUT
+// ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+// FITNESS FOR A PARTICULAR PURPOSE.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package msgtemplate
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	"github.com/erda-project/erda/apistructs"
+	stypes "github.com/erda-project/erda/modules/eventbox/server/types"
+	"github.com/erda-project/erda/modules/eventbox/types"
+)
+
+const (
+	BadRequestCode        = "MT400"
+	NotFoundCode          = "MT404"
+	InternalServerErrCode = "MT500"
+)
+
+type TemplateHTTP struct {
+	registry *Registry
+}
+
+func NewHTTP(registry *Registry) *TemplateHTTP {
+	return &TemplateHTTP{registry: registry}
+}
+
+func (h *TemplateHTTP) List(ctx context.Context, req *http.Request, vars map[string]string) (stypes.Responser, error) {
+	list, err := h.registry.List(req.URL.Query().Get("event"), req.URL.Query().Get("channel"))
+	if err != nil {
+		return errorResponse(InternalServerErrCode, err), nil
+	}
+	return stypes.HTTPResponse{Compose: true, Content: list}, nil
+}
+
+func (h *TemplateHTTP) Inspect(ctx context.Context, req *http.Request, vars map[string]string) (stypes.Responser, error) {
+	t, err := h.registry.Get(vars["event"], vars["channel"], pathLocale(vars))
+	if err != nil {
+		return errorResponse(toCode(err), err), nil
+	}
+	return stypes.HTTPResponse{Compose: true, Content: t}, nil
+}
+
+func (h *TemplateHTTP) Put(ctx context.Context, req *http.Request, vars map[string]string) (stypes.Responser, error) {
+	var t Template
+	if err := json.NewDecoder(req.Body).Decode(&t); err != nil {
+		return errorResponse(BadRequestCode, err), nil
+	}
+	if err := Validate(&t); err != nil {
+		return errorResponse(BadRequestCode, err), nil
+	}
+	if err := h.registry.Put(t); err != nil {
+		return errorResponse(InternalServerErrCode, err), nil
+	}
+	return stypes.HTTPResponse{Compose: true, Content: ""}, nil
+}
+
+func (h *TemplateHTTP) Delete(ctx context.Context, req *http.Request, vars map[string]string) (stypes.Responser, error) {
+	if err := h.registry.Delete(vars["event"], vars["channel"], pathLocale(vars)); err != nil {
+		return errorResponse(toCode(err), err), nil
+	}
+	return stypes.HTTPResponse{Compose: true, Content: ""}, nil
+}
+
+// Preview renders a template against a sample message, the saved template is used when the request has no subject and body.
+func (h *TemplateHTTP) Preview(ctx context.Context, req *http.Request, vars map[string]string) (stypes.Responser, error) {
+	var r apistructs.MessageTemplatePreviewRequest
+	if err := json.NewDecoder(req.Body).Decode(&r); err != nil {
+		return errorResponse(BadRequestCode, err), nil
+	}
+	t := &r.Template
+	if t.Subject == "" && t.Body == "" {
+		saved, err := h.registry.Lookup(t.Event, t.Channel, t.Locale)
+		if err != nil {
+			return errorResponse(toCode(err), err), nil
+		}
+		t = saved
+	} else if err := Validate(t); err != nil {
+		return errorResponse(BadRequestCode, err), nil
+	}
+
+	msg := &types.Message{
+		Sender:  r.Message.Sender,
+		Content: r.Message.Content,
+		Labels:  map[types.LabelKey]interface{}{},
+	}
+	for k, v := range r.Message.Labels {
+		msg.Labels[types.LabelKey(k).NormalizeLabelKey()] = v
+	}
+	content, err := json.Marshal(msg.Content)
+	if err != nil {
+		return errorResponse(BadRequestCode, err), nil
+	}
+	_, data := messageData(msg, string(content))
+	if data.Event == "" {
+		data.Event = t.Event
+	}
+	rendered, err := Render(t, data)
+	if err != nil {
+		return errorResponse(BadRequestCode, err), nil
+	}
+	return stypes.HTTPResponse{Compose: true, Content: rendered}, nil
+}
+
+func (h *TemplateHTTP) GetHTTPEndPoints() []stypes.Endpoint {
+	return []stypes.Endpoint{
+		{"/message_templates", http.MethodGet, h.List},
+		{"/message_templates", http.MethodPut, h.Put},
+		{"/message_templates/{event}/{channel}/{locale}", http.MethodGet, h.Inspect},
+		{"/message_templates/{event}/{channel}/{locale}", http.MethodDelete, h.Delete},
+		{"/message_templates/actions/preview", http.MethodPost, h.Preview},
+	}
+}
+
+// pathLocale maps the locale "default" in paths to the empty locale.
+func pathLocale(vars map[string]string) string {
+	if l := vars["locale"]; l != defaultLocale {
+		return l
+	}
+	return ""
+}
+
+func toCode(err error) string {
+	if err == NotFoundErr {
+		return NotFoundCode
+	}
+	return InternalServerErrCode
+}
+
+func errorResponse(code string, err error) stypes.HTTPResponse {
+	return stypes.HTTPResponse{
+		Error: &stypes.ErrorResponse{
+			Code: code,
+			Msg:  err.Error(),
+		},
+		Compose: true,
+	}
+}
@@ -0,0 +1,238 @@
+// Copyright (c) 2021 Terminus, Inc.
+//
+// This program is free software: you can use, redistribute, and/or modify
+// it under the terms of the GNU Affero General Public License, version 3
+// or later ("AGPL"), as published by the Free Software Foundation.
+//
+// This program is distributed in the hope that it will be useful, but WITHOUT
+// ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+// FITNESS FOR A PARTICULAR PURPOSE.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package msgtemplate
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+
+	"github.com/erda-project/erda/modules/eventbox/constant"
+	"github.com/erda-project/erda/modules/eventbox/types"
+	"github.com/erda-project/erda/pkg/jsonstore"
+)
+
+var NotFoundErr = errors.New("template not found")
+
+const defaultLocale = "default"
+
+// Registry stores templates in etcd, and keeps them in memory for rendering.
+type Registry struct {
+	js jsonstore.JsonStore
+}
+
+func New() (*Registry, error) {
+	js, err := jsonstore.New(jsonstore.UseMemEtcdStore(context.Background(), constant.TemplateDir, nil, nil))
+	if err != nil {
+		return nil, err
+	}
+	return &Registry{js: js}, nil
+}
+
+// List lists the templates, event and channel are optional filters.
+func (r *Registry) List(event, channel string) ([]Template, error) {
+	prefix := constant.TemplateDir + "/"
+	if event != "" {
+		prefix += event + "/"
+	}
+	list := []Template{}
+	if err := r.js.ForEach(context.Background(), prefix, Template{}, func(_ string, v interface{}) error {
+		t := v.(*Template)
+		if channel == "" || t.Channel == channel {
+			list = append(list, *t)
+		}
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	registered := make(map[string]bool, len(list))
+	for _, t := range list {
+		registered[mkTemplateKey(t.Event, t.Channel, t.Locale)] = true
+	}
+	for key, t := range builtinTemplates {
+		if !registered[key] && strings.HasPrefix(key, prefix) && (channel == "" || t.Channel == channel) {
+			list = append(list, t)
+		}
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return mkTemplateKey(list[i].Event, list[i].Channel, list[i].Locale) < mkTemplateKey(list[j].Event, list[j].Channel, list[j].Locale)
+	})
+	return list, nil
+}
+
+// Get returns the template of exactly event, channel and locale, the registered one overrides the built-in one.
+func (r *Registry) Get(event, channel, locale string) (*Template, error) {
+	t, err := r.getRegistered(event, channel, locale)
+	if err == NotFoundErr {
+		if builtin, ok := builtinTemplates[mkTemplateKey(event, channel, locale)]; ok {
+			return &builtin, nil
+		}
+	}
+	return t, err
+}
+
+func (r *Registry) getRegistered(event, channel, locale string) (*Template, error) {
+	t := Template{}
+	if err := r.js.Get(context.Background(), mkTemplateKey(event, channel, locale), &t); err != nil {
+		if err == jsonstore.NotFoundErr {
+			return nil, NotFoundErr
+		}
+		return nil, err
+	}
+	return &t, nil
+}
+
+// Put creates or updates a template.
+func (r *Registry) Put(t Template) error {
+	if err := Validate(&t); err != nil {
+		return err
+	}
+	now := time.Now().Format("2006-01-02 15:04:05")
+	if old, err := r.getRegistered(t.Event, t.Channel, t.Locale); err == nil {
+		t.CreatedAt = old.CreatedAt
+	} else {
+		t.CreatedAt = now
+	}
+	t.UpdatedAt = now
+	return r.js.Put(context.Background(), mkTemplateKey(t.Event, t.Channel, t.Locale), t)
+}
+
+// Delete removes a registered template, the built-in one of the same key is used again.
+func (r *Registry) Delete(event, channel, locale string) error {
+	if _, err := r.getRegistered(event, channel, locale); err != nil {
+		return err
+	}
+	var unused interface{}
+	return r.js.Remove(context.Background(), mkTemplateKey(event, channel, locale), &unused)
+}
+
+// Lookup finds the template for the channel of the event, falling back from the locale to its language and the default locale,
+// and then from the channel to the default channel.
+func (r *Registry) Lookup(event, channel, locale string) (*Template, error) {
+	locales := []string{locale}
+	if i := strings.IndexAny(locale, "-_"); i > 0 {
+		locales = append(locales, locale[:i])
+	}
+	locales = append(locales, "")
+	for _, ch := range []string{channel, ChannelDefault} {
+		for _, l := range locales {
+			t, err := r.Get(event, ch, l)
+			if err == NotFoundErr {
+				continue
+			}
+			return t, err
+		}
+	}
+	return nil, NotFoundErr
+}
+
+// RenderMessage renders msg for the channel with the template of its event, ok is false when there is no template for it,
+// and the subscriber should render msg as before. content is the marshaled content of msg.
+func (r *Registry) RenderMessage(channel string, msg *types.Message, content string) (*Rendered, bool) {
+	if r == nil {
+		return nil, false
+	}
+	label, data := messageData(msg, content)
+	if data.Event == "" {
+		return nil, false
+	}
+	t, err := r.Lookup(data.Event, channel, label.Locale)
+	if err != nil {
+		if err != NotFoundErr {
+			logrus.Errorf("msgtemplate: lookup template of event: %s, channel: %s, err: %v", data.Event, channel, err)
+		}
+		return nil, false
+	}
+	rendered, err := Render(t, data)
+	if err != nil {
+		logrus.Errorf("msgtemplate: render template of event: %s, channel: %s, err: %v", data.Event, channel, err)
+		return nil, false
+	}
+	return rendered, true
+}
+
+// messageData collects the data for templates from the TEMPLATE label, the WEBHOOK label and the content of msg.
+func messageData(msg *types.Message, content string) (Label, *Data) {
+	var label Label
+	if v, ok := msg.Labels[types.LabelKey(constant.TemplateLabelKey)]; ok {
+		decodeLabel(v, &label)
+	}
+	data := &Data{
+		Event:  label.Event,
+		Sender: msg.Sender,
+		Time:   time.Unix(0, msg.Time),
+		Params: label.Params,
+		Labels: map[string]interface{}{},
+	}
+	if data.Event == "" {
+		if v, ok := msg.Labels[types.LabelKey(constant.WebhookLabelKey)]; ok {
+			var wh struct {
+				Event string `json:"event"`
+			}
+			decodeLabel(v, &wh)
+			data.Event = wh.Event
+		}
+	}
+	for k, v := range msg.Labels {
+		data.Labels[strings.TrimPrefix(k.Normalize(), "/")] = v
+	}
+	if err := json.Unmarshal([]byte(content), &data.Content); err != nil {
+		data.Content = content
+	}
+	if data.Params == nil {
+		if m, ok := data.Content.(map[string]interface{}); ok {
+			if params, ok := m["params"].(map[string]interface{}); ok {
+				data.Params = map[string]string{}
+				for k, v := range params {
+					data.Params[k] = fmt.Sprint(v)
+				}
+			}
+		}
+	}
+	return label, data
+}
+
+// MessageParams returns the params of msg for templates.
+func MessageParams(msg *types.Message) map[string]string {
+	content, ok := msg.Content.(string)
+	if !ok {
+		raw, _ := json.Marshal(msg.Content)
+		content = string(raw)
+	}
+	_, data := messageData(msg, content)
+	return data.Params
+}
+
+func decodeLabel(v interface{}, out interface{}) {
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return
+	}
+	json.Unmarshal(raw, out)
+}
+
+// template dir structure
+// /<templatedir>/<event>/<channel>/<locale> -> <template>
+func mkTemplateKey(event, channel, locale string) string {
+	if locale == "" {
+		locale = defaultLocale
+	}
+	return strings.Join([]string{constant.TemplateDir, event, channel, locale}, "/")
+}
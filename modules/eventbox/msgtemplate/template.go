@@ -0,0 +1,238 @@
+// Copyright (c) 2021 Terminus, Inc.
+//
+// This program is free software: you can use, redistribute, and/or modify
+// it under the terms of the GNU Affero General Public License, version 3
+// or later ("AGPL"), as published by the Free Software Foundation.
+//
+// This program is distributed in the hope that it will be useful, but WITHOUT
+// ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+// FITNESS FOR A PARTICULAR PURPOSE.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+// Package msgtemplate renders the subject and body of eventbox messages with templates
+// registered by event, channel and locale.
+package msgtemplate
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	htmltemplate "html/template"
+	"io"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/erda-project/erda/apistructs"
+)
+
+// channels
+const (
+	ChannelDefault  = "default"
+	ChannelDingding = "dingding"
+	ChannelEmail    = "email"
+	ChannelSMS      = "sms"
+	ChannelVMS      = "vms"
+	ChannelMBox     = "mbox"
+	ChannelSlack    = "slack"
+	ChannelTeams    = "teams"
+	ChannelFeishu   = "feishu"
+	ChannelWeCom    = "wecom"
+)
+
+// formats
+const (
+	FormatText     = "text"
+	FormatMarkdown = "markdown"
+	FormatHTML     = "html"
+)
+
+const maxOutputSize = 64 * 1024
+
+type Template = apistructs.MessageTemplate
+type Rendered = apistructs.MessageTemplateRendered
+type Label = apistructs.MessageTemplateLabel
+
+// Data is the data that templates are executed with.
+type Data struct {
+	Event  string
+	Sender string
+	// Time of the message
+	Time time.Time
+	// Params of the TEMPLATE label, or the params in the content of the message
+	Params map[string]string
+	// Content is the decoded content of the message
+	Content interface{}
+	Labels  map[string]interface{}
+}
+
+var funcs = map[string]interface{}{
+	"default": func(def, v interface{}) interface{} {
+		if v == nil || v == "" {
+			return def
+		}
+		return v
+	},
+	"upper": strings.ToUpper,
+	"lower": strings.ToLower,
+	"trim":  strings.TrimSpace,
+	"replace": func(old, new, s string) string {
+		return strings.Replace(s, old, new, -1)
+	},
+	"truncate": func(n int, s string) string {
+		if runes := []rune(s); len(runes) > n {
+			return string(runes[:n]) + "..."
+		}
+		return s
+	},
+	"join": func(sep string, v interface{}) string {
+		list, ok := v.([]interface{})
+		if !ok {
+			return fmt.Sprint(v)
+		}
+		parts := make([]string, len(list))
+		for i, item := range list {
+			parts[i] = fmt.Sprint(item)
+		}
+		return strings.Join(parts, sep)
+	},
+	"json": func(v interface{}) (string, error) {
+		b, err := json.Marshal(v)
+		return string(b), err
+	},
+	"time":     formatTime,
+	"markdown": escapeMarkdown,
+}
+
+// Validate checks the channel, the format and the syntax of t.
+func Validate(t *Template) error {
+	if t.Event == "" {
+		return fmt.Errorf("not provide template's event")
+	}
+	switch t.Channel {
+	case ChannelDefault, ChannelDingding, ChannelEmail, ChannelSMS, ChannelVMS, ChannelMBox,
+		ChannelSlack, ChannelTeams, ChannelFeishu, ChannelWeCom:
+	default:
+		return fmt.Errorf("bad template's channel: %q", t.Channel)
+	}
+	switch t.Format {
+	case "":
+		t.Format = FormatText
+	case FormatText, FormatMarkdown, FormatHTML:
+	default:
+		return fmt.Errorf("bad template's format: %q, only support [text, markdown, html]", t.Format)
+	}
+	if strings.Contains(t.Event+t.Channel+t.Locale, "/") {
+		return fmt.Errorf("event, channel and locale must not contain '/'")
+	}
+	if _, err := template.New("subject").Funcs(funcs).Parse(t.Subject); err != nil {
+		return errors.Wrap(err, "bad subject")
+	}
+	if t.Format == FormatHTML {
+		_, err := htmltemplate.New("body").Funcs(funcs).Parse(t.Body)
+		return errors.Wrap(err, "bad body")
+	}
+	_, err := template.New("body").Funcs(funcs).Parse(t.Body)
+	return errors.Wrap(err, "bad body")
+}
+
+// Render executes the subject and the body of t with data, html bodies are escaped.
+func Render(t *Template, data *Data) (*Rendered, error) {
+	r := &Rendered{Format: t.Format}
+	if r.Format == "" {
+		r.Format = FormatText
+	}
+	subject, err := template.New("subject").Funcs(funcs).Option("missingkey=zero").Parse(t.Subject)
+	if err != nil {
+		return nil, errors.Wrap(err, "bad subject")
+	}
+	if r.Subject, err = execute(subject, data); err != nil {
+		return nil, err
+	}
+	var body interface {
+		Execute(io.Writer, interface{}) error
+	}
+	if r.Format == FormatHTML {
+		body, err = htmltemplate.New("body").Funcs(funcs).Option("missingkey=zero").Parse(t.Body)
+	} else {
+		body, err = template.New("body").Funcs(funcs).Option("missingkey=zero").Parse(t.Body)
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "bad body")
+	}
+	if r.Body, err = execute(body, data); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func execute(t interface {
+	Execute(io.Writer, interface{}) error
+}, data *Data) (string, error) {
+	w := &limitedWriter{limit: maxOutputSize}
+	if err := t.Execute(w, data); err != nil {
+		return "", err
+	}
+	return w.String(), nil
+}
+
+type limitedWriter struct {
+	bytes.Buffer
+	limit int
+}
+
+func (w *limitedWriter) Write(p []byte) (int, error) {
+	if w.Len()+len(p) > w.limit {
+		return 0, fmt.Errorf("rendered message exceeds %d bytes", w.limit)
+	}
+	return w.Buffer.Write(p)
+}
+
+// formatTime formats a unix timestamp in seconds, milliseconds or nanoseconds, or a RFC3339 string.
+func formatTime(layout string, v interface{}) string {
+	var t time.Time
+	switch val := v.(type) {
+	case time.Time:
+		t = val
+	case string:
+		if parsed, err := time.Parse(time.RFC3339, val); err == nil {
+			t = parsed
+		} else if i, err := strconv.ParseInt(val, 10, 64); err == nil {
+			t = unixTime(i)
+		} else {
+			return val
+		}
+	case float64:
+		t = unixTime(int64(val))
+	case int64:
+		t = unixTime(val)
+	case int:
+		t = unixTime(int64(val))
+	default:
+		return fmt.Sprint(v)
+	}
+	return t.Format(layout)
+}
+
+func unixTime(i int64) time.Time {
+	switch {
+	case i > 1e17:
+		return time.Unix(0, i)
+	case i > 1e11:
+		return time.Unix(0, i*int64(time.Millisecond))
+	}
+	return time.Unix(i, 0)
+}
+
+var markdownReplacer = strings.NewReplacer(
+	`\`, `\\`, "`", "\\`", "*", `\*`, "_", `\_`, "[", `\[`, "]", `\]`, "#", `\#`, "|", `\|`, "<", "&lt;", ">", "&gt;",
+)
+
+func escapeMarkdown(v interface{}) string {
+	return markdownReplacer.Replace(fmt.Sprint(v))
+}
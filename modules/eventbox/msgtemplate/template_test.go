@@ -0,0 +1,132 @@
+// Copyright (c) 2021 Terminus, Inc.
+//
+// This program is free software: you can use, redistribute, and/or modify
+// it under the terms of the GNU Affero General Public License, version 3
+// or later ("AGPL"), as published by the Free Software Foundation.
+//
+// This program is distributed in the hope that it will be useful, but WITHOUT
+// ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+// FITNESS FOR A PARTICULAR PURPOSE.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package msgtemplate
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/erda-project/erda/apistructs"
+	"github.com/erda-project/erda/modules/eventbox/constant"
+	"github.com/erda-project/erda/modules/eventbox/types"
+	"github.com/erda-project/erda/pkg/jsonstore"
+)
+
+func TestValidate(t *testing.T) {
+	tpl := &Template{Event: "e", Channel: ChannelEmail, Body: "{{.Params.name}}"}
+	assert.Nil(t, Validate(tpl))
+	assert.Equal(t, FormatText, tpl.Format)
+	assert.NotNil(t, Validate(&Template{Event: "e", Channel: "unknown"}))
+	assert.NotNil(t, Validate(&Template{Event: "e", Channel: ChannelEmail, Format: "pdf"}))
+	assert.NotNil(t, Validate(&Template{Event: "e", Channel: ChannelEmail, Body: "{{.Params.name"}))
+	assert.NotNil(t, Validate(&Template{Event: "a/b", Channel: ChannelEmail}))
+}
+
+func TestRender(t *testing.T) {
+	data := &Data{
+		Event:   "pipeline",
+		Params:  map[string]string{"name": "<b>erda</b>"},
+		Content: map[string]interface{}{"tags": []interface{}{"a", "b"}, "ts": float64(1600000000000)},
+	}
+	r, err := Render(&Template{
+		Subject: "[{{upper .Event}}] {{.Params.name | truncate 4}}",
+		Body:    `{{join "," .Content.tags}} {{time "2006-01-02" .Content.ts}} {{default "none" .Params.missing}} {{markdown "*x*"}}`,
+	}, data)
+	assert.Nil(t, err)
+	assert.Equal(t, "[PIPELINE] <b>e...", r.Subject)
+	assert.Equal(t, `a,b `+time.Unix(1600000000, 0).Format("2006-01-02")+` none \*x\*`, r.Body)
+	assert.Equal(t, FormatText, r.Format)
+
+	r, err = Render(&Template{Subject: "{{.Params.name}}", Body: "<p>{{.Params.name}}</p>", Format: FormatHTML}, data)
+	assert.Nil(t, err)
+	assert.Equal(t, "<b>erda</b>", r.Subject)
+	assert.Equal(t, "<p>&lt;b&gt;erda&lt;/b&gt;</p>", r.Body)
+
+	_, err = Render(&Template{Body: `{{range .Content.tags}}{{printf "%70000s" .}}{{end}}`}, data)
+	assert.NotNil(t, err)
+}
+
+func TestRegistryRenderMessage(t *testing.T) {
+	js, err := jsonstore.New(jsonstore.UseMemStore())
+	assert.Nil(t, err)
+	r := &Registry{js: js}
+	assert.Nil(t, r.Put(Template{Event: "deploy", Channel: ChannelDefault, Subject: "default", Body: "{{.Params.app}}"}))
+	assert.Nil(t, r.Put(Template{Event: "deploy", Channel: ChannelEmail, Locale: "zh", Subject: "zh", Body: "{{.Params.app}}"}))
+
+	tpl, err := r.Lookup("deploy", ChannelEmail, "zh-CN")
+	assert.Nil(t, err)
+	assert.Equal(t, "zh", tpl.Subject)
+	tpl, err = r.Lookup("deploy", ChannelEmail, "en")
+	assert.Nil(t, err)
+	assert.Equal(t, "default", tpl.Subject)
+	_, err = r.Lookup("other", ChannelEmail, "")
+	assert.Equal(t, NotFoundErr, err)
+
+	msg := &types.Message{Labels: map[types.LabelKey]interface{}{
+		types.LabelKey(constant.TemplateLabelKey): map[string]interface{}{"event": "deploy", "locale": "zh-CN"},
+	}}
+	rendered, ok := r.RenderMessage(ChannelEmail, msg, `{"params":{"app":"web"}}`)
+	assert.True(t, ok)
+	assert.Equal(t, "zh", rendered.Subject)
+	assert.Equal(t, "web", rendered.Body)
+
+	_, ok = r.RenderMessage(ChannelEmail, &types.Message{}, `{}`)
+	assert.False(t, ok)
+	var nilRegistry *Registry
+	_, ok = nilRegistry.RenderMessage(ChannelEmail, msg, `{}`)
+	assert.False(t, ok)
+
+	list, err := r.List("deploy", "")
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(list))
+	assert.Nil(t, r.Delete("deploy", ChannelEmail, "zh"))
+	assert.Equal(t, NotFoundErr, r.Delete("deploy", ChannelEmail, "zh"))
+}
+
+func TestRegistryBuiltin(t *testing.T) {
+	js, err := jsonstore.New(jsonstore.UseMemStore())
+	assert.Nil(t, err)
+	r := &Registry{js: js}
+	event := apistructs.OrgAlertNotifySampleTemplateEvent
+
+	msg := &types.Message{Labels: map[types.LabelKey]interface{}{
+		types.LabelKey(constant.TemplateLabelKey): map[string]interface{}{"event": event, "locale": "zh-CN"},
+	}}
+	rendered, ok := r.RenderMessage(ChannelDingding, msg, `{"params":{"ack_url":"{{ack_url}}"}}`)
+	assert.True(t, ok)
+	assert.Contains(t, rendered.Body, "[确认告警]({{ack_url}})")
+	tpl, err := r.Lookup(event, ChannelEmail, "en-US")
+	assert.Nil(t, err)
+	assert.Contains(t, tpl.Body, "[Acknowledge]({{.Params.ack_url}})")
+
+	list, err := r.List(event, "")
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(list))
+
+	// a registered template overrides the built-in one, and deleting it restores the built-in one
+	assert.Nil(t, r.Put(Template{Event: event, Channel: ChannelDefault, Locale: "zh", Body: "custom"}))
+	tpl, err = r.Lookup(event, ChannelEmail, "zh")
+	assert.Nil(t, err)
+	assert.Equal(t, "custom", tpl.Body)
+	list, err = r.List(event, "")
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(list))
+	assert.Nil(t, r.Delete(event, ChannelDefault, "zh"))
+	assert.Equal(t, NotFoundErr, r.Delete(event, ChannelDefault, "zh"))
+	tpl, err = r.Lookup(event, ChannelEmail, "zh")
+	assert.Nil(t, err)
+	assert.NotEqual(t, "custom", tpl.Body)
+}
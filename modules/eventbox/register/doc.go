@@ -22,5 +22,16 @@ body: {"labels": map[string]string{"<label1>":"<value1>", "<label2>":"<value2>"}
 之后在发送消息的时候，带上 label : {"REGISTERED_LABEL":"<VALUE>"},
 相当于 带上了 上面所注册的所有 labels
 
+注册时可以带上过滤表达式，只有满足表达式的消息才会带上所注册的 labels
+body: {"labels": {...}, "filter": "content.status == \"Failed\" && labels.workspace in [\"PROD\", \"STAGING\"]"}
+
+过滤表达式语法 (详见 expr 包):
+  - 参数为 labels, content, event 开头的路径, e.g. content.pipeline.tags.0, 路径不存在时为 nil;
+    含有字母、数字、_ 以外字符的路径放在方括号中, e.g. [labels.some-key]
+  - 列表放在方括号中, e.g. ["PROD"], in 的右侧必须是列表或路径; 方括号中只有一个路径时是该路径而不是列表
+  - 支持 ==, !=, >, >=, <, <=, =~, !~ (正则必须是字符串常量), in, &&, ||, !, 括号
+  - 兼容 govaluate 语法: 路径放在方括号中, 列表放在圆括号中且至少两项, e.g.
+    [content.status] == "Failed" && [labels.workspace] in ("PROD", "STAGING"),
+    圆括号中的单个值不是列表, [labels.workspace] in ("PROD") 会校验失败
 */
 package register
@@ -18,6 +18,7 @@ import (
 	"encoding/json"
 	"net/http"
 
+	"github.com/erda-project/erda/modules/eventbox/constant"
 	stypes "github.com/erda-project/erda/modules/eventbox/server/types"
 	"github.com/erda-project/erda/modules/eventbox/types"
 
@@ -28,6 +29,8 @@ import (
 type PutRequest struct {
 	Key    string                         `json:"key"`
 	Labels map[types.LabelKey]interface{} `json:"labels"`
+	// Filter is the expression messages must satisfy to get the labels, e.g. [content.status] == "Failed"
+	Filter string `json:"filter"`
 }
 
 type DelRequest struct {
@@ -55,6 +58,18 @@ func (r *RegisterHTTP) Put(ctx context.Context, req *http.Request, vars map[stri
 			Content: "unmarshal message failed",
 		}, err
 	}
+	if m.Filter != "" {
+		if err := ValidateFilter(m.Filter); err != nil {
+			return stypes.HTTPResponse{
+				Status:  http.StatusBadRequest,
+				Content: err.Error(),
+			}, nil
+		}
+		if m.Labels == nil {
+			m.Labels = map[types.LabelKey]interface{}{}
+		}
+		m.Labels[types.LabelKey(constant.FilterLabelKey)] = m.Filter
+	}
 	if err := r.register.Put(m.Key, m.Labels); err != nil {
 		err := errors.Errorf("RegisterHTTP Put: %v", err)
 		logrus.Error(err)
@@ -15,11 +15,13 @@ package register
 
 import (
 	"context"
+	"fmt"
 	"path/filepath"
 	"strings"
 	"sync"
 
 	"github.com/erda-project/erda/modules/eventbox/constant"
+	"github.com/erda-project/erda/modules/eventbox/expr"
 	"github.com/erda-project/erda/modules/eventbox/register/label"
 	"github.com/erda-project/erda/modules/eventbox/types"
 	"github.com/erda-project/erda/pkg/jsonstore"
@@ -82,6 +84,11 @@ func (r *registerImpl) Put(key string, labels map[types.LabelKey]interface{}) er
 	for k, l := range labels {
 		normalizedLabels[k.NormalizeLabelKey()] = l
 	}
+	if filter, ok := normalizedLabels[types.LabelKey(constant.FilterLabelKey)]; ok {
+		if err := ValidateFilter(filter); err != nil {
+			return err
+		}
+	}
 
 	path := filepath.Join(constant.RegisterDir, normalizedKey)
 	if err := r.js.Put(context.Background(), path, normalizedLabels); err != nil {
@@ -90,6 +97,16 @@ func (r *registerImpl) Put(key string, labels map[types.LabelKey]interface{}) er
 	return nil
 }
 
+// ValidateFilter checks the filter expression of a registration.
+func ValidateFilter(filter interface{}) error {
+	s, ok := filter.(string)
+	if !ok {
+		return fmt.Errorf("filter expression should be a string, got %T", filter)
+	}
+	_, err := expr.CompileFilter(s)
+	return err
+}
+
 func (r *registerImpl) Del(key string) error {
 	normalizedKey := types.LabelKey(key).Normalize()
 	path := filepath.Join(constant.RegisterDir, normalizedKey)
@@ -0,0 +1,148 @@
+// Copyright (c) 2021 Terminus, Inc.
+//
+// This program is free software: you can use, redistribute, and/or modify
+// it under the terms of the GNU Affero General Public License, version 3
+// or later ("AGPL"), as published by the Free Software Foundation.
+//
+// This program is distributed in the hope that it will be useful, but WITHOUT
+// ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+// FITNESS FOR A PARTICULAR PURPOSE.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+// Package chat holds what the chat subscribers, such as slack, teams, feishu and wecom, have in common:
+// the channel independent card of a message, the destinations, the rate limits and the sending.
+package chat
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/erda-project/erda/modules/eventbox/constant"
+	"github.com/erda-project/erda/modules/eventbox/msgtemplate"
+	"github.com/erda-project/erda/modules/eventbox/types"
+	"github.com/erda-project/erda/modules/eventbox/webhook"
+)
+
+// label: CARD
+const CardLabelKey = "/CARD"
+
+// Card is the content of a chat message, each subscriber converts it to the card format of its platform.
+type Card struct {
+	Title string `json:"title"`
+	// Text is in markdown
+	Text    string   `json:"text"`
+	Fields  []Field  `json:"fields"`
+	Buttons []Button `json:"buttons"`
+	// Color is the theme color of the card, such as red, green, or #ff0000
+	Color string `json:"color"`
+}
+
+// Field is a short key value shown in the card.
+type Field struct {
+	Title string `json:"title"`
+	Value string `json:"value"`
+}
+
+// Button opens URL when clicked.
+type Button struct {
+	Text string `json:"text"`
+	URL  string `json:"url"`
+}
+
+// BuildCard builds the card of msg for the channel, content is the marshaled content of msg.
+// The card starts from the CARD label, the title and text come from the template of the message,
+// or the MARKDOWN label, or the content itself. Webhook events get their scope as fields.
+func BuildCard(channel string, msg *types.Message, content string, templates *msgtemplate.Registry) *Card {
+	card := &Card{}
+	if v, ok := msg.Labels[types.LabelKey(CardLabelKey)]; ok {
+		if err := decodeLabel(v, card); err != nil {
+			logrus.Warnf("chat: illegal [CARD] label value: %v", err)
+		}
+	}
+	var md struct {
+		Title string `json:"title"`
+		Text  string `json:"text"`
+	}
+	if v, ok := msg.Labels["/MARKDOWN"]; ok {
+		decodeLabel(v, &md)
+	}
+	_, isWebhook := msg.Labels[types.LabelKey(constant.WebhookLabelKey)]
+
+	var text string
+	if rendered, ok := templates.RenderMessage(channel, msg, content); ok {
+		if rendered.Subject != "" {
+			md.Title = rendered.Subject
+		}
+		text = rendered.Body
+	} else if md.Text != "" {
+		text = md.Text
+	} else {
+		text = prettyPrint(content, isWebhook)
+	}
+	if card.Title == "" {
+		card.Title = md.Title
+	}
+	if card.Text == "" {
+		card.Text = text
+	}
+	if isWebhook {
+		var em webhook.EventMessage
+		if err := json.Unmarshal([]byte(content), &em); err == nil && em.Event != "" {
+			if card.Title == "" {
+				card.Title = fmt.Sprintf("%s %s", em.Event, em.Action)
+			}
+			card.Fields = append(card.Fields, eventFields(em)...)
+		}
+	}
+	return card
+}
+
+func eventFields(em webhook.EventMessage) []Field {
+	var fields []Field
+	for _, f := range []Field{
+		{Title: "Event", Value: em.Event},
+		{Title: "Action", Value: em.Action},
+		{Title: "Project", Value: em.ProjectID},
+		{Title: "Application", Value: em.ApplicationID},
+		{Title: "Env", Value: em.Env},
+		{Title: "Time", Value: em.TimeStamp},
+	} {
+		if f.Value != "" {
+			fields = append(fields, f)
+		}
+	}
+	return fields
+}
+
+// prettyPrint uses the string as is, formats webhook events, and indents other json.
+func prettyPrint(content string, isWebhook bool) string {
+	var s string
+	if err := json.Unmarshal([]byte(content), &s); err == nil {
+		return s
+	}
+	var v interface{}
+	if err := json.Unmarshal([]byte(content), &v); err != nil {
+		return content
+	}
+	if isWebhook {
+		if s, err := webhook.Format(v); err == nil {
+			return s
+		}
+	}
+	if b, err := json.MarshalIndent(v, "", "    "); err == nil {
+		return string(b)
+	}
+	return content
+}
+
+func decodeLabel(v interface{}, out interface{}) error {
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(raw, out)
+}
@@ -0,0 +1,99 @@
+// Copyright (c) 2021 Terminus, Inc.
+//
+// This program is free software: you can use, redistribute, and/or modify
+// it under the terms of the GNU Affero General Public License, version 3
+// or later ("AGPL"), as published by the Free Software Foundation.
+//
+// This program is distributed in the hope that it will be useful, but WITHOUT
+// ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+// FITNESS FOR A PARTICULAR PURPOSE.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package chat
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/erda-project/erda/modules/eventbox/types"
+)
+
+func TestLimiterWait(t *testing.T) {
+	now := time.Unix(0, 0)
+	var slept time.Duration
+	l := NewLimiter(60, 2, 2500*time.Millisecond)
+	l.now = func() time.Time { return now }
+	l.sleep = func(d time.Duration) { slept += d }
+
+	// burst
+	assert.Nil(t, l.Wait("a"))
+	assert.Nil(t, l.Wait("a"))
+	assert.Equal(t, time.Duration(0), slept)
+	// queued
+	assert.Nil(t, l.Wait("a"))
+	assert.Equal(t, time.Second, slept)
+	assert.Nil(t, l.Wait("a"))
+	assert.Equal(t, 3*time.Second, slept)
+	// too long to wait
+	assert.NotNil(t, l.Wait("a"))
+	// other destinations have their own buckets
+	assert.Nil(t, l.Wait("b"))
+	assert.Equal(t, 3*time.Second, slept)
+	// refilled
+	now = now.Add(time.Minute)
+	assert.Nil(t, l.Wait("a"))
+	assert.Equal(t, 3*time.Second, slept)
+
+	var nilLimiter *Limiter
+	assert.Nil(t, nilLimiter.Wait("a"))
+}
+
+func TestParseTargets(t *testing.T) {
+	targets, err := ParseTargets(`["https://a", {"url": "https://b", "secret": "s"}, {"token": "t", "channel": "c"}]`)
+	assert.Nil(t, err)
+	assert.Equal(t, []Target{{URL: "https://a"}, {URL: "https://b", Secret: "s"}, {Token: "t", Channel: "c"}}, targets)
+	assert.Equal(t, "#c", targets[2].Key())
+
+	_, err = ParseTargets(`"https://a"`)
+	assert.NotNil(t, err)
+	_, err = ParseTargets(`[1]`)
+	assert.NotNil(t, err)
+}
+
+func TestBuildCard(t *testing.T) {
+	msg := &types.Message{Labels: map[types.LabelKey]interface{}{
+		CardLabelKey: map[string]interface{}{
+			"title":   "deploy failed",
+			"buttons": []interface{}{map[string]interface{}{"text": "View", "url": "https://erda.cloud"}},
+			"color":   "red",
+		},
+	}}
+	card := BuildCard("slack", msg, `"pipeline 1 failed"`, nil)
+	assert.Equal(t, &Card{
+		Title:   "deploy failed",
+		Text:    "pipeline 1 failed",
+		Buttons: []Button{{Text: "View", URL: "https://erda.cloud"}},
+		Color:   "red",
+	}, card)
+
+	msg = &types.Message{Labels: map[types.LabelKey]interface{}{
+		"/MARKDOWN": map[string]interface{}{"title": "title", "text": "**text**"},
+	}}
+	card = BuildCard("slack", msg, `{"a": 1}`, nil)
+	assert.Equal(t, "title", card.Title)
+	assert.Equal(t, "**text**", card.Text)
+
+	card = BuildCard("slack", &types.Message{}, `{"a":1}`, nil)
+	assert.Equal(t, "{\n    \"a\": 1\n}", card.Text)
+}
+
+func TestTruncate(t *testing.T) {
+	assert.Equal(t, "abc", Truncate("abc", 3))
+	assert.Equal(t, "a...", Truncate("abcde", 4))
+	assert.Equal(t, "中文中文", Truncate("中文中文", 4))
+	assert.Equal(t, "中文...", Truncate("中文中文中文", 5))
+}
@@ -0,0 +1,86 @@
+// Copyright (c) 2021 Terminus, Inc.
+//
+// This program is free software: you can use, redistribute, and/or modify
+// it under the terms of the GNU Affero General Public License, version 3
+// or later ("AGPL"), as published by the Free Software Foundation.
+//
+// This program is distributed in the hope that it will be useful, but WITHOUT
+// ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+// FITNESS FOR A PARTICULAR PURPOSE.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package chat
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Limiter limits the rate of messages to each destination with token buckets,
+// a message waits for its turn, or fails if the wait is longer than maxWait.
+type Limiter struct {
+	rate    float64 // tokens per second
+	burst   float64
+	maxWait time.Duration
+	now     func() time.Time
+	sleep   func(time.Duration)
+
+	lock    sync.Mutex
+	buckets map[string]*bucket
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// NewLimiter returns a Limiter allows perMinute messages to each destination, and burst at most.
+func NewLimiter(perMinute, burst int, maxWait time.Duration) *Limiter {
+	if burst < 1 {
+		burst = 1
+	}
+	return &Limiter{
+		rate:    float64(perMinute) / 60,
+		burst:   float64(burst),
+		maxWait: maxWait,
+		now:     time.Now,
+		sleep:   time.Sleep,
+		buckets: make(map[string]*bucket),
+	}
+}
+
+// Wait blocks until a message can be sent to dest.
+func (l *Limiter) Wait(dest string) error {
+	if l == nil || l.rate <= 0 {
+		return nil
+	}
+	l.lock.Lock()
+	now := l.now()
+	b, ok := l.buckets[dest]
+	if !ok {
+		b = &bucket{tokens: l.burst, last: now}
+		l.buckets[dest] = b
+	}
+	b.tokens += now.Sub(b.last).Seconds() * l.rate
+	if b.tokens > l.burst {
+		b.tokens = l.burst
+	}
+	b.last = now
+	var wait time.Duration
+	if b.tokens < 1 {
+		wait = time.Duration((1 - b.tokens) / l.rate * float64(time.Second))
+		if wait > l.maxWait {
+			l.lock.Unlock()
+			return fmt.Errorf("rate limited, dest: %s, retry after: %v", dest, wait)
+		}
+	}
+	b.tokens-- // reserve the token, the waiters are queued by the negative tokens
+	l.lock.Unlock()
+	if wait > 0 {
+		l.sleep(wait)
+	}
+	return nil
+}
@@ -0,0 +1,132 @@
+// Copyright (c) 2021 Terminus, Inc.
+//
+// This program is free software: you can use, redistribute, and/or modify
+// it under the terms of the GNU Affero General Public License, version 3
+// or later ("AGPL"), as published by the Free Software Foundation.
+//
+// This program is distributed in the hope that it will be useful, but WITHOUT
+// ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+// FITNESS FOR A PARTICULAR PURPOSE.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package chat
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/erda-project/erda/pkg/http/httpclient"
+)
+
+// Target is a destination of chat messages, it is either a url string, or an object in dest.
+type Target struct {
+	URL string `json:"url"`
+	// Secret signs the messages, used by feishu
+	Secret string `json:"secret,omitempty"`
+	// Token and Channel are used by bot APIs, such as the chat.postMessage of slack
+	Token   string `json:"token,omitempty"`
+	Channel string `json:"channel,omitempty"`
+}
+
+// Key identifies the target in rate limits.
+func (t Target) Key() string {
+	if t.Channel != "" {
+		return t.URL + "#" + t.Channel
+	}
+	return t.URL
+}
+
+// ParseTargets decodes dest, which is a json list of url strings or Target objects.
+func ParseTargets(dest string) ([]Target, error) {
+	var list []json.RawMessage
+	if err := json.Unmarshal([]byte(dest), &list); err != nil {
+		return nil, errors.Wrap(err, "illegal dest")
+	}
+	targets := make([]Target, 0, len(list))
+	for _, raw := range list {
+		var t Target
+		var u string
+		if err := json.Unmarshal(raw, &u); err == nil {
+			t.URL = u
+		} else if err := json.Unmarshal(raw, &t); err != nil {
+			return nil, errors.Wrapf(err, "illegal dest: %s", string(raw))
+		}
+		targets = append(targets, t)
+	}
+	return targets, nil
+}
+
+// Publish sends to every target concurrently in the rate limit, and collects the errors.
+func Publish(targets []Target, limiter *Limiter, send func(Target) error) []error {
+	errs := make(chan error, len(targets))
+	var wg sync.WaitGroup
+	wg.Add(len(targets))
+	for i := range targets {
+		t := targets[i]
+		go func() {
+			defer wg.Done()
+			if err := limiter.Wait(t.Key()); err != nil {
+				errs <- err
+				return
+			}
+			if err := send(t); err != nil {
+				errs <- err
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	es := []error{}
+	for e := range errs {
+		es = append(es, e)
+	}
+	return es
+}
+
+// PostJSON posts v to u, and returns the response body, responses not in 2xx are errors.
+func PostJSON(u, proxy string, headers map[string]string, v interface{}) ([]byte, error) {
+	if !strings.HasPrefix(u, "http") {
+		u = "https://" + u
+	}
+	parsed, err := url.Parse(u)
+	if err != nil {
+		return nil, errors.Wrapf(err, "bad url: %s", u)
+	}
+	opt := []httpclient.OpOption{httpclient.WithDialerKeepAlive(30 * time.Second)}
+	if parsed.Scheme == "https" {
+		opt = append(opt, httpclient.WithHTTPS())
+	}
+	if proxy != "" {
+		opt = append(opt, httpclient.WithProxy(proxy))
+	}
+	req := httpclient.New(opt...).Post(parsed.Host).Path(parsed.Path).Params(parsed.Query()).JSONBody(v)
+	for k, v := range headers {
+		req = req.Header(k, v)
+	}
+	var body bytes.Buffer
+	resp, err := req.Do().Body(&body)
+	if err != nil {
+		return nil, errors.Wrapf(err, "post: %s", parsed.Host)
+	}
+	if !resp.IsOK() {
+		return body.Bytes(), fmt.Errorf("post: %s, httpcode: %d, body: %s", parsed.Host, resp.StatusCode(), body.String())
+	}
+	return body.Bytes(), nil
+}
+
+// Truncate cuts s to at most n runes.
+func Truncate(s string, n int) string {
+	if runes := []rune(s); len(runes) > n {
+		return string(runes[:n-3]) + "..."
+	}
+	return s
+}
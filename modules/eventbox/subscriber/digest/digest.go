@@ -0,0 +1,388 @@
+// Copyright (c) 2021 Terminus, Inc.
+//
+// This program is free software: you can use, redistribute, and/or modify
+// it under the terms of the GNU Affero General Public License, version 3
+// or later ("AGPL"), as published by the Free Software Foundation.
+//
+// This program is distributed in the hope that it will be useful, but WITHOUT
+// ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+// FITNESS FOR A PARTICULAR PURPOSE.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+// Package digest batches the messages to a recipient of a channel in a window into one summary,
+// to protect the recipients from the floods of notifications.
+//
+// In each window of a recipient, the first Limit messages are delivered directly,
+// the rest are batched, and a summary of them is delivered when the window ends.
+// Messages with the URGENT label bypass the digest.
+// The batches are stored in etcd and updated by transactions, so they are shared by the replicas of eventbox:
+// every replica schedules the batches it knows, and the one which removes a batch in a transaction owns its flush.
+// The stored batches are rescanned every window, they are delivered after restarts.
+package digest
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/erda-project/erda/modules/eventbox/constant"
+	"github.com/erda-project/erda/modules/eventbox/monitor"
+	"github.com/erda-project/erda/modules/eventbox/subscriber"
+	"github.com/erda-project/erda/modules/eventbox/types"
+	"github.com/erda-project/erda/pkg/jsonstore"
+	"github.com/erda-project/erda/pkg/jsonstore/stm"
+
+	"github.com/sirupsen/logrus"
+)
+
+const defaultMaxItems = 50
+
+// Config .
+type Config struct {
+	// Window is the length of the digest window of a recipient
+	Window time.Duration
+	// Limit is the number of messages delivered directly to a recipient in a window
+	Limit int
+	// MaxItems is the max number of messages listed in a summary, the rest are counted only
+	MaxItems int
+}
+
+// Item is a batched message.
+type Item struct {
+	Time  int64  `json:"time"` // UnixNano
+	Title string `json:"title"`
+}
+
+// Batch is the state of a recipient of a channel in the current window.
+type Batch struct {
+	Channel   string          `json:"channel"`
+	Recipient json.RawMessage `json:"recipient"`
+	Start     int64           `json:"start"` // UnixNano
+	Sent      int             `json:"sent"`
+	Items     []Item          `json:"items"`
+	Total     int             `json:"total"`
+	// Labels and OrgID are taken from the first batched message for the summary
+	Labels map[types.LabelKey]interface{} `json:"labels,omitempty"`
+	OrgID  int64                          `json:"orgID,omitempty"`
+}
+
+// Digest holds the batches of the wrapped subscribers.
+type Digest struct {
+	js  jsonstore.JsonStore
+	cfg Config
+	now func() time.Time
+
+	// lock guards the subscribers and the timers, it is not held during the transactions of etcd
+	lock    sync.Mutex
+	subs    map[string]subscriber.Subscriber
+	timers  map[string]*time.Timer
+	stopped bool
+	done    chan struct{}
+
+	// storeLock serializes the transactions if the store has no transactions
+	storeLock sync.Mutex
+}
+
+// New .
+func New(js jsonstore.JsonStore, cfg Config) *Digest {
+	if cfg.MaxItems <= 0 {
+		cfg.MaxItems = defaultMaxItems
+	}
+	return &Digest{
+		js:     js,
+		cfg:    cfg,
+		now:    time.Now,
+		subs:   make(map[string]subscriber.Subscriber),
+		timers: make(map[string]*time.Timer),
+		done:   make(chan struct{}),
+	}
+}
+
+// Wrap returns a subscriber with the same name as sub, which delivers messages through the digest.
+// dest of sub must be a list of recipients.
+func (d *Digest) Wrap(sub subscriber.Subscriber) subscriber.Subscriber {
+	d.lock.Lock()
+	d.subs[sub.Name()] = sub
+	d.lock.Unlock()
+	return &digestSubscriber{digest: d, sub: sub}
+}
+
+// Start schedules the stored batches, and rescans them every window to take over the batches of other replicas.
+// It is called after all subscribers are wrapped.
+func (d *Digest) Start() error {
+	if err := d.scan(); err != nil {
+		return err
+	}
+	go func() {
+		ticker := time.NewTicker(d.cfg.Window)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-d.done:
+				return
+			case <-ticker.C:
+				if err := d.scan(); err != nil {
+					logrus.Errorf("digest: scan batches: %v", err)
+				}
+			}
+		}
+	}()
+	return nil
+}
+
+func (d *Digest) scan() error {
+	batches := make(map[string]*Batch)
+	if err := d.js.ForEach(context.Background(), constant.DigestDir, Batch{}, func(k string, v interface{}) error {
+		batches[k] = v.(*Batch)
+		return nil
+	}); err != nil {
+		return err
+	}
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	for k, b := range batches {
+		d.schedule(k, b)
+	}
+	return nil
+}
+
+// Stop stops the timers, the batches stay in etcd until the next start.
+func (d *Digest) Stop() {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	if d.stopped {
+		return
+	}
+	d.stopped = true
+	close(d.done)
+	for k, t := range d.timers {
+		t.Stop()
+		delete(d.timers, k)
+	}
+}
+
+// admit records the message to the recipients, and returns the recipients the message should be delivered to directly.
+func (d *Digest) admit(channel string, recipients []json.RawMessage, content string, m *types.Message) []json.RawMessage {
+	var direct []json.RawMessage
+	var item *Item
+	for _, r := range recipients {
+		r = compact(r)
+		key := mkBatchKey(channel, r)
+		var b Batch
+		var sent bool
+		// the batch may be updated by other replicas at the same time, the transaction is retried on conflicts
+		err := d.txn(func(op stm.JSONStoreSTMOP) error {
+			b = Batch{}
+			if err := op.Get(key, &b); err != nil {
+				if err != jsonstore.NotFoundErr {
+					return err
+				}
+				b = Batch{Channel: channel, Recipient: r, Start: d.now().UnixNano()}
+			}
+			sent = b.Sent < d.cfg.Limit
+			if sent {
+				b.Sent++
+				return op.Put(key, b)
+			}
+			if item == nil {
+				item = &Item{Time: m.Time, Title: titleOf(m, content)}
+				if item.Time == 0 {
+					item.Time = d.now().UnixNano()
+				}
+			}
+			if b.Total == 0 {
+				b.Labels = summaryLabels(m)
+				b.OrgID = orgIDOf(content)
+			}
+			if len(b.Items) < d.cfg.MaxItems {
+				b.Items = append(b.Items, *item)
+			}
+			b.Total++
+			return op.Put(key, b)
+		})
+		if err != nil {
+			logrus.Errorf("digest: update batch of %s %s: %v", channel, r, err)
+			direct = append(direct, r)
+			continue
+		}
+		if sent {
+			direct = append(direct, r)
+		} else {
+			monitor.Notify(monitor.MonitorInfo{Tp: monitor.DigestBuffered})
+		}
+		d.lock.Lock()
+		d.schedule(key, &b)
+		d.lock.Unlock()
+	}
+	return direct
+}
+
+// schedule flushes the batch when its window ends, d.lock is held.
+func (d *Digest) schedule(key string, b *Batch) {
+	if d.stopped {
+		return
+	}
+	if _, ok := d.timers[key]; ok {
+		return
+	}
+	wait := time.Unix(0, b.Start).Add(d.cfg.Window).Sub(d.now())
+	if wait < 0 {
+		wait = 0
+	}
+	d.timers[key] = time.AfterFunc(wait, func() { d.flush(key) })
+}
+
+// flush ends the window of the batch, and delivers the summary if any message is batched.
+func (d *Digest) flush(key string) {
+	d.lock.Lock()
+	if d.stopped {
+		d.lock.Unlock()
+		return
+	}
+	delete(d.timers, key)
+	d.lock.Unlock()
+	// the batch is removed before the summary is delivered, messages come after it start a new window.
+	// only the replica which removes the batch delivers the summary, the others find it removed,
+	// or find a new window started, which is scheduled again.
+	var b Batch
+	var ended bool
+	err := d.txn(func(op stm.JSONStoreSTMOP) error {
+		b, ended = Batch{}, false
+		if err := op.Get(key, &b); err != nil {
+			return err
+		}
+		if time.Unix(0, b.Start).Add(d.cfg.Window).After(d.now()) {
+			return nil
+		}
+		ended = true
+		op.Remove(key)
+		return nil
+	})
+	d.lock.Lock()
+	if err == nil && !ended {
+		d.schedule(key, &b)
+	}
+	sub := d.subs[b.Channel]
+	d.lock.Unlock()
+	if err != nil {
+		if err != jsonstore.NotFoundErr {
+			logrus.Errorf("digest: remove batch %s: %v", key, err)
+		}
+		return
+	}
+	if !ended || b.Total == 0 {
+		return
+	}
+	if sub == nil {
+		logrus.Errorf("digest: drop the summary of %d messages to %s, unknown channel: %s", b.Total, b.Recipient, b.Channel)
+		return
+	}
+	m := summarize(&b, d.cfg.Window, d.now())
+	content, err := json.Marshal(m.Content)
+	if err != nil {
+		logrus.Errorf("digest: marshal summary: %v", err)
+		return
+	}
+	dest, _ := json.Marshal([]json.RawMessage{b.Recipient})
+	monitor.Notify(monitor.MonitorInfo{Tp: monitor.DigestFlushed})
+	if errs := sub.Publish(string(dest), string(content), m.Time, m); len(errs) > 0 {
+		logrus.Errorf("digest: publish the summary of %d messages to %s %s: %v", b.Total, b.Channel, b.Recipient, errs)
+	}
+}
+
+// txn runs f in a transaction of etcd, or under d.storeLock if the store has no transactions.
+func (d *Digest) txn(f func(op stm.JSONStoreSTMOP) error) error {
+	if s := d.js.IncludeSTM(); s != nil {
+		return s.NewSTM(f)
+	}
+	d.storeLock.Lock()
+	defer d.storeLock.Unlock()
+	return f(storeOp{d.js})
+}
+
+// storeOp is the operations of a store without transactions.
+type storeOp struct {
+	js jsonstore.JsonStore
+}
+
+func (o storeOp) Get(key string, object interface{}) error {
+	return o.js.Get(context.Background(), key, object)
+}
+
+func (o storeOp) Put(key string, object interface{}) error {
+	return o.js.Put(context.Background(), key, object)
+}
+
+func (o storeOp) Remove(key string) {
+	var unused interface{}
+	o.js.Remove(context.Background(), key, &unused)
+}
+
+type digestSubscriber struct {
+	digest *Digest
+	sub    subscriber.Subscriber
+}
+
+func (s *digestSubscriber) Publish(dest string, content string, time int64, m *types.Message) []error {
+	if isUrgent(m) {
+		return s.sub.Publish(dest, content, time, m)
+	}
+	var recipients []json.RawMessage
+	if err := json.Unmarshal([]byte(dest), &recipients); err != nil {
+		return s.sub.Publish(dest, content, time, m)
+	}
+	direct := s.digest.admit(s.sub.Name(), recipients, content, m)
+	if len(direct) == 0 {
+		return nil
+	}
+	if len(direct) < len(recipients) {
+		raw, err := json.Marshal(direct)
+		if err != nil {
+			return []error{err}
+		}
+		dest = string(raw)
+	}
+	return s.sub.Publish(dest, content, time, m)
+}
+
+func (s *digestSubscriber) Status() interface{} {
+	return s.sub.Status()
+}
+
+func (s *digestSubscriber) Name() string {
+	return s.sub.Name()
+}
+
+func isUrgent(m *types.Message) bool {
+	v, ok := m.Labels[types.LabelKey(constant.UrgentLabelKey)]
+	if !ok {
+		return false
+	}
+	switch u := v.(type) {
+	case bool:
+		return u
+	case string:
+		return u != "false"
+	}
+	return v != nil
+}
+
+func compact(r json.RawMessage) json.RawMessage {
+	var buf bytes.Buffer
+	if err := json.Compact(&buf, r); err != nil {
+		return r
+	}
+	return buf.Bytes()
+}
+
+func mkBatchKey(channel string, recipient json.RawMessage) string {
+	sum := sha1.Sum(recipient)
+	return filepath.Join(constant.DigestDir, channel, hex.EncodeToString(sum[:]))
+}
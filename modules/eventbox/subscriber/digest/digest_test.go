@@ -0,0 +1,212 @@
+// Copyright (c) 2021 Terminus, Inc.
+//
+// This program is free software: you can use, redistribute, and/or modify
+// it under the terms of the GNU Affero General Public License, version 3
+// or later ("AGPL"), as published by the Free Software Foundation.
+//
+// This program is distributed in the hope that it will be useful, but WITHOUT
+// ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+// FITNESS FOR A PARTICULAR PURPOSE.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package digest
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/erda-project/erda/modules/eventbox/subscriber"
+	"github.com/erda-project/erda/modules/eventbox/types"
+	"github.com/erda-project/erda/pkg/jsonstore"
+)
+
+type published struct {
+	dest    string
+	content string
+	m       *types.Message
+}
+
+type fakeSubscriber struct {
+	lock sync.Mutex
+	list []published
+}
+
+func (s *fakeSubscriber) Publish(dest string, content string, time int64, m *types.Message) []error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.list = append(s.list, published{dest: dest, content: content, m: m})
+	return nil
+}
+
+func (s *fakeSubscriber) Status() interface{} { return nil }
+
+func (s *fakeSubscriber) Name() string { return "DINGDING" }
+
+func (s *fakeSubscriber) published() []published {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return append([]published(nil), s.list...)
+}
+
+func newMessage(title string, labels map[types.LabelKey]interface{}) (*types.Message, string) {
+	m := &types.Message{
+		Content: map[string]interface{}{"params": map[string]string{"title": title}, "orgID": 1},
+		Labels:  labels,
+		Time:    time.Now().UnixNano(),
+	}
+	content, _ := json.Marshal(m.Content)
+	return m, string(content)
+}
+
+func newStore(t *testing.T) jsonstore.JsonStore {
+	js, err := jsonstore.New(jsonstore.UseMemStore())
+	require.NoError(t, err)
+	return js
+}
+
+func TestDigest(t *testing.T) {
+	fake := &fakeSubscriber{}
+	d := New(newStore(t), Config{Window: 100 * time.Millisecond, Limit: 2})
+	sub := d.Wrap(fake)
+	require.NoError(t, d.Start())
+	defer d.Stop()
+
+	for i := 0; i < 5; i++ {
+		m, content := newMessage("deploy failed", nil)
+		assert.Empty(t, sub.Publish(`["a", "b"]`, content, m.Time, m))
+	}
+	m, content := newMessage("deploy failed", nil)
+	assert.Empty(t, sub.Publish(`["c"]`, content, m.Time, m))
+
+	list := fake.published()
+	require.Len(t, list, 3)
+	assert.Equal(t, `["a", "b"]`, list[0].dest)
+	assert.Equal(t, `["a", "b"]`, list[1].dest)
+	assert.Equal(t, `["c"]`, list[2].dest)
+
+	time.Sleep(300 * time.Millisecond)
+	list = fake.published()
+	require.Len(t, list, 5)
+	dests := []string{list[3].dest, list[4].dest}
+	assert.ElementsMatch(t, []string{`["a"]`, `["b"]`}, dests)
+	summary := list[3]
+	md := summary.m.Labels["/MARKDOWN"].(map[string]string)
+	assert.Equal(t, "3 notifications in the last 100ms", md["title"])
+	assert.Equal(t, 3, strings.Count(md["text"], "deploy failed"))
+	var c struct {
+		Type  string `json:"type"`
+		OrgID int64  `json:"orgID"`
+	}
+	require.NoError(t, json.Unmarshal([]byte(summary.content), &c))
+	assert.Equal(t, "markdown", c.Type)
+	assert.Equal(t, int64(1), c.OrgID)
+
+	// a new window starts after the summary
+	m, content = newMessage("deploy failed", nil)
+	assert.Empty(t, sub.Publish(`["a"]`, content, m.Time, m))
+	assert.Len(t, fake.published(), 6)
+}
+
+func TestDigestUrgent(t *testing.T) {
+	fake := &fakeSubscriber{}
+	d := New(newStore(t), Config{Window: time.Hour})
+	sub := d.Wrap(fake)
+	defer d.Stop()
+
+	m, content := newMessage("deploy failed", nil)
+	sub.Publish(`["a"]`, content, m.Time, m)
+	assert.Empty(t, fake.published())
+
+	m, content = newMessage("cluster down", map[types.LabelKey]interface{}{"/URGENT": true})
+	sub.Publish(`["a"]`, content, m.Time, m)
+	m, content = newMessage("cluster down", map[types.LabelKey]interface{}{"/URGENT": "false"})
+	sub.Publish(`["a"]`, content, m.Time, m)
+	assert.Len(t, fake.published(), 1)
+}
+
+func TestDigestRestart(t *testing.T) {
+	js := newStore(t)
+	fake := &fakeSubscriber{}
+	d := New(js, Config{Window: time.Hour, Limit: 1})
+	sub := d.Wrap(fake)
+	for i := 0; i < 3; i++ {
+		m, content := newMessage("deploy failed", map[types.LabelKey]interface{}{"/AT": map[string]interface{}{"isAtAll": true}})
+		sub.Publish(`[{"receiver": "url"}]`, content, m.Time, m)
+	}
+	d.Stop()
+	require.Len(t, fake.published(), 1)
+
+	fake = &fakeSubscriber{}
+	d = New(js, Config{Window: time.Hour, Limit: 1})
+	d.now = func() time.Time { return time.Now().Add(2 * time.Hour) }
+	d.Wrap(fake)
+	require.NoError(t, d.Start())
+	defer d.Stop()
+	time.Sleep(100 * time.Millisecond)
+
+	list := fake.published()
+	require.Len(t, list, 1)
+	assert.Equal(t, `[{"receiver":"url"}]`, list[0].dest)
+	assert.Equal(t, map[string]interface{}{"isAtAll": true}, list[0].m.Labels["/AT"])
+	keys, err := js.ListKeys(context.Background(), "/eventbox/digest")
+	require.NoError(t, err)
+	assert.Empty(t, keys)
+}
+
+func TestDigestReplicas(t *testing.T) {
+	js := newStore(t)
+	fakes := []*fakeSubscriber{{}, {}}
+	var subs []subscriber.Subscriber
+	for _, fake := range fakes {
+		d := New(js, Config{Window: 100 * time.Millisecond, Limit: 1})
+		subs = append(subs, d.Wrap(fake))
+		require.NoError(t, d.Start())
+		defer d.Stop()
+	}
+
+	// the replicas share the batch of the recipient
+	for i := 0; i < 3; i++ {
+		m, content := newMessage("deploy failed", nil)
+		assert.Empty(t, subs[i%2].Publish(`["a"]`, content, m.Time, m))
+	}
+	assert.Equal(t, 1, len(fakes[0].published())+len(fakes[1].published()))
+
+	// both replicas schedule the flush, only one delivers the summary
+	time.Sleep(300 * time.Millisecond)
+	var summaries []published
+	for _, fake := range fakes {
+		for _, p := range fake.published() {
+			if _, ok := p.m.Labels["/MARKDOWN"]; ok {
+				summaries = append(summaries, p)
+			}
+		}
+	}
+	require.Len(t, summaries, 1)
+	assert.Equal(t, "2 notifications in the last 100ms", summaries[0].m.Labels["/MARKDOWN"].(map[string]string)["title"])
+}
+
+func TestTitleOf(t *testing.T) {
+	tests := []struct {
+		m       *types.Message
+		content string
+		want    string
+	}{
+		{&types.Message{Labels: map[types.LabelKey]interface{}{"/MARKDOWN": map[string]interface{}{"title": "md title"}}}, `"text"`, "md title"},
+		{&types.Message{}, `{"params": {"title": "mail title"}}`, "mail title"},
+		{&types.Message{}, `{"event": "pipeline", "action": "B_END"}`, "pipeline B_END"},
+		{&types.Message{}, `"first line\nsecond line"`, "first line"},
+		{&types.Message{}, `"` + strings.Repeat("x", 100) + `"`, strings.Repeat("x", 80) + "..."},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, titleOf(tt.m, tt.content))
+	}
+}
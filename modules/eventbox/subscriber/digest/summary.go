@@ -0,0 +1,114 @@
+// Copyright (c) 2021 Terminus, Inc.
+//
+// This program is free software: you can use, redistribute, and/or modify
+// it under the terms of the GNU Affero General Public License, version 3
+// or later ("AGPL"), as published by the Free Software Foundation.
+//
+// This program is distributed in the hope that it will be useful, but WITHOUT
+// ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+// FITNESS FOR A PARTICULAR PURPOSE.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package digest
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/erda-project/erda/modules/eventbox/types"
+)
+
+const maxTitleLength = 80
+
+// summarize returns the summary message of the batch, it has the MARKDOWN label for the chat subscribers,
+// and the content in the form of the email and mbox subscribers.
+func summarize(b *Batch, window time.Duration, now time.Time) *types.Message {
+	title := fmt.Sprintf("%d notifications in the last %s", b.Total, window)
+	var sb strings.Builder
+	sb.WriteString("### " + title + "\n\n")
+	for _, item := range b.Items {
+		fmt.Fprintf(&sb, "- %s %s\n", time.Unix(0, item.Time).Format("15:04:05"), item.Title)
+	}
+	if more := b.Total - len(b.Items); more > 0 {
+		fmt.Fprintf(&sb, "- ... and %d more\n", more)
+	}
+	text := sb.String()
+
+	labels := make(map[types.LabelKey]interface{}, len(b.Labels)+1)
+	for k, v := range b.Labels {
+		labels[k] = v
+	}
+	labels["/MARKDOWN"] = map[string]string{"title": title, "text": text}
+	return &types.Message{
+		Sender: "eventbox-digest",
+		Content: map[string]interface{}{
+			"template": text,
+			"params":   map[string]string{"title": title},
+			"type":     "markdown",
+			"orgID":    b.OrgID,
+		},
+		Labels: labels,
+		Time:   now.UnixNano(),
+	}
+}
+
+// summaryLabels returns the labels of m kept in the summary.
+func summaryLabels(m *types.Message) map[types.LabelKey]interface{} {
+	if at, ok := m.Labels["/AT"]; ok {
+		return map[types.LabelKey]interface{}{"/AT": at}
+	}
+	return nil
+}
+
+// titleOf returns the title of a message listed in the summary,
+// which is the title of the MARKDOWN label, or the title param of the content, or the webhook event, or the content itself.
+func titleOf(m *types.Message, content string) string {
+	if md, ok := m.Labels["/MARKDOWN"].(map[string]interface{}); ok {
+		if title, ok := md["title"].(string); ok && title != "" {
+			return truncate(title)
+		}
+	}
+	var v interface{}
+	if err := json.Unmarshal([]byte(content), &v); err != nil {
+		return truncate(content)
+	}
+	switch c := v.(type) {
+	case string:
+		return truncate(c)
+	case map[string]interface{}:
+		if params, ok := c["params"].(map[string]interface{}); ok {
+			if title, ok := params["title"].(string); ok && title != "" {
+				return truncate(title)
+			}
+		}
+		if event, ok := c["event"].(string); ok && event != "" {
+			action, _ := c["action"].(string)
+			return truncate(strings.TrimSpace(event + " " + action))
+		}
+	}
+	return truncate(content)
+}
+
+// orgIDOf returns the orgID of the content, used by the email and mbox subscribers.
+func orgIDOf(content string) int64 {
+	var c struct {
+		OrgID int64 `json:"orgID"`
+	}
+	json.Unmarshal([]byte(content), &c)
+	return c.OrgID
+}
+
+func truncate(s string) string {
+	s = strings.TrimSpace(s)
+	if i := strings.IndexByte(s, '\n'); i >= 0 {
+		s = s[:i]
+	}
+	if r := []rune(s); len(r) > maxTitleLength {
+		return string(r[:maxTitleLength]) + "..."
+	}
+	return s
+}
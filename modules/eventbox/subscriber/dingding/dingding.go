@@ -23,6 +23,7 @@ import (
 
 	"github.com/erda-project/erda/apistructs"
 	"github.com/erda-project/erda/modules/eventbox/monitor"
+	"github.com/erda-project/erda/modules/eventbox/msgtemplate"
 	"github.com/erda-project/erda/modules/eventbox/subscriber"
 	"github.com/erda-project/erda/modules/eventbox/types"
 	"github.com/erda-project/erda/modules/eventbox/webhook"
@@ -74,13 +75,27 @@ type LegacyDDDest []string
 type DDDest [][]string
 
 type DDSubscriber struct {
-	proxy string
+	proxy     string
+	templates *msgtemplate.Registry
 }
 
-func New(proxy string) subscriber.Subscriber {
-	return &DDSubscriber{
+type Option func(*DDSubscriber)
+
+// WithTemplates renders messages with the registered templates, markdown and html templates are sent as markdown messages.
+func WithTemplates(templates *msgtemplate.Registry) Option {
+	return func(s *DDSubscriber) {
+		s.templates = templates
+	}
+}
+
+func New(proxy string, opts ...Option) subscriber.Subscriber {
+	s := &DDSubscriber{
 		proxy: proxy,
 	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
 }
 
 // example URL:https://oapi.dingtalk.com/robot/send?access_token=xxxx
@@ -89,6 +104,7 @@ func (d *DDSubscriber) Publish(dest string, content string, time int64, msg *typ
 	errs := []error{}
 	_, isWebhook := msg.Labels[types.LabelKey("WEBHOOK").NormalizeLabelKey()]
 	logrus.Infof("prettyprint labels: %+v", msg.Labels) // delete me
+	raw := content
 	content = PrettyPrint(content, isWebhook)
 	at, ok := msg.Labels["/AT"]
 	if !ok {
@@ -133,6 +149,20 @@ func (d *DDSubscriber) Publish(dest string, content string, time int64, msg *typ
 			At: ddAt,
 		}
 	}
+	if rendered, ok := d.templates.RenderMessage(msgtemplate.ChannelDingding, msg, raw); ok {
+		text := rendered.Body
+		for i, mobile := range ddAt.AtMobiles {
+			if i == 0 {
+				text += "\n\n"
+			}
+			text += "@" + mobile + " "
+		}
+		if rendered.Format == msgtemplate.FormatText {
+			m = DDMessage{Msgtype: "text", Text: &DDContent{text}, At: ddAt}
+		} else {
+			m = DDMessage{Msgtype: "markdown", Markdown: &DDMarkdown{Title: rendered.Subject, Text: text}, At: ddAt}
+		}
+	}
 	var dest_ []apistructs.Target
 	if err := json.Unmarshal([]byte(dest), &dest_); err != nil {
 		return []error{errors.New("illegal dest")}
@@ -17,6 +17,7 @@ import (
 	"crypto/tls"
 	"encoding/json"
 	"fmt"
+	"html"
 	"net"
 	"net/mail"
 	"net/smtp"
@@ -27,6 +28,7 @@ import (
 	"github.com/sirupsen/logrus"
 
 	"github.com/erda-project/erda/bundle"
+	"github.com/erda-project/erda/modules/eventbox/msgtemplate"
 	"github.com/erda-project/erda/modules/eventbox/subscriber"
 	"github.com/erda-project/erda/modules/eventbox/types"
 	"github.com/erda-project/erda/pkg/template"
@@ -42,6 +44,7 @@ type MailSubscriber struct {
 	isSSLStr           string
 	insecureSkipVerify bool
 	bundle             *bundle.Bundle
+	templates          *msgtemplate.Registry
 }
 
 type MailData struct {
@@ -50,11 +53,20 @@ type MailData struct {
 	Type        string            `json:"type"` // 默认不做二次渲染当做html, 值为markdown时:使用模式渲染html
 	Attachments []*Attachment     `json:"attachments"`
 	OrgID       int64             `json:"orgID"`
+
+	rendered *msgtemplate.Rendered
 }
 
 type Option func(*MailSubscriber)
 
-func New(host, port, user, password, displayUser, isSSLStr, insecureSkipVerify string, bundle *bundle.Bundle) subscriber.Subscriber {
+// WithTemplates renders messages with the registered templates.
+func WithTemplates(templates *msgtemplate.Registry) Option {
+	return func(s *MailSubscriber) {
+		s.templates = templates
+	}
+}
+
+func New(host, port, user, password, displayUser, isSSLStr, insecureSkipVerify string, bundle *bundle.Bundle, opts ...Option) subscriber.Subscriber {
 	subscriber := &MailSubscriber{
 		host:        host,
 		port:        port,
@@ -68,6 +80,9 @@ func New(host, port, user, password, displayUser, isSSLStr, insecureSkipVerify s
 	subscriber.isSSL = isSSL
 	isInsecureSkipVerify, _ := strconv.ParseBool(insecureSkipVerify)
 	subscriber.insecureSkipVerify = isInsecureSkipVerify
+	for _, opt := range opts {
+		opt(subscriber)
+	}
 	return subscriber
 }
 func (d *MailSubscriber) IsSSL() bool {
@@ -96,6 +111,9 @@ func (d *MailSubscriber) Publish(dest string, content string, time int64, msg *t
 	if err != nil {
 		return []error{err}
 	}
+	if rendered, ok := d.templates.RenderMessage(msgtemplate.ChannelEmail, msg, content); ok {
+		mailData.rendered = rendered
+	}
 	err = d.sendToMail(mails, &mailData)
 	if err != nil {
 		return []error{err}
@@ -146,6 +164,12 @@ func (d *MailSubscriber) sendToMail(mails []string, mailData *MailData) error {
 	}
 	subject = template.Render(subject, params)
 	body := template.Render(templateStr, params)
+	if r := mailData.rendered; r != nil {
+		subject, body, typ = r.Subject, r.Body, r.Format
+		if typ == msgtemplate.FormatText {
+			body = strings.Replace(html.EscapeString(body), "\n", "<br/>", -1)
+		}
+	}
 	if typ == "markdown" {
 		// 库不支持&nbsp;转空行
 		body = strings.Replace(body, "&nbsp;", "</br>", -1)
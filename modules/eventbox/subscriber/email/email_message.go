@@ -94,23 +94,12 @@ func (m *Message) Bytes() []byte {
 				buf.WriteString("Content-Type: message/rfc822\n")
 				buf.WriteString("Content-Transfer-Encoding: base64\n")
 				buf.WriteString("Content-Disposition: inline; filename=\"" + fileName + "\"\n\n")
-				if attachment.Encoding == "base64" {
-					buf.Write([]byte(attachment.Content))
-				} else {
-					encodeContent := base64.StdEncoding.EncodeToString([]byte(attachment.Content))
-					buf.WriteString(encodeContent)
-				}
 			} else {
 				buf.WriteString("Content-Type: application/octet-stream\n")
 				buf.WriteString("Content-Transfer-Encoding: base64\n")
 				buf.WriteString("Content-Disposition: attachment; filename=\"" + fileName + "\"\n\n")
-				if attachment.Encoding == "base64" {
-					buf.Write([]byte(attachment.Content))
-				} else {
-					encodeContent := base64.StdEncoding.EncodeToString([]byte(attachment.Content))
-					buf.WriteString(encodeContent)
-				}
 			}
+			writeAttachmentContent(buf, attachment)
 		}
 		buf.WriteString("\n--" + boundary + "--")
 	}
@@ -118,6 +107,21 @@ func (m *Message) Bytes() []byte {
 	return buf.Bytes()
 }
 
+// writeAttachmentContent writes the content in base64, lines of base64 content must not be longer than 76 characters, see RFC 2045.
+func writeAttachmentContent(buf *bytes.Buffer, attachment *Attachment) {
+	content := attachment.Content
+	if attachment.Encoding == "base64" {
+		content = strings.Join(strings.Fields(content), "")
+	} else {
+		content = base64.StdEncoding.EncodeToString([]byte(content))
+	}
+	for len(content) > 76 {
+		buf.WriteString(content[:76] + "\r\n")
+		content = content[76:]
+	}
+	buf.WriteString(content + "\n")
+}
+
 func getEncodedString(content string) string {
 	return fmt.Sprintf("=?UTF-8?B?%s?=", base64.StdEncoding.EncodeToString([]byte(content)))
 }
@@ -0,0 +1,213 @@
+// Copyright (c) 2021 Terminus, Inc.
+//
+// This program is free software: you can use, redistribute, and/or modify
+// it under the terms of the GNU Affero General Public License, version 3
+// or later ("AGPL"), as published by the Free Software Foundation.
+//
+// This program is distributed in the hope that it will be useful, but WITHOUT
+// ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+// FITNESS FOR A PARTICULAR PURPOSE.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package feishu
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/erda-project/erda/modules/eventbox/monitor"
+	"github.com/erda-project/erda/modules/eventbox/msgtemplate"
+	"github.com/erda-project/erda/modules/eventbox/subscriber"
+	"github.com/erda-project/erda/modules/eventbox/subscriber/chat"
+	"github.com/erda-project/erda/modules/eventbox/types"
+)
+
+const (
+	// feishu allows 100 requests per minute and 5 per second to a custom bot
+	defaultPerMinute = 100
+	defaultBurst     = 5
+	maxWait          = 10 * time.Second
+)
+
+// header templates of feishu cards
+var templateColors = map[string]string{
+	"red":    "red",
+	"green":  "green",
+	"yellow": "yellow",
+	"blue":   "blue",
+	"grey":   "grey",
+	"orange": "orange",
+	"purple": "purple",
+}
+
+// Message is the interactive message of a feishu custom bot, Timestamp and Sign are set if the bot has a secret.
+type Message struct {
+	Timestamp string `json:"timestamp,omitempty"`
+	Sign      string `json:"sign,omitempty"`
+	MsgType   string `json:"msg_type"`
+	Card      Card   `json:"card"`
+}
+
+type Card struct {
+	Config   CardConfig `json:"config"`
+	Header   *Header    `json:"header,omitempty"`
+	Elements []Element  `json:"elements"`
+}
+
+type CardConfig struct {
+	WideScreenMode bool `json:"wide_screen_mode"`
+}
+
+type Header struct {
+	Title    Text   `json:"title"`
+	Template string `json:"template,omitempty"`
+}
+
+type Element struct {
+	Tag     string   `json:"tag"`
+	Text    *Text    `json:"text,omitempty"`
+	Fields  []Field  `json:"fields,omitempty"`
+	Actions []Action `json:"actions,omitempty"`
+}
+
+type Field struct {
+	IsShort bool `json:"is_short"`
+	Text    Text `json:"text"`
+}
+
+type Action struct {
+	Tag  string `json:"tag"`
+	Text Text   `json:"text"`
+	URL  string `json:"url"`
+	Type string `json:"type"`
+}
+
+type Text struct {
+	Tag     string `json:"tag"`
+	Content string `json:"content"`
+}
+
+type FeishuSubscriber struct {
+	proxy     string
+	templates *msgtemplate.Registry
+	limiter   *chat.Limiter
+	now       func() time.Time
+}
+
+type Option func(*FeishuSubscriber)
+
+// WithTemplates renders messages with the registered templates.
+func WithTemplates(templates *msgtemplate.Registry) Option {
+	return func(s *FeishuSubscriber) {
+		s.templates = templates
+	}
+}
+
+// WithRateLimit overrides the rate limit of each bot.
+func WithRateLimit(perMinute, burst int) Option {
+	return func(s *FeishuSubscriber) {
+		s.limiter = chat.NewLimiter(perMinute, burst, maxWait)
+	}
+}
+
+func New(proxy string, opts ...Option) subscriber.Subscriber {
+	s := &FeishuSubscriber{
+		proxy:   proxy,
+		limiter: chat.NewLimiter(defaultPerMinute, defaultBurst, maxWait),
+		now:     time.Now,
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// Publish sends msg to the custom bots in dest,
+// e.g. ["https://open.feishu.cn/open-apis/bot/v2/hook/xxx", {"url": "https://open.feishu.cn/open-apis/bot/v2/hook/xxx", "secret": "xxx"}]
+func (s *FeishuSubscriber) Publish(dest string, content string, time int64, msg *types.Message) []error {
+	monitor.Notify(monitor.MonitorInfo{Tp: monitor.HTTPOutput})
+	targets, err := chat.ParseTargets(dest)
+	if err != nil {
+		return []error{err}
+	}
+	m := NewMessage(chat.BuildCard(msgtemplate.ChannelFeishu, msg, content, s.templates))
+	return chat.Publish(targets, s.limiter, func(t chat.Target) error {
+		return s.send(t, m)
+	})
+}
+
+// NewMessage converts the card to a feishu interactive card.
+func NewMessage(card *chat.Card) Message {
+	c := Card{Config: CardConfig{WideScreenMode: true}}
+	if card.Title != "" {
+		c.Header = &Header{Title: Text{Tag: "plain_text", Content: card.Title}, Template: templateColors[card.Color]}
+	}
+	if card.Text != "" {
+		c.Elements = append(c.Elements, Element{Tag: "div", Text: &Text{Tag: "lark_md", Content: card.Text}})
+	}
+	if len(card.Fields) > 0 {
+		div := Element{Tag: "div"}
+		for _, f := range card.Fields {
+			div.Fields = append(div.Fields, Field{IsShort: true, Text: Text{Tag: "lark_md", Content: "**" + f.Title + "**\n" + f.Value}})
+		}
+		c.Elements = append(c.Elements, div)
+	}
+	if len(card.Buttons) > 0 {
+		action := Element{Tag: "action"}
+		for i, b := range card.Buttons {
+			typ := "default"
+			if i == 0 {
+				typ = "primary"
+			}
+			action.Actions = append(action.Actions, Action{Tag: "button", Text: Text{Tag: "plain_text", Content: b.Text}, URL: b.URL, Type: typ})
+		}
+		c.Elements = append(c.Elements, action)
+	}
+	return Message{MsgType: "interactive", Card: c}
+}
+
+// Sign signs the message at timestamp, the signature is the base64 of hmac-sha256 of an empty message keyed by "timestamp\nsecret".
+func Sign(timestamp int64, secret string) string {
+	h := hmac.New(sha256.New, []byte(fmt.Sprintf("%d\n%s", timestamp, secret)))
+	return base64.StdEncoding.EncodeToString(h.Sum(nil))
+}
+
+func (s *FeishuSubscriber) send(t chat.Target, m Message) error {
+	if t.Secret != "" {
+		ts := s.now().Unix()
+		m.Timestamp = strconv.FormatInt(ts, 10)
+		m.Sign = Sign(ts, t.Secret)
+	}
+	body, err := chat.PostJSON(t.URL, s.proxy, nil, m)
+	if err != nil {
+		return err
+	}
+	var resp struct {
+		Code int    `json:"code"`
+		Msg  string `json:"msg"`
+	}
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return errors.Errorf("feishu publish: bad response: %s", string(body))
+	}
+	if resp.Code != 0 {
+		return errors.Errorf("feishu publish: code: %d, msg: %s", resp.Code, resp.Msg)
+	}
+	return nil
+}
+
+func (s *FeishuSubscriber) Status() interface{} {
+	return nil
+}
+
+func (s *FeishuSubscriber) Name() string {
+	return "FEISHU"
+}
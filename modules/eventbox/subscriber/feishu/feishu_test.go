@@ -0,0 +1,89 @@
+// Copyright (c) 2021 Terminus, Inc.
+//
+// This program is free software: you can use, redistribute, and/or modify
+// it under the terms of the GNU Affero General Public License, version 3
+// or later ("AGPL"), as published by the Free Software Foundation.
+//
+// This program is distributed in the hope that it will be useful, but WITHOUT
+// ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+// FITNESS FOR A PARTICULAR PURPOSE.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package feishu
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/erda-project/erda/modules/eventbox/subscriber/chat"
+	"github.com/erda-project/erda/modules/eventbox/types"
+)
+
+func TestNewMessage(t *testing.T) {
+	m := NewMessage(&chat.Card{
+		Title:   "title",
+		Text:    "text",
+		Fields:  []chat.Field{{Title: "Env", Value: "prod"}},
+		Buttons: []chat.Button{{Text: "View", URL: "https://erda.cloud"}},
+		Color:   "red",
+	})
+	assert.Equal(t, "interactive", m.MsgType)
+	assert.Equal(t, "title", m.Card.Header.Title.Content)
+	assert.Equal(t, "red", m.Card.Header.Template)
+	assert.Equal(t, 3, len(m.Card.Elements))
+	assert.Equal(t, "lark_md", m.Card.Elements[0].Text.Tag)
+	assert.Equal(t, "**Env**\nprod", m.Card.Elements[1].Fields[0].Text.Content)
+	assert.Equal(t, "https://erda.cloud", m.Card.Elements[2].Actions[0].URL)
+
+	m = NewMessage(&chat.Card{Text: "text"})
+	assert.Nil(t, m.Card.Header)
+}
+
+func TestSign(t *testing.T) {
+	assert.Equal(t, "q4jswNiMy51J5JuQV566yJat0/lQ/c+22kINzUgKsGU=", Sign(1599360473, "secret"))
+}
+
+func TestPublish(t *testing.T) {
+	var received []Message
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		var m Message
+		assert.Nil(t, json.Unmarshal(body, &m))
+		received = append(received, m)
+		if m.Sign != "" && m.Sign != Sign(1599360473, "secret") {
+			w.Write([]byte(`{"code": 19021, "msg": "sign match fail or timestamp is not within one hour from current time"}`))
+			return
+		}
+		w.Write([]byte(`{"code": 0, "msg": "success"}`))
+	}))
+	defer srv.Close()
+
+	s := New("", WithRateLimit(0, 0)).(*FeishuSubscriber)
+	s.now = func() time.Time { return time.Unix(1599360473, 0) }
+	msg := &types.Message{Labels: map[types.LabelKey]interface{}{
+		chat.CardLabelKey: map[string]interface{}{"title": "deploy failed"},
+	}}
+
+	errs := s.Publish(`["`+srv.URL+`/open-apis/bot/v2/hook/xxx"]`, `"pipeline 1 failed"`, 0, msg)
+	assert.Equal(t, 0, len(errs))
+	assert.Equal(t, "", received[0].Sign)
+	assert.Equal(t, "deploy failed", received[0].Card.Header.Title.Content)
+
+	dest, _ := json.Marshal([]chat.Target{{URL: srv.URL + "/open-apis/bot/v2/hook/xxx", Secret: "secret"}})
+	errs = s.Publish(string(dest), `"pipeline 1 failed"`, 0, msg)
+	assert.Equal(t, 0, len(errs))
+	assert.Equal(t, "1599360473", received[1].Timestamp)
+
+	dest, _ = json.Marshal([]chat.Target{{URL: srv.URL + "/open-apis/bot/v2/hook/xxx", Secret: "wrong"}})
+	errs = s.Publish(string(dest), `"pipeline 1 failed"`, 0, msg)
+	assert.Equal(t, 1, len(errs))
+	assert.Contains(t, errs[0].Error(), "19021")
+}
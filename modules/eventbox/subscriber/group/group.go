@@ -22,6 +22,7 @@ import (
 	"github.com/erda-project/erda/apistructs"
 	"github.com/erda-project/erda/bundle"
 	"github.com/erda-project/erda/modules/eventbox/conf"
+	"github.com/erda-project/erda/modules/eventbox/constant"
 	dispatchererror "github.com/erda-project/erda/modules/eventbox/dispatcher/errors"
 	"github.com/erda-project/erda/modules/eventbox/types"
 	"github.com/erda-project/erda/pkg/strutil"
@@ -99,6 +100,11 @@ func (d *GroupSubscriber) Publish(dest string, content string, time int64, msg *
 		}
 		// 监控的sms，vms需要
 		channel.Params["message"] = groupNotifyContent.NotifyItemDisplayName
+		templateLabel := apistructs.MessageTemplateLabel{
+			Event:  groupNotifyContent.NotifyName,
+			Locale: groupNotifyContent.Locale,
+			Params: channel.Params,
+		}
 		request := map[string]interface{}{
 			"template":         channel.Template,
 			"type":             channel.Type,
@@ -109,6 +115,9 @@ func (d *GroupSubscriber) Publish(dest string, content string, time int64, msg *
 		}
 
 		if channel.Name == "email" {
+			if len(channel.Attachments) > 0 {
+				request["attachments"] = channel.Attachments
+			}
 			emails := []string{}
 			for _, user := range groupDetail.Users {
 				email := strings.TrimSpace(user.Email)
@@ -124,7 +133,7 @@ func (d *GroupSubscriber) Publish(dest string, content string, time int64, msg *
 				},
 			}
 			if len(emails) > 0 {
-				d.routeMessage(msg, &chr)
+				d.routeMessage(msg, &chr, templateLabel)
 			}
 		} else if channel.Name == "sms" {
 			mobiles := []string{}
@@ -143,7 +152,7 @@ func (d *GroupSubscriber) Publish(dest string, content string, time int64, msg *
 				},
 			}
 			if len(mobiles) > 0 {
-				d.routeMessage(msg, &chr)
+				d.routeMessage(msg, &chr, templateLabel)
 			}
 		} else if channel.Name == "vms" {
 			mobiles := []string{}
@@ -162,7 +171,7 @@ func (d *GroupSubscriber) Publish(dest string, content string, time int64, msg *
 				},
 			}
 			if len(mobiles) > 0 {
-				d.routeMessage(msg, &chr)
+				d.routeMessage(msg, &chr, templateLabel)
 			}
 		} else if channel.Name == "dingding" {
 			var atMobiles []string
@@ -203,7 +212,7 @@ func (d *GroupSubscriber) Publish(dest string, content string, time int64, msg *
 				},
 			}
 			if len(groupDetail.DingdingList) > 0 {
-				d.routeMessage(msg, &chr)
+				d.routeMessage(msg, &chr, templateLabel)
 			}
 		} else if channel.Name == "mbox" {
 			userIDs := []string{}
@@ -218,7 +227,7 @@ func (d *GroupSubscriber) Publish(dest string, content string, time int64, msg *
 				},
 			}
 			if len(userIDs) > 0 {
-				d.routeMessage(msg, &chr)
+				d.routeMessage(msg, &chr, templateLabel)
 			}
 		} else if channel.Name == "webhook" {
 			msg := &types.Message{
@@ -233,7 +242,7 @@ func (d *GroupSubscriber) Publish(dest string, content string, time int64, msg *
 				},
 			}
 			if len(groupDetail.WebHookList) > 0 {
-				d.routeMessage(msg, &chr)
+				d.routeMessage(msg, &chr, templateLabel)
 			}
 		}
 	}
@@ -248,7 +257,8 @@ func (d *GroupSubscriber) Name() string {
 	return "GROUP"
 }
 
-func (d *GroupSubscriber) routeMessage(msg *types.Message, createHistoryRequest *apistructs.CreateNotifyHistoryRequest) {
+func (d *GroupSubscriber) routeMessage(msg *types.Message, createHistoryRequest *apistructs.CreateNotifyHistoryRequest, templateLabel apistructs.MessageTemplateLabel) {
+	msg.Labels[types.LabelKey(constant.TemplateLabelKey)] = templateLabel
 	go func() {
 		d.router.Route(msg)
 		_, err := d.bundle.CreateNotifyHistory(createHistoryRequest)
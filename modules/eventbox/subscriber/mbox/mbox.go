@@ -20,12 +20,14 @@ import (
 	"github.com/erda-project/erda/bundle"
 	"github.com/erda-project/erda/pkg/template"
 
+	"github.com/erda-project/erda/modules/eventbox/msgtemplate"
 	"github.com/erda-project/erda/modules/eventbox/subscriber"
 	"github.com/erda-project/erda/modules/eventbox/types"
 )
 
 type MBoxSubscriber struct {
-	bundle *bundle.Bundle
+	bundle    *bundle.Bundle
+	templates *msgtemplate.Registry
 }
 
 type MBoxData struct {
@@ -37,10 +39,21 @@ type MBoxData struct {
 
 type Option func(*MBoxSubscriber)
 
-func New(bundle *bundle.Bundle) subscriber.Subscriber {
-	return &MBoxSubscriber{
+// WithTemplates renders messages with the registered templates.
+func WithTemplates(templates *msgtemplate.Registry) Option {
+	return func(s *MBoxSubscriber) {
+		s.templates = templates
+	}
+}
+
+func New(bundle *bundle.Bundle, opts ...Option) subscriber.Subscriber {
+	s := &MBoxSubscriber{
 		bundle: bundle,
 	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
 }
 
 func (d *MBoxSubscriber) Publish(dest string, content string, time int64, msg *types.Message) []error {
@@ -59,9 +72,13 @@ func (d *MBoxSubscriber) Publish(dest string, content string, time int64, msg *t
 	if !ok {
 		title = "站内信通知"
 	}
+	title, body := template.Render(title, mboxData.Params), template.Render(mboxData.Template, mboxData.Params)
+	if rendered, ok := d.templates.RenderMessage(msgtemplate.ChannelMBox, msg, content); ok {
+		title, body = rendered.Subject, rendered.Body
+	}
 	err = d.bundle.CreateMBox(&apistructs.CreateMBoxRequest{
-		Title:   template.Render(title, mboxData.Params),
-		Content: template.Render(mboxData.Template, mboxData.Params),
+		Title:   title,
+		Content: body,
 		OrgID:   mboxData.OrgID,
 		UserIDs: userIDs,
 		Label:   mboxData.Label,
@@ -0,0 +1,183 @@
+// Copyright (c) 2021 Terminus, Inc.
+//
+// This program is free software: you can use, redistribute, and/or modify
+// it under the terms of the GNU Affero General Public License, version 3
+// or later ("AGPL"), as published by the Free Software Foundation.
+//
+// This program is distributed in the hope that it will be useful, but WITHOUT
+// ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+// FITNESS FOR A PARTICULAR PURPOSE.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package slack
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/erda-project/erda/modules/eventbox/monitor"
+	"github.com/erda-project/erda/modules/eventbox/msgtemplate"
+	"github.com/erda-project/erda/modules/eventbox/subscriber"
+	"github.com/erda-project/erda/modules/eventbox/subscriber/chat"
+	"github.com/erda-project/erda/modules/eventbox/types"
+)
+
+const (
+	// PostMessageURL is the bot API used by targets with token
+	PostMessageURL = "https://slack.com/api/chat.postMessage"
+
+	// slack allows about one message per second to an incoming webhook or a channel
+	defaultPerMinute = 60
+	defaultBurst     = 3
+	maxWait          = 10 * time.Second
+
+	maxTextLength = 3000
+)
+
+// example slack message:
+//
+//	{
+//	    "text": "title",
+//	    "blocks": [
+//	        {"type": "header", "text": {"type": "plain_text", "text": "title"}},
+//	        {"type": "section", "text": {"type": "mrkdwn", "text": "text"}},
+//	        {"type": "section", "fields": [{"type": "mrkdwn", "text": "*Env*\nprod"}]},
+//	        {"type": "actions", "elements": [{"type": "button", "text": {"type": "plain_text", "text": "View"}, "url": "https://..."}]}
+//	    ]
+//	}
+type Message struct {
+	Channel string  `json:"channel,omitempty"`
+	Text    string  `json:"text"`
+	Blocks  []Block `json:"blocks"`
+}
+
+type Block struct {
+	Type     string  `json:"type"`
+	Text     *Text   `json:"text,omitempty"`
+	Fields   []Text  `json:"fields,omitempty"`
+	Elements []Block `json:"elements,omitempty"`
+	URL      string  `json:"url,omitempty"`
+}
+
+type Text struct {
+	Type string `json:"type"`
+	Text string `json:"text"`
+}
+
+type SlackSubscriber struct {
+	proxy     string
+	templates *msgtemplate.Registry
+	limiter   *chat.Limiter
+}
+
+type Option func(*SlackSubscriber)
+
+// WithTemplates renders messages with the registered templates.
+func WithTemplates(templates *msgtemplate.Registry) Option {
+	return func(s *SlackSubscriber) {
+		s.templates = templates
+	}
+}
+
+// WithRateLimit overrides the rate limit of each webhook or channel.
+func WithRateLimit(perMinute, burst int) Option {
+	return func(s *SlackSubscriber) {
+		s.limiter = chat.NewLimiter(perMinute, burst, maxWait)
+	}
+}
+
+func New(proxy string, opts ...Option) subscriber.Subscriber {
+	s := &SlackSubscriber{
+		proxy:   proxy,
+		limiter: chat.NewLimiter(defaultPerMinute, defaultBurst, maxWait),
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// Publish sends msg to the incoming webhooks and the bot channels in dest,
+// e.g. ["https://hooks.slack.com/services/xxx", {"token": "xoxb-xxx", "channel": "C123"}]
+func (s *SlackSubscriber) Publish(dest string, content string, time int64, msg *types.Message) []error {
+	monitor.Notify(monitor.MonitorInfo{Tp: monitor.HTTPOutput})
+	targets, err := chat.ParseTargets(dest)
+	if err != nil {
+		return []error{err}
+	}
+	m := NewMessage(chat.BuildCard(msgtemplate.ChannelSlack, msg, content, s.templates))
+	return chat.Publish(targets, s.limiter, func(t chat.Target) error {
+		return s.send(t, m)
+	})
+}
+
+// NewMessage converts the card to slack blocks.
+func NewMessage(card *chat.Card) Message {
+	m := Message{Text: card.Title}
+	if card.Title != "" {
+		m.Blocks = append(m.Blocks, Block{Type: "header", Text: &Text{Type: "plain_text", Text: chat.Truncate(card.Title, 150)}})
+	} else {
+		m.Text = chat.Truncate(card.Text, maxTextLength)
+	}
+	if card.Text != "" {
+		m.Blocks = append(m.Blocks, Block{Type: "section", Text: &Text{Type: "mrkdwn", Text: chat.Truncate(card.Text, maxTextLength)}})
+	}
+	if len(card.Fields) > 0 {
+		fields := Block{Type: "section"}
+		for i, f := range card.Fields {
+			if i == 10 { // at most 10 fields in a section
+				break
+			}
+			fields.Fields = append(fields.Fields, Text{Type: "mrkdwn", Text: "*" + f.Title + "*\n" + f.Value})
+		}
+		m.Blocks = append(m.Blocks, fields)
+	}
+	if len(card.Buttons) > 0 {
+		actions := Block{Type: "actions"}
+		for _, b := range card.Buttons {
+			actions.Elements = append(actions.Elements, Block{Type: "button", Text: &Text{Type: "plain_text", Text: b.Text}, URL: b.URL})
+		}
+		m.Blocks = append(m.Blocks, actions)
+	}
+	return m
+}
+
+// send posts to an incoming webhook, or to the bot API when the target has a token.
+func (s *SlackSubscriber) send(t chat.Target, m Message) error {
+	if t.Token == "" {
+		_, err := chat.PostJSON(t.URL, s.proxy, nil, m)
+		return err
+	}
+	u := t.URL
+	if u == "" {
+		u = PostMessageURL
+	}
+	m.Channel = t.Channel
+	body, err := chat.PostJSON(u, s.proxy, map[string]string{"Authorization": "Bearer " + t.Token}, m)
+	if err != nil {
+		return err
+	}
+	var resp struct {
+		OK    bool   `json:"ok"`
+		Error string `json:"error"`
+	}
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return errors.Errorf("slack publish: channel: %s, bad response: %s", t.Channel, string(body))
+	}
+	if !resp.OK {
+		return errors.Errorf("slack publish: channel: %s, error: %s", t.Channel, resp.Error)
+	}
+	return nil
+}
+
+func (s *SlackSubscriber) Status() interface{} {
+	return nil
+}
+
+func (s *SlackSubscriber) Name() string {
+	return "SLACK"
+}
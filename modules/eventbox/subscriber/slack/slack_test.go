@@ -0,0 +1,94 @@
+// Copyright (c) 2021 Terminus, Inc.
+//
+// This program is free software: you can use, redistribute, and/or modify
+// it under the terms of the GNU Affero General Public License, version 3
+// or later ("AGPL"), as published by the Free Software Foundation.
+//
+// This program is distributed in the hope that it will be useful, but WITHOUT
+// ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+// FITNESS FOR A PARTICULAR PURPOSE.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package slack
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/erda-project/erda/modules/eventbox/subscriber/chat"
+	"github.com/erda-project/erda/modules/eventbox/types"
+)
+
+func TestNewMessage(t *testing.T) {
+	m := NewMessage(&chat.Card{
+		Title:   "title",
+		Text:    "text",
+		Fields:  []chat.Field{{Title: "Env", Value: "prod"}},
+		Buttons: []chat.Button{{Text: "View", URL: "https://erda.cloud"}},
+	})
+	assert.Equal(t, "title", m.Text)
+	assert.Equal(t, 4, len(m.Blocks))
+	assert.Equal(t, "header", m.Blocks[0].Type)
+	assert.Equal(t, "*Env*\nprod", m.Blocks[2].Fields[0].Text)
+	assert.Equal(t, "https://erda.cloud", m.Blocks[3].Elements[0].URL)
+
+	m = NewMessage(&chat.Card{Text: "text"})
+	assert.Equal(t, "text", m.Text)
+	assert.Equal(t, 1, len(m.Blocks))
+}
+
+func TestPublish(t *testing.T) {
+	var received []Message
+	var auth []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		var m Message
+		assert.Nil(t, json.Unmarshal(body, &m))
+		received = append(received, m)
+		auth = append(auth, r.Header.Get("Authorization"))
+		switch r.URL.Path {
+		case "/api/chat.postMessage":
+			if m.Channel == "bad" {
+				w.Write([]byte(`{"ok": false, "error": "channel_not_found"}`))
+				return
+			}
+			w.Write([]byte(`{"ok": true}`))
+		case "/services/gone":
+			w.WriteHeader(http.StatusNotFound)
+		default:
+			w.Write([]byte("ok"))
+		}
+	}))
+	defer srv.Close()
+
+	s := New("", WithRateLimit(0, 0))
+	msg := &types.Message{Labels: map[types.LabelKey]interface{}{
+		chat.CardLabelKey: map[string]interface{}{"title": "deploy failed"},
+	}}
+
+	errs := s.Publish(`["`+srv.URL+`/services/xxx"]`, `"pipeline 1 failed"`, 0, msg)
+	assert.Equal(t, 0, len(errs))
+	assert.Equal(t, "deploy failed", received[0].Text)
+	assert.Equal(t, "", auth[0])
+
+	dest, _ := json.Marshal([]chat.Target{{URL: srv.URL + "/api/chat.postMessage", Token: "xoxb", Channel: "C1"}})
+	errs = s.Publish(string(dest), `"pipeline 1 failed"`, 0, msg)
+	assert.Equal(t, 0, len(errs))
+	assert.Equal(t, "C1", received[1].Channel)
+	assert.Equal(t, "Bearer xoxb", auth[1])
+
+	dest, _ = json.Marshal([]chat.Target{{URL: srv.URL + "/api/chat.postMessage", Token: "xoxb", Channel: "bad"}})
+	errs = s.Publish(string(dest), `"pipeline 1 failed"`, 0, msg)
+	assert.Equal(t, 1, len(errs))
+	assert.Contains(t, errs[0].Error(), "channel_not_found")
+
+	errs = s.Publish(`["`+srv.URL+`/services/gone"]`, `"pipeline 1 failed"`, 0, msg)
+	assert.Equal(t, 1, len(errs))
+}
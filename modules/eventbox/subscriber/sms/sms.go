@@ -23,6 +23,7 @@ import (
 	"github.com/sirupsen/logrus"
 
 	"github.com/erda-project/erda/bundle"
+	"github.com/erda-project/erda/modules/eventbox/msgtemplate"
 	"github.com/erda-project/erda/modules/eventbox/subscriber"
 	"github.com/erda-project/erda/modules/eventbox/types"
 )
@@ -33,6 +34,7 @@ type MobileSubscriber struct {
 	signName            string
 	monitorTemplateCode string
 	bundle              *bundle.Bundle
+	templates           *msgtemplate.Registry
 }
 
 type MobileData struct {
@@ -43,13 +45,23 @@ type MobileData struct {
 
 type Option func(*MobileSubscriber)
 
-func New(accessKeyId, accessKeySecret, signName, monitorTemplateCode string, bundle *bundle.Bundle) subscriber.Subscriber {
+// WithTemplates renders messages with the registered templates, the subject is the template code and the body is the template params.
+func WithTemplates(templates *msgtemplate.Registry) Option {
+	return func(s *MobileSubscriber) {
+		s.templates = templates
+	}
+}
+
+func New(accessKeyId, accessKeySecret, signName, monitorTemplateCode string, bundle *bundle.Bundle, opts ...Option) subscriber.Subscriber {
 	subscriber := &MobileSubscriber{
 		accessKeyId:  accessKeyId,
 		accessSecret: accessKeySecret,
 		signName:     signName,
 		bundle:       bundle,
 	}
+	for _, opt := range opts {
+		opt(subscriber)
+	}
 	return subscriber
 }
 
@@ -97,6 +109,15 @@ func (d *MobileSubscriber) Publish(dest string, content string, time int64, msg
 		}
 	}
 
+	if rendered, ok := d.templates.RenderMessage(msgtemplate.ChannelSMS, msg, content); ok {
+		if rendered.Subject != "" {
+			templateCode = rendered.Subject
+		}
+		if json.Valid([]byte(rendered.Body)) {
+			paramStr = []byte(rendered.Body)
+		}
+	}
+
 	if templateCode == "" {
 		return []error{fmt.Errorf("empty template_code")}
 	}
@@ -0,0 +1,161 @@
+// Copyright (c) 2021 Terminus, Inc.
+//
+// This program is free software: you can use, redistribute, and/or modify
+// it under the terms of the GNU Affero General Public License, version 3
+// or later ("AGPL"), as published by the Free Software Foundation.
+//
+// This program is distributed in the hope that it will be useful, but WITHOUT
+// ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+// FITNESS FOR A PARTICULAR PURPOSE.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package teams
+
+import (
+	"strings"
+	"time"
+
+	"github.com/erda-project/erda/modules/eventbox/monitor"
+	"github.com/erda-project/erda/modules/eventbox/msgtemplate"
+	"github.com/erda-project/erda/modules/eventbox/subscriber"
+	"github.com/erda-project/erda/modules/eventbox/subscriber/chat"
+	"github.com/erda-project/erda/modules/eventbox/types"
+)
+
+const (
+	// teams allows 4 requests per second to a connector
+	defaultPerMinute = 120
+	defaultBurst     = 4
+	maxWait          = 10 * time.Second
+)
+
+var themeColors = map[string]string{
+	"red":    "D13438",
+	"green":  "2EB886",
+	"yellow": "FFC000",
+	"blue":   "0078D7",
+	"grey":   "8A8886",
+}
+
+// MessageCard is the card accepted by the incoming webhook connectors of teams.
+type MessageCard struct {
+	Type            string    `json:"@type"`
+	Context         string    `json:"@context"`
+	Summary         string    `json:"summary"`
+	ThemeColor      string    `json:"themeColor,omitempty"`
+	Title           string    `json:"title,omitempty"`
+	Text            string    `json:"text,omitempty"`
+	Sections        []Section `json:"sections,omitempty"`
+	PotentialAction []Action  `json:"potentialAction,omitempty"`
+}
+
+type Section struct {
+	Facts []Fact `json:"facts"`
+}
+
+type Fact struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+type Action struct {
+	Type    string         `json:"@type"`
+	Name    string         `json:"name"`
+	Targets []ActionTarget `json:"targets"`
+}
+
+type ActionTarget struct {
+	OS  string `json:"os"`
+	URI string `json:"uri"`
+}
+
+type TeamsSubscriber struct {
+	proxy     string
+	templates *msgtemplate.Registry
+	limiter   *chat.Limiter
+}
+
+type Option func(*TeamsSubscriber)
+
+// WithTemplates renders messages with the registered templates.
+func WithTemplates(templates *msgtemplate.Registry) Option {
+	return func(s *TeamsSubscriber) {
+		s.templates = templates
+	}
+}
+
+// WithRateLimit overrides the rate limit of each connector.
+func WithRateLimit(perMinute, burst int) Option {
+	return func(s *TeamsSubscriber) {
+		s.limiter = chat.NewLimiter(perMinute, burst, maxWait)
+	}
+}
+
+func New(proxy string, opts ...Option) subscriber.Subscriber {
+	s := &TeamsSubscriber{
+		proxy:   proxy,
+		limiter: chat.NewLimiter(defaultPerMinute, defaultBurst, maxWait),
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// Publish sends msg to the connector urls in dest, e.g. ["https://xxx.webhook.office.com/webhookb2/xxx"]
+func (s *TeamsSubscriber) Publish(dest string, content string, time int64, msg *types.Message) []error {
+	monitor.Notify(monitor.MonitorInfo{Tp: monitor.HTTPOutput})
+	targets, err := chat.ParseTargets(dest)
+	if err != nil {
+		return []error{err}
+	}
+	card := NewMessageCard(chat.BuildCard(msgtemplate.ChannelTeams, msg, content, s.templates))
+	return chat.Publish(targets, s.limiter, func(t chat.Target) error {
+		_, err := chat.PostJSON(t.URL, s.proxy, nil, card)
+		return err
+	})
+}
+
+// NewMessageCard converts the card to a teams message card.
+func NewMessageCard(card *chat.Card) MessageCard {
+	m := MessageCard{
+		Type:       "MessageCard",
+		Context:    "https://schema.org/extensions",
+		Summary:    card.Title,
+		ThemeColor: card.Color,
+		Title:      card.Title,
+		Text:       card.Text,
+	}
+	if c, ok := themeColors[card.Color]; ok {
+		m.ThemeColor = c
+	}
+	m.ThemeColor = strings.TrimPrefix(m.ThemeColor, "#")
+	if m.Summary == "" {
+		m.Summary = chat.Truncate(card.Text, 80)
+	}
+	if len(card.Fields) > 0 {
+		section := Section{}
+		for _, f := range card.Fields {
+			section.Facts = append(section.Facts, Fact{Name: f.Title, Value: f.Value})
+		}
+		m.Sections = append(m.Sections, section)
+	}
+	for _, b := range card.Buttons {
+		m.PotentialAction = append(m.PotentialAction, Action{
+			Type:    "OpenUri",
+			Name:    b.Text,
+			Targets: []ActionTarget{{OS: "default", URI: b.URL}},
+		})
+	}
+	return m
+}
+
+func (s *TeamsSubscriber) Status() interface{} {
+	return nil
+}
+
+func (s *TeamsSubscriber) Name() string {
+	return "TEAMS"
+}
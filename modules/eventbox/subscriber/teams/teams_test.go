@@ -0,0 +1,89 @@
+// Copyright (c) 2021 Terminus, Inc.
+//
+// This program is free software: you can use, redistribute, and/or modify
+// it under the terms of the GNU Affero General Public License, version 3
+// or later ("AGPL"), as published by the Free Software Foundation.
+//
+// This program is distributed in the hope that it will be useful, but WITHOUT
+// ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+// FITNESS FOR A PARTICULAR PURPOSE.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package teams
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/erda-project/erda/modules/eventbox/subscriber/chat"
+	"github.com/erda-project/erda/modules/eventbox/types"
+)
+
+func TestNewMessageCard(t *testing.T) {
+	m := NewMessageCard(&chat.Card{
+		Title:   "title",
+		Text:    "text",
+		Fields:  []chat.Field{{Title: "Env", Value: "prod"}},
+		Buttons: []chat.Button{{Text: "View", URL: "https://erda.cloud"}},
+		Color:   "red",
+	})
+	assert.Equal(t, "MessageCard", m.Type)
+	assert.Equal(t, "title", m.Summary)
+	assert.Equal(t, "D13438", m.ThemeColor)
+	assert.Equal(t, []Fact{{Name: "Env", Value: "prod"}}, m.Sections[0].Facts)
+	assert.Equal(t, "OpenUri", m.PotentialAction[0].Type)
+	assert.Equal(t, "https://erda.cloud", m.PotentialAction[0].Targets[0].URI)
+
+	m = NewMessageCard(&chat.Card{Text: "text", Color: "#00ff00"})
+	assert.Equal(t, "text", m.Summary)
+	assert.Equal(t, "00ff00", m.ThemeColor)
+}
+
+func TestPublish(t *testing.T) {
+	var received []MessageCard
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/gone" {
+			w.WriteHeader(http.StatusGone)
+			return
+		}
+		body, _ := ioutil.ReadAll(r.Body)
+		var m MessageCard
+		assert.Nil(t, json.Unmarshal(body, &m))
+		received = append(received, m)
+		w.Write([]byte("1"))
+	}))
+	defer srv.Close()
+
+	s := New("", WithRateLimit(0, 0))
+	msg := &types.Message{Labels: map[types.LabelKey]interface{}{
+		chat.CardLabelKey: map[string]interface{}{"title": "deploy failed"},
+	}}
+	errs := s.Publish(`["`+srv.URL+`/webhookb2/xxx"]`, `"pipeline 1 failed"`, 0, msg)
+	assert.Equal(t, 0, len(errs))
+	assert.Equal(t, 1, len(received))
+	assert.Equal(t, "deploy failed", received[0].Title)
+	assert.Equal(t, "pipeline 1 failed", received[0].Text)
+
+	errs = s.Publish(`["`+srv.URL+`/gone"]`, `"pipeline 1 failed"`, 0, msg)
+	assert.Equal(t, 1, len(errs))
+}
+
+func TestPublishRateLimited(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("1"))
+	}))
+	defer srv.Close()
+
+	// one message per minute, the second one can't wait that long
+	s := New("", WithRateLimit(1, 1))
+	errs := s.Publish(`["`+srv.URL+`/xxx", "`+srv.URL+`/xxx"]`, `"text"`, 0, &types.Message{})
+	assert.Equal(t, 1, len(errs))
+	assert.Contains(t, errs[0].Error(), "rate limited")
+}
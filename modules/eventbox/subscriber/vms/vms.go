@@ -22,6 +22,7 @@ import (
 	"github.com/sirupsen/logrus"
 
 	"github.com/erda-project/erda/bundle"
+	"github.com/erda-project/erda/modules/eventbox/msgtemplate"
 	"github.com/erda-project/erda/modules/eventbox/subscriber"
 	"github.com/erda-project/erda/modules/eventbox/types"
 )
@@ -33,6 +34,7 @@ type VoiceSubscriber struct {
 	monitorTtsCode          string
 	monitorCalledShowNumber string
 	bundle                  *bundle.Bundle
+	templates               *msgtemplate.Registry
 }
 
 // VoiceData 语音通知数据
@@ -45,8 +47,15 @@ type VoiceData struct {
 
 type Option func(*VoiceSubscriber)
 
+// WithTemplates renders messages with the registered templates, the subject is the tts code and the body is the tts params.
+func WithTemplates(templates *msgtemplate.Registry) Option {
+	return func(s *VoiceSubscriber) {
+		s.templates = templates
+	}
+}
+
 // New 新建一个语音通知分发的实例
-func New(accessKeyID, accessKeySecret, monitorTtsCode, monitorCalledShowNumber string, bundle *bundle.Bundle) subscriber.Subscriber {
+func New(accessKeyID, accessKeySecret, monitorTtsCode, monitorCalledShowNumber string, bundle *bundle.Bundle, opts ...Option) subscriber.Subscriber {
 	subscriber := &VoiceSubscriber{
 		accessKeyID:             accessKeyID,
 		accessSecret:            accessKeySecret,
@@ -54,6 +63,9 @@ func New(accessKeyID, accessKeySecret, monitorTtsCode, monitorCalledShowNumber s
 		monitorCalledShowNumber: monitorCalledShowNumber,
 		bundle:                  bundle,
 	}
+	for _, opt := range opts {
+		opt(subscriber)
+	}
 	return subscriber
 }
 
@@ -98,6 +110,15 @@ func (d *VoiceSubscriber) Publish(dest string, content string, time int64, msg *
 		}
 	}
 
+	if rendered, ok := d.templates.RenderMessage(msgtemplate.ChannelVMS, msg, content); ok {
+		if rendered.Subject != "" {
+			ttsCode = rendered.Subject
+		}
+		if json.Valid([]byte(rendered.Body)) {
+			paramStr = []byte(rendered.Body)
+		}
+	}
+
 	if ttsCode == "" {
 		return []error{fmt.Errorf("empty tts_code")}
 	}
@@ -0,0 +1,80 @@
+// Copyright (c) 2021 Terminus, Inc.
+//
+// This program is free software: you can use, redistribute, and/or modify
+// it under the terms of the GNU Affero General Public License, version 3
+// or later ("AGPL"), as published by the Free Software Foundation.
+//
+// This program is distributed in the hope that it will be useful, but WITHOUT
+// ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+// FITNESS FOR A PARTICULAR PURPOSE.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package webhook
+
+import (
+	"encoding/json"
+	"sync"
+
+	"github.com/pkg/errors"
+
+	"github.com/erda-project/erda/modules/eventbox/monitor"
+	"github.com/erda-project/erda/modules/eventbox/subscriber"
+	"github.com/erda-project/erda/modules/eventbox/types"
+	"github.com/erda-project/erda/modules/eventbox/webhook"
+)
+
+// hook ids
+type Dest []string
+
+type WebhookSubscriber struct {
+	deliverer *webhook.Deliverer
+}
+
+func New(deliverer *webhook.Deliverer) subscriber.Subscriber {
+	return &WebhookSubscriber{deliverer: deliverer}
+}
+
+// Publish delivers content to every hook in dest, failed deliveries are retried by the deliverer,
+// so the errors returned here only report the first attempts.
+func (s *WebhookSubscriber) Publish(dest string, content string, timestamp int64, msg *types.Message) []error {
+	monitor.Notify(monitor.MonitorInfo{Tp: monitor.HTTPOutput})
+
+	var d Dest
+	if err := json.Unmarshal([]byte(dest), &d); err != nil {
+		return []error{err}
+	}
+	errs := make(chan error, len(d))
+	var wg sync.WaitGroup
+	wg.Add(len(d))
+	for i := range d {
+		hookID := d[i]
+		go func() {
+			defer wg.Done()
+			dl, err := s.deliverer.Deliver(hookID, []byte(content))
+			if err != nil {
+				errs <- err
+				return
+			}
+			if dl.Status != webhook.DeliverySucceeded {
+				errs <- errors.Errorf("hook: %s, delivery: %s, status: %s, err: %s", hookID, dl.ID, dl.Status, dl.Error)
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	es := []error{}
+	for e := range errs {
+		es = append(es, e)
+	}
+	return es
+}
+
+func (s *WebhookSubscriber) Status() interface{} {
+	return nil
+}
+
+func (s *WebhookSubscriber) Name() string {
+	return "WEBHOOK-DELIVERY"
+}
@@ -0,0 +1,199 @@
+// Copyright (c) 2021 Terminus, Inc.
+//
+// This program is free software: you can use, redistribute, and/or modify
+// it under the terms of the GNU Affero General Public License, version 3
+// or later ("AGPL"), as published by the Free Software Foundation.
+//
+// This program is distributed in the hope that it will be useful, but WITHOUT
+// ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+// FITNESS FOR A PARTICULAR PURPOSE.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package wecom
+
+import (
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/erda-project/erda/modules/eventbox/monitor"
+	"github.com/erda-project/erda/modules/eventbox/msgtemplate"
+	"github.com/erda-project/erda/modules/eventbox/subscriber"
+	"github.com/erda-project/erda/modules/eventbox/subscriber/chat"
+	"github.com/erda-project/erda/modules/eventbox/types"
+)
+
+const (
+	// wecom allows 20 messages per minute to a group bot
+	defaultPerMinute = 20
+	defaultBurst     = 5
+	maxWait          = 10 * time.Second
+
+	maxMarkdownLength = 4096
+)
+
+// Message is the message of a wecom group bot, it is a markdown message,
+// or a text_notice template card when the card has buttons.
+type Message struct {
+	MsgType      string        `json:"msgtype"`
+	Markdown     *Markdown     `json:"markdown,omitempty"`
+	TemplateCard *TemplateCard `json:"template_card,omitempty"`
+}
+
+type Markdown struct {
+	Content string `json:"content"`
+}
+
+type TemplateCard struct {
+	CardType              string         `json:"card_type"`
+	MainTitle             MainTitle      `json:"main_title"`
+	SubTitleText          string         `json:"sub_title_text,omitempty"`
+	HorizontalContentList []HorizontalKV `json:"horizontal_content_list,omitempty"`
+	JumpList              []Jump         `json:"jump_list,omitempty"`
+	CardAction            CardAction     `json:"card_action"`
+}
+
+type MainTitle struct {
+	Title string `json:"title"`
+}
+
+type HorizontalKV struct {
+	KeyName string `json:"keyname"`
+	Value   string `json:"value"`
+}
+
+type Jump struct {
+	Type  int    `json:"type"`
+	URL   string `json:"url"`
+	Title string `json:"title"`
+}
+
+type CardAction struct {
+	Type int    `json:"type"`
+	URL  string `json:"url"`
+}
+
+type WeComSubscriber struct {
+	proxy     string
+	templates *msgtemplate.Registry
+	limiter   *chat.Limiter
+}
+
+type Option func(*WeComSubscriber)
+
+// WithTemplates renders messages with the registered templates.
+func WithTemplates(templates *msgtemplate.Registry) Option {
+	return func(s *WeComSubscriber) {
+		s.templates = templates
+	}
+}
+
+// WithRateLimit overrides the rate limit of each bot.
+func WithRateLimit(perMinute, burst int) Option {
+	return func(s *WeComSubscriber) {
+		s.limiter = chat.NewLimiter(perMinute, burst, maxWait)
+	}
+}
+
+func New(proxy string, opts ...Option) subscriber.Subscriber {
+	s := &WeComSubscriber{
+		proxy:   proxy,
+		limiter: chat.NewLimiter(defaultPerMinute, defaultBurst, maxWait),
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// Publish sends msg to the group bots in dest, e.g. ["https://qyapi.weixin.qq.com/cgi-bin/webhook/send?key=xxx"]
+func (s *WeComSubscriber) Publish(dest string, content string, time int64, msg *types.Message) []error {
+	monitor.Notify(monitor.MonitorInfo{Tp: monitor.HTTPOutput})
+	targets, err := chat.ParseTargets(dest)
+	if err != nil {
+		return []error{err}
+	}
+	m := NewMessage(chat.BuildCard(msgtemplate.ChannelWeCom, msg, content, s.templates))
+	return chat.Publish(targets, s.limiter, func(t chat.Target) error {
+		return s.send(t, m)
+	})
+}
+
+// NewMessage converts the card to a wecom message, the template card takes the first button as its action.
+func NewMessage(card *chat.Card) Message {
+	if len(card.Buttons) == 0 {
+		var sb strings.Builder
+		if card.Title != "" {
+			sb.WriteString("### " + card.Title + "\n")
+		}
+		sb.WriteString(card.Text)
+		for _, f := range card.Fields {
+			sb.WriteString("\n> " + f.Title + ": <font color=\"comment\">" + f.Value + "</font>")
+		}
+		return Message{MsgType: "markdown", Markdown: &Markdown{Content: truncateBytes(sb.String(), maxMarkdownLength)}}
+	}
+	tc := &TemplateCard{
+		CardType:     "text_notice",
+		MainTitle:    MainTitle{Title: chat.Truncate(card.Title, 26)},
+		SubTitleText: chat.Truncate(card.Text, 112),
+		CardAction:   CardAction{Type: 1, URL: card.Buttons[0].URL},
+	}
+	for i, f := range card.Fields {
+		if i == 6 { // at most 6 items in a card
+			break
+		}
+		tc.HorizontalContentList = append(tc.HorizontalContentList, HorizontalKV{KeyName: chat.Truncate(f.Title, 5), Value: chat.Truncate(f.Value, 26)})
+	}
+	for i, b := range card.Buttons {
+		if i == 3 { // at most 3 jumps in a card
+			break
+		}
+		tc.JumpList = append(tc.JumpList, Jump{Type: 1, URL: b.URL, Title: chat.Truncate(b.Text, 13)})
+	}
+	return Message{MsgType: "template_card", TemplateCard: tc}
+}
+
+// truncateBytes cuts s to at most n bytes without breaking runes, wecom counts the length of markdown in bytes.
+func truncateBytes(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	end := 0
+	for i := range s {
+		if i > n-3 {
+			break
+		}
+		end = i
+	}
+	return s[:end] + "..."
+}
+
+func (s *WeComSubscriber) send(t chat.Target, m Message) error {
+	body, err := chat.PostJSON(t.URL, s.proxy, nil, m)
+	if err != nil {
+		return err
+	}
+	var resp struct {
+		ErrCode int    `json:"errcode"`
+		ErrMsg  string `json:"errmsg"`
+	}
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return errors.Errorf("wecom publish: bad response: %s", string(body))
+	}
+	if resp.ErrCode != 0 {
+		return errors.Errorf("wecom publish: errcode: %d, errmsg: %s", resp.ErrCode, resp.ErrMsg)
+	}
+	return nil
+}
+
+func (s *WeComSubscriber) Status() interface{} {
+	return nil
+}
+
+func (s *WeComSubscriber) Name() string {
+	return "WECOM"
+}
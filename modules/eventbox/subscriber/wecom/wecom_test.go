@@ -0,0 +1,86 @@
+// Copyright (c) 2021 Terminus, Inc.
+//
+// This program is free software: you can use, redistribute, and/or modify
+// it under the terms of the GNU Affero General Public License, version 3
+// or later ("AGPL"), as published by the Free Software Foundation.
+//
+// This program is distributed in the hope that it will be useful, but WITHOUT
+// ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+// FITNESS FOR A PARTICULAR PURPOSE.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package wecom
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/erda-project/erda/modules/eventbox/subscriber/chat"
+	"github.com/erda-project/erda/modules/eventbox/types"
+)
+
+func TestNewMessage(t *testing.T) {
+	m := NewMessage(&chat.Card{
+		Title:  "title",
+		Text:   "text",
+		Fields: []chat.Field{{Title: "Env", Value: "prod"}},
+	})
+	assert.Equal(t, "markdown", m.MsgType)
+	assert.Equal(t, "### title\ntext\n> Env: <font color=\"comment\">prod</font>", m.Markdown.Content)
+
+	m = NewMessage(&chat.Card{
+		Title:   "title",
+		Text:    "text",
+		Fields:  []chat.Field{{Title: "Env", Value: "prod"}},
+		Buttons: []chat.Button{{Text: "View", URL: "https://erda.cloud"}},
+	})
+	assert.Equal(t, "template_card", m.MsgType)
+	assert.Nil(t, m.Markdown)
+	assert.Equal(t, "text_notice", m.TemplateCard.CardType)
+	assert.Equal(t, "https://erda.cloud", m.TemplateCard.CardAction.URL)
+	assert.Equal(t, []HorizontalKV{{KeyName: "Env", Value: "prod"}}, m.TemplateCard.HorizontalContentList)
+	assert.Equal(t, []Jump{{Type: 1, URL: "https://erda.cloud", Title: "View"}}, m.TemplateCard.JumpList)
+}
+
+func TestTruncateBytes(t *testing.T) {
+	assert.Equal(t, "abc", truncateBytes("abc", 3))
+	assert.Equal(t, "ab...", truncateBytes("abcdef", 5))
+	s := truncateBytes(strings.Repeat("中", 10), 10)
+	assert.Equal(t, "中中...", s)
+}
+
+func TestPublish(t *testing.T) {
+	var received []Message
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		var m Message
+		assert.Nil(t, json.Unmarshal(body, &m))
+		received = append(received, m)
+		if r.URL.Query().Get("key") != "xxx" {
+			w.Write([]byte(`{"errcode": 93000, "errmsg": "invalid webhook url"}`))
+			return
+		}
+		w.Write([]byte(`{"errcode": 0, "errmsg": "ok"}`))
+	}))
+	defer srv.Close()
+
+	s := New("", WithRateLimit(0, 0))
+	msg := &types.Message{Labels: map[types.LabelKey]interface{}{
+		chat.CardLabelKey: map[string]interface{}{"title": "deploy failed"},
+	}}
+	errs := s.Publish(`["`+srv.URL+`/cgi-bin/webhook/send?key=xxx"]`, `"pipeline 1 failed"`, 0, msg)
+	assert.Equal(t, 0, len(errs))
+	assert.Equal(t, "### deploy failed\npipeline 1 failed", received[0].Markdown.Content)
+
+	errs = s.Publish(`["`+srv.URL+`/cgi-bin/webhook/send?key=yyy"]`, `"pipeline 1 failed"`, 0, msg)
+	assert.Equal(t, 1, len(errs))
+	assert.Contains(t, errs[0].Error(), "93000")
+}
@@ -39,7 +39,7 @@ func createIfNotExist(impl *WebHookImpl, req *CreateHookRequest) error {
 			return nil
 		}
 	}
-	if _, err := impl.CreateHook(req.Org, *req); err != nil {
+	if _, err := impl.CreateHook(req.Org, *req, ""); err != nil {
 		return err
 	}
 	return nil
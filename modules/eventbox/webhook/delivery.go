@@ -0,0 +1,557 @@
+// Copyright (c) 2021 Terminus, Inc.
+//
+// This program is free software: you can use, redistribute, and/or modify
+// it under the terms of the GNU Affero General Public License, version 3
+// or later ("AGPL"), as published by the Free Software Foundation.
+//
+// This program is distributed in the hope that it will be useful, but WITHOUT
+// ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+// FITNESS FOR A PARTICULAR PURPOSE.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+
+	"github.com/erda-project/erda/apistructs"
+	"github.com/erda-project/erda/modules/eventbox/conf"
+	"github.com/erda-project/erda/modules/eventbox/constant"
+	"github.com/erda-project/erda/modules/eventbox/webhook/eventschema"
+	"github.com/erda-project/erda/pkg/http/httpclient"
+	"github.com/erda-project/erda/pkg/jsonstore"
+	"github.com/erda-project/erda/pkg/jsonstore/stm"
+)
+
+// headers of delivery requests
+const (
+	SignatureHeader = "X-Erda-Signature"
+	EventHeader     = "X-Erda-Event"
+	DeliveryHeader  = "X-Erda-Delivery"
+)
+
+// delivery status
+const (
+	DeliveryPending   = "pending"
+	DeliverySucceeded = "succeeded"
+	DeliveryDead      = "dead"
+)
+
+const (
+	maxResponseBodySize = 4 * 1024
+	// the dead letters are kept for days, their request bodies are truncated to bound the size in etcd
+	maxDeadLetterBodySize = 64 * 1024
+)
+
+type Delivery = apistructs.WebhookDelivery
+
+// RetryPolicy is an exponential backoff schedule bounded by attempts and age.
+type RetryPolicy struct {
+	Interval    time.Duration
+	MaxInterval time.Duration
+	MaxAttempts int
+	MaxAge      time.Duration
+}
+
+// Next returns the delay before the next attempt of a delivery which has failed attempts times and was created age ago,
+// false means giving up.
+func (p RetryPolicy) Next(attempts int, age time.Duration) (time.Duration, bool) {
+	if attempts >= p.MaxAttempts {
+		return 0, false
+	}
+	delay := p.Interval
+	for i := 1; i < attempts && delay < p.MaxInterval; i++ {
+		delay *= 2
+	}
+	if delay > p.MaxInterval {
+		delay = p.MaxInterval
+	}
+	if age+delay > p.MaxAge {
+		return 0, false
+	}
+	return delay, true
+}
+
+// Sign returns the value of X-Erda-Signature for body.
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Deliverer posts events to webhooks, retries failed deliveries in the background,
+// and keeps the recent deliveries and the dead letters of each webhook.
+type Deliverer struct {
+	impl    *WebHookImpl
+	js      jsonstore.JsonStore
+	schemas *eventschema.Registry
+	policy  RetryPolicy
+	timeout time.Duration
+	history int
+	// the max number and the max age of the dead letters of each webhook, 0 means no limit
+	deadLetters   int
+	deadLetterAge time.Duration
+	// strictSchema makes the deliveries breaking the contract dead without attempts
+	strictSchema bool
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+func NewDeliverer() (*Deliverer, error) {
+	impl, err := NewWebHookImpl()
+	if err != nil {
+		return nil, err
+	}
+	js, err := jsonstore.New()
+	if err != nil {
+		return nil, err
+	}
+	schemas, err := eventschema.Default()
+	if err != nil {
+		return nil, err
+	}
+	policy := RetryPolicy{
+		Interval:    conf.WebhookRetryInterval(),
+		MaxInterval: conf.WebhookRetryMaxInterval(),
+		MaxAttempts: conf.WebhookRetryMaxAttempts(),
+		MaxAge:      conf.WebhookRetryMaxAge(),
+	}
+	d := newDeliverer(impl, js, policy, conf.WebhookDeliveryTimeout(), conf.WebhookDeliveryHistory())
+	d.schemas = schemas
+	d.strictSchema = conf.WebhookStrictSchema()
+	d.deadLetters = conf.WebhookDeadLetters()
+	d.deadLetterAge = conf.WebhookDeadLetterMaxAge()
+	return d, nil
+}
+
+func newDeliverer(impl *WebHookImpl, js jsonstore.JsonStore, policy RetryPolicy, timeout time.Duration, history int) *Deliverer {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Deliverer{
+		impl:    impl,
+		js:      js,
+		policy:  policy,
+		timeout: timeout,
+		history: history,
+		ctx:     ctx,
+		cancel:  cancel,
+	}
+}
+
+// Close stops the pending retries, and waits for the running attempts.
+func (d *Deliverer) Close() {
+	d.cancel()
+	d.wg.Wait()
+}
+
+// Deliver posts content to the hook, a failed delivery is retried in the background.
+func (d *Deliverer) Deliver(hookID string, content []byte) (*Delivery, error) {
+	return d.deliver(hookID, content, "")
+}
+
+func (d *Deliverer) deliver(hookID string, content []byte, redeliveryOf string) (*Delivery, error) {
+	h := Hook{}
+	if err := d.impl.js.Get(context.Background(), mkHookEtcdName(hookID), &h); err != nil {
+		return nil, errors.Wrap(InternalServerErr, fmt.Sprintf("get hook: %s, err: %v", hookID, err))
+	}
+	var em struct {
+		Event string `json:"event"`
+	}
+	json.Unmarshal(content, &em) // content of other formats has no event
+	now := nowTimestamp()
+	dl := &Delivery{
+		ID:           genDeliveryID(),
+		HookID:       h.ID,
+		Event:        em.Event,
+		URL:          h.URL,
+		Status:       DeliveryPending,
+		RedeliveryOf: redeliveryOf,
+		Request:      apistructs.WebhookDeliveryRequest{Body: string(content)},
+		CreatedAt:    now,
+		UpdatedAt:    now,
+	}
+	body, err := payload(d.schemas, h, content, d.strictSchema)
+	if err != nil {
+		// the payload breaks the contract, it is dead without attempts, and can be redelivered after fixed
+		dl.Error = err.Error()
+		d.bury(dl)
+		d.prune(h.ID)
+		return dl, nil
+	}
+	dl.Request.Body = string(body)
+	d.attempt(h, dl, time.Now())
+	d.prune(h.ID)
+	return dl, nil
+}
+
+func (d *Deliverer) attempt(h Hook, dl *Delivery, created time.Time) {
+	retryable := d.post(h, dl)
+	if dl.Status == DeliverySucceeded {
+		d.save(dl)
+		if dl.RedeliveryOf != "" {
+			var unused interface{}
+			d.js.Remove(context.Background(), mkDeadLetterKey(dl.HookID, dl.RedeliveryOf), &unused) // not found if it was not dead
+		}
+		return
+	}
+	if delay, ok := d.policy.Next(dl.Attempts, time.Since(created)); retryable && ok {
+		dl.NextAttemptAt = formatTimestamp(time.Now().Add(delay))
+		d.save(dl)
+		next := *dl
+		d.retry(&next, created, delay)
+		return
+	}
+	d.bury(dl)
+}
+
+func (d *Deliverer) retry(dl *Delivery, created time.Time, delay time.Duration) {
+	d.wg.Add(1)
+	go func() {
+		defer d.wg.Done()
+		select {
+		case <-d.ctx.Done():
+			return
+		case <-time.After(delay):
+		}
+		// the delivery may be resumed by other instances, only the one which claims it attempts
+		if !d.claim(dl) {
+			return
+		}
+		h := Hook{}
+		if err := d.impl.js.Get(context.Background(), mkHookEtcdName(dl.HookID), &h); err != nil {
+			logrus.Warnf("webhook delivery: drop retry of %s, get hook: %s, err: %v", dl.ID, dl.HookID, err)
+			return
+		}
+		if !h.Active {
+			dl.Error = "hook is inactive"
+			dl.UpdatedAt = nowTimestamp()
+			d.bury(dl)
+			return
+		}
+		d.attempt(h, dl, created)
+	}()
+}
+
+// claim leases the pending delivery for an attempt, by moving its next attempt time forward atomically,
+// an attempt interrupted by a restart is resumed after the lease.
+func (d *Deliverer) claim(dl *Delivery) bool {
+	key := mkDeliveryKey(dl.HookID, dl.ID)
+	lease := formatTimestamp(time.Now().Add(2*d.timeout + time.Minute))
+	update := func(get func(string, interface{}) error, put func(string, interface{}) error) error {
+		cur := Delivery{}
+		if err := get(key, &cur); err != nil {
+			return err
+		}
+		if cur.Status != DeliveryPending || cur.Attempts != dl.Attempts || cur.NextAttemptAt != dl.NextAttemptAt {
+			return errClaimed
+		}
+		cur.NextAttemptAt = lease
+		return put(key, &cur)
+	}
+	var err error
+	if s := d.js.IncludeSTM(); s != nil {
+		err = s.NewSTM(func(op stm.JSONStoreSTMOP) error {
+			return update(op.Get, op.Put)
+		})
+	} else {
+		err = update(func(k string, v interface{}) error {
+			return d.js.Get(context.Background(), k, v)
+		}, func(k string, v interface{}) error {
+			return d.js.Put(context.Background(), k, v)
+		})
+	}
+	if err != nil {
+		if err != errClaimed {
+			logrus.Warnf("webhook delivery: skip retry of %s, claim err: %v", dl.ID, err)
+		}
+		return false
+	}
+	dl.NextAttemptAt = lease
+	return true
+}
+
+var errClaimed = errors.New("claimed")
+
+// Resume schedules the retries of the pending deliveries persisted before, which were interrupted by restarts.
+func (d *Deliverer) Resume() error {
+	var pending []Delivery
+	if err := d.js.ForEach(context.Background(), constant.WebhookDeliveryDir+"/", Delivery{}, func(_ string, v interface{}) error {
+		if dl := v.(*Delivery); dl.Status == DeliveryPending && dl.NextAttemptAt != "" {
+			pending = append(pending, *dl)
+		}
+		return nil
+	}); err != nil {
+		return err
+	}
+	now := time.Now()
+	for i := range pending {
+		dl := &pending[i]
+		next, err := parseTimestamp(dl.NextAttemptAt)
+		if err != nil {
+			logrus.Warnf("webhook delivery: skip resuming %s, err: %v", dl.ID, err)
+			continue
+		}
+		created, err := parseTimestamp(dl.CreatedAt)
+		if err != nil {
+			created = now
+		}
+		delay := next.Sub(now)
+		if delay < 0 {
+			delay = 0
+		}
+		d.retry(dl, created, delay)
+	}
+	if len(pending) > 0 {
+		logrus.Infof("webhook delivery: resumed %d pending deliveries", len(pending))
+	}
+	return nil
+}
+
+// bury gives up the delivery and moves it to the dead letters.
+func (d *Deliverer) bury(dl *Delivery) {
+	dl.Status = DeliveryDead
+	dl.NextAttemptAt = ""
+	d.save(dl)
+	letter := *dl
+	if len(letter.Request.Body) > maxDeadLetterBodySize {
+		letter.Request.Body = truncate(letter.Request.Body, maxDeadLetterBodySize)
+		letter.Truncated = true
+	}
+	if err := d.js.Put(context.Background(), mkDeadLetterKey(dl.HookID, dl.ID), &letter); err != nil {
+		logrus.Errorf("webhook delivery: put dead letter: %s, err: %v", dl.ID, err)
+	}
+	d.pruneDeadLetters(dl.HookID)
+	logrus.Warnf("webhook delivery: %s of hook: %s is dead after %d attempts, err: %s", dl.ID, dl.HookID, dl.Attempts, dl.Error)
+}
+
+// post sends the delivery to the current url of the hook, and returns whether a failure is worth retrying.
+func (d *Deliverer) post(h Hook, dl *Delivery) bool {
+	dl.Attempts++
+	dl.URL = h.URL
+	dl.UpdatedAt = nowTimestamp()
+	dl.Response = nil
+	dl.Error = ""
+	headers := map[string]string{
+		"Content-Type": "application/json",
+		EventHeader:    dl.Event,
+		DeliveryHeader: dl.ID,
+	}
+	if h.Secret != "" {
+		headers[SignatureHeader] = Sign(h.Secret, []byte(dl.Request.Body))
+	}
+	dl.Request.Headers = headers
+
+	u, err := parseHookURL(h.URL)
+	if err != nil {
+		dl.Error = err.Error()
+		return false
+	}
+	opt := []httpclient.OpOption{httpclient.WithTimeout(d.timeout, d.timeout)}
+	if u.Scheme == "https" {
+		opt = append(opt, httpclient.WithHTTPS())
+	}
+	req := httpclient.New(opt...).Post(u.Host).Path(u.Path).Params(u.Query()).RawBody(bytes.NewBufferString(dl.Request.Body))
+	for k, v := range headers {
+		req = req.Header(k, v)
+	}
+	var body bytes.Buffer
+	start := time.Now()
+	resp, err := req.Do().Body(&body)
+	dl.Latency = time.Since(start).Milliseconds()
+	if err != nil {
+		dl.Error = err.Error()
+		return true
+	}
+	dl.Response = &apistructs.WebhookDeliveryResponse{
+		StatusCode: resp.StatusCode(),
+		Headers:    map[string]string{},
+		Body:       truncate(body.String(), maxResponseBodySize),
+	}
+	for k := range resp.Headers() {
+		dl.Response.Headers[k] = resp.Headers().Get(k)
+	}
+	if resp.IsOK() {
+		dl.Status = DeliverySucceeded
+		return false
+	}
+	dl.Error = fmt.Sprintf("response status: %d", resp.StatusCode())
+	code := resp.StatusCode()
+	return code >= 500 || code == 408 || code == 429
+}
+
+func (d *Deliverer) save(dl *Delivery) {
+	if err := d.js.Put(context.Background(), mkDeliveryKey(dl.HookID, dl.ID), dl); err != nil {
+		logrus.Errorf("webhook delivery: put delivery: %s, err: %v", dl.ID, err)
+	}
+}
+
+// prune removes the oldest finished deliveries of the hook beyond the history size,
+// the pending ones are kept for their retries, and they are bounded by the retry policy.
+func (d *Deliverer) prune(hookID string) {
+	var finished []string
+	if err := d.js.ForEach(context.Background(), mkDeliveryDir(hookID), Delivery{}, func(_ string, v interface{}) error {
+		if dl := v.(*Delivery); dl.Status != DeliveryPending {
+			finished = append(finished, dl.ID)
+		}
+		return nil
+	}); err != nil || len(finished) <= d.history {
+		return
+	}
+	sort.Strings(finished)
+	for _, id := range finished[:len(finished)-d.history] {
+		var unused interface{}
+		d.js.Remove(context.Background(), mkDeliveryKey(hookID, id), &unused)
+	}
+}
+
+// pruneDeadLetters removes the dead letters of the hook which are too old, and the oldest ones beyond the max number.
+func (d *Deliverer) pruneDeadLetters(hookID string) {
+	var ids, expired []string
+	deadline := time.Now().Add(-d.deadLetterAge)
+	if err := d.js.ForEach(context.Background(), mkDeadLetterDir(hookID), Delivery{}, func(_ string, v interface{}) error {
+		dl := v.(*Delivery)
+		if updated, err := parseTimestamp(dl.UpdatedAt); err == nil && d.deadLetterAge > 0 && updated.Before(deadline) {
+			expired = append(expired, dl.ID)
+		} else {
+			ids = append(ids, dl.ID)
+		}
+		return nil
+	}); err != nil {
+		logrus.Warnf("webhook delivery: list dead letters of hook: %s, err: %v", hookID, err)
+		return
+	}
+	if d.deadLetters > 0 && len(ids) > d.deadLetters {
+		sort.Strings(ids)
+		expired = append(expired, ids[:len(ids)-d.deadLetters]...)
+	}
+	for _, id := range expired {
+		var unused interface{}
+		d.js.Remove(context.Background(), mkDeadLetterKey(hookID, id), &unused)
+	}
+}
+
+// ListDeliveries lists the recent deliveries of the hook, newest first.
+func (d *Deliverer) ListDeliveries(realOrg, hookID string) ([]Delivery, error) {
+	if _, err := d.impl.InspectHook(realOrg, hookID); err != nil {
+		return nil, err
+	}
+	return d.list(mkDeliveryDir(hookID))
+}
+
+// ListDeadLetters lists the deliveries of the hook which have given up retrying, newest first.
+func (d *Deliverer) ListDeadLetters(realOrg, hookID string) ([]Delivery, error) {
+	if _, err := d.impl.InspectHook(realOrg, hookID); err != nil {
+		return nil, err
+	}
+	return d.list(mkDeadLetterDir(hookID))
+}
+
+func (d *Deliverer) list(dir string) ([]Delivery, error) {
+	r := []Delivery{}
+	if err := d.js.ForEach(context.Background(), dir, Delivery{}, func(_ string, v interface{}) error {
+		r = append(r, *(v.(*Delivery)))
+		return nil
+	}); err != nil {
+		return nil, errors.Wrap(InternalServerErr, fmt.Sprintf("list deliveries fail: %v", err))
+	}
+	sort.Slice(r, func(i, j int) bool { return r[i].ID > r[j].ID })
+	return r, nil
+}
+
+// InspectDelivery returns a delivery of the hook, which is either recent or dead.
+func (d *Deliverer) InspectDelivery(realOrg, hookID, id string) (*Delivery, error) {
+	if _, err := d.impl.InspectHook(realOrg, hookID); err != nil {
+		return nil, err
+	}
+	dl := Delivery{}
+	err := d.js.Get(context.Background(), mkDeliveryKey(hookID, id), &dl)
+	if err == jsonstore.NotFoundErr {
+		err = d.js.Get(context.Background(), mkDeadLetterKey(hookID, id), &dl)
+	}
+	if err == jsonstore.NotFoundErr {
+		return nil, fmt.Errorf("not found")
+	}
+	if err != nil {
+		return nil, errors.Wrap(InternalServerErr, err.Error())
+	}
+	return &dl, nil
+}
+
+// Redeliver posts the content of a delivery again as a new delivery, the dead letter is removed once it succeeds.
+func (d *Deliverer) Redeliver(realOrg, hookID, id string) (*Delivery, error) {
+	dl, err := d.InspectDelivery(realOrg, hookID, id)
+	if err != nil {
+		return nil, err
+	}
+	if dl.Truncated {
+		return nil, errors.Wrap(BadRequestErr, "the request body of the dead letter is truncated")
+	}
+	return d.deliver(hookID, []byte(dl.Request.Body), dl.ID)
+}
+
+// Purge removes the deliveries and the dead letters of a deleted hook.
+func (d *Deliverer) Purge(hookID string) {
+	d.js.PrefixRemove(context.Background(), mkDeliveryDir(hookID))
+	d.js.PrefixRemove(context.Background(), mkDeadLetterDir(hookID))
+}
+
+// delivery dir structure
+// /<deliverydir>/<hookID>/<deliveryID> -> <delivery>
+// /<deadletterdir>/<hookID>/<deliveryID> -> <delivery>
+
+func mkDeliveryDir(hookID string) string {
+	return strings.Join([]string{constant.WebhookDeliveryDir, hookID}, "/") + "/"
+}
+
+func mkDeliveryKey(hookID, id string) string {
+	return mkDeliveryDir(hookID) + id
+}
+
+func mkDeadLetterDir(hookID string) string {
+	return strings.Join([]string{constant.WebhookDeadLetterDir, hookID}, "/") + "/"
+}
+
+func mkDeadLetterKey(hookID, id string) string {
+	return mkDeadLetterDir(hookID) + id
+}
+
+// genDeliveryID returns an id which sorts by creation time.
+func genDeliveryID() string {
+	return strconv.FormatInt(time.Now().UnixNano(), 36) + genID()[:4]
+}
+
+func parseHookURL(s string) (*url.URL, error) {
+	if !strings.HasPrefix(s, "http") {
+		s = "http://" + s
+	}
+	u, err := url.Parse(s)
+	if err != nil {
+		return nil, errors.Wrap(BadRequestErr, "bad hook url")
+	}
+	return u, nil
+}
+
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return s[:n]
+}
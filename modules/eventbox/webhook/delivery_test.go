@@ -0,0 +1,264 @@
+// Copyright (c) 2021 Terminus, Inc.
+//
+// This program is free software: you can use, redistribute, and/or modify
+// it under the terms of the GNU Affero General Public License, version 3
+// or later ("AGPL"), as published by the Free Software Foundation.
+//
+// This program is distributed in the hope that it will be useful, but WITHOUT
+// ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+// FITNESS FOR A PARTICULAR PURPOSE.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package webhook
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/erda-project/erda/pkg/jsonstore"
+)
+
+func TestRetryPolicyNext(t *testing.T) {
+	p := RetryPolicy{Interval: time.Second, MaxInterval: 5 * time.Second, MaxAttempts: 5, MaxAge: time.Minute}
+	for _, c := range []struct {
+		attempts int
+		age      time.Duration
+		delay    time.Duration
+		ok       bool
+	}{
+		{1, 0, time.Second, true},
+		{2, 0, 2 * time.Second, true},
+		{3, 0, 4 * time.Second, true},
+		{4, 0, 5 * time.Second, true},
+		{5, 0, 0, false},
+		{2, 59 * time.Second, 0, false},
+	} {
+		delay, ok := p.Next(c.attempts, c.age)
+		assert.Equal(t, c.ok, ok, "attempts: %d", c.attempts)
+		assert.Equal(t, c.delay, delay, "attempts: %d", c.attempts)
+	}
+}
+
+func TestSign(t *testing.T) {
+	assert.Equal(t, "sha256=b613679a0814d9ec772f95d778c35fc5ff1697c493715653c6c712144292c5ad", Sign("", nil))
+	assert.NotEqual(t, Sign("a", []byte("body")), Sign("b", []byte("body")))
+}
+
+func newTestDeliverer(t *testing.T, url string, policy RetryPolicy) (*Deliverer, Hook) {
+	hooks, err := jsonstore.New(jsonstore.UseMemStore())
+	assert.Nil(t, err)
+	js, err := jsonstore.New(jsonstore.UseMemStore())
+	assert.Nil(t, err)
+	h := Hook{ID: "hook1", Secret: "secret", CreateHookRequest: CreateHookRequest{
+		Name: "test", URL: url, Active: true, HookLocation: HookLocation{Org: "1", Project: "2", Application: "3"},
+	}}
+	assert.Nil(t, hooks.Put(context.Background(), mkHookEtcdName(h.ID), h))
+	return newDeliverer(&WebHookImpl{js: hooks}, js, policy, time.Second, 2), h
+}
+
+func TestDeliverSigned(t *testing.T) {
+	content := []byte(`{"event":"pipeline","content":{}}`)
+	var signature, event string
+	s := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		assert.Equal(t, content, body)
+		signature, event = r.Header.Get(SignatureHeader), r.Header.Get(EventHeader)
+		rw.Write([]byte("ok"))
+	}))
+	defer s.Close()
+	d, h := newTestDeliverer(t, s.URL, RetryPolicy{MaxAttempts: 1})
+	defer d.Close()
+
+	dl, err := d.Deliver(h.ID, content)
+	assert.Nil(t, err)
+	assert.Equal(t, DeliverySucceeded, dl.Status)
+	assert.Equal(t, Sign("secret", content), signature)
+	assert.Equal(t, "pipeline", event)
+	assert.Equal(t, "ok", dl.Response.Body)
+
+	for i := 0; i < 3; i++ {
+		_, err := d.Deliver(h.ID, content)
+		assert.Nil(t, err)
+	}
+	list, err := d.ListDeliveries("1", h.ID)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(list))
+	assert.True(t, list[0].ID > list[1].ID)
+	_, err = d.ListDeliveries("2", h.ID)
+	assert.NotNil(t, err)
+}
+
+func TestDeliverRetryAndDeadLetter(t *testing.T) {
+	var calls, fail int32 = 0, 1
+	s := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		if atomic.LoadInt32(&fail) == 1 {
+			rw.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer s.Close()
+	d, h := newTestDeliverer(t, s.URL, RetryPolicy{Interval: 10 * time.Millisecond, MaxInterval: 20 * time.Millisecond, MaxAttempts: 3, MaxAge: time.Minute})
+	defer d.Close()
+
+	dl, err := d.Deliver(h.ID, []byte(`{"event":"runtime"}`))
+	assert.Nil(t, err)
+	assert.Equal(t, DeliveryPending, dl.Status)
+	assert.Equal(t, 1, dl.Attempts)
+	assert.NotEqual(t, "", dl.NextAttemptAt)
+
+	var dead []Delivery
+	for i := 0; i < 100 && len(dead) == 0; i++ {
+		time.Sleep(10 * time.Millisecond)
+		dead, err = d.ListDeadLetters("1", h.ID)
+		assert.Nil(t, err)
+	}
+	assert.Equal(t, 1, len(dead))
+	assert.Equal(t, DeliveryDead, dead[0].Status)
+	assert.Equal(t, 3, dead[0].Attempts)
+	assert.Equal(t, int32(3), atomic.LoadInt32(&calls))
+
+	atomic.StoreInt32(&fail, 0)
+	re, err := d.Redeliver("1", h.ID, dl.ID)
+	assert.Nil(t, err)
+	assert.Equal(t, DeliverySucceeded, re.Status)
+	assert.Equal(t, dl.ID, re.RedeliveryOf)
+	dead, err = d.ListDeadLetters("1", h.ID)
+	assert.Nil(t, err)
+	assert.Equal(t, 0, len(dead))
+}
+
+func TestDeliverResume(t *testing.T) {
+	var calls, fail int32 = 0, 1
+	s := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		if atomic.LoadInt32(&fail) == 1 {
+			rw.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer s.Close()
+	policy := RetryPolicy{Interval: time.Hour, MaxInterval: time.Hour, MaxAttempts: 3, MaxAge: 2 * time.Hour}
+	d, h := newTestDeliverer(t, s.URL, policy)
+	dl, err := d.Deliver(h.ID, []byte(`{"event":"runtime"}`))
+	assert.Nil(t, err)
+	assert.Equal(t, DeliveryPending, dl.Status)
+	d.Close() // restarted before the retry
+
+	// the next attempt is due
+	stored := Delivery{}
+	assert.Nil(t, d.js.Get(context.Background(), mkDeliveryKey(h.ID, dl.ID), &stored))
+	stored.NextAttemptAt = formatTimestamp(time.Now().Add(-time.Second))
+	assert.Nil(t, d.js.Put(context.Background(), mkDeliveryKey(h.ID, dl.ID), &stored))
+
+	atomic.StoreInt32(&fail, 0)
+	resumed := newDeliverer(d.impl, d.js, policy, time.Second, 2)
+	defer resumed.Close()
+	assert.Nil(t, resumed.Resume())
+	var got *Delivery
+	for i := 0; i < 100; i++ {
+		time.Sleep(10 * time.Millisecond)
+		got, err = resumed.InspectDelivery("1", h.ID, dl.ID)
+		assert.Nil(t, err)
+		if got.Status == DeliverySucceeded {
+			break
+		}
+	}
+	assert.Equal(t, DeliverySucceeded, got.Status)
+	assert.Equal(t, 2, got.Attempts)
+	assert.Equal(t, int32(2), atomic.LoadInt32(&calls))
+
+	// a stale copy of the delivery can't be claimed again
+	assert.False(t, resumed.claim(&stored))
+}
+
+func TestDeliverNotRetryable(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		rw.WriteHeader(http.StatusBadRequest)
+	}))
+	defer s.Close()
+	d, h := newTestDeliverer(t, s.URL, RetryPolicy{Interval: time.Second, MaxInterval: time.Second, MaxAttempts: 3, MaxAge: time.Minute})
+	defer d.Close()
+
+	dl, err := d.Deliver(h.ID, []byte(`{}`))
+	assert.Nil(t, err)
+	assert.Equal(t, DeliveryDead, dl.Status)
+	assert.Equal(t, http.StatusBadRequest, dl.Response.StatusCode)
+	got, err := d.InspectDelivery("1", h.ID, dl.ID)
+	assert.Nil(t, err)
+	assert.Equal(t, DeliveryDead, got.Status)
+	_, err = d.InspectDelivery("1", h.ID, "unknown")
+	assert.NotNil(t, err)
+}
+
+func TestPrune(t *testing.T) {
+	d, h := newTestDeliverer(t, "http://127.0.0.1:1", RetryPolicy{})
+	defer d.Close()
+	now := time.Now()
+	put := func(key string, id string, status string, updated time.Time) {
+		dl := Delivery{ID: id, HookID: h.ID, Status: status, UpdatedAt: formatTimestamp(updated)}
+		assert.Nil(t, d.js.Put(context.Background(), key, &dl))
+	}
+	// the pending deliveries are older than the finished ones, but they are kept for the retries
+	put(mkDeliveryKey(h.ID, "a"), "a", DeliveryPending, now)
+	put(mkDeliveryKey(h.ID, "b"), "b", DeliverySucceeded, now)
+	put(mkDeliveryKey(h.ID, "c"), "c", DeliveryDead, now)
+	put(mkDeliveryKey(h.ID, "d"), "d", DeliverySucceeded, now)
+	d.prune(h.ID)
+	list, err := d.ListDeliveries("1", h.ID)
+	assert.Nil(t, err)
+	var ids []string
+	for _, dl := range list {
+		ids = append(ids, dl.ID)
+	}
+	assert.Equal(t, []string{"d", "c", "a"}, ids)
+
+	d.deadLetters, d.deadLetterAge = 2, time.Hour
+	put(mkDeadLetterKey(h.ID, "a"), "a", DeliveryDead, now.Add(-2*time.Hour))
+	put(mkDeadLetterKey(h.ID, "b"), "b", DeliveryDead, now)
+	put(mkDeadLetterKey(h.ID, "c"), "c", DeliveryDead, now)
+	put(mkDeadLetterKey(h.ID, "d"), "d", DeliveryDead, now)
+	d.pruneDeadLetters(h.ID)
+	dead, err := d.ListDeadLetters("1", h.ID)
+	assert.Nil(t, err)
+	ids = nil
+	for _, dl := range dead {
+		ids = append(ids, dl.ID)
+	}
+	assert.Equal(t, []string{"d", "c"}, ids)
+}
+
+func TestDeadLetterTruncated(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		rw.WriteHeader(http.StatusBadRequest)
+	}))
+	defer s.Close()
+	d, h := newTestDeliverer(t, s.URL, RetryPolicy{MaxAttempts: 1})
+	defer d.Close()
+
+	body := make([]byte, maxDeadLetterBodySize+1)
+	for i := range body {
+		body[i] = ' '
+	}
+	copy(body, `{"event":"runtime"}`)
+	dl, err := d.Deliver(h.ID, body)
+	assert.Nil(t, err)
+	assert.Equal(t, DeliveryDead, dl.Status)
+	letter := Delivery{}
+	assert.Nil(t, d.js.Get(context.Background(), mkDeadLetterKey(h.ID, dl.ID), &letter))
+	assert.True(t, letter.Truncated)
+	assert.Equal(t, maxDeadLetterBodySize, len(letter.Request.Body))
+
+	// the truncated dead letter can't be redelivered after the delivery is pruned
+	var unused interface{}
+	assert.Nil(t, d.js.Remove(context.Background(), mkDeliveryKey(h.ID, dl.ID), &unused))
+	_, err = d.Redeliver("1", h.ID, dl.ID)
+	assert.NotNil(t, err)
+}
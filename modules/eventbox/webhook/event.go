@@ -27,6 +27,8 @@ type EventMessage struct {
 	ProjectID     string `json:"projectID"`
 	ApplicationID string `json:"applicationID"`
 	Env           string `json:"env"`
+	// content 的版本，见 eventschema
+	Version string `json:"version,omitempty"`
 	// content 结构跟具体 event 相关
 	Content   json.RawMessage `json:"content"`
 	TimeStamp string          `json:"timestamp"`
@@ -55,6 +57,7 @@ func MkEventMessage(label EventLabel, content []byte) EventMessage {
 		ProjectID:     label.ProjectID,
 		ApplicationID: label.ApplicationID,
 		Env:           label.Env,
+		Version:       label.Version,
 		Content:       content,
 		TimeStamp:     nowTimestamp(),
 	}
@@ -0,0 +1,315 @@
+// Copyright (c) 2021 Terminus, Inc.
+//
+// This program is free software: you can use, redistribute, and/or modify
+// it under the terms of the GNU Affero General Public License, version 3
+// or later ("AGPL"), as published by the Free Software Foundation.
+//
+// This program is distributed in the hope that it will be useful, but WITHOUT
+// ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+// FITNESS FOR A PARTICULAR PURPOSE.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+// Package eventschema defines the payload contract of webhook events.
+//
+// The content of each event is described by a JSON Schema (the OpenAPI 3.0 dialect) per version.
+// Producers declare the version in the event header, empty means v1.
+// A webhook may pin the version of an event, the payload is converted to it by the registered converters.
+package eventschema
+
+import (
+	"context"
+	"embed"
+	"encoding/json"
+	"fmt"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/pkg/errors"
+
+	"github.com/erda-project/erda/apistructs"
+)
+
+// DefaultVersion is the version of the events without version.
+const DefaultVersion = "v1"
+
+// Latest refers to the latest version of an event in queries.
+const Latest = "latest"
+
+var NotFoundErr = errors.New("schema not found")
+
+type Schema = apistructs.WebhookEventSchema
+
+// Converter converts the content of an event between two adjacent versions.
+type Converter func(content json.RawMessage) (json.RawMessage, error)
+
+//go:embed schemas
+var builtin embed.FS
+
+var (
+	defaultRegistry     *Registry
+	defaultRegistryErr  error
+	defaultRegistryOnce sync.Once
+)
+
+// Default returns the registry of the builtin schemas.
+func Default() (*Registry, error) {
+	defaultRegistryOnce.Do(func() {
+		defaultRegistry, defaultRegistryErr = loadBuiltin()
+	})
+	return defaultRegistry, defaultRegistryErr
+}
+
+type entry struct {
+	Schema
+	compiled *openapi3.Schema
+}
+
+// Registry holds the schemas and converters of events.
+type Registry struct {
+	lock       sync.RWMutex
+	events     map[string][]*entry // sorted by version
+	converters map[string]Converter
+}
+
+// New returns an empty Registry.
+func New() *Registry {
+	return &Registry{
+		events:     make(map[string][]*entry),
+		converters: make(map[string]Converter),
+	}
+}
+
+func loadBuiltin() (*Registry, error) {
+	r := New()
+	dirs, err := builtin.ReadDir("schemas")
+	if err != nil {
+		return nil, err
+	}
+	for _, dir := range dirs {
+		files, err := builtin.ReadDir(path.Join("schemas", dir.Name()))
+		if err != nil {
+			return nil, err
+		}
+		for _, f := range files {
+			raw, err := builtin.ReadFile(path.Join("schemas", dir.Name(), f.Name()))
+			if err != nil {
+				return nil, err
+			}
+			s := Schema{}
+			if err := json.Unmarshal(raw, &s); err != nil {
+				return nil, fmt.Errorf("schema %s/%s: %v", dir.Name(), f.Name(), err)
+			}
+			s.Event, s.Version = dir.Name(), strings.TrimSuffix(f.Name(), path.Ext(f.Name()))
+			if err := r.Add(s); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return r, nil
+}
+
+// Add adds or replaces the schema of an event version, the sample must satisfy the schema.
+func (r *Registry) Add(s Schema) error {
+	if s.Event == "" {
+		return errors.New("empty event")
+	}
+	if _, ok := versionNumber(s.Version); !ok {
+		return fmt.Errorf("illegal version of %s: %q, it should be like v1", s.Event, s.Version)
+	}
+	compiled := openapi3.NewSchema()
+	if err := json.Unmarshal(s.Schema, compiled); err != nil {
+		return fmt.Errorf("schema of %s %s: %v", s.Event, s.Version, err)
+	}
+	if err := compiled.Validate(context.Background()); err != nil {
+		return fmt.Errorf("schema of %s %s: %v", s.Event, s.Version, err)
+	}
+	e := &entry{Schema: s, compiled: compiled}
+	if len(s.Sample) > 0 {
+		if err := e.validate(s.Sample); err != nil {
+			return fmt.Errorf("sample of %s %s: %v", s.Event, s.Version, err)
+		}
+	}
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	list := r.events[s.Event]
+	for i := range list {
+		if list[i].Version == s.Version {
+			list[i] = e
+			return nil
+		}
+	}
+	list = append(list, e)
+	sort.Slice(list, func(i, j int) bool {
+		vi, _ := versionNumber(list[i].Version)
+		vj, _ := versionNumber(list[j].Version)
+		return vi < vj
+	})
+	r.events[s.Event] = list
+	return nil
+}
+
+// AddConverter adds the converter of the content of event from one version to an adjacent one.
+func (r *Registry) AddConverter(event, from, to string, c Converter) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.converters[converterKey(event, from, to)] = c
+}
+
+// Events returns the events with schemas.
+func (r *Registry) Events() []string {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+	events := make([]string, 0, len(r.events))
+	for e := range r.events {
+		events = append(events, e)
+	}
+	sort.Strings(events)
+	return events
+}
+
+// List returns the schemas of all versions of the event.
+func (r *Registry) List(event string) ([]Schema, error) {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+	list, ok := r.events[event]
+	if !ok {
+		return nil, errors.Wrap(NotFoundErr, event)
+	}
+	schemas := make([]Schema, 0, len(list))
+	for _, e := range list {
+		schemas = append(schemas, e.Schema)
+	}
+	return schemas, nil
+}
+
+// Get returns the schema of the event version, version may be Latest.
+func (r *Registry) Get(event, version string) (*Schema, error) {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+	e, err := r.get(event, version)
+	if err != nil {
+		return nil, err
+	}
+	s := e.Schema
+	return &s, nil
+}
+
+// Has returns whether the event has any schema, events without schemas are not validated.
+func (r *Registry) Has(event string) bool {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+	_, ok := r.events[event]
+	return ok
+}
+
+// Validate validates the content of the event version.
+func (r *Registry) Validate(event, version string, content json.RawMessage) error {
+	r.lock.RLock()
+	e, err := r.get(event, version)
+	r.lock.RUnlock()
+	if err != nil {
+		return err
+	}
+	return e.validate(content)
+}
+
+// Convert converts the content of event from one version to another step by step.
+func (r *Registry) Convert(event, from, to string, content json.RawMessage) (json.RawMessage, error) {
+	from, to = normalize(from), normalize(to)
+	if from == to {
+		return content, nil
+	}
+	vf, ok1 := versionNumber(from)
+	vt, ok2 := versionNumber(to)
+	if !ok1 || !ok2 {
+		return nil, fmt.Errorf("illegal version: %s -> %s", from, to)
+	}
+	step := 1
+	if vt < vf {
+		step = -1
+	}
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+	for v := vf; v != vt; v += step {
+		a, b := "v"+strconv.Itoa(v), "v"+strconv.Itoa(v+step)
+		c, ok := r.converters[converterKey(event, a, b)]
+		if !ok {
+			return nil, fmt.Errorf("can not convert %s from %s to %s", event, a, b)
+		}
+		var err error
+		if content, err = c(content); err != nil {
+			return nil, fmt.Errorf("convert %s from %s to %s: %v", event, a, b, err)
+		}
+	}
+	return content, nil
+}
+
+// get returns the entry of the event version, r.lock is held.
+func (r *Registry) get(event, version string) (*entry, error) {
+	list, ok := r.events[event]
+	if !ok || len(list) == 0 {
+		return nil, errors.Wrap(NotFoundErr, event)
+	}
+	if version == Latest {
+		return list[len(list)-1], nil
+	}
+	version = normalize(version)
+	for _, e := range list {
+		if e.Version == version {
+			return e, nil
+		}
+	}
+	return nil, errors.Wrap(NotFoundErr, fmt.Sprintf("%s %s", event, version))
+}
+
+func (e *entry) validate(content json.RawMessage) error {
+	var v interface{}
+	if err := json.Unmarshal(content, &v); err != nil {
+		return fmt.Errorf("illegal content: %v", err)
+	}
+	if err := e.compiled.VisitJSON(v, openapi3.MultiErrors()); err != nil {
+		return fmt.Errorf("content of %s %s does not match the schema: %s", e.Event, e.Version, formatError(err))
+	}
+	return nil
+}
+
+func formatError(err error) string {
+	switch e := err.(type) {
+	case openapi3.MultiError:
+		msgs := make([]string, 0, len(e))
+		for _, item := range e {
+			msgs = append(msgs, formatError(item))
+		}
+		return strings.Join(msgs, "; ")
+	case *openapi3.SchemaError:
+		return "/" + strings.Join(e.JSONPointer(), "/") + ": " + e.Reason
+	}
+	return err.Error()
+}
+
+// normalize returns the version, empty means DefaultVersion.
+func normalize(version string) string {
+	if version == "" {
+		return DefaultVersion
+	}
+	return version
+}
+
+func versionNumber(version string) (int, bool) {
+	if !strings.HasPrefix(version, "v") {
+		return 0, false
+	}
+	n, err := strconv.Atoi(version[1:])
+	return n, err == nil && n > 0
+}
+
+func converterKey(event, from, to string) string {
+	return event + "/" + from + "/" + to
+}
@@ -0,0 +1,164 @@
+// Copyright (c) 2021 Terminus, Inc.
+//
+// This program is free software: you can use, redistribute, and/or modify
+// it under the terms of the GNU Affero General Public License, version 3
+// or later ("AGPL"), as published by the Free Software Foundation.
+//
+// This program is distributed in the hope that it will be useful, but WITHOUT
+// ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+// FITNESS FOR A PARTICULAR PURPOSE.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package eventschema
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/erda-project/erda/apistructs"
+)
+
+func TestDefault(t *testing.T) {
+	r, err := Default()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"ping", "pipeline", "runtime"}, r.Events())
+	for _, event := range r.Events() {
+		s, err := r.Get(event, "")
+		require.NoError(t, err)
+		assert.Equal(t, DefaultVersion, s.Version)
+		assert.NotEmpty(t, s.Desc)
+		assert.NoError(t, r.Validate(event, s.Version, s.Sample))
+	}
+}
+
+// TestSchemaFields checks the properties of the schemas against the types the producers marshal,
+// so the schemas follow the json tags of apistructs.
+func TestSchemaFields(t *testing.T) {
+	r, err := Default()
+	require.NoError(t, err)
+	for _, c := range []struct {
+		event string
+		path  []string
+		typ   reflect.Type
+	}{
+		{"pipeline", nil, reflect.TypeOf(apistructs.PipelineInstanceEventData{})},
+		{"runtime", []string{"runtime"}, reflect.TypeOf(apistructs.RuntimeDTO{})},
+	} {
+		e, err := r.get(c.event, DefaultVersion)
+		require.NoError(t, err)
+		schema := e.compiled
+		for _, p := range c.path {
+			require.NotNil(t, schema.Properties[p], "%s %s", c.event, p)
+			schema = schema.Properties[p].Value
+		}
+		fields := jsonFields(c.typ)
+		for name, prop := range schema.Properties {
+			f, ok := fields[name]
+			if !assert.True(t, ok, "%s: property %s is not a field of %s", c.event, name, c.typ) {
+				continue
+			}
+			assert.Equal(t, schemaType(f.Type), prop.Value.Type, "%s: type of property %s", c.event, name)
+		}
+	}
+}
+
+func jsonFields(typ reflect.Type) map[string]reflect.StructField {
+	fields := make(map[string]reflect.StructField)
+	for i := 0; i < typ.NumField(); i++ {
+		f := typ.Field(i)
+		name := strings.Split(f.Tag.Get("json"), ",")[0]
+		if name == "" {
+			name = f.Name
+		}
+		if name != "-" {
+			fields[name] = f
+		}
+	}
+	return fields
+}
+
+func schemaType(typ reflect.Type) string {
+	if typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+	if typ == reflect.TypeOf(time.Time{}) {
+		return "string"
+	}
+	switch typ.Kind() {
+	case reflect.Bool:
+		return "boolean"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return "integer"
+	case reflect.Float32, reflect.Float64:
+		return "number"
+	case reflect.String:
+		return "string"
+	case reflect.Slice, reflect.Array:
+		return "array"
+	}
+	return "object"
+}
+
+func TestValidate(t *testing.T) {
+	r, err := Default()
+	require.NoError(t, err)
+	assert.NoError(t, r.Validate("pipeline", "v1", json.RawMessage(`{"pipelineID": 1, "status": "Failed", "timeBegin": null, "extra": 1}`)))
+	err = r.Validate("pipeline", "v1", json.RawMessage(`{"pipelineID": "1"}`))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "/pipelineID")
+	assert.Contains(t, err.Error(), "/status")
+	_, err = r.Get("pipeline", "v9")
+	assert.Error(t, err)
+	assert.False(t, r.Has("unknown"))
+}
+
+func TestConvert(t *testing.T) {
+	r := New()
+	require.NoError(t, r.Add(Schema{
+		Event:   "demo",
+		Version: "v1",
+		Schema:  json.RawMessage(`{"type": "object", "required": ["name"]}`),
+		Sample:  json.RawMessage(`{"name": "a"}`),
+	}))
+	require.NoError(t, r.Add(Schema{
+		Event:   "demo",
+		Version: "v2",
+		Schema:  json.RawMessage(`{"type": "object", "required": ["title"]}`),
+		Sample:  json.RawMessage(`{"title": "a"}`),
+	}))
+	assert.Error(t, r.Add(Schema{Event: "demo", Version: "2", Schema: json.RawMessage(`{}`)}))
+	assert.Error(t, r.Add(Schema{Event: "demo", Version: "v3", Schema: json.RawMessage(`{"required": ["a"]}`), Sample: json.RawMessage(`{}`)}))
+
+	latest, err := r.Get("demo", Latest)
+	require.NoError(t, err)
+	assert.Equal(t, "v2", latest.Version)
+
+	_, err = r.Convert("demo", "v2", "v1", json.RawMessage(`{"title": "a"}`))
+	assert.Error(t, err)
+	r.AddConverter("demo", "v2", "v1", func(content json.RawMessage) (json.RawMessage, error) {
+		var v map[string]interface{}
+		if err := json.Unmarshal(content, &v); err != nil {
+			return nil, err
+		}
+		v["name"] = v["title"]
+		delete(v, "title")
+		return json.Marshal(v)
+	})
+	converted, err := r.Convert("demo", "v2", "v1", json.RawMessage(`{"title": "a"}`))
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"name": "a"}`, string(converted))
+	assert.NoError(t, r.Validate("demo", "", converted))
+
+	same, err := r.Convert("demo", "", "v1", json.RawMessage(`{"name": "a"}`))
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"name": "a"}`, string(same))
+}
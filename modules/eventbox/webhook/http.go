@@ -27,11 +27,13 @@ import (
 	"github.com/erda-project/erda/apistructs"
 	"github.com/erda-project/erda/bundle"
 	stypes "github.com/erda-project/erda/modules/eventbox/server/types"
+	"github.com/erda-project/erda/modules/eventbox/webhook/eventschema"
 )
 
 const (
 	BadRequestCode        = "WH400"
 	InternalServerErrCode = "WH500"
+	NotFoundCode          = "WH404"
 	OtherErrCode          = "WH600"
 )
 
@@ -46,10 +48,11 @@ func toCode(e error) string {
 }
 
 type WebHookHTTP struct {
-	impl *WebHookImpl
+	impl      *WebHookImpl
+	deliverer *Deliverer
 }
 
-func NewWebHookHTTP() (*WebHookHTTP, error) {
+func NewWebHookHTTP(deliverer *Deliverer) (*WebHookHTTP, error) {
 	impl, err := NewWebHookImpl()
 	if err != nil {
 		return nil, err
@@ -58,7 +61,8 @@ func NewWebHookHTTP() (*WebHookHTTP, error) {
 		return nil, err
 	}
 	return &WebHookHTTP{
-		impl: impl,
+		impl:      impl,
+		deliverer: deliverer,
 	}, nil
 }
 func (w *WebHookHTTP) ListHooks(ctx context.Context, req *http.Request, vars map[string]string) (stypes.Responser, error) {
@@ -91,6 +95,9 @@ func (w *WebHookHTTP) ListHooks(ctx context.Context, req *http.Request, vars map
 			Compose: true,
 		}, nil
 	}
+	for i := range r {
+		r[i] = redactSecret(r[i])
+	}
 	return stypes.HTTPResponse{
 		Compose: true,
 		Content: r,
@@ -115,13 +122,17 @@ func (w *WebHookHTTP) InspectHook(ctx context.Context, req *http.Request, vars m
 	}
 	return stypes.HTTPResponse{
 		Compose: true,
-		Content: r,
+		Content: InspectHookResponse(redactSecret(Hook(r))),
 	}, nil
 }
 
 // org, project will be provided in request body
 func (w *WebHookHTTP) CreateHook(ctx context.Context, req *http.Request, vars map[string]string) (stypes.Responser, error) {
-	h := CreateHookRequest{}
+	var h struct {
+		CreateHookRequest
+		// 签名密钥，为空则不签名
+		Secret string `json:"secret"`
+	}
 	if err := json.NewDecoder(req.Body).Decode(&h); err != nil {
 		err := fmt.Errorf("createhook: decode fail: %v", err)
 		logrus.Error(err)
@@ -133,7 +144,7 @@ func (w *WebHookHTTP) CreateHook(ctx context.Context, req *http.Request, vars ma
 			Compose: true,
 		}, nil
 	}
-	r, err := w.impl.CreateHook(extractOrgIDHeader(req), h)
+	r, err := w.impl.CreateHook(extractOrgIDHeader(req), h.CreateHookRequest, h.Secret)
 	if err != nil {
 		logrus.Error(err)
 		return stypes.HTTPResponse{
@@ -211,12 +222,50 @@ func (w *WebHookHTTP) DeleteHook(ctx context.Context, req *http.Request, vars ma
 			Compose: true,
 		}, nil
 	}
+	w.deliverer.Purge(id)
 	return stypes.HTTPResponse{
 		Content: "",
 		Compose: true,
 	}, nil
 }
 
+func (w *WebHookHTTP) ListDeliveries(ctx context.Context, req *http.Request, vars map[string]string) (stypes.Responser, error) {
+	r, err := w.deliverer.ListDeliveries(extractOrgIDHeader(req), vars["id"])
+	return deliveryResponse(r, err)
+}
+
+func (w *WebHookHTTP) InspectDelivery(ctx context.Context, req *http.Request, vars map[string]string) (stypes.Responser, error) {
+	r, err := w.deliverer.InspectDelivery(extractOrgIDHeader(req), vars["id"], vars["deliveryID"])
+	return deliveryResponse(r, err)
+}
+
+func (w *WebHookHTTP) Redeliver(ctx context.Context, req *http.Request, vars map[string]string) (stypes.Responser, error) {
+	r, err := w.deliverer.Redeliver(extractOrgIDHeader(req), vars["id"], vars["deliveryID"])
+	return deliveryResponse(r, err)
+}
+
+func (w *WebHookHTTP) ListDeadLetters(ctx context.Context, req *http.Request, vars map[string]string) (stypes.Responser, error) {
+	r, err := w.deliverer.ListDeadLetters(extractOrgIDHeader(req), vars["id"])
+	return deliveryResponse(r, err)
+}
+
+func deliveryResponse(content interface{}, err error) (stypes.Responser, error) {
+	if err != nil {
+		logrus.Error(err)
+		return stypes.HTTPResponse{
+			Error: &stypes.ErrorResponse{
+				Code: toCode(errors.Cause(err)),
+				Msg:  err.Error(),
+			},
+			Compose: true,
+		}, nil
+	}
+	return stypes.HTTPResponse{
+		Content: content,
+		Compose: true,
+	}, nil
+}
+
 type ListHookEventsResponse = apistructs.WebhookListEventsResponseData
 
 func (w *WebHookHTTP) ListHookEvents(ctx context.Context, req *http.Request, vars map[string]string) (stypes.Responser, error) {
@@ -238,6 +287,54 @@ func (w *WebHookHTTP) ListHookEvents(ctx context.Context, req *http.Request, var
 
 }
 
+func (w *WebHookHTTP) ListEventSchemas(ctx context.Context, req *http.Request, vars map[string]string) (stypes.Responser, error) {
+	schemas, err := eventschema.Default()
+	if err != nil {
+		return schemaResponse(nil, errors.Wrap(InternalServerErr, err.Error()))
+	}
+	return schemaResponse(schemas.List(vars["event"]))
+}
+
+func (w *WebHookHTTP) InspectEventSchema(ctx context.Context, req *http.Request, vars map[string]string) (stypes.Responser, error) {
+	schemas, err := eventschema.Default()
+	if err != nil {
+		return schemaResponse(nil, errors.Wrap(InternalServerErr, err.Error()))
+	}
+	return schemaResponse(schemas.Get(vars["event"], vars["version"]))
+}
+
+// EventSample returns a sample of the whole payload delivered to webhooks.
+func (w *WebHookHTTP) EventSample(ctx context.Context, req *http.Request, vars map[string]string) (stypes.Responser, error) {
+	schemas, err := eventschema.Default()
+	if err != nil {
+		return schemaResponse(nil, errors.Wrap(InternalServerErr, err.Error()))
+	}
+	s, err := schemas.Get(vars["event"], vars["version"])
+	if err != nil {
+		return schemaResponse(nil, err)
+	}
+	em := MkEventMessage(EventLabel{
+		Event:         s.Event,
+		OrgID:         "1",
+		ProjectID:     "2",
+		ApplicationID: "3",
+		Env:           "PROD",
+		Version:       s.Version,
+	}, s.Sample)
+	return schemaResponse(em, nil)
+}
+
+func schemaResponse(content interface{}, err error) (stypes.Responser, error) {
+	if errors.Cause(err) == eventschema.NotFoundErr {
+		return stypes.HTTPResponse{
+			Status:  http.StatusNotFound,
+			Error:   &stypes.ErrorResponse{Code: NotFoundCode, Msg: err.Error()},
+			Compose: true,
+		}, nil
+	}
+	return deliveryResponse(content, err)
+}
+
 func extractHookLocation(req *http.Request) (apistructs.HookLocation, error) {
 	org := queryGetByOrder(req, "orgID", "orgId", "orgid")
 	project := queryGetByOrder(req, "projectID", "projectId", "projectid")
@@ -370,6 +467,13 @@ func (w *WebHookHTTP) GetHTTPEndPoints() []stypes.Endpoint {
 		{"/webhooks/{id}", http.MethodPut, check(w.EditHook)},
 		{"/webhooks/{id}/actions/ping", http.MethodPost, check(w.PingHook)},
 		{"/webhooks/{id}", http.MethodDelete, check(w.DeleteHook)},
+		{"/webhooks/{id}/deliveries", http.MethodGet, check(w.ListDeliveries)},
+		{"/webhooks/{id}/deliveries/{deliveryID}", http.MethodGet, check(w.InspectDelivery)},
+		{"/webhooks/{id}/deliveries/{deliveryID}/actions/redeliver", http.MethodPost, check(w.Redeliver)},
+		{"/webhooks/{id}/deadletters", http.MethodGet, check(w.ListDeadLetters)},
 		{"/webhook_events", http.MethodGet, w.ListHookEvents},
+		{"/webhook_events/{event}/schemas", http.MethodGet, w.ListEventSchemas},
+		{"/webhook_events/{event}/schemas/{version}", http.MethodGet, w.InspectEventSchema},
+		{"/webhook_events/{event}/schemas/{version}/sample", http.MethodGet, w.EventSample},
 	}
 }
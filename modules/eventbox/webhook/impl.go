@@ -14,7 +14,9 @@
 package webhook
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
 	"fmt"
 	"net/url"
 	"strings"
@@ -25,6 +27,7 @@ import (
 
 	"github.com/erda-project/erda/apistructs"
 	"github.com/erda-project/erda/modules/eventbox/constant"
+	"github.com/erda-project/erda/modules/eventbox/expr"
 	"github.com/erda-project/erda/pkg/crypto/uuid"
 	"github.com/erda-project/erda/pkg/http/httpclient"
 	"github.com/erda-project/erda/pkg/jsonstore"
@@ -105,7 +108,8 @@ func (w *WebHookImpl) InspectHook(realOrg, id string) (InspectHookResponse, erro
 	return InspectHookResponse(h), nil
 }
 
-func (w *WebHookImpl) CreateHook(realOrg string, h CreateHookRequest) (CreateHookResponse, error) {
+// CreateHook 创建 webhook，secret 为空则投递时不签名。
+func (w *WebHookImpl) CreateHook(realOrg string, h CreateHookRequest, secret string) (CreateHookResponse, error) {
 	hook := Hook{}
 	hook.CreateHookRequest = h
 	if hook.Name == "" {
@@ -143,6 +147,14 @@ func (w *WebHookImpl) CreateHook(realOrg string, h CreateHookRequest) (CreateHoo
 			return CreateHookResponse(""), errors.Wrap(BadRequestErr, "bad hook url")
 		}
 	}
+	if hook.Filter != "" {
+		if _, err := expr.CompileFilter(hook.Filter); err != nil {
+			return CreateHookResponse(""), errors.Wrap(BadRequestErr, err.Error())
+		}
+	}
+	if err := checkEventVersions(hook.Events, hook.EventVersions); err != nil {
+		return CreateHookResponse(""), err
+	}
 	if realOrg != "" && !hookCheckOrg(Hook{CreateHookRequest: h}, realOrg) {
 		return CreateHookResponse(""), errors.Wrap(BadRequestErr, fmt.Sprintf("cannot operate on org: %v", hook.Org))
 	}
@@ -150,6 +162,7 @@ func (w *WebHookImpl) CreateHook(realOrg string, h CreateHookRequest) (CreateHoo
 	hook.CreatedAt = nowTimestamp()
 	hook.UpdatedAt = nowTimestamp()
 	hook.ID = genID()
+	hook.Secret = secret
 	var err error
 	defer func() {
 		if err != nil {
@@ -188,6 +201,14 @@ func (w *WebHookImpl) EditHook(realOrg, id string, e EditHookRequest) (EditHookR
 		h.Events = addEvents(h.Events, e.AddEvents)
 	}
 
+	if e.EventVersions != nil {
+		if err := checkEventVersions(h.Events, e.EventVersions); err != nil {
+			return EditHookResponse(""), err
+		}
+		h.EventVersions = e.EventVersions
+	}
+	h.EventVersions = prunedEventVersions(h.Events, h.EventVersions)
+
 	if e.URL != "" {
 		if _, err := url.Parse(e.URL); err != nil {
 			return EditHookResponse(""), errors.Wrap(BadRequestErr, "bad hook url")
@@ -195,6 +216,19 @@ func (w *WebHookImpl) EditHook(realOrg, id string, e EditHookRequest) (EditHookR
 		h.URL = e.URL
 	}
 
+	if e.Secret != "" {
+		h.Secret = e.Secret
+	}
+
+	if e.Filter != nil {
+		if *e.Filter != "" {
+			if _, err := expr.CompileFilter(*e.Filter); err != nil {
+				return EditHookResponse(""), errors.Wrap(BadRequestErr, err.Error())
+			}
+		}
+		h.Filter = *e.Filter
+	}
+
 	h.Active = e.Active
 	h.UpdatedAt = nowTimestamp()
 
@@ -221,11 +255,20 @@ func (w *WebHookImpl) PingHook(realOrg, id string) error {
 	if err != nil {
 		return errors.Wrap(InternalServerErr, err.Error())
 	}
+	body, err := json.Marshal(pingEvent)
+	if err != nil {
+		return errors.Wrap(InternalServerErr, err.Error())
+	}
 	opt := []httpclient.OpOption{}
 	if u.Scheme == "https" {
 		opt = []httpclient.OpOption{httpclient.WithHTTPS()}
 	}
-	r, err := httpclient.New(opt...).Post(u.Host).Path(u.Path).JSONBody(pingEvent).Do().DiscardBody()
+	req := httpclient.New(opt...).Post(u.Host).Path(u.Path).
+		Header("Content-Type", "application/json").Header(EventHeader, pingEvent.Event).RawBody(bytes.NewReader(body))
+	if h.Secret != "" {
+		req = req.Header(SignatureHeader, Sign(h.Secret, body))
+	}
+	r, err := req.Do().DiscardBody()
 	if err != nil {
 		return errors.Wrap(InternalServerErr, err.Error())
 	}
@@ -303,7 +346,27 @@ func genID() string {
 }
 
 func nowTimestamp() string {
-	return time.Now().In(time.FixedZone("CST", 8*3600)).Format("2006-01-02 15:04:05")
+	return formatTimestamp(time.Now())
+}
+
+var timestampLocation = time.FixedZone("CST", 8*3600)
+
+func formatTimestamp(t time.Time) string {
+	return t.In(timestampLocation).Format("2006-01-02 15:04:05")
+}
+
+func parseTimestamp(s string) (time.Time, error) {
+	return time.ParseInLocation("2006-01-02 15:04:05", s, timestampLocation)
+}
+
+const redactedSecret = "******"
+
+// redactSecret 隐藏 webhook 的签名密钥，密钥只在创建或更新时由用户提供。
+func redactSecret(h Hook) Hook {
+	if h.Secret != "" {
+		h.Secret = redactedSecret
+	}
+	return h
 }
 
 func removeEvents(origin, remove []string) []string {
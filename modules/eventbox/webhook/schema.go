@@ -0,0 +1,100 @@
+// Copyright (c) 2021 Terminus, Inc.
+//
+// This program is free software: you can use, redistribute, and/or modify
+// it under the terms of the GNU Affero General Public License, version 3
+// or later ("AGPL"), as published by the Free Software Foundation.
+//
+// This program is distributed in the hope that it will be useful, but WITHOUT
+// ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+// FITNESS FOR A PARTICULAR PURPOSE.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package webhook
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+
+	"github.com/erda-project/erda/modules/eventbox/webhook/eventschema"
+)
+
+// checkEventVersions checks the versions pinned by a hook, only the events of the hook with schemas can be pinned.
+func checkEventVersions(events []string, versions map[string]string) error {
+	if len(versions) == 0 {
+		return nil
+	}
+	schemas, err := eventschema.Default()
+	if err != nil {
+		return errors.Wrap(InternalServerErr, err.Error())
+	}
+	for event, version := range versions {
+		found := false
+		for _, e := range events {
+			found = found || e == event
+		}
+		if !found {
+			return errors.Wrap(BadRequestErr, fmt.Sprintf("pinned event %s is not in the events of hook", event))
+		}
+		if version == eventschema.Latest {
+			return errors.Wrap(BadRequestErr, fmt.Sprintf("can not pin event %s to %s", event, version))
+		}
+		if _, err := schemas.Get(event, version); err != nil {
+			return errors.Wrap(BadRequestErr, fmt.Sprintf("bad version of event %s: %v", event, err))
+		}
+	}
+	return nil
+}
+
+// prunedEventVersions returns the versions of the events which are still in events.
+func prunedEventVersions(events []string, versions map[string]string) map[string]string {
+	if versions == nil {
+		return nil
+	}
+	pruned := make(map[string]string, len(versions))
+	for _, e := range events {
+		if v, ok := versions[e]; ok {
+			pruned[e] = v
+		}
+	}
+	return pruned
+}
+
+// payload returns the body delivered to the hook,
+// the content of an event with schemas is converted to the version pinned by the hook, and validated.
+// The content breaking the contract is an error only if strict, or it is logged and delivered as it is.
+func payload(schemas *eventschema.Registry, h Hook, content []byte, strict bool) ([]byte, error) {
+	var em EventMessage
+	if err := json.Unmarshal(content, &em); err != nil || schemas == nil || !schemas.Has(em.Event) {
+		return content, nil // content of other formats has no event
+	}
+	from := em.Version
+	if from == "" {
+		from = eventschema.DefaultVersion
+	}
+	to := from
+	if v, ok := h.EventVersions[em.Event]; ok {
+		to = v
+	}
+	converted, err := schemas.Convert(em.Event, from, to, em.Content)
+	if err != nil {
+		if strict {
+			return nil, err
+		}
+		// the hook gets the version of the producer
+		logrus.Errorf("[alert] webhook delivery: hook: %s, deliver %s %s without converting, %v", h.ID, em.Event, from, err)
+		converted, to = em.Content, from
+	}
+	if err := schemas.Validate(em.Event, to, converted); err != nil {
+		logrus.Errorf("[alert] webhook delivery: hook: %s, %v", h.ID, err)
+		if strict {
+			return nil, err
+		}
+	}
+	em.Content, em.Version = converted, to
+	return json.Marshal(em)
+}
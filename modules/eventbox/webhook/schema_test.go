@@ -0,0 +1,114 @@
+// Copyright (c) 2021 Terminus, Inc.
+//
+// This program is free software: you can use, redistribute, and/or modify
+// it under the terms of the GNU Affero General Public License, version 3
+// or later ("AGPL"), as published by the Free Software Foundation.
+//
+// This program is distributed in the hope that it will be useful, but WITHOUT
+// ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+// FITNESS FOR A PARTICULAR PURPOSE.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package webhook
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/erda-project/erda/modules/eventbox/webhook/eventschema"
+)
+
+func TestCheckEventVersions(t *testing.T) {
+	events := []string{"pipeline", "runtime"}
+	assert.Nil(t, checkEventVersions(events, nil))
+	assert.Nil(t, checkEventVersions(events, map[string]string{"pipeline": "v1"}))
+	assert.NotNil(t, checkEventVersions(events, map[string]string{"pipeline": "v9"}))
+	assert.NotNil(t, checkEventVersions(events, map[string]string{"pipeline": "latest"}))
+	assert.NotNil(t, checkEventVersions(events, map[string]string{"cluster": "v1"}))
+
+	assert.Equal(t, map[string]string{"runtime": "v1"}, prunedEventVersions([]string{"runtime"}, map[string]string{"pipeline": "v1", "runtime": "v1"}))
+}
+
+func newTestSchemas(t *testing.T) *eventschema.Registry {
+	r := eventschema.New()
+	require.NoError(t, r.Add(eventschema.Schema{
+		Event: "demo", Version: "v1", Schema: json.RawMessage(`{"type": "object", "required": ["name"]}`),
+	}))
+	require.NoError(t, r.Add(eventschema.Schema{
+		Event: "demo", Version: "v2", Schema: json.RawMessage(`{"type": "object", "required": ["title"]}`),
+	}))
+	r.AddConverter("demo", "v1", "v2", func(content json.RawMessage) (json.RawMessage, error) {
+		var v struct {
+			Name string `json:"name"`
+		}
+		if err := json.Unmarshal(content, &v); err != nil {
+			return nil, err
+		}
+		return json.Marshal(map[string]string{"title": v.Name})
+	})
+	return r
+}
+
+func TestPayload(t *testing.T) {
+	schemas := newTestSchemas(t)
+	h := Hook{ID: "hook1"}
+	content := []byte(`{"event":"demo","content":{"name":"a"}}`)
+
+	body, err := payload(schemas, h, content, true)
+	require.NoError(t, err)
+	var em EventMessage
+	require.NoError(t, json.Unmarshal(body, &em))
+	assert.Equal(t, "v1", em.Version)
+	assert.JSONEq(t, `{"name":"a"}`, string(em.Content))
+
+	h.EventVersions = map[string]string{"demo": "v2"}
+	body, err = payload(schemas, h, content, true)
+	require.NoError(t, err)
+	require.NoError(t, json.Unmarshal(body, &em))
+	assert.Equal(t, "v2", em.Version)
+	assert.JSONEq(t, `{"title":"a"}`, string(em.Content))
+
+	_, err = payload(schemas, h, []byte(`{"event":"demo","version":"v2","content":{}}`), true)
+	assert.NotNil(t, err)
+	_, err = payload(schemas, Hook{CreateHookRequest: CreateHookRequest{EventVersions: map[string]string{"demo": "v1"}}}, []byte(`{"event":"demo","version":"v2","content":{"title":"a"}}`), true)
+	assert.NotNil(t, err)
+
+	// not strict, the content breaking the contract is delivered as it is
+	body, err = payload(schemas, h, []byte(`{"event":"demo","version":"v2","content":{}}`), false)
+	require.NoError(t, err)
+	require.NoError(t, json.Unmarshal(body, &em))
+	assert.Equal(t, "v2", em.Version)
+	assert.JSONEq(t, `{}`, string(em.Content))
+	body, err = payload(schemas, Hook{CreateHookRequest: CreateHookRequest{EventVersions: map[string]string{"demo": "v1"}}}, []byte(`{"event":"demo","version":"v2","content":{"title":"a"}}`), false)
+	require.NoError(t, err)
+	require.NoError(t, json.Unmarshal(body, &em))
+	assert.Equal(t, "v2", em.Version)
+	assert.JSONEq(t, `{"title":"a"}`, string(em.Content))
+
+	other := []byte(`{"event":"other","content":1}`)
+	body, err = payload(schemas, h, other, true)
+	assert.Nil(t, err)
+	assert.Equal(t, other, body)
+}
+
+func TestDeliverBreakingContract(t *testing.T) {
+	d, h := newTestDeliverer(t, "http://127.0.0.1:1", RetryPolicy{MaxAttempts: 1})
+	defer d.Close()
+	d.schemas = newTestSchemas(t)
+	d.strictSchema = true
+
+	content := []byte(`{"event":"demo","content":{}}`)
+	dl, err := d.Deliver(h.ID, content)
+	require.NoError(t, err)
+	assert.Equal(t, DeliveryDead, dl.Status)
+	assert.Equal(t, 0, dl.Attempts)
+	assert.Equal(t, string(content), dl.Request.Body)
+	dead, err := d.ListDeadLetters("1", h.ID)
+	require.NoError(t, err)
+	assert.Equal(t, 1, len(dead))
+}
@@ -0,0 +1,134 @@
+// Copyright (c) 2021 Terminus, Inc.
+//
+// This program is free software: you can use, redistribute, and/or modify
+// it under the terms of the GNU Affero General Public License, version 3
+// or later ("AGPL"), as published by the Free Software Foundation.
+//
+// This program is distributed in the hope that it will be useful, but WITHOUT
+// ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+// FITNESS FOR A PARTICULAR PURPOSE.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package query
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/olivere/elastic"
+)
+
+// LogFieldsAggregationRequest .
+type LogFieldsAggregationRequest struct {
+	LogRequest
+	Fields []string
+	Size   int
+}
+
+// LogFieldsAggregationResponse .
+type LogFieldsAggregationResponse struct {
+	Total  int64                  `json:"total"`
+	Fields []*LogFieldAggregation `json:"fields"`
+}
+
+// LogFieldAggregation .
+type LogFieldAggregation struct {
+	Field   string            `json:"field"`
+	Buckets []*LogFieldBucket `json:"buckets"`
+}
+
+// LogFieldBucket .
+type LogFieldBucket struct {
+	Key   string `json:"key"`
+	Count int64  `json:"count"`
+}
+
+func (c *ESClient) getBoolQuery(req *LogRequest) *elastic.BoolQuery {
+	if c.LogVersion == LogVersion1 {
+		return c.getBoolQueryV1(req)
+	}
+	return c.getBoolQueryV2(req)
+}
+
+func (c *ESClient) aggregateLogFields(req *LogFieldsAggregationRequest, timeout time.Duration) (*LogFieldsAggregationResponse, error) {
+	searchSource := elastic.NewSearchSource().Query(c.getBoolQuery(&req.LogRequest)).Size(0)
+	for _, field := range req.Fields {
+		searchSource.Aggregation(field, elastic.NewTermsAggregation().Field("tags."+field).Size(req.Size))
+	}
+	if req.Debug {
+		c.printSearchSource(searchSource)
+	}
+	resp, err := c.doRequest(searchSource, timeout)
+	if err != nil {
+		return nil, err
+	}
+	result := &LogFieldsAggregationResponse{Total: resp.TotalHits()}
+	for _, field := range req.Fields {
+		agg := &LogFieldAggregation{Field: field}
+		if resp.Aggregations != nil {
+			if terms, ok := resp.Aggregations.Terms(field); ok {
+				for _, b := range terms.Buckets {
+					agg.Buckets = append(agg.Buckets, &LogFieldBucket{
+						Key:   fmt.Sprint(b.Key),
+						Count: b.DocCount,
+					})
+				}
+			}
+		}
+		result.Fields = append(result.Fields, agg)
+	}
+	return result, nil
+}
+
+// AggregateLogFields counts the logs by the values of tags.
+func (p *provider) AggregateLogFields(req *LogFieldsAggregationRequest) (interface{}, error) {
+	clients := p.getESClients(req.OrgID, &req.LogRequest)
+	var results []*LogFieldsAggregationResponse
+	for _, client := range clients {
+		result, err := client.aggregateLogFields(req, p.C.Timeout)
+		if err != nil {
+			continue
+		}
+		results = append(results, result)
+	}
+	return mergeFieldsAggregation(req.Fields, req.Size, results), nil
+}
+
+func mergeFieldsAggregation(fields []string, size int, results []*LogFieldsAggregationResponse) *LogFieldsAggregationResponse {
+	resp := &LogFieldsAggregationResponse{}
+	counts := make([]map[string]int64, len(fields))
+	for i := range counts {
+		counts[i] = make(map[string]int64)
+	}
+	for _, result := range results {
+		resp.Total += result.Total
+		for i, agg := range result.Fields {
+			if i >= len(counts) {
+				break
+			}
+			for _, b := range agg.Buckets {
+				counts[i][b.Key] += b.Count
+			}
+		}
+	}
+	for i, field := range fields {
+		agg := &LogFieldAggregation{Field: field, Buckets: make([]*LogFieldBucket, 0, len(counts[i]))}
+		for key, count := range counts[i] {
+			agg.Buckets = append(agg.Buckets, &LogFieldBucket{Key: key, Count: count})
+		}
+		sort.Slice(agg.Buckets, func(x, y int) bool {
+			if agg.Buckets[x].Count != agg.Buckets[y].Count {
+				return agg.Buckets[x].Count > agg.Buckets[y].Count
+			}
+			return agg.Buckets[x].Key < agg.Buckets[y].Key
+		})
+		if len(agg.Buckets) > size {
+			agg.Buckets = agg.Buckets[:size]
+		}
+		resp.Fields = append(resp.Fields, agg)
+	}
+	return resp
+}
@@ -0,0 +1,59 @@
+// Copyright (c) 2021 Terminus, Inc.
+//
+// This program is free software: you can use, redistribute, and/or modify
+// it under the terms of the GNU Affero General Public License, version 3
+// or later ("AGPL"), as published by the Free Software Foundation.
+//
+// This program is distributed in the hope that it will be useful, but WITHOUT
+// ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+// FITNESS FOR A PARTICULAR PURPOSE.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package query
+
+import (
+	"fmt"
+)
+
+func Example_mergeFieldsAggregation() {
+	results := []*LogFieldsAggregationResponse{
+		{
+			Total: 6,
+			Fields: []*LogFieldAggregation{
+				{
+					Field: "level",
+					Buckets: []*LogFieldBucket{
+						{Key: "INFO", Count: 4},
+						{Key: "ERROR", Count: 2},
+					},
+				},
+			},
+		},
+		{
+			Total: 5,
+			Fields: []*LogFieldAggregation{
+				{
+					Field: "level",
+					Buckets: []*LogFieldBucket{
+						{Key: "ERROR", Count: 3},
+						{Key: "WARN", Count: 1},
+						{Key: "DEBUG", Count: 1},
+					},
+				},
+			},
+		},
+	}
+	resp := mergeFieldsAggregation([]string{"level"}, 3, results)
+	fmt.Println(resp.Total)
+	for _, b := range resp.Fields[0].Buckets {
+		fmt.Println(b.Key, b.Count)
+	}
+
+	// Output:
+	// 11
+	// ERROR 5
+	// INFO 4
+	// DEBUG 1
+}
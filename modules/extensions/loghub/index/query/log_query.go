@@ -24,6 +24,7 @@ import (
 	"github.com/recallsong/go-utils/encoding/jsonx"
 
 	"github.com/erda-project/erda-infra/providers/i18n"
+	"github.com/erda-project/erda/modules/extensions/loghub/index/query/logexpr"
 	"github.com/erda-project/erda/modules/monitor/core/logs"
 )
 
@@ -42,6 +43,7 @@ type LogRequest struct {
 	End         int64
 	Filters     []*Tag
 	Query       string
+	Expr        logexpr.Node
 	Debug       bool
 	Lang        i18n.LanguageCodes
 }
@@ -49,8 +51,10 @@ type LogRequest struct {
 // LogSearchRequest .
 type LogSearchRequest struct {
 	LogRequest
-	Size int64
-	Sort string
+	From      int64
+	Size      int64
+	Sort      string
+	Highlight bool
 }
 
 // LogStatisticRequest .
@@ -146,9 +150,19 @@ func (c *ESClient) getTagsBoolQuery(req *LogRequest) *elastic.BoolQuery {
 	if c.LogVersion != LogVersion1 {
 		boolQuery.Filter(elastic.NewTermQuery("tags.dice_org_id", strconv.FormatInt(req.OrgID, 10)))
 	}
+	if req.Expr != nil {
+		boolQuery.Filter(req.Expr.Query(&logexpr.Fields{Content: c.contentField(), TagPrefix: "tags."}))
+	}
 	return boolQuery
 }
 
+func (c *ESClient) contentField() string {
+	if c.LogVersion == LogVersion1 {
+		return "message"
+	}
+	return "content"
+}
+
 func (c *ESClient) getSearchSource(req *LogSearchRequest, boolQuery *elastic.BoolQuery) *elastic.SearchSource {
 	searchSource := elastic.NewSearchSource().Query(boolQuery)
 	if len(req.Sort) > 0 {
@@ -166,7 +180,13 @@ func (c *ESClient) getSearchSource(req *LogSearchRequest, boolQuery *elastic.Boo
 			searchSource.Sort(key, ascending)
 		}
 	}
+	if req.From > 0 {
+		searchSource.From(int(req.From))
+	}
 	searchSource.Size(int(req.Size))
+	if req.Highlight {
+		searchSource.Highlight(elastic.NewHighlight().Field(c.contentField()).NumOfFragments(0))
+	}
 	return searchSource
 }
 
@@ -197,6 +217,12 @@ func (c *ESClient) doSearchLogs(req *LogSearchRequest, searchSource *elastic.Sea
 	return resp.TotalHits(), resp.Hits.Hits, nil
 }
 
+func (c *ESClient) setHighlight(log *logs.Log, hit *elastic.SearchHit) {
+	if fragments := hit.Highlight[c.contentField()]; len(fragments) > 0 {
+		log.Highlight = map[string][]string{"content": fragments}
+	}
+}
+
 func (c *ESClient) setModule(log *logs.Log) {
 	if log.Tags != nil {
 		if log.Tags["origin"] == "sls" {
@@ -220,15 +246,30 @@ func (c *ESClient) setModule(log *logs.Log) {
 // SearchLogs .
 func (p *provider) SearchLogs(req *LogSearchRequest) (interface{}, error) {
 	clients := p.getESClients(req.OrgID, &req.LogRequest)
+	// the page of merged result can only be taken after merging the leading logs of all clients
+	creq := req
+	if len(clients) > 1 && req.From > 0 {
+		creq = &LogSearchRequest{}
+		*creq = *req
+		creq.From, creq.Size = 0, req.From+req.Size
+	}
 	var results []*LogQueryResponse
 	for _, client := range clients {
-		result, err := client.searchLogs(req, p.C.Timeout)
+		result, err := client.searchLogs(creq, p.C.Timeout)
 		if err != nil {
 			continue
 		}
 		results = append(results, result)
 	}
-	return mergeLogSearch(int(req.Size), results), nil
+	resp := mergeLogSearch(int(creq.Size), results)
+	if creq != req {
+		if int64(len(resp.Data)) > req.From {
+			resp.Data = resp.Data[req.From:]
+		} else {
+			resp.Data = nil
+		}
+	}
+	return resp, nil
 }
 
 func mergeLogSearch(limit int, results []*LogQueryResponse) *LogQueryResponse {
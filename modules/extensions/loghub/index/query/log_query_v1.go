@@ -99,6 +99,7 @@ func (c *ESClient) searchLogsV1(req *LogSearchRequest, timeout time.Duration) (*
 		}
 		log := logv1.ToLog()
 		c.setModule(log)
+		c.setHighlight(log, hit)
 		resp.Data = append(resp.Data, log)
 	}
 	return resp, nil
@@ -60,6 +60,7 @@ func (c *ESClient) searchLogsV2(req *LogSearchRequest, timeout time.Duration) (*
 			continue
 		}
 		c.setModule(&log)
+		c.setHighlight(&log, hit)
 		log.Timestamp = log.Timestamp / int64(time.Millisecond)
 		resp.Data = append(resp.Data, &log)
 	}
@@ -0,0 +1,114 @@
+// Copyright (c) 2021 Terminus, Inc.
+//
+// This program is free software: you can use, redistribute, and/or modify
+// it under the terms of the GNU Affero General Public License, version 3
+// or later ("AGPL"), as published by the Free Software Foundation.
+//
+// This program is distributed in the hope that it will be useful, but WITHOUT
+// ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+// FITNESS FOR A PARTICULAR PURPOSE.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+// Package logexpr parses the log search expression and translates it into elasticsearch queries.
+// See README.md for the syntax.
+package logexpr
+
+import (
+	"strings"
+
+	"github.com/olivere/elastic"
+)
+
+// TermKind .
+type TermKind int
+
+// term kinds
+const (
+	Keyword TermKind = iota
+	Phrase
+	Regexp
+)
+
+// Fields tells how the fields of expression are mapped to the fields of index.
+type Fields struct {
+	Content   string
+	TagPrefix string
+}
+
+// Node is a node of parsed expression.
+type Node interface {
+	Query(fields *Fields) elastic.Query
+}
+
+// Term matches a keyword, a phrase or a regular expression, on the content if Field is empty.
+type Term struct {
+	Field string
+	Kind  TermKind
+	Value string
+}
+
+// And .
+type And []Node
+
+// Or .
+type Or []Node
+
+// Not .
+type Not struct {
+	Node Node
+}
+
+// Query .
+func (t *Term) Query(fields *Fields) elastic.Query {
+	if len(t.Field) <= 0 || t.Field == "content" || t.Field == fields.Content {
+		switch t.Kind {
+		case Phrase:
+			return elastic.NewMatchPhraseQuery(fields.Content, t.Value)
+		case Regexp:
+			return elastic.NewRegexpQuery(fields.Content, t.Value)
+		}
+		if isWildcard(t.Value) {
+			return elastic.NewWildcardQuery(fields.Content, strings.ToLower(t.Value))
+		}
+		return elastic.NewMatchQuery(fields.Content, t.Value).Operator("and")
+	}
+	field := fields.TagPrefix + t.Field
+	switch t.Kind {
+	case Regexp:
+		return elastic.NewRegexpQuery(field, t.Value)
+	case Keyword:
+		if isWildcard(t.Value) {
+			return elastic.NewWildcardQuery(field, t.Value)
+		}
+	}
+	return elastic.NewTermQuery(field, t.Value)
+}
+
+// Query .
+func (a And) Query(fields *Fields) elastic.Query {
+	q := elastic.NewBoolQuery()
+	for _, n := range a {
+		q.Must(n.Query(fields))
+	}
+	return q
+}
+
+// Query .
+func (o Or) Query(fields *Fields) elastic.Query {
+	q := elastic.NewBoolQuery().MinimumNumberShouldMatch(1)
+	for _, n := range o {
+		q.Should(n.Query(fields))
+	}
+	return q
+}
+
+// Query .
+func (n *Not) Query(fields *Fields) elastic.Query {
+	return elastic.NewBoolQuery().MustNot(n.Node.Query(fields))
+}
+
+func isWildcard(s string) bool {
+	return strings.ContainsAny(s, "*?")
+}
@@ -0,0 +1,282 @@
+// Copyright (c) 2021 Terminus, Inc.
+//
+// This program is free software: you can use, redistribute, and/or modify
+// it under the terms of the GNU Affero General Public License, version 3
+// or later ("AGPL"), as published by the Free Software Foundation.
+//
+// This program is distributed in the hope that it will be useful, but WITHOUT
+// ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+// FITNESS FOR A PARTICULAR PURPOSE.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package logexpr
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+type tokenType int
+
+const (
+	tokenEOF tokenType = iota
+	tokenLParen
+	tokenRParen
+	tokenAnd
+	tokenOr
+	tokenNot
+	tokenField
+	tokenWord
+	tokenPhrase
+	tokenRegexp
+)
+
+type token struct {
+	typ tokenType
+	val string
+	pos int
+}
+
+var fieldRegexp = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_.\-]*$`)
+
+func tokenize(s string) ([]*token, error) {
+	var tokens []*token
+	rs := []rune(s)
+	for i := 0; i < len(rs); {
+		c := rs[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case c == '(':
+			tokens = append(tokens, &token{typ: tokenLParen, pos: i})
+			i++
+		case c == ')':
+			tokens = append(tokens, &token{typ: tokenRParen, pos: i})
+			i++
+		case (c == '-' || c == '!') && !afterField(tokens):
+			tokens = append(tokens, &token{typ: tokenNot, pos: i})
+			i++
+		case c == '"' || c == '/':
+			val, n, err := readQuoted(rs[i:], c)
+			if err != nil {
+				return nil, fmt.Errorf("%s at position %d", err, i)
+			}
+			typ := tokenPhrase
+			if c == '/' {
+				typ = tokenRegexp
+				if _, err := regexp.Compile(val); err != nil {
+					return nil, fmt.Errorf("invalid regexp /%s/ at position %d: %s", val, i, err)
+				}
+			}
+			tokens = append(tokens, &token{typ: typ, val: val, pos: i})
+			i += n
+		default:
+			start := i
+			for i < len(rs) && !strings.ContainsRune(" \t\r\n()\"", rs[i]) {
+				if rs[i] == ':' && !afterField(tokens) && fieldRegexp.MatchString(string(rs[start:i])) {
+					break
+				}
+				i++
+			}
+			word := string(rs[start:i])
+			if i < len(rs) && rs[i] == ':' {
+				tokens = append(tokens, &token{typ: tokenField, val: word, pos: start})
+				i++
+				continue
+			}
+			switch word {
+			case "AND", "&&":
+				tokens = append(tokens, &token{typ: tokenAnd, pos: start})
+			case "OR", "||":
+				tokens = append(tokens, &token{typ: tokenOr, pos: start})
+			case "NOT":
+				tokens = append(tokens, &token{typ: tokenNot, pos: start})
+			default:
+				tokens = append(tokens, &token{typ: tokenWord, val: word, pos: start})
+			}
+		}
+	}
+	return append(tokens, &token{typ: tokenEOF, pos: len(rs)}), nil
+}
+
+func afterField(tokens []*token) bool {
+	return len(tokens) > 0 && tokens[len(tokens)-1].typ == tokenField
+}
+
+// readQuoted reads the value quoted by q, and returns the unescaped value and the count of runes read.
+func readQuoted(rs []rune, q rune) (string, int, error) {
+	var sb strings.Builder
+	for i := 1; i < len(rs); i++ {
+		c := rs[i]
+		if c == '\\' && i+1 < len(rs) {
+			next := rs[i+1]
+			if next == q || (q == '"' && next == '\\') {
+				sb.WriteRune(next)
+				i++
+				continue
+			}
+			sb.WriteRune(c)
+			continue
+		}
+		if c == q {
+			if q == '/' && sb.Len() <= 0 {
+				return "", 0, fmt.Errorf("empty regexp")
+			}
+			return sb.String(), i + 1, nil
+		}
+		sb.WriteRune(c)
+	}
+	return "", 0, fmt.Errorf("unterminated %c", q)
+}
+
+type parser struct {
+	tokens []*token
+	idx    int
+}
+
+// Parse parses the expression, it returns nil if the expression is empty.
+func Parse(expr string) (Node, error) {
+	tokens, err := tokenize(expr)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{tokens: tokens}
+	if p.peek().typ == tokenEOF {
+		return nil, nil
+	}
+	node, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if t := p.peek(); t.typ != tokenEOF {
+		return nil, fmt.Errorf("unexpected %s at position %d", t, t.pos)
+	}
+	return node, nil
+}
+
+func (p *parser) peek() *token { return p.tokens[p.idx] }
+func (p *parser) next() *token {
+	t := p.tokens[p.idx]
+	if t.typ != tokenEOF {
+		p.idx++
+	}
+	return t
+}
+
+func (p *parser) parseOr() (Node, error) {
+	node, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	or := Or{node}
+	for p.peek().typ == tokenOr {
+		p.next()
+		node, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		or = append(or, node)
+	}
+	if len(or) == 1 {
+		return or[0], nil
+	}
+	return or, nil
+}
+
+func (p *parser) parseAnd() (Node, error) {
+	node, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	and := And{node}
+	for {
+		switch p.peek().typ {
+		case tokenAnd:
+			p.next()
+		case tokenEOF, tokenOr, tokenRParen:
+			if len(and) == 1 {
+				return and[0], nil
+			}
+			return and, nil
+		}
+		node, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		and = append(and, node)
+	}
+}
+
+func (p *parser) parseUnary() (Node, error) {
+	if p.peek().typ == tokenNot {
+		p.next()
+		node, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return &Not{Node: node}, nil
+	}
+	return p.parsePrimary()
+}
+
+func (p *parser) parsePrimary() (Node, error) {
+	t := p.next()
+	switch t.typ {
+	case tokenLParen:
+		node, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if r := p.next(); r.typ != tokenRParen {
+			return nil, fmt.Errorf("missing ) for ( at position %d", t.pos)
+		}
+		return node, nil
+	case tokenField:
+		v := p.next()
+		term, ok := newTerm(v)
+		if !ok {
+			return nil, fmt.Errorf("missing value of field %q at position %d", t.val, t.pos)
+		}
+		term.Field = t.val
+		return term, nil
+	}
+	if term, ok := newTerm(t); ok {
+		return term, nil
+	}
+	return nil, fmt.Errorf("unexpected %s at position %d", t, t.pos)
+}
+
+func newTerm(t *token) (*Term, bool) {
+	switch t.typ {
+	case tokenWord:
+		return &Term{Kind: Keyword, Value: t.val}, true
+	case tokenPhrase:
+		return &Term{Kind: Phrase, Value: t.val}, true
+	case tokenRegexp:
+		return &Term{Kind: Regexp, Value: t.val}, true
+	}
+	return nil, false
+}
+
+func (t *token) String() string {
+	switch t.typ {
+	case tokenEOF:
+		return "end of expression"
+	case tokenLParen:
+		return "("
+	case tokenRParen:
+		return ")"
+	case tokenAnd:
+		return "AND"
+	case tokenOr:
+		return "OR"
+	case tokenNot:
+		return "NOT"
+	case tokenField:
+		return t.val + ":"
+	}
+	return fmt.Sprintf("%q", t.val)
+}
@@ -0,0 +1,111 @@
+// Copyright (c) 2021 Terminus, Inc.
+//
+// This program is free software: you can use, redistribute, and/or modify
+// it under the terms of the GNU Affero General Public License, version 3
+// or later ("AGPL"), as published by the Free Software Foundation.
+//
+// This program is distributed in the hope that it will be useful, but WITHOUT
+// ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+// FITNESS FOR A PARTICULAR PURPOSE.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package logexpr
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		expr string
+		want Node
+	}{
+		{"", nil},
+		{"error", &Term{Kind: Keyword, Value: "error"}},
+		{`"connection refused"`, &Term{Kind: Phrase, Value: "connection refused"}},
+		{`/time(out|d out)/`, &Term{Kind: Regexp, Value: "time(out|d out)"}},
+		{`/a\/b/`, &Term{Kind: Regexp, Value: "a/b"}},
+		{`"say \"hi\""`, &Term{Kind: Phrase, Value: `say "hi"`}},
+		{"level:ERROR", &Term{Field: "level", Kind: Keyword, Value: "ERROR"}},
+		{`dice_service_name:"order service"`, &Term{Field: "dice_service_name", Kind: Phrase, Value: "order service"}},
+		{`request-id:/^abc.*/`, &Term{Field: "request-id", Kind: Regexp, Value: "^abc.*"}},
+		{"foo-bar a:b:c", And{
+			&Term{Kind: Keyword, Value: "foo-bar"},
+			&Term{Field: "a", Kind: Keyword, Value: "b:c"},
+		}},
+		{"error timeout", And{
+			&Term{Kind: Keyword, Value: "error"},
+			&Term{Kind: Keyword, Value: "timeout"},
+		}},
+		{"a OR b AND c", Or{
+			&Term{Kind: Keyword, Value: "a"},
+			And{&Term{Kind: Keyword, Value: "b"}, &Term{Kind: Keyword, Value: "c"}},
+		}},
+		{"(a || b) && !c", And{
+			Or{&Term{Kind: Keyword, Value: "a"}, &Term{Kind: Keyword, Value: "b"}},
+			&Not{Node: &Term{Kind: Keyword, Value: "c"}},
+		}},
+		{"error -stream:stderr", And{
+			&Term{Kind: Keyword, Value: "error"},
+			&Not{Node: &Term{Field: "stream", Kind: Keyword, Value: "stderr"}},
+		}},
+		{"code:-1", &Term{Field: "code", Kind: Keyword, Value: "-1"}},
+		{"NOT NOT a", &Not{Node: &Not{Node: &Term{Kind: Keyword, Value: "a"}}}},
+	}
+	for _, tt := range tests {
+		got, err := Parse(tt.expr)
+		if err != nil {
+			t.Errorf("Parse(%q) got error: %s", tt.expr, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Parse(%q) = %#v, want %#v", tt.expr, got, tt.want)
+		}
+	}
+}
+
+func TestParseError(t *testing.T) {
+	for _, expr := range []string{
+		`"unterminated`,
+		`/unterminated`,
+		`//`,
+		`/[a/`,
+		`(a OR b`,
+		`a OR`,
+		`a )`,
+		`level:`,
+		`level:(a)`,
+		`NOT`,
+	} {
+		if _, err := Parse(expr); err == nil {
+			t.Errorf("Parse(%q) want error, got nil", expr)
+		}
+	}
+}
+
+func TestQuery(t *testing.T) {
+	node, err := Parse(`error* "read timeout" -stream:stderr OR /panic:.*/ service:order-*`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	source, err := node.Query(&Fields{Content: "content", TagPrefix: "tags."}).Source()
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, _ := json.Marshal(source)
+	want := `{"bool":{"minimum_should_match":"1","should":[` +
+		`{"bool":{"must":[` +
+		`{"wildcard":{"content":{"wildcard":"error*"}}},` +
+		`{"match_phrase":{"content":{"query":"read timeout"}}},` +
+		`{"bool":{"must_not":{"term":{"tags.stream":"stderr"}}}}]}},` +
+		`{"bool":{"must":[` +
+		`{"regexp":{"content":{"value":"panic:.*"}}},` +
+		`{"wildcard":{"tags.service":{"wildcard":"order-*"}}}]}}]}}`
+	if string(got) != want {
+		t.Errorf("got %s\nwant %s", got, want)
+	}
+}
@@ -21,17 +21,20 @@ import (
 
 	"github.com/erda-project/erda-infra/modcom/api"
 	"github.com/erda-project/erda-infra/providers/httpserver"
+	"github.com/erda-project/erda/modules/extensions/loghub/index/query/logexpr"
 )
 
 func (p *provider) intRoutes(routes httpserver.Router) error {
 	// 项目 + env 日志查询
 	routes.GET("/api/micro_service/:addon/logs/statistic/histogram", p.logStatistic)
 	routes.GET("/api/micro_service/:addon/logs/search", p.logSearch)
+	routes.GET("/api/micro_service/:addon/logs/statistic/fields", p.logFieldsAggregation)
 	routes.GET("/api/micro_service/logs/tags/tree", p.logMSTagsTree)
 
 	// 企业日志查询
 	routes.GET("/api/org/logs/statistic/histogram", p.logStatistic)
 	routes.GET("/api/org/logs/search", p.logSearch)
+	routes.GET("/api/org/logs/statistic/fields", p.logFieldsAggregation)
 	routes.GET("/api/org/logs/tags/tree", p.orgLogTagsTree)
 	return nil
 }
@@ -58,6 +61,9 @@ func (p *provider) buildLogFilters(r *http.Request) []*Tag {
 	return filters
 }
 
+// maxResultWindow is the default index.max_result_window of elasticsearch.
+const maxResultWindow = 10000
+
 func (p *provider) checkTime(start, end int64) error {
 	if end <= start {
 		return fmt.Errorf("end must after start")
@@ -72,6 +78,7 @@ func (p *provider) logStatistic(r *http.Request, params struct {
 	Start       int64  `query:"start" validate:"gte=1"`
 	End         int64  `query:"end" validate:"gte=1"`
 	Query       string `query:"query"`
+	Expr        string `query:"expr"`
 	Points      int64  `query:"points"`
 	Interval    int64  `query:"interval"`
 	Debug       bool   `query:"debug"`
@@ -86,6 +93,10 @@ func (p *provider) logStatistic(r *http.Request, params struct {
 	if params.Points <= 0 {
 		params.Points = 60
 	}
+	expr, err := logexpr.Parse(params.Expr)
+	if err != nil {
+		return api.Errors.InvalidParameter(fmt.Errorf("invalid expr: %s", err))
+	}
 	filters := p.buildLogFilters(r)
 	data, err := p.StatisticLogs(&LogStatisticRequest{
 		LogRequest: LogRequest{
@@ -96,6 +107,7 @@ func (p *provider) logStatistic(r *http.Request, params struct {
 			End:         params.End,
 			Filters:     filters,
 			Query:       params.Query,
+			Expr:        expr,
 			Debug:       params.Debug,
 			Lang:        api.Language(r),
 		},
@@ -111,9 +123,12 @@ func (p *provider) logStatistic(r *http.Request, params struct {
 func (p *provider) logSearch(r *http.Request, params struct {
 	Start       int64  `query:"start" validate:"gte=1"`
 	End         int64  `query:"end" validate:"gte=1"`
+	From        int64  `query:"from"`
 	Size        int64  `query:"size"`
 	Query       string `query:"query"`
+	Expr        string `query:"expr"`
 	Sort        string `query:"sort"`
+	Highlight   bool   `query:"highlight"`
 	Debug       bool   `query:"debug"`
 	Addon       string `param:"addon"`
 	ClusterName string `query:"clusterName"`
@@ -126,10 +141,17 @@ func (p *provider) logSearch(r *http.Request, params struct {
 	if params.Size <= 0 {
 		params.Size = 50
 	}
+	if params.From < 0 || params.From+params.Size > maxResultWindow {
+		return api.Errors.InvalidParameter(fmt.Errorf("from + size must be less than or equal to %d", maxResultWindow))
+	}
 	err = p.checkTime(params.Start, params.End)
 	if err != nil {
 		return api.Errors.InvalidParameter(err)
 	}
+	expr, err := logexpr.Parse(params.Expr)
+	if err != nil {
+		return api.Errors.InvalidParameter(fmt.Errorf("invalid expr: %s", err))
+	}
 	filters := p.buildLogFilters(r)
 	logs, err := p.SearchLogs(&LogSearchRequest{
 		LogRequest: LogRequest{
@@ -140,11 +162,14 @@ func (p *provider) logSearch(r *http.Request, params struct {
 			End:         params.End,
 			Filters:     filters,
 			Query:       params.Query,
+			Expr:        expr,
 			Debug:       params.Debug,
 			Lang:        api.Language(r),
 		},
-		Size: params.Size,
-		Sort: params.Sort,
+		From:      params.From,
+		Size:      params.Size,
+		Sort:      params.Sort,
+		Highlight: params.Highlight,
 	})
 	if err != nil {
 		return api.Errors.Internal(err)
@@ -152,6 +177,62 @@ func (p *provider) logSearch(r *http.Request, params struct {
 	return api.Success(logs)
 }
 
+func (p *provider) logFieldsAggregation(r *http.Request, params struct {
+	Start       int64  `query:"start" validate:"gte=1"`
+	End         int64  `query:"end" validate:"gte=1"`
+	Fields      string `query:"fields" validate:"required"`
+	Size        int    `query:"size"`
+	Query       string `query:"query"`
+	Expr        string `query:"expr"`
+	Debug       bool   `query:"debug"`
+	Addon       string `param:"addon"`
+	ClusterName string `query:"clusterName"`
+}) interface{} {
+	orgID := api.OrgID(r)
+	orgid, err := strconv.ParseInt(orgID, 10, 64)
+	if err != nil {
+		return api.Errors.InvalidParameter("invalid Org-ID")
+	}
+	if params.Size <= 0 {
+		params.Size = 10
+	}
+	err = p.checkTime(params.Start, params.End)
+	if err != nil {
+		return api.Errors.InvalidParameter(err)
+	}
+	expr, err := logexpr.Parse(params.Expr)
+	if err != nil {
+		return api.Errors.InvalidParameter(fmt.Errorf("invalid expr: %s", err))
+	}
+	var fields []string
+	for _, field := range strings.Split(params.Fields, ",") {
+		field = strings.TrimSpace(field)
+		if len(field) > 0 {
+			fields = append(fields, field)
+		}
+	}
+	data, err := p.AggregateLogFields(&LogFieldsAggregationRequest{
+		LogRequest: LogRequest{
+			OrgID:       orgid,
+			ClusterName: params.ClusterName,
+			Addon:       params.Addon,
+			Start:       params.Start,
+			End:         params.End,
+			Filters:     p.buildLogFilters(r),
+			Query:       params.Query,
+			Expr:        expr,
+			Debug:       params.Debug,
+			Lang:        api.Language(r),
+		},
+		Fields: fields,
+		Size:   params.Size,
+	})
+	if err != nil {
+		return api.Errors.Internal(err)
+	}
+	return api.Success(data)
+}
+
 func (p *provider) logMSTagsTree(r *http.Request) interface{} {
 	return api.Success(p.GetTagsTree("micro_service", api.Language(r)))
 }
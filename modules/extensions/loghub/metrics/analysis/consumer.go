@@ -80,26 +80,16 @@ func (p *provider) invoke(key []byte, value []byte, topic *string, timestamp tim
 
 	ps := (pv.(*processors.Processors)).Find("", scopeID, log.Tags)
 	var errs errorx.Errors
-	for _, processor := range ps {
-		name, fields, err := processor.Process(log.Content)
+	for _, rule := range ps {
+		name, fields, err := rule.Process(log.Content)
 		if err != nil {
 			// invalid processor or not match content
 			continue
 		}
-		for k, v := range fields {
-			if s, ok := v.(string); ok {
-				if _, ok := log.Tags[k]; !ok {
-					// 直接在 Tags 上修改，因为这里 len(ps) == 1，不会混淆
-					// 后面大盘支持 field 过滤了，再调整
-					log.Tags[k] = s
-				}
-			}
-		}
-		metric := &metrics.Metric{
-			Name:      name,
-			Timestamp: log.Timestamp,
-			Tags:      log.Tags,
-			Fields:    fields,
+		metric := rule.Options.Metric(name, log.Timestamp, log.Tags, fields)
+		if rule.Options.MetricType != processors.MetricTypeGauge {
+			p.aggregator.Add(rule.Options, metric)
+			continue
 		}
 		err = p.output.Write(metric)
 		if err != nil {
@@ -108,3 +98,11 @@ func (p *provider) invoke(key []byte, value []byte, topic *string, timestamp tim
 	}
 	return errs.MaybeUnwrap()
 }
+
+func (p *provider) flushAggregator() {
+	for _, metric := range p.aggregator.Flush(time.Now().UnixNano()) {
+		if err := p.output.Write(metric); err != nil {
+			p.L.Errorf("fail to write aggregated log metric: %s", err)
+		}
+	}
+}
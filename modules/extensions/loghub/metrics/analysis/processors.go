@@ -18,6 +18,7 @@ import (
 	"github.com/recallsong/go-utils/reflectx"
 
 	"github.com/erda-project/erda/modules/extensions/loghub/metrics/analysis/processors"
+	_ "github.com/erda-project/erda/modules/extensions/loghub/metrics/analysis/processors/grok"  //
 	_ "github.com/erda-project/erda/modules/extensions/loghub/metrics/analysis/processors/regex" //
 )
 
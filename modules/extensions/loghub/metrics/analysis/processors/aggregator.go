@@ -0,0 +1,166 @@
+// Copyright (c) 2021 Terminus, Inc.
+//
+// This program is free software: you can use, redistribute, and/or modify
+// it under the terms of the GNU Affero General Public License, version 3
+// or later ("AGPL"), as published by the Free Software Foundation.
+//
+// This program is distributed in the hope that it will be useful, but WITHOUT
+// ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+// FITNESS FOR A PARTICULAR PURPOSE.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package processors
+
+import (
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/erda-project/erda/modules/monitor/core/metrics"
+)
+
+// AggregatedTags are kept in the aggregated metrics besides the tags of rule. The other tags, such as the tags of
+// containers and the string fields of logs, are dropped, so that the number of series is bounded by the tags of rule.
+var AggregatedTags = []string{"_meta", "_metric_scope", "_metric_scope_id", "org_name", "cluster_name"}
+
+// Aggregator aggregates the metrics of counter and histogram rules until flushed.
+type Aggregator struct {
+	lock   sync.Mutex
+	series map[string]*series
+}
+
+type series struct {
+	opts    *Options
+	name    string
+	tags    map[string]string
+	count   int64
+	sums    map[string]float64
+	mins    map[string]float64
+	maxs    map[string]float64
+	buckets map[string][]int64
+}
+
+// NewAggregator .
+func NewAggregator() *Aggregator {
+	return &Aggregator{series: make(map[string]*series)}
+}
+
+// Add .
+func (a *Aggregator) Add(opts *Options, m *metrics.Metric) {
+	tags := make(map[string]string, len(opts.Tags)+len(AggregatedTags))
+	for _, keys := range [][]string{AggregatedTags, opts.Tags} {
+		for _, k := range keys {
+			if v, ok := m.Tags[k]; ok {
+				tags[k] = v
+			}
+		}
+	}
+	key := seriesKey(opts.MetricType, m.Name, tags)
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	s, ok := a.series[key]
+	if !ok {
+		s = &series{
+			opts:    opts,
+			name:    m.Name,
+			tags:    tags,
+			sums:    make(map[string]float64),
+			mins:    make(map[string]float64),
+			maxs:    make(map[string]float64),
+			buckets: make(map[string][]int64),
+		}
+		a.series[key] = s
+	}
+	s.count++
+	for k, v := range m.Fields {
+		val, ok := toFloat(v)
+		if !ok {
+			continue
+		}
+		s.sums[k] += val
+		if opts.MetricType != MetricTypeHistogram {
+			continue
+		}
+		if min, ok := s.mins[k]; !ok || val < min {
+			s.mins[k] = val
+		}
+		if max, ok := s.maxs[k]; !ok || val > max {
+			s.maxs[k] = val
+		}
+		counts, ok := s.buckets[k]
+		if !ok {
+			counts = make([]int64, len(opts.Buckets)+1)
+			s.buckets[k] = counts
+		}
+		idx := sort.SearchFloat64s(opts.Buckets, val)
+		counts[idx]++
+	}
+}
+
+// Flush returns the aggregated metrics and resets the aggregator.
+func (a *Aggregator) Flush(timestamp int64) []*metrics.Metric {
+	a.lock.Lock()
+	all := a.series
+	a.series = make(map[string]*series)
+	a.lock.Unlock()
+
+	list := make([]*metrics.Metric, 0, len(all))
+	for _, s := range all {
+		m := &metrics.Metric{
+			Name:      s.name,
+			Timestamp: timestamp,
+			Tags:      s.tags,
+			Fields:    map[string]interface{}{"count": s.count},
+		}
+		for k, sum := range s.sums {
+			if s.opts.MetricType == MetricTypeCounter {
+				m.Fields[k] = sum
+				continue
+			}
+			m.Fields[k+"_sum"] = sum
+			m.Fields[k+"_min"] = s.mins[k]
+			m.Fields[k+"_max"] = s.maxs[k]
+			var cumulative int64
+			for i, bound := range s.opts.bucketNames() {
+				cumulative += s.buckets[k][i]
+				m.Fields[k+"_bucket_le_"+bound] = cumulative
+			}
+		}
+		list = append(list, m)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list
+}
+
+func seriesKey(typ, name string, tags map[string]string) string {
+	keys := make([]string, 0, len(tags))
+	for k := range tags {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var sb strings.Builder
+	sb.WriteString(name)
+	sb.WriteString("/")
+	sb.WriteString(typ)
+	for _, k := range keys {
+		sb.WriteString("\x00")
+		sb.WriteString(k)
+		sb.WriteString("=")
+		sb.WriteString(tags[k])
+	}
+	return sb.String()
+}
+
+func toFloat(v interface{}) (float64, bool) {
+	switch val := v.(type) {
+	case float64:
+		return val, true
+	case int64:
+		return float64(val), true
+	case int:
+		return float64(val), true
+	}
+	return 0, false
+}
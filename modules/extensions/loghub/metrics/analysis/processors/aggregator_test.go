@@ -0,0 +1,100 @@
+// Copyright (c) 2021 Terminus, Inc.
+//
+// This program is free software: you can use, redistribute, and/or modify
+// it under the terms of the GNU Affero General Public License, version 3
+// or later ("AGPL"), as published by the Free Software Foundation.
+//
+// This program is distributed in the hope that it will be useful, but WITHOUT
+// ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+// FITNESS FOR A PARTICULAR PURPOSE.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package processors
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestOptionsMetric(t *testing.T) {
+	opts, err := ParseOptions([]byte(`{"tags":["status"]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tags := map[string]string{"dice_service_name": "order"}
+	m := opts.Metric("m", 1, tags, map[string]interface{}{
+		"status":  int64(500),
+		"url":     "/api/orders",
+		"latency": 12.5,
+	})
+	if want := map[string]string{"dice_service_name": "order", "status": "500", "url": "/api/orders"}; !reflect.DeepEqual(m.Tags, want) {
+		t.Errorf("Tags = %v, want %v", m.Tags, want)
+	}
+	if want := map[string]interface{}{"url": "/api/orders", "latency": 12.5}; !reflect.DeepEqual(m.Fields, want) {
+		t.Errorf("Fields = %v, want %v", m.Fields, want)
+	}
+	if len(tags) != 1 {
+		t.Errorf("tags of log are modified: %v", tags)
+	}
+}
+
+func TestParseOptionsError(t *testing.T) {
+	for _, cfg := range []string{
+		`{"metric_type":"summary"}`,
+		`{"metric_type":"histogram","buckets":[10,5]}`,
+	} {
+		if _, err := ParseOptions([]byte(cfg)); err == nil {
+			t.Errorf("ParseOptions(%s) want error, got nil", cfg)
+		}
+	}
+}
+
+func TestAggregator(t *testing.T) {
+	counter, _ := ParseOptions([]byte(`{"metric_type":"counter","tags":["status"]}`))
+	histogram, _ := ParseOptions([]byte(`{"metric_type":"histogram","buckets":[10,100]}`))
+	a := NewAggregator()
+	for i, status := range []int64{200, 200, 500} {
+		tags := map[string]string{"_metric_scope": "org", "pod_name": fmt.Sprint("pod-", i)}
+		a.Add(counter, counter.Metric("requests", 0, tags, map[string]interface{}{"status": status, "bytes": int64(10), "url": fmt.Sprint("/api/", i)}))
+	}
+	for _, latency := range []float64{5, 10, 50, 500} {
+		a.Add(histogram, histogram.Metric("latency", 0, nil, map[string]interface{}{"latency": latency}))
+	}
+
+	list := a.Flush(100)
+	if len(list) != 3 {
+		t.Fatalf("Flush() got %d metrics, want 3", len(list))
+	}
+	got := make(map[string]map[string]interface{})
+	for _, m := range list {
+		if m.Timestamp != 100 {
+			t.Errorf("Timestamp = %d, want 100", m.Timestamp)
+		}
+		got[m.Name+"/"+m.Tags["status"]] = m.Fields
+		if m.Name == "requests" && !reflect.DeepEqual(m.Tags, map[string]string{"_metric_scope": "org", "status": m.Tags["status"]}) {
+			t.Errorf("Tags = %v, want the tags of rule and scope only", m.Tags)
+		}
+	}
+	want := map[string]map[string]interface{}{
+		"requests/200": {"count": int64(2), "bytes": float64(20)},
+		"requests/500": {"count": int64(1), "bytes": float64(10)},
+		"latency/": {
+			"count":                 int64(4),
+			"latency_sum":           float64(565),
+			"latency_min":           float64(5),
+			"latency_max":           float64(500),
+			"latency_bucket_le_10":  int64(2),
+			"latency_bucket_le_100": int64(3),
+			"latency_bucket_le_inf": int64(4),
+		},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Flush() = %v, want %v", got, want)
+	}
+	if list := a.Flush(200); len(list) != 0 {
+		t.Errorf("Flush() after flushed got %d metrics, want 0", len(list))
+	}
+}
@@ -0,0 +1,173 @@
+// Copyright (c) 2021 Terminus, Inc.
+//
+// This program is free software: you can use, redistribute, and/or modify
+// it under the terms of the GNU Affero General Public License, version 3
+// or later ("AGPL"), as published by the Free Software Foundation.
+//
+// This program is distributed in the hope that it will be useful, but WITHOUT
+// ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+// FITNESS FOR A PARTICULAR PURPOSE.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package grok
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/erda-project/erda/modules/extensions/loghub/metrics/analysis/processors"
+	"github.com/erda-project/erda/modules/extensions/loghub/metrics/analysis/processors/convert"
+	"github.com/erda-project/erda/modules/monitor/core/metrics"
+)
+
+type config struct {
+	Pattern  string                 `json:"pattern"`
+	Patterns map[string]string      `json:"patterns"`
+	Keys     []*metrics.FieldDefine `json:"keys"`
+}
+
+type processor struct {
+	metric   string
+	reg      *regexp.Regexp
+	groups   []int
+	keys     []*metrics.FieldDefine
+	converts []func(text string) (interface{}, error)
+}
+
+// %{SYNTAX}, %{SYNTAX:field} or %{SYNTAX:field:type}
+var grokRegexp = regexp.MustCompile(`%\{(\w+)(?::([\w.\-\[\]@]+))?(?::(\w+))?\}`)
+
+const maxDepth = 16
+
+type compiler struct {
+	patterns map[string]string
+	keys     []*metrics.FieldDefine
+}
+
+func (c *compiler) expand(pattern string, depth int) (string, error) {
+	if depth > maxDepth {
+		return "", fmt.Errorf("grok patterns are nested too deeply, maybe recursive")
+	}
+	var err error
+	expanded := grokRegexp.ReplaceAllStringFunc(pattern, func(s string) string {
+		if err != nil {
+			return ""
+		}
+		parts := grokRegexp.FindStringSubmatch(s)
+		def, ok := c.patterns[parts[1]]
+		if !ok {
+			err = fmt.Errorf("grok pattern %q not exist", parts[1])
+			return ""
+		}
+		var sub string
+		sub, err = c.expand(def, depth+1)
+		if len(parts[2]) <= 0 {
+			return "(?:" + sub + ")"
+		}
+		group := "_g" + strconv.Itoa(len(c.keys))
+		c.keys = append(c.keys, &metrics.FieldDefine{Key: parts[2], Type: parts[3]})
+		return "(?P<" + group + ">" + sub + ")"
+	})
+	return expanded, err
+}
+
+// New .
+func New(metric string, cfg []byte) (processors.Processor, error) {
+	var c config
+	err := json.Unmarshal(cfg, &c)
+	if err != nil {
+		return nil, fmt.Errorf("fail to unmarshal grok config: %s", err)
+	}
+	if len(c.Pattern) <= 0 {
+		return nil, fmt.Errorf("grok pattern must not be empty")
+	}
+	patterns := make(map[string]string, len(Patterns)+len(c.Patterns))
+	for k, v := range Patterns {
+		patterns[k] = v
+	}
+	for k, v := range c.Patterns {
+		patterns[k] = v
+	}
+	comp := &compiler{patterns: patterns}
+	expr, err := comp.expand(c.Pattern, 0)
+	if err != nil {
+		return nil, err
+	}
+	reg, err := regexp.Compile(expr)
+	if err != nil {
+		return nil, fmt.Errorf("fail to compile grok pattern: %s", err)
+	}
+	if len(comp.keys) <= 0 {
+		return nil, fmt.Errorf("grok pattern must capture at least one field")
+	}
+	types := make(map[string]*metrics.FieldDefine, len(c.Keys))
+	for _, key := range c.Keys {
+		types[key.Key] = key
+	}
+	p := &processor{
+		metric: metric,
+		reg:    reg,
+	}
+	keyset := make(map[string]bool)
+	for i, name := range reg.SubexpNames() {
+		if !strings.HasPrefix(name, "_g") {
+			continue
+		}
+		idx, _ := strconv.Atoi(name[2:])
+		key := comp.keys[idx]
+		if keyset[key.Key] {
+			return nil, fmt.Errorf("grok field %q is captured more than once", key.Key)
+		}
+		keyset[key.Key] = true
+		if def, ok := types[key.Key]; ok {
+			if len(def.Type) > 0 {
+				key.Type = def.Type
+			}
+			key.Name, key.Unit = def.Name, def.Unit
+		}
+		if len(key.Type) <= 0 {
+			key.Type = "string"
+		}
+		p.groups = append(p.groups, i)
+		p.keys = append(p.keys, key)
+		p.converts = append(p.converts, convert.Converter(key.Type))
+	}
+	return p, nil
+}
+
+// ErrNotMatch .
+var ErrNotMatch = fmt.Errorf("not match grok pattern")
+
+// Process .
+func (p *processor) Process(content string) (string, map[string]interface{}, error) {
+	match := p.reg.FindStringSubmatchIndex(content)
+	if match == nil {
+		return "", nil, ErrNotMatch
+	}
+	fields := make(map[string]interface{}, len(p.keys))
+	for i, group := range p.groups {
+		start, end := match[2*group], match[2*group+1]
+		if start < 0 {
+			continue // optional group not matched
+		}
+		val, err := p.converts[i](content[start:end])
+		if err != nil {
+			return "", nil, ErrNotMatch
+		}
+		fields[p.keys[i].Key] = val
+	}
+	return p.metric, fields, nil
+}
+
+func (p *processor) Keys() []*metrics.FieldDefine {
+	return p.keys
+}
+
+func init() {
+	processors.RegisterProcessor("grok", New)
+}
@@ -0,0 +1,104 @@
+// Copyright (c) 2021 Terminus, Inc.
+//
+// This program is free software: you can use, redistribute, and/or modify
+// it under the terms of the GNU Affero General Public License, version 3
+// or later ("AGPL"), as published by the Free Software Foundation.
+//
+// This program is distributed in the hope that it will be useful, but WITHOUT
+// ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+// FITNESS FOR A PARTICULAR PURPOSE.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package grok
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func newProcessor(t *testing.T, cfg map[string]interface{}) (*processor, error) {
+	byts, err := json.Marshal(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, err := New("test_metric", byts)
+	if err != nil {
+		return nil, err
+	}
+	return p.(*processor), nil
+}
+
+func TestProcess(t *testing.T) {
+	p, err := newProcessor(t, map[string]interface{}{
+		"pattern": `%{IP:client} %{HTTPMETHOD:method} %{URIPATHPARAM:url} %{INT:status} %{NUMBER:latency:float}(?: %{WORD:cache})?`,
+		"keys": []map[string]interface{}{
+			{"key": "status", "type": "int"},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var keys []string
+	for _, k := range p.Keys() {
+		keys = append(keys, k.Key+":"+k.Type)
+	}
+	if want := []string{"client:string", "method:string", "url:string", "status:int", "latency:float", "cache:string"}; !reflect.DeepEqual(keys, want) {
+		t.Errorf("Keys() = %v, want %v", keys, want)
+	}
+
+	name, fields, err := p.Process("10.0.0.1 GET /api/orders?page=1 200 12.5")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]interface{}{
+		"client":  "10.0.0.1",
+		"method":  "GET",
+		"url":     "/api/orders?page=1",
+		"status":  int64(200),
+		"latency": 12.5,
+	}
+	if name != "test_metric" || !reflect.DeepEqual(fields, want) {
+		t.Errorf("Process() = %s %v, want test_metric %v", name, fields, want)
+	}
+
+	if _, _, err := p.Process("10.0.0.1 GET /api/orders 200 slow"); err != ErrNotMatch {
+		t.Errorf("Process() got error %v, want ErrNotMatch", err)
+	}
+}
+
+func TestCustomPatterns(t *testing.T) {
+	p, err := newProcessor(t, map[string]interface{}{
+		"pattern": `order %{ORDER_ID:order} paid %{NUMBER:amount:number}`,
+		"patterns": map[string]string{
+			"ORDER_ID": `ORD-%{POSINT}`,
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, fields, err := p.Process("[INFO] order ORD-1024 paid 99.9")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fields["order"] != "ORD-1024" || fields["amount"] != 99.9 {
+		t.Errorf("Process() = %v", fields)
+	}
+}
+
+func TestNewError(t *testing.T) {
+	for _, cfg := range []map[string]interface{}{
+		{"pattern": ""},
+		{"pattern": "%{NOT_EXIST:a}"},
+		{"pattern": "%{INT}"},
+		{"pattern": "%{INT:a} %{INT:a}"},
+		{"pattern": "%{LOOP:a}", "patterns": map[string]string{"LOOP": "%{LOOP}"}},
+		{"pattern": "(%{INT:a}"},
+	} {
+		if _, err := newProcessor(t, cfg); err == nil {
+			t.Errorf("New(%v) want error, got nil", cfg)
+		}
+	}
+}
@@ -0,0 +1,152 @@
+// Copyright (c) 2021 Terminus, Inc.
+//
+// This program is free software: you can use, redistribute, and/or modify
+// it under the terms of the GNU Affero General Public License, version 3
+// or later ("AGPL"), as published by the Free Software Foundation.
+//
+// This program is distributed in the hope that it will be useful, but WITHOUT
+// ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+// FITNESS FOR A PARTICULAR PURPOSE.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package processors
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+
+	"github.com/erda-project/erda/modules/monitor/core/metrics"
+)
+
+// metric types
+const (
+	MetricTypeGauge     = "gauge"
+	MetricTypeCounter   = "counter"
+	MetricTypeHistogram = "histogram"
+)
+
+// DefaultBuckets is the upper bounds of histogram buckets if not specified.
+var DefaultBuckets = []float64{5, 10, 25, 50, 100, 250, 500, 1000, 2500, 5000, 10000}
+
+// Options are the options shared by all processor types, they are parsed from the same config of processor.
+type Options struct {
+	// Tags are the keys extracted as tags instead of fields, counters and histograms are aggregated by them and AggregatedTags.
+	Tags []string `json:"tags"`
+	// MetricType is gauge by default, which emits every matched log as a metric.
+	// A counter counts the matched logs and sums up the numeric fields in the flush interval,
+	// and a histogram emits the count, sum, min, max and bucket counts of numeric fields in the flush interval.
+	MetricType string `json:"metric_type"`
+	// Buckets are the upper bounds of histogram buckets.
+	Buckets []float64 `json:"buckets"`
+}
+
+// ParseOptions .
+func ParseOptions(cfg []byte) (*Options, error) {
+	opts := &Options{}
+	if len(cfg) > 0 {
+		if err := json.Unmarshal(cfg, opts); err != nil {
+			return nil, fmt.Errorf("fail to unmarshal processor options: %s", err)
+		}
+	}
+	switch opts.MetricType {
+	case "":
+		opts.MetricType = MetricTypeGauge
+	case MetricTypeGauge, MetricTypeCounter:
+	case MetricTypeHistogram:
+		if len(opts.Buckets) <= 0 {
+			opts.Buckets = DefaultBuckets
+		}
+		if !sort.Float64sAreSorted(opts.Buckets) {
+			return nil, fmt.Errorf("histogram buckets must be in ascending order")
+		}
+	default:
+		return nil, fmt.Errorf("invalid metric_type %q", opts.MetricType)
+	}
+	return opts, nil
+}
+
+// IsTag returns whether the key is extracted as a tag.
+func (o *Options) IsTag(key string) bool {
+	for _, t := range o.Tags {
+		if t == key {
+			return true
+		}
+	}
+	return false
+}
+
+// Metric converts the result of processor into a metric, the keys in Options.Tags and the string fields are set to tags.
+func (o *Options) Metric(name string, timestamp int64, tags map[string]string, fields map[string]interface{}) *metrics.Metric {
+	m := &metrics.Metric{
+		Name:      name,
+		Timestamp: timestamp,
+		Tags:      make(map[string]string, len(tags)+len(fields)),
+		Fields:    make(map[string]interface{}, len(fields)),
+	}
+	for k, v := range tags {
+		m.Tags[k] = v
+	}
+	for k, v := range fields {
+		if o.IsTag(k) {
+			m.Tags[k] = fmt.Sprint(v)
+			continue
+		}
+		if s, ok := v.(string); ok {
+			if _, ok := m.Tags[k]; !ok {
+				m.Tags[k] = s
+			}
+		}
+		m.Fields[k] = v
+	}
+	return m
+}
+
+// FieldDefines returns the definitions of fields of the emitted metrics, by the keys of processor.
+func (o *Options) FieldDefines(keys []*metrics.FieldDefine) []*metrics.FieldDefine {
+	if o.MetricType == MetricTypeGauge {
+		var list []*metrics.FieldDefine
+		for _, k := range keys {
+			if !o.IsTag(k.Key) {
+				list = append(list, k)
+			}
+		}
+		return list
+	}
+	list := []*metrics.FieldDefine{{Key: "count", Type: "number", Name: "count"}}
+	for _, k := range keys {
+		if o.IsTag(k.Key) || !isNumberType(k.Type) {
+			continue
+		}
+		if o.MetricType == MetricTypeCounter {
+			list = append(list, &metrics.FieldDefine{Key: k.Key, Type: "number", Name: k.Name, Unit: k.Unit})
+			continue
+		}
+		for _, suffix := range []string{"sum", "min", "max"} {
+			list = append(list, &metrics.FieldDefine{Key: k.Key + "_" + suffix, Type: "number", Name: k.Name + " " + suffix, Unit: k.Unit})
+		}
+		for _, bound := range o.bucketNames() {
+			list = append(list, &metrics.FieldDefine{Key: k.Key + "_bucket_le_" + bound, Type: "number", Name: k.Name + " <= " + bound})
+		}
+	}
+	return list
+}
+
+func (o *Options) bucketNames() []string {
+	names := make([]string, 0, len(o.Buckets)+1)
+	for _, b := range o.Buckets {
+		names = append(names, strconv.FormatFloat(b, 'f', -1, 64))
+	}
+	return append(names, "inf")
+}
+
+func isNumberType(typ string) bool {
+	switch typ {
+	case "number", "int", "float":
+		return true
+	}
+	return false
+}
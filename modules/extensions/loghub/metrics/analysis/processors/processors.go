@@ -25,9 +25,11 @@ type Processor interface {
 	Keys() []*metrics.FieldDefine
 }
 
-type processor struct {
+// Rule is a processor with the tags of logs it applies to.
+type Rule struct {
 	Processor
-	tags map[string]string
+	Options *Options
+	tags    map[string]string
 }
 
 var processors = make(map[string]func(metric string, cfg []byte) (Processor, error))
@@ -48,13 +50,13 @@ func NewProcessor(name, typ string, cfg []byte) (Processor, error) {
 
 // Processors .
 type Processors struct {
-	ps map[string][]*processor
+	ps map[string][]*Rule
 }
 
 // New .
 func New() *Processors {
 	return &Processors{
-		ps: make(map[string][]*processor),
+		ps: make(map[string][]*Rule),
 	}
 }
 
@@ -68,17 +70,22 @@ func (ps *Processors) Add(key string, tags map[string]string, name, typ string,
 	if err != nil {
 		return err
 	}
-	ps.ps[key] = append(ps.ps[key], &processor{
-		tags:      tags,
+	opts, err := ParseOptions(config)
+	if err != nil {
+		return err
+	}
+	ps.ps[key] = append(ps.ps[key], &Rule{
 		Processor: p,
+		Options:   opts,
+		tags:      tags,
 	})
 	return nil
 }
 
 // Find .
-func (ps *Processors) Find(name, key string, tags map[string]string) []Processor {
+func (ps *Processors) Find(name, key string, tags map[string]string) []*Rule {
 	procs := ps.ps[key]
-	var list []Processor
+	var list []*Rule
 loop:
 	for _, p := range procs {
 		if len(p.tags) > 0 {
@@ -25,6 +25,7 @@ import (
 	writer "github.com/erda-project/erda-infra/pkg/parallel-writer"
 	"github.com/erda-project/erda-infra/providers/kafka"
 	"github.com/erda-project/erda-infra/providers/mysql"
+	"github.com/erda-project/erda/modules/extensions/loghub/metrics/analysis/processors"
 	"github.com/erda-project/erda/modules/extensions/loghub/metrics/rules/db"
 )
 
@@ -48,6 +49,7 @@ type config struct {
 		ScopeID        string        `file:"scope_id"`
 		ScopeIDKey     string        `file:"scope_id_key"`
 		ReloadInterval time.Duration `file:"reload_interval" default:"3m"`
+		FlushInterval  time.Duration `file:"flush_interval" default:"1m"`
 	} `file:"processors"`
 	Input  kafka.ConsumerConfig `file:"input"`
 	Output struct {
@@ -68,7 +70,10 @@ type provider struct {
 	kafka      kafka.Interface
 	output     writer.Writer
 	processors atomic.Value
+	aggregator *processors.Aggregator
 	db         *db.DB
+	closeCh    chan struct{}
+	flushDone  chan struct{}
 }
 
 func (p *provider) Init(ctx servicehub.Context) error {
@@ -80,6 +85,8 @@ func (p *provider) Init(ctx servicehub.Context) error {
 		return fmt.Errorf("fail to create kafka producer: %s", err)
 	}
 	p.output = w
+	p.aggregator = processors.NewAggregator()
+	p.closeCh = make(chan struct{})
 	return nil
 }
 
@@ -98,10 +105,33 @@ func (p *provider) Start() error {
 			time.Sleep(p.C.Processors.ReloadInterval)
 		}
 	}()
+	p.flushDone = make(chan struct{})
+	go func() {
+		defer close(p.flushDone)
+		tick := time.NewTicker(p.C.Processors.FlushInterval)
+		defer tick.Stop()
+		for {
+			select {
+			case <-tick.C:
+				p.flushAggregator()
+			case <-p.closeCh:
+				// write the metrics aggregated since the last flush
+				p.flushAggregator()
+				return
+			}
+		}
+	}()
 	return nil
 }
 
-func (p *provider) Close() error { return nil }
+// Close stops flushing after the final flush, and closes the output.
+func (p *provider) Close() error {
+	close(p.closeCh)
+	if p.flushDone != nil {
+		<-p.flushDone
+	}
+	return p.output.Close()
+}
 
 func init() {
 	servicehub.RegisterProvider("logs-metrics-analysis", &define{})
@@ -148,11 +148,20 @@ func (p *provider) convertToMetricMeta(cfg *db.LogMetricConfig) (*metrics.Metric
 			byts, _ := json.Marshal(p.Config)
 			proc, err := processors.NewProcessor(cfg.Metric, p.Type, byts)
 			if err == nil {
+				opts, err := processors.ParseOptions(byts)
+				if err != nil {
+					return nil, err
+				}
 				keys := proc.Keys()
 				for _, k := range keys {
 					if len(k.Name) <= 0 {
 						k.Name = k.Key
 					}
+					if opts.IsTag(k.Key) {
+						m.Tags[k.Key] = &metrics.TagDefine{Key: k.Key, Name: k.Name}
+					}
+				}
+				for _, k := range opts.FieldDefines(keys) {
 					m.Fields[k.Key] = k
 				}
 			}
@@ -23,6 +23,7 @@ import (
 	"github.com/erda-project/erda-infra/modcom/api"
 	"github.com/erda-project/erda-infra/providers/httpserver"
 	"github.com/erda-project/erda/modules/extensions/loghub/metrics/analysis/processors"
+	_ "github.com/erda-project/erda/modules/extensions/loghub/metrics/analysis/processors/grok"  //
 	_ "github.com/erda-project/erda/modules/extensions/loghub/metrics/analysis/processors/regex" //
 	"github.com/erda-project/erda/modules/monitor/core/metrics"
 )
@@ -37,6 +38,7 @@ func (p *provider) intRoutes(routes httpserver.Router) error {
 	routes.PUT("/api/logs/metric/:scope/rules/:id/state", p.enableRule)
 	routes.DELETE("/api/logs/metric/:scope/rules/:id", p.deleteRule)
 	routes.POST("/api/logs/metric/:scope/rules/test", p.testRule)
+	routes.POST("/api/logs/metric/:scope/rules/test/samples", p.testRuleSamples)
 	return nil
 }
 
@@ -156,6 +158,20 @@ func (p *provider) checkLogConfig(c *LogMetricConfig) interface{} {
 		if p == nil || len(p.Type) <= 0 || len(p.Config) <= 0 {
 			return api.Errors.MissingParameter(fmt.Sprintf("invalid processors[%d]", i))
 		}
+		byts, err := json.Marshal(p.Config)
+		if err != nil {
+			return api.Errors.InvalidParameter(fmt.Sprintf("invalid processors[%d]", i))
+		}
+		if _, err := processors.ParseOptions(byts); err != nil {
+			return api.Errors.InvalidParameter(fmt.Sprintf("invalid processors[%d]: %s", i, err))
+		}
+		if p.Type != "regexp" {
+			// the keys of other processors are defined by pattern
+			if _, err := processors.NewProcessor(c.Metric, p.Type, byts); err != nil {
+				return api.Errors.InvalidParameter(fmt.Sprintf("invalid processors[%d]: %s", i, err))
+			}
+			continue
+		}
 		keys := p.Config["keys"]
 		if keys == nil {
 			return api.Errors.MissingParameter(fmt.Sprintf("keys must not empty in processors[%d]", i))
@@ -288,3 +304,71 @@ func (p *provider) testRule(params struct {
 	}
 	return api.Success(nil)
 }
+
+// testRuleSamples applies the processors to the sample lines, and returns the metrics they produce.
+// The metrics of counter and histogram are aggregated over all samples.
+func (p *provider) testRuleSamples(params struct {
+	Lines      []string           `json:"lines"`
+	Tags       map[string]string  `json:"tags"`
+	MetricName string             `json:"metric_name"`
+	Processors []*ProcessorConfig `json:"processors"`
+}) interface{} {
+	type result struct {
+		Metrics   []*metrics.Metric `json:"metrics"`
+		Matched   int               `json:"matched"`
+		Unmatched int               `json:"unmatched"`
+	}
+	if len(params.Lines) <= 0 {
+		return api.Errors.MissingParameter("lines must not be empty")
+	}
+	if len(params.MetricName) <= 0 {
+		params.MetricName = "log_metric_test"
+	}
+	type rule struct {
+		proc processors.Processor
+		opts *processors.Options
+	}
+	var rules []*rule
+	for _, p := range params.Processors {
+		byts, err := json.Marshal(p.Config)
+		if err != nil {
+			return api.Errors.InvalidParameter("invalid processor", err.Error())
+		}
+		proc, err := processors.NewProcessor(params.MetricName, p.Type, byts)
+		if err != nil {
+			return api.Errors.InvalidParameter("fail to create processor", err.Error())
+		}
+		opts, err := processors.ParseOptions(byts)
+		if err != nil {
+			return api.Errors.InvalidParameter("invalid processor options", err.Error())
+		}
+		rules = append(rules, &rule{proc: proc, opts: opts})
+	}
+	now := time.Now().UnixNano()
+	res := &result{Metrics: []*metrics.Metric{}}
+	aggregator := processors.NewAggregator()
+	// every processor is applied to each line, the same as the logs are analyzed
+	for _, line := range params.Lines {
+		matched := false
+		for _, r := range rules {
+			name, fields, err := r.proc.Process(line)
+			if err != nil {
+				continue
+			}
+			matched = true
+			metric := r.opts.Metric(name, now, params.Tags, fields)
+			if r.opts.MetricType != processors.MetricTypeGauge {
+				aggregator.Add(r.opts, metric)
+				continue
+			}
+			res.Metrics = append(res.Metrics, metric)
+		}
+		if matched {
+			res.Matched++
+		} else {
+			res.Unmatched++
+		}
+	}
+	res.Metrics = append(res.Metrics, aggregator.Flush(now)...)
+	return api.Success(res)
+}
@@ -21,6 +21,7 @@ import (
 
 	"github.com/erda-project/erda/modules/hepa/apipolicy"
 	"github.com/erda-project/erda/modules/hepa/config"
+	gw "github.com/erda-project/erda/modules/hepa/gateway/dto"
 	"github.com/erda-project/erda/modules/hepa/kong"
 	kongDto "github.com/erda-project/erda/modules/hepa/kong/dto"
 	"github.com/erda-project/erda/modules/hepa/repository/orm"
@@ -106,14 +107,23 @@ proxy_intercept_errors on;
 			res.KongPolicyChange = true
 		}
 	}
-	newPlugin, err := policy.touchPluginIfNeed(zone.Id, builtinPlugins, "spot-collector", map[string]interface{}{
+	spotConfig := map[string]interface{}{
 		"send_port":          config.ServerConf.SpotSendPort,
 		"addon_name":         config.ServerConf.SpotAddonName,
 		"metric_name":        config.ServerConf.SpotMetricName,
 		"tags_header_prefix": config.ServerConf.SpotTagsHeaderPrefix,
 		"host_ip_key":        config.ServerConf.SpotHostIpKey,
 		"instance_key":       config.ServerConf.SpotInstanceKey,
-	}, kongAdapter)
+	}
+	// 配置了延时分布的桶上限时由插件统计各桶的累计请求数, 用于计算延时分位值
+	buckets, err := gw.ParseLatencyBuckets(config.ServerConf.SpotLatencyBuckets)
+	if err != nil {
+		return res, err
+	}
+	if len(buckets) > 0 {
+		spotConfig["latency_buckets"] = buckets
+	}
+	newPlugin, err := policy.touchPluginIfNeed(zone.Id, builtinPlugins, "spot-collector", spotConfig, kongAdapter)
 	if err != nil {
 		return res, err
 	}
@@ -191,6 +201,11 @@ func (policy Policy) touchPluginIfNeed(zoneId string, builtinPlugins []string, p
 
 }
 
+// RenderPlugins 内置策略只调整 nginx 的重试参数, 网关后端使用自身的默认值, 没有需要渲染的插件
+func (policy Policy) RenderPlugins(apipolicy.PolicyDto) ([]apipolicy.PluginConfig, error) {
+	return nil, nil
+}
+
 func init() {
 	err := apipolicy.RegisterPolicyEngine("built-in", &Policy{})
 	if err != nil {
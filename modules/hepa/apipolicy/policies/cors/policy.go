@@ -16,6 +16,7 @@ package cors
 import (
 	"encoding/json"
 	"fmt"
+	"strings"
 
 	"github.com/erda-project/erda/modules/hepa/apipolicy"
 
@@ -158,6 +159,32 @@ more_set_headers 'Access-Control-Allow-Headers: %s';
 	return res, nil
 }
 
+// RenderPlugins 渲染为 cors 插件, 回显请求中来源和请求头的 nginx 变量对应通配
+func (policy Policy) RenderPlugins(dto apipolicy.PolicyDto) ([]apipolicy.PluginConfig, error) {
+	policyDto, ok := dto.(*PolicyDto)
+	if !ok {
+		return nil, errors.Errorf("invalid config:%+v", dto)
+	}
+	plugin := apipolicy.PluginConfig{Name: "cors"}
+	if !policyDto.Switch {
+		return []apipolicy.PluginConfig{plugin}, nil
+	}
+	wildcard := func(value string) string {
+		if strings.HasPrefix(value, "$") {
+			return "*"
+		}
+		return value
+	}
+	plugin.Config = map[string]interface{}{
+		"origins":     wildcard(policyDto.Origin),
+		"methods":     policyDto.Methods,
+		"headers":     wildcard(policyDto.Headers),
+		"credentials": policyDto.Credentials,
+		"max_age":     policyDto.MaxAge,
+	}
+	return []apipolicy.PluginConfig{plugin}, nil
+}
+
 func init() {
 	err := apipolicy.RegisterPolicyEngine("cors", &Policy{})
 	if err != nil {
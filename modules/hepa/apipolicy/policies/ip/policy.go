@@ -150,6 +150,32 @@ func (policy Policy) ParseConfig(dto apipolicy.PolicyDto, ctx map[string]interfa
 	return res, nil
 }
 
+// RenderPlugins 渲染为 ip-restriction 插件, 按 IP 的限速和连接数限制、以及从请求头获取 IP 不支持
+func (policy Policy) RenderPlugins(dto apipolicy.PolicyDto) ([]apipolicy.PluginConfig, error) {
+	policyDto, ok := dto.(*PolicyDto)
+	if !ok {
+		return nil, errors.Errorf("invalid config:%+v", dto)
+	}
+	plugin := apipolicy.PluginConfig{Name: "ip-restriction"}
+	if !policyDto.Switch {
+		return []apipolicy.PluginConfig{plugin}, nil
+	}
+	if policyDto.IpSource != REMOTE_IP {
+		return nil, errors.Errorf("ip source %s not supported by the gateway", policyDto.IpSource)
+	}
+	if policyDto.IpRate != nil || policyDto.IpMaxConnections > 0 {
+		return nil, errors.New("ip rate and connection limits not supported by the gateway")
+	}
+	list := "whitelist"
+	if policyDto.IpAclType == ACL_BLACK {
+		list = "blacklist"
+	}
+	plugin.Config = map[string]interface{}{
+		list: policyDto.IpAclList,
+	}
+	return []apipolicy.PluginConfig{plugin}, nil
+}
+
 func init() {
 	err := apipolicy.RegisterPolicyEngine("safety-ip", &Policy{})
 	if err != nil {
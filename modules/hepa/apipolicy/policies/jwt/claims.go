@@ -0,0 +1,319 @@
+// Copyright (c) 2021 Terminus, Inc.
+//
+// This program is free software: you can use, redistribute, and/or modify
+// it under the terms of the GNU Affero General Public License, version 3
+// or later ("AGPL"), as published by the Free Software Foundation.
+//
+// This program is distributed in the hope that it will be useful, but WITHOUT
+// ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+// FITNESS FOR A PARTICULAR PURPOSE.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package jwt
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	kongDto "github.com/erda-project/erda/modules/hepa/kong/dto"
+)
+
+// claimsCode 在 jwt 插件验签之后执行, 读取 jwt 插件保存的 token 解析载荷,
+// kong 2.x 的沙箱中不能 require cjson, 因此自带一个简单的 JSON 解析
+const claimsCode = `
+local escapes = {b = "\b", f = "\f", n = "\n", r = "\r", t = "\t"}
+
+local function utf8char(code)
+  if code < 0x80 then
+    return string.char(code)
+  elseif code < 0x800 then
+    return string.char(0xC0 + math.floor(code / 0x40), 0x80 + code % 0x40)
+  elseif code < 0x10000 then
+    return string.char(0xE0 + math.floor(code / 0x1000), 0x80 + math.floor(code / 0x40) % 0x40, 0x80 + code % 0x40)
+  end
+  return string.char(0xF0 + math.floor(code / 0x40000), 0x80 + math.floor(code / 0x1000) % 0x40,
+    0x80 + math.floor(code / 0x40) % 0x40, 0x80 + code % 0x40)
+end
+
+local function decode(s)
+  local pos = 1
+  local function skip()
+    pos = s:find("[^ \t\r\n]", pos) or #s + 1
+  end
+  local function str()
+    local parts = {}
+    pos = pos + 1
+    while true do
+      local c = s:sub(pos, pos)
+      if c == "" then
+        error("unterminated string")
+      elseif c == '"' then
+        pos = pos + 1
+        return table.concat(parts)
+      elseif c == "\\" then
+        local e = s:sub(pos + 1, pos + 1)
+        if e == "u" then
+          local code = tonumber(s:sub(pos + 2, pos + 5), 16)
+          if not code then
+            error("invalid escape")
+          end
+          pos = pos + 6
+          if code >= 0xD800 and code < 0xDC00 and s:sub(pos, pos + 1) == "\\u" then
+            local low = tonumber(s:sub(pos + 2, pos + 5), 16)
+            if low and low >= 0xDC00 and low < 0xE000 then
+              code = 0x10000 + (code - 0xD800) * 0x400 + (low - 0xDC00)
+              pos = pos + 6
+            end
+          end
+          parts[#parts + 1] = utf8char(code)
+        else
+          parts[#parts + 1] = escapes[e] or e
+          pos = pos + 2
+        end
+      else
+        local stop = s:find('["\\]', pos) or #s + 1
+        parts[#parts + 1] = s:sub(pos, stop - 1)
+        pos = stop
+      end
+    end
+  end
+  local value
+  value = function()
+    skip()
+    local c = s:sub(pos, pos)
+    if c == "{" then
+      local obj = {}
+      pos = pos + 1
+      skip()
+      if s:sub(pos, pos) == "}" then
+        pos = pos + 1
+        return obj
+      end
+      while true do
+        skip()
+        if s:sub(pos, pos) ~= '"' then
+          error("invalid object")
+        end
+        local key = str()
+        skip()
+        if s:sub(pos, pos) ~= ":" then
+          error("invalid object")
+        end
+        pos = pos + 1
+        obj[key] = value()
+        skip()
+        c = s:sub(pos, pos)
+        pos = pos + 1
+        if c == "}" then
+          return obj
+        elseif c ~= "," then
+          error("invalid object")
+        end
+      end
+    elseif c == "[" then
+      local arr = {}
+      pos = pos + 1
+      skip()
+      if s:sub(pos, pos) == "]" then
+        pos = pos + 1
+        return arr
+      end
+      while true do
+        arr[#arr + 1] = value()
+        skip()
+        c = s:sub(pos, pos)
+        pos = pos + 1
+        if c == "]" then
+          return arr
+        elseif c ~= "," then
+          error("invalid array")
+        end
+      end
+    elseif c == '"' then
+      return str()
+    end
+    local literal = s:match("^[%w%.%+%-]+", pos)
+    if not literal then
+      error("invalid value")
+    end
+    pos = pos + #literal
+    if literal == "true" then
+      return true
+    elseif literal == "false" then
+      return false
+    elseif literal == "null" then
+      return nil
+    end
+    local number = tonumber(literal)
+    if not number then
+      error("invalid value")
+    end
+    return number
+  end
+  local ok, res = pcall(value)
+  if ok then
+    return res
+  end
+end
+
+local function text(value)
+  local kind = type(value)
+  if kind == "string" then
+    return value
+  elseif kind == "number" or kind == "boolean" then
+    return tostring(value)
+  elseif kind == "table" then
+    local items = {}
+    for _, item in ipairs(value) do
+      local s = text(item)
+      if s then
+        items[#items + 1] = s
+      end
+    end
+    if #items > 0 then
+      return table.concat(items, ",")
+    end
+  end
+end
+
+local function contains(value, expected)
+  if type(value) == "table" then
+    for _, item in ipairs(value) do
+      if text(item) == expected then
+        return true
+      end
+    end
+    return false
+  end
+  return text(value) == expected
+end
+
+local function deny()
+  return kong.response.exit(conf.status, conf.message)
+end
+
+local function check()
+  for _, header in pairs(conf.headers) do
+    kong.service.request.clear_header(header)
+  end
+  local token = kong.ctx.shared.authenticated_jwt_token or ngx.ctx.authenticated_jwt_token
+  if type(token) ~= "string" then
+    return deny()
+  end
+  local payload = token:match("^[^.]*%.([^.]*)%.")
+  if not payload then
+    return deny()
+  end
+  payload = payload:gsub("%-", "+"):gsub("_", "/")
+  payload = payload .. string.rep("=", (4 - #payload % 4) % 4)
+  local claims = decode(ngx.decode_base64(payload) or "")
+  if type(claims) ~= "table" then
+    return deny()
+  end
+  if conf.issuer and claims.iss ~= conf.issuer then
+    return deny()
+  end
+  if #conf.audiences > 0 then
+    local matched = false
+    for _, aud in ipairs(conf.audiences) do
+      if contains(claims.aud, aud) then
+        matched = true
+        break
+      end
+    end
+    if not matched then
+      return deny()
+    end
+  end
+  for name, expected in pairs(conf.required) do
+    if claims[name] == nil or (expected ~= "" and not contains(claims[name], expected)) then
+      return deny()
+    end
+  end
+  for name, header in pairs(conf.headers) do
+    local value = text(claims[name])
+    if value then
+      kong.service.request.set_header(header, value)
+    end
+  end
+end
+`
+
+// luaString 转义为 lua 的字符串字面量, 控制字符使用十进制转义
+func luaString(value string) string {
+	var b strings.Builder
+	b.WriteByte('"')
+	for i := 0; i < len(value); i++ {
+		c := value[i]
+		switch {
+		case c == '"' || c == '\\':
+			b.WriteByte('\\')
+			b.WriteByte(c)
+		case c < 0x20 || c == 0x7f:
+			fmt.Fprintf(&b, "\\%03d", c)
+		default:
+			b.WriteByte(c)
+		}
+	}
+	b.WriteByte('"')
+	return b.String()
+}
+
+func luaMap(values map[string]string) string {
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	items := make([]string, 0, len(names))
+	for _, name := range names {
+		items = append(items, fmt.Sprintf("[%s] = %s", luaString(name), luaString(values[name])))
+	}
+	return "{" + strings.Join(items, ", ") + "}"
+}
+
+// claimsFunction 渲染 post-function 插件执行的 lua 代码,
+// kong 2.x 缓存代码返回的函数在每个请求执行, 0.x 每个请求执行整段代码
+func claimsFunction(dto *PolicyDto, cached bool) string {
+	var b strings.Builder
+	b.WriteString("local conf = {\n")
+	if dto.Issuer != "" {
+		fmt.Fprintf(&b, "  issuer = %s,\n", luaString(dto.Issuer))
+	}
+	audiences := make([]string, 0, len(dto.Audiences))
+	for _, aud := range dto.Audiences {
+		audiences = append(audiences, luaString(aud))
+	}
+	fmt.Fprintf(&b, "  audiences = {%s},\n", strings.Join(audiences, ", "))
+	fmt.Fprintf(&b, "  required = %s,\n", luaMap(dto.RequiredClaims))
+	fmt.Fprintf(&b, "  headers = %s,\n", luaMap(dto.ClaimsToHeaders))
+	fmt.Fprintf(&b, "  status = %d,\n", dto.errStatus())
+	fmt.Fprintf(&b, "  message = %s,\n", luaString(dto.errMsg()))
+	b.WriteString("}\n")
+	b.WriteString(claimsCode)
+	if cached {
+		b.WriteString("\nreturn check\n")
+	} else {
+		b.WriteString("\nreturn check()\n")
+	}
+	return b.String()
+}
+
+func (policy Policy) buildClaimsPluginReq(dto *PolicyDto, version string) *kongDto.KongPluginReqDto {
+	disable := false
+	req := &kongDto.KongPluginReqDto{
+		Name:    CLAIMS_PLUGIN_NAME,
+		Config:  map[string]interface{}{},
+		Enabled: &disable,
+	}
+	// kong 2.x 按阶段配置代码, functions 为 0.x 的配置
+	if strings.HasPrefix(version, "0.") {
+		req.Config["functions"] = []string{claimsFunction(dto, false)}
+	} else {
+		req.Config["access"] = []string{claimsFunction(dto, true)}
+	}
+	return req
+}
@@ -0,0 +1,256 @@
+// Copyright (c) 2021 Terminus, Inc.
+//
+// This program is free software: you can use, redistribute, and/or modify
+// it under the terms of the GNU Affero General Public License, version 3
+// or later ("AGPL"), as published by the Free Software Foundation.
+//
+// This program is distributed in the hope that it will be useful, but WITHOUT
+// ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+// FITNESS FOR A PARTICULAR PURPOSE.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package jwt
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/erda-project/erda/modules/hepa/apipolicy"
+)
+
+const (
+	POLICY_NAME = "safety-jwt"
+	// kong 内置的 jwt 插件, 根据 token 中 KeyClaim 声明的值匹配消费者的 jwt 凭证验签
+	PLUGIN_NAME = "jwt"
+	// 每个 zone 使用单独的 kong 消费者保存验签密钥
+	CONSUMER_PREFIX   = "hepa-jwt-"
+	DEFAULT_KEY_CLAIM = "iss"
+	// 从 JWKS 同步的公钥以 kid 作为凭证的 key, 同一 JWKS 地址的公钥由各 zone 共用一个消费者保存
+	JWKS_KEY_CLAIM       = "kid"
+	JWKS_CONSUMER_PREFIX = "hepa-jwks-"
+	// kong 内置的 post-function 插件, 在 jwt 插件验签之后校验签发者、受众、必需的声明并转发声明到请求头
+	CLAIMS_PLUGIN_NAME = "post-function"
+	DEFAULT_ERR_STATUS = 401
+	DEFAULT_ERR_MSG    = `{"message":"Unauthorized"}`
+)
+
+const (
+	ALG_HS256 = "HS256"
+	ALG_HS384 = "HS384"
+	ALG_HS512 = "HS512"
+	ALG_RS256 = "RS256"
+	ALG_RS512 = "RS512"
+	ALG_ES256 = "ES256"
+)
+
+// kong 1.x 和 2.x 的 jwt 插件都支持的签名算法, value 为是否为对称算法
+var algorithms = map[string]bool{
+	ALG_HS256: true,
+	ALG_HS384: true,
+	ALG_HS512: true,
+	ALG_RS256: false,
+	ALG_RS512: false,
+	ALG_ES256: false,
+}
+
+// kong jwt 插件支持校验的声明
+var verifiableClaims = map[string]bool{
+	"exp": true,
+	"nbf": true,
+}
+
+// JwtKey 验签密钥
+type JwtKey struct {
+	// token 中 KeyClaim 声明的值, 如签发者
+	Key       string `json:"key"`
+	Algorithm string `json:"algorithm"`
+	// HS* 为共享密钥，其余为 PEM 格式公钥
+	Secret string `json:"secret"`
+}
+
+type PolicyDto struct {
+	apipolicy.BaseDto
+	// 不为空时校验 token 的 iss 声明
+	Issuer string `json:"issuer"`
+	// 为 true 时通过 issuer 的 /.well-known/openid-configuration 获取 jwks 地址
+	Discovery bool   `json:"discovery"`
+	JwksUrl   string `json:"jwksUrl,omitempty"`
+	// 用于匹配验签密钥的声明, 默认为 iss, 使用 JWKS 时默认且只能为 token 头部的 kid
+	KeyClaim string `json:"keyClaim"`
+	// 静态配置的验签密钥
+	Keys []JwtKey `json:"keys"`
+	// token 的 aud 声明需要包含其中之一
+	Audiences []string `json:"audiences,omitempty"`
+	// 声明名称到期望值, 期望值为空时只要求声明存在
+	RequiredClaims map[string]string `json:"requiredClaims,omitempty"`
+	// 声明名称到转发的请求头名称
+	ClaimsToHeaders map[string]string `json:"claimsToHeaders,omitempty"`
+	// 需要校验的声明, 可选 exp, nbf
+	ClaimsToVerify []string `json:"claimsToVerify,omitempty"`
+	// token 最长有效期(秒), 为 0 时不限制, 需要同时校验 exp
+	MaximumExpiration int64  `json:"maximumExpiration,omitempty"`
+	TokenHeader       string `json:"tokenHeader"`
+	TokenCookie       string `json:"tokenCookie,omitempty"`
+	TokenQuery        string `json:"tokenQuery,omitempty"`
+	// 声明校验失败时的应答, 签名校验失败时为 kong jwt 插件的应答
+	ErrStatus int64  `json:"errStatus,omitempty"`
+	ErrMsg    string `json:"errMsg,omitempty"`
+}
+
+var nameRegex = regexp.MustCompile(`^[0-9a-zA-Z-_]+$`)
+
+func validUrl(addr string) bool {
+	u, err := url.Parse(addr)
+	if err != nil {
+		return false
+	}
+	return (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
+
+func (key JwtKey) Symmetric() bool {
+	return algorithms[key.Algorithm]
+}
+
+func (key JwtKey) IsValid() (bool, string) {
+	if strings.TrimSpace(key.Key) == "" {
+		return false, "密钥标识不能为空"
+	}
+	if _, exist := algorithms[key.Algorithm]; !exist {
+		return false, fmt.Sprintf("不支持的签名算法: %s", key.Algorithm)
+	}
+	if strings.TrimSpace(key.Secret) == "" {
+		return false, fmt.Sprintf("密钥不能为空, key:%s", key.Key)
+	}
+	if key.Symmetric() {
+		return true, ""
+	}
+	block, _ := pem.Decode([]byte(key.Secret))
+	if block == nil {
+		return false, fmt.Sprintf("公钥需要为PEM格式, key:%s", key.Key)
+	}
+	if _, err := x509.ParsePKIXPublicKey(block.Bytes); err != nil {
+		return false, fmt.Sprintf("公钥解析失败, key:%s", key.Key)
+	}
+	return true, ""
+}
+
+func (dto PolicyDto) usesJwks() bool {
+	return dto.Discovery || dto.JwksUrl != ""
+}
+
+// checksClaims 返回是否需要 post-function 插件校验或转发声明
+func (dto PolicyDto) checksClaims() bool {
+	return dto.Issuer != "" || len(dto.Audiences) > 0 || len(dto.RequiredClaims) > 0 || len(dto.ClaimsToHeaders) > 0
+}
+
+func (dto PolicyDto) keyClaim() string {
+	if dto.KeyClaim != "" {
+		return dto.KeyClaim
+	}
+	if dto.usesJwks() {
+		return JWKS_KEY_CLAIM
+	}
+	return DEFAULT_KEY_CLAIM
+}
+
+func (dto PolicyDto) errStatus() int64 {
+	if dto.ErrStatus == 0 {
+		return DEFAULT_ERR_STATUS
+	}
+	return dto.ErrStatus
+}
+
+func (dto PolicyDto) errMsg() string {
+	if dto.ErrMsg == "" {
+		return DEFAULT_ERR_MSG
+	}
+	return dto.ErrMsg
+}
+
+func (dto PolicyDto) IsValidDto() (bool, string) {
+	if !dto.Switch {
+		return true, ""
+	}
+	if dto.Discovery && !validUrl(dto.Issuer) {
+		return false, fmt.Sprintf("开启OIDC发现时签发者需要为合法的URL: %s", dto.Issuer)
+	}
+	if dto.JwksUrl != "" && !validUrl(dto.JwksUrl) {
+		return false, fmt.Sprintf("JWKS地址不合法: %s", dto.JwksUrl)
+	}
+	if !nameRegex.MatchString(dto.keyClaim()) {
+		return false, fmt.Sprintf("密钥声明名称不合法: %s", dto.KeyClaim)
+	}
+	if dto.usesJwks() && dto.keyClaim() != JWKS_KEY_CLAIM {
+		return false, fmt.Sprintf("使用JWKS时密钥声明需要为%s", JWKS_KEY_CLAIM)
+	}
+	if !dto.usesJwks() && len(dto.Keys) == 0 {
+		return false, "需要配置JWKS地址、开启OIDC发现或者填写至少一个验签密钥"
+	}
+	keys := map[string]bool{}
+	for _, key := range dto.Keys {
+		if ok, msg := key.IsValid(); !ok {
+			return false, msg
+		}
+		if keys[key.Key] {
+			return false, fmt.Sprintf("密钥标识重复: %s", key.Key)
+		}
+		keys[key.Key] = true
+	}
+	verifyExp := false
+	for _, claim := range dto.ClaimsToVerify {
+		if !verifiableClaims[claim] {
+			return false, fmt.Sprintf("不支持校验的声明: %s", claim)
+		}
+		if claim == "exp" {
+			verifyExp = true
+		}
+	}
+	for _, aud := range dto.Audiences {
+		if strings.TrimSpace(aud) == "" {
+			return false, "受众(audience)不能为空字符串"
+		}
+	}
+	for claim := range dto.RequiredClaims {
+		if strings.TrimSpace(claim) == "" {
+			return false, "必需的声明名称不能为空"
+		}
+	}
+	headers := map[string]bool{}
+	for claim, header := range dto.ClaimsToHeaders {
+		if strings.TrimSpace(claim) == "" {
+			return false, "转发的声明名称不能为空"
+		}
+		if !nameRegex.MatchString(header) {
+			return false, fmt.Sprintf("转发的请求头名称不合法: %s", header)
+		}
+		if headers[strings.ToLower(header)] {
+			return false, fmt.Sprintf("转发的请求头名称重复: %s", header)
+		}
+		headers[strings.ToLower(header)] = true
+	}
+	if dto.MaximumExpiration < 0 {
+		return false, "最长有效期不能小于0"
+	}
+	if dto.MaximumExpiration > 0 && !verifyExp {
+		return false, "限制最长有效期时需要校验exp声明"
+	}
+	if !nameRegex.MatchString(dto.TokenHeader) {
+		return false, fmt.Sprintf("token请求头名称不合法: %s", dto.TokenHeader)
+	}
+	if dto.TokenCookie != "" && !nameRegex.MatchString(dto.TokenCookie) {
+		return false, fmt.Sprintf("token的cookie名称不合法: %s", dto.TokenCookie)
+	}
+	if dto.TokenQuery != "" && !nameRegex.MatchString(dto.TokenQuery) {
+		return false, fmt.Sprintf("token的请求参数名称不合法: %s", dto.TokenQuery)
+	}
+	if dto.ErrStatus != 0 && (dto.ErrStatus < 100 || dto.ErrStatus >= 600) {
+		return false, "请填写合法的校验失败状态码"
+	}
+	return true, ""
+}
@@ -0,0 +1,204 @@
+// Copyright (c) 2021 Terminus, Inc.
+//
+// This program is free software: you can use, redistribute, and/or modify
+// it under the terms of the GNU Affero General Public License, version 3
+// or later ("AGPL"), as published by the Free Software Foundation.
+//
+// This program is distributed in the hope that it will be useful, but WITHOUT
+// ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+// FITNESS FOR A PARTICULAR PURPOSE.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package jwt
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"io"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+// kong jwt 插件不支持从 JWKS 获取公钥, 由 hepa 拉取后写入 jwt 凭证
+var jwksClient = &http.Client{Timeout: 10 * time.Second}
+
+// 响应体大小上限, 避免异常的地址返回过大的内容
+const maxJwksSize = 1 << 20
+
+type jsonWebKey struct {
+	Kid string `json:"kid"`
+	Kty string `json:"kty"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	// RSA
+	N string `json:"n"`
+	E string `json:"e"`
+	// EC
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+func getJson(addr string, v interface{}) error {
+	resp, err := jwksClient.Get(addr)
+	if err != nil {
+		return errors.Wrapf(err, "request %s failed", addr)
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(io.LimitReader(resp.Body, maxJwksSize))
+	if err != nil {
+		return errors.Wrapf(err, "read %s failed", addr)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return errors.Errorf("request %s failed, status:%d, body:%s", addr, resp.StatusCode, body)
+	}
+	if err = json.Unmarshal(body, v); err != nil {
+		return errors.Wrapf(err, "parse %s failed", addr)
+	}
+	return nil
+}
+
+// jwksAddr 返回 JWKS 地址, 开启 OIDC 发现时从签发者的配置中获取
+func (dto PolicyDto) jwksAddr() (string, error) {
+	if dto.JwksUrl != "" {
+		return dto.JwksUrl, nil
+	}
+	discovery := struct {
+		JwksUri string `json:"jwks_uri"`
+	}{}
+	err := getJson(strings.TrimSuffix(dto.Issuer, "/")+"/.well-known/openid-configuration", &discovery)
+	if err != nil {
+		return "", err
+	}
+	if !validUrl(discovery.JwksUri) {
+		return "", errors.Errorf("invalid jwks_uri in openid configuration of %s: %s", dto.Issuer, discovery.JwksUri)
+	}
+	return discovery.JwksUri, nil
+}
+
+func jwksConsumerName(addr string) string {
+	sum := sha1.Sum([]byte(addr))
+	return JWKS_CONSUMER_PREFIX + hex.EncodeToString(sum[:])[:16]
+}
+
+func decodeSegment(value string) ([]byte, error) {
+	return base64.RawURLEncoding.DecodeString(strings.TrimRight(value, "="))
+}
+
+func decodeInt(value string) (*big.Int, error) {
+	data, err := decodeSegment(value)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) == 0 {
+		return nil, errors.New("empty value")
+	}
+	return new(big.Int).SetBytes(data), nil
+}
+
+// publicKey 转换 JWK 为 kong jwt 插件支持的算法和公钥
+func (key jsonWebKey) publicKey() (string, interface{}, error) {
+	switch key.Kty {
+	case "RSA":
+		alg := key.Alg
+		if alg == "" {
+			alg = ALG_RS256
+		}
+		if alg != ALG_RS256 && alg != ALG_RS512 {
+			return "", nil, errors.Errorf("unsupported algorithm %s", alg)
+		}
+		n, err := decodeInt(key.N)
+		if err != nil {
+			return "", nil, errors.Wrap(err, "invalid modulus")
+		}
+		e, err := decodeInt(key.E)
+		if err != nil || !e.IsInt64() {
+			return "", nil, errors.New("invalid exponent")
+		}
+		return alg, &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		if key.Crv != "P-256" || (key.Alg != "" && key.Alg != ALG_ES256) {
+			return "", nil, errors.Errorf("unsupported curve %s", key.Crv)
+		}
+		x, err := decodeInt(key.X)
+		if err != nil {
+			return "", nil, errors.Wrap(err, "invalid x")
+		}
+		y, err := decodeInt(key.Y)
+		if err != nil {
+			return "", nil, errors.Wrap(err, "invalid y")
+		}
+		if !elliptic.P256().IsOnCurve(x, y) {
+			return "", nil, errors.New("point not on curve")
+		}
+		return ALG_ES256, &ecdsa.PublicKey{Curve: elliptic.P256(), X: x, Y: y}, nil
+	}
+	return "", nil, errors.Errorf("unsupported key type %s", key.Kty)
+}
+
+// parseJwks 转换 JWKS 中用于签名的公钥为 PEM 格式的验签密钥, 跳过 kong 不支持的密钥
+func parseJwks(data []jsonWebKey) ([]JwtKey, error) {
+	var keys []JwtKey
+	kids := map[string]bool{}
+	for _, jwk := range data {
+		if jwk.Use == "enc" {
+			continue
+		}
+		if jwk.Kid == "" {
+			log.Warnf("jwk without kid skipped, kty:%s", jwk.Kty)
+			continue
+		}
+		alg, pub, err := jwk.publicKey()
+		if err != nil {
+			log.Warnf("jwk %s skipped: %v", jwk.Kid, err)
+			continue
+		}
+		der, err := x509.MarshalPKIXPublicKey(pub)
+		if err != nil {
+			return nil, errors.Wrapf(err, "marshal jwk %s failed", jwk.Kid)
+		}
+		if kids[jwk.Kid] {
+			return nil, errors.Errorf("duplicated kid %s in jwks", jwk.Kid)
+		}
+		kids[jwk.Kid] = true
+		keys = append(keys, JwtKey{
+			Key:       jwk.Kid,
+			Algorithm: alg,
+			Secret:    string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})),
+		})
+	}
+	if len(keys) == 0 {
+		return nil, errors.New("no signing key supported by kong in jwks")
+	}
+	return keys, nil
+}
+
+func fetchJwks(addr string) ([]JwtKey, error) {
+	jwks := struct {
+		Keys []jsonWebKey `json:"keys"`
+	}{}
+	err := getJson(addr, &jwks)
+	if err != nil {
+		return nil, err
+	}
+	keys, err := parseJwks(jwks.Keys)
+	if err != nil {
+		return nil, errors.WithMessagef(err, "jwks:%s", addr)
+	}
+	return keys, nil
+}
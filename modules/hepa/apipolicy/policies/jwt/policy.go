@@ -0,0 +1,308 @@
+// Copyright (c) 2021 Terminus, Inc.
+//
+// This program is free software: you can use, redistribute, and/or modify
+// it under the terms of the GNU Affero General Public License, version 3
+// or later ("AGPL"), as published by the Free Software Foundation.
+//
+// This program is distributed in the hope that it will be useful, but WITHOUT
+// ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+// FITNESS FOR A PARTICULAR PURPOSE.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package jwt
+
+import (
+	"encoding/json"
+	"strings"
+
+	"github.com/erda-project/erda/modules/hepa/apipolicy"
+	"github.com/erda-project/erda/modules/hepa/kong"
+	kongDto "github.com/erda-project/erda/modules/hepa/kong/dto"
+	"github.com/erda-project/erda/modules/hepa/repository/orm"
+	db "github.com/erda-project/erda/modules/hepa/repository/service"
+
+	"github.com/pkg/errors"
+)
+
+type Policy struct {
+	apipolicy.BasePolicy
+}
+
+func (policy Policy) CreateDefaultConfig(ctx map[string]interface{}) apipolicy.PolicyDto {
+	dto := &PolicyDto{
+		KeyClaim:       DEFAULT_KEY_CLAIM,
+		ClaimsToVerify: []string{"exp"},
+		TokenHeader:    "Authorization",
+		ErrStatus:      DEFAULT_ERR_STATUS,
+		ErrMsg:         DEFAULT_ERR_MSG,
+	}
+	dto.Switch = false
+	return dto
+}
+
+func (policy Policy) UnmarshalConfig(config []byte) (apipolicy.PolicyDto, error, string) {
+	policyDto := &PolicyDto{}
+	err := json.Unmarshal(config, policyDto)
+	if err != nil {
+		return nil, errors.Wrapf(err, "json parse config failed, config:%s", config), "Invalid config"
+	}
+	ok, msg := policyDto.IsValidDto()
+	if !ok {
+		return nil, errors.Errorf("invalid policy dto, msg:%s", msg), msg
+	}
+	return policyDto, nil, ""
+}
+
+func consumerName(zoneId string) string {
+	return CONSUMER_PREFIX + zoneId
+}
+
+func (policy Policy) buildPluginReq(dto *PolicyDto) *kongDto.KongPluginReqDto {
+	disable := false
+	req := &kongDto.KongPluginReqDto{
+		Name:    PLUGIN_NAME,
+		Config:  map[string]interface{}{},
+		Enabled: &disable,
+	}
+	req.Config["key_claim_name"] = dto.keyClaim()
+	req.Config["claims_to_verify"] = append([]string{}, dto.ClaimsToVerify...)
+	if dto.MaximumExpiration > 0 {
+		req.Config["maximum_expiration"] = dto.MaximumExpiration
+	}
+	req.Config["header_names"] = []string{dto.TokenHeader}
+	req.Config["cookie_names"] = []string{}
+	if dto.TokenCookie != "" {
+		req.Config["cookie_names"] = []string{dto.TokenCookie}
+	}
+	// kong 默认从 jwt 请求参数读取 token, 未配置时关闭
+	req.Config["uri_param_names"] = []string{}
+	if dto.TokenQuery != "" {
+		req.Config["uri_param_names"] = []string{dto.TokenQuery}
+	}
+	req.Config["secret_is_base64"] = false
+	return req
+}
+
+func (policy Policy) buildCredential(key JwtKey) *kongDto.KongCredentialDto {
+	credential := &kongDto.KongCredentialDto{
+		Key:       key.Key,
+		Algorithm: key.Algorithm,
+	}
+	if key.Symmetric() {
+		credential.Secret = key.Secret
+	} else {
+		credential.RsaPublicKey = key.Secret
+	}
+	return credential
+}
+
+func sameCredential(exist, desired *kongDto.KongCredentialDto) bool {
+	return exist.Algorithm == desired.Algorithm && exist.Secret == desired.Secret &&
+		strings.TrimSpace(exist.RsaPublicKey) == strings.TrimSpace(desired.RsaPublicKey)
+}
+
+// syncCredentials 使消费者的 jwt 凭证与密钥一致
+func (policy Policy) syncCredentials(adapter kong.KongAdapter, consumer string, keys []JwtKey) error {
+	list, err := adapter.GetCredentialList(consumer, PLUGIN_NAME)
+	if err != nil {
+		// 消费者不存在
+		_, err = adapter.CreateConsumer(&kongDto.KongConsumerReqDto{Username: consumer})
+		if err != nil {
+			return err
+		}
+		list = &kongDto.KongCredentialListDto{}
+	}
+	exists := map[string]kongDto.KongCredentialDto{}
+	for _, credential := range list.Data {
+		exists[credential.Key] = credential
+	}
+	for _, key := range keys {
+		desired := policy.buildCredential(key)
+		if exist, ok := exists[key.Key]; ok {
+			delete(exists, key.Key)
+			if sameCredential(&exist, desired) {
+				continue
+			}
+			err = adapter.DeleteCredential(consumer, PLUGIN_NAME, exist.Id)
+			if err != nil {
+				return err
+			}
+		}
+		_, err = adapter.CreateCredential(&kongDto.KongCredentialReqDto{
+			ConsumerId: consumer,
+			PluginName: PLUGIN_NAME,
+			Config:     desired,
+		})
+		if err != nil {
+			// kong 中 jwt 凭证的 key 全局唯一
+			return errors.Wrapf(err, "create jwt credential failed, key %s may be used by other zone", key.Key)
+		}
+	}
+	for _, stale := range exists {
+		err = adapter.DeleteCredential(consumer, PLUGIN_NAME, stale.Id)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// syncJwks 拉取 JWKS 写入地址对应的共用消费者, 密钥轮换后删除过期的公钥
+func (policy Policy) syncJwks(adapter kong.KongAdapter, addr string) error {
+	keys, err := fetchJwks(addr)
+	if err != nil {
+		return err
+	}
+	return policy.syncCredentials(adapter, jwksConsumerName(addr), keys)
+}
+
+// savePlugin 创建或更新 zone 的插件, 返回是否新建了插件
+func (policy Policy) savePlugin(adapter kong.KongAdapter, policyDb *db.GatewayPolicyServiceImpl, zoneId string, exist *orm.GatewayPolicy, req *kongDto.KongPluginReqDto) (bool, error) {
+	if exist != nil {
+		req.Id = exist.PluginId
+		resp, err := adapter.CreateOrUpdatePluginById(req)
+		if err != nil {
+			return false, err
+		}
+		configByte, err := json.Marshal(resp.Config)
+		if err != nil {
+			return false, err
+		}
+		exist.Config = configByte
+		return false, policyDb.Update(exist)
+	}
+	resp, err := adapter.AddPlugin(req)
+	if err != nil {
+		return false, err
+	}
+	configByte, err := json.Marshal(resp.Config)
+	if err != nil {
+		return false, err
+	}
+	err = policyDb.Insert(&orm.GatewayPolicy{
+		ZoneId:     zoneId,
+		PluginName: req.Name,
+		Category:   "safety",
+		PluginId:   resp.Id,
+		Config:     configByte,
+		Enabled:    1,
+	})
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// removePlugin 删除 zone 的插件, 返回是否删除了插件
+func (policy Policy) removePlugin(adapter kong.KongAdapter, policyDb *db.GatewayPolicyServiceImpl, exist *orm.GatewayPolicy) (bool, error) {
+	if exist == nil {
+		return false, nil
+	}
+	err := adapter.RemovePlugin(exist.PluginId)
+	if err != nil {
+		return false, err
+	}
+	_ = policyDb.DeleteById(exist.Id)
+	return true, nil
+}
+
+func (policy Policy) ParseConfig(dto apipolicy.PolicyDto, ctx map[string]interface{}) (apipolicy.PolicyConfig, error) {
+	res := apipolicy.PolicyConfig{}
+	policyDto, ok := dto.(*PolicyDto)
+	if !ok {
+		return res, errors.Errorf("invalid config:%+v", dto)
+	}
+	value, ok := ctx[apipolicy.CTX_KONG_ADAPTER]
+	if !ok {
+		return res, errors.Errorf("get identify failed:%+v", ctx)
+	}
+	adapter, ok := value.(kong.KongAdapter)
+	if !ok {
+		return res, errors.Errorf("convert failed:%+v", value)
+	}
+	value, ok = ctx[apipolicy.CTX_ZONE]
+	if !ok {
+		return res, errors.Errorf("get identify failed:%+v", ctx)
+	}
+	zone, ok := value.(*orm.GatewayZone)
+	if !ok {
+		return res, errors.Errorf("convert failed:%+v", value)
+	}
+	policyDb, _ := db.NewGatewayPolicyServiceImpl()
+	exist, err := policyDb.GetByAny(&orm.GatewayPolicy{
+		ZoneId:     zone.Id,
+		PluginName: PLUGIN_NAME,
+	})
+	if err != nil {
+		return res, err
+	}
+	claimsExist, err := policyDb.GetByAny(&orm.GatewayPolicy{
+		ZoneId:     zone.Id,
+		PluginName: CLAIMS_PLUGIN_NAME,
+	})
+	if err != nil {
+		return res, err
+	}
+	if !policyDto.Switch {
+		for _, plugin := range []*orm.GatewayPolicy{exist, claimsExist} {
+			removed, err := policy.removePlugin(adapter, policyDb, plugin)
+			if err != nil {
+				return res, err
+			}
+			res.KongPolicyChange = res.KongPolicyChange || removed
+		}
+		// JWKS 的消费者可能被其他 zone 共用, 不删除
+		err = adapter.DeleteConsumer(consumerName(zone.Id))
+		if err != nil {
+			return res, err
+		}
+		return res, nil
+	}
+	// 先写入凭证再开启插件, 避免插件生效时没有可用的密钥
+	err = policy.syncCredentials(adapter, consumerName(zone.Id), policyDto.Keys)
+	if err != nil {
+		return res, err
+	}
+	if policyDto.usesJwks() {
+		addr, err := policyDto.jwksAddr()
+		if err != nil {
+			return res, err
+		}
+		err = policy.syncJwks(adapter, addr)
+		if err != nil {
+			return res, err
+		}
+	}
+	created, err := policy.savePlugin(adapter, policyDb, zone.Id, exist, policy.buildPluginReq(policyDto))
+	if err != nil {
+		return res, err
+	}
+	res.KongPolicyChange = created
+	if !policyDto.checksClaims() {
+		removed, err := policy.removePlugin(adapter, policyDb, claimsExist)
+		if err != nil {
+			return res, err
+		}
+		res.KongPolicyChange = res.KongPolicyChange || removed
+		return res, nil
+	}
+	version, err := adapter.GetVersion()
+	if err != nil {
+		return res, err
+	}
+	created, err = policy.savePlugin(adapter, policyDb, zone.Id, claimsExist, policy.buildClaimsPluginReq(policyDto, version))
+	if err != nil {
+		return res, err
+	}
+	res.KongPolicyChange = res.KongPolicyChange || created
+	return res, nil
+}
+
+func init() {
+	err := apipolicy.RegisterPolicyEngine(POLICY_NAME, &Policy{})
+	if err != nil {
+		panic(err)
+	}
+}
@@ -0,0 +1,364 @@
+// Copyright (c) 2021 Terminus, Inc.
+//
+// This program is free software: you can use, redistribute, and/or modify
+// it under the terms of the GNU Affero General Public License, version 3
+// or later ("AGPL"), as published by the Free Software Foundation.
+//
+// This program is distributed in the hope that it will be useful, but WITHOUT
+// ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+// FITNESS FOR A PARTICULAR PURPOSE.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package jwt
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/pkg/errors"
+
+	"github.com/erda-project/erda/modules/hepa/apipolicy"
+	"github.com/erda-project/erda/modules/hepa/kong"
+	kongDto "github.com/erda-project/erda/modules/hepa/kong/dto"
+)
+
+func publicKeyPem(t *testing.T) string {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}))
+}
+
+func TestPolicyDto_IsValidDto(t *testing.T) {
+	pub := publicKeyPem(t)
+	valid := func(modify func(dto *PolicyDto)) PolicyDto {
+		dto := PolicyDto{
+			BaseDto:        apipolicy.BaseDto{Switch: true},
+			KeyClaim:       "iss",
+			Keys:           []JwtKey{{Key: "https://issuer.example.com", Algorithm: ALG_HS256, Secret: "secret"}},
+			ClaimsToVerify: []string{"exp"},
+			TokenHeader:    "Authorization",
+		}
+		if modify != nil {
+			modify(&dto)
+		}
+		return dto
+	}
+	tests := []struct {
+		name string
+		dto  PolicyDto
+		want bool
+	}{
+		{"disabled", PolicyDto{}, true},
+		{"shared secret", valid(nil), true},
+		{"default key claim", valid(func(dto *PolicyDto) { dto.KeyClaim = "" }), true},
+		{"public key", valid(func(dto *PolicyDto) {
+			dto.KeyClaim = "kid"
+			dto.Keys = append(dto.Keys, JwtKey{Key: "k2", Algorithm: ALG_ES256, Secret: pub})
+		}), true},
+		{"invalid key claim", valid(func(dto *PolicyDto) { dto.KeyClaim = "a b" }), false},
+		{"no keys", valid(func(dto *PolicyDto) { dto.Keys = nil }), false},
+		{"empty key", valid(func(dto *PolicyDto) { dto.Keys[0].Key = "" }), false},
+		{"unsupported algorithm", valid(func(dto *PolicyDto) { dto.Keys[0].Algorithm = "PS256" }), false},
+		{"empty secret", valid(func(dto *PolicyDto) { dto.Keys[0].Secret = " " }), false},
+		{"invalid public key", valid(func(dto *PolicyDto) { dto.Keys[0] = JwtKey{Key: "k", Algorithm: ALG_RS256, Secret: "abc"} }), false},
+		{"duplicated key", valid(func(dto *PolicyDto) { dto.Keys = append(dto.Keys, dto.Keys[0]) }), false},
+		{"unsupported claim", valid(func(dto *PolicyDto) { dto.ClaimsToVerify = []string{"aud"} }), false},
+		{"max expiration", valid(func(dto *PolicyDto) { dto.MaximumExpiration = 3600 }), true},
+		{"max expiration without exp", valid(func(dto *PolicyDto) {
+			dto.MaximumExpiration = 3600
+			dto.ClaimsToVerify = []string{"nbf"}
+		}), false},
+		{"negative max expiration", valid(func(dto *PolicyDto) { dto.MaximumExpiration = -1 }), false},
+		{"invalid header", valid(func(dto *PolicyDto) { dto.TokenHeader = "" }), false},
+		{"invalid cookie", valid(func(dto *PolicyDto) { dto.TokenCookie = "a;b" }), false},
+		{"invalid query", valid(func(dto *PolicyDto) { dto.TokenQuery = "a=b" }), false},
+		{"jwks", valid(func(dto *PolicyDto) {
+			dto.KeyClaim = ""
+			dto.Keys = nil
+			dto.JwksUrl = "https://issuer.example.com/jwks"
+		}), true},
+		{"discovery", valid(func(dto *PolicyDto) {
+			dto.KeyClaim = "kid"
+			dto.Keys = nil
+			dto.Issuer = "https://issuer.example.com"
+			dto.Discovery = true
+		}), true},
+		{"discovery without issuer url", valid(func(dto *PolicyDto) {
+			dto.KeyClaim = "kid"
+			dto.Issuer = "issuer"
+			dto.Discovery = true
+		}), false},
+		{"invalid jwks url", valid(func(dto *PolicyDto) {
+			dto.KeyClaim = "kid"
+			dto.JwksUrl = "ftp://issuer.example.com/jwks"
+		}), false},
+		{"jwks with iss key claim", valid(func(dto *PolicyDto) { dto.JwksUrl = "https://issuer.example.com/jwks" }), false},
+		{"claims", valid(func(dto *PolicyDto) {
+			dto.Issuer = "https://issuer.example.com"
+			dto.Audiences = []string{"api"}
+			dto.RequiredClaims = map[string]string{"scope": "read", "sub": ""}
+			dto.ClaimsToHeaders = map[string]string{"sub": "X-User-Id", "tenant": "X-Tenant"}
+			dto.ErrStatus = 403
+		}), true},
+		{"empty audience", valid(func(dto *PolicyDto) { dto.Audiences = []string{" "} }), false},
+		{"empty required claim", valid(func(dto *PolicyDto) { dto.RequiredClaims = map[string]string{"": "a"} }), false},
+		{"invalid claim header", valid(func(dto *PolicyDto) { dto.ClaimsToHeaders = map[string]string{"sub": "X User"} }), false},
+		{"duplicated claim header", valid(func(dto *PolicyDto) {
+			dto.ClaimsToHeaders = map[string]string{"sub": "X-User", "name": "x-user"}
+		}), false},
+		{"invalid err status", valid(func(dto *PolicyDto) { dto.ErrStatus = 99 }), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got, msg := tt.dto.IsValidDto(); got != tt.want {
+				t.Errorf("IsValidDto() = %v, want %v, msg: %s", got, tt.want, msg)
+			}
+		})
+	}
+}
+
+func TestPolicy_buildPluginReq(t *testing.T) {
+	tests := []struct {
+		name string
+		dto  PolicyDto
+		want map[string]interface{}
+	}{
+		{
+			name: "default",
+			dto:  PolicyDto{ClaimsToVerify: []string{"exp"}, TokenHeader: "Authorization"},
+			want: map[string]interface{}{
+				"key_claim_name":   "iss",
+				"claims_to_verify": []string{"exp"},
+				"header_names":     []string{"Authorization"},
+				"cookie_names":     []string{},
+				"uri_param_names":  []string{},
+				"secret_is_base64": false,
+			},
+		},
+		{
+			name: "all",
+			dto: PolicyDto{KeyClaim: "kid", ClaimsToVerify: []string{"exp", "nbf"}, MaximumExpiration: 600,
+				TokenHeader: "X-Token", TokenCookie: "token", TokenQuery: "access_token"},
+			want: map[string]interface{}{
+				"key_claim_name":     "kid",
+				"claims_to_verify":   []string{"exp", "nbf"},
+				"maximum_expiration": int64(600),
+				"header_names":       []string{"X-Token"},
+				"cookie_names":       []string{"token"},
+				"uri_param_names":    []string{"access_token"},
+				"secret_is_base64":   false,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := Policy{}.buildPluginReq(&tt.dto)
+			if req.Name != PLUGIN_NAME || req.Enabled == nil || *req.Enabled {
+				t.Errorf("unexpected plugin req: %+v", req)
+			}
+			if !reflect.DeepEqual(req.Config, tt.want) {
+				t.Errorf("buildPluginReq() config = %+v, want %+v", req.Config, tt.want)
+			}
+		})
+	}
+}
+
+func TestPolicy_buildClaimsPluginReq(t *testing.T) {
+	dto := &PolicyDto{
+		Issuer:          "https://issuer.example.com",
+		Audiences:       []string{"api", `a"b`},
+		RequiredClaims:  map[string]string{"sub": "", "scope": "read"},
+		ClaimsToHeaders: map[string]string{"sub": "X-User-Id"},
+		ErrMsg:          "deny\n",
+	}
+	req := Policy{}.buildClaimsPluginReq(dto, "2.2.1")
+	if req.Name != CLAIMS_PLUGIN_NAME || req.Enabled == nil || *req.Enabled {
+		t.Fatalf("unexpected plugin req: %+v", req)
+	}
+	access, ok := req.Config["access"].([]string)
+	if !ok || len(access) != 1 || req.Config["functions"] != nil {
+		t.Fatalf("unexpected plugin config: %+v", req.Config)
+	}
+	for _, want := range []string{
+		`issuer = "https://issuer.example.com",`,
+		`audiences = {"api", "a\"b"},`,
+		`required = {["scope"] = "read", ["sub"] = ""},`,
+		`headers = {["sub"] = "X-User-Id"},`,
+		`status = 401,`,
+		`message = "deny\010",`,
+		"return check\n",
+	} {
+		if !strings.Contains(access[0], want) {
+			t.Errorf("code doesn't contain %q:\n%s", want, access[0])
+		}
+	}
+	req = Policy{}.buildClaimsPluginReq(dto, "0.14.1")
+	functions, ok := req.Config["functions"].([]string)
+	if !ok || len(functions) != 1 || !strings.HasSuffix(functions[0], "return check()\n") {
+		t.Errorf("unexpected plugin config: %+v", req.Config)
+	}
+}
+
+func TestParseJwks(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	encode := func(i *big.Int) string {
+		return base64.RawURLEncoding.EncodeToString(i.Bytes())
+	}
+	keys, err := parseJwks([]jsonWebKey{
+		{Kid: "rsa", Kty: "RSA", Use: "sig", N: encode(rsaKey.N), E: encode(big.NewInt(int64(rsaKey.E)))},
+		{Kid: "rsa512", Kty: "RSA", Alg: ALG_RS512, N: encode(rsaKey.N), E: encode(big.NewInt(int64(rsaKey.E)))},
+		{Kid: "ec", Kty: "EC", Crv: "P-256", X: encode(ecKey.X), Y: encode(ecKey.Y)},
+		{Kid: "ps", Kty: "RSA", Alg: "PS256", N: encode(rsaKey.N), E: encode(big.NewInt(int64(rsaKey.E)))},
+		{Kid: "enc", Kty: "RSA", Use: "enc", N: encode(rsaKey.N), E: encode(big.NewInt(int64(rsaKey.E)))},
+		{Kid: "p384", Kty: "EC", Crv: "P-384", X: encode(ecKey.X), Y: encode(ecKey.Y)},
+		{Kty: "RSA", N: encode(rsaKey.N), E: encode(big.NewInt(int64(rsaKey.E)))},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	algs := map[string]string{}
+	for _, key := range keys {
+		if ok, msg := key.IsValid(); !ok {
+			t.Errorf("invalid key %s: %s", key.Key, msg)
+		}
+		algs[key.Key] = key.Algorithm
+	}
+	want := map[string]string{"rsa": ALG_RS256, "rsa512": ALG_RS512, "ec": ALG_ES256}
+	if !reflect.DeepEqual(algs, want) {
+		t.Errorf("parseJwks() = %v, want %v", algs, want)
+	}
+	if _, err = parseJwks([]jsonWebKey{{Kid: "ps", Kty: "RSA", Alg: "PS256"}}); err == nil {
+		t.Error("parseJwks() should fail without supported keys")
+	}
+}
+
+func TestPolicyDto_jwksAddr(t *testing.T) {
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/.well-known/openid-configuration" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		_, _ = w.Write([]byte(`{"issuer":"` + server.URL + `","jwks_uri":"` + server.URL + `/keys"}`))
+	}))
+	defer server.Close()
+	addr, err := PolicyDto{Issuer: server.URL + "/", Discovery: true}.jwksAddr()
+	if err != nil || addr != server.URL+"/keys" {
+		t.Errorf("jwksAddr() = %s, %v", addr, err)
+	}
+	if _, err = fetchJwks(server.URL + "/keys"); err == nil {
+		t.Error("fetchJwks() should fail on error status")
+	}
+	addr, err = PolicyDto{JwksUrl: "https://issuer.example.com/jwks"}.jwksAddr()
+	if err != nil || addr != "https://issuer.example.com/jwks" {
+		t.Errorf("jwksAddr() = %s, %v", addr, err)
+	}
+}
+
+type fakeAdapter struct {
+	kong.KongAdapter
+	consumers   map[string]bool
+	credentials map[string]kongDto.KongCredentialDto
+	seq         int
+	deleted     []string
+}
+
+func (adapter *fakeAdapter) CreateConsumer(req *kongDto.KongConsumerReqDto) (*kongDto.KongConsumerRespDto, error) {
+	adapter.consumers[req.Username] = true
+	return &kongDto.KongConsumerRespDto{}, nil
+}
+
+func (adapter *fakeAdapter) GetCredentialList(consumer, pluginName string) (*kongDto.KongCredentialListDto, error) {
+	if !adapter.consumers[consumer] {
+		return nil, errors.New("consumer not found")
+	}
+	res := &kongDto.KongCredentialListDto{}
+	for _, credential := range adapter.credentials {
+		res.Data = append(res.Data, credential)
+	}
+	return res, nil
+}
+
+func (adapter *fakeAdapter) CreateCredential(req *kongDto.KongCredentialReqDto) (*kongDto.KongCredentialDto, error) {
+	adapter.seq++
+	credential := *req.Config
+	credential.Id = string(rune('a' + adapter.seq))
+	adapter.credentials[credential.Id] = credential
+	return &credential, nil
+}
+
+func (adapter *fakeAdapter) DeleteCredential(consumer, pluginName, id string) error {
+	delete(adapter.credentials, id)
+	adapter.deleted = append(adapter.deleted, id)
+	return nil
+}
+
+func TestPolicy_syncCredentials(t *testing.T) {
+	pub := publicKeyPem(t)
+	adapter := &fakeAdapter{consumers: map[string]bool{}, credentials: map[string]kongDto.KongCredentialDto{}}
+	policy := Policy{}
+	keys := []JwtKey{
+		{Key: "k1", Algorithm: ALG_HS256, Secret: "s1"},
+		{Key: "k2", Algorithm: ALG_ES256, Secret: pub},
+	}
+	if err := policy.syncCredentials(adapter, consumerName("zone"), keys); err != nil {
+		t.Fatal(err)
+	}
+	if !adapter.consumers[consumerName("zone")] || len(adapter.credentials) != 2 {
+		t.Fatalf("unexpected credentials: %+v", adapter.credentials)
+	}
+	for _, credential := range adapter.credentials {
+		if credential.Key == "k2" && (credential.RsaPublicKey != pub || credential.Secret != "") {
+			t.Errorf("unexpected public key credential: %+v", credential)
+		}
+	}
+	// 未变化的密钥保持不变, 修改的密钥重建, 删除的密钥清理
+	keys = []JwtKey{
+		{Key: "k1", Algorithm: ALG_HS256, Secret: "s1"},
+		{Key: "k3", Algorithm: ALG_HS512, Secret: "s3"},
+	}
+	if err := policy.syncCredentials(adapter, consumerName("zone"), keys); err != nil {
+		t.Fatal(err)
+	}
+	if len(adapter.credentials) != 2 || len(adapter.deleted) != 1 {
+		t.Fatalf("unexpected credentials: %+v, deleted: %v", adapter.credentials, adapter.deleted)
+	}
+	keys[0].Secret = "changed"
+	if err := policy.syncCredentials(adapter, consumerName("zone"), keys); err != nil {
+		t.Fatal(err)
+	}
+	for _, credential := range adapter.credentials {
+		if credential.Key == "k1" && credential.Secret != "changed" {
+			t.Errorf("credential not updated: %+v", credential)
+		}
+	}
+	if len(adapter.credentials) != 2 || len(adapter.deleted) != 2 {
+		t.Errorf("unexpected credentials: %+v, deleted: %v", adapter.credentials, adapter.deleted)
+	}
+}
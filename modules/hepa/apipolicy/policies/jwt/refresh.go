@@ -0,0 +1,132 @@
+// Copyright (c) 2021 Terminus, Inc.
+//
+// This program is free software: you can use, redistribute, and/or modify
+// it under the terms of the GNU Affero General Public License, version 3
+// or later ("AGPL"), as published by the Free Software Foundation.
+//
+// This program is distributed in the hope that it will be useful, but WITHOUT
+// ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+// FITNESS FOR A PARTICULAR PURPOSE.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package jwt
+
+import (
+	"time"
+
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/erda-project/erda/modules/hepa/apipolicy"
+	"github.com/erda-project/erda/modules/hepa/common/util"
+	"github.com/erda-project/erda/modules/hepa/kong"
+	"github.com/erda-project/erda/modules/hepa/repository/orm"
+	db "github.com/erda-project/erda/modules/hepa/repository/service"
+)
+
+// 签发者轮换密钥后, 定期同步 JWKS 使新公钥生效
+const jwksRefreshInterval = 10 * time.Minute
+
+// zonePackageId 返回 zone 所属的产品包, 用于读取产品包级别的默认策略
+func zonePackageId(zone *orm.GatewayZone) (string, error) {
+	switch zone.Type {
+	case db.ZONE_TYPE_UNITY:
+		packDb, err := db.NewGatewayPackageServiceImpl()
+		if err != nil {
+			return "", err
+		}
+		pack, err := packDb.GetByAny(&orm.GatewayPackage{ZoneId: zone.Id})
+		if err != nil || pack == nil {
+			return "", err
+		}
+		return pack.Id, nil
+	case db.ZONE_TYPE_PACKAGE_API:
+		apiDb, err := db.NewGatewayPackageApiServiceImpl()
+		if err != nil {
+			return "", err
+		}
+		api, err := apiDb.GetByAny(&orm.GatewayPackageApi{ZoneId: zone.Id})
+		if err != nil || api == nil {
+			return "", err
+		}
+		return api.PackageId, nil
+	}
+	return "", errors.Errorf("zone type %s not supported", zone.Type)
+}
+
+// refreshJwks 同步开启了 jwt 策略且使用 JWKS 的 zone 的公钥, 同一 kong 的相同地址只同步一次
+func refreshJwks() {
+	engine, err := apipolicy.GetPolicyEngine(POLICY_NAME)
+	if err != nil {
+		log.Errorf("refresh jwks failed, err:%+v", err)
+		return
+	}
+	policyDb, err := db.NewGatewayPolicyServiceImpl()
+	if err != nil {
+		log.Errorf("refresh jwks failed, err:%+v", err)
+		return
+	}
+	zoneDb, err := db.NewGatewayZoneServiceImpl()
+	if err != nil {
+		log.Errorf("refresh jwks failed, err:%+v", err)
+		return
+	}
+	policies, err := policyDb.SelectByAny(&orm.GatewayPolicy{PluginName: PLUGIN_NAME})
+	if err != nil {
+		log.Errorf("refresh jwks failed, err:%+v", err)
+		return
+	}
+	synced := map[string]bool{}
+	for _, policy := range policies {
+		if policy.ZoneId == "" {
+			continue
+		}
+		zone, err := zoneDb.GetById(policy.ZoneId)
+		if err != nil || zone == nil {
+			continue
+		}
+		packageId, err := zonePackageId(zone)
+		if err != nil {
+			log.Errorf("get package of zone %s failed, err:%+v", zone.Id, err)
+			continue
+		}
+		dto, err := engine.GetConfig(POLICY_NAME, packageId, zone, nil)
+		if err != nil {
+			log.Errorf("get jwt policy of zone %s failed, err:%+v", zone.Id, err)
+			continue
+		}
+		policyDto, ok := dto.(*PolicyDto)
+		if !ok || !policyDto.Switch || !policyDto.usesJwks() {
+			continue
+		}
+		addr, err := policyDto.jwksAddr()
+		if err != nil {
+			log.Errorf("get jwks of zone %s failed, err:%+v", zone.Id, err)
+			continue
+		}
+		id := zone.DiceClusterName + "/" + zone.DiceProjectId + "/" + zone.DiceEnv + "/" + addr
+		if synced[id] {
+			continue
+		}
+		synced[id] = true
+		adapter := kong.NewKongAdapterForProject(zone.DiceClusterName, zone.DiceEnv, zone.DiceProjectId)
+		if adapter == nil {
+			continue
+		}
+		err = Policy{}.syncJwks(adapter, addr)
+		if err != nil {
+			log.Errorf("sync jwks of zone %s failed, err:%+v", zone.Id, err)
+		}
+	}
+}
+
+func init() {
+	go func() {
+		defer util.DoRecover()
+		for range time.Tick(jwksRefreshInterval) {
+			refreshJwks()
+		}
+	}()
+}
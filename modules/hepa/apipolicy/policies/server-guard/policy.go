@@ -185,6 +185,25 @@ location @LIMIT-%s {
 	return res, nil
 }
 
+// RenderPlugins 渲染为 rate-limiting 插件, 超出限制时固定返回 429, 不支持额外延时排队和自定义拒绝应答
+func (policy Policy) RenderPlugins(dto apipolicy.PolicyDto) ([]apipolicy.PluginConfig, error) {
+	policyDto, ok := dto.(*PolicyDto)
+	if !ok {
+		return nil, errors.Errorf("invalid config:%+v", dto)
+	}
+	plugin := apipolicy.PluginConfig{Name: "rate-limiting"}
+	if !policyDto.Switch {
+		return []apipolicy.PluginConfig{plugin}, nil
+	}
+	if policyDto.RefuseCode != 429 {
+		return nil, errors.Errorf("refuse code %d not supported by the gateway, only 429", policyDto.RefuseCode)
+	}
+	plugin.Config = map[string]interface{}{
+		"second": policyDto.MaxTps,
+	}
+	return []apipolicy.PluginConfig{plugin}, nil
+}
+
 func init() {
 	err := apipolicy.RegisterPolicyEngine("safety-server-guard", &Policy{})
 	if err != nil {
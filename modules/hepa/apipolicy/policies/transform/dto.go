@@ -0,0 +1,191 @@
+// Copyright (c) 2021 Terminus, Inc.
+//
+// This program is free software: you can use, redistribute, and/or modify
+// it under the terms of the GNU Affero General Public License, version 3
+// or later ("AGPL"), as published by the Free Software Foundation.
+//
+// This program is distributed in the hope that it will be useful, but WITHOUT
+// ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+// FITNESS FOR A PARTICULAR PURPOSE.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package transform
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/erda-project/erda/modules/hepa/apipolicy"
+)
+
+const (
+	REQUEST_PLUGIN  = "request-transformer"
+	RESPONSE_PLUGIN = "response-transformer"
+)
+
+type RenameDto struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+// RequestTransform 请求转换，执行顺序为 删除->重命名->设置->添加
+type RequestTransform struct {
+	RemoveHeaders []string          `json:"removeHeaders,omitempty"`
+	RenameHeaders []RenameDto       `json:"renameHeaders,omitempty"`
+	SetHeaders    map[string]string `json:"setHeaders,omitempty"`
+	// 仅在请求头不存在时添加
+	AddHeaders  map[string]string `json:"addHeaders,omitempty"`
+	RemoveQuery []string          `json:"removeQuery,omitempty"`
+	RenameQuery []RenameDto       `json:"renameQuery,omitempty"`
+	SetQuery    map[string]string `json:"setQuery,omitempty"`
+	AddQuery    map[string]string `json:"addQuery,omitempty"`
+	RemoveBody  []string          `json:"removeBody,omitempty"`
+	RenameBody  []RenameDto       `json:"renameBody,omitempty"`
+	SetBody     map[string]string `json:"setBody,omitempty"`
+	AddBody     map[string]string `json:"addBody,omitempty"`
+}
+
+// ResponseTransform 应答转换，body 相关操作仅对 json 应答生效
+type ResponseTransform struct {
+	RemoveHeaders []string          `json:"removeHeaders,omitempty"`
+	RenameHeaders []RenameDto       `json:"renameHeaders,omitempty"`
+	SetHeaders    map[string]string `json:"setHeaders,omitempty"`
+	AddHeaders    map[string]string `json:"addHeaders,omitempty"`
+	RemoveJson    []string          `json:"removeJson,omitempty"`
+	SetJson       map[string]string `json:"setJson,omitempty"`
+	AddJson       map[string]string `json:"addJson,omitempty"`
+}
+
+type PolicyDto struct {
+	apipolicy.BaseDto
+	Request  *RequestTransform  `json:"request,omitempty"`
+	Response *ResponseTransform `json:"response,omitempty"`
+}
+
+var headerRegex = regexp.MustCompile(`^[0-9a-zA-Z-_]+$`)
+
+var fieldRegex = regexp.MustCompile(`^[^:,\s]+$`)
+
+func checkNames(desc string, regex *regexp.Regexp, names []string) (bool, string) {
+	for _, name := range names {
+		if ok := regex.MatchString(name); !ok {
+			return false, fmt.Sprintf("%s名称不合法: %s", desc, name)
+		}
+	}
+	return true, ""
+}
+
+func checkRenames(desc string, regex *regexp.Regexp, renames []RenameDto) (bool, string) {
+	for _, rename := range renames {
+		if ok, msg := checkNames(desc, regex, []string{rename.From, rename.To}); !ok {
+			return false, msg
+		}
+	}
+	return true, ""
+}
+
+func checkValues(desc string, regex *regexp.Regexp, values map[string]string) (bool, string) {
+	for _, name := range sortedKeys(values) {
+		if ok := regex.MatchString(name); !ok {
+			return false, fmt.Sprintf("%s名称不合法: %s", desc, name)
+		}
+		// kong 以逗号分隔数组参数
+		if strings.Contains(values[name], ",") {
+			return false, fmt.Sprintf("%s的值不能包含逗号: %s", desc, name)
+		}
+	}
+	return true, ""
+}
+
+func sortedKeys(values map[string]string) []string {
+	var keys []string
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func (req RequestTransform) IsEmpty() bool {
+	return len(req.RemoveHeaders) == 0 && len(req.RenameHeaders) == 0 && len(req.SetHeaders) == 0 && len(req.AddHeaders) == 0 &&
+		len(req.RemoveQuery) == 0 && len(req.RenameQuery) == 0 && len(req.SetQuery) == 0 && len(req.AddQuery) == 0 &&
+		len(req.RemoveBody) == 0 && len(req.RenameBody) == 0 && len(req.SetBody) == 0 && len(req.AddBody) == 0
+}
+
+func (req RequestTransform) IsValid() (bool, string) {
+	checks := []func() (bool, string){
+		func() (bool, string) { return checkNames("删除的请求头", headerRegex, req.RemoveHeaders) },
+		func() (bool, string) { return checkRenames("重命名的请求头", headerRegex, req.RenameHeaders) },
+		func() (bool, string) { return checkValues("设置的请求头", headerRegex, req.SetHeaders) },
+		func() (bool, string) { return checkValues("添加的请求头", headerRegex, req.AddHeaders) },
+		func() (bool, string) { return checkNames("删除的请求参数", fieldRegex, req.RemoveQuery) },
+		func() (bool, string) { return checkRenames("重命名的请求参数", fieldRegex, req.RenameQuery) },
+		func() (bool, string) { return checkValues("设置的请求参数", fieldRegex, req.SetQuery) },
+		func() (bool, string) { return checkValues("添加的请求参数", fieldRegex, req.AddQuery) },
+		func() (bool, string) { return checkNames("删除的请求体字段", fieldRegex, req.RemoveBody) },
+		func() (bool, string) { return checkRenames("重命名的请求体字段", fieldRegex, req.RenameBody) },
+		func() (bool, string) { return checkValues("设置的请求体字段", fieldRegex, req.SetBody) },
+		func() (bool, string) { return checkValues("添加的请求体字段", fieldRegex, req.AddBody) },
+	}
+	for _, check := range checks {
+		if ok, msg := check(); !ok {
+			return false, msg
+		}
+	}
+	return true, ""
+}
+
+func (resp ResponseTransform) IsEmpty() bool {
+	return len(resp.RemoveHeaders) == 0 && len(resp.RenameHeaders) == 0 && len(resp.SetHeaders) == 0 && len(resp.AddHeaders) == 0 &&
+		len(resp.RemoveJson) == 0 && len(resp.SetJson) == 0 && len(resp.AddJson) == 0
+}
+
+func (resp ResponseTransform) IsValid() (bool, string) {
+	checks := []func() (bool, string){
+		func() (bool, string) { return checkNames("删除的应答头", headerRegex, resp.RemoveHeaders) },
+		func() (bool, string) { return checkRenames("重命名的应答头", headerRegex, resp.RenameHeaders) },
+		func() (bool, string) { return checkValues("设置的应答头", headerRegex, resp.SetHeaders) },
+		func() (bool, string) { return checkValues("添加的应答头", headerRegex, resp.AddHeaders) },
+		func() (bool, string) { return checkNames("删除的应答字段", fieldRegex, resp.RemoveJson) },
+		func() (bool, string) { return checkValues("设置的应答字段", fieldRegex, resp.SetJson) },
+		func() (bool, string) { return checkValues("添加的应答字段", fieldRegex, resp.AddJson) },
+	}
+	for _, check := range checks {
+		if ok, msg := check(); !ok {
+			return false, msg
+		}
+	}
+	return true, ""
+}
+
+func (dto PolicyDto) requestEnabled() bool {
+	return dto.Request != nil && !dto.Request.IsEmpty()
+}
+
+func (dto PolicyDto) responseEnabled() bool {
+	return dto.Response != nil && !dto.Response.IsEmpty()
+}
+
+func (dto PolicyDto) IsValidDto() (bool, string) {
+	if !dto.Switch {
+		return true, ""
+	}
+	if !dto.requestEnabled() && !dto.responseEnabled() {
+		return false, "请至少配置一项请求或应答转换规则"
+	}
+	if dto.Request != nil {
+		if ok, msg := dto.Request.IsValid(); !ok {
+			return false, msg
+		}
+	}
+	if dto.Response != nil {
+		if ok, msg := dto.Response.IsValid(); !ok {
+			return false, msg
+		}
+	}
+	return true, ""
+}
@@ -0,0 +1,277 @@
+// Copyright (c) 2021 Terminus, Inc.
+//
+// This program is free software: you can use, redistribute, and/or modify
+// it under the terms of the GNU Affero General Public License, version 3
+// or later ("AGPL"), as published by the Free Software Foundation.
+//
+// This program is distributed in the hope that it will be useful, but WITHOUT
+// ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+// FITNESS FOR A PARTICULAR PURPOSE.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package transform
+
+import (
+	"encoding/json"
+
+	"github.com/erda-project/erda/modules/hepa/apipolicy"
+	"github.com/erda-project/erda/modules/hepa/kong"
+	kongDto "github.com/erda-project/erda/modules/hepa/kong/dto"
+	"github.com/erda-project/erda/modules/hepa/repository/orm"
+	db "github.com/erda-project/erda/modules/hepa/repository/service"
+
+	"github.com/pkg/errors"
+)
+
+type Policy struct {
+	apipolicy.BasePolicy
+}
+
+func (policy Policy) CreateDefaultConfig(ctx map[string]interface{}) apipolicy.PolicyDto {
+	dto := &PolicyDto{
+		Request:  &RequestTransform{},
+		Response: &ResponseTransform{},
+	}
+	dto.Switch = false
+	return dto
+}
+
+func (policy Policy) UnmarshalConfig(config []byte) (apipolicy.PolicyDto, error, string) {
+	policyDto := &PolicyDto{}
+	err := json.Unmarshal(config, policyDto)
+	if err != nil {
+		return nil, errors.Wrapf(err, "json parse config failed, config:%s", config), "Invalid config"
+	}
+	ok, msg := policyDto.IsValidDto()
+	if !ok {
+		return nil, errors.Errorf("invalid policy dto, msg:%s", msg), msg
+	}
+	return policyDto, nil, ""
+}
+
+func renameList(renames []RenameDto) []string {
+	var res []string
+	for _, rename := range renames {
+		res = append(res, rename.From+":"+rename.To)
+	}
+	return res
+}
+
+func valueList(values map[string]string, excludes ...map[string]string) []string {
+	var res []string
+outer:
+	for _, name := range sortedKeys(values) {
+		for _, exclude := range excludes {
+			if _, exist := exclude[name]; exist {
+				continue outer
+			}
+		}
+		res = append(res, name+":"+values[name])
+	}
+	return res
+}
+
+// setSection 只写入非空的配置项，避免覆盖 kong 插件的默认值
+func setSection(config map[string]interface{}, section string, fields map[string][]string) {
+	item := map[string]interface{}{}
+	for field, values := range fields {
+		if len(values) > 0 {
+			item[field] = values
+		}
+	}
+	if len(item) > 0 {
+		config[section] = item
+	}
+}
+
+func (policy Policy) buildRequestPluginReq(req *RequestTransform) *kongDto.KongPluginReqDto {
+	disable := false
+	pluginReq := &kongDto.KongPluginReqDto{
+		Name:    REQUEST_PLUGIN,
+		Config:  map[string]interface{}{},
+		Enabled: &disable,
+	}
+	setSection(pluginReq.Config, "remove", map[string][]string{
+		"headers":     req.RemoveHeaders,
+		"querystring": req.RemoveQuery,
+		"body":        req.RemoveBody,
+	})
+	setSection(pluginReq.Config, "rename", map[string][]string{
+		"headers":     renameList(req.RenameHeaders),
+		"querystring": renameList(req.RenameQuery),
+		"body":        renameList(req.RenameBody),
+	})
+	// kong 的 replace 只作用于已存在的字段，配合 add 实现设置语义
+	setSection(pluginReq.Config, "replace", map[string][]string{
+		"headers":     valueList(req.SetHeaders),
+		"querystring": valueList(req.SetQuery),
+		"body":        valueList(req.SetBody),
+	})
+	setSection(pluginReq.Config, "add", map[string][]string{
+		"headers":     append(valueList(req.SetHeaders), valueList(req.AddHeaders, req.SetHeaders)...),
+		"querystring": append(valueList(req.SetQuery), valueList(req.AddQuery, req.SetQuery)...),
+		"body":        append(valueList(req.SetBody), valueList(req.AddBody, req.SetBody)...),
+	})
+	return pluginReq
+}
+
+func (policy Policy) buildResponsePluginReq(resp *ResponseTransform) *kongDto.KongPluginReqDto {
+	disable := false
+	pluginReq := &kongDto.KongPluginReqDto{
+		Name:    RESPONSE_PLUGIN,
+		Config:  map[string]interface{}{},
+		Enabled: &disable,
+	}
+	setSection(pluginReq.Config, "remove", map[string][]string{
+		"headers": resp.RemoveHeaders,
+		"json":    resp.RemoveJson,
+	})
+	setSection(pluginReq.Config, "rename", map[string][]string{
+		"headers": renameList(resp.RenameHeaders),
+	})
+	setSection(pluginReq.Config, "replace", map[string][]string{
+		"headers": valueList(resp.SetHeaders),
+		"json":    valueList(resp.SetJson),
+	})
+	setSection(pluginReq.Config, "add", map[string][]string{
+		"headers": append(valueList(resp.SetHeaders), valueList(resp.AddHeaders, resp.SetHeaders)...),
+		"json":    append(valueList(resp.SetJson), valueList(resp.AddJson, resp.SetJson)...),
+	})
+	return pluginReq
+}
+
+func (policy Policy) buildPluginReqs(dto *PolicyDto) map[string]*kongDto.KongPluginReqDto {
+	reqs := map[string]*kongDto.KongPluginReqDto{
+		REQUEST_PLUGIN:  nil,
+		RESPONSE_PLUGIN: nil,
+	}
+	if !dto.Switch {
+		return reqs
+	}
+	if dto.requestEnabled() {
+		reqs[REQUEST_PLUGIN] = policy.buildRequestPluginReq(dto.Request)
+	}
+	if dto.responseEnabled() {
+		reqs[RESPONSE_PLUGIN] = policy.buildResponsePluginReq(dto.Response)
+	}
+	return reqs
+}
+
+func (policy Policy) Preview(dto apipolicy.PolicyDto) (interface{}, error) {
+	policyDto, ok := dto.(*PolicyDto)
+	if !ok {
+		return nil, errors.Errorf("invalid config:%+v", dto)
+	}
+	res := []kongDto.KongPluginReqDto{}
+	reqs := policy.buildPluginReqs(policyDto)
+	for _, name := range []string{REQUEST_PLUGIN, RESPONSE_PLUGIN} {
+		if reqs[name] != nil {
+			res = append(res, *reqs[name])
+		}
+	}
+	return res, nil
+}
+
+func (policy Policy) touchPlugin(adapter kong.KongAdapter, zoneId, pluginName string, req *kongDto.KongPluginReqDto) (bool, error) {
+	policyDb, _ := db.NewGatewayPolicyServiceImpl()
+	exist, err := policyDb.GetByAny(&orm.GatewayPolicy{
+		ZoneId:     zoneId,
+		PluginName: pluginName,
+	})
+	if err != nil {
+		return false, err
+	}
+	if req == nil {
+		if exist == nil {
+			return false, nil
+		}
+		err = adapter.RemovePlugin(exist.PluginId)
+		if err != nil {
+			return false, err
+		}
+		_ = policyDb.DeleteById(exist.Id)
+		return true, nil
+	}
+	if exist != nil {
+		req.Id = exist.PluginId
+		resp, err := adapter.CreateOrUpdatePluginById(req)
+		if err != nil {
+			return false, err
+		}
+		configByte, err := json.Marshal(resp.Config)
+		if err != nil {
+			return false, err
+		}
+		exist.Config = configByte
+		err = policyDb.Update(exist)
+		if err != nil {
+			return false, err
+		}
+		return false, nil
+	}
+	resp, err := adapter.AddPlugin(req)
+	if err != nil {
+		return false, err
+	}
+	configByte, err := json.Marshal(resp.Config)
+	if err != nil {
+		return false, err
+	}
+	policyDao := &orm.GatewayPolicy{
+		ZoneId:     zoneId,
+		PluginName: pluginName,
+		Category:   "transform",
+		PluginId:   resp.Id,
+		Config:     configByte,
+		Enabled:    1,
+	}
+	err = policyDb.Insert(policyDao)
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (policy Policy) ParseConfig(dto apipolicy.PolicyDto, ctx map[string]interface{}) (apipolicy.PolicyConfig, error) {
+	res := apipolicy.PolicyConfig{}
+	policyDto, ok := dto.(*PolicyDto)
+	if !ok {
+		return res, errors.Errorf("invalid config:%+v", dto)
+	}
+	value, ok := ctx[apipolicy.CTX_KONG_ADAPTER]
+	if !ok {
+		return res, errors.Errorf("get identify failed:%+v", ctx)
+	}
+	adapter, ok := value.(kong.KongAdapter)
+	if !ok {
+		return res, errors.Errorf("convert failed:%+v", value)
+	}
+	value, ok = ctx[apipolicy.CTX_ZONE]
+	if !ok {
+		return res, errors.Errorf("get identify failed:%+v", ctx)
+	}
+	zone, ok := value.(*orm.GatewayZone)
+	if !ok {
+		return res, errors.Errorf("convert failed:%+v", value)
+	}
+	reqs := policy.buildPluginReqs(policyDto)
+	for _, name := range []string{REQUEST_PLUGIN, RESPONSE_PLUGIN} {
+		changed, err := policy.touchPlugin(adapter, zone.Id, name, reqs[name])
+		if err != nil {
+			return res, err
+		}
+		if changed {
+			res.KongPolicyChange = true
+		}
+	}
+	return res, nil
+}
+
+func init() {
+	err := apipolicy.RegisterPolicyEngine("transform", &Policy{})
+	if err != nil {
+		panic(err)
+	}
+}
@@ -0,0 +1,150 @@
+// Copyright (c) 2021 Terminus, Inc.
+//
+// This program is free software: you can use, redistribute, and/or modify
+// it under the terms of the GNU Affero General Public License, version 3
+// or later ("AGPL"), as published by the Free Software Foundation.
+//
+// This program is distributed in the hope that it will be useful, but WITHOUT
+// ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+// FITNESS FOR A PARTICULAR PURPOSE.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package transform
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/erda-project/erda/modules/hepa/apipolicy"
+	kongDto "github.com/erda-project/erda/modules/hepa/kong/dto"
+)
+
+func TestPolicyDto_IsValidDto(t *testing.T) {
+	tests := []struct {
+		name string
+		dto  PolicyDto
+		want bool
+	}{
+		{"disabled", PolicyDto{}, true},
+		{"empty rules", PolicyDto{BaseDto: apipolicy.BaseDto{Switch: true}, Request: &RequestTransform{}}, false},
+		{"set header", PolicyDto{BaseDto: apipolicy.BaseDto{Switch: true}, Request: &RequestTransform{
+			SetHeaders: map[string]string{"X-Env": "prod"},
+		}}, true},
+		{"invalid header", PolicyDto{BaseDto: apipolicy.BaseDto{Switch: true}, Request: &RequestTransform{
+			RemoveHeaders: []string{"X Env"},
+		}}, false},
+		{"invalid rename", PolicyDto{BaseDto: apipolicy.BaseDto{Switch: true}, Request: &RequestTransform{
+			RenameQuery: []RenameDto{{From: "a:b", To: "c"}},
+		}}, false},
+		{"value with comma", PolicyDto{BaseDto: apipolicy.BaseDto{Switch: true}, Request: &RequestTransform{
+			AddBody: map[string]string{"tags": "a,b"},
+		}}, false},
+		{"response json", PolicyDto{BaseDto: apipolicy.BaseDto{Switch: true}, Response: &ResponseTransform{
+			RemoveJson: []string{"password"},
+		}}, true},
+		{"invalid response header", PolicyDto{BaseDto: apipolicy.BaseDto{Switch: true}, Response: &ResponseTransform{
+			AddHeaders: map[string]string{"X:Trace": "1"},
+		}}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got, msg := tt.dto.IsValidDto(); got != tt.want {
+				t.Errorf("IsValidDto() = %v, want %v, msg: %s", got, tt.want, msg)
+			}
+		})
+	}
+}
+
+func TestPolicy_buildPluginReqs(t *testing.T) {
+	policy := Policy{}
+	tests := []struct {
+		name     string
+		dto      PolicyDto
+		request  map[string]interface{}
+		response map[string]interface{}
+	}{
+		{
+			name: "disabled",
+			dto: PolicyDto{Request: &RequestTransform{
+				RemoveHeaders: []string{"Cookie"},
+			}},
+		},
+		{
+			name: "request",
+			dto: PolicyDto{BaseDto: apipolicy.BaseDto{Switch: true}, Request: &RequestTransform{
+				RemoveHeaders: []string{"Cookie"},
+				RenameQuery:   []RenameDto{{From: "uid", To: "userId"}},
+				SetHeaders:    map[string]string{"X-Env": "prod"},
+				AddHeaders:    map[string]string{"X-Env": "dev", "X-From": "gateway"},
+			}, Response: &ResponseTransform{}},
+			request: map[string]interface{}{
+				"remove":  map[string]interface{}{"headers": []string{"Cookie"}},
+				"rename":  map[string]interface{}{"querystring": []string{"uid:userId"}},
+				"replace": map[string]interface{}{"headers": []string{"X-Env:prod"}},
+				"add":     map[string]interface{}{"headers": []string{"X-Env:prod", "X-From:gateway"}},
+			},
+		},
+		{
+			name: "response",
+			dto: PolicyDto{BaseDto: apipolicy.BaseDto{Switch: true}, Response: &ResponseTransform{
+				RemoveJson: []string{"password"},
+				SetJson:    map[string]string{"code": "0"},
+			}},
+			response: map[string]interface{}{
+				"remove":  map[string]interface{}{"json": []string{"password"}},
+				"replace": map[string]interface{}{"json": []string{"code:0"}},
+				"add":     map[string]interface{}{"json": []string{"code:0"}},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reqs := policy.buildPluginReqs(&tt.dto)
+			for name, want := range map[string]map[string]interface{}{REQUEST_PLUGIN: tt.request, RESPONSE_PLUGIN: tt.response} {
+				req := reqs[name]
+				if want == nil {
+					if req != nil {
+						t.Errorf("plugin %s should not be created, got: %+v", name, req.Config)
+					}
+					continue
+				}
+				if req == nil {
+					t.Fatalf("plugin %s not created", name)
+				}
+				if req.Name != name || req.Enabled == nil || *req.Enabled {
+					t.Errorf("unexpected plugin req: %+v", req)
+				}
+				if !reflect.DeepEqual(req.Config, want) {
+					t.Errorf("plugin %s config = %+v, want %+v", name, req.Config, want)
+				}
+			}
+		})
+	}
+}
+
+func TestPolicy_Preview(t *testing.T) {
+	policy := Policy{}
+	res, err := policy.Preview(&PolicyDto{BaseDto: apipolicy.BaseDto{Switch: true},
+		Request:  &RequestTransform{RemoveQuery: []string{"debug"}},
+		Response: &ResponseTransform{RemoveHeaders: []string{"Server"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	reqs, ok := res.([]kongDto.KongPluginReqDto)
+	if !ok || len(reqs) != 2 || reqs[0].Name != REQUEST_PLUGIN || reqs[1].Name != RESPONSE_PLUGIN {
+		t.Errorf("unexpected preview: %+v", res)
+	}
+	res, err = policy.Preview(&PolicyDto{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reqs, ok := res.([]kongDto.KongPluginReqDto); !ok || len(reqs) != 0 {
+		t.Errorf("unexpected preview of disabled policy: %+v", res)
+	}
+	if _, err = policy.Preview(nil); err == nil {
+		t.Errorf("expect error of invalid dto")
+	}
+}
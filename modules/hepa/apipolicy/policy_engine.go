@@ -65,6 +65,23 @@ type PolicyEngine interface {
 	NeedSerialUpdate() bool
 }
 
+// PolicyPreviewer 策略引擎的可选实现，用于预览策略最终生效的配置
+type PolicyPreviewer interface {
+	Preview(PolicyDto) (interface{}, error)
+}
+
+// PluginConfig 策略渲染出的 kong 插件，Config 为 nil 表示删除该插件
+type PluginConfig struct {
+	Name   string
+	Config map[string]interface{}
+}
+
+// PluginRender 策略引擎的可选实现，用于不经过 nginx ingress 的网关后端，
+// 将策略渲染为路由上的插件，返回策略对应的全部插件，未开启时插件配置为 nil
+type PluginRender interface {
+	RenderPlugins(PolicyDto) ([]PluginConfig, error)
+}
+
 var registerMap = map[string]PolicyEngine{}
 
 func GetPolicyEngine(name string) (PolicyEngine, error) {
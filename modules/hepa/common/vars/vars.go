@@ -98,7 +98,10 @@ var (
 	TRANSFORM_CALL_PARAMS_MISS  = StandardErrorCode{"GW_200002", "envType或runtimeId缺失"}
 
 	//mock数据
-	MOCK_IS_NOT_EXISTS = StandardErrorCode{"GW_300003", "mock接口不存在"}
+	MOCK_IS_NOT_EXISTS       = StandardErrorCode{"GW_300003", "mock接口不存在"}
+	MOCK_SPEC_INVALID        = StandardErrorCode{"GW_300004", "接口规范解析失败"}
+	MOCK_REQUEST_INVALID     = StandardErrorCode{"GW_300005", "请求不符合接口规范"}
+	MOCK_SCENARIO_NOT_EXISTS = StandardErrorCode{"GW_300006", "mock场景不存在"}
 
 	CLUSTER_NOT_EXIST = StandardErrorCode{"GW_400001", "集群查找失败"}
 	KONG_NOT_EXIST    = StandardErrorCode{"GW_400002", "kong服务查找失败"}
@@ -112,6 +115,18 @@ var (
 	INVALID_LIMIT_API    = StandardErrorCode{"GW_400009", "只能限制流量入口中已存在的API"}
 	API_IN_PACKAGE       = StandardErrorCode{"GW_400010", "API被其他流量入口引用,不可更改或删除"}
 	LIMIT_RULE_EXIST     = StandardErrorCode{"GW_400011", "该规则已存在，请直接编辑"}
+
+	INVALID_TRAFFIC_SPLIT     = StandardErrorCode{"GW_400012", "分流配置错误"}
+	TRAFFIC_SPLIT_NOT_SUPPORT = StandardErrorCode{"GW_400013", "只能对转发地址类型的API配置分流"}
+	TRAFFIC_RULE_NOT_SUPPORT  = StandardErrorCode{"GW_400014", "当前网关版本不支持此分流匹配规则"}
+	TRAFFIC_RULE_API_POLICY   = StandardErrorCode{"GW_400015", "API已配置独立的访问策略，不支持按请求头或Cookie分流"}
+
+	INVALID_RESILIENCE     = StandardErrorCode{"GW_400016", "容错配置错误"}
+	RESILIENCE_NOT_SUPPORT = StandardErrorCode{"GW_400017", "只能对转发地址类型的API配置容错"}
+
+	INVALID_ANALYTICS = StandardErrorCode{"GW_400018", "访问统计参数错误"}
+
+	QUOTA_LIMIT_CONFLICT = StandardErrorCode{"GW_400019", "调用方已配置配额，不能同时配置流量限制"}
 )
 
 type PolicyCategory struct {
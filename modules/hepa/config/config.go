@@ -53,6 +53,9 @@ type ServerConfig struct {
 	SpotTagsHeaderPrefix     string   `default:"terminus-request-bg-"`
 	SpotHostIpKey            string   `default:"HOST_IP"`
 	SpotInstanceKey          string   `default:"DICE_ADDON"`
+	SpotConsumerTag          string   `default:"consumer"`
+	SpotRouteTag             string   `default:"route_id"`
+	SpotLatencyBuckets       []string `default:""`
 	SubDomainSplit           string   `default:"-"`
 	HasRouteInfo             bool     `default:"true"`
 	UseAdminEndpoint         bool     `default:"false"`
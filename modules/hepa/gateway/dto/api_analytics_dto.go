@@ -0,0 +1,379 @@
+// Copyright (c) 2021 Terminus, Inc.
+//
+// This program is free software: you can use, redistribute, and/or modify
+// it under the terms of the GNU Affero General Public License, version 3
+// or later ("AGPL"), as published by the Free Software Foundation.
+//
+// This program is distributed in the hope that it will be useful, but WITHOUT
+// ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+// FITNESS FOR A PARTICULAR PURPOSE.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package dto
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+type AnalyticsGroupBy string
+
+const (
+	AG_API      AnalyticsGroupBy = "api"
+	AG_CONSUMER AnalyticsGroupBy = "consumer"
+
+	// 默认统计最近一小时, 单次最多统计31天
+	DEFAULT_ANALYTICS_RANGE = time.Hour
+	MAX_ANALYTICS_RANGE     = 31 * 24 * time.Hour
+	// 分组和调用方排行的默认数量
+	DEFAULT_ANALYTICS_LIMIT = 10
+	MAX_ANALYTICS_LIMIT     = 100
+
+	// 网关访问指标中的状态码标签
+	STATUS_CODE_TAG = "http_status_code"
+)
+
+// 指标聚合方式, 对应 metricq 的聚合函数和字段
+type MetricAggregation struct {
+	Func  string
+	Field string
+}
+
+// 查询结果中的列名, 如 sum.elapsed_count
+func (agg MetricAggregation) Column() string {
+	return agg.Func + "." + agg.Field
+}
+
+var (
+	REQUEST_COUNT_AGG = MetricAggregation{"sum", "elapsed_count"}
+	// 延时字段单位为秒, 平均延时由总延时和请求数计算, 不能对各统计点的 latency_mean 再求平均;
+	// 分位值由延时分布计算, 见 LatencyBuckets
+	LATENCY_SUM_AGG = MetricAggregation{"sum", "latency_sum"}
+	LATENCY_MAX_AGG = MetricAggregation{"max", "latency_max"}
+	LATENCY_AGGS    = []MetricAggregation{LATENCY_SUM_AGG, REQUEST_COUNT_AGG, LATENCY_MAX_AGG}
+)
+
+type ApiAnalyticsReqDto struct {
+	Start   time.Time
+	End     time.Time
+	GroupBy AnalyticsGroupBy
+	// 分组和调用方排行的数量
+	Limit int
+}
+
+// 解析毫秒时间戳形式的起止时间, 为空时统计截止到当前的最近一小时
+func ParseApiAnalyticsReq(start, end, groupBy, limit string, now time.Time) (*ApiAnalyticsReqDto, error) {
+	req := &ApiAnalyticsReqDto{
+		End:     now,
+		GroupBy: AnalyticsGroupBy(groupBy),
+		Limit:   DEFAULT_ANALYTICS_LIMIT,
+	}
+	if end != "" {
+		ms, err := strconv.ParseInt(end, 10, 64)
+		if err != nil {
+			return nil, errors.Errorf("invalid end: %s", end)
+		}
+		req.End = time.Unix(0, ms*int64(time.Millisecond))
+	}
+	req.Start = req.End.Add(-DEFAULT_ANALYTICS_RANGE)
+	if start != "" {
+		ms, err := strconv.ParseInt(start, 10, 64)
+		if err != nil {
+			return nil, errors.Errorf("invalid start: %s", start)
+		}
+		req.Start = time.Unix(0, ms*int64(time.Millisecond))
+	}
+	if limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil {
+			return nil, errors.Errorf("invalid limit: %s", limit)
+		}
+		req.Limit = n
+	}
+	return req, nil
+}
+
+func (dto ApiAnalyticsReqDto) CheckValid() error {
+	if !dto.End.After(dto.Start) {
+		return errors.New("end must be after start")
+	}
+	if dto.End.Sub(dto.Start) > MAX_ANALYTICS_RANGE {
+		return errors.Errorf("time range can't exceed %d days", MAX_ANALYTICS_RANGE/(24*time.Hour))
+	}
+	switch dto.GroupBy {
+	case "", AG_API, AG_CONSUMER:
+	default:
+		return errors.Errorf("invalid groupBy: %s", dto.GroupBy)
+	}
+	if dto.Limit <= 0 || dto.Limit > MAX_ANALYTICS_LIMIT {
+		return errors.Errorf("limit must be between 1 and %d", MAX_ANALYTICS_LIMIT)
+	}
+	return nil
+}
+
+// 延时分布的桶上限, 单位为毫秒, 由网关的 spot-collector 插件统计;
+// 访问指标中 latency_bucket_le_<上限> 为延时不超过上限的累计请求数, latency_bucket_le_inf 为全部请求数
+type LatencyBuckets []int
+
+// 解析配置的桶上限, 须为递增的正整数, 为空时访问指标中没有延时分布
+func ParseLatencyBuckets(bounds []string) (LatencyBuckets, error) {
+	var buckets LatencyBuckets
+	for _, bound := range bounds {
+		bound = strings.TrimSpace(bound)
+		if bound == "" {
+			continue
+		}
+		ms, err := strconv.Atoi(bound)
+		if err != nil || ms <= 0 {
+			return nil, errors.Errorf("invalid latency bucket: %s", bound)
+		}
+		if len(buckets) > 0 && ms <= buckets[len(buckets)-1] {
+			return nil, errors.New("latency buckets must be in ascending order")
+		}
+		buckets = append(buckets, ms)
+	}
+	return buckets, nil
+}
+
+func (buckets LatencyBuckets) aggs() []MetricAggregation {
+	aggs := make([]MetricAggregation, 0, len(buckets)+1)
+	for _, bound := range buckets {
+		aggs = append(aggs, MetricAggregation{"sum", "latency_bucket_le_" + strconv.Itoa(bound)})
+	}
+	return append(aggs, MetricAggregation{"sum", "latency_bucket_le_inf"})
+}
+
+// 延时统计需要查询的聚合, 配置了延时分布时查询各桶的累计请求数
+func (buckets LatencyBuckets) Aggs() []MetricAggregation {
+	if len(buckets) == 0 {
+		return LATENCY_AGGS
+	}
+	return append(append([]MetricAggregation{}, LATENCY_AGGS...), buckets.aggs()...)
+}
+
+// 按桶内均匀分布线性插值计算分位值, 最后一个桶的上限取最大延时, 单位为毫秒
+func (buckets LatencyBuckets) quantile(q float64, counts []float64, max float64) float64 {
+	rank := q * counts[len(counts)-1]
+	lower, lowerCount := 0.0, 0.0
+	for i, count := range counts {
+		upper := max
+		if i < len(buckets) && (max <= 0 || float64(buckets[i]) < max) {
+			upper = float64(buckets[i])
+		}
+		if count >= rank {
+			if upper < lower {
+				upper = lower
+			}
+			return lower + (upper-lower)*(rank-lowerCount)/(count-lowerCount)
+		}
+		lower, lowerCount = upper, count
+	}
+	return max
+}
+
+// 延时统计, 单位为毫秒
+type ApiLatencyDto struct {
+	Avg float64 `json:"avg"`
+	Max float64 `json:"max"`
+	// 分位值, 访问指标中没有延时分布时为空
+	P50 float64 `json:"p50,omitempty"`
+	P90 float64 `json:"p90,omitempty"`
+	P95 float64 `json:"p95,omitempty"`
+	P99 float64 `json:"p99,omitempty"`
+}
+
+func newApiLatencyDto(values map[string]float64, buckets LatencyBuckets) *ApiLatencyDto {
+	round := func(ms float64) float64 {
+		return math.Round(ms*100) / 100
+	}
+	max := values[LATENCY_MAX_AGG.Column()] * 1000
+	dto := &ApiLatencyDto{Max: round(max)}
+	if count := values[REQUEST_COUNT_AGG.Column()]; count > 0 {
+		dto.Avg = round(values[LATENCY_SUM_AGG.Column()] / count * 1000)
+	}
+	if len(buckets) == 0 {
+		return dto
+	}
+	var counts []float64
+	for _, agg := range buckets.aggs() {
+		counts = append(counts, values[agg.Column()])
+	}
+	if counts[len(counts)-1] <= 0 {
+		return dto
+	}
+	dto.P50 = round(buckets.quantile(0.5, counts, max))
+	dto.P90 = round(buckets.quantile(0.9, counts, max))
+	dto.P95 = round(buckets.quantile(0.95, counts, max))
+	dto.P99 = round(buckets.quantile(0.99, counts, max))
+	return dto
+}
+
+type ApiAccessStatsDto struct {
+	// 分组值, 按 API 分组时为 API id, 按调用方分组时为调用方 id
+	Key  string `json:"key"`
+	Name string `json:"name"`
+	// 请求数和 4xx、5xx 请求数
+	Requests     int64 `json:"requests"`
+	ClientErrors int64 `json:"clientErrors"`
+	ServerErrors int64 `json:"serverErrors"`
+	// 4xx 和 5xx 请求占比
+	ErrorRate float64        `json:"errorRate"`
+	Latency   *ApiLatencyDto `json:"latency,omitempty"`
+}
+
+type ApiAnalyticsDto struct {
+	Start   string              `json:"start"`
+	End     string              `json:"end"`
+	GroupBy AnalyticsGroupBy    `json:"groupBy"`
+	Summary ApiAccessStatsDto   `json:"summary"`
+	Groups  []ApiAccessStatsDto `json:"groups"`
+	// 请求数最多的调用方
+	TopClients []ApiAccessStatsDto `json:"topClients"`
+}
+
+// 网关访问指标按标签分组后的一个统计点
+type AccessMetricPoint struct {
+	// 分组标签值, 顺序与查询时的分组一致
+	Tags []string
+	// key 为查询结果中的列名
+	Values map[string]float64
+}
+
+type accessMetricResp struct {
+	Data struct {
+		Results []struct {
+			Data []map[string]json.RawMessage `json:"data"`
+		} `json:"results"`
+	} `json:"data"`
+}
+
+type accessMetricColumn struct {
+	Tag  interface{} `json:"tag"`
+	Data interface{} `json:"data"`
+}
+
+// 解析 metricq chart 格式的查询结果, 多级分组时逐级展开
+func ParseAccessMetrics(body []byte) ([]AccessMetricPoint, error) {
+	resp := accessMetricResp{}
+	err := json.Unmarshal(body, &resp)
+	if err != nil {
+		return nil, errors.Wrapf(err, "json unmarshal failed, body:%s", body)
+	}
+	var points []AccessMetricPoint
+	for _, result := range resp.Data.Results {
+		res, err := parseAccessMetricItems(nil, result.Data)
+		if err != nil {
+			return nil, err
+		}
+		points = append(points, res...)
+	}
+	return points, nil
+}
+
+func parseAccessMetricItems(tags []string, items []map[string]json.RawMessage) ([]AccessMetricPoint, error) {
+	var points []AccessMetricPoint
+	for _, item := range items {
+		// 非最后一级分组的结构为 {"tag": .., "total": .., "data": [..]}
+		if _, ok := item["total"]; ok {
+			var tag interface{}
+			var children []map[string]json.RawMessage
+			if err := json.Unmarshal(item["tag"], &tag); err != nil {
+				return nil, errors.WithStack(err)
+			}
+			if err := json.Unmarshal(item["data"], &children); err != nil {
+				return nil, errors.WithStack(err)
+			}
+			res, err := parseAccessMetricItems(appendTag(tags, tag), children)
+			if err != nil {
+				return nil, err
+			}
+			points = append(points, res...)
+			continue
+		}
+		var tag interface{}
+		values := map[string]float64{}
+		for key, raw := range item {
+			column := accessMetricColumn{}
+			if err := json.Unmarshal(raw, &column); err != nil {
+				return nil, errors.WithStack(err)
+			}
+			if column.Tag != nil {
+				tag = column.Tag
+			}
+			if value, ok := column.Data.(float64); ok {
+				values[key] = value
+			}
+		}
+		point := AccessMetricPoint{Tags: tags, Values: values}
+		if tag != nil {
+			point.Tags = appendTag(tags, tag)
+		}
+		points = append(points, point)
+	}
+	return points, nil
+}
+
+func appendTag(tags []string, tag interface{}) []string {
+	res := append([]string{}, tags...)
+	if tag == nil {
+		return append(res, "")
+	}
+	return append(res, fmt.Sprint(tag))
+}
+
+// 合并请求数和延时统计, counts 的最后一级分组为状态码, 其余分组与 latencies 一致;
+// 结果按请求数降序排列
+func MergeAccessStats(counts, latencies []AccessMetricPoint, buckets LatencyBuckets) []ApiAccessStatsDto {
+	statsMap := map[string]*ApiAccessStatsDto{}
+	get := func(tags []string) *ApiAccessStatsDto {
+		key := strings.Join(tags, "/")
+		stats, ok := statsMap[key]
+		if !ok {
+			stats = &ApiAccessStatsDto{Key: key, Name: key}
+			statsMap[key] = stats
+		}
+		return stats
+	}
+	for _, point := range counts {
+		if len(point.Tags) == 0 {
+			continue
+		}
+		stats := get(point.Tags[:len(point.Tags)-1])
+		requests := int64(point.Values[REQUEST_COUNT_AGG.Column()])
+		stats.Requests += requests
+		code, _ := strconv.Atoi(point.Tags[len(point.Tags)-1])
+		switch {
+		case code >= 500:
+			stats.ServerErrors += requests
+		case code >= 400:
+			stats.ClientErrors += requests
+		}
+	}
+	for _, point := range latencies {
+		get(point.Tags).Latency = newApiLatencyDto(point.Values, buckets)
+	}
+	res := []ApiAccessStatsDto{}
+	for _, stats := range statsMap {
+		if stats.Requests > 0 {
+			errorRate := float64(stats.ClientErrors+stats.ServerErrors) / float64(stats.Requests)
+			stats.ErrorRate = math.Round(errorRate*10000) / 10000
+		}
+		res = append(res, *stats)
+	}
+	sort.Slice(res, func(i, j int) bool {
+		if res[i].Requests != res[j].Requests {
+			return res[i].Requests > res[j].Requests
+		}
+		return res[i].Key < res[j].Key
+	})
+	return res
+}
@@ -0,0 +1,190 @@
+// Copyright (c) 2021 Terminus, Inc.
+//
+// This program is free software: you can use, redistribute, and/or modify
+// it under the terms of the GNU Affero General Public License, version 3
+// or later ("AGPL"), as published by the Free Software Foundation.
+//
+// This program is distributed in the hope that it will be useful, but WITHOUT
+// ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+// FITNESS FOR A PARTICULAR PURPOSE.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package dto
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseApiAnalyticsReq(t *testing.T) {
+	now := time.Date(2021, 7, 5, 12, 0, 0, 0, time.UTC)
+	req, err := ParseApiAnalyticsReq("", "", "", "", now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !req.End.Equal(now) || !req.Start.Equal(now.Add(-time.Hour)) || req.Limit != DEFAULT_ANALYTICS_LIMIT {
+		t.Errorf("unexpected default req: %+v", req)
+	}
+	req, err = ParseApiAnalyticsReq("1625400000000", "1625486400000", "consumer", "5", now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if req.Start.Unix() != 1625400000 || req.End.Unix() != 1625486400 || req.GroupBy != AG_CONSUMER || req.Limit != 5 {
+		t.Errorf("unexpected req: %+v", req)
+	}
+	for _, args := range [][]string{{"yesterday", ""}, {"", "now"}} {
+		if _, err := ParseApiAnalyticsReq(args[0], args[1], "", "", now); err == nil {
+			t.Errorf("ParseApiAnalyticsReq(%q, %q) expect error", args[0], args[1])
+		}
+	}
+	if _, err := ParseApiAnalyticsReq("", "", "", "ten", now); err == nil {
+		t.Errorf("ParseApiAnalyticsReq() expect error of limit")
+	}
+}
+
+func TestApiAnalyticsReqDto_CheckValid(t *testing.T) {
+	end := time.Date(2021, 7, 5, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name    string
+		dto     ApiAnalyticsReqDto
+		wantErr bool
+	}{
+		{"default", ApiAnalyticsReqDto{Start: end.Add(-time.Hour), End: end, Limit: 10}, false},
+		{"group by consumer", ApiAnalyticsReqDto{Start: end.AddDate(0, 0, -7), End: end, GroupBy: AG_CONSUMER, Limit: 100}, false},
+		{"end before start", ApiAnalyticsReqDto{Start: end, End: end.Add(-time.Hour), Limit: 10}, true},
+		{"range too long", ApiAnalyticsReqDto{Start: end.AddDate(0, -2, 0), End: end, Limit: 10}, true},
+		{"invalid groupBy", ApiAnalyticsReqDto{Start: end.Add(-time.Hour), End: end, GroupBy: "method", Limit: 10}, true},
+		{"zero limit", ApiAnalyticsReqDto{Start: end.Add(-time.Hour), End: end}, true},
+		{"limit too large", ApiAnalyticsReqDto{Start: end.Add(-time.Hour), End: end, Limit: 1000}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.dto.CheckValid(); (err != nil) != tt.wantErr {
+				t.Errorf("CheckValid() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestParseAccessMetrics(t *testing.T) {
+	body := []byte(`{"success":true,"data":{"results":[{"name":"application_http","data":[
+		{"tag":"r1","total":3,"data":[
+			{"sum.elapsed_count":{"agg":"sum","tag":"200","data":90}},
+			{"sum.elapsed_count":{"agg":"sum","tag":"404","data":6}},
+			{"sum.elapsed_count":{"agg":"sum","tag":"502","data":4}}
+		]},
+		{"tag":"r2","total":1,"data":[
+			{"sum.elapsed_count":{"agg":"sum","tag":"200","data":10}}
+		]}
+	]}]}}`)
+	counts, err := ParseAccessMetrics(body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(counts) != 4 || counts[2].Tags[0] != "r1" || counts[2].Tags[1] != "502" || counts[2].Values["sum.elapsed_count"] != 4 {
+		t.Errorf("unexpected counts: %+v", counts)
+	}
+	body = []byte(`{"success":true,"data":{"results":[{"name":"application_http","data":[
+		{"sum.latency_sum":{"agg":"sum","tag":"r1","data":2},"sum.elapsed_count":{"agg":"sum","tag":"r1","data":100},"max.latency_max":{"agg":"max","tag":"r1","data":0.1234}},
+		{"sum.latency_sum":{"agg":"sum","tag":"r2","data":null},"sum.elapsed_count":{"agg":"sum","tag":"r2","data":null},"max.latency_max":{"agg":"max","tag":"r2","data":null}}
+	]}]}}`)
+	latencies, err := ParseAccessMetrics(body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(latencies) != 2 || latencies[0].Tags[0] != "r1" || len(latencies[1].Values) != 0 {
+		t.Errorf("unexpected latencies: %+v", latencies)
+	}
+	stats := MergeAccessStats(counts, latencies, nil)
+	if len(stats) != 2 {
+		t.Fatalf("unexpected stats: %+v", stats)
+	}
+	r1 := stats[0]
+	if r1.Key != "r1" || r1.Requests != 100 || r1.ClientErrors != 6 || r1.ServerErrors != 4 || r1.ErrorRate != 0.1 {
+		t.Errorf("unexpected stats: %+v", r1)
+	}
+	if r1.Latency == nil || r1.Latency.Avg != 20 || r1.Latency.Max != 123.4 {
+		t.Errorf("unexpected latency: %+v", r1.Latency)
+	}
+	if stats[1].Key != "r2" || stats[1].Requests != 10 || stats[1].ErrorRate != 0 {
+		t.Errorf("unexpected stats: %+v", stats[1])
+	}
+
+	// 不分组时只有一个统计点
+	body = []byte(`{"success":true,"data":{"results":[{"name":"application_http","data":[
+		{"sum.latency_sum":{"agg":"sum","tag":null,"data":0.5},"sum.elapsed_count":{"agg":"sum","tag":null,"data":10}}
+	]}]}}`)
+	latencies, err = ParseAccessMetrics(body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	counts = []AccessMetricPoint{
+		{Tags: []string{"200"}, Values: map[string]float64{"sum.elapsed_count": 8}},
+		{Tags: []string{"500"}, Values: map[string]float64{"sum.elapsed_count": 2}},
+	}
+	stats = MergeAccessStats(counts, latencies, nil)
+	if len(stats) != 1 || stats[0].Key != "" || stats[0].Requests != 10 || stats[0].ErrorRate != 0.2 || stats[0].Latency.Avg != 50 {
+		t.Errorf("unexpected summary: %+v", stats)
+	}
+}
+
+func TestParseLatencyBuckets(t *testing.T) {
+	buckets, err := ParseLatencyBuckets([]string{"10", " 100", "1000"})
+	if err != nil || len(buckets) != 3 || buckets[1] != 100 {
+		t.Errorf("unexpected buckets: %v, err:%v", buckets, err)
+	}
+	if aggs := buckets.Aggs(); len(aggs) != len(LATENCY_AGGS)+4 || aggs[len(aggs)-1].Column() != "sum.latency_bucket_le_inf" {
+		t.Errorf("unexpected aggs: %+v", aggs)
+	}
+	buckets, err = ParseLatencyBuckets([]string{""})
+	if err != nil || len(buckets) != 0 || len(buckets.Aggs()) != len(LATENCY_AGGS) {
+		t.Errorf("unexpected buckets: %v, err:%v", buckets, err)
+	}
+	for _, bounds := range [][]string{{"0.5"}, {"0"}, {"100", "10"}, {"10", "10"}} {
+		if _, err = ParseLatencyBuckets(bounds); err == nil {
+			t.Errorf("ParseLatencyBuckets(%v) want error, got nil", bounds)
+		}
+	}
+}
+
+func TestMergeAccessStats_Percentiles(t *testing.T) {
+	buckets := LatencyBuckets{10, 100, 1000}
+	latencies := []AccessMetricPoint{{Tags: []string{"r1"}, Values: map[string]float64{
+		"sum.latency_sum":            20,
+		"sum.elapsed_count":          100,
+		"max.latency_max":            2,
+		"sum.latency_bucket_le_10":   50,
+		"sum.latency_bucket_le_100":  90,
+		"sum.latency_bucket_le_1000": 98,
+		"sum.latency_bucket_le_inf":  100,
+	}}, {Tags: []string{"r2"}, Values: map[string]float64{
+		"sum.latency_sum":           0.05,
+		"sum.elapsed_count":         2,
+		"max.latency_max":           0.05,
+		"sum.latency_bucket_le_10":  1,
+		"sum.latency_bucket_le_100": 2,
+		"sum.latency_bucket_le_inf": 2,
+	}}, {Tags: []string{"r3"}, Values: map[string]float64{}}}
+	counts := []AccessMetricPoint{
+		{Tags: []string{"r1", "200"}, Values: map[string]float64{"sum.elapsed_count": 100}},
+		{Tags: []string{"r2", "200"}, Values: map[string]float64{"sum.elapsed_count": 2}},
+	}
+	stats := MergeAccessStats(counts, latencies, buckets)
+	if len(stats) != 3 {
+		t.Fatalf("unexpected stats: %+v", stats)
+	}
+	// 桶内线性插值, 最后一个桶的上限为最大延时
+	if l := stats[0].Latency; l.Avg != 200 || l.Max != 2000 || l.P50 != 10 || l.P90 != 100 || l.P95 != 662.5 || l.P99 != 1500 {
+		t.Errorf("unexpected latency: %+v", l)
+	}
+	// 桶上限超过最大延时时取最大延时
+	if l := stats[1].Latency; l.Avg != 25 || l.P50 != 10 || l.P90 != 42 || l.P99 != 49.2 {
+		t.Errorf("unexpected latency: %+v", l)
+	}
+	// 没有延时分布时不提供分位值
+	if l := stats[2].Latency; l.P50 != 0 || l.P99 != 0 {
+		t.Errorf("unexpected latency: %+v", l)
+	}
+}
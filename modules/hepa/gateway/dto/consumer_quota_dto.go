@@ -0,0 +1,160 @@
+// Copyright (c) 2021 Terminus, Inc.
+//
+// This program is free software: you can use, redistribute, and/or modify
+// it under the terms of the GNU Affero General Public License, version 3
+// or later ("AGPL"), as published by the Free Software Foundation.
+//
+// This program is distributed in the hope that it will be useful, but WITHOUT
+// ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+// FITNESS FOR A PARTICULAR PURPOSE.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package dto
+
+import (
+	"bytes"
+	"encoding/csv"
+	"strconv"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+type QuotaPeriod string
+
+const (
+	QP_DAY   QuotaPeriod = "day"
+	QP_MONTH QuotaPeriod = "month"
+
+	// 用量导出的月份格式
+	USAGE_MONTH_LAYOUT = "2006-01"
+)
+
+type ConsumerQuotaDto struct {
+	// 计费周期, day 或 month
+	Period QuotaPeriod `json:"period"`
+	// 周期内允许的请求总数
+	Quota int64 `json:"quota"`
+	// 每秒允许的突发请求数, 为 0 时不限制
+	Burst int `json:"burst"`
+}
+
+func (dto ConsumerQuotaDto) CheckValid() error {
+	if dto.Period != QP_DAY && dto.Period != QP_MONTH {
+		return errors.Errorf("invalid period: %s", dto.Period)
+	}
+	if dto.Quota <= 0 {
+		return errors.New("quota must be positive")
+	}
+	if dto.Burst < 0 {
+		return errors.New("burst can't be negative")
+	}
+	if dto.Burst > 0 && int64(dto.Burst) > dto.Quota {
+		return errors.New("burst can't be greater than quota")
+	}
+	return nil
+}
+
+// kong rate-limiting 插件配置, 插件只挂载在消费者上, 按消费者计数
+func (dto ConsumerQuotaDto) KongConfig() map[string]interface{} {
+	config := map[string]interface{}{
+		string(dto.Period): dto.Quota,
+		"limit_by":         "consumer",
+		"fault_tolerant":   true,
+	}
+	if dto.Burst > 0 {
+		config["second"] = dto.Burst
+	}
+	return config
+}
+
+// 计费周期的起止时间, kong 按 UTC 时间划分周期, 这里保持一致
+func (dto ConsumerQuotaDto) PeriodRange(now time.Time) (time.Time, time.Time) {
+	now = now.UTC()
+	if dto.Period == QP_DAY {
+		start := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+		return start, start.AddDate(0, 0, 1)
+	}
+	return MonthRange(now)
+}
+
+func MonthRange(t time.Time) (time.Time, time.Time) {
+	t = t.UTC()
+	start := time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+	return start, start.AddDate(0, 1, 0)
+}
+
+// 解析形如 2021-06 的月份, 为空时取当前月份
+func ParseUsageMonth(month string, now time.Time) (time.Time, time.Time, error) {
+	if month == "" {
+		start, end := MonthRange(now)
+		return start, end, nil
+	}
+	t, err := time.ParseInLocation(USAGE_MONTH_LAYOUT, month, time.UTC)
+	if err != nil {
+		return time.Time{}, time.Time{}, errors.Errorf("invalid month: %s", month)
+	}
+	start, end := MonthRange(t)
+	return start, end, nil
+}
+
+type ConsumerQuotaInfoDto struct {
+	ConsumerId   string `json:"consumerId"`
+	ConsumerName string `json:"consumerName"`
+	ConsumerQuotaDto
+	// 当前周期已用请求数和剩余配额
+	Used        int64  `json:"used"`
+	Remaining   int64  `json:"remaining"`
+	PeriodStart string `json:"periodStart"`
+	PeriodEnd   string `json:"periodEnd"`
+}
+
+func (dto *ConsumerQuotaInfoDto) SetUsage(used int64, start, end time.Time) {
+	dto.Used = used
+	dto.Remaining = dto.Quota - used
+	if dto.Remaining < 0 {
+		dto.Remaining = 0
+	}
+	dto.PeriodStart = start.Format(time.RFC3339)
+	dto.PeriodEnd = end.Format(time.RFC3339)
+}
+
+type ConsumerUsageDto struct {
+	ConsumerId   string
+	ConsumerName string
+	ConsumerType string
+	Az           string
+	Month        string
+	Requests     int64
+	// 未配置配额时为空
+	Period QuotaPeriod
+	Quota  int64
+}
+
+var consumerUsageCsvHeader = []string{"consumer_id", "consumer_name", "consumer_type", "cluster", "month", "requests", "quota_period", "quota"}
+
+func ConsumerUsageToCsv(usages []ConsumerUsageDto) ([]byte, error) {
+	buffer := &bytes.Buffer{}
+	writer := csv.NewWriter(buffer)
+	if err := writer.Write(consumerUsageCsvHeader); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	for _, usage := range usages {
+		quota := ""
+		if usage.Period != "" {
+			quota = strconv.FormatInt(usage.Quota, 10)
+		}
+		err := writer.Write([]string{usage.ConsumerId, usage.ConsumerName, usage.ConsumerType, usage.Az,
+			usage.Month, strconv.FormatInt(usage.Requests, 10), string(usage.Period), quota})
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+	}
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return buffer.Bytes(), nil
+}
@@ -0,0 +1,89 @@
+// Copyright (c) 2021 Terminus, Inc.
+//
+// This program is free software: you can use, redistribute, and/or modify
+// it under the terms of the GNU Affero General Public License, version 3
+// or later ("AGPL"), as published by the Free Software Foundation.
+//
+// This program is distributed in the hope that it will be useful, but WITHOUT
+// ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+// FITNESS FOR A PARTICULAR PURPOSE.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package dto
+
+import (
+	"testing"
+	"time"
+)
+
+func TestConsumerQuotaDto_CheckValid(t *testing.T) {
+	tests := []struct {
+		name    string
+		dto     ConsumerQuotaDto
+		wantErr bool
+	}{
+		{"month", ConsumerQuotaDto{Period: QP_MONTH, Quota: 100000, Burst: 10}, false},
+		{"day without burst", ConsumerQuotaDto{Period: QP_DAY, Quota: 1000}, false},
+		{"invalid period", ConsumerQuotaDto{Period: "week", Quota: 1000}, true},
+		{"zero quota", ConsumerQuotaDto{Period: QP_DAY}, true},
+		{"negative burst", ConsumerQuotaDto{Period: QP_DAY, Quota: 1000, Burst: -1}, true},
+		{"burst greater than quota", ConsumerQuotaDto{Period: QP_DAY, Quota: 10, Burst: 20}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.dto.CheckValid(); (err != nil) != tt.wantErr {
+				t.Errorf("CheckValid() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestConsumerQuotaDto_KongConfig(t *testing.T) {
+	config := ConsumerQuotaDto{Period: QP_MONTH, Quota: 100000, Burst: 10}.KongConfig()
+	if config["month"] != int64(100000) || config["second"] != 10 || config["limit_by"] != "consumer" {
+		t.Errorf("unexpected config: %+v", config)
+	}
+	config = ConsumerQuotaDto{Period: QP_DAY, Quota: 1000}.KongConfig()
+	if _, ok := config["second"]; ok || config["day"] != int64(1000) {
+		t.Errorf("unexpected config: %+v", config)
+	}
+}
+
+func TestConsumerQuotaDto_PeriodRange(t *testing.T) {
+	now := time.Date(2021, 6, 30, 23, 30, 0, 0, time.FixedZone("CST", -8*3600))
+	start, end := ConsumerQuotaDto{Period: QP_DAY}.PeriodRange(now)
+	if !start.Equal(time.Date(2021, 7, 1, 0, 0, 0, 0, time.UTC)) || !end.Equal(time.Date(2021, 7, 2, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("unexpected day range: %s - %s", start, end)
+	}
+	start, end = ConsumerQuotaDto{Period: QP_MONTH}.PeriodRange(now)
+	if !start.Equal(time.Date(2021, 7, 1, 0, 0, 0, 0, time.UTC)) || !end.Equal(time.Date(2021, 8, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("unexpected month range: %s - %s", start, end)
+	}
+
+	start, end, err := ParseUsageMonth("2021-12", now)
+	if err != nil || !start.Equal(time.Date(2021, 12, 1, 0, 0, 0, 0, time.UTC)) || !end.Equal(time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("unexpected usage month: %s - %s, err:%v", start, end, err)
+	}
+	if _, _, err = ParseUsageMonth("2021/12", now); err == nil {
+		t.Error("expect error for invalid month")
+	}
+}
+
+func TestConsumerUsageToCsv(t *testing.T) {
+	data, err := ConsumerUsageToCsv([]ConsumerUsageDto{
+		{ConsumerId: "c1", ConsumerName: "app,one", ConsumerType: "project", Az: "terminus-dev", Month: "2021-06",
+			Requests: 1024, Period: QP_MONTH, Quota: 5000},
+		{ConsumerId: "c2", ConsumerName: "client", ConsumerType: "apim_client", Az: "terminus-dev", Month: "2021-06"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "consumer_id,consumer_name,consumer_type,cluster,month,requests,quota_period,quota\n" +
+		"c1,\"app,one\",project,terminus-dev,2021-06,1024,month,5000\n" +
+		"c2,client,apim_client,terminus-dev,2021-06,0,,\n"
+	if string(data) != expected {
+		t.Errorf("unexpected csv:\n%s", data)
+	}
+}
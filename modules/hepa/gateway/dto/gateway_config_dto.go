@@ -0,0 +1,470 @@
+// Copyright (c) 2021 Terminus, Inc.
+//
+// This program is free software: you can use, redistribute, and/or modify
+// it under the terms of the GNU Affero General Public License, version 3
+// or later ("AGPL"), as published by the Free Software Foundation.
+//
+// This program is distributed in the hope that it will be useful, but WITHOUT
+// ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+// FITNESS FOR A PARTICULAR PURPOSE.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package dto
+
+import (
+	"encoding/json"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/ghodss/yaml"
+	"github.com/pkg/errors"
+)
+
+const GATEWAY_CONFIG_VERSION = "hepa/v1"
+
+// PolicyConfigs key 为策略类别，value 为策略配置
+type PolicyConfigs map[string]map[string]interface{}
+
+type ApiConfigDto struct {
+	Method              string        `json:"method,omitempty"`
+	ApiPath             string        `json:"apiPath"`
+	RedirectType        string        `json:"redirectType"`
+	RedirectAddr        string        `json:"redirectAddr,omitempty"`
+	RedirectPath        string        `json:"redirectPath,omitempty"`
+	RedirectApp         string        `json:"redirectApp,omitempty"`
+	RedirectService     string        `json:"redirectService,omitempty"`
+	RedirectRuntimeName string        `json:"redirectRuntimeName,omitempty"`
+	AllowPassAuth       bool          `json:"allowPassAuth,omitempty"`
+	Description         string        `json:"description,omitempty"`
+	Protocol            string        `json:"protocol,omitempty"`
+	Grpc                *GrpcRouteDto `json:"grpc,omitempty"`
+	IdleTimeout         int           `json:"idleTimeout,omitempty"`
+	Policies            PolicyConfigs `json:"policies,omitempty"`
+}
+
+type PackageConfigDto struct {
+	Name        string         `json:"name"`
+	BindDomain  []string       `json:"bindDomain"`
+	AuthType    string         `json:"authType,omitempty"`
+	AclType     string         `json:"aclType,omitempty"`
+	Scene       string         `json:"scene"`
+	Description string         `json:"description,omitempty"`
+	Policies    PolicyConfigs  `json:"policies,omitempty"`
+	Apis        []ApiConfigDto `json:"apis,omitempty"`
+}
+
+// ConsumerConfigDto 不包含凭证信息，凭证由各环境独立生成
+type ConsumerConfigDto struct {
+	Name        string   `json:"name"`
+	Description string   `json:"description,omitempty"`
+	Packages    []string `json:"packages,omitempty"`
+}
+
+type GatewayConfigDto struct {
+	Version   string              `json:"version"`
+	Packages  []PackageConfigDto  `json:"packages"`
+	Consumers []ConsumerConfigDto `json:"consumers"`
+}
+
+type ConfigChangeAction string
+
+const (
+	CCA_CREATE ConfigChangeAction = "create"
+	CCA_UPDATE ConfigChangeAction = "update"
+	// 对于策略表示关闭
+	CCA_DELETE ConfigChangeAction = "delete"
+)
+
+type ConfigKind string
+
+const (
+	CK_PACKAGE        ConfigKind = "package"
+	CK_API            ConfigKind = "api"
+	CK_PACKAGE_POLICY ConfigKind = "packagePolicy"
+	CK_API_POLICY     ConfigKind = "apiPolicy"
+	CK_CONSUMER       ConfigKind = "consumer"
+	CK_CONSUMER_ACL   ConfigKind = "consumerAcl"
+)
+
+type ConfigChangeDto struct {
+	Action   ConfigChangeAction `json:"action"`
+	Kind     ConfigKind         `json:"kind"`
+	Package  string             `json:"package,omitempty"`
+	Api      string             `json:"api,omitempty"`
+	Policy   string             `json:"policy,omitempty"`
+	Consumer string             `json:"consumer,omitempty"`
+	Fields   []string           `json:"fields,omitempty"`
+}
+
+type ConfigPlanDto struct {
+	DryRun  bool              `json:"dryRun"`
+	Changes []ConfigChangeDto `json:"changes"`
+}
+
+func ApiConfigKey(method, apiPath string) string {
+	if method == "" {
+		return apiPath
+	}
+	return strings.ToUpper(method) + " " + apiPath
+}
+
+func (dto ApiConfigDto) Key() string {
+	return ApiConfigKey(dto.Method, dto.ApiPath)
+}
+
+func (dto ApiConfigDto) OpenapiDto() *OpenapiDto {
+	return &OpenapiDto{
+		ApiPath:             dto.ApiPath,
+		Method:              dto.Method,
+		RedirectType:        dto.RedirectType,
+		RedirectAddr:        dto.RedirectAddr,
+		RedirectPath:        dto.RedirectPath,
+		RedirectApp:         dto.RedirectApp,
+		RedirectService:     dto.RedirectService,
+		RedirectRuntimeName: dto.RedirectRuntimeName,
+		AllowPassAuth:       dto.AllowPassAuth,
+		Description:         dto.Description,
+		Protocol:            dto.Protocol,
+		Grpc:                dto.Grpc,
+		IdleTimeout:         dto.IdleTimeout,
+	}
+}
+
+func (dto PackageConfigDto) PackageDto() *PackageDto {
+	return &PackageDto{
+		Name:        dto.Name,
+		BindDomain:  append([]string{}, dto.BindDomain...),
+		AuthType:    dto.AuthType,
+		AclType:     dto.AclType,
+		Scene:       dto.Scene,
+		Description: dto.Description,
+	}
+}
+
+func (dto PackageConfigDto) GetApi(key string) *ApiConfigDto {
+	for i := range dto.Apis {
+		if dto.Apis[i].Key() == key {
+			return &dto.Apis[i]
+		}
+	}
+	return nil
+}
+
+func (dto GatewayConfigDto) GetPackage(name string) *PackageConfigDto {
+	for i := range dto.Packages {
+		if dto.Packages[i].Name == name {
+			return &dto.Packages[i]
+		}
+	}
+	return nil
+}
+
+func (dto GatewayConfigDto) GetConsumer(name string) *ConsumerConfigDto {
+	for i := range dto.Consumers {
+		if dto.Consumers[i].Name == name {
+			return &dto.Consumers[i]
+		}
+	}
+	return nil
+}
+
+func sortedUniq(list []string) []string {
+	var res []string
+	exist := map[string]bool{}
+	for _, item := range list {
+		item = strings.TrimSpace(item)
+		if item == "" || exist[item] {
+			continue
+		}
+		exist[item] = true
+		res = append(res, item)
+	}
+	sort.Strings(res)
+	return res
+}
+
+// Normalize 统一格式，保证导出结果稳定以及对比时不受顺序影响
+func (dto *GatewayConfigDto) Normalize() {
+	for i := range dto.Packages {
+		pack := &dto.Packages[i]
+		pack.BindDomain = sortedUniq(pack.BindDomain)
+		for j := range pack.Apis {
+			pack.Apis[j].Method = strings.ToUpper(pack.Apis[j].Method)
+		}
+		sort.SliceStable(pack.Apis, func(a, b int) bool {
+			return pack.Apis[a].Key() < pack.Apis[b].Key()
+		})
+	}
+	sort.SliceStable(dto.Packages, func(a, b int) bool {
+		return dto.Packages[a].Name < dto.Packages[b].Name
+	})
+	for i := range dto.Consumers {
+		dto.Consumers[i].Packages = sortedUniq(dto.Consumers[i].Packages)
+	}
+	sort.SliceStable(dto.Consumers, func(a, b int) bool {
+		return dto.Consumers[a].Name < dto.Consumers[b].Name
+	})
+}
+
+func (dto GatewayConfigDto) CheckValid() error {
+	if dto.Version != GATEWAY_CONFIG_VERSION {
+		return errors.Errorf("unsupported config version: %s", dto.Version)
+	}
+	packages := map[string]bool{}
+	for _, pack := range dto.Packages {
+		if packages[pack.Name] {
+			return errors.Errorf("duplicate package: %s", pack.Name)
+		}
+		packages[pack.Name] = true
+		if pack.Scene == UNITY_SCENE {
+			return errors.Errorf("package %s: unity package is not configurable", pack.Name)
+		}
+		if err := pack.PackageDto().CheckValid(); err != nil {
+			return errors.Wrapf(err, "package %s", pack.Name)
+		}
+		apis := map[string]bool{}
+		for _, api := range pack.Apis {
+			if apis[api.Key()] {
+				return errors.Errorf("package %s: duplicate api: %s", pack.Name, api.Key())
+			}
+			apis[api.Key()] = true
+			if api.ApiPath == "" {
+				return errors.Errorf("package %s: empty api path", pack.Name)
+			}
+			// 转发到服务的 api 在导入时按应用和服务名解析 runtime
+			if api.RedirectType == RT_SERVICE {
+				if api.RedirectApp == "" || api.RedirectService == "" {
+					return errors.Errorf("package %s: api %s: redirect app and service required", pack.Name, api.Key())
+				}
+				continue
+			}
+			if ok, msg := api.OpenapiDto().CheckValid(); !ok {
+				return errors.Errorf("package %s: api %s: %s", pack.Name, api.Key(), msg)
+			}
+		}
+	}
+	consumers := map[string]bool{}
+	for _, consumer := range dto.Consumers {
+		if consumer.Name == "" {
+			return errors.New("empty consumer name")
+		}
+		if consumers[consumer.Name] {
+			return errors.Errorf("duplicate consumer: %s", consumer.Name)
+		}
+		consumers[consumer.Name] = true
+		for _, name := range consumer.Packages {
+			if !packages[name] {
+				return errors.Errorf("consumer %s: package %s not found in config", consumer.Name, name)
+			}
+		}
+	}
+	return nil
+}
+
+func ParseGatewayConfig(content []byte) (*GatewayConfigDto, error) {
+	dto := &GatewayConfigDto{}
+	err := yaml.Unmarshal(content, dto)
+	if err != nil {
+		return nil, errors.Wrap(err, "parse gateway config failed")
+	}
+	dto.Normalize()
+	err = dto.CheckValid()
+	if err != nil {
+		return nil, err
+	}
+	return dto, nil
+}
+
+func (dto GatewayConfigDto) ToYaml() ([]byte, error) {
+	return yaml.Marshal(dto)
+}
+
+// ParsePolicyConfig 将数据库中保存的策略配置转为文档格式，global 字段仅用于展示，不导出
+func ParsePolicyConfig(config []byte) (map[string]interface{}, error) {
+	res := map[string]interface{}{}
+	err := json.Unmarshal(config, &res)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	delete(res, "global")
+	return res, nil
+}
+
+func policyEnabled(config map[string]interface{}) bool {
+	enable, _ := config["switch"].(bool)
+	return enable
+}
+
+func PolicyConfigEqual(a, b map[string]interface{}) bool {
+	// 统一经过 json 编解码，消除数值类型的差异
+	normalize := func(config map[string]interface{}) interface{} {
+		var res interface{}
+		content, _ := json.Marshal(config)
+		_ = json.Unmarshal(content, &res)
+		if m, ok := res.(map[string]interface{}); ok {
+			delete(m, "global")
+		}
+		return res
+	}
+	return reflect.DeepEqual(normalize(a), normalize(b))
+}
+
+func diffPolicies(kind ConfigKind, pack, api string, current, desired PolicyConfigs, prune bool) []ConfigChangeDto {
+	var changes []ConfigChangeDto
+	var categories []string
+	for category := range desired {
+		categories = append(categories, category)
+	}
+	sort.Strings(categories)
+	for _, category := range categories {
+		change := ConfigChangeDto{Kind: kind, Package: pack, Api: api, Policy: category}
+		exist, ok := current[category]
+		if !ok {
+			change.Action = CCA_CREATE
+		} else if !PolicyConfigEqual(exist, desired[category]) {
+			change.Action = CCA_UPDATE
+		} else {
+			continue
+		}
+		changes = append(changes, change)
+	}
+	if !prune {
+		return changes
+	}
+	categories = nil
+	for category, config := range current {
+		if _, ok := desired[category]; !ok && policyEnabled(config) {
+			categories = append(categories, category)
+		}
+	}
+	sort.Strings(categories)
+	for _, category := range categories {
+		changes = append(changes, ConfigChangeDto{Action: CCA_DELETE, Kind: kind, Package: pack, Api: api, Policy: category})
+	}
+	return changes
+}
+
+func diffPackageFields(current, desired PackageConfigDto) []string {
+	var fields []string
+	if !reflect.DeepEqual(sortedUniq(current.BindDomain), sortedUniq(desired.BindDomain)) {
+		fields = append(fields, "bindDomain")
+	}
+	if current.AuthType != desired.AuthType {
+		fields = append(fields, "authType")
+	}
+	if current.AclType != desired.AclType {
+		fields = append(fields, "aclType")
+	}
+	if current.Scene != desired.Scene {
+		fields = append(fields, "scene")
+	}
+	if current.Description != desired.Description {
+		fields = append(fields, "description")
+	}
+	return fields
+}
+
+func diffApiFields(current, desired ApiConfigDto) []string {
+	var fields []string
+	if current.RedirectType != desired.RedirectType {
+		fields = append(fields, "redirectType")
+	}
+	if current.RedirectAddr != desired.RedirectAddr {
+		fields = append(fields, "redirectAddr")
+	}
+	if current.RedirectPath != desired.RedirectPath {
+		fields = append(fields, "redirectPath")
+	}
+	if current.RedirectApp != desired.RedirectApp {
+		fields = append(fields, "redirectApp")
+	}
+	if current.RedirectService != desired.RedirectService {
+		fields = append(fields, "redirectService")
+	}
+	if current.RedirectRuntimeName != desired.RedirectRuntimeName {
+		fields = append(fields, "redirectRuntimeName")
+	}
+	if current.AllowPassAuth != desired.AllowPassAuth {
+		fields = append(fields, "allowPassAuth")
+	}
+	if current.Description != desired.Description {
+		fields = append(fields, "description")
+	}
+	if routeProtocol(current.Protocol) != routeProtocol(desired.Protocol) {
+		fields = append(fields, "protocol")
+	}
+	if !reflect.DeepEqual(current.Grpc, desired.Grpc) {
+		fields = append(fields, "grpc")
+	}
+	if current.IdleTimeout != desired.IdleTimeout {
+		fields = append(fields, "idleTimeout")
+	}
+	return fields
+}
+
+// DiffGatewayConfig 对比当前配置和期望配置，按执行顺序返回变更计划
+// prune 为 true 时，删除期望配置中不存在的产品包、api、调用方，并关闭未声明的策略
+func DiffGatewayConfig(current, desired *GatewayConfigDto, prune bool) []ConfigChangeDto {
+	var packages, apis, policies, consumers, acls, deletes []ConfigChangeDto
+	for _, pack := range desired.Packages {
+		exist := current.GetPackage(pack.Name)
+		if exist == nil {
+			packages = append(packages, ConfigChangeDto{Action: CCA_CREATE, Kind: CK_PACKAGE, Package: pack.Name})
+			exist = &PackageConfigDto{}
+		} else if fields := diffPackageFields(*exist, pack); len(fields) > 0 {
+			packages = append(packages, ConfigChangeDto{Action: CCA_UPDATE, Kind: CK_PACKAGE, Package: pack.Name, Fields: fields})
+		}
+		policies = append(policies, diffPolicies(CK_PACKAGE_POLICY, pack.Name, "", exist.Policies, pack.Policies, prune)...)
+		for _, api := range pack.Apis {
+			existApi := exist.GetApi(api.Key())
+			if existApi == nil {
+				apis = append(apis, ConfigChangeDto{Action: CCA_CREATE, Kind: CK_API, Package: pack.Name, Api: api.Key()})
+				existApi = &ApiConfigDto{}
+			} else if fields := diffApiFields(*existApi, api); len(fields) > 0 {
+				apis = append(apis, ConfigChangeDto{Action: CCA_UPDATE, Kind: CK_API, Package: pack.Name, Api: api.Key(), Fields: fields})
+			}
+			policies = append(policies, diffPolicies(CK_API_POLICY, pack.Name, api.Key(), existApi.Policies, api.Policies, prune)...)
+		}
+		if !prune {
+			continue
+		}
+		for _, api := range exist.Apis {
+			if pack.GetApi(api.Key()) == nil {
+				deletes = append(deletes, ConfigChangeDto{Action: CCA_DELETE, Kind: CK_API, Package: pack.Name, Api: api.Key()})
+			}
+		}
+	}
+	for _, consumer := range desired.Consumers {
+		exist := current.GetConsumer(consumer.Name)
+		if exist == nil {
+			consumers = append(consumers, ConfigChangeDto{Action: CCA_CREATE, Kind: CK_CONSUMER, Consumer: consumer.Name})
+			exist = &ConsumerConfigDto{}
+		} else if exist.Description != consumer.Description {
+			consumers = append(consumers, ConfigChangeDto{Action: CCA_UPDATE, Kind: CK_CONSUMER, Consumer: consumer.Name, Fields: []string{"description"}})
+		}
+		if !reflect.DeepEqual(sortedUniq(exist.Packages), sortedUniq(consumer.Packages)) {
+			acls = append(acls, ConfigChangeDto{Action: CCA_UPDATE, Kind: CK_CONSUMER_ACL, Consumer: consumer.Name, Fields: []string{"packages"}})
+		}
+	}
+	if prune {
+		// 先删除调用方，避免产品包因存在授权而删除失败
+		for _, consumer := range current.Consumers {
+			if desired.GetConsumer(consumer.Name) == nil {
+				deletes = append(deletes, ConfigChangeDto{Action: CCA_DELETE, Kind: CK_CONSUMER, Consumer: consumer.Name})
+			}
+		}
+		for _, pack := range current.Packages {
+			if desired.GetPackage(pack.Name) == nil {
+				deletes = append(deletes, ConfigChangeDto{Action: CCA_DELETE, Kind: CK_PACKAGE, Package: pack.Name})
+			}
+		}
+	}
+	var changes []ConfigChangeDto
+	for _, group := range [][]ConfigChangeDto{packages, apis, policies, consumers, acls, deletes} {
+		changes = append(changes, group...)
+	}
+	return changes
+}
@@ -0,0 +1,147 @@
+// Copyright (c) 2021 Terminus, Inc.
+//
+// This program is free software: you can use, redistribute, and/or modify
+// it under the terms of the GNU Affero General Public License, version 3
+// or later ("AGPL"), as published by the Free Software Foundation.
+//
+// This program is distributed in the hope that it will be useful, but WITHOUT
+// ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+// FITNESS FOR A PARTICULAR PURPOSE.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package dto
+
+import (
+	"reflect"
+	"testing"
+)
+
+const gatewayConfigYaml = `
+version: hepa/v1
+packages:
+- name: order
+  scene: openapi
+  authType: key-auth
+  aclType: "on"
+  bindDomain:
+  - order.example.com
+  - api.example.com
+  policies:
+    cors:
+      switch: true
+      methods: GET
+      maxAge: 86400
+  apis:
+  - apiPath: /orders
+    method: get
+    redirectType: url
+    redirectAddr: http://order.default.svc.cluster.local:8080
+    redirectPath: /api/orders
+consumers:
+- name: mobile
+  packages:
+  - order
+`
+
+func TestParseGatewayConfig(t *testing.T) {
+	dto, err := ParseGatewayConfig([]byte(gatewayConfigYaml))
+	if err != nil {
+		t.Fatal(err)
+	}
+	pack := dto.GetPackage("order")
+	if pack == nil {
+		t.Fatal("package not found")
+	}
+	if !reflect.DeepEqual(pack.BindDomain, []string{"api.example.com", "order.example.com"}) {
+		t.Errorf("domains not normalized: %v", pack.BindDomain)
+	}
+	if pack.GetApi("GET /orders") == nil {
+		t.Errorf("api not found: %+v", pack.Apis)
+	}
+	content, err := dto.ToYaml()
+	if err != nil {
+		t.Fatal(err)
+	}
+	again, err := ParseGatewayConfig(content)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if changes := DiffGatewayConfig(dto, again, true); len(changes) != 0 {
+		t.Errorf("export is not stable, changes: %+v", changes)
+	}
+
+	invalids := map[string]string{
+		"version":          "version: v0\n",
+		"unknown package":  "version: hepa/v1\nconsumers:\n- name: mobile\n  packages: [order]\n",
+		"duplicate api":    "version: hepa/v1\npackages:\n- name: a\n  scene: openapi\n  bindDomain: [a.com]\n  apis:\n  - {apiPath: /a, redirectType: url, redirectAddr: 'http://a', redirectPath: /}\n  - {apiPath: /a, redirectType: url, redirectAddr: 'http://b', redirectPath: /}\n",
+		"unity package":    "version: hepa/v1\npackages:\n- name: a\n  scene: unity\n  bindDomain: [a.com]\n",
+		"invalid redirect": "version: hepa/v1\npackages:\n- name: a\n  scene: openapi\n  bindDomain: [a.com]\n  apis:\n  - {apiPath: /a, redirectType: url, redirectAddr: 'a.com', redirectPath: /}\n",
+	}
+	for name, content := range invalids {
+		if _, err := ParseGatewayConfig([]byte(content)); err == nil {
+			t.Errorf("%s: want error", name)
+		}
+	}
+}
+
+func TestDiffGatewayConfig(t *testing.T) {
+	current := &GatewayConfigDto{
+		Version: GATEWAY_CONFIG_VERSION,
+		Packages: []PackageConfigDto{
+			{Name: "order", Scene: OPENAPI_SCENE, BindDomain: []string{"order.example.com"},
+				Policies: PolicyConfigs{
+					"cors":      {"switch": true, "maxAge": float64(600), "global": true},
+					"safety-ip": {"switch": true, "ipSource": "remoteIp"},
+				},
+				Apis: []ApiConfigDto{
+					{Method: "GET", ApiPath: "/orders", RedirectType: RT_URL, RedirectAddr: "http://order:8080", RedirectPath: "/"},
+					{ApiPath: "/legacy", RedirectType: RT_URL, RedirectAddr: "http://legacy:8080", RedirectPath: "/"},
+				}},
+			{Name: "stale", Scene: OPENAPI_SCENE, BindDomain: []string{"stale.example.com"}},
+		},
+		Consumers: []ConsumerConfigDto{
+			{Name: "mobile", Packages: []string{"order", "stale"}},
+			{Name: "old"},
+		},
+	}
+	desired := &GatewayConfigDto{
+		Version: GATEWAY_CONFIG_VERSION,
+		Packages: []PackageConfigDto{
+			{Name: "order", Scene: OPENAPI_SCENE, BindDomain: []string{"order.example.com"}, Description: "orders",
+				Policies: PolicyConfigs{
+					"cors": {"switch": true, "maxAge": 600},
+				},
+				Apis: []ApiConfigDto{
+					{Method: "GET", ApiPath: "/orders", RedirectType: RT_URL, RedirectAddr: "http://order-v2:8080", RedirectPath: "/",
+						Policies: PolicyConfigs{"proxy": {"switch": true}}},
+				}},
+			{Name: "user", Scene: OPENAPI_SCENE, BindDomain: []string{"user.example.com"},
+				Apis: []ApiConfigDto{{ApiPath: "/users", RedirectType: RT_URL, RedirectAddr: "http://user:8080", RedirectPath: "/"}}},
+		},
+		Consumers: []ConsumerConfigDto{
+			{Name: "mobile", Packages: []string{"order", "user"}},
+		},
+	}
+	want := []ConfigChangeDto{
+		{Action: CCA_UPDATE, Kind: CK_PACKAGE, Package: "order", Fields: []string{"description"}},
+		{Action: CCA_CREATE, Kind: CK_PACKAGE, Package: "user"},
+		{Action: CCA_UPDATE, Kind: CK_API, Package: "order", Api: "GET /orders", Fields: []string{"redirectAddr"}},
+		{Action: CCA_CREATE, Kind: CK_API, Package: "user", Api: "/users"},
+		{Action: CCA_DELETE, Kind: CK_PACKAGE_POLICY, Package: "order", Policy: "safety-ip"},
+		{Action: CCA_CREATE, Kind: CK_API_POLICY, Package: "order", Api: "GET /orders", Policy: "proxy"},
+		{Action: CCA_UPDATE, Kind: CK_CONSUMER_ACL, Consumer: "mobile", Fields: []string{"packages"}},
+		{Action: CCA_DELETE, Kind: CK_API, Package: "order", Api: "/legacy"},
+		{Action: CCA_DELETE, Kind: CK_CONSUMER, Consumer: "old"},
+		{Action: CCA_DELETE, Kind: CK_PACKAGE, Package: "stale"},
+	}
+	if got := DiffGatewayConfig(current, desired, true); !reflect.DeepEqual(got, want) {
+		t.Errorf("DiffGatewayConfig() = %+v, want %+v", got, want)
+	}
+	for _, change := range DiffGatewayConfig(current, desired, false) {
+		if change.Action == CCA_DELETE {
+			t.Errorf("unexpected delete without prune: %+v", change)
+		}
+	}
+}
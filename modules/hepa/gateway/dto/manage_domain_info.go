@@ -49,6 +49,8 @@ type ManageDomainInfo struct {
 	AppName     string          `json:"appName"`
 	Workspace   string          `json:"workspace"`
 	Link        *DomainLinkInfo `json:"link,omitempty"`
+	// 流量入口域名下API的路由协议
+	Protocols []string `json:"protocols,omitempty"`
 }
 
 func (req ManageDomainReq) GenSelectOptions() []orm.SelectOption {
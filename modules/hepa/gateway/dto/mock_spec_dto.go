@@ -0,0 +1,440 @@
+// Copyright (c) 2021 Terminus, Inc.
+//
+// This program is free software: you can use, redistribute, and/or modify
+// it under the terms of the GNU Affero General Public License, version 3
+// or later ("AGPL"), as published by the Free Software Foundation.
+//
+// This program is distributed in the hope that it will be useful, but WITHOUT
+// ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+// FITNESS FOR A PARTICULAR PURPOSE.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package dto
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/getkin/kin-openapi/openapi3filter"
+	"github.com/pkg/errors"
+
+	"github.com/erda-project/erda/pkg/swagger"
+)
+
+// 通过该请求头选择 mock 场景, 取值为响应状态码(如 404, 4XX, default)或响应中的 example 名称
+const MOCK_SCENARIO_HEADER = "X-Mock-Scenario"
+
+// MOCK_SPEC_HEAD_KEY_MAX_LEN 是 mock 标识的最大长度, 与唯一索引的列长度一致
+const MOCK_SPEC_HEAD_KEY_MAX_LEN = 191
+
+type MockSpecDto struct {
+	Az      string `json:"az"`
+	HeadKey string `json:"headKey"`
+	// OpenAPI 3 或 Swagger 2 接口规范, 支持 json 和 yaml
+	Spec string `json:"spec"`
+	// 为 true 时不校验请求是否符合接口规范
+	SkipValidate bool `json:"skipValidate"`
+}
+
+func (dto MockSpecDto) CheckValid() error {
+	if dto.HeadKey == "" {
+		return errors.New("headKey is empty")
+	}
+	if len(dto.HeadKey) > MOCK_SPEC_HEAD_KEY_MAX_LEN {
+		return errors.Errorf("headKey is longer than %d", MOCK_SPEC_HEAD_KEY_MAX_LEN)
+	}
+	if dto.Spec == "" {
+		return errors.New("spec is empty")
+	}
+	return nil
+}
+
+type CallMockSpecDto struct {
+	HeadKey string            `json:"headKey"`
+	PathUrl string            `json:"pathUrl"`
+	Method  string            `json:"method"`
+	Query   string            `json:"query"`
+	Headers map[string]string `json:"headers"`
+	Body    string            `json:"body"`
+}
+
+// 场景选择, 未指定时返回空
+func (dto CallMockSpecDto) Scenario() string {
+	for key, value := range dto.Headers {
+		if strings.EqualFold(key, MOCK_SCENARIO_HEADER) {
+			return strings.TrimSpace(value)
+		}
+	}
+	return ""
+}
+
+type MockResponseDto struct {
+	StatusCode int               `json:"statusCode"`
+	Headers    map[string]string `json:"headers,omitempty"`
+	Body       string            `json:"body"`
+	Scenario   string            `json:"scenario,omitempty"`
+}
+
+type MockErrorKind int
+
+const (
+	MEK_ROUTE_NOT_FOUND MockErrorKind = iota
+	MEK_INVALID_REQUEST
+	MEK_SCENARIO_NOT_FOUND
+)
+
+type MockError struct {
+	Kind   MockErrorKind
+	Reason string
+}
+
+func (err *MockError) Error() string {
+	return err.Reason
+}
+
+// 基于接口规范生成 mock 响应
+type OpenapiMock struct {
+	swagger *openapi3.Swagger
+	router  *openapi3filter.Router
+}
+
+func NewOpenapiMock(spec []byte) (*OpenapiMock, error) {
+	sw, err := swagger.LoadFromData(spec)
+	if err != nil {
+		return nil, errors.Wrap(err, "load spec failed")
+	}
+	// mock 只按路径匹配接口, 忽略规范中声明的 servers
+	sw.Servers = nil
+	router := openapi3filter.NewRouter()
+	if err = router.AddSwagger(sw); err != nil {
+		return nil, err
+	}
+	return &OpenapiMock{swagger: sw, router: router}, nil
+}
+
+func (mock *OpenapiMock) Call(req *CallMockSpecDto, validate bool) (*MockResponseDto, error) {
+	method := strings.ToUpper(req.Method)
+	if method == "" {
+		method = http.MethodGet
+	}
+	u, err := url.Parse(req.PathUrl)
+	if err != nil {
+		return nil, &MockError{MEK_INVALID_REQUEST, fmt.Sprintf("invalid path: %s", req.PathUrl)}
+	}
+	if req.Query != "" {
+		u.RawQuery = strings.TrimPrefix(req.Query, "?")
+	}
+	route, pathParams, err := mock.router.FindRoute(method, u)
+	if err != nil {
+		return nil, &MockError{MEK_ROUTE_NOT_FOUND, fmt.Sprintf("%s %s: %v", method, u.Path, err)}
+	}
+	// 路由匹配时路径参数允许为空段, 如 /pets 会匹配到 /pets/{petId}, 需要排除
+	for _, value := range pathParams {
+		if value == "" {
+			return nil, &MockError{MEK_ROUTE_NOT_FOUND, fmt.Sprintf("%s %s: Path was not found", method, u.Path)}
+		}
+	}
+	if validate {
+		httpReq, err := http.NewRequest(method, u.String(), strings.NewReader(req.Body))
+		if err != nil {
+			return nil, &MockError{MEK_INVALID_REQUEST, err.Error()}
+		}
+		for key, value := range req.Headers {
+			httpReq.Header.Set(key, value)
+		}
+		err = openapi3filter.ValidateRequest(context.Background(), &openapi3filter.RequestValidationInput{
+			Request:    httpReq,
+			PathParams: pathParams,
+			Route:      route,
+			Options: &openapi3filter.Options{
+				MultiError:         true,
+				AuthenticationFunc: openapi3filter.NoopAuthenticationFunc,
+			},
+		})
+		if err != nil {
+			return nil, &MockError{MEK_INVALID_REQUEST, err.Error()}
+		}
+	}
+	return mockResponse(route.Operation, req.Scenario())
+}
+
+func mockResponse(op *openapi3.Operation, scenario string) (*MockResponseDto, error) {
+	status, response, example, err := selectResponse(op.Responses, scenario)
+	if err != nil {
+		return nil, err
+	}
+	res := &MockResponseDto{
+		StatusCode: statusCode(status),
+		Headers:    map[string]string{},
+		Scenario:   scenario,
+	}
+	if response == nil {
+		return res, nil
+	}
+	for name, header := range response.Headers {
+		if header.Value == nil {
+			continue
+		}
+		var value interface{}
+		if header.Value.Example != nil {
+			value = header.Value.Example
+		} else if header.Value.Schema != nil && header.Value.Schema.Value != nil {
+			value = GenMockValue(header.Value.Schema.Value)
+		}
+		if value != nil {
+			res.Headers[name] = fmt.Sprint(value)
+		}
+	}
+	contentType, media := selectMediaType(response.Content)
+	if media == nil {
+		return res, nil
+	}
+	res.Headers["Content-Type"] = contentType
+	var value interface{}
+	switch {
+	case example != "":
+		value = media.Examples[example].Value.Value
+	case media.Example != nil:
+		value = media.Example
+	case len(media.Examples) > 0:
+		for _, name := range sortedExampleNames(media.Examples) {
+			if media.Examples[name].Value != nil {
+				value = media.Examples[name].Value.Value
+				break
+			}
+		}
+	case media.Schema != nil && media.Schema.Value != nil:
+		value = GenMockValue(media.Schema.Value)
+	}
+	if str, ok := value.(string); ok && !strings.Contains(contentType, "json") {
+		res.Body = str
+		return res, nil
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	res.Body = string(data)
+	return res, nil
+}
+
+// 未指定场景时优先选择 2xx 响应, 其次是 default;
+// 指定场景时先按状态码匹配, 再按 example 名称匹配
+func selectResponse(responses openapi3.Responses, scenario string) (string, *openapi3.Response, string, error) {
+	var statuses []string
+	for status := range responses {
+		statuses = append(statuses, status)
+	}
+	sort.Strings(statuses)
+	get := func(status string) *openapi3.Response {
+		if ref := responses[status]; ref != nil {
+			return ref.Value
+		}
+		return nil
+	}
+	if scenario == "" {
+		for _, status := range statuses {
+			if strings.HasPrefix(status, "2") {
+				return status, get(status), "", nil
+			}
+		}
+		if _, ok := responses["default"]; ok {
+			return "default", get("default"), "", nil
+		}
+		if len(statuses) > 0 {
+			return statuses[0], get(statuses[0]), "", nil
+		}
+		return "200", nil, "", nil
+	}
+	for _, status := range statuses {
+		if strings.EqualFold(status, scenario) {
+			return status, get(status), "", nil
+		}
+	}
+	for _, status := range statuses {
+		response := get(status)
+		if response == nil {
+			continue
+		}
+		for _, contentType := range sortedContentTypes(response.Content) {
+			media := response.Content[contentType]
+			if example, ok := media.Examples[scenario]; ok && example.Value != nil {
+				// 同一响应下可能有多种媒体类型, 只保留命中 example 的那一种
+				matched := *response
+				matched.Content = openapi3.Content{contentType: media}
+				return status, &matched, scenario, nil
+			}
+		}
+	}
+	return "", nil, "", &MockError{MEK_SCENARIO_NOT_FOUND, fmt.Sprintf("scenario %s not found", scenario)}
+}
+
+func statusCode(status string) int {
+	if code, err := strconv.Atoi(status); err == nil {
+		return code
+	}
+	// 形如 4XX 的范围状态码取该范围的第一个
+	if len(status) == 3 && strings.HasSuffix(strings.ToUpper(status), "XX") {
+		if code, err := strconv.Atoi(status[:1]); err == nil {
+			return code * 100
+		}
+	}
+	return http.StatusOK
+}
+
+func selectMediaType(content openapi3.Content) (string, *openapi3.MediaType) {
+	if len(content) == 0 {
+		return "", nil
+	}
+	types := sortedContentTypes(content)
+	for _, contentType := range types {
+		if strings.Contains(contentType, "json") {
+			return contentType, content[contentType]
+		}
+	}
+	return types[0], content[types[0]]
+}
+
+func sortedContentTypes(content openapi3.Content) []string {
+	var types []string
+	for contentType, media := range content {
+		if media != nil {
+			types = append(types, contentType)
+		}
+	}
+	sort.Strings(types)
+	return types
+}
+
+func sortedExampleNames(examples openapi3.Examples) []string {
+	var names []string
+	for name := range examples {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// 根据 schema 生成示例值, 优先使用 schema 中声明的 example, default 和 enum
+func GenMockValue(schema *openapi3.Schema) interface{} {
+	return genMockValue(schema, map[*openapi3.Schema]bool{})
+}
+
+func genMockValue(schema *openapi3.Schema, visiting map[*openapi3.Schema]bool) interface{} {
+	if schema == nil || visiting[schema] {
+		return nil
+	}
+	switch {
+	case schema.Example != nil:
+		return schema.Example
+	case schema.Default != nil:
+		return schema.Default
+	case len(schema.Enum) > 0:
+		return schema.Enum[0]
+	}
+	visiting[schema] = true
+	defer delete(visiting, schema)
+	if len(schema.AllOf) > 0 {
+		obj := map[string]interface{}{}
+		for _, ref := range schema.AllOf {
+			if ref == nil {
+				continue
+			}
+			if m, ok := genMockValue(ref.Value, visiting).(map[string]interface{}); ok {
+				for key, value := range m {
+					obj[key] = value
+				}
+			}
+		}
+		return obj
+	}
+	for _, refs := range []openapi3.SchemaRefs{schema.OneOf, schema.AnyOf} {
+		if len(refs) > 0 && refs[0] != nil {
+			return genMockValue(refs[0].Value, visiting)
+		}
+	}
+	switch schema.Type {
+	case "boolean":
+		return true
+	case "integer":
+		if schema.Min != nil {
+			return int64(*schema.Min)
+		}
+		return 0
+	case "number":
+		if schema.Min != nil {
+			return *schema.Min
+		}
+		return 0.0
+	case "string":
+		return mockString(schema)
+	case "array":
+		var items []interface{}
+		if schema.Items == nil || schema.Items.Value == nil {
+			return []interface{}{}
+		}
+		count := int(schema.MinItems)
+		if count < 1 {
+			count = 1
+		}
+		item := genMockValue(schema.Items.Value, visiting)
+		for i := 0; i < count; i++ {
+			items = append(items, item)
+		}
+		return items
+	case "object", "":
+		if schema.Type == "" && len(schema.Properties) == 0 {
+			return nil
+		}
+		obj := map[string]interface{}{}
+		for key, property := range schema.Properties {
+			if property == nil {
+				continue
+			}
+			obj[key] = genMockValue(property.Value, visiting)
+		}
+		if ap := schema.AdditionalProperties; ap != nil && ap.Value != nil && len(obj) == 0 {
+			obj["key"] = genMockValue(ap.Value, visiting)
+		}
+		return obj
+	}
+	return nil
+}
+
+func mockString(schema *openapi3.Schema) string {
+	var value string
+	switch schema.Format {
+	case "date":
+		value = "2021-01-01"
+	case "date-time":
+		value = "2021-01-01T00:00:00Z"
+	case "email":
+		value = "user@example.com"
+	case "uuid":
+		value = "3fa85f64-5717-4562-b3fc-2c963f66afa6"
+	case "uri", "url":
+		value = "https://example.com"
+	case "ipv4":
+		value = "127.0.0.1"
+	case "byte":
+		value = "c3RyaW5n"
+	default:
+		value = "string"
+	}
+	if min := int(schema.MinLength); len(value) < min {
+		value += strings.Repeat("x", min-len(value))
+	}
+	if schema.MaxLength != nil && uint64(len(value)) > *schema.MaxLength {
+		value = value[:*schema.MaxLength]
+	}
+	return value
+}
@@ -0,0 +1,174 @@
+// Copyright (c) 2021 Terminus, Inc.
+//
+// This program is free software: you can use, redistribute, and/or modify
+// it under the terms of the GNU Affero General Public License, version 3
+// or later ("AGPL"), as published by the Free Software Foundation.
+//
+// This program is distributed in the hope that it will be useful, but WITHOUT
+// ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+// FITNESS FOR A PARTICULAR PURPOSE.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package dto
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+const mockSpec = `
+openapi: 3.0.0
+info:
+  title: pets
+  version: 1.0.0
+servers:
+  - url: https://pets.example.com/v1
+paths:
+  /pets/{petId}:
+    get:
+      parameters:
+        - name: petId
+          in: path
+          required: true
+          schema:
+            type: integer
+        - name: verbose
+          in: query
+          schema:
+            type: boolean
+      responses:
+        "200":
+          description: ok
+          headers:
+            X-Rate-Limit:
+              schema:
+                type: integer
+                minimum: 100
+          content:
+            application/json:
+              schema:
+                $ref: '#/components/schemas/Pet'
+        "404":
+          description: not found
+          content:
+            application/json:
+              examples:
+                missing:
+                  value:
+                    message: pet not found
+  /pets:
+    post:
+      requestBody:
+        required: true
+        content:
+          application/json:
+            schema:
+              $ref: '#/components/schemas/Pet'
+      responses:
+        "201":
+          description: created
+components:
+  schemas:
+    Pet:
+      type: object
+      required: [id, name]
+      properties:
+        id:
+          type: integer
+          format: int64
+        name:
+          type: string
+        status:
+          type: string
+          enum: [available, sold]
+        tags:
+          type: array
+          items:
+            type: string
+        owner:
+          $ref: '#/components/schemas/Owner'
+    Owner:
+      type: object
+      properties:
+        email:
+          type: string
+          format: email
+        pets:
+          type: array
+          items:
+            $ref: '#/components/schemas/Pet'
+`
+
+func TestOpenapiMock_Call(t *testing.T) {
+	mock, err := NewOpenapiMock([]byte(mockSpec))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	res, err := mock.Call(&CallMockSpecDto{PathUrl: "/pets/1", Method: "get"}, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.StatusCode != 200 || res.Headers["Content-Type"] != "application/json" || res.Headers["X-Rate-Limit"] != "100" {
+		t.Fatalf("unexpected response: %+v", res)
+	}
+	var pet map[string]interface{}
+	if err = json.Unmarshal([]byte(res.Body), &pet); err != nil {
+		t.Fatal(err)
+	}
+	if pet["name"] != "string" || pet["status"] != "available" {
+		t.Fatalf("unexpected body: %s", res.Body)
+	}
+	owner, ok := pet["owner"].(map[string]interface{})
+	if !ok || owner["email"] != "user@example.com" {
+		t.Fatalf("unexpected owner: %s", res.Body)
+	}
+
+	res, err = mock.Call(&CallMockSpecDto{PathUrl: "/pets/1", Method: "GET",
+		Headers: map[string]string{"x-mock-scenario": "missing"}}, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.StatusCode != 404 || res.Body != `{"message":"pet not found"}` {
+		t.Fatalf("unexpected response: %+v", res)
+	}
+
+	res, err = mock.Call(&CallMockSpecDto{PathUrl: "/pets", Method: "POST", Body: `{"id":1,"name":"kitty"}`,
+		Headers: map[string]string{"Content-Type": "application/json"}}, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.StatusCode != 201 || res.Body != "" {
+		t.Fatalf("unexpected response: %+v", res)
+	}
+
+	tests := []struct {
+		name string
+		req  CallMockSpecDto
+		kind MockErrorKind
+	}{
+		{"route not found", CallMockSpecDto{PathUrl: "/owners", Method: "GET"}, MEK_ROUTE_NOT_FOUND},
+		{"method not allowed", CallMockSpecDto{PathUrl: "/pets", Method: "GET"}, MEK_ROUTE_NOT_FOUND},
+		{"invalid path param", CallMockSpecDto{PathUrl: "/pets/abc", Method: "GET"}, MEK_INVALID_REQUEST},
+		{"invalid query", CallMockSpecDto{PathUrl: "/pets/1", Query: "verbose=maybe", Method: "GET"}, MEK_INVALID_REQUEST},
+		{"missing required field", CallMockSpecDto{PathUrl: "/pets", Method: "POST", Body: `{"id":1}`,
+			Headers: map[string]string{"Content-Type": "application/json"}}, MEK_INVALID_REQUEST},
+		{"unknown scenario", CallMockSpecDto{PathUrl: "/pets/1", Method: "GET",
+			Headers: map[string]string{MOCK_SCENARIO_HEADER: "500"}}, MEK_SCENARIO_NOT_FOUND},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := mock.Call(&tt.req, true)
+			mockErr, ok := err.(*MockError)
+			if !ok || mockErr.Kind != tt.kind {
+				t.Errorf("Call() error = %v, want kind %v", err, tt.kind)
+			}
+		})
+	}
+
+	if _, err = mock.Call(&CallMockSpecDto{PathUrl: "/pets/abc", Method: "GET"}, false); err != nil {
+		t.Errorf("Call() without validate error = %v", err)
+	}
+}
@@ -38,6 +38,11 @@ type OpenapiDto struct {
 	RedirectRuntimeName string `json:"redirectRuntimeName"`
 	Method              string `json:"method,omitempty"`
 	AllowPassAuth       bool   `json:"allowPassAuth"`
+	// 路由协议，http(默认)、grpc、websocket
+	Protocol string        `json:"protocol,omitempty"`
+	Grpc     *GrpcRouteDto `json:"grpc,omitempty"`
+	// websocket连接的空闲超时，单位秒，0表示使用默认值
+	IdleTimeout int `json:"idleTimeout,omitempty"`
 	//	AclType            string   `json:"aclType"`
 	Description        string   `json:"description"`
 	Origin             Origin   `json:"-"`
@@ -46,12 +51,14 @@ type OpenapiDto struct {
 	ServiceRewritePath string   `json:"-"`
 	IsRegexPath        bool     `json:"-"`
 	RouteId            string   `json:"-"`
+	GrpcWebRouteId     string   `json:"-"`
 	ServiceId          string   `json:"-"`
 	ZoneId             string   `json:"-"`
 	ProjectId          string   `json:"-"`
 	Env                string   `json:"-"`
 	RuntimeServiceId   string   `json:"-"`
 	Hosts              []string `json:"hosts"`
+	GrpcPaths          []string `json:"-"`
 }
 
 const varSlot = ""
@@ -130,7 +137,43 @@ func (dto *OpenapiDto) pathVariableReplace(rawPaths []string, vars *[]string) (s
 	return strings.Join(rawPaths, ""), nil
 }
 
+func (dto *OpenapiDto) checkGrpc() (bool, string) {
+	if dto.RedirectType != RT_URL {
+		return false, "grpc api only supports redirect addr"
+	}
+	if ok, _ := regexp.MatchString(`^(grpc://|grpcs://)[0-9a-zA-z-_\.:]+$`, dto.RedirectAddr); !ok {
+		return false, fmt.Sprintf("invalid grpc redirect addr: %s", dto.RedirectAddr)
+	}
+	if dto.Method != "" {
+		return false, "grpc api matches requests by service and method of proto, not http method"
+	}
+	if dto.Grpc == nil {
+		return false, "grpc config is empty"
+	}
+	if _, err := dto.Grpc.MethodPaths(); err != nil {
+		return false, err.Error()
+	}
+	return true, ""
+}
+
 func (dto *OpenapiDto) CheckValid() (bool, string) {
+	switch dto.RouteProtocol() {
+	case RP_HTTP:
+	case RP_GRPC:
+		return dto.checkGrpc()
+	case RP_WEBSOCKET:
+		if dto.RedirectType != RT_URL {
+			return false, "websocket api only supports redirect addr"
+		}
+		if dto.Method != "" && dto.Method != "GET" {
+			return false, "websocket api only accepts GET method"
+		}
+		if dto.IdleTimeout < 0 {
+			return false, "idle timeout should not be negative"
+		}
+	default:
+		return false, fmt.Sprintf("invalid protocol: %s", dto.Protocol)
+	}
 	if dto.RedirectType == RT_URL {
 		if ok, _ := regexp.MatchString(`^(http://|https://)[0-9a-zA-z-_\.:]+$`, dto.RedirectAddr); !ok {
 			return false, fmt.Sprintf("invalid redirect addr: %s", dto.RedirectAddr)
@@ -152,6 +195,20 @@ func (dto *OpenapiDto) Adjust() error {
 	if dto.RedirectType == RT_SERVICE {
 		return nil
 	}
+	if dto.RouteProtocol() == RP_GRPC {
+		// grpc requests are matched by the method paths of service, which upstream expects as is
+		paths, err := dto.Grpc.MethodPaths()
+		if err != nil {
+			return err
+		}
+		dto.GrpcPaths = paths
+		dto.ApiPath = "/" + dto.Grpc.Service
+		dto.AdjustPath = dto.ApiPath
+		dto.RedirectPath = ""
+		dto.RedirectAddr = strings.TrimSuffix(dto.RedirectAddr, "/")
+		dto.AdjustRedirectAddr = dto.RedirectAddr
+		return nil
+	}
 	dto.RedirectPath = strings.Replace(dto.RedirectPath, "//", "/", -1)
 	dto.RedirectAddr = strings.TrimSuffix(dto.RedirectAddr, "/") + "/" + strings.TrimPrefix(dto.RedirectPath, "/")
 	if strings.HasSuffix(dto.ApiPath, "/") {
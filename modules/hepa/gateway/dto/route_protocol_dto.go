@@ -0,0 +1,146 @@
+// Copyright (c) 2021 Terminus, Inc.
+//
+// This program is free software: you can use, redistribute, and/or modify
+// it under the terms of the GNU Affero General Public License, version 3
+// or later ("AGPL"), as published by the Free Software Foundation.
+//
+// This program is distributed in the hope that it will be useful, but WITHOUT
+// ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+// FITNESS FOR A PARTICULAR PURPOSE.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package dto
+
+import (
+	"encoding/json"
+
+	"github.com/pkg/errors"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+// RouteProtocol
+const (
+	RP_HTTP      = "http"
+	RP_GRPC      = "grpc"
+	RP_WEBSOCKET = "websocket"
+)
+
+// GRPC_WEB_PLUGIN translates gRPC-Web requests into gRPC
+const GRPC_WEB_PLUGIN = "grpc-web"
+
+type GrpcRouteDto struct {
+	// protoc --include_imports --descriptor_set_out 生成的FileDescriptorSet，json中为base64编码
+	Descriptor []byte `json:"descriptor"`
+	// 完整的服务名，e.g. helloworld.Greeter
+	Service string `json:"service"`
+	// 开放的方法名，为空表示开放服务的全部方法
+	Methods []string `json:"methods,omitempty"`
+	// 将gRPC-Web请求转换为gRPC转发
+	GrpcWeb bool `json:"grpcWeb"`
+}
+
+// RouteProtocolConfigDto is the protocol specific config of api
+type RouteProtocolConfigDto struct {
+	Grpc *GrpcRouteDto `json:"grpc,omitempty"`
+	// websocket连接的空闲超时，单位秒
+	IdleTimeout int `json:"idleTimeout,omitempty"`
+}
+
+// MethodPaths returns the request paths of methods, e.g. /helloworld.Greeter/SayHello
+func (dto GrpcRouteDto) MethodPaths() ([]string, error) {
+	if dto.Service == "" {
+		return nil, errors.New("grpc service is empty")
+	}
+	set := &descriptorpb.FileDescriptorSet{}
+	err := proto.Unmarshal(dto.Descriptor, set)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid proto descriptor")
+	}
+	var service *descriptorpb.ServiceDescriptorProto
+	for _, file := range set.GetFile() {
+		for _, item := range file.GetService() {
+			name := item.GetName()
+			if file.GetPackage() != "" {
+				name = file.GetPackage() + "." + name
+			}
+			if name == dto.Service {
+				service = item
+			}
+		}
+	}
+	if service == nil {
+		return nil, errors.Errorf("grpc service %s not found in descriptor", dto.Service)
+	}
+	methods := map[string]bool{}
+	for _, method := range service.GetMethod() {
+		methods[method.GetName()] = true
+	}
+	names := dto.Methods
+	if len(names) == 0 {
+		for _, method := range service.GetMethod() {
+			names = append(names, method.GetName())
+		}
+	}
+	if len(names) == 0 {
+		return nil, errors.Errorf("grpc service %s has no method", dto.Service)
+	}
+	var paths []string
+	for _, name := range names {
+		if !methods[name] {
+			return nil, errors.Errorf("method %s not found in grpc service %s", name, dto.Service)
+		}
+		paths = append(paths, "/"+dto.Service+"/"+name)
+	}
+	return paths, nil
+}
+
+// RouteProtocol returns the protocol of api, http by default
+func (dto OpenapiDto) RouteProtocol() string {
+	return routeProtocol(dto.Protocol)
+}
+
+func routeProtocol(protocol string) string {
+	if protocol == "" {
+		return RP_HTTP
+	}
+	return protocol
+}
+
+// GrpcWebEnabled returns whether the grpc api accepts grpc-web requests
+func (dto OpenapiDto) GrpcWebEnabled() bool {
+	return dto.RouteProtocol() == RP_GRPC && dto.Grpc != nil && dto.Grpc.GrpcWeb
+}
+
+// ProtocolConfig returns the protocol specific config of api to keep, empty for http
+func (dto OpenapiDto) ProtocolConfig() string {
+	config := RouteProtocolConfigDto{}
+	switch dto.RouteProtocol() {
+	case RP_GRPC:
+		config.Grpc = dto.Grpc
+	case RP_WEBSOCKET:
+		config.IdleTimeout = dto.IdleTimeout
+	default:
+		return ""
+	}
+	res, _ := json.Marshal(config)
+	return string(res)
+}
+
+// SetProtocolConfig restores the protocol specific config of api
+func (dto *OpenapiDto) SetProtocolConfig(protocol string, config string) error {
+	dto.Protocol = protocol
+	if config == "" {
+		return nil
+	}
+	res := RouteProtocolConfigDto{}
+	err := json.Unmarshal([]byte(config), &res)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	dto.Grpc = res.Grpc
+	dto.IdleTimeout = res.IdleTimeout
+	return nil
+}
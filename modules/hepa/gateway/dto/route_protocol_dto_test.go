@@ -0,0 +1,120 @@
+// Copyright (c) 2021 Terminus, Inc.
+//
+// This program is free software: you can use, redistribute, and/or modify
+// it under the terms of the GNU Affero General Public License, version 3
+// or later ("AGPL"), as published by the Free Software Foundation.
+//
+// This program is distributed in the hope that it will be useful, but WITHOUT
+// ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+// FITNESS FOR A PARTICULAR PURPOSE.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package dto
+
+import (
+	"reflect"
+	"testing"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+func greeterDescriptor(t *testing.T) []byte {
+	set := &descriptorpb.FileDescriptorSet{
+		File: []*descriptorpb.FileDescriptorProto{{
+			Name:    proto.String("helloworld.proto"),
+			Package: proto.String("helloworld"),
+			Service: []*descriptorpb.ServiceDescriptorProto{{
+				Name: proto.String("Greeter"),
+				Method: []*descriptorpb.MethodDescriptorProto{
+					{Name: proto.String("SayHello"), InputType: proto.String(".helloworld.HelloRequest"), OutputType: proto.String(".helloworld.HelloReply")},
+					{Name: proto.String("SayGoodbye"), InputType: proto.String(".helloworld.HelloRequest"), OutputType: proto.String(".helloworld.HelloReply")},
+				},
+			}},
+		}},
+	}
+	data, err := proto.Marshal(set)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func TestGrpcRouteDto_MethodPaths(t *testing.T) {
+	descriptor := greeterDescriptor(t)
+	tests := []struct {
+		name    string
+		dto     GrpcRouteDto
+		want    []string
+		wantErr bool
+	}{
+		{"all methods", GrpcRouteDto{Descriptor: descriptor, Service: "helloworld.Greeter"},
+			[]string{"/helloworld.Greeter/SayHello", "/helloworld.Greeter/SayGoodbye"}, false},
+		{"some methods", GrpcRouteDto{Descriptor: descriptor, Service: "helloworld.Greeter", Methods: []string{"SayHello"}},
+			[]string{"/helloworld.Greeter/SayHello"}, false},
+		{"unknown method", GrpcRouteDto{Descriptor: descriptor, Service: "helloworld.Greeter", Methods: []string{"SayHi"}}, nil, true},
+		{"unknown service", GrpcRouteDto{Descriptor: descriptor, Service: "Greeter"}, nil, true},
+		{"invalid descriptor", GrpcRouteDto{Descriptor: []byte("syntax = proto3"), Service: "helloworld.Greeter"}, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.dto.MethodPaths()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("MethodPaths() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("MethodPaths() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestOpenapiDto_Protocols(t *testing.T) {
+	grpc := &GrpcRouteDto{Descriptor: greeterDescriptor(t), Service: "helloworld.Greeter", GrpcWeb: true}
+	tests := []struct {
+		name  string
+		dto   OpenapiDto
+		valid bool
+	}{
+		{"grpc", OpenapiDto{Protocol: RP_GRPC, RedirectType: RT_URL, RedirectAddr: "grpc://greeter:50051", Grpc: grpc}, true},
+		{"grpc with http addr", OpenapiDto{Protocol: RP_GRPC, RedirectType: RT_URL, RedirectAddr: "http://greeter:50051", Grpc: grpc}, false},
+		{"grpc with method", OpenapiDto{Protocol: RP_GRPC, RedirectType: RT_URL, RedirectAddr: "grpc://greeter:50051", Method: "POST", Grpc: grpc}, false},
+		{"grpc without config", OpenapiDto{Protocol: RP_GRPC, RedirectType: RT_URL, RedirectAddr: "grpc://greeter:50051"}, false},
+		{"websocket", OpenapiDto{Protocol: RP_WEBSOCKET, RedirectType: RT_URL, RedirectAddr: "http://chat:8080", RedirectPath: "/ws", IdleTimeout: 600}, true},
+		{"websocket with post", OpenapiDto{Protocol: RP_WEBSOCKET, RedirectType: RT_URL, RedirectAddr: "http://chat:8080", RedirectPath: "/ws", Method: "POST"}, false},
+		{"unknown protocol", OpenapiDto{Protocol: "tcp", RedirectType: RT_URL, RedirectAddr: "http://chat:8080", RedirectPath: "/"}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if valid, msg := tt.dto.CheckValid(); valid != tt.valid {
+				t.Errorf("CheckValid() = %v, %s, want %v", valid, msg, tt.valid)
+			}
+		})
+	}
+
+	dto := OpenapiDto{Protocol: RP_GRPC, RedirectType: RT_URL, RedirectAddr: "grpc://greeter:50051/", Grpc: grpc}
+	if err := dto.Adjust(); err != nil {
+		t.Fatal(err)
+	}
+	if dto.ApiPath != "/helloworld.Greeter" || dto.AdjustRedirectAddr != "grpc://greeter:50051" || len(dto.GrpcPaths) != 2 {
+		t.Errorf("Adjust() = %+v", dto)
+	}
+	restored := OpenapiDto{}
+	if err := restored.SetProtocolConfig(dto.RouteProtocol(), dto.ProtocolConfig()); err != nil {
+		t.Fatal(err)
+	}
+	if restored.Protocol != RP_GRPC || !reflect.DeepEqual(restored.Grpc, grpc) {
+		t.Errorf("SetProtocolConfig() = %+v", restored)
+	}
+	if !restored.GrpcWebEnabled() {
+		t.Errorf("GrpcWebEnabled() of %+v = false", restored)
+	}
+	if (OpenapiDto{Protocol: RP_GRPC}).GrpcWebEnabled() || (OpenapiDto{Grpc: grpc}).GrpcWebEnabled() {
+		t.Error("GrpcWebEnabled() = true without grpc-web")
+	}
+	if config := (OpenapiDto{}).ProtocolConfig(); config != "" {
+		t.Errorf("ProtocolConfig() of http = %s", config)
+	}
+}
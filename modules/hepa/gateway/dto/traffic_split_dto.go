@@ -0,0 +1,149 @@
+// Copyright (c) 2021 Terminus, Inc.
+//
+// This program is free software: you can use, redistribute, and/or modify
+// it under the terms of the GNU Affero General Public License, version 3
+// or later ("AGPL"), as published by the Free Software Foundation.
+//
+// This program is distributed in the hope that it will be useful, but WITHOUT
+// ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+// FITNESS FOR A PARTICULAR PURPOSE.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package dto
+
+import (
+	"net/url"
+	"regexp"
+
+	"github.com/pkg/errors"
+)
+
+type TrafficMatchType string
+
+// TrafficMatchType
+const (
+	TMT_HEADER TrafficMatchType = "header"
+	TMT_COOKIE TrafficMatchType = "cookie"
+)
+
+type TrafficBackendDto struct {
+	Name string `json:"name"`
+	// 转发地址，e.g. http://order-v2.project-1-dev.svc.cluster.local:8080，路径沿用API的转发路径
+	Addr   string `json:"addr"`
+	Weight int    `json:"weight"`
+}
+
+type TrafficMatchRuleDto struct {
+	Type  TrafficMatchType `json:"type"`
+	Name  string           `json:"name"`
+	Value string           `json:"value"`
+	// 命中规则的请求转发到的backend名称，不参与权重分流
+	Backend string `json:"backend"`
+}
+
+type TrafficSplitDto struct {
+	Backends []TrafficBackendDto   `json:"backends"`
+	Rules    []TrafficMatchRuleDto `json:"rules"`
+}
+
+var headerNameRegex = regexp.MustCompile("^[0-9a-zA-Z!#$%&'*+.^_`|~-]+$")
+
+func (dto TrafficBackendDto) CheckValid() error {
+	if dto.Name == "" {
+		return errors.New("backend name is empty")
+	}
+	if ok, _ := regexp.MatchString(`^(http://|https://)[0-9a-zA-z-_\.:]+$`, dto.Addr); !ok {
+		return errors.Errorf("invalid addr of backend %s: %s", dto.Name, dto.Addr)
+	}
+	if dto.Weight < 0 || dto.Weight > 100 {
+		return errors.Errorf("weight of backend %s should be between 0 and 100", dto.Name)
+	}
+	return nil
+}
+
+// Target returns the upstream target of backend
+func (dto TrafficBackendDto) Target() string {
+	u, err := url.Parse(dto.Addr)
+	if err != nil {
+		return ""
+	}
+	if u.Port() != "" {
+		return u.Host
+	}
+	if u.Scheme == "https" {
+		return u.Host + ":443"
+	}
+	return u.Host + ":80"
+}
+
+// Scheme returns the protocol of backend
+func (dto TrafficBackendDto) Scheme() string {
+	u, err := url.Parse(dto.Addr)
+	if err != nil {
+		return ""
+	}
+	return u.Scheme
+}
+
+func (dto TrafficMatchRuleDto) CheckValid() error {
+	if dto.Type != TMT_HEADER && dto.Type != TMT_COOKIE {
+		return errors.Errorf("invalid match type: %s", dto.Type)
+	}
+	if !headerNameRegex.MatchString(dto.Name) {
+		return errors.Errorf("invalid %s name: %s", dto.Type, dto.Name)
+	}
+	if dto.Value == "" {
+		return errors.Errorf("value of %s %s is empty", dto.Type, dto.Name)
+	}
+	return nil
+}
+
+// CheckValid checks weights of backends sum to 100, and rules match existing backends
+func (dto TrafficSplitDto) CheckValid() error {
+	if len(dto.Backends) == 0 {
+		return errors.New("backends are empty")
+	}
+	backends := map[string]bool{}
+	scheme := ""
+	weight := 0
+	for _, backend := range dto.Backends {
+		err := backend.CheckValid()
+		if err != nil {
+			return err
+		}
+		if backends[backend.Name] {
+			return errors.Errorf("duplicate backend: %s", backend.Name)
+		}
+		backends[backend.Name] = true
+		if scheme != "" && backend.Scheme() != scheme {
+			return errors.New("backends should use the same protocol")
+		}
+		scheme = backend.Scheme()
+		weight += backend.Weight
+	}
+	if weight != 100 {
+		return errors.Errorf("weights of backends sum to %d, not 100", weight)
+	}
+	for _, rule := range dto.Rules {
+		err := rule.CheckValid()
+		if err != nil {
+			return err
+		}
+		if !backends[rule.Backend] {
+			return errors.Errorf("backend %s of %s rule not exist", rule.Backend, rule.Type)
+		}
+	}
+	return nil
+}
+
+// GetBackend returns the backend named name
+func (dto TrafficSplitDto) GetBackend(name string) *TrafficBackendDto {
+	for i := range dto.Backends {
+		if dto.Backends[i].Name == name {
+			return &dto.Backends[i]
+		}
+	}
+	return nil
+}
@@ -0,0 +1,88 @@
+// Copyright (c) 2021 Terminus, Inc.
+//
+// This program is free software: you can use, redistribute, and/or modify
+// it under the terms of the GNU Affero General Public License, version 3
+// or later ("AGPL"), as published by the Free Software Foundation.
+//
+// This program is distributed in the hope that it will be useful, but WITHOUT
+// ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+// FITNESS FOR A PARTICULAR PURPOSE.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package dto
+
+import (
+	"testing"
+)
+
+func TestTrafficSplitDto_CheckValid(t *testing.T) {
+	backends := []TrafficBackendDto{
+		{Name: "v1", Addr: "http://order.project-1-dev.svc.cluster.local:8080", Weight: 90},
+		{Name: "v2", Addr: "http://order-v2.project-1-dev.svc.cluster.local:8080", Weight: 10},
+	}
+	tests := []struct {
+		name    string
+		dto     TrafficSplitDto
+		wantErr bool
+	}{
+		{"weights", TrafficSplitDto{Backends: backends}, false},
+		{"header rule", TrafficSplitDto{Backends: backends, Rules: []TrafficMatchRuleDto{
+			{Type: TMT_HEADER, Name: "x-canary", Value: "true", Backend: "v2"},
+			{Type: TMT_COOKIE, Name: "canary", Value: "always", Backend: "v2"},
+		}}, false},
+		{"empty", TrafficSplitDto{}, true},
+		{"weights not sum to 100", TrafficSplitDto{Backends: []TrafficBackendDto{
+			{Name: "v1", Addr: "http://order:8080", Weight: 90},
+			{Name: "v2", Addr: "http://order-v2:8080", Weight: 20},
+		}}, true},
+		{"negative weight", TrafficSplitDto{Backends: []TrafficBackendDto{
+			{Name: "v1", Addr: "http://order:8080", Weight: 110},
+			{Name: "v2", Addr: "http://order-v2:8080", Weight: -10},
+		}}, true},
+		{"duplicate backend", TrafficSplitDto{Backends: []TrafficBackendDto{
+			{Name: "v1", Addr: "http://order:8080", Weight: 50},
+			{Name: "v1", Addr: "http://order-v2:8080", Weight: 50},
+		}}, true},
+		{"addr with path", TrafficSplitDto{Backends: []TrafficBackendDto{
+			{Name: "v1", Addr: "http://order:8080/api", Weight: 100},
+		}}, true},
+		{"different protocols", TrafficSplitDto{Backends: []TrafficBackendDto{
+			{Name: "v1", Addr: "http://order:8080", Weight: 50},
+			{Name: "v2", Addr: "https://order-v2:8443", Weight: 50},
+		}}, true},
+		{"unknown backend of rule", TrafficSplitDto{Backends: backends, Rules: []TrafficMatchRuleDto{
+			{Type: TMT_HEADER, Name: "x-canary", Value: "true", Backend: "v3"},
+		}}, true},
+		{"invalid match type", TrafficSplitDto{Backends: backends, Rules: []TrafficMatchRuleDto{
+			{Type: "query", Name: "canary", Value: "true", Backend: "v2"},
+		}}, true},
+		{"invalid header name", TrafficSplitDto{Backends: backends, Rules: []TrafficMatchRuleDto{
+			{Type: TMT_HEADER, Name: "x canary", Value: "true", Backend: "v2"},
+		}}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.dto.CheckValid(); (err != nil) != tt.wantErr {
+				t.Errorf("CheckValid() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestTrafficBackendDto_Target(t *testing.T) {
+	tests := []struct {
+		addr string
+		want string
+	}{
+		{"http://order:8080", "order:8080"},
+		{"http://order", "order:80"},
+		{"https://order", "order:443"},
+	}
+	for _, tt := range tests {
+		if got := (TrafficBackendDto{Addr: tt.addr}).Target(); got != tt.want {
+			t.Errorf("Target(%s) = %v, want %v", tt.addr, got, tt.want)
+		}
+	}
+}
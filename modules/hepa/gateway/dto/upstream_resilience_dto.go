@@ -0,0 +1,209 @@
+// Copyright (c) 2021 Terminus, Inc.
+//
+// This program is free software: you can use, redistribute, and/or modify
+// it under the terms of the GNU Affero General Public License, version 3
+// or later ("AGPL"), as published by the Free Software Foundation.
+//
+// This program is distributed in the hope that it will be useful, but WITHOUT
+// ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+// FITNESS FOR A PARTICULAR PURPOSE.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package dto
+
+import (
+	"strings"
+
+	kongDto "github.com/erda-project/erda/modules/hepa/kong/dto"
+
+	"github.com/pkg/errors"
+)
+
+// Default timeouts in milliseconds of kong service
+const (
+	DEFAULT_CONNECT_TIMEOUT = 5000
+	DEFAULT_READ_TIMEOUT    = 60000
+	DEFAULT_WRITE_TIMEOUT   = 60000
+)
+
+type UpstreamActiveCheckDto struct {
+	// 探测路径，e.g. /health
+	HttpPath string `json:"httpPath"`
+	// 探测间隔，单位秒
+	Interval int `json:"interval"`
+	// 探测超时，单位秒，默认1
+	Timeout int `json:"timeout"`
+	// 连续探测成功多少次后恢复节点
+	HealthyThreshold int `json:"healthyThreshold"`
+	// 连续探测失败多少次后摘除节点
+	UnhealthyThreshold int `json:"unhealthyThreshold"`
+}
+
+type UpstreamPassiveCheckDto struct {
+	// 转发请求连续返回失败状态码多少次后熔断节点，0表示不检查
+	HttpFailures int `json:"httpFailures"`
+	// 连续连接失败多少次后熔断节点，0表示不检查
+	TcpFailures int `json:"tcpFailures"`
+	// 连续超时多少次后熔断节点，0表示不检查
+	Timeouts int `json:"timeouts"`
+	// 视为失败的状态码，默认429、500、503
+	UnhealthyStatuses []int `json:"unhealthyStatuses"`
+}
+
+type UpstreamResilienceDto struct {
+	// 转发失败后的重试次数
+	Retries int `json:"retries"`
+	// 超时时间，单位毫秒，0表示使用默认值
+	ConnectTimeout int `json:"connectTimeout"`
+	ReadTimeout    int `json:"readTimeout"`
+	WriteTimeout   int `json:"writeTimeout"`
+	// 主动健康检查，为空表示关闭
+	Active *UpstreamActiveCheckDto `json:"active"`
+	// 被动健康检查(熔断)，为空表示关闭，被熔断的节点只能靠主动健康检查恢复
+	Passive *UpstreamPassiveCheckDto `json:"passive"`
+}
+
+// UpstreamTargetHealthDto is the health of a target which api forwards to
+type UpstreamTargetHealthDto struct {
+	Target string `json:"target"`
+	Weight int64  `json:"weight"`
+	// HEALTHY, UNHEALTHY, HEALTHCHECKS_OFF
+	Health string `json:"health"`
+}
+
+// kong limits thresholds of healthchecks to 255
+const maxHealthThreshold = 255
+
+func checkThreshold(name string, value int, allowZero bool) error {
+	if value < 0 || value > maxHealthThreshold || (value == 0 && !allowZero) {
+		if allowZero {
+			return errors.Errorf("%s should be between 0 and %d", name, maxHealthThreshold)
+		}
+		return errors.Errorf("%s should be between 1 and %d", name, maxHealthThreshold)
+	}
+	return nil
+}
+
+func (dto UpstreamActiveCheckDto) CheckValid() error {
+	if !strings.HasPrefix(dto.HttpPath, "/") {
+		return errors.Errorf("invalid http path of active check: %s", dto.HttpPath)
+	}
+	if dto.Interval <= 0 {
+		return errors.New("interval of active check should be positive")
+	}
+	if dto.Timeout < 0 {
+		return errors.New("timeout of active check should not be negative")
+	}
+	err := checkThreshold("healthy threshold", dto.HealthyThreshold, false)
+	if err != nil {
+		return err
+	}
+	return checkThreshold("unhealthy threshold", dto.UnhealthyThreshold, false)
+}
+
+func (dto UpstreamPassiveCheckDto) CheckValid() error {
+	err := checkThreshold("http failures", dto.HttpFailures, true)
+	if err != nil {
+		return err
+	}
+	err = checkThreshold("tcp failures", dto.TcpFailures, true)
+	if err != nil {
+		return err
+	}
+	err = checkThreshold("timeouts", dto.Timeouts, true)
+	if err != nil {
+		return err
+	}
+	if dto.HttpFailures == 0 && dto.TcpFailures == 0 && dto.Timeouts == 0 {
+		return errors.New("passive check needs at least one failure threshold")
+	}
+	for _, status := range dto.UnhealthyStatuses {
+		if status < 100 || status > 999 {
+			return errors.Errorf("invalid unhealthy status: %d", status)
+		}
+	}
+	return nil
+}
+
+// CheckValid checks thresholds are in range of kong, and passive check works with active check
+func (dto UpstreamResilienceDto) CheckValid() error {
+	if dto.Retries < 0 || dto.Retries > 10 {
+		return errors.New("retries should be between 0 and 10")
+	}
+	if dto.ConnectTimeout < 0 || dto.ReadTimeout < 0 || dto.WriteTimeout < 0 {
+		return errors.New("timeouts should not be negative")
+	}
+	if dto.Active != nil {
+		err := dto.Active.CheckValid()
+		if err != nil {
+			return err
+		}
+	}
+	if dto.Passive != nil {
+		// kong never recovers targets broken by passive check without active check
+		if dto.Active == nil {
+			return errors.New("passive check needs active check to recover targets")
+		}
+		err := dto.Passive.CheckValid()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ApplyService sets retries and timeouts of kong service req
+func (dto UpstreamResilienceDto) ApplyService(req *kongDto.KongServiceReqDto) {
+	retries := dto.Retries
+	req.Retries = &retries
+	req.ConnectTimeout = DEFAULT_CONNECT_TIMEOUT
+	if dto.ConnectTimeout > 0 {
+		req.ConnectTimeout = dto.ConnectTimeout
+	}
+	req.ReadTimeout = DEFAULT_READ_TIMEOUT
+	if dto.ReadTimeout > 0 {
+		req.ReadTimeout = dto.ReadTimeout
+	}
+	req.WriteTimeout = DEFAULT_WRITE_TIMEOUT
+	if dto.WriteTimeout > 0 {
+		req.WriteTimeout = dto.WriteTimeout
+	}
+}
+
+// Healthchecks returns kong upstream healthchecks, checks absent stay off
+func (dto UpstreamResilienceDto) Healthchecks() kongDto.HealthchecksDto {
+	checks := kongDto.HealthchecksDto{}
+	if dto.Active != nil {
+		timeout := dto.Active.Timeout
+		if timeout == 0 {
+			timeout = 1
+		}
+		checks.Active = kongDto.ActiveHealthcheckDto{
+			Timeout:  timeout,
+			HttpPath: dto.Active.HttpPath,
+			Healthy: kongDto.HealthyDto{
+				Interval:  dto.Active.Interval,
+				Successes: dto.Active.HealthyThreshold,
+			},
+			Unhealthy: kongDto.UnhealthyDto{
+				Interval:     dto.Active.Interval,
+				HttpFailures: dto.Active.UnhealthyThreshold,
+				TcpFailures:  dto.Active.UnhealthyThreshold,
+				Timeouts:     dto.Active.UnhealthyThreshold,
+			},
+		}
+	}
+	if dto.Passive != nil {
+		checks.Passive = kongDto.PassiveHealthcheckDto{
+			Unhealthy: kongDto.UnhealthyDto{
+				HttpStatuses: dto.Passive.UnhealthyStatuses,
+				HttpFailures: dto.Passive.HttpFailures,
+				TcpFailures:  dto.Passive.TcpFailures,
+				Timeouts:     dto.Passive.Timeouts,
+			},
+		}
+	}
+	return checks
+}
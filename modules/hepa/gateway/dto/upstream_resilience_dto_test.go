@@ -0,0 +1,89 @@
+// Copyright (c) 2021 Terminus, Inc.
+//
+// This program is free software: you can use, redistribute, and/or modify
+// it under the terms of the GNU Affero General Public License, version 3
+// or later ("AGPL"), as published by the Free Software Foundation.
+//
+// This program is distributed in the hope that it will be useful, but WITHOUT
+// ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+// FITNESS FOR A PARTICULAR PURPOSE.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package dto
+
+import (
+	"reflect"
+	"testing"
+
+	kongDto "github.com/erda-project/erda/modules/hepa/kong/dto"
+)
+
+func TestUpstreamResilienceDto_CheckValid(t *testing.T) {
+	active := &UpstreamActiveCheckDto{HttpPath: "/health", Interval: 5, HealthyThreshold: 2, UnhealthyThreshold: 3}
+	tests := []struct {
+		name    string
+		dto     UpstreamResilienceDto
+		wantErr bool
+	}{
+		{"empty", UpstreamResilienceDto{}, false},
+		{"retries and timeouts", UpstreamResilienceDto{Retries: 3, ConnectTimeout: 1000, ReadTimeout: 3000}, false},
+		{"active and passive", UpstreamResilienceDto{Active: active, Passive: &UpstreamPassiveCheckDto{HttpFailures: 5}}, false},
+		{"too many retries", UpstreamResilienceDto{Retries: 11}, true},
+		{"negative timeout", UpstreamResilienceDto{ReadTimeout: -1}, true},
+		{"passive without active", UpstreamResilienceDto{Passive: &UpstreamPassiveCheckDto{HttpFailures: 5}}, true},
+		{"passive without thresholds", UpstreamResilienceDto{Active: active, Passive: &UpstreamPassiveCheckDto{}}, true},
+		{"invalid unhealthy status", UpstreamResilienceDto{Active: active, Passive: &UpstreamPassiveCheckDto{
+			HttpFailures: 5, UnhealthyStatuses: []int{50},
+		}}, true},
+		{"active without path", UpstreamResilienceDto{Active: &UpstreamActiveCheckDto{
+			Interval: 5, HealthyThreshold: 2, UnhealthyThreshold: 3,
+		}}, true},
+		{"active without interval", UpstreamResilienceDto{Active: &UpstreamActiveCheckDto{
+			HttpPath: "/health", HealthyThreshold: 2, UnhealthyThreshold: 3,
+		}}, true},
+		{"threshold out of range", UpstreamResilienceDto{Active: &UpstreamActiveCheckDto{
+			HttpPath: "/health", Interval: 5, HealthyThreshold: 256, UnhealthyThreshold: 3,
+		}}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.dto.CheckValid(); (err != nil) != tt.wantErr {
+				t.Errorf("CheckValid() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestUpstreamResilienceDto_ApplyService(t *testing.T) {
+	req := &kongDto.KongServiceReqDto{}
+	UpstreamResilienceDto{Retries: 2, ReadTimeout: 3000}.ApplyService(req)
+	if *req.Retries != 2 || req.ConnectTimeout != DEFAULT_CONNECT_TIMEOUT || req.ReadTimeout != 3000 || req.WriteTimeout != DEFAULT_WRITE_TIMEOUT {
+		t.Errorf("ApplyService() = %+v", req)
+	}
+}
+
+func TestUpstreamResilienceDto_Healthchecks(t *testing.T) {
+	if got := (UpstreamResilienceDto{}).Healthchecks(); !reflect.DeepEqual(got, kongDto.HealthchecksDto{}) {
+		t.Errorf("Healthchecks() = %+v, want checks off", got)
+	}
+	got := UpstreamResilienceDto{
+		Active:  &UpstreamActiveCheckDto{HttpPath: "/health", Interval: 5, HealthyThreshold: 2, UnhealthyThreshold: 3},
+		Passive: &UpstreamPassiveCheckDto{TcpFailures: 4, UnhealthyStatuses: []int{502}},
+	}.Healthchecks()
+	want := kongDto.HealthchecksDto{
+		Active: kongDto.ActiveHealthcheckDto{
+			Timeout:   1,
+			HttpPath:  "/health",
+			Healthy:   kongDto.HealthyDto{Interval: 5, Successes: 2},
+			Unhealthy: kongDto.UnhealthyDto{Interval: 5, HttpFailures: 3, TcpFailures: 3, Timeouts: 3},
+		},
+		Passive: kongDto.PassiveHealthcheckDto{
+			Unhealthy: kongDto.UnhealthyDto{HttpStatuses: []int{502}, TcpFailures: 4},
+		},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Healthchecks() = %+v, want %+v", got, want)
+	}
+}
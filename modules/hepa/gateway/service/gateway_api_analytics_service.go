@@ -0,0 +1,284 @@
+// Copyright (c) 2021 Terminus, Inc.
+//
+// This program is free software: you can use, redistribute, and/or modify
+// it under the terms of the GNU Affero General Public License, version 3
+// or later ("AGPL"), as published by the Free Software Foundation.
+//
+// This program is distributed in the hope that it will be useful, but WITHOUT
+// ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+// FITNESS FOR A PARTICULAR PURPOSE.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package service
+
+import (
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/erda-project/erda/modules/hepa/common"
+	. "github.com/erda-project/erda/modules/hepa/common/vars"
+	"github.com/erda-project/erda/modules/hepa/config"
+	gw "github.com/erda-project/erda/modules/hepa/gateway/dto"
+	"github.com/erda-project/erda/modules/hepa/metrics"
+	"github.com/erda-project/erda/modules/hepa/repository/orm"
+	db "github.com/erda-project/erda/modules/hepa/repository/service"
+	"github.com/erda-project/erda/providers/metrics/query"
+)
+
+type GatewayApiAnalyticsServiceImpl struct {
+	packageDb    db.GatewayPackageService
+	packageApiDb db.GatewayPackageApiService
+	routeDb      db.GatewayRouteService
+	consumerBiz  GatewayOpenapiConsumerService
+	buckets      gw.LatencyBuckets
+}
+
+func NewGatewayApiAnalyticsServiceImpl() (*GatewayApiAnalyticsServiceImpl, error) {
+	packageDb, _ := db.NewGatewayPackageServiceImpl()
+	packageApiDb, _ := db.NewGatewayPackageApiServiceImpl()
+	routeDb, _ := db.NewGatewayRouteServiceImpl()
+	consumerBiz, _ := NewGatewayOpenapiConsumerServiceImpl()
+	buckets, err := gw.ParseLatencyBuckets(config.ServerConf.SpotLatencyBuckets)
+	if err != nil {
+		// 延时分布配置错误时不提供分位值, 不影响其余统计
+		log.Errorf("invalid spot latency buckets, err:%+v", err)
+	}
+	return &GatewayApiAnalyticsServiceImpl{
+		packageDb:    packageDb,
+		packageApiDb: packageApiDb,
+		routeDb:      routeDb,
+		consumerBiz:  consumerBiz,
+		buckets:      buckets,
+	}, nil
+}
+
+// 网关访问指标中的 kong 路由 id 和 API 的对应关系
+type analyticsApis struct {
+	routeIds []string
+	apis     map[string]orm.GatewayPackageApi
+}
+
+func (impl GatewayApiAnalyticsServiceImpl) analyticsApis(apis []orm.GatewayPackageApi) (*analyticsApis, error) {
+	res := &analyticsApis{apis: map[string]orm.GatewayPackageApi{}}
+	for _, api := range apis {
+		route, err := impl.routeDb.GetByApiId(api.Id)
+		if err != nil {
+			return nil, err
+		}
+		// 未生成 kong 路由的 API 没有访问指标
+		if route == nil || route.RouteId == "" {
+			continue
+		}
+		res.routeIds = append(res.routeIds, route.RouteId)
+		res.apis[route.RouteId] = api
+	}
+	return res, nil
+}
+
+func (impl GatewayApiAnalyticsServiceImpl) queryMetric(req *gw.ApiAnalyticsReqDto, routeIds []string, groups []string, aggs ...gw.MetricAggregation) ([]gw.AccessMetricPoint, error) {
+	if metrics.Client == nil {
+		return nil, errors.New("metric query client not initialized")
+	}
+	metricReq := query.CreateQueryRequest(config.ServerConf.SpotMetricName).
+		StartFrom(req.Start).
+		EndWith(req.End).
+		In(config.ServerConf.SpotRouteTag, routeIds).
+		GroupBy(groups)
+	for _, agg := range aggs {
+		metricReq.Apply(agg.Func, agg.Field)
+	}
+	// 按状态码分组时, 状态码种类有限, 只限制上一级分组的数量
+	for _, group := range groups {
+		if group == gw.STATUS_CODE_TAG {
+			metricReq.LimitGroup(gw.MAX_ANALYTICS_LIMIT)
+		} else {
+			metricReq.LimitGroup(req.Limit)
+		}
+	}
+	resp, err := metrics.Client.QueryMetric(metricReq)
+	if err != nil {
+		return nil, errors.Wrap(err, "query access metric failed")
+	}
+	return gw.ParseAccessMetrics(resp.Body)
+}
+
+// 统计指定分组的请求数、错误率和延时, tag 为空时不分组
+func (impl GatewayApiAnalyticsServiceImpl) accessStats(req *gw.ApiAnalyticsReqDto, routeIds []string, tag string, withLatency bool) ([]gw.ApiAccessStatsDto, error) {
+	var groups []string
+	if tag != "" {
+		groups = append(groups, tag)
+	}
+	counts, err := impl.queryMetric(req, routeIds, append(append([]string{}, groups...), gw.STATUS_CODE_TAG), gw.REQUEST_COUNT_AGG)
+	if err != nil {
+		return nil, err
+	}
+	var latencies []gw.AccessMetricPoint
+	if withLatency {
+		latencies, err = impl.queryMetric(req, routeIds, groups, impl.buckets.Aggs()...)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return gw.MergeAccessStats(counts, latencies, impl.buckets), nil
+}
+
+// 将分组值替换为 API 或调用方的 id 和名称, 找不到对应关系时保留原始标签值
+func (impl GatewayApiAnalyticsServiceImpl) nameStats(stats []gw.ApiAccessStatsDto, groupBy gw.AnalyticsGroupBy, apis *analyticsApis, consumers map[string]orm.GatewayConsumer) {
+	for i := range stats {
+		switch groupBy {
+		case gw.AG_API:
+			if api, ok := apis.apis[stats[i].Key]; ok {
+				stats[i].Key = api.Id
+				stats[i].Name = strings.TrimSpace(strings.ToUpper(api.Method) + " " + api.ApiPath)
+			}
+		case gw.AG_CONSUMER:
+			if consumer, ok := consumers[stats[i].Key]; ok {
+				stats[i].Key = consumer.Id
+				stats[i].Name = consumer.ConsumerName
+			}
+		}
+	}
+}
+
+func (impl GatewayApiAnalyticsServiceImpl) packageConsumers(packageId string) (map[string]orm.GatewayConsumer, error) {
+	consumers, err := impl.consumerBiz.GetConsumersOfPackage(packageId)
+	if err != nil {
+		return nil, err
+	}
+	res := map[string]orm.GatewayConsumer{}
+	for _, consumer := range consumers {
+		res[impl.consumerBiz.GetKongConsumerName(&consumer)] = consumer
+	}
+	return res, nil
+}
+
+func (impl GatewayApiAnalyticsServiceImpl) analytics(packageId string, apis []orm.GatewayPackageApi, req *gw.ApiAnalyticsReqDto) (*gw.ApiAnalyticsDto, error) {
+	res := &gw.ApiAnalyticsDto{
+		Start:      req.Start.Format(time.RFC3339),
+		End:        req.End.Format(time.RFC3339),
+		GroupBy:    req.GroupBy,
+		Groups:     []gw.ApiAccessStatsDto{},
+		TopClients: []gw.ApiAccessStatsDto{},
+	}
+	routes, err := impl.analyticsApis(apis)
+	if err != nil {
+		return nil, err
+	}
+	if len(routes.routeIds) == 0 {
+		return res, nil
+	}
+	consumers, err := impl.packageConsumers(packageId)
+	if err != nil {
+		return nil, err
+	}
+	summary, err := impl.accessStats(req, routes.routeIds, "", true)
+	if err != nil {
+		return nil, err
+	}
+	if len(summary) > 0 {
+		res.Summary = summary[0]
+	}
+	groupTag := config.ServerConf.SpotRouteTag
+	if req.GroupBy == gw.AG_CONSUMER {
+		groupTag = config.ServerConf.SpotConsumerTag
+	}
+	res.Groups, err = impl.accessStats(req, routes.routeIds, groupTag, true)
+	if err != nil {
+		return nil, err
+	}
+	impl.nameStats(res.Groups, req.GroupBy, routes, consumers)
+	res.TopClients, err = impl.accessStats(req, routes.routeIds, config.ServerConf.SpotConsumerTag, false)
+	if err != nil {
+		return nil, err
+	}
+	if len(res.TopClients) > req.Limit {
+		res.TopClients = res.TopClients[:req.Limit]
+	}
+	impl.nameStats(res.TopClients, gw.AG_CONSUMER, routes, consumers)
+	return res, nil
+}
+
+func (impl GatewayApiAnalyticsServiceImpl) GetPackageAnalytics(packageId string, req *gw.ApiAnalyticsReqDto) *common.StandardResult {
+	res := &common.StandardResult{Success: false}
+	if packageId == "" || req == nil {
+		return res.SetReturnCode(PARAMS_IS_NULL)
+	}
+	var pack *orm.GatewayPackage
+	var apis []orm.GatewayPackageApi
+	var dto *gw.ApiAnalyticsDto
+	if req.GroupBy == "" {
+		req.GroupBy = gw.AG_API
+	}
+	err := req.CheckValid()
+	if err != nil {
+		log.Errorf("invalid analytics args, err:%+v", err)
+		return res.SetErrorInfo(&common.ErrInfo{
+			Code: INVALID_ANALYTICS.GetCode(),
+			Msg:  INVALID_ANALYTICS.GetMessage() + ": " + err.Error(),
+		})
+	}
+	pack, err = impl.packageDb.Get(packageId)
+	if err != nil {
+		goto failed
+	}
+	if pack == nil {
+		err = errors.New("package not exist")
+		goto failed
+	}
+	apis, err = impl.packageApiDb.SelectByAny(&orm.GatewayPackageApi{PackageId: packageId})
+	if err != nil {
+		goto failed
+	}
+	dto, err = impl.analytics(packageId, apis, req)
+	if err != nil {
+		goto failed
+	}
+	return res.SetSuccessAndData(dto)
+failed:
+	log.Errorf("error happened, err:%+v", err)
+	return res.SetErrorInfo(&common.ErrInfo{
+		Msg: errors.Cause(err).Error(),
+	})
+}
+
+func (impl GatewayApiAnalyticsServiceImpl) GetPackageApiAnalytics(packageId, apiId string, req *gw.ApiAnalyticsReqDto) *common.StandardResult {
+	res := &common.StandardResult{Success: false}
+	if packageId == "" || apiId == "" || req == nil {
+		return res.SetReturnCode(PARAMS_IS_NULL)
+	}
+	var api *orm.GatewayPackageApi
+	var dto *gw.ApiAnalyticsDto
+	if req.GroupBy == "" {
+		req.GroupBy = gw.AG_CONSUMER
+	}
+	err := req.CheckValid()
+	if err != nil {
+		log.Errorf("invalid analytics args, err:%+v", err)
+		return res.SetErrorInfo(&common.ErrInfo{
+			Code: INVALID_ANALYTICS.GetCode(),
+			Msg:  INVALID_ANALYTICS.GetMessage() + ": " + err.Error(),
+		})
+	}
+	api, err = impl.packageApiDb.Get(apiId)
+	if err != nil {
+		goto failed
+	}
+	if api == nil || api.PackageId != packageId {
+		return res.SetReturnCode(API_NOT_EXIST)
+	}
+	dto, err = impl.analytics(packageId, []orm.GatewayPackageApi{*api}, req)
+	if err != nil {
+		goto failed
+	}
+	return res.SetSuccessAndData(dto)
+failed:
+	log.Errorf("error happened, err:%+v", err)
+	return res.SetErrorInfo(&common.ErrInfo{
+		Msg: errors.Cause(err).Error(),
+	})
+}
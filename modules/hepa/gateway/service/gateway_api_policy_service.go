@@ -33,14 +33,17 @@ import (
 	_ "github.com/erda-project/erda/modules/hepa/apipolicy/policies/csrf"
 	_ "github.com/erda-project/erda/modules/hepa/apipolicy/policies/custom"
 	_ "github.com/erda-project/erda/modules/hepa/apipolicy/policies/ip"
+	_ "github.com/erda-project/erda/modules/hepa/apipolicy/policies/jwt"
 	_ "github.com/erda-project/erda/modules/hepa/apipolicy/policies/proxy"
 	_ "github.com/erda-project/erda/modules/hepa/apipolicy/policies/server-guard"
+	_ "github.com/erda-project/erda/modules/hepa/apipolicy/policies/transform"
 	_ "github.com/erda-project/erda/modules/hepa/apipolicy/policies/waf"
 	"github.com/erda-project/erda/modules/hepa/bundle"
 	"github.com/erda-project/erda/modules/hepa/common"
 	"github.com/erda-project/erda/modules/hepa/config"
 	"github.com/erda-project/erda/modules/hepa/k8s"
 	"github.com/erda-project/erda/modules/hepa/kong"
+	kongDto "github.com/erda-project/erda/modules/hepa/kong/dto"
 	"github.com/erda-project/erda/modules/hepa/repository/orm"
 	db "github.com/erda-project/erda/modules/hepa/repository/service"
 )
@@ -75,6 +78,7 @@ type GatewayApiPolicyServiceImpl struct {
 	kongPolicyDb    db.GatewayPolicyService
 	packageDb       db.GatewayPackageService
 	packageApiDb    db.GatewayPackageApiService
+	routeDb         db.GatewayRouteService
 	defaultPolicyDb db.GatewayDefaultPolicyService
 	openapiRuleBiz  GatewayOpenapiRuleService
 	zoneBiz         GatewayZoneService
@@ -96,6 +100,7 @@ func NewGatewayApiPolicyServiceImpl() (*GatewayApiPolicyServiceImpl, error) {
 	zoneDb, _ := db.NewGatewayZoneServiceImpl()
 	packageDb, _ := db.NewGatewayPackageServiceImpl()
 	packageApiDb, _ := db.NewGatewayPackageApiServiceImpl()
+	routeDb, _ := db.NewGatewayRouteServiceImpl()
 	openapiRuleBiz, _ := NewGatewayOpenapiRuleServiceImpl()
 	domainBiz, _ := NewGatewayDomainServiceImpl()
 	engine, _ := orm.GetSingleton()
@@ -112,6 +117,7 @@ func NewGatewayApiPolicyServiceImpl() (*GatewayApiPolicyServiceImpl, error) {
 		openapiRuleBiz:  openapiRuleBiz,
 		packageDb:       packageDb,
 		packageApiDb:    packageApiDb,
+		routeDb:         routeDb,
 		domainBiz:       domainBiz,
 	}, nil
 }
@@ -391,16 +397,104 @@ func (impl GatewayApiPolicyServiceImpl) setIngressPolicyDaoConfig(policyDao *orm
 	return nil
 }
 
+// zoneRouteIds 返回策略生效的 kong 路由
+func (impl GatewayApiPolicyServiceImpl) zoneRouteIds(zone *orm.GatewayZone, apiService db.GatewayPackageApiService, packService db.GatewayPackageService, routeService db.GatewayRouteService) ([]string, error) {
+	var apis []orm.GatewayPackageApi
+	switch zone.Type {
+	case db.ZONE_TYPE_PACKAGE_API:
+		api, err := apiService.GetByAny(&orm.GatewayPackageApi{ZoneId: zone.Id})
+		if err != nil {
+			return nil, err
+		}
+		if api != nil {
+			apis = append(apis, *api)
+		}
+	case db.ZONE_TYPE_UNITY:
+		pack, err := packService.GetByAny(&orm.GatewayPackage{ZoneId: zone.Id})
+		if err != nil {
+			return nil, err
+		}
+		if pack != nil {
+			apis, err = apiService.SelectByAny(&orm.GatewayPackageApi{PackageId: pack.Id})
+			if err != nil {
+				return nil, err
+			}
+		}
+	default:
+		return nil, errors.Errorf("zone type %s not supported by the gateway", zone.Type)
+	}
+	var routeIds []string
+	for _, api := range apis {
+		route, err := routeService.GetByApiId(api.Id)
+		if err != nil {
+			return nil, err
+		}
+		if route != nil {
+			routeIds = append(routeIds, route.RouteId)
+		}
+	}
+	return routeIds, nil
+}
+
+// renderBackendPolicy 网关后端不经过 nginx ingress 时, 策略需要渲染为路由上的插件才能生效,
+// 无法渲染且依赖 ingress 的策略开启时直接报错
+func (impl GatewayApiPolicyServiceImpl) renderBackendPolicy(adapter kong.KongAdapter, zone *orm.GatewayZone, category string, engine apipolicy.PolicyEngine, dto apipolicy.PolicyDto, policyConfig apipolicy.PolicyConfig, apiService db.GatewayPackageApiService, packService db.GatewayPackageService, routeService db.GatewayRouteService) error {
+	render, ok := engine.(apipolicy.PluginRender)
+	if !ok {
+		if dto != nil && dto.Enable() && (policyConfig.IngressAnnotation != nil || policyConfig.IngressController != nil) {
+			return errors.Errorf("policy %s not supported by the gateway, it works with nginx ingress only", category)
+		}
+		return nil
+	}
+	plugins, err := render.RenderPlugins(dto)
+	if err != nil {
+		return err
+	}
+	if len(plugins) == 0 {
+		return nil
+	}
+	routeIds, err := impl.zoneRouteIds(zone, apiService, packService, routeService)
+	if err != nil {
+		if dto != nil && dto.Enable() {
+			return err
+		}
+		return nil
+	}
+	for _, routeId := range routeIds {
+		for _, plugin := range plugins {
+			req := &kongDto.KongPluginReqDto{
+				Name:    plugin.Name,
+				RouteId: routeId,
+				Config:  plugin.Config,
+			}
+			if plugin.Config == nil {
+				err = adapter.DeletePluginIfExist(req)
+			} else {
+				_, err = adapter.CreateOrUpdatePlugin(req)
+			}
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
 func (impl GatewayApiPolicyServiceImpl) executePolicyEngine(zone *orm.GatewayZone, category string, engine apipolicy.PolicyEngine, config []byte, dto apipolicy.PolicyDto, ctx map[string]interface{}, policyService db.GatewayIngressPolicyService, k8sAdapter k8s.K8SAdapter, helper *db.SessionHelper, needDeployTag ...bool) error {
 	var apiService db.GatewayPackageApiService
 	var packService db.GatewayPackageService
 	var kongService db.GatewayKongInfoService
+	var routeService db.GatewayRouteService
 	var err error
 	if helper != nil {
 		apiService, err = impl.packageApiDb.NewSession(helper)
 		if err != nil {
 			return err
 		}
+		routeService, err = impl.routeDb.NewSession(helper)
+		if err != nil {
+			return err
+		}
 		packService, err = impl.packageDb.NewSession(helper)
 		if err != nil {
 			return err
@@ -413,6 +507,7 @@ func (impl GatewayApiPolicyServiceImpl) executePolicyEngine(zone *orm.GatewayZon
 		apiService = impl.packageApiDb
 		packService = impl.packageDb
 		kongService = impl.kongDb
+		routeService = impl.routeDb
 	}
 	needDeployIngress := true
 	if len(needDeployTag) > 0 {
@@ -422,6 +517,12 @@ func (impl GatewayApiPolicyServiceImpl) executePolicyEngine(zone *orm.GatewayZon
 	if err != nil {
 		return err
 	}
+	if adapter, ok := ctx[apipolicy.CTX_KONG_ADAPTER].(kong.KongAdapter); ok && !adapter.IngressPolicyEnforced() {
+		err = impl.renderBackendPolicy(adapter, zone, category, engine, dto, policyConfig, apiService, packService, routeService)
+		if err != nil {
+			return err
+		}
+	}
 	policyDao := &orm.GatewayIngressPolicy{
 		Name:   category,
 		Az:     zone.DiceClusterName,
@@ -824,6 +925,37 @@ func (impl GatewayApiPolicyServiceImpl) SetPackageDefaultPolicyConfig(category,
 	return "", nil
 }
 
+func (impl GatewayApiPolicyServiceImpl) PreviewPolicyConfig(category string, config []byte) *common.StandardResult {
+	res := &common.StandardResult{Success: false}
+	policyEngine, err := apipolicy.GetPolicyEngine(category)
+	if err != nil {
+		log.Errorf("get policy engine failed, category:%s, err:%+v", category, err)
+		return res
+	}
+	previewer, ok := policyEngine.(apipolicy.PolicyPreviewer)
+	if !ok {
+		return res.SetErrorInfo(&common.ErrInfo{
+			Msg: fmt.Sprintf("policy %s not support preview", category),
+		})
+	}
+	dto, err, msg := policyEngine.UnmarshalConfig(config)
+	if err != nil {
+		log.Errorf("unmarshal config failed, category:%s, err:%+v", category, err)
+		return res.SetErrorInfo(&common.ErrInfo{
+			Code: "提交配置失败",
+			Msg:  msg,
+		})
+	}
+	preview, err := previewer.Preview(dto)
+	if err != nil {
+		log.Errorf("preview config failed, category:%s, err:%+v", category, err)
+		return res.SetErrorInfo(&common.ErrInfo{
+			Msg: errors.Cause(err).Error(),
+		})
+	}
+	return res.SetSuccessAndData(preview)
+}
+
 func (impl GatewayApiPolicyServiceImpl) SetPolicyConfig(category, packageId, packageApiId string, config []byte) *common.StandardResult {
 	res := &common.StandardResult{Success: false}
 	auditCtx := map[string]interface{}{}
@@ -0,0 +1,491 @@
+// Copyright (c) 2021 Terminus, Inc.
+//
+// This program is free software: you can use, redistribute, and/or modify
+// it under the terms of the GNU Affero General Public License, version 3
+// or later ("AGPL"), as published by the Free Software Foundation.
+//
+// This program is distributed in the hope that it will be useful, but WITHOUT
+// ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+// FITNESS FOR A PARTICULAR PURPOSE.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/gin-gonic/gin"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/erda-project/erda/modules/hepa/common"
+	. "github.com/erda-project/erda/modules/hepa/common/vars"
+	gw "github.com/erda-project/erda/modules/hepa/gateway/dto"
+	"github.com/erda-project/erda/modules/hepa/repository/orm"
+	db "github.com/erda-project/erda/modules/hepa/repository/service"
+)
+
+type GatewayConfigServiceImpl struct {
+	azDb            db.GatewayAzInfoService
+	packageDb       db.GatewayPackageService
+	packageApiDb    db.GatewayPackageApiService
+	packageInDb     db.GatewayPackageInConsumerService
+	consumerDb      db.GatewayConsumerService
+	defaultPolicyDb db.GatewayDefaultPolicyService
+	ingressPolicyDb db.GatewayIngressPolicyService
+	runtimeDb       db.GatewayRuntimeServiceService
+	domainBiz       GatewayDomainService
+	openapiBiz      *GatewayOpenapiServiceImpl
+	consumerBiz     *GatewayOpenapiConsumerServiceImpl
+	policyBiz       *GatewayApiPolicyServiceImpl
+	ReqCtx          *gin.Context
+}
+
+// gatewayConfigState is the exported config of an env, with ids to apply changes against
+type gatewayConfigState struct {
+	config      *gw.GatewayConfigDto
+	packageIds  map[string]string
+	apiIds      map[string]map[string]string
+	consumerIds map[string]string
+	// grants to packages out of config, kept when consumer acls are applied
+	unmanagedGrants map[string][]string
+}
+
+func NewGatewayConfigServiceImpl() (*GatewayConfigServiceImpl, error) {
+	azDb, _ := db.NewGatewayAzInfoServiceImpl()
+	packageDb, _ := db.NewGatewayPackageServiceImpl()
+	packageApiDb, _ := db.NewGatewayPackageApiServiceImpl()
+	packageInDb, _ := db.NewGatewayPackageInConsumerServiceImpl()
+	consumerDb, _ := db.NewGatewayConsumerServiceImpl()
+	defaultPolicyDb, _ := db.NewGatewayDefaultPolicyServiceImpl()
+	ingressPolicyDb, _ := db.NewGatewayIngressPolicyServiceImpl()
+	runtimeDb, _ := db.NewGatewayRuntimeServiceServiceImpl()
+	domainBiz, _ := NewGatewayDomainServiceImpl()
+	openapiBiz, _ := NewGatewayOpenapiServiceImpl()
+	consumerBiz, _ := NewGatewayOpenapiConsumerServiceImpl()
+	policyBiz, _ := NewGatewayApiPolicyServiceImpl()
+	return &GatewayConfigServiceImpl{
+		azDb:            azDb,
+		packageDb:       packageDb,
+		packageApiDb:    packageApiDb,
+		packageInDb:     packageInDb,
+		consumerDb:      consumerDb,
+		defaultPolicyDb: defaultPolicyDb,
+		ingressPolicyDb: ingressPolicyDb,
+		runtimeDb:       runtimeDb,
+		domainBiz:       domainBiz,
+		openapiBiz:      openapiBiz,
+		consumerBiz:     consumerBiz,
+		policyBiz:       policyBiz,
+	}, nil
+}
+
+func resultError(res *common.StandardResult) error {
+	if res.Success {
+		return nil
+	}
+	if res.Err != nil && res.Err.Msg != "" {
+		return errors.New(res.Err.Msg)
+	}
+	return errors.New("unknown error")
+}
+
+// configurablePackage 由 runtime 自动生成的产品包不纳入声明式配置
+func configurablePackage(pack orm.GatewayPackage) bool {
+	return pack.Scene != orm.UNITY_SCENE && pack.RuntimeServiceId == ""
+}
+
+func configurableApi(api orm.GatewayPackageApi) bool {
+	return api.Origin == "" || gw.Origin(api.Origin) == gw.FROM_CUSTOM
+}
+
+func (impl GatewayConfigServiceImpl) packagePolicies(packageId string) (gw.PolicyConfigs, error) {
+	policies, err := impl.defaultPolicyDb.SelectByAny(&orm.GatewayDefaultPolicy{
+		Level:     orm.POLICY_PACKAGE_LEVEL,
+		PackageId: packageId,
+	})
+	if err != nil {
+		return nil, err
+	}
+	res := gw.PolicyConfigs{}
+	for _, policy := range policies {
+		if len(policy.Config) == 0 {
+			continue
+		}
+		config, err := gw.ParsePolicyConfig(policy.Config)
+		if err != nil {
+			return nil, err
+		}
+		res[policy.Name] = config
+	}
+	return res, nil
+}
+
+// apiPolicies 只导出与产品包默认配置不同的 api 策略
+func (impl GatewayConfigServiceImpl) apiPolicies(zoneId string, packagePolicies gw.PolicyConfigs) (gw.PolicyConfigs, error) {
+	res := gw.PolicyConfigs{}
+	if zoneId == "" {
+		return res, nil
+	}
+	policies, err := impl.ingressPolicyDb.SelectByAny(&orm.GatewayIngressPolicy{
+		ZoneId: zoneId,
+	})
+	if err != nil {
+		return nil, err
+	}
+	for _, policy := range policies {
+		if len(policy.Config) == 0 {
+			continue
+		}
+		config, err := gw.ParsePolicyConfig(policy.Config)
+		if err != nil {
+			return nil, err
+		}
+		if packageConfig, ok := packagePolicies[policy.Name]; ok && gw.PolicyConfigEqual(packageConfig, config) {
+			continue
+		}
+		res[policy.Name] = config
+	}
+	return res, nil
+}
+
+func (impl GatewayConfigServiceImpl) apiConfig(api orm.GatewayPackageApi, packagePolicies gw.PolicyConfigs) (*gw.ApiConfigDto, error) {
+	info := impl.openapiBiz.openapiDto(&api)
+	policies, err := impl.apiPolicies(api.ZoneId, packagePolicies)
+	if err != nil {
+		return nil, err
+	}
+	res := &gw.ApiConfigDto{
+		Method:        info.Method,
+		ApiPath:       info.ApiPath,
+		RedirectType:  info.RedirectType,
+		AllowPassAuth: info.AllowPassAuth,
+		Description:   info.Description,
+		Grpc:          info.Grpc,
+		IdleTimeout:   info.IdleTimeout,
+		Policies:      policies,
+	}
+	// http 为默认协议，不导出，避免与未声明协议的配置产生差异
+	if info.RouteProtocol() != gw.RP_HTTP {
+		res.Protocol = info.RouteProtocol()
+	}
+	if info.RedirectType == gw.RT_SERVICE {
+		res.RedirectApp = info.RedirectApp
+		res.RedirectService = info.RedirectService
+		res.RedirectRuntimeName = info.RedirectRuntimeName
+		res.RedirectPath = info.RedirectPath
+	} else {
+		res.RedirectAddr = info.RedirectAddr
+		res.RedirectPath = info.RedirectPath
+	}
+	return res, nil
+}
+
+func (impl GatewayConfigServiceImpl) loadState(args *gw.DiceArgsDto) (*gatewayConfigState, error) {
+	state := &gatewayConfigState{
+		config:          &gw.GatewayConfigDto{Version: gw.GATEWAY_CONFIG_VERSION},
+		packageIds:      map[string]string{},
+		apiIds:          map[string]map[string]string{},
+		consumerIds:     map[string]string{},
+		unmanagedGrants: map[string][]string{},
+	}
+	az, err := impl.azDb.GetAz(&orm.GatewayAzInfo{
+		Env:       args.Env,
+		OrgId:     args.OrgId,
+		ProjectId: args.ProjectId,
+	})
+	if err != nil {
+		return nil, err
+	}
+	packs, err := impl.packageDb.SelectByAny(&orm.GatewayPackage{
+		DiceProjectId:   args.ProjectId,
+		DiceEnv:         args.Env,
+		DiceClusterName: az,
+	})
+	if err != nil {
+		return nil, err
+	}
+	packageNames := map[string]string{}
+	for _, pack := range packs {
+		if !configurablePackage(pack) {
+			continue
+		}
+		domains, err := impl.domainBiz.GetPackageDomains(pack.Id)
+		if err != nil {
+			return nil, err
+		}
+		policies, err := impl.packagePolicies(pack.Id)
+		if err != nil {
+			return nil, err
+		}
+		packConfig := gw.PackageConfigDto{
+			Name:        pack.PackageName,
+			BindDomain:  domains,
+			AuthType:    pack.AuthType,
+			AclType:     pack.AclType,
+			Scene:       pack.Scene,
+			Description: pack.Description,
+			Policies:    policies,
+		}
+		apis, err := impl.packageApiDb.SelectByAny(&orm.GatewayPackageApi{
+			PackageId: pack.Id,
+		})
+		if err != nil {
+			return nil, err
+		}
+		apiIds := map[string]string{}
+		for _, api := range apis {
+			if !configurableApi(api) {
+				continue
+			}
+			apiConfig, err := impl.apiConfig(api, policies)
+			if err != nil {
+				return nil, err
+			}
+			packConfig.Apis = append(packConfig.Apis, *apiConfig)
+			apiIds[apiConfig.Key()] = api.Id
+		}
+		state.config.Packages = append(state.config.Packages, packConfig)
+		state.packageIds[pack.PackageName] = pack.Id
+		state.apiIds[pack.PackageName] = apiIds
+		packageNames[pack.Id] = pack.PackageName
+	}
+	consumers, err := impl.consumerDb.SelectByAny(&orm.GatewayConsumer{
+		OrgId:     args.OrgId,
+		ProjectId: args.ProjectId,
+		Env:       args.Env,
+		Az:        az,
+	})
+	if err != nil {
+		return nil, err
+	}
+	defaultName := impl.consumerDb.GetDefaultConsumerName(&orm.GatewayConsumer{
+		OrgId:     args.OrgId,
+		ProjectId: args.ProjectId,
+		Env:       args.Env,
+		Az:        az,
+	})
+	for _, consumer := range consumers {
+		if consumer.ConsumerName == defaultName || (consumer.Type != "" && consumer.Type != gw.CT_PRO) {
+			continue
+		}
+		consumerConfig := gw.ConsumerConfigDto{
+			Name:        consumer.ConsumerName,
+			Description: consumer.Description,
+		}
+		packageIn, err := impl.packageInDb.SelectByConsumer(consumer.Id)
+		if err != nil {
+			return nil, err
+		}
+		for _, in := range packageIn {
+			if name, ok := packageNames[in.PackageId]; ok {
+				consumerConfig.Packages = append(consumerConfig.Packages, name)
+				continue
+			}
+			state.unmanagedGrants[consumer.Id] = append(state.unmanagedGrants[consumer.Id], in.PackageId)
+		}
+		state.config.Consumers = append(state.config.Consumers, consumerConfig)
+		state.consumerIds[consumer.ConsumerName] = consumer.Id
+	}
+	state.config.Normalize()
+	return state, nil
+}
+
+func (impl GatewayConfigServiceImpl) resolveRuntime(args *gw.DiceArgsDto, dto *gw.OpenapiDto) error {
+	if dto.RedirectType != gw.RT_SERVICE {
+		return nil
+	}
+	runtimes, err := impl.runtimeDb.SelectByAny(&orm.GatewayRuntimeService{
+		ProjectId:   args.ProjectId,
+		Workspace:   args.Env,
+		AppName:     dto.RedirectApp,
+		ServiceName: dto.RedirectService,
+		RuntimeName: dto.RedirectRuntimeName,
+	})
+	if err != nil {
+		return err
+	}
+	if len(runtimes) == 0 {
+		return errors.Errorf("runtime service not found, app:%s, service:%s", dto.RedirectApp, dto.RedirectService)
+	}
+	if len(runtimes) > 1 && dto.RedirectRuntimeName == "" {
+		return errors.Errorf("multiple runtimes found, redirectRuntimeName required, app:%s, service:%s", dto.RedirectApp, dto.RedirectService)
+	}
+	dto.RedirectRuntimeId = runtimes[0].RuntimeId
+	return nil
+}
+
+func (impl GatewayConfigServiceImpl) policyConfig(state *gatewayConfigState, desired *gw.GatewayConfigDto, change gw.ConfigChangeDto) ([]byte, error) {
+	policies := func(config *gw.GatewayConfigDto) gw.PolicyConfigs {
+		pack := config.GetPackage(change.Package)
+		if pack == nil {
+			return nil
+		}
+		if change.Kind == gw.CK_PACKAGE_POLICY {
+			return pack.Policies
+		}
+		if api := pack.GetApi(change.Api); api != nil {
+			return api.Policies
+		}
+		return nil
+	}
+	if change.Action != gw.CCA_DELETE {
+		return json.Marshal(policies(desired)[change.Policy])
+	}
+	config := map[string]interface{}{}
+	for key, value := range policies(state.config)[change.Policy] {
+		config[key] = value
+	}
+	config["switch"] = false
+	return json.Marshal(config)
+}
+
+func (impl GatewayConfigServiceImpl) applyChange(args *gw.DiceArgsDto, state *gatewayConfigState, desired *gw.GatewayConfigDto, change gw.ConfigChangeDto) error {
+	packageId := state.packageIds[change.Package]
+	apiId := state.apiIds[change.Package][change.Api]
+	consumerId := state.consumerIds[change.Consumer]
+	switch change.Kind {
+	case gw.CK_PACKAGE:
+		switch change.Action {
+		case gw.CCA_CREATE:
+			res := impl.openapiBiz.CreatePackage(args, desired.GetPackage(change.Package).PackageDto())
+			if err := resultError(res); err != nil {
+				return err
+			}
+			info, ok := res.Data.(*gw.PackageInfoDto)
+			if !ok {
+				return errors.Errorf("invalid create package result: %+v", res.Data)
+			}
+			state.packageIds[change.Package] = info.Id
+			state.apiIds[change.Package] = map[string]string{}
+			return nil
+		case gw.CCA_UPDATE:
+			return resultError(impl.openapiBiz.UpdatePackage(packageId, desired.GetPackage(change.Package).PackageDto()))
+		case gw.CCA_DELETE:
+			return resultError(impl.openapiBiz.DeletePackage(packageId))
+		}
+	case gw.CK_API:
+		if change.Action == gw.CCA_DELETE {
+			return resultError(impl.openapiBiz.DeletePackageApi(packageId, apiId))
+		}
+		dto := desired.GetPackage(change.Package).GetApi(change.Api).OpenapiDto()
+		if err := impl.resolveRuntime(args, dto); err != nil {
+			return err
+		}
+		if change.Action == gw.CCA_UPDATE {
+			return resultError(impl.openapiBiz.UpdatePackageApi(packageId, apiId, dto))
+		}
+		res := impl.openapiBiz.CreatePackageApi(packageId, dto)
+		if err := resultError(res); err != nil {
+			return err
+		}
+		id, ok := res.Data.(string)
+		if !ok {
+			return errors.Errorf("invalid create api result: %+v", res.Data)
+		}
+		state.apiIds[change.Package][change.Api] = id
+		return nil
+	case gw.CK_PACKAGE_POLICY, gw.CK_API_POLICY:
+		config, err := impl.policyConfig(state, desired, change)
+		if err != nil {
+			return errors.Wrap(err, ERR_JSON_FAIL)
+		}
+		if change.Kind == gw.CK_PACKAGE_POLICY {
+			apiId = ""
+		}
+		return resultError(impl.policyBiz.SetPolicyConfig(change.Policy, packageId, apiId, config))
+	case gw.CK_CONSUMER:
+		switch change.Action {
+		case gw.CCA_CREATE:
+			consumer := desired.GetConsumer(change.Consumer)
+			res := impl.consumerBiz.CreateConsumer(args, &gw.OpenConsumerDto{
+				Name:        consumer.Name,
+				Description: consumer.Description,
+			})
+			if err := resultError(res); err != nil {
+				return err
+			}
+			id, ok := res.Data.(string)
+			if !ok {
+				return errors.Errorf("invalid create consumer result: %+v", res.Data)
+			}
+			state.consumerIds[change.Consumer] = id
+			return nil
+		case gw.CCA_UPDATE:
+			return resultError(impl.consumerBiz.UpdateConsumer(consumerId, &gw.OpenConsumerDto{
+				Description: desired.GetConsumer(change.Consumer).Description,
+			}))
+		case gw.CCA_DELETE:
+			return resultError(impl.consumerBiz.DeleteConsumer(consumerId))
+		}
+	case gw.CK_CONSUMER_ACL:
+		packages := append([]string{}, state.unmanagedGrants[consumerId]...)
+		for _, name := range desired.GetConsumer(change.Consumer).Packages {
+			packages = append(packages, state.packageIds[name])
+		}
+		return resultError(impl.consumerBiz.UpdateConsumerAcls(consumerId, &gw.ConsumerAclsDto{Packages: packages}))
+	}
+	return errors.Errorf("unsupported change: %+v", change)
+}
+
+func (impl GatewayConfigServiceImpl) ExportConfig(args *gw.DiceArgsDto) *common.StandardResult {
+	res := &common.StandardResult{Success: false}
+	if args.OrgId == "" || args.ProjectId == "" || args.Env == "" {
+		return res.SetReturnCode(PARAMS_IS_NULL)
+	}
+	var content []byte
+	state, err := impl.loadState(args)
+	if err != nil {
+		goto failed
+	}
+	content, err = state.config.ToYaml()
+	if err != nil {
+		goto failed
+	}
+	return res.SetSuccessAndData(string(content))
+failed:
+	log.Errorf("error happened, err:%+v", err)
+	return res.SetErrorInfo(&common.ErrInfo{Msg: errors.Cause(err).Error()})
+}
+
+// ImportConfig 对比并应用配置，每次只执行有差异的变更，失败后可以重复执行
+func (impl GatewayConfigServiceImpl) ImportConfig(args *gw.DiceArgsDto, content []byte, dryRun, prune bool) *common.StandardResult {
+	res := &common.StandardResult{Success: false}
+	if args.OrgId == "" || args.ProjectId == "" || args.Env == "" {
+		return res.SetReturnCode(PARAMS_IS_NULL)
+	}
+	var state *gatewayConfigState
+	var plan *gw.ConfigPlanDto
+	desired, err := gw.ParseGatewayConfig(content)
+	if err != nil {
+		goto failed
+	}
+	state, err = impl.loadState(args)
+	if err != nil {
+		goto failed
+	}
+	plan = &gw.ConfigPlanDto{
+		DryRun:  dryRun,
+		Changes: gw.DiffGatewayConfig(state.config, desired, prune),
+	}
+	if dryRun {
+		return res.SetSuccessAndData(plan)
+	}
+	impl.openapiBiz.ReqCtx = impl.ReqCtx
+	impl.consumerBiz.ReqCtx = impl.ReqCtx
+	impl.policyBiz.ReqCtx = impl.ReqCtx
+	for i, change := range plan.Changes {
+		err = impl.applyChange(args, state, desired, change)
+		if err != nil {
+			err = errors.Wrap(err, fmt.Sprintf("apply change[%d] %s %s failed", i, change.Action, change.Kind))
+			goto failed
+		}
+	}
+	return res.SetSuccessAndData(plan)
+failed:
+	log.Errorf("error happened, err:%+v", err)
+	return res.SetErrorInfo(&common.ErrInfo{Msg: err.Error()})
+}
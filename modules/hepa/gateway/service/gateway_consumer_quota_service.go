@@ -0,0 +1,605 @@
+// Copyright (c) 2021 Terminus, Inc.
+//
+// This program is free software: you can use, redistribute, and/or modify
+// it under the terms of the GNU Affero General Public License, version 3
+// or later ("AGPL"), as published by the Free Software Foundation.
+//
+// This program is distributed in the hope that it will be useful, but WITHOUT
+// ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+// FITNESS FOR A PARTICULAR PURPOSE.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package service
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/erda-project/erda/modules/hepa/common"
+	. "github.com/erda-project/erda/modules/hepa/common/vars"
+	"github.com/erda-project/erda/modules/hepa/config"
+	gw "github.com/erda-project/erda/modules/hepa/gateway/dto"
+	"github.com/erda-project/erda/modules/hepa/kong"
+	kongDto "github.com/erda-project/erda/modules/hepa/kong/dto"
+	"github.com/erda-project/erda/modules/hepa/metrics"
+	"github.com/erda-project/erda/modules/hepa/repository/orm"
+	db "github.com/erda-project/erda/modules/hepa/repository/service"
+	"github.com/erda-project/erda/providers/metrics/query"
+)
+
+// 配额插件只挂载在 kong 消费者上;
+// 同一消费者在流量入口或 API 上配置的流量限制更具体, kong 会只执行后者而绕过配额,
+// 因此配额和流量限制不能同时配置在一个消费者上
+const QUOTA_PLUGIN = "rate-limiting"
+
+var errQuotaLimitConflict = errors.New("consumer has limit rules, remove them before setting quota")
+
+type GatewayConsumerQuotaServiceImpl struct {
+	quotaDb     db.GatewayConsumerQuotaService
+	consumerDb  db.GatewayConsumerService
+	ruleDb      db.GatewayPackageRuleService
+	clientDb    db.GatewayOrgClientService
+	kongDb      db.GatewayKongInfoService
+	consumerBiz GatewayOpenapiConsumerService
+}
+
+func NewGatewayConsumerQuotaServiceImpl() (*GatewayConsumerQuotaServiceImpl, error) {
+	quotaDb, err := db.NewGatewayConsumerQuotaServiceImpl()
+	if err != nil {
+		return nil, err
+	}
+	consumerDb, err := db.NewGatewayConsumerServiceImpl()
+	if err != nil {
+		return nil, err
+	}
+	ruleDb, err := db.NewGatewayPackageRuleServiceImpl()
+	if err != nil {
+		return nil, err
+	}
+	clientDb, err := db.NewGatewayOrgClientServiceImpl()
+	if err != nil {
+		return nil, err
+	}
+	kongDb, err := db.NewGatewayKongInfoServiceImpl()
+	if err != nil {
+		return nil, err
+	}
+	consumerBiz, err := NewGatewayOpenapiConsumerServiceImpl()
+	if err != nil {
+		return nil, err
+	}
+	return &GatewayConsumerQuotaServiceImpl{
+		quotaDb:     quotaDb,
+		consumerDb:  consumerDb,
+		ruleDb:      ruleDb,
+		clientDb:    clientDb,
+		kongDb:      kongDb,
+		consumerBiz: consumerBiz,
+	}, nil
+}
+
+func (impl GatewayConsumerQuotaServiceImpl) kongAdapter(consumer *orm.GatewayConsumer) (kong.KongAdapter, error) {
+	if consumer.Type != orm.APIM_CLIENT_CONSUMER {
+		adapter := kong.NewKongAdapterForConsumer(consumer)
+		if adapter == nil {
+			return nil, errors.Errorf("kong not found, consumer:%s", consumer.Id)
+		}
+		return adapter, nil
+	}
+	// 客户端在每个集群都有对应的消费者, 没有项目信息, 直接按集群查找
+	kongInfo, err := impl.kongDb.GetKongInfo(&orm.GatewayKongInfo{
+		Az: consumer.Az,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return kong.NewKongAdapter(kongInfo.KongAddr), nil
+}
+
+func (impl GatewayConsumerQuotaServiceImpl) getConsumer(id string) (*orm.GatewayConsumer, error) {
+	consumer, err := impl.consumerDb.GetById(id)
+	if err != nil {
+		return nil, err
+	}
+	if consumer == nil {
+		return nil, errors.New("consumer not found")
+	}
+	return consumer, nil
+}
+
+func (impl GatewayConsumerQuotaServiceImpl) getClientConsumers(clientId string) ([]orm.GatewayConsumer, error) {
+	client, err := impl.clientDb.GetById(clientId)
+	if err != nil {
+		return nil, err
+	}
+	if client == nil {
+		return nil, errors.New("client not found")
+	}
+	return impl.consumerDb.SelectByAny(&orm.GatewayConsumer{
+		ClientId: clientId,
+		Type:     orm.APIM_CLIENT_CONSUMER,
+	})
+}
+
+func (impl GatewayConsumerQuotaServiceImpl) checkLimitRules(consumer *orm.GatewayConsumer) error {
+	rules, err := impl.ruleDb.SelectByAny(&orm.GatewayPackageRule{
+		ConsumerId: consumer.Id,
+		Category:   string(gw.LIMIT_RULE),
+	})
+	if err != nil {
+		return err
+	}
+	if len(rules) > 0 {
+		return errors.Wrapf(errQuotaLimitConflict, "consumer:%s", consumer.Id)
+	}
+	return nil
+}
+
+func (impl GatewayConsumerQuotaServiceImpl) applyQuota(consumer *orm.GatewayConsumer, quota *gw.ConsumerQuotaDto) error {
+	err := impl.checkLimitRules(consumer)
+	if err != nil {
+		return err
+	}
+	exist, err := impl.quotaDb.GetByConsumerId(consumer.Id)
+	if err != nil {
+		return err
+	}
+	adapter, err := impl.kongAdapter(consumer)
+	if err != nil {
+		return err
+	}
+	req := &kongDto.KongPluginReqDto{
+		Name:       QUOTA_PLUGIN,
+		ConsumerId: consumer.ConsumerId,
+		Config:     quota.KongConfig(),
+	}
+	if exist != nil {
+		req.Id = exist.PluginId
+	}
+	resp, err := adapter.CreateOrUpdatePluginById(req)
+	if err != nil {
+		return err
+	}
+	dao := &orm.GatewayConsumerQuota{
+		ConsumerId: consumer.Id,
+		Period:     string(quota.Period),
+		Quota:      quota.Quota,
+		Burst:      quota.Burst,
+		PluginId:   resp.Id,
+	}
+	if exist != nil {
+		dao.Id = exist.Id
+		return impl.quotaDb.Update(dao)
+	}
+	err = impl.quotaDb.Insert(dao)
+	if err != nil {
+		if rmErr := adapter.RemovePlugin(resp.Id); rmErr != nil {
+			log.Errorf("remove quota plugin failed, id:%s, err:%+v", resp.Id, rmErr)
+		}
+		return err
+	}
+	return nil
+}
+
+func (impl GatewayConsumerQuotaServiceImpl) removeQuota(consumer *orm.GatewayConsumer) error {
+	exist, err := impl.quotaDb.GetByConsumerId(consumer.Id)
+	if err != nil {
+		return err
+	}
+	if exist == nil {
+		return nil
+	}
+	adapter, err := impl.kongAdapter(consumer)
+	if err != nil {
+		return err
+	}
+	if exist.PluginId != "" {
+		err = adapter.RemovePlugin(exist.PluginId)
+		if err != nil {
+			return err
+		}
+	}
+	return impl.quotaDb.DeleteById(exist.Id)
+}
+
+type usageQueryResp struct {
+	Data struct {
+		Results []struct {
+			Data []map[string]struct {
+				Agg  string      `json:"agg"`
+				Data interface{} `json:"data"`
+			} `json:"data"`
+		} `json:"results"`
+	} `json:"data"`
+}
+
+func sumRequests(req *query.MetricQueryRequest) (int64, error) {
+	if metrics.Client == nil {
+		return 0, errors.New("metric query client not initialized")
+	}
+	resp, err := metrics.Client.QueryMetric(req.Apply("sum", "elapsed_count"))
+	if err != nil {
+		return 0, errors.Wrap(err, "query usage failed")
+	}
+	respDto := usageQueryResp{}
+	err = json.Unmarshal(resp.Body, &respDto)
+	if err != nil {
+		return 0, errors.Wrapf(err, "json unmarshal failed, body:%s", resp.Body)
+	}
+	var used int64
+	for _, result := range respDto.Data.Results {
+		for _, point := range result.Data {
+			for _, value := range point {
+				if count, ok := value.Data.(float64); ok && value.Agg == "sum" {
+					used += int64(count)
+				}
+			}
+		}
+	}
+	return used, nil
+}
+
+func usageRange(start, end time.Time) *query.MetricQueryRequest {
+	if now := time.Now(); end.After(now) {
+		end = now
+	}
+	return query.CreateQueryRequest(config.ServerConf.SpotMetricName).
+		StartFrom(start).
+		EndWith(end)
+}
+
+// 从网关访问指标中统计消费者在时间范围内的请求数
+func (impl GatewayConsumerQuotaServiceImpl) queryUsage(consumer *orm.GatewayConsumer, start, end time.Time) (int64, error) {
+	return sumRequests(usageRange(start, end).
+		Filter(config.ServerConf.SpotConsumerTag, impl.consumerBiz.GetKongConsumerName(consumer)))
+}
+
+// 时间范围内有请求但都没有消费者标签时, 说明网关上报的访问指标中没有该标签,
+// 此时按消费者统计的用量都为0, 直接报错而不是导出错误的用量
+func (impl GatewayConsumerQuotaServiceImpl) checkConsumerTag(start, end time.Time) error {
+	total, err := sumRequests(usageRange(start, end))
+	if err != nil {
+		return err
+	}
+	if total == 0 {
+		return nil
+	}
+	tagged, err := sumRequests(usageRange(start, end).Match(config.ServerConf.SpotConsumerTag, "*"))
+	if err != nil {
+		return err
+	}
+	if tagged == 0 {
+		return errors.Errorf("tag %s not found in metric %s, check the SpotConsumerTag config",
+			config.ServerConf.SpotConsumerTag, config.ServerConf.SpotMetricName)
+	}
+	return nil
+}
+
+func (impl GatewayConsumerQuotaServiceImpl) quotaInfo(consumer *orm.GatewayConsumer) (*gw.ConsumerQuotaInfoDto, error) {
+	dao, err := impl.quotaDb.GetByConsumerId(consumer.Id)
+	if err != nil {
+		return nil, err
+	}
+	if dao == nil {
+		return nil, nil
+	}
+	info := &gw.ConsumerQuotaInfoDto{
+		ConsumerId:   consumer.Id,
+		ConsumerName: consumer.ConsumerName,
+		ConsumerQuotaDto: gw.ConsumerQuotaDto{
+			Period: gw.QuotaPeriod(dao.Period),
+			Quota:  dao.Quota,
+			Burst:  dao.Burst,
+		},
+	}
+	start, end := info.PeriodRange(time.Now())
+	used, err := impl.queryUsage(consumer, start, end)
+	if err != nil {
+		return nil, err
+	}
+	info.SetUsage(used, start, end)
+	return info, nil
+}
+
+func (impl GatewayConsumerQuotaServiceImpl) GetConsumerQuota(consumerId string) (res *common.StandardResult) {
+	var err error
+	res = &common.StandardResult{Success: false}
+	defer func() {
+		if err != nil {
+			log.Errorf("error happened: %+v", err)
+			res.SetErrorInfo(&common.ErrInfo{
+				Msg: errors.Cause(err).Error(),
+			})
+		}
+	}()
+	if consumerId == "" {
+		err = errors.New("empty arguments")
+		return
+	}
+	consumer, err := impl.getConsumer(consumerId)
+	if err != nil {
+		return
+	}
+	info, err := impl.quotaInfo(consumer)
+	if err != nil {
+		return
+	}
+	res.SetSuccessAndData(info)
+	return
+}
+
+func (impl GatewayConsumerQuotaServiceImpl) SetConsumerQuota(consumerId string, quota *gw.ConsumerQuotaDto) (res *common.StandardResult) {
+	var err error
+	res = &common.StandardResult{Success: false}
+	defer func() {
+		if err != nil {
+			log.Errorf("error happened: %+v", err)
+			res.SetErrorInfo(&common.ErrInfo{
+				Msg: errors.Cause(err).Error(),
+			})
+		}
+	}()
+	if consumerId == "" {
+		err = errors.New("empty arguments")
+		return
+	}
+	err = quota.CheckValid()
+	if err != nil {
+		return
+	}
+	consumer, err := impl.getConsumer(consumerId)
+	if err != nil {
+		return
+	}
+	err = impl.applyQuota(consumer, quota)
+	if err != nil {
+		return
+	}
+	res.SetSuccessAndData(true)
+	return
+}
+
+func (impl GatewayConsumerQuotaServiceImpl) DeleteConsumerQuota(consumerId string) (res *common.StandardResult) {
+	var err error
+	res = &common.StandardResult{Success: false}
+	defer func() {
+		if err != nil {
+			log.Errorf("error happened: %+v", err)
+			res.SetErrorInfo(&common.ErrInfo{
+				Msg: errors.Cause(err).Error(),
+			})
+		}
+	}()
+	if consumerId == "" {
+		err = errors.New("empty arguments")
+		return
+	}
+	consumer, err := impl.getConsumer(consumerId)
+	if err != nil {
+		return
+	}
+	err = impl.removeQuota(consumer)
+	if err != nil {
+		return
+	}
+	res.SetSuccessAndData(true)
+	return
+}
+
+func (impl GatewayConsumerQuotaServiceImpl) GetClientQuota(clientId string) (res *common.StandardResult) {
+	var err error
+	res = &common.StandardResult{Success: false}
+	defer func() {
+		if err != nil {
+			log.Errorf("error happened: %+v", err)
+			res.SetErrorInfo(&common.ErrInfo{
+				Msg: errors.Cause(err).Error(),
+			})
+		}
+	}()
+	if clientId == "" {
+		err = errors.New("empty arguments")
+		return
+	}
+	consumers, err := impl.getClientConsumers(clientId)
+	if err != nil {
+		return
+	}
+	infos := []gw.ConsumerQuotaInfoDto{}
+	for i := range consumers {
+		var info *gw.ConsumerQuotaInfoDto
+		info, err = impl.quotaInfo(&consumers[i])
+		if err != nil {
+			return
+		}
+		if info != nil {
+			infos = append(infos, *info)
+		}
+	}
+	res.SetSuccessAndData(infos)
+	return
+}
+
+// 客户端的配额作用于其在各集群的消费者, 在各集群分别计数
+func (impl GatewayConsumerQuotaServiceImpl) SetClientQuota(clientId string, quota *gw.ConsumerQuotaDto) (res *common.StandardResult) {
+	var err error
+	res = &common.StandardResult{Success: false}
+	defer func() {
+		if err != nil {
+			log.Errorf("error happened: %+v", err)
+			res.SetErrorInfo(&common.ErrInfo{
+				Msg: errors.Cause(err).Error(),
+			})
+		}
+	}()
+	if clientId == "" {
+		err = errors.New("empty arguments")
+		return
+	}
+	err = quota.CheckValid()
+	if err != nil {
+		return
+	}
+	consumers, err := impl.getClientConsumers(clientId)
+	if err != nil {
+		return
+	}
+	if len(consumers) == 0 {
+		err = errors.New("client has no consumer, please grant package first")
+		return
+	}
+	for i := range consumers {
+		err = impl.applyQuota(&consumers[i], quota)
+		if err != nil {
+			return
+		}
+	}
+	res.SetSuccessAndData(true)
+	return
+}
+
+func (impl GatewayConsumerQuotaServiceImpl) DeleteClientQuota(clientId string) (res *common.StandardResult) {
+	var err error
+	res = &common.StandardResult{Success: false}
+	defer func() {
+		if err != nil {
+			log.Errorf("error happened: %+v", err)
+			res.SetErrorInfo(&common.ErrInfo{
+				Msg: errors.Cause(err).Error(),
+			})
+		}
+	}()
+	if clientId == "" {
+		err = errors.New("empty arguments")
+		return
+	}
+	consumers, err := impl.getClientConsumers(clientId)
+	if err != nil {
+		return
+	}
+	for i := range consumers {
+		err = impl.removeQuota(&consumers[i])
+		if err != nil {
+			return
+		}
+	}
+	res.SetSuccessAndData(true)
+	return
+}
+
+// 客户端在新集群创建消费者时, 沿用其他集群上的配额
+func (impl GatewayConsumerQuotaServiceImpl) InheritClientQuota(consumer *orm.GatewayConsumer) error {
+	if consumer.Type != orm.APIM_CLIENT_CONSUMER || consumer.ClientId == "" {
+		return nil
+	}
+	siblings, err := impl.consumerDb.SelectByAny(&orm.GatewayConsumer{
+		ClientId: consumer.ClientId,
+		Type:     orm.APIM_CLIENT_CONSUMER,
+	})
+	if err != nil {
+		return err
+	}
+	for _, sibling := range siblings {
+		if sibling.Id == consumer.Id {
+			continue
+		}
+		dao, err := impl.quotaDb.GetByConsumerId(sibling.Id)
+		if err != nil {
+			return err
+		}
+		if dao == nil {
+			continue
+		}
+		return impl.applyQuota(consumer, &gw.ConsumerQuotaDto{
+			Period: gw.QuotaPeriod(dao.Period),
+			Quota:  dao.Quota,
+			Burst:  dao.Burst,
+		})
+	}
+	return nil
+}
+
+// 导出项目环境下消费者或企业下客户端的月度用量, 返回 csv 内容
+func (impl GatewayConsumerQuotaServiceImpl) ExportUsage(args *gw.DiceArgsDto, month string) *common.StandardResult {
+	res := &common.StandardResult{Success: false}
+	var consumers []orm.GatewayConsumer
+	var usages []gw.ConsumerUsageDto
+	var clients []orm.GatewayOrgClient
+	var data []byte
+	start, end, err := gw.ParseUsageMonth(month, time.Now())
+	if err != nil {
+		res.SetReturnCode(PARAMS_IS_NULL)
+		goto failed
+	}
+	switch {
+	case args.ProjectId != "" && args.Env != "":
+		consumers, err = impl.consumerDb.SelectByAny(&orm.GatewayConsumer{
+			ProjectId: args.ProjectId,
+			Env:       args.Env,
+		})
+		if err != nil {
+			goto failed
+		}
+	case args.OrgId != "":
+		clients, err = impl.clientDb.SelectByAny(&orm.GatewayOrgClient{
+			OrgId: args.OrgId,
+		})
+		if err != nil {
+			goto failed
+		}
+		for _, client := range clients {
+			var clientConsumers []orm.GatewayConsumer
+			clientConsumers, err = impl.consumerDb.SelectByAny(&orm.GatewayConsumer{
+				ClientId: client.Id,
+				Type:     orm.APIM_CLIENT_CONSUMER,
+			})
+			if err != nil {
+				goto failed
+			}
+			consumers = append(consumers, clientConsumers...)
+		}
+	default:
+		return res.SetReturnCode(PARAMS_IS_NULL)
+	}
+	err = impl.checkConsumerTag(start, end)
+	if err != nil {
+		goto failed
+	}
+	for i := range consumers {
+		consumer := &consumers[i]
+		usage := gw.ConsumerUsageDto{
+			ConsumerId:   consumer.Id,
+			ConsumerName: consumer.ConsumerName,
+			ConsumerType: consumer.Type,
+			Az:           consumer.Az,
+			Month:        start.Format(gw.USAGE_MONTH_LAYOUT),
+		}
+		usage.Requests, err = impl.queryUsage(consumer, start, end)
+		if err != nil {
+			goto failed
+		}
+		var quota *orm.GatewayConsumerQuota
+		quota, err = impl.quotaDb.GetByConsumerId(consumer.Id)
+		if err != nil {
+			goto failed
+		}
+		if quota != nil {
+			usage.Period = gw.QuotaPeriod(quota.Period)
+			usage.Quota = quota.Quota
+		}
+		usages = append(usages, usage)
+	}
+	data, err = gw.ConsumerUsageToCsv(usages)
+	if err != nil {
+		goto failed
+	}
+	return res.SetSuccessAndData(data)
+failed:
+	log.Errorf("error happened, err:%+v", err)
+	return res
+}
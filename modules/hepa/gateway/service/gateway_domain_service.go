@@ -45,13 +45,14 @@ import (
 )
 
 type GatewayDomainServiceImpl struct {
-	domainDb  db.GatewayDomainService
-	packageDb db.GatewayPackageService
-	runtimeDb db.GatewayRuntimeServiceService
-	azDb      db.GatewayAzInfoService
-	kongDb    db.GatewayKongInfoService
-	globalBiz GatewayGlobalService
-	ReqCtx    *gin.Context
+	domainDb     db.GatewayDomainService
+	packageDb    db.GatewayPackageService
+	packageApiDb db.GatewayPackageApiService
+	runtimeDb    db.GatewayRuntimeServiceService
+	azDb         db.GatewayAzInfoService
+	kongDb       db.GatewayKongInfoService
+	globalBiz    GatewayGlobalService
+	ReqCtx       *gin.Context
 }
 
 func NewGatewayDomainServiceImpl() (*GatewayDomainServiceImpl, error) {
@@ -63,6 +64,10 @@ func NewGatewayDomainServiceImpl() (*GatewayDomainServiceImpl, error) {
 	if err != nil {
 		return nil, err
 	}
+	packageApiDb, err := db.NewGatewayPackageApiServiceImpl()
+	if err != nil {
+		return nil, err
+	}
 	runtimeDb, err := db.NewGatewayRuntimeServiceServiceImpl()
 	if err != nil {
 		return nil, err
@@ -80,15 +85,42 @@ func NewGatewayDomainServiceImpl() (*GatewayDomainServiceImpl, error) {
 		return nil, err
 	}
 	return &GatewayDomainServiceImpl{
-		domainDb:  domainDb,
-		packageDb: packageDb,
-		runtimeDb: runtimeDb,
-		azDb:      azDb,
-		kongDb:    kongDb,
-		globalBiz: globalBiz,
+		domainDb:     domainDb,
+		packageDb:    packageDb,
+		packageApiDb: packageApiDb,
+		runtimeDb:    runtimeDb,
+		azDb:         azDb,
+		kongDb:       kongDb,
+		globalBiz:    globalBiz,
 	}, nil
 }
 
+// packageProtocols returns the distinct route protocols of apis in package
+func (impl GatewayDomainServiceImpl) packageProtocols(packageId string) ([]string, error) {
+	if packageId == "" {
+		return nil, nil
+	}
+	apis, err := impl.packageApiDb.SelectByAny(&orm.GatewayPackageApi{PackageId: packageId})
+	if err != nil {
+		return nil, err
+	}
+	var protocols []string
+	exist := map[string]bool{}
+	for _, api := range apis {
+		protocol := api.Protocol
+		if protocol == "" {
+			protocol = gw.RP_HTTP
+		}
+		if exist[protocol] {
+			continue
+		}
+		exist[protocol] = true
+		protocols = append(protocols, protocol)
+	}
+	sort.Strings(protocols)
+	return protocols, nil
+}
+
 func diffDomains(reqDomains []gw.EndpointDomainDto, existDomains []orm.GatewayDomain) (adds []gw.EndpointDomainDto, dels []orm.GatewayDomain, updates []orm.GatewayDomain) {
 	for _, domain := range reqDomains {
 		exist := false
@@ -1480,6 +1512,10 @@ func (impl GatewayDomainServiceImpl) GetOrgDomainInfo(dice gw.DiceArgsDto, reqDt
 			dto.Type = gw.GatewayDomain
 			link.TenantGroup = impl.globalBiz.GenTenantGroup(dao.ProjectId, dao.Workspace, dao.ClusterName)
 			dto.Link = link
+			dto.Protocols, err = impl.packageProtocols(dao.PackageId)
+			if err != nil {
+				return
+			}
 		default:
 			dto.Type = gw.OtherDomain
 		}
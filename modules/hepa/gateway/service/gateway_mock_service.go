@@ -14,6 +14,9 @@
 package service
 
 import (
+	"sync"
+	"time"
+
 	"github.com/erda-project/erda/modules/hepa/common"
 	. "github.com/erda-project/erda/modules/hepa/common/vars"
 	gw "github.com/erda-project/erda/modules/hepa/gateway/dto"
@@ -26,15 +29,29 @@ import (
 
 type GatewayMockServiceImpl struct {
 	mockDb db.GatewayMockService
+	specDb db.GatewayMockSpecService
+}
+
+// 解析后的接口规范, 按 headKey 缓存, 规范更新后失效
+type cachedOpenapiMock struct {
+	updateTime time.Time
+	mock       *gw.OpenapiMock
 }
 
+var openapiMockCache sync.Map
+
 func NewGatewayMockServiceImpl() (*GatewayMockServiceImpl, error) {
 	mockDb, err := db.NewGatewayMockServiceImpl()
 	if err != nil {
 		return nil, errors.Wrap(err, "NewGatewayMockServiceImpl failed")
 	}
+	specDb, err := db.NewGatewayMockSpecServiceImpl()
+	if err != nil {
+		return nil, errors.Wrap(err, "NewGatewayMockServiceImpl failed")
+	}
 	return &GatewayMockServiceImpl{
 		mockDb: mockDb,
+		specDb: specDb,
 	}, nil
 }
 
@@ -89,3 +106,99 @@ func (impl GatewayMockServiceImpl) CallMockApi(headKey, pathUrl, method string)
 	data := mock.Body
 	return res.SetSuccessAndData(data)
 }
+
+func (impl GatewayMockServiceImpl) RegisterMockSpec(dto *gw.MockSpecDto) *common.StandardResult {
+	res := &common.StandardResult{Success: false}
+	if err := dto.CheckValid(); err != nil {
+		log.Errorf("invalid mock spec: %+v", err)
+		return res.SetReturnCode(PARAMS_IS_NULL)
+	}
+	// 注册时先解析一次, 尽早暴露规范中的错误
+	if _, err := gw.NewOpenapiMock([]byte(dto.Spec)); err != nil {
+		log.Errorf("parse mock spec failed, headKey:%s, err:%+v", dto.HeadKey, err)
+		return res.SetErrorInfo(&common.ErrInfo{
+			Code: MOCK_SPEC_INVALID.GetCode(),
+			Msg:  MOCK_SPEC_INVALID.GetMessage() + ": " + err.Error(),
+		})
+	}
+	exist, err := impl.specDb.GetByHeadKey(dto.HeadKey)
+	if err != nil {
+		log.Error(errors.WithStack(err))
+		return res
+	}
+	spec := &orm.GatewayMockSpec{
+		Az:             dto.Az,
+		HeadKey:        dto.HeadKey,
+		Spec:           dto.Spec,
+		IsSkipValidate: dto.SkipValidate,
+	}
+	if exist == nil {
+		err = impl.specDb.Insert(spec)
+	} else {
+		spec.Id = exist.Id
+		err = impl.specDb.Update(spec)
+	}
+	if err != nil {
+		log.Error(errors.WithStack(err))
+		return res
+	}
+	openapiMockCache.Delete(dto.HeadKey)
+	return res.SetSuccessAndData(true)
+}
+
+func (impl GatewayMockServiceImpl) CallMockSpec(dto *gw.CallMockSpecDto) *common.StandardResult {
+	res := &common.StandardResult{Success: false}
+	if dto.HeadKey == "" || dto.PathUrl == "" {
+		return res.SetReturnCode(PARAMS_IS_NULL)
+	}
+	spec, err := impl.specDb.GetByHeadKey(dto.HeadKey)
+	if err != nil {
+		log.Error(errors.WithStack(err))
+		return res
+	}
+	if spec == nil {
+		return res.SetReturnCode(MOCK_IS_NOT_EXISTS)
+	}
+	mock, err := impl.loadOpenapiMock(spec)
+	if err != nil {
+		log.Errorf("parse mock spec failed, headKey:%s, err:%+v", spec.HeadKey, err)
+		return res.SetReturnCode(MOCK_SPEC_INVALID)
+	}
+	resp, err := mock.Call(dto, !spec.IsSkipValidate)
+	if err != nil {
+		mockErr, ok := err.(*gw.MockError)
+		if !ok {
+			log.Error(errors.WithStack(err))
+			return res
+		}
+		var code StandardErrorCode
+		switch mockErr.Kind {
+		case gw.MEK_ROUTE_NOT_FOUND:
+			code = MOCK_IS_NOT_EXISTS
+		case gw.MEK_INVALID_REQUEST:
+			code = MOCK_REQUEST_INVALID
+		default:
+			code = MOCK_SCENARIO_NOT_EXISTS
+		}
+		return res.SetErrorInfo(&common.ErrInfo{
+			Code: code.GetCode(),
+			Msg:  code.GetMessage() + ": " + mockErr.Reason,
+		})
+	}
+	return res.SetSuccessAndData(resp)
+}
+
+func (impl GatewayMockServiceImpl) loadOpenapiMock(spec *orm.GatewayMockSpec) (*gw.OpenapiMock, error) {
+	if value, ok := openapiMockCache.Load(spec.HeadKey); ok {
+		cached := value.(cachedOpenapiMock)
+		if cached.updateTime.Equal(spec.UpdatedAt) {
+			return cached.mock, nil
+		}
+	}
+	mock, err := gw.NewOpenapiMock([]byte(spec.Spec))
+	if err != nil {
+		return nil, err
+	}
+	openapiMockCache.Store(spec.HeadKey, cachedOpenapiMock{spec.UpdatedAt, mock})
+	return mock, nil
+}
@@ -34,18 +34,21 @@ import (
 type GatewayOpenapiRuleServiceImpl struct {
 	packageDb    db.GatewayPackageService
 	consumerDb   db.GatewayConsumerService
+	quotaDb      db.GatewayConsumerQuotaService
 	ruleDb       db.GatewayPackageRuleService
 	routeDb      db.GatewayRouteService
 	azDb         db.GatewayAzInfoService
 	kongDb       db.GatewayKongInfoService
 	kongPolicyDb db.GatewayPolicyService
 	packageApiDb db.GatewayPackageApiService
+	splitDb      db.GatewayTrafficSplitService
 	zoneBiz      GatewayZoneService
 	domainBiz    GatewayDomainService
 }
 
 func NewGatewayOpenapiRuleServiceImpl() (*GatewayOpenapiRuleServiceImpl, error) {
 	consumerDb, _ := db.NewGatewayConsumerServiceImpl()
+	quotaDb, _ := db.NewGatewayConsumerQuotaServiceImpl()
 	packageDb, _ := db.NewGatewayPackageServiceImpl()
 	kongPolicyDb, _ := db.NewGatewayPolicyServiceImpl()
 	ruleDb, _ := db.NewGatewayPackageRuleServiceImpl()
@@ -53,16 +56,19 @@ func NewGatewayOpenapiRuleServiceImpl() (*GatewayOpenapiRuleServiceImpl, error)
 	azDb, _ := db.NewGatewayAzInfoServiceImpl()
 	kongDb, _ := db.NewGatewayKongInfoServiceImpl()
 	packageApiDb, _ := db.NewGatewayPackageApiServiceImpl()
+	splitDb, _ := db.NewGatewayTrafficSplitServiceImpl()
 	zoneBiz, _ := NewGatewayZoneServiceImpl()
 	domainBiz, _ := NewGatewayDomainServiceImpl()
 	return &GatewayOpenapiRuleServiceImpl{
 		consumerDb:   consumerDb,
+		quotaDb:      quotaDb,
 		packageDb:    packageDb,
 		ruleDb:       ruleDb,
 		routeDb:      routeDb,
 		azDb:         azDb,
 		kongDb:       kongDb,
 		packageApiDb: packageApiDb,
+		splitDb:      splitDb,
 		zoneBiz:      zoneBiz,
 		kongPolicyDb: kongPolicyDb,
 		domainBiz:    domainBiz,
@@ -109,6 +115,18 @@ func (impl GatewayOpenapiRuleServiceImpl) config2Limit(config []byte) exdto.Limi
 	return res
 }
 
+// 消费者上的配额插件会被更具体的流量限制插件绕过, 不能同时配置
+func (impl GatewayOpenapiRuleServiceImpl) checkQuota(consumerId string) error {
+	quota, err := impl.quotaDb.GetByConsumerId(consumerId)
+	if err != nil {
+		return err
+	}
+	if quota != nil {
+		return errors.Errorf("consumer has quota, remove it before setting limit rules, consumer:%s", consumerId)
+	}
+	return nil
+}
+
 func (impl GatewayOpenapiRuleServiceImpl) checkApi(dto *gw.OpenLimitRuleDto) error {
 	if dto.Method == "" && dto.ApiPath == "" {
 		return nil
@@ -442,6 +460,12 @@ func (impl GatewayOpenapiRuleServiceImpl) CreateOrUpdateLimitRule(consumerId, pa
 			return
 		}
 	}
+	if len(limits) > 0 {
+		err = impl.checkQuota(consumerId)
+		if err != nil {
+			return
+		}
+	}
 	for _, limit := range limits {
 		limitDto := &gw.OpenLimitRuleDto{
 			ConsumerId: consumerId,
@@ -501,6 +525,11 @@ func (impl GatewayOpenapiRuleServiceImpl) CreateLimitRule(args *gw.DiceArgsDto,
 		res.SetReturnCode(INVALID_LIMIT_RULE)
 		goto failed
 	}
+	err = impl.checkQuota(dto.ConsumerId)
+	if err != nil {
+		res.SetReturnCode(QUOTA_LIMIT_CONFLICT)
+		goto failed
+	}
 	err = impl.checkApi(dto)
 	if err != nil {
 		res.SetReturnCode(INVALID_LIMIT_API)
@@ -572,6 +601,11 @@ func (impl GatewayOpenapiRuleServiceImpl) UpdateLimitRule(ruleId string, dto *gw
 		res.SetReturnCode(INVALID_LIMIT_RULE)
 		goto failed
 	}
+	err = impl.checkQuota(dto.ConsumerId)
+	if err != nil {
+		res.SetReturnCode(QUOTA_LIMIT_CONFLICT)
+		goto failed
+	}
 	err = impl.checkApi(dto)
 	if err != nil {
 		res.SetReturnCode(INVALID_LIMIT_API)
@@ -790,6 +824,54 @@ func (impl GatewayOpenapiRuleServiceImpl) deleteKongPlugin(adapter kong.KongAdap
 	return adapter.RemovePlugin(pluginId)
 }
 
+// checkTrafficSplitRules refuses api rules when requests of api are split by headers,
+// since the plugins of rule only work on the api route
+func (impl GatewayOpenapiRuleServiceImpl) checkTrafficSplitRules(rule *gw.OpenapiRule) error {
+	if rule.Region != gw.API_RULE || rule.PackageApiId == "" {
+		return nil
+	}
+	split, err := impl.splitDb.GetByApiId(rule.PackageApiId)
+	if err != nil {
+		return err
+	}
+	if split == nil {
+		return nil
+	}
+	config := gw.TrafficSplitDto{}
+	err = json.Unmarshal([]byte(split.Config), &config)
+	if err != nil {
+		return errors.Wrap(err, ERR_JSON_FAIL)
+	}
+	if len(config.Rules) > 0 {
+		return errors.Errorf("api %s splits traffic by headers or cookies, remove the rules of traffic split first", rule.PackageApiId)
+	}
+	return nil
+}
+
+// checkGrpcWebRules refuses api rules when the grpc api accepts grpc-web requests,
+// since the plugins of rule only work on the grpc route, not the grpc-web route beside it
+func (impl GatewayOpenapiRuleServiceImpl) checkGrpcWebRules(rule *gw.OpenapiRule) error {
+	if rule.Region != gw.API_RULE || rule.PackageApiId == "" || rule.NotKongPlugin {
+		return nil
+	}
+	api, err := impl.packageApiDb.Get(rule.PackageApiId)
+	if err != nil {
+		return err
+	}
+	if api == nil {
+		return nil
+	}
+	dto := gw.OpenapiDto{}
+	err = dto.SetProtocolConfig(api.Protocol, api.ProtocolConfig)
+	if err != nil {
+		return err
+	}
+	if dto.GrpcWebEnabled() {
+		return errors.Errorf("api %s accepts grpc-web requests, turn off grpc-web first", rule.PackageApiId)
+	}
+	return nil
+}
+
 func (impl GatewayOpenapiRuleServiceImpl) CreateRule(diceInfo DiceInfo, rule *gw.OpenapiRule, helper *db.SessionHelper) error {
 	var ruleDbService db.GatewayPackageRuleService
 	var err error
@@ -805,6 +887,14 @@ func (impl GatewayOpenapiRuleServiceImpl) CreateRule(diceInfo DiceInfo, rule *gw
 	if dao == nil {
 		return errors.Errorf("convert to dao failed, rule:%+v", rule)
 	}
+	err = impl.checkTrafficSplitRules(rule)
+	if err != nil {
+		return err
+	}
+	err = impl.checkGrpcWebRules(rule)
+	if err != nil {
+		return err
+	}
 	if !rule.NotKongPlugin {
 		az, err := impl.azDb.GetAz(&orm.GatewayAzInfo{
 			Env:       diceInfo.Env,
@@ -70,6 +70,8 @@ type GatewayOpenapiServiceImpl struct {
 	policyBiz       GatewayApiPolicyService
 	runtimeDb       db.GatewayRuntimeServiceService
 	domainBiz       GatewayDomainService
+	splitBiz        GatewayTrafficSplitService
+	resilienceBiz   GatewayUpstreamResilienceService
 	ctx             context.Context
 	ReqCtx          *gin.Context
 }
@@ -95,6 +97,8 @@ func NewGatewayOpenapiServiceImpl() (*GatewayOpenapiServiceImpl, error) {
 	policyBiz, _ := NewGatewayApiPolicyServiceImpl()
 	runtimeDb, _ := db.NewGatewayRuntimeServiceServiceImpl()
 	domainBiz, _ := NewGatewayDomainServiceImpl()
+	splitBiz, _ := NewGatewayTrafficSplitServiceImpl()
+	resilienceBiz, _ := NewGatewayUpstreamResilienceServiceImpl()
 	return &GatewayOpenapiServiceImpl{
 		packageDb:       packageDb,
 		packageApiDb:    packageApiDb,
@@ -116,6 +120,8 @@ func NewGatewayOpenapiServiceImpl() (*GatewayOpenapiServiceImpl, error) {
 		policyBiz:       policyBiz,
 		runtimeDb:       runtimeDb,
 		domainBiz:       domainBiz,
+		splitBiz:        splitBiz,
+		resilienceBiz:   resilienceBiz,
 	}, nil
 }
 
@@ -566,6 +572,14 @@ func (impl GatewayOpenapiServiceImpl) updatePackageApiHost(pack *orm.GatewayPack
 		if err != nil {
 			return err
 		}
+		err = impl.splitBiz.SyncPackageApiTrafficSplit(kongAdapter, api.Id)
+		if err != nil {
+			return err
+		}
+		err = impl.resilienceBiz.SyncPackageApiResilience(kongAdapter, api.Id)
+		if err != nil {
+			return err
+		}
 	}
 	return nil
 }
@@ -977,15 +991,17 @@ failed:
 
 func (impl GatewayOpenapiServiceImpl) packageApiDao(dto *gw.OpenapiDto) *orm.GatewayPackageApi {
 	dao := &orm.GatewayPackageApi{
-		ApiPath:      dto.ApiPath,
-		Method:       dto.Method,
-		DiceApp:      dto.RedirectApp,
-		DiceService:  dto.RedirectService,
-		RedirectAddr: dto.RedirectAddr,
-		RedirectPath: dto.RedirectPath,
-		RedirectType: dto.RedirectType,
-		Description:  dto.Description,
-		Origin:       string(gw.FROM_CUSTOM),
+		ApiPath:        dto.ApiPath,
+		Method:         dto.Method,
+		DiceApp:        dto.RedirectApp,
+		DiceService:    dto.RedirectService,
+		RedirectAddr:   dto.RedirectAddr,
+		RedirectPath:   dto.RedirectPath,
+		RedirectType:   dto.RedirectType,
+		Description:    dto.Description,
+		Origin:         string(gw.FROM_CUSTOM),
+		Protocol:       dto.RouteProtocol(),
+		ProtocolConfig: dto.ProtocolConfig(),
 	}
 	if dto.AllowPassAuth {
 		dao.AclType = gw.ACL_OFF
@@ -1000,11 +1016,16 @@ func (impl GatewayOpenapiServiceImpl) createKongServiceReq(dto *gw.OpenapiDto, s
 	i := 0
 	reqDto := &kongDto.KongServiceReqDto{
 		Url:            dto.AdjustRedirectAddr,
-		ConnectTimeout: 5000,
-		ReadTimeout:    60000,
-		WriteTimeout:   60000,
+		ConnectTimeout: gw.DEFAULT_CONNECT_TIMEOUT,
+		ReadTimeout:    gw.DEFAULT_READ_TIMEOUT,
+		WriteTimeout:   gw.DEFAULT_WRITE_TIMEOUT,
 		Retries:        &i,
 	}
+	// kong closes websocket connections idle longer than the read or write timeout
+	if dto.RouteProtocol() == gw.RP_WEBSOCKET && dto.IdleTimeout > 0 {
+		reqDto.ReadTimeout = dto.IdleTimeout * 1000
+		reqDto.WriteTimeout = dto.IdleTimeout * 1000
+	}
 	if len(serviceId) != 0 {
 		reqDto.ServiceId = serviceId[0]
 	}
@@ -1102,12 +1123,113 @@ func (impl GatewayOpenapiServiceImpl) createKongRouteReq(dto *gw.OpenapiDto, ser
 		ignore := strings.Count(dto.AdjustPath, "^/") + strings.Count(dto.AdjustPath, `\/`)
 		reqDto.RegexPriority = strings.Count(dto.AdjustPath, "/") - ignore
 	}
+	switch dto.RouteProtocol() {
+	case gw.RP_GRPC:
+		// upstream expects the method paths as is
+		stripPath := false
+		reqDto.StripPath = &stripPath
+		reqDto.Paths = dto.GrpcPaths
+		reqDto.Methods = nil
+		reqDto.Protocols = []string{"grpc", "grpcs"}
+	case gw.RP_WEBSOCKET:
+		// kong proxies the upgrade request of websocket handshake
+		reqDto.Methods = []string{"GET"}
+	}
 	if len(routeId) != 0 {
 		reqDto.RouteId = routeId[0]
 	}
 	return reqDto
 }
 
+// grpcRouteSupported reports whether kong of version proxies grpc, gateway api backend supports it
+func grpcRouteSupported(version string) bool {
+	parts := strings.SplitN(version, ".", 3)
+	major, err := strconv.Atoi(parts[0])
+	if err != nil {
+		return true
+	}
+	minor := 0
+	if len(parts) > 1 {
+		minor, _ = strconv.Atoi(parts[1])
+	}
+	return major >= 2 || (major == 1 && minor >= 3)
+}
+
+func (impl GatewayOpenapiServiceImpl) checkRouteProtocol(adapter kong.KongAdapter, dto *gw.OpenapiDto) error {
+	if dto.RouteProtocol() != gw.RP_GRPC {
+		return nil
+	}
+	version, err := adapter.GetVersion()
+	if err != nil {
+		return err
+	}
+	if !grpcRouteSupported(version) {
+		return errors.Errorf("grpc api needs kong 1.3 or later, current version:%s", version)
+	}
+	if dto.Grpc.GrpcWeb {
+		enabled, err := adapter.CheckPluginEnabled(gw.GRPC_WEB_PLUGIN)
+		if err != nil {
+			return err
+		}
+		if !enabled {
+			return errors.Errorf("plugin %s not enabled", gw.GRPC_WEB_PLUGIN)
+		}
+	}
+	return nil
+}
+
+// grpcWebRouteName is the name of kong route which accepts grpc-web requests of grpc api
+func grpcWebRouteName(apiId string) string {
+	return "grpc-web-" + apiId
+}
+
+// checkGrpcWebRules refuses grpc-web for api with rules, since the plugins of api rules
+// are bound to the grpc route and don't work on the grpc-web route beside it
+func (impl GatewayOpenapiServiceImpl) checkGrpcWebRules(dto *gw.OpenapiDto, apiId string) error {
+	// rules of passing auth are created with api
+	if dto.AllowPassAuth {
+		return errors.Errorf("api %s accepts grpc-web requests, which can't pass the auth of package", apiId)
+	}
+	rules, err := impl.ruleBiz.GetApiRules(apiId)
+	if err != nil {
+		return err
+	}
+	for _, rule := range rules {
+		if !rule.NotKongPlugin {
+			return errors.Errorf("api %s has rules which don't work on grpc-web requests, remove the rules of api first", apiId)
+		}
+	}
+	return nil
+}
+
+// touchGrpcWebRoute keeps a route beside the grpc route of api for grpc-web requests,
+// they come in http/1.1 and are translated by plugin, native grpc clients still match the grpc route,
+// plugins of the grpc route are added by createOrUpdatePlugins
+func (impl GatewayOpenapiServiceImpl) touchGrpcWebRoute(adapter kong.KongAdapter, dto *gw.OpenapiDto, apiId string) error {
+	name := grpcWebRouteName(apiId)
+	if !dto.GrpcWebEnabled() {
+		// plugins of route are deleted with it
+		return adapter.DeleteRoute(name)
+	}
+	err := impl.checkGrpcWebRules(dto, apiId)
+	if err != nil {
+		return err
+	}
+	req := impl.createKongRouteReq(dto, dto.ServiceId, name)
+	req.Name = name
+	req.Protocols = []string{"http", "https"}
+	resp, err := adapter.CreateOrUpdateRoute(req)
+	if err != nil {
+		return err
+	}
+	dto.GrpcWebRouteId = resp.Id
+	_, err = adapter.CreateOrUpdatePlugin(&kongDto.KongPluginReqDto{
+		Name:    gw.GRPC_WEB_PLUGIN,
+		RouteId: resp.Id,
+	})
+	return err
+}
+
 func (impl GatewayOpenapiServiceImpl) kongRouteDao(dto *kongDto.KongRouteRespDto, serviceId, apiId string) *orm.GatewayRoute {
 	protocols, _ := json.Marshal(dto.Protocols)
 	hosts, _ := json.Marshal(dto.Hosts)
@@ -1140,6 +1262,10 @@ func (impl GatewayOpenapiServiceImpl) touchKongRoute(adapter kong.KongAdapter, d
 	if err != nil {
 		return "", err
 	}
+	err = impl.checkRouteProtocol(adapter, dto)
+	if err != nil {
+		return "", err
+	}
 	var req *kongDto.KongRouteReqDto
 	if route == nil {
 		req = impl.createKongRouteReq(dto, dto.ServiceId)
@@ -1150,6 +1276,18 @@ func (impl GatewayOpenapiServiceImpl) touchKongRoute(adapter kong.KongAdapter, d
 	if err != nil {
 		return "", err
 	}
+	// grpc-web plugin used to be on the grpc route itself
+	err = adapter.DeletePluginIfExist(&kongDto.KongPluginReqDto{
+		Name:    gw.GRPC_WEB_PLUGIN,
+		RouteId: resp.Id,
+	})
+	if err != nil {
+		return "", err
+	}
+	err = impl.touchGrpcWebRoute(adapter, dto, apiId)
+	if err != nil {
+		return "", err
+	}
 	routeDao := impl.kongRouteDao(resp, dto.ServiceId, apiId)
 	if err != nil {
 		return "", err
@@ -1170,6 +1308,10 @@ func (impl GatewayOpenapiServiceImpl) touchKongRoute(adapter kong.KongAdapter, d
 }
 
 func (impl GatewayOpenapiServiceImpl) deleteKongRoute(adapter kong.KongAdapter, apiId string) error {
+	err := adapter.DeleteRoute(grpcWebRouteName(apiId))
+	if err != nil {
+		return err
+	}
 	route, err := impl.routeDb.GetByApiId(apiId)
 	if err != nil {
 		return err
@@ -1201,34 +1343,41 @@ func (impl GatewayOpenapiServiceImpl) deleteKongApi(adapter kong.KongAdapter, ap
 }
 
 func (impl GatewayOpenapiServiceImpl) createOrUpdatePlugins(adapter kong.KongAdapter, dto *gw.OpenapiDto) error {
+	var reqs []*kongDto.KongPluginReqDto
 	if dto.ServiceRewritePath != "" {
-		reqDto := &kongDto.KongPluginReqDto{
+		reqs = append(reqs, &kongDto.KongPluginReqDto{
 			Name: "path-variable",
-			// ServiceId: dto.ServiceId,
-			RouteId: dto.RouteId,
 			Config: map[string]interface{}{
 				"request_regex": dto.AdjustPath,
 				"rewrite_path":  dto.ServiceRewritePath,
 			},
-		}
-		_, err := adapter.CreateOrUpdatePlugin(reqDto)
-		if err != nil {
-			return err
-		}
+		})
 	}
 	if config.ServerConf.HasRouteInfo {
-		reqDto := &kongDto.KongPluginReqDto{
-			Name:    "set-route-info",
-			RouteId: dto.RouteId,
+		reqs = append(reqs, &kongDto.KongPluginReqDto{
+			Name: "set-route-info",
 			Config: map[string]interface{}{
 				"project_id": dto.ProjectId,
 				"workspace":  strings.ToLower(dto.Env),
 				"api_path":   dto.ApiPath,
 			},
-		}
-		_, err := adapter.CreateOrUpdatePlugin(reqDto)
-		if err != nil {
-			return err
+		})
+	}
+	// grpc-web requests match the route beside the grpc route, which needs the same plugins
+	routeIds := []string{dto.RouteId}
+	if dto.GrpcWebRouteId != "" {
+		routeIds = append(routeIds, dto.GrpcWebRouteId)
+	}
+	for _, routeId := range routeIds {
+		for _, req := range reqs {
+			_, err := adapter.CreateOrUpdatePlugin(&kongDto.KongPluginReqDto{
+				Name:    req.Name,
+				RouteId: routeId,
+				Config:  req.Config,
+			})
+			if err != nil {
+				return err
+			}
 		}
 	}
 	return nil
@@ -1897,6 +2046,10 @@ func (impl GatewayOpenapiServiceImpl) openapiDto(dao *orm.GatewayPackageApi) *gw
 	if dao.AclType == gw.ACL_OFF {
 		dto.AllowPassAuth = true
 	}
+	err := dto.SetProtocolConfig(dao.Protocol, dao.ProtocolConfig)
+	if err != nil {
+		log.Errorf("invalid protocol config of api:%s, err:%+v", dao.Id, err)
+	}
 	if dto.Origin == gw.FROM_DICE || dto.Origin == gw.FROM_SHADOW {
 		dto.Mutable = false
 	} else {
@@ -1922,6 +2075,9 @@ func (impl GatewayOpenapiServiceImpl) openapiDto(dao *orm.GatewayPackageApi) *gw
 			log.Errorf("invalide RedirectAddr %s", dto.RedirectAddr)
 			return dto
 		}
+		if dto.RouteProtocol() == gw.RP_GRPC {
+			return dto
+		}
 		dto.RedirectPath = "/"
 		slash_find := strings.Index(dto.RedirectAddr[scheme_find+3:], "/")
 		if slash_find != -1 {
@@ -2085,7 +2241,8 @@ func (impl GatewayOpenapiServiceImpl) UpdatePackageApi(packageId, apiId string,
 		goto failed
 	}
 	auditCtx["endpoint"] = pack.PackageName
-	if pack.Scene == orm.UNITY_SCENE {
+	// paths of grpc api are fixed by proto
+	if pack.Scene == orm.UNITY_SCENE && dto.RouteProtocol() != gw.RP_GRPC {
 		var defaultPath string
 		defaultPath, err = impl.globalBiz.GenerateDefaultPath(pack.DiceProjectId)
 		if err != nil {
@@ -2140,7 +2297,8 @@ func (impl GatewayOpenapiServiceImpl) UpdatePackageApi(packageId, apiId string,
 			updateDao.RuntimeServiceId = ""
 			updateDao.DiceApp = ""
 			updateDao.DiceService = ""
-			if dto.RedirectAddr != dao.RedirectAddr || dto.ApiPath != dao.ApiPath || dto.Method != dao.Method {
+			if dto.RedirectAddr != dao.RedirectAddr || dto.ApiPath != dao.ApiPath || dto.Method != dao.Method ||
+				updateDao.Protocol != dao.Protocol || updateDao.ProtocolConfig != dao.ProtocolConfig {
 				serviceId, err = impl.touchKongService(kongAdapter, dto, apiId)
 				if err != nil {
 					goto failed
@@ -2151,6 +2309,14 @@ func (impl GatewayOpenapiServiceImpl) UpdatePackageApi(packageId, apiId string,
 					goto failed
 				}
 				dto.RouteId = routeId
+				err = impl.splitBiz.SyncPackageApiTrafficSplit(kongAdapter, apiId)
+				if err != nil {
+					goto failed
+				}
+				err = impl.resilienceBiz.SyncPackageApiResilience(kongAdapter, apiId)
+				if err != nil {
+					goto failed
+				}
 			} else {
 				var route *orm.GatewayRoute
 				route, err = impl.routeDb.GetByApiId(apiId)
@@ -2170,6 +2336,14 @@ func (impl GatewayOpenapiServiceImpl) UpdatePackageApi(packageId, apiId string,
 		} else if updateDao.RedirectType == gw.RT_SERVICE {
 			var runtimeService *orm.GatewayRuntimeService
 			if dao.RedirectType == gw.RT_URL {
+				err = impl.splitBiz.ClearPackageApiTrafficSplit(kongAdapter, apiId)
+				if err != nil {
+					goto failed
+				}
+				err = impl.resilienceBiz.ClearPackageApiResilience(kongAdapter, apiId)
+				if err != nil {
+					goto failed
+				}
 				err = impl.deleteKongApi(kongAdapter, apiId)
 				if err != nil {
 					goto failed
@@ -2341,6 +2515,14 @@ func (impl *GatewayOpenapiServiceImpl) DeletePackageApi(packageId, apiId string)
 		}
 
 	} else if dao.Origin == string(gw.FROM_CUSTOM) || dao.Origin == string(gw.FROM_DICEYML) {
+		err = impl.splitBiz.ClearPackageApiTrafficSplit(kongAdapter, apiId)
+		if err != nil {
+			goto failed
+		}
+		err = impl.resilienceBiz.ClearPackageApiResilience(kongAdapter, apiId)
+		if err != nil {
+			goto failed
+		}
 		err = impl.deleteKongApi(kongAdapter, apiId)
 		if err != nil {
 			goto failed
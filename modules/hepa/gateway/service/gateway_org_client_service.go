@@ -32,6 +32,7 @@ type GatewayOrgClientServiceImpl struct {
 	consumerDb  db.GatewayConsumerService
 	consumerBiz GatewayOpenapiConsumerService
 	ruleBiz     GatewayOpenapiRuleService
+	quotaBiz    GatewayConsumerQuotaService
 }
 
 func NewGatewayOrgClientServiceImpl() (*GatewayOrgClientServiceImpl, error) {
@@ -56,12 +57,17 @@ func NewGatewayOrgClientServiceImpl() (*GatewayOrgClientServiceImpl, error) {
 	if err != nil {
 		return nil, err
 	}
+	quotaBiz, err := NewGatewayConsumerQuotaServiceImpl()
+	if err != nil {
+		return nil, err
+	}
 	return &GatewayOrgClientServiceImpl{
 		clientDb:    clientDb,
 		packageDb:   packageDb,
 		consumerDb:  consumerDb,
 		consumerBiz: consumerBiz,
 		ruleBiz:     ruleBiz,
+		quotaBiz:    quotaBiz,
 	}, nil
 }
 
@@ -305,6 +311,10 @@ func (impl GatewayOrgClientServiceImpl) GrantPackage(id, packageId string) (res
 		if err != nil {
 			return
 		}
+		err = impl.quotaBiz.InheritClientQuota(consumer)
+		if err != nil {
+			return
+		}
 	}
 	err = impl.consumerBiz.GrantPackageToConsumer(consumer.Id, packageId)
 	if err != nil {
@@ -0,0 +1,561 @@
+// Copyright (c) 2021 Terminus, Inc.
+//
+// This program is free software: you can use, redistribute, and/or modify
+// it under the terms of the GNU Affero General Public License, version 3
+// or later ("AGPL"), as published by the Free Software Foundation.
+//
+// This program is distributed in the hope that it will be useful, but WITHOUT
+// ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+// FITNESS FOR A PARTICULAR PURPOSE.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package service
+
+import (
+	"encoding/json"
+	"net"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/erda-project/erda/modules/hepa/common"
+	. "github.com/erda-project/erda/modules/hepa/common/vars"
+	gw "github.com/erda-project/erda/modules/hepa/gateway/dto"
+	"github.com/erda-project/erda/modules/hepa/kong"
+	kongDto "github.com/erda-project/erda/modules/hepa/kong/dto"
+	"github.com/erda-project/erda/modules/hepa/repository/orm"
+	db "github.com/erda-project/erda/modules/hepa/repository/service"
+
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+type GatewayTrafficSplitServiceImpl struct {
+	packageDb    db.GatewayPackageService
+	packageApiDb db.GatewayPackageApiService
+	serviceDb    db.GatewayServiceService
+	routeDb      db.GatewayRouteService
+	ruleDb       db.GatewayPackageRuleService
+	kongDb       db.GatewayKongInfoService
+	splitDb      db.GatewayTrafficSplitService
+	// resilience of api works on the upstream of split
+	resilienceBiz GatewayUpstreamResilienceService
+}
+
+// trafficSplitRoute is the kong route and service forwarding requests matching a rule
+type trafficSplitRoute struct {
+	RouteId   string `json:"routeId"`
+	ServiceId string `json:"serviceId"`
+}
+
+func NewGatewayTrafficSplitServiceImpl() (*GatewayTrafficSplitServiceImpl, error) {
+	packageDb, _ := db.NewGatewayPackageServiceImpl()
+	packageApiDb, _ := db.NewGatewayPackageApiServiceImpl()
+	serviceDb, _ := db.NewGatewayServiceServiceImpl()
+	routeDb, _ := db.NewGatewayRouteServiceImpl()
+	ruleDb, _ := db.NewGatewayPackageRuleServiceImpl()
+	kongDb, _ := db.NewGatewayKongInfoServiceImpl()
+	splitDb, _ := db.NewGatewayTrafficSplitServiceImpl()
+	resilienceBiz, _ := NewGatewayUpstreamResilienceServiceImpl()
+	return &GatewayTrafficSplitServiceImpl{
+		packageDb:     packageDb,
+		packageApiDb:  packageApiDb,
+		serviceDb:     serviceDb,
+		routeDb:       routeDb,
+		ruleDb:        ruleDb,
+		kongDb:        kongDb,
+		splitDb:       splitDb,
+		resilienceBiz: resilienceBiz,
+	}, nil
+}
+
+func trafficSplitUpstreamName(apiId string) string {
+	return "traffic-split-" + apiId
+}
+
+// routeMatchSupported reports whether kong of version matches routes by headers, and by
+// header regex which cookie rules need, gateway api backend supports both
+func routeMatchSupported(version string) (header bool, regex bool) {
+	parts := strings.SplitN(version, ".", 3)
+	major, err := strconv.Atoi(parts[0])
+	if err != nil {
+		return true, true
+	}
+	minor := 0
+	if len(parts) > 1 {
+		minor, _ = strconv.Atoi(parts[1])
+	}
+	if major >= 3 {
+		return true, true
+	}
+	if major == 2 || (major == 1 && minor >= 3) {
+		return true, false
+	}
+	return false, false
+}
+
+func trafficRuleHeaders(rule gw.TrafficMatchRuleDto) map[string][]string {
+	if rule.Type == gw.TMT_COOKIE {
+		// ~* marks regex header value in kong 3.x
+		return map[string][]string{
+			"cookie": {`~*(^|;\s*)` + regexp.QuoteMeta(rule.Name) + "=" + regexp.QuoteMeta(rule.Value) + `(;|$)`},
+		}
+	}
+	return map[string][]string{rule.Name: {rule.Value}}
+}
+
+func trafficSplitServiceReq(service *orm.GatewayService, protocol, host string, port int) *kongDto.KongServiceReqDto {
+	i := 0
+	return &kongDto.KongServiceReqDto{
+		Protocol:       protocol,
+		Host:           host,
+		Port:           port,
+		Path:           service.Path,
+		ConnectTimeout: gw.DEFAULT_CONNECT_TIMEOUT,
+		ReadTimeout:    gw.DEFAULT_READ_TIMEOUT,
+		WriteTimeout:   gw.DEFAULT_WRITE_TIMEOUT,
+		Retries:        &i,
+	}
+}
+
+func defaultPort(protocol string) int {
+	if protocol == "https" {
+		return 443
+	}
+	return 80
+}
+
+func (impl GatewayTrafficSplitServiceImpl) kongAdapter(packageId string) (kong.KongAdapter, error) {
+	pack, err := impl.packageDb.Get(packageId)
+	if err != nil {
+		return nil, err
+	}
+	if pack == nil {
+		return nil, errors.New("package not exist")
+	}
+	kongInfo, err := impl.kongDb.GetKongInfo(&orm.GatewayKongInfo{
+		Az:        pack.DiceClusterName,
+		ProjectId: pack.DiceProjectId,
+		Env:       pack.DiceEnv,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return kong.NewKongAdapter(kongInfo.KongAddr), nil
+}
+
+func (impl GatewayTrafficSplitServiceImpl) getPackageApi(packageId, apiId string) (*orm.GatewayPackageApi, error) {
+	api, err := impl.packageApiDb.Get(apiId)
+	if err != nil {
+		return nil, err
+	}
+	if api == nil || api.PackageId != packageId {
+		return nil, nil
+	}
+	return api, nil
+}
+
+// render applies split to kong, weighted backends become targets of the upstream which the
+// service of api forwards to, and each rule becomes a route matching headers of the api route,
+// which forwards to the backend of rule
+func (impl GatewayTrafficSplitServiceImpl) render(adapter kong.KongAdapter, split *orm.GatewayTrafficSplit, old, dto *gw.TrafficSplitDto) error {
+	service, err := impl.serviceDb.GetByApiId(split.ApiId)
+	if err != nil {
+		return err
+	}
+	if service == nil {
+		return errors.Errorf("service of api:%s not exist", split.ApiId)
+	}
+	if split.KongUpstreamId == "" {
+		upstream, err := adapter.CreateUpstream(&kongDto.KongUpstreamDto{
+			Name: trafficSplitUpstreamName(split.ApiId),
+		})
+		if err != nil {
+			return err
+		}
+		split.KongUpstreamId = upstream.Id
+	}
+	oldWeights := map[string]int{}
+	if old != nil {
+		for _, backend := range old.Backends {
+			oldWeights[backend.Target()] = backend.Weight
+		}
+	}
+	weights := map[string]int{}
+	for _, backend := range dto.Backends {
+		weights[backend.Target()] = backend.Weight
+		if backend.Weight == 0 || oldWeights[backend.Target()] == backend.Weight {
+			continue
+		}
+		_, err = adapter.AddUpstreamTarget(split.KongUpstreamId, &kongDto.KongTargetDto{
+			Target: backend.Target(),
+			Weight: int64(backend.Weight),
+		})
+		if err != nil {
+			return err
+		}
+	}
+	for target, weight := range oldWeights {
+		if weight == 0 || weights[target] != 0 {
+			continue
+		}
+		err = adapter.DeleteUpstreamTarget(split.KongUpstreamId, target)
+		if err != nil {
+			return err
+		}
+	}
+	protocol := dto.Backends[0].Scheme()
+	req := trafficSplitServiceReq(service, protocol, trafficSplitUpstreamName(split.ApiId), defaultPort(protocol))
+	req.ServiceId = service.ServiceId
+	_, err = adapter.CreateOrUpdateService(req)
+	if err != nil {
+		return err
+	}
+	return impl.renderRules(adapter, split, service, dto)
+}
+
+func (impl GatewayTrafficSplitServiceImpl) renderRules(adapter kong.KongAdapter, split *orm.GatewayTrafficSplit, service *orm.GatewayService, dto *gw.TrafficSplitDto) error {
+	var oldRoutes, routes []trafficSplitRoute
+	if split.KongRoutes != "" {
+		err := json.Unmarshal([]byte(split.KongRoutes), &oldRoutes)
+		if err != nil {
+			return errors.Wrap(err, ERR_JSON_FAIL)
+		}
+	}
+	var route *orm.GatewayRoute
+	var plugins []kongDto.KongPluginRespDto
+	var err error
+	if len(dto.Rules) > 0 {
+		route, err = impl.routeDb.GetByApiId(split.ApiId)
+		if err != nil {
+			return err
+		}
+		if route == nil {
+			return errors.Errorf("route of api:%s not exist", split.ApiId)
+		}
+		// plugins of api route work on the rule routes too
+		plugins, err = adapter.GetRoutePlugins(route.RouteId)
+		if err != nil {
+			return err
+		}
+	}
+	for i, rule := range dto.Rules {
+		var exist trafficSplitRoute
+		if i < len(oldRoutes) {
+			exist = oldRoutes[i]
+		}
+		backend := dto.GetBackend(rule.Backend)
+		host, portStr, err := net.SplitHostPort(backend.Target())
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		port, _ := strconv.Atoi(portStr)
+		serviceReq := trafficSplitServiceReq(service, backend.Scheme(), host, port)
+		serviceReq.ServiceId = exist.ServiceId
+		serviceResp, err := adapter.CreateOrUpdateService(serviceReq)
+		if err != nil {
+			return err
+		}
+		routeReq := &kongDto.KongRouteReqDto{
+			Headers: trafficRuleHeaders(rule),
+			Service: &kongDto.Service{Id: serviceResp.Id},
+			RouteId: exist.RouteId,
+		}
+		_ = json.Unmarshal([]byte(route.Protocols), &routeReq.Protocols)
+		_ = json.Unmarshal([]byte(route.Methods), &routeReq.Methods)
+		_ = json.Unmarshal([]byte(route.Hosts), &routeReq.Hosts)
+		_ = json.Unmarshal([]byte(route.Paths), &routeReq.Paths)
+		routeResp, err := adapter.CreateOrUpdateRoute(routeReq)
+		if err != nil {
+			return err
+		}
+		for _, plugin := range plugins {
+			if plugin.ConsumerId != "" {
+				continue
+			}
+			enabled := plugin.Enabled
+			_, err = adapter.CreateOrUpdatePlugin(&kongDto.KongPluginReqDto{
+				Name:    plugin.Name,
+				RouteId: routeResp.Id,
+				Config:  plugin.Config,
+				Enabled: &enabled,
+			})
+			if err != nil {
+				return err
+			}
+		}
+		routes = append(routes, trafficSplitRoute{RouteId: routeResp.Id, ServiceId: serviceResp.Id})
+	}
+	for i := len(routes); i < len(oldRoutes); i++ {
+		err = impl.deleteRuleRoute(adapter, oldRoutes[i])
+		if err != nil {
+			return err
+		}
+	}
+	kongRoutes, err := json.Marshal(routes)
+	if err != nil {
+		return errors.Wrap(err, ERR_JSON_FAIL)
+	}
+	split.KongRoutes = string(kongRoutes)
+	return nil
+}
+
+func (impl GatewayTrafficSplitServiceImpl) deleteRuleRoute(adapter kong.KongAdapter, route trafficSplitRoute) error {
+	if route.RouteId != "" {
+		err := adapter.DeleteRoute(route.RouteId)
+		if err != nil {
+			return err
+		}
+	}
+	if route.ServiceId != "" {
+		return adapter.DeleteService(route.ServiceId)
+	}
+	return nil
+}
+
+// clear deletes the kong objects of split and the split itself, the service of api is not restored
+func (impl GatewayTrafficSplitServiceImpl) clear(adapter kong.KongAdapter, split *orm.GatewayTrafficSplit) error {
+	var routes []trafficSplitRoute
+	if split.KongRoutes != "" {
+		err := json.Unmarshal([]byte(split.KongRoutes), &routes)
+		if err != nil {
+			return errors.Wrap(err, ERR_JSON_FAIL)
+		}
+	}
+	for _, route := range routes {
+		err := impl.deleteRuleRoute(adapter, route)
+		if err != nil {
+			return err
+		}
+	}
+	if split.KongUpstreamId != "" {
+		err := adapter.DeleteUpstream(split.KongUpstreamId)
+		if err != nil {
+			return err
+		}
+	}
+	return impl.splitDb.DeleteById(split.Id)
+}
+
+func (impl GatewayTrafficSplitServiceImpl) GetPackageApiTrafficSplit(packageId, apiId string) *common.StandardResult {
+	res := &common.StandardResult{Success: false}
+	if packageId == "" || apiId == "" {
+		return res.SetReturnCode(PARAMS_IS_NULL)
+	}
+	var split *orm.GatewayTrafficSplit
+	dto := &gw.TrafficSplitDto{}
+	api, err := impl.getPackageApi(packageId, apiId)
+	if err != nil {
+		goto failed
+	}
+	if api == nil {
+		return res.SetReturnCode(API_NOT_EXIST)
+	}
+	split, err = impl.splitDb.GetByApiId(apiId)
+	if err != nil {
+		goto failed
+	}
+	if split == nil {
+		return res.SetSuccessAndData(nil)
+	}
+	err = json.Unmarshal([]byte(split.Config), dto)
+	if err != nil {
+		goto failed
+	}
+	return res.SetSuccessAndData(dto)
+failed:
+	log.Errorf("error happened, err:%+v", err)
+	return res.SetErrorInfo(&common.ErrInfo{
+		Msg: errors.Cause(err).Error(),
+	})
+}
+
+func (impl GatewayTrafficSplitServiceImpl) SetPackageApiTrafficSplit(packageId, apiId string, dto *gw.TrafficSplitDto) *common.StandardResult {
+	res := &common.StandardResult{Success: false}
+	if packageId == "" || apiId == "" || dto == nil {
+		return res.SetReturnCode(PARAMS_IS_NULL)
+	}
+	var api *orm.GatewayPackageApi
+	var adapter kong.KongAdapter
+	var split *orm.GatewayTrafficSplit
+	var old *gw.TrafficSplitDto
+	var apiRules []orm.GatewayPackageRule
+	var version string
+	var config []byte
+	err := dto.CheckValid()
+	if err != nil {
+		log.Errorf("invalid traffic split, err:%+v", err)
+		return res.SetErrorInfo(&common.ErrInfo{
+			Code: INVALID_TRAFFIC_SPLIT.GetCode(),
+			Msg:  INVALID_TRAFFIC_SPLIT.GetMessage() + ": " + err.Error(),
+		})
+	}
+	api, err = impl.getPackageApi(packageId, apiId)
+	if err != nil {
+		goto failed
+	}
+	if api == nil {
+		return res.SetReturnCode(API_NOT_EXIST)
+	}
+	if api.RedirectType != gw.RT_URL {
+		return res.SetReturnCode(TRAFFIC_SPLIT_NOT_SUPPORT)
+	}
+	adapter, err = impl.kongAdapter(packageId)
+	if err != nil {
+		goto failed
+	}
+	if len(dto.Rules) > 0 {
+		version, err = adapter.GetVersion()
+		if err != nil {
+			goto failed
+		}
+		header, regex := routeMatchSupported(version)
+		for _, rule := range dto.Rules {
+			if !header || (rule.Type == gw.TMT_COOKIE && !regex) {
+				return res.SetReturnCode(TRAFFIC_RULE_NOT_SUPPORT)
+			}
+		}
+		// rules of api are kong plugins bound to the api route, which rule routes can't share
+		apiRules, err = impl.ruleDb.SelectByAny(&orm.GatewayPackageRule{ApiId: apiId})
+		if err != nil {
+			goto failed
+		}
+		if len(apiRules) > 0 {
+			return res.SetReturnCode(TRAFFIC_RULE_API_POLICY)
+		}
+	}
+	split, err = impl.splitDb.GetByApiId(apiId)
+	if err != nil {
+		goto failed
+	}
+	if split == nil {
+		split = &orm.GatewayTrafficSplit{
+			PackageId: packageId,
+			ApiId:     apiId,
+		}
+	} else {
+		old = &gw.TrafficSplitDto{}
+		err = json.Unmarshal([]byte(split.Config), old)
+		if err != nil {
+			goto failed
+		}
+	}
+	err = impl.render(adapter, split, old, dto)
+	if err != nil {
+		goto failed
+	}
+	config, err = json.Marshal(dto)
+	if err != nil {
+		goto failed
+	}
+	split.Config = string(config)
+	if split.Id == "" {
+		err = impl.splitDb.Insert(split)
+	} else {
+		err = impl.splitDb.Update(split)
+	}
+	if err != nil {
+		goto failed
+	}
+	err = impl.resilienceBiz.SyncPackageApiResilience(adapter, apiId)
+	if err != nil {
+		goto failed
+	}
+	return res.SetSuccessAndData(dto)
+failed:
+	log.Errorf("error happened, err:%+v", err)
+	return res.SetErrorInfo(&common.ErrInfo{
+		Msg: errors.Cause(err).Error(),
+	})
+}
+
+func (impl GatewayTrafficSplitServiceImpl) DeletePackageApiTrafficSplit(packageId, apiId string) *common.StandardResult {
+	res := &common.StandardResult{Success: false}
+	if packageId == "" || apiId == "" {
+		return res.SetReturnCode(PARAMS_IS_NULL)
+	}
+	var adapter kong.KongAdapter
+	var split *orm.GatewayTrafficSplit
+	var service *orm.GatewayService
+	var req *kongDto.KongServiceReqDto
+	var port int
+	api, err := impl.getPackageApi(packageId, apiId)
+	if err != nil {
+		goto failed
+	}
+	if api == nil {
+		return res.SetReturnCode(API_NOT_EXIST)
+	}
+	split, err = impl.splitDb.GetByApiId(apiId)
+	if err != nil {
+		goto failed
+	}
+	if split == nil {
+		return res.SetSuccessAndData(true)
+	}
+	adapter, err = impl.kongAdapter(packageId)
+	if err != nil {
+		goto failed
+	}
+	// forward to the redirect address of api again
+	service, err = impl.serviceDb.GetByApiId(apiId)
+	if err != nil {
+		goto failed
+	}
+	if service != nil {
+		port, _ = strconv.Atoi(service.Port)
+		req = trafficSplitServiceReq(service, service.Protocol, service.Host, port)
+		req.ServiceId = service.ServiceId
+		_, err = adapter.CreateOrUpdateService(req)
+		if err != nil {
+			goto failed
+		}
+	}
+	err = impl.clear(adapter, split)
+	if err != nil {
+		goto failed
+	}
+	err = impl.resilienceBiz.SyncPackageApiResilience(adapter, apiId)
+	if err != nil {
+		goto failed
+	}
+	return res.SetSuccessAndData(true)
+failed:
+	log.Errorf("error happened, err:%+v", err)
+	return res.SetErrorInfo(&common.ErrInfo{
+		Msg: errors.Cause(err).Error(),
+	})
+}
+
+func (impl GatewayTrafficSplitServiceImpl) SyncPackageApiTrafficSplit(adapter kong.KongAdapter, apiId string) error {
+	split, err := impl.splitDb.GetByApiId(apiId)
+	if err != nil {
+		return err
+	}
+	if split == nil {
+		return nil
+	}
+	dto := &gw.TrafficSplitDto{}
+	err = json.Unmarshal([]byte(split.Config), dto)
+	if err != nil {
+		return errors.Wrap(err, ERR_JSON_FAIL)
+	}
+	err = impl.render(adapter, split, dto, dto)
+	if err != nil {
+		return err
+	}
+	return impl.splitDb.Update(split)
+}
+
+func (impl GatewayTrafficSplitServiceImpl) ClearPackageApiTrafficSplit(adapter kong.KongAdapter, apiId string) error {
+	split, err := impl.splitDb.GetByApiId(apiId)
+	if err != nil {
+		return err
+	}
+	if split == nil {
+		return nil
+	}
+	return impl.clear(adapter, split)
+}
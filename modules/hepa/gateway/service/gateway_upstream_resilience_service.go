@@ -0,0 +1,487 @@
+// Copyright (c) 2021 Terminus, Inc.
+//
+// This program is free software: you can use, redistribute, and/or modify
+// it under the terms of the GNU Affero General Public License, version 3
+// or later ("AGPL"), as published by the Free Software Foundation.
+//
+// This program is distributed in the hope that it will be useful, but WITHOUT
+// ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+// FITNESS FOR A PARTICULAR PURPOSE.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package service
+
+import (
+	"encoding/json"
+	"net"
+	"strconv"
+
+	"github.com/erda-project/erda/modules/hepa/common"
+	. "github.com/erda-project/erda/modules/hepa/common/vars"
+	gw "github.com/erda-project/erda/modules/hepa/gateway/dto"
+	"github.com/erda-project/erda/modules/hepa/kong"
+	kongDto "github.com/erda-project/erda/modules/hepa/kong/dto"
+	"github.com/erda-project/erda/modules/hepa/repository/orm"
+	db "github.com/erda-project/erda/modules/hepa/repository/service"
+
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+type GatewayUpstreamResilienceServiceImpl struct {
+	packageDb    db.GatewayPackageService
+	packageApiDb db.GatewayPackageApiService
+	serviceDb    db.GatewayServiceService
+	kongDb       db.GatewayKongInfoService
+	splitDb      db.GatewayTrafficSplitService
+	resilienceDb db.GatewayUpstreamResilienceService
+}
+
+func NewGatewayUpstreamResilienceServiceImpl() (*GatewayUpstreamResilienceServiceImpl, error) {
+	packageDb, _ := db.NewGatewayPackageServiceImpl()
+	packageApiDb, _ := db.NewGatewayPackageApiServiceImpl()
+	serviceDb, _ := db.NewGatewayServiceServiceImpl()
+	kongDb, _ := db.NewGatewayKongInfoServiceImpl()
+	splitDb, _ := db.NewGatewayTrafficSplitServiceImpl()
+	resilienceDb, _ := db.NewGatewayUpstreamResilienceServiceImpl()
+	return &GatewayUpstreamResilienceServiceImpl{
+		packageDb:    packageDb,
+		packageApiDb: packageApiDb,
+		serviceDb:    serviceDb,
+		kongDb:       kongDb,
+		splitDb:      splitDb,
+		resilienceDb: resilienceDb,
+	}, nil
+}
+
+func resilienceUpstreamName(apiId string) string {
+	return "resilience-" + apiId
+}
+
+// resilienceUpstream is the kong upstream which the service of api forwards to
+type resilienceUpstream struct {
+	Id       string
+	Name     string
+	Protocol string
+	// shared with traffic split of api
+	Shared bool
+}
+
+func (impl GatewayUpstreamResilienceServiceImpl) kongAdapter(packageId string) (kong.KongAdapter, error) {
+	pack, err := impl.packageDb.Get(packageId)
+	if err != nil {
+		return nil, err
+	}
+	if pack == nil {
+		return nil, errors.New("package not exist")
+	}
+	kongInfo, err := impl.kongDb.GetKongInfo(&orm.GatewayKongInfo{
+		Az:        pack.DiceClusterName,
+		ProjectId: pack.DiceProjectId,
+		Env:       pack.DiceEnv,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return kong.NewKongAdapter(kongInfo.KongAddr), nil
+}
+
+func (impl GatewayUpstreamResilienceServiceImpl) getPackageApi(packageId, apiId string) (*orm.GatewayPackageApi, error) {
+	api, err := impl.packageApiDb.Get(apiId)
+	if err != nil {
+		return nil, err
+	}
+	if api == nil || api.PackageId != packageId {
+		return nil, nil
+	}
+	return api, nil
+}
+
+// splitUpstream returns the upstream of traffic split, nil if api has no traffic split
+func (impl GatewayUpstreamResilienceServiceImpl) splitUpstream(apiId string) (*resilienceUpstream, error) {
+	split, err := impl.splitDb.GetByApiId(apiId)
+	if err != nil {
+		return nil, err
+	}
+	if split == nil || split.KongUpstreamId == "" {
+		return nil, nil
+	}
+	dto := &gw.TrafficSplitDto{}
+	err = json.Unmarshal([]byte(split.Config), dto)
+	if err != nil {
+		return nil, errors.Wrap(err, ERR_JSON_FAIL)
+	}
+	if len(dto.Backends) == 0 {
+		return nil, errors.Errorf("backends of traffic split of api:%s are empty", apiId)
+	}
+	return &resilienceUpstream{
+		Id:       split.KongUpstreamId,
+		Name:     trafficSplitUpstreamName(apiId),
+		Protocol: dto.Backends[0].Scheme(),
+		Shared:   true,
+	}, nil
+}
+
+func serviceTarget(service *orm.GatewayService) string {
+	port := service.Port
+	if port == "" {
+		port = strconv.Itoa(defaultPort(service.Protocol))
+	}
+	return net.JoinHostPort(service.Host, port)
+}
+
+// syncTarget keeps the redirect address of api as the only target of upstream
+func syncTarget(adapter kong.KongAdapter, upstreamId, target string) error {
+	status, err := adapter.GetUpstreamStatus(upstreamId)
+	if err != nil {
+		return err
+	}
+	exist := false
+	for _, item := range status.Data {
+		if item.Target == target {
+			exist = true
+			continue
+		}
+		err = adapter.DeleteUpstreamTarget(upstreamId, item.Target)
+		if err != nil {
+			return err
+		}
+	}
+	if exist {
+		return nil
+	}
+	_, err = adapter.AddUpstreamTarget(upstreamId, &kongDto.KongTargetDto{Target: target})
+	return err
+}
+
+// render applies healthchecks to the upstream of api, and retries and timeouts to the service
+// of api, the upstream of traffic split is shared if exists, otherwise api gets its own upstream
+// with the redirect address as target
+func (impl GatewayUpstreamResilienceServiceImpl) render(adapter kong.KongAdapter, record *orm.GatewayUpstreamResilience, dto *gw.UpstreamResilienceDto) error {
+	service, err := impl.serviceDb.GetByApiId(record.ApiId)
+	if err != nil {
+		return err
+	}
+	if service == nil {
+		return errors.Errorf("service of api:%s not exist", record.ApiId)
+	}
+	upstream, err := impl.splitUpstream(record.ApiId)
+	if err != nil {
+		return err
+	}
+	if upstream == nil {
+		upstream = &resilienceUpstream{
+			Id:       record.KongUpstreamId,
+			Name:     resilienceUpstreamName(record.ApiId),
+			Protocol: service.Protocol,
+		}
+		if upstream.Id == "" {
+			resp, err := adapter.CreateUpstream(&kongDto.KongUpstreamDto{
+				Name:         upstream.Name,
+				Healthchecks: dto.Healthchecks(),
+			})
+			if err != nil {
+				return err
+			}
+			upstream.Id = resp.Id
+			record.KongUpstreamId = resp.Id
+		}
+		err = syncTarget(adapter, upstream.Id, serviceTarget(service))
+		if err != nil {
+			return err
+		}
+	}
+	_, err = adapter.UpdateUpstream(&kongDto.KongUpstreamDto{
+		Id:           upstream.Id,
+		Name:         upstream.Name,
+		Healthchecks: dto.Healthchecks(),
+	})
+	if err != nil {
+		return err
+	}
+	req := trafficSplitServiceReq(service, upstream.Protocol, upstream.Name, defaultPort(upstream.Protocol))
+	req.ServiceId = service.ServiceId
+	dto.ApplyService(req)
+	_, err = adapter.CreateOrUpdateService(req)
+	if err != nil {
+		return err
+	}
+	if upstream.Shared && record.KongUpstreamId != "" {
+		// the service of api doesn't forward to its own upstream any more
+		err = adapter.DeleteUpstream(record.KongUpstreamId)
+		if err != nil {
+			return err
+		}
+		record.KongUpstreamId = ""
+	}
+	return nil
+}
+
+// restore turns healthchecks off and forwards api with default retries and timeouts again
+func (impl GatewayUpstreamResilienceServiceImpl) restore(adapter kong.KongAdapter, record *orm.GatewayUpstreamResilience) error {
+	service, err := impl.serviceDb.GetByApiId(record.ApiId)
+	if err != nil {
+		return err
+	}
+	upstream, err := impl.splitUpstream(record.ApiId)
+	if err != nil {
+		return err
+	}
+	if service != nil {
+		var req *kongDto.KongServiceReqDto
+		if upstream != nil {
+			_, err = adapter.UpdateUpstream(&kongDto.KongUpstreamDto{
+				Id:   upstream.Id,
+				Name: upstream.Name,
+			})
+			if err != nil {
+				return err
+			}
+			req = trafficSplitServiceReq(service, upstream.Protocol, upstream.Name, defaultPort(upstream.Protocol))
+		} else {
+			port, _ := strconv.Atoi(service.Port)
+			req = trafficSplitServiceReq(service, service.Protocol, service.Host, port)
+		}
+		req.ServiceId = service.ServiceId
+		_, err = adapter.CreateOrUpdateService(req)
+		if err != nil {
+			return err
+		}
+	}
+	return impl.clear(adapter, record)
+}
+
+// clear deletes the own upstream of api and the record, the service of api is not restored
+func (impl GatewayUpstreamResilienceServiceImpl) clear(adapter kong.KongAdapter, record *orm.GatewayUpstreamResilience) error {
+	if record.KongUpstreamId != "" {
+		err := adapter.DeleteUpstream(record.KongUpstreamId)
+		if err != nil {
+			return err
+		}
+	}
+	return impl.resilienceDb.DeleteById(record.Id)
+}
+
+func (impl GatewayUpstreamResilienceServiceImpl) GetPackageApiResilience(packageId, apiId string) *common.StandardResult {
+	res := &common.StandardResult{Success: false}
+	if packageId == "" || apiId == "" {
+		return res.SetReturnCode(PARAMS_IS_NULL)
+	}
+	var record *orm.GatewayUpstreamResilience
+	dto := &gw.UpstreamResilienceDto{}
+	api, err := impl.getPackageApi(packageId, apiId)
+	if err != nil {
+		goto failed
+	}
+	if api == nil {
+		return res.SetReturnCode(API_NOT_EXIST)
+	}
+	record, err = impl.resilienceDb.GetByApiId(apiId)
+	if err != nil {
+		goto failed
+	}
+	if record == nil {
+		return res.SetSuccessAndData(nil)
+	}
+	err = json.Unmarshal([]byte(record.Config), dto)
+	if err != nil {
+		goto failed
+	}
+	return res.SetSuccessAndData(dto)
+failed:
+	log.Errorf("error happened, err:%+v", err)
+	return res.SetErrorInfo(&common.ErrInfo{
+		Msg: errors.Cause(err).Error(),
+	})
+}
+
+func (impl GatewayUpstreamResilienceServiceImpl) SetPackageApiResilience(packageId, apiId string, dto *gw.UpstreamResilienceDto) *common.StandardResult {
+	res := &common.StandardResult{Success: false}
+	if packageId == "" || apiId == "" || dto == nil {
+		return res.SetReturnCode(PARAMS_IS_NULL)
+	}
+	var api *orm.GatewayPackageApi
+	var adapter kong.KongAdapter
+	var record *orm.GatewayUpstreamResilience
+	var config []byte
+	err := dto.CheckValid()
+	if err != nil {
+		log.Errorf("invalid resilience, err:%+v", err)
+		return res.SetErrorInfo(&common.ErrInfo{
+			Code: INVALID_RESILIENCE.GetCode(),
+			Msg:  INVALID_RESILIENCE.GetMessage() + ": " + err.Error(),
+		})
+	}
+	api, err = impl.getPackageApi(packageId, apiId)
+	if err != nil {
+		goto failed
+	}
+	if api == nil {
+		return res.SetReturnCode(API_NOT_EXIST)
+	}
+	if api.RedirectType != gw.RT_URL {
+		return res.SetReturnCode(RESILIENCE_NOT_SUPPORT)
+	}
+	adapter, err = impl.kongAdapter(packageId)
+	if err != nil {
+		goto failed
+	}
+	record, err = impl.resilienceDb.GetByApiId(apiId)
+	if err != nil {
+		goto failed
+	}
+	if record == nil {
+		record = &orm.GatewayUpstreamResilience{
+			PackageId: packageId,
+			ApiId:     apiId,
+		}
+	}
+	err = impl.render(adapter, record, dto)
+	if err != nil {
+		goto failed
+	}
+	config, err = json.Marshal(dto)
+	if err != nil {
+		goto failed
+	}
+	record.Config = string(config)
+	if record.Id == "" {
+		err = impl.resilienceDb.Insert(record)
+	} else {
+		err = impl.resilienceDb.Update(record)
+	}
+	if err != nil {
+		goto failed
+	}
+	return res.SetSuccessAndData(dto)
+failed:
+	log.Errorf("error happened, err:%+v", err)
+	return res.SetErrorInfo(&common.ErrInfo{
+		Msg: errors.Cause(err).Error(),
+	})
+}
+
+func (impl GatewayUpstreamResilienceServiceImpl) DeletePackageApiResilience(packageId, apiId string) *common.StandardResult {
+	res := &common.StandardResult{Success: false}
+	if packageId == "" || apiId == "" {
+		return res.SetReturnCode(PARAMS_IS_NULL)
+	}
+	var adapter kong.KongAdapter
+	var record *orm.GatewayUpstreamResilience
+	api, err := impl.getPackageApi(packageId, apiId)
+	if err != nil {
+		goto failed
+	}
+	if api == nil {
+		return res.SetReturnCode(API_NOT_EXIST)
+	}
+	record, err = impl.resilienceDb.GetByApiId(apiId)
+	if err != nil {
+		goto failed
+	}
+	if record == nil {
+		return res.SetSuccessAndData(true)
+	}
+	adapter, err = impl.kongAdapter(packageId)
+	if err != nil {
+		goto failed
+	}
+	err = impl.restore(adapter, record)
+	if err != nil {
+		goto failed
+	}
+	return res.SetSuccessAndData(true)
+failed:
+	log.Errorf("error happened, err:%+v", err)
+	return res.SetErrorInfo(&common.ErrInfo{
+		Msg: errors.Cause(err).Error(),
+	})
+}
+
+func (impl GatewayUpstreamResilienceServiceImpl) GetPackageApiUpstreamStatus(packageId, apiId string) *common.StandardResult {
+	res := &common.StandardResult{Success: false}
+	if packageId == "" || apiId == "" {
+		return res.SetReturnCode(PARAMS_IS_NULL)
+	}
+	var record *orm.GatewayUpstreamResilience
+	var upstream *resilienceUpstream
+	var adapter kong.KongAdapter
+	var status *kongDto.KongUpstreamStatusRespDto
+	targets := []gw.UpstreamTargetHealthDto{}
+	api, err := impl.getPackageApi(packageId, apiId)
+	if err != nil {
+		goto failed
+	}
+	if api == nil {
+		return res.SetReturnCode(API_NOT_EXIST)
+	}
+	upstream, err = impl.splitUpstream(apiId)
+	if err != nil {
+		goto failed
+	}
+	if upstream == nil {
+		record, err = impl.resilienceDb.GetByApiId(apiId)
+		if err != nil {
+			goto failed
+		}
+		// api forwards to the redirect address directly
+		if record == nil || record.KongUpstreamId == "" {
+			return res.SetSuccessAndData(targets)
+		}
+		upstream = &resilienceUpstream{Id: record.KongUpstreamId}
+	}
+	adapter, err = impl.kongAdapter(packageId)
+	if err != nil {
+		goto failed
+	}
+	status, err = adapter.GetUpstreamStatus(upstream.Id)
+	if err != nil {
+		goto failed
+	}
+	for _, target := range status.Data {
+		targets = append(targets, gw.UpstreamTargetHealthDto{
+			Target: target.Target,
+			Weight: target.Weight,
+			Health: target.Health,
+		})
+	}
+	return res.SetSuccessAndData(targets)
+failed:
+	log.Errorf("error happened, err:%+v", err)
+	return res.SetErrorInfo(&common.ErrInfo{
+		Msg: errors.Cause(err).Error(),
+	})
+}
+
+func (impl GatewayUpstreamResilienceServiceImpl) SyncPackageApiResilience(adapter kong.KongAdapter, apiId string) error {
+	record, err := impl.resilienceDb.GetByApiId(apiId)
+	if err != nil {
+		return err
+	}
+	if record == nil {
+		return nil
+	}
+	dto := &gw.UpstreamResilienceDto{}
+	err = json.Unmarshal([]byte(record.Config), dto)
+	if err != nil {
+		return errors.Wrap(err, ERR_JSON_FAIL)
+	}
+	err = impl.render(adapter, record, dto)
+	if err != nil {
+		return err
+	}
+	return impl.resilienceDb.Update(record)
+}
+
+func (impl GatewayUpstreamResilienceServiceImpl) ClearPackageApiResilience(adapter kong.KongAdapter, apiId string) error {
+	record, err := impl.resilienceDb.GetByApiId(apiId)
+	if err != nil {
+		return err
+	}
+	if record == nil {
+		return nil
+	}
+	return impl.clear(adapter, record)
+}
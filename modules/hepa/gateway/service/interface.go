@@ -24,6 +24,7 @@ import (
 	gw "github.com/erda-project/erda/modules/hepa/gateway/dto"
 	"github.com/erda-project/erda/modules/hepa/gateway/exdto"
 	. "github.com/erda-project/erda/modules/hepa/k8s"
+	"github.com/erda-project/erda/modules/hepa/kong"
 	"github.com/erda-project/erda/modules/hepa/repository/orm"
 	db "github.com/erda-project/erda/modules/hepa/repository/service"
 	"github.com/erda-project/erda/pkg/parser/diceyml"
@@ -183,6 +184,7 @@ type GatewayApiPolicyService interface {
 	SetPackageDefaultPolicyConfig(category, packageId string, az *orm.GatewayAzInfo, config []byte, helper ...*db.SessionHelper) (string, error)
 	GetPolicyConfig(category, packageId, packageApiId string) *common.StandardResult
 	SetPolicyConfig(category, packageId, packageApiId string, config []byte) *common.StandardResult
+	PreviewPolicyConfig(category string, config []byte) *common.StandardResult
 	RefreshZoneIngress(zone orm.GatewayZone, az orm.GatewayAzInfo) error
 	SetZonePolicyConfig(zone *orm.GatewayZone, category string, config []byte, helper *db.SessionHelper, needDeployTag ...bool) (apipolicy.PolicyDto, string, error)
 	SetZoneDefaultPolicyConfig(packageId string, zone *orm.GatewayZone, az *orm.GatewayAzInfo, session ...*db.SessionHelper) (map[string]*string, *string, *db.SessionHelper, error)
@@ -237,9 +239,22 @@ type GatewayGlobalService interface {
 	CreateTenant(*gw.TenantDto) *common.StandardResult
 }
 
+type GatewayConsumerQuotaService interface {
+	GetConsumerQuota(consumerId string) *common.StandardResult
+	SetConsumerQuota(consumerId string, quota *gw.ConsumerQuotaDto) *common.StandardResult
+	DeleteConsumerQuota(consumerId string) *common.StandardResult
+	GetClientQuota(clientId string) *common.StandardResult
+	SetClientQuota(clientId string, quota *gw.ConsumerQuotaDto) *common.StandardResult
+	DeleteClientQuota(clientId string) *common.StandardResult
+	InheritClientQuota(consumer *orm.GatewayConsumer) error
+	ExportUsage(args *gw.DiceArgsDto, month string) *common.StandardResult
+}
+
 type GatewayMockService interface {
 	RegisterMockApi(*gw.MockInfoDto) *common.StandardResult
 	CallMockApi(string, string, string) *common.StandardResult
+	RegisterMockSpec(*gw.MockSpecDto) *common.StandardResult
+	CallMockSpec(*gw.CallMockSpecDto) *common.StandardResult
 }
 
 type GatewayUpstreamService interface {
@@ -248,6 +263,39 @@ type GatewayUpstreamService interface {
 	UpstreamRegisterAsync(*gin.Context, *gw.UpstreamRegisterDto) *common.StandardResult
 }
 
+type GatewayTrafficSplitService interface {
+	GetPackageApiTrafficSplit(string, string) *common.StandardResult
+	SetPackageApiTrafficSplit(string, string, *gw.TrafficSplitDto) *common.StandardResult
+	DeletePackageApiTrafficSplit(string, string) *common.StandardResult
+	// render again after kong service or route of api changed
+	SyncPackageApiTrafficSplit(kong.KongAdapter, string) error
+	// recycle kong objects before kong service of api deleted
+	ClearPackageApiTrafficSplit(kong.KongAdapter, string) error
+}
+
+type GatewayUpstreamResilienceService interface {
+	GetPackageApiResilience(string, string) *common.StandardResult
+	SetPackageApiResilience(string, string, *gw.UpstreamResilienceDto) *common.StandardResult
+	DeletePackageApiResilience(string, string) *common.StandardResult
+	// health of targets which api forwards to
+	GetPackageApiUpstreamStatus(string, string) *common.StandardResult
+	// render again after kong service of api or traffic split changed
+	SyncPackageApiResilience(kong.KongAdapter, string) error
+	// recycle kong objects before kong service of api deleted
+	ClearPackageApiResilience(kong.KongAdapter, string) error
+}
+
+type GatewayApiAnalyticsService interface {
+	GetPackageAnalytics(string, *gw.ApiAnalyticsReqDto) *common.StandardResult
+	GetPackageApiAnalytics(string, string, *gw.ApiAnalyticsReqDto) *common.StandardResult
+}
+
+type GatewayConfigService interface {
+	ExportConfig(*gw.DiceArgsDto) *common.StandardResult
+	// dryRun only returns the plan, prune deletes objects absent in config
+	ImportConfig(args *gw.DiceArgsDto, content []byte, dryRun, prune bool) *common.StandardResult
+}
+
 type GatewayUpstreamLbService interface {
 	UpstreamTargetOnline(*gw.UpstreamLbDto) *common.StandardResult
 	UpstreamTargetOffline(*gw.UpstreamLbDto) *common.StandardResult
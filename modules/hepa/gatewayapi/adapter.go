@@ -0,0 +1,283 @@
+// Copyright (c) 2021 Terminus, Inc.
+//
+// This program is free software: you can use, redistribute, and/or modify
+// it under the terms of the GNU Affero General Public License, version 3
+// or later ("AGPL"), as published by the Free Software Foundation.
+//
+// This program is distributed in the hope that it will be useful, but WITHOUT
+// ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+// FITNESS FOR A PARTICULAR PURPOSE.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+// Package gatewayapi is the gateway backend for clusters without kong, it
+// renders hepa's services, routes, upstreams, consumers and plugins into
+// kubernetes gateway api resources, with envoy gateway policy CRDs for plugins.
+//
+// Adapter implements kong.KongAdapter, it's selected by a kong addr like
+// gateway-api://<namespace>/<gateway>?master=<url encoded apiserver addr>,
+// an empty master means the cluster hepa running in.
+package gatewayapi
+
+import (
+	"context"
+	"encoding/json"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/rest"
+
+	. "github.com/erda-project/erda/modules/hepa/common/vars"
+	"github.com/erda-project/erda/pkg/clientgo/restclient"
+)
+
+const (
+	Scheme = "gateway-api://"
+	// Version is returned as the gateway version
+	Version = "gateway.networking.k8s.io/v1"
+)
+
+const (
+	gatewayGroup = "gateway.networking.k8s.io"
+	envoyGroup   = "gateway.envoyproxy.io"
+)
+
+var (
+	httpRouteResource      = schema.GroupVersionResource{Group: gatewayGroup, Version: "v1", Resource: "httproutes"}
+	backendResource        = schema.GroupVersionResource{Group: envoyGroup, Version: "v1alpha1", Resource: "backends"}
+	routeFilterResource    = schema.GroupVersionResource{Group: envoyGroup, Version: "v1alpha1", Resource: "httproutefilters"}
+	securityPolicyResource = schema.GroupVersionResource{Group: envoyGroup, Version: "v1alpha1", Resource: "securitypolicies"}
+	trafficPolicyResource  = schema.GroupVersionResource{Group: envoyGroup, Version: "v1alpha1", Resource: "backendtrafficpolicies"}
+	secretResource         = schema.GroupVersionResource{Version: "v1", Resource: "secrets"}
+	configMapResource      = schema.GroupVersionResource{Version: "v1", Resource: "configmaps"}
+	resourceKinds          = map[schema.GroupVersionResource]string{
+		httpRouteResource:      "HTTPRoute",
+		backendResource:        "Backend",
+		routeFilterResource:    "HTTPRouteFilter",
+		securityPolicyResource: "SecurityPolicy",
+		trafficPolicyResource:  "BackendTrafficPolicy",
+		secretResource:         "Secret",
+		configMapResource:      "ConfigMap",
+	}
+)
+
+// labels and annotations of the rendered resources
+const (
+	LabelManagedBy    = "app.kubernetes.io/managed-by"
+	LabelServiceId    = "hepa/service-id"
+	LabelRouteId      = "hepa/route-id"
+	LabelUpstreamId   = "hepa/upstream-id"
+	LabelConsumerId   = "hepa/consumer-id"
+	LabelPluginId     = "hepa/plugin-id"
+	LabelPluginName   = "hepa/plugin-name"
+	AnnotationRecord  = "hepa/record"
+	AnnotationGroups  = "hepa/acl-groups"
+	managedByHepa     = "hepa"
+	recordDataKey     = "record"
+	gatewayPolicyName = "hepa-gateway"
+)
+
+var (
+	ErrInvalidReq         = errors.New("gatewayapi: invalid request")
+	ErrPluginNotSupported = errors.New("gatewayapi: plugin not supported")
+)
+
+type Adapter struct {
+	Client dynamic.Interface
+	// Namespace of the gateway and all rendered resources
+	Namespace string
+	// Gateway the routes attached to
+	Gateway string
+}
+
+// IsGatewayApiAddr returns whether addr is served by the gateway api backend
+func IsGatewayApiAddr(addr string) bool {
+	return strings.HasPrefix(addr, Scheme)
+}
+
+// NewAdapter creates the adapter by addr like gateway-api://<namespace>/<gateway>?master=<addr>
+func NewAdapter(addr string) (*Adapter, error) {
+	u, err := url.Parse(addr)
+	if err != nil || !IsGatewayApiAddr(addr) {
+		return nil, errors.Errorf("invalid gateway api addr:%s", addr)
+	}
+	namespace, gateway := u.Host, strings.Trim(u.Path, "/")
+	if namespace == "" || gateway == "" {
+		return nil, errors.Errorf("namespace or gateway missing, addr:%s", addr)
+	}
+	var config *rest.Config
+	if master := u.Query().Get("master"); master != "" {
+		config, err = restclient.NewInetConfig(master, restclient.GetDefaultConfig(""))
+	} else {
+		config, err = rest.InClusterConfig()
+	}
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	client, err := dynamic.NewForConfig(config)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return &Adapter{
+		Client:    client,
+		Namespace: namespace,
+		Gateway:   gateway,
+	}, nil
+}
+
+func (impl *Adapter) KongExist() bool {
+	return impl != nil
+}
+
+// IngressPolicyEnforced is false since the gateway is exposed directly,
+// api policies are rendered into plugins instead
+func (impl *Adapter) IngressPolicyEnforced() bool {
+	return false
+}
+
+func (impl *Adapter) GetVersion() (string, error) {
+	if impl == nil {
+		return "", errors.New("gateway can't be attached")
+	}
+	return Version, nil
+}
+
+func (impl *Adapter) resource(gvr schema.GroupVersionResource) dynamic.ResourceInterface {
+	return impl.Client.Resource(gvr).Namespace(impl.Namespace)
+}
+
+func (impl *Adapter) newObject(gvr schema.GroupVersionResource, name string, objLabels map[string]string) *unstructured.Unstructured {
+	obj := &unstructured.Unstructured{}
+	obj.SetAPIVersion(gvr.GroupVersion().String())
+	obj.SetKind(resourceKinds[gvr])
+	obj.SetNamespace(impl.Namespace)
+	obj.SetName(name)
+	all := map[string]string{LabelManagedBy: managedByHepa}
+	for key, value := range objLabels {
+		if value != "" {
+			all[key] = value
+		}
+	}
+	obj.SetLabels(all)
+	return obj
+}
+
+func (impl *Adapter) get(gvr schema.GroupVersionResource, name string) (*unstructured.Unstructured, error) {
+	obj, err := impl.resource(gvr).Get(context.Background(), name, metav1.GetOptions{})
+	if k8serrors.IsNotFound(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "get %s %s failed", resourceKinds[gvr], name)
+	}
+	return obj, nil
+}
+
+// apply creates obj, or replaces it if exists
+func (impl *Adapter) apply(gvr schema.GroupVersionResource, obj *unstructured.Unstructured) error {
+	exist, err := impl.get(gvr, obj.GetName())
+	if err != nil {
+		return err
+	}
+	if exist == nil {
+		_, err = impl.resource(gvr).Create(context.Background(), obj, metav1.CreateOptions{})
+		return errors.Wrapf(err, "create %s %s failed", obj.GetKind(), obj.GetName())
+	}
+	obj.SetResourceVersion(exist.GetResourceVersion())
+	_, err = impl.resource(gvr).Update(context.Background(), obj, metav1.UpdateOptions{})
+	return errors.Wrapf(err, "update %s %s failed", obj.GetKind(), obj.GetName())
+}
+
+func (impl *Adapter) remove(gvr schema.GroupVersionResource, name string) error {
+	err := impl.resource(gvr).Delete(context.Background(), name, metav1.DeleteOptions{})
+	if err != nil && !k8serrors.IsNotFound(err) {
+		return errors.Wrapf(err, "delete %s %s failed", resourceKinds[gvr], name)
+	}
+	return nil
+}
+
+func (impl *Adapter) list(gvr schema.GroupVersionResource, selector map[string]string) ([]unstructured.Unstructured, error) {
+	all := labels.Set{LabelManagedBy: managedByHepa}
+	for key, value := range selector {
+		all[key] = value
+	}
+	list, err := impl.resource(gvr).List(context.Background(), metav1.ListOptions{
+		LabelSelector: all.String(),
+	})
+	if err != nil {
+		return nil, errors.Wrapf(err, "list %s failed", resourceKinds[gvr])
+	}
+	return list.Items, nil
+}
+
+// setRecord keeps the kong view of the resource, so that it can be returned as kong does
+func setRecord(obj *unstructured.Unstructured, record interface{}) error {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return errors.Wrap(err, ERR_JSON_FAIL)
+	}
+	annotations := obj.GetAnnotations()
+	if annotations == nil {
+		annotations = map[string]string{}
+	}
+	annotations[AnnotationRecord] = string(data)
+	obj.SetAnnotations(annotations)
+	return nil
+}
+
+func getRecord(obj *unstructured.Unstructured, record interface{}) error {
+	data := obj.GetAnnotations()[AnnotationRecord]
+	if data == "" {
+		return errors.Errorf("record of %s %s missing", obj.GetKind(), obj.GetName())
+	}
+	return errors.Wrapf(json.Unmarshal([]byte(data), record), "invalid record of %s %s", obj.GetKind(), obj.GetName())
+}
+
+func newId() string {
+	return uuid.New().String()
+}
+
+func now() int64 {
+	return time.Now().Unix()
+}
+
+func objectRef(gvr schema.GroupVersionResource, name string) map[string]interface{} {
+	return map[string]interface{}{
+		"group": gvr.Group,
+		"kind":  resourceKinds[gvr],
+		"name":  name,
+	}
+}
+
+// unstructuredValue converts v to json values, which unstructured objects require
+func unstructuredValue(v interface{}) (interface{}, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, errors.Wrap(err, ERR_JSON_FAIL)
+	}
+	var res interface{}
+	err = json.Unmarshal(data, &res)
+	if err != nil {
+		return nil, errors.Wrap(err, ERR_JSON_FAIL)
+	}
+	return res, nil
+}
+
+func setSpec(obj *unstructured.Unstructured, spec interface{}) error {
+	value, err := unstructuredValue(spec)
+	if err != nil {
+		return err
+	}
+	obj.Object["spec"] = value
+	return nil
+}
@@ -0,0 +1,625 @@
+// Copyright (c) 2021 Terminus, Inc.
+//
+// This program is free software: you can use, redistribute, and/or modify
+// it under the terms of the GNU Affero General Public License, version 3
+// or later ("AGPL"), as published by the Free Software Foundation.
+//
+// This program is distributed in the hope that it will be useful, but WITHOUT
+// ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+// FITNESS FOR A PARTICULAR PURPOSE.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package gatewayapi
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic/fake"
+
+	. "github.com/erda-project/erda/modules/hepa/kong/dto"
+)
+
+func newTestAdapter() *Adapter {
+	return &Adapter{
+		Client:    fake.NewSimpleDynamicClient(runtime.NewScheme()),
+		Namespace: "project-1-dev",
+		Gateway:   "hepa",
+	}
+}
+
+func mustGet(t *testing.T, impl *Adapter, gvr schema.GroupVersionResource, name string) *unstructured.Unstructured {
+	obj, err := impl.get(gvr, name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if obj == nil {
+		t.Fatalf("%s %s not found", resourceKinds[gvr], name)
+	}
+	return obj
+}
+
+func mustNotExist(t *testing.T, impl *Adapter, gvr schema.GroupVersionResource, name string) {
+	obj, err := impl.get(gvr, name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if obj != nil {
+		t.Fatalf("%s %s should be deleted", resourceKinds[gvr], name)
+	}
+}
+
+func nested(t *testing.T, obj *unstructured.Unstructured, fields ...interface{}) interface{} {
+	var value interface{} = obj.Object
+	for _, field := range fields {
+		switch f := field.(type) {
+		case string:
+			m, ok := value.(map[string]interface{})
+			if !ok {
+				t.Fatalf("%v of %s is not a map", fields, obj.GetName())
+			}
+			value = m[f]
+		case int:
+			l, ok := value.([]interface{})
+			if !ok || len(l) <= f {
+				t.Fatalf("%v of %s is not a list with %d items", fields, obj.GetName(), f+1)
+			}
+			value = l[f]
+		}
+	}
+	return value
+}
+
+func TestNewAdapter(t *testing.T) {
+	impl, err := NewAdapter("gateway-api://project-1-dev/hepa?master=https%3A%2F%2F127.0.0.1%3A6443")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if impl.Namespace != "project-1-dev" || impl.Gateway != "hepa" {
+		t.Errorf("NewAdapter() = %s/%s", impl.Namespace, impl.Gateway)
+	}
+	for _, addr := range []string{"http://kong:8001", "gateway-api://project-1-dev", "gateway-api:///hepa"} {
+		if _, err := NewAdapter(addr); err == nil {
+			t.Errorf("NewAdapter(%s) should fail", addr)
+		}
+	}
+}
+
+func TestRoute(t *testing.T) {
+	impl := newTestAdapter()
+	service, err := impl.CreateOrUpdateService(&KongServiceReqDto{
+		Url:         "http://user-center.project-1-dev.svc.cluster.local:8080/api",
+		ReadTimeout: 5000,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if service.Port != 8080 || service.Path != "/api" || service.Protocol != "http" {
+		t.Errorf("CreateOrUpdateService() = %+v", service)
+	}
+	backend := mustGet(t, impl, backendResource, serviceName(service.Id))
+	if got := nested(t, backend, "spec", "endpoints", 0, "fqdn", "hostname"); got != "user-center.project-1-dev.svc.cluster.local" {
+		t.Errorf("backend hostname = %v", got)
+	}
+
+	stripPath := true
+	route, err := impl.CreateOrUpdateRoute(&KongRouteReqDto{
+		Methods:   []string{"GET", "POST"},
+		Hosts:     []string{"api.example.com"},
+		Paths:     []string{"/user"},
+		StripPath: &stripPath,
+		Service:   &Service{Id: service.Id},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	obj := mustGet(t, impl, httpRouteResource, routeName(route.Id))
+	if got := nested(t, obj, "spec", "parentRefs", 0, "name"); got != "hepa" {
+		t.Errorf("parentRef = %v", got)
+	}
+	if got := nested(t, obj, "spec", "hostnames"); !reflect.DeepEqual(got, []interface{}{"api.example.com"}) {
+		t.Errorf("hostnames = %v", got)
+	}
+	if got := nested(t, obj, "spec", "rules", 0, "matches"); len(got.([]interface{})) != 2 {
+		t.Errorf("matches = %v", got)
+	}
+	if got := nested(t, obj, "spec", "rules", 0, "matches", 1, "path", "value"); got != "/user" {
+		t.Errorf("match path = %v", got)
+	}
+	rewrite := nested(t, obj, "spec", "rules", 0, "filters", 0, "urlRewrite")
+	want := map[string]interface{}{
+		"hostname": "user-center.project-1-dev.svc.cluster.local",
+		"path":     map[string]interface{}{"type": "ReplacePrefixMatch", "replacePrefixMatch": "/api"},
+	}
+	if !reflect.DeepEqual(rewrite, want) {
+		t.Errorf("urlRewrite = %v, want %v", rewrite, want)
+	}
+	if got := nested(t, obj, "spec", "rules", 0, "backendRefs", 0, "name"); got != serviceName(service.Id) {
+		t.Errorf("backendRef = %v", got)
+	}
+	if got := nested(t, obj, "spec", "rules", 0, "timeouts", "backendRequest"); got != "5000ms" {
+		t.Errorf("timeout = %v", got)
+	}
+
+	// route follows the path of service
+	_, err = impl.CreateOrUpdateService(&KongServiceReqDto{
+		Url:       "http://user-center.project-1-dev.svc.cluster.local:8080",
+		ServiceId: service.Id,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	obj = mustGet(t, impl, httpRouteResource, routeName(route.Id))
+	if got := nested(t, obj, "spec", "rules", 0, "filters", 0, "urlRewrite", "path", "replacePrefixMatch"); got != "/" {
+		t.Errorf("replacePrefixMatch = %v", got)
+	}
+
+	// regex path is rewritten by HTTPRouteFilter
+	route, err = impl.UpdateRoute(&KongRouteReqDto{
+		RouteId: route.Id,
+		Methods: []string{"GET"},
+		Paths:   []string{"/user/(?<id>[^/]+)"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(route.Hosts, []string{"api.example.com"}) {
+		t.Errorf("UpdateRoute() hosts = %v", route.Hosts)
+	}
+	obj = mustGet(t, impl, httpRouteResource, routeName(route.Id))
+	if got := nested(t, obj, "spec", "rules", 0, "matches", 0, "path"); !reflect.DeepEqual(got, map[string]interface{}{
+		"type": "RegularExpression", "value": "/user/(?<id>[^/]+).*"}) {
+		t.Errorf("match path = %v", got)
+	}
+	if got := nested(t, obj, "spec", "rules", 0, "filters", 0, "extensionRef", "name"); got != routeFilterName(route.Id, 0) {
+		t.Errorf("extensionRef = %v", got)
+	}
+	filter := mustGet(t, impl, routeFilterResource, routeFilterName(route.Id, 0))
+	if got := nested(t, filter, "spec", "urlRewrite", "path", "replaceRegexMatch", "pattern"); got != "^/user/(?<id>[^/]+)" {
+		t.Errorf("filter pattern = %v", got)
+	}
+
+	err = impl.TouchRouteOAuthMethod(route.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	routes, err := impl.GetRoutes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(routes) != 2 {
+		t.Errorf("GetRoutes() = %d routes, want 2", len(routes))
+	}
+
+	err = impl.DeleteRoute(route.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mustNotExist(t, impl, httpRouteResource, routeName(route.Id))
+	mustNotExist(t, impl, routeFilterResource, routeFilterName(route.Id, 0))
+
+	_, err = impl.CreateOrUpdateRoute(&KongRouteReqDto{Service: &Service{Id: service.Id}})
+	if err != ErrInvalidReq {
+		t.Errorf("CreateOrUpdateRoute() err = %v, want %v", err, ErrInvalidReq)
+	}
+}
+
+func TestUpstream(t *testing.T) {
+	impl := newTestAdapter()
+	upstream, err := impl.CreateUpstream(&KongUpstreamDto{
+		Name:         "user-center.upstream",
+		Healthchecks: NewHealthchecks("/health"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = impl.CreateUpstream(&KongUpstreamDto{Name: "user-center.upstream"}); err == nil {
+		t.Error("CreateUpstream() with same name should fail")
+	}
+	for _, target := range []string{"10.0.0.1:8080", "10.0.0.2:8080", "10.0.0.1:8080"} {
+		resp, err := impl.AddUpstreamTarget(upstream.Id, &KongTargetDto{Target: target})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.CreatedAt == 0 || resp.Weight != 100 {
+			t.Errorf("AddUpstreamTarget() = %+v", resp)
+		}
+	}
+	status, err := impl.GetUpstreamStatus(upstream.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(status.Data) != 2 {
+		t.Errorf("GetUpstreamStatus() = %+v", status)
+	}
+	backend := mustGet(t, impl, backendResource, upstreamName(upstream.Id))
+	if got := nested(t, backend, "spec", "endpoints", 1, "ip", "address"); got != "10.0.0.2" {
+		t.Errorf("endpoint = %v", got)
+	}
+
+	// service using upstream as host forwards to the upstream backend
+	service, err := impl.CreateOrUpdateService(&KongServiceReqDto{Host: "user-center.upstream", Port: 80})
+	if err != nil {
+		t.Fatal(err)
+	}
+	route, err := impl.CreateOrUpdateRoute(&KongRouteReqDto{
+		Paths:   []string{"/"},
+		Service: &Service{Id: service.Id},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	obj := mustGet(t, impl, httpRouteResource, routeName(route.Id))
+	if got := nested(t, obj, "spec", "rules", 0, "backendRefs", 0, "name"); got != upstreamName(upstream.Id) {
+		t.Errorf("backendRef = %v", got)
+	}
+
+	err = impl.DeleteUpstreamTarget(upstream.Id, "10.0.0.1:8080")
+	if err != nil {
+		t.Fatal(err)
+	}
+	backend = mustGet(t, impl, backendResource, upstreamName(upstream.Id))
+	if got := nested(t, backend, "spec", "endpoints"); len(got.([]interface{})) != 1 {
+		t.Errorf("endpoints = %v", got)
+	}
+
+	// targets with different weights are rendered as weighted backendRefs
+	_, err = impl.AddUpstreamTarget(upstream.Id, &KongTargetDto{Target: "10.0.0.3:8080", Weight: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	obj = mustGet(t, impl, httpRouteResource, routeName(route.Id))
+	refs := nested(t, obj, "spec", "rules", 0, "backendRefs").([]interface{})
+	if len(refs) != 2 {
+		t.Fatalf("backendRefs = %v", refs)
+	}
+	if got := nested(t, obj, "spec", "rules", 0, "backendRefs", 0, "weight"); got != float64(10) {
+		t.Errorf("backendRef weight = %v", got)
+	}
+	mustGet(t, impl, backendResource, upstreamTargetName(upstream.Id, 1))
+
+	// healthchecks are kept, targets stay untouched
+	updated, err := impl.UpdateUpstream(&KongUpstreamDto{Id: upstream.Id, Healthchecks: NewHealthchecks("/ping")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if updated.Name != "user-center.upstream" || updated.Healthchecks.Active.HttpPath != "/ping" {
+		t.Errorf("UpdateUpstream() = %+v", updated)
+	}
+	status, err = impl.GetUpstreamStatus(upstream.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(status.Data) != 2 {
+		t.Errorf("GetUpstreamStatus() = %+v", status)
+	}
+	if _, err = impl.UpdateUpstream(&KongUpstreamDto{Id: "not-exist"}); err == nil {
+		t.Error("UpdateUpstream() of unknown upstream should fail")
+	}
+
+	err = impl.DeleteUpstream(upstream.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mustNotExist(t, impl, backendResource, upstreamName(upstream.Id))
+	mustNotExist(t, impl, backendResource, upstreamTargetName(upstream.Id, 1))
+	obj = mustGet(t, impl, httpRouteResource, routeName(route.Id))
+	if got := nested(t, obj, "spec", "rules", 0, "backendRefs", 0, "name"); got != serviceName(service.Id) {
+		t.Errorf("backendRef = %v", got)
+	}
+}
+
+func TestGrpcRoute(t *testing.T) {
+	impl := newTestAdapter()
+	service, err := impl.CreateOrUpdateService(&KongServiceReqDto{Url: "grpc://greeter.project-1-dev.svc.cluster.local"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if service.Port != 80 || service.Protocol != "grpc" {
+		t.Errorf("CreateOrUpdateService() = %+v", service)
+	}
+	backend := mustGet(t, impl, backendResource, serviceName(service.Id))
+	if got := nested(t, backend, "spec", "appProtocols", 0); got != "gateway.envoyproxy.io/h2c" {
+		t.Errorf("appProtocols = %v", got)
+	}
+
+	// method paths of grpc are forwarded as is
+	stripPath := false
+	route, err := impl.CreateOrUpdateRoute(&KongRouteReqDto{
+		Protocols: []string{"http", "https"},
+		Paths:     []string{"/helloworld.Greeter/SayHello"},
+		StripPath: &stripPath,
+		Service:   &Service{Id: service.Id},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	obj := mustGet(t, impl, httpRouteResource, routeName(route.Id))
+	if got := nested(t, obj, "spec", "rules", 0, "matches", 0, "path", "value"); got != "/helloworld.Greeter/SayHello" {
+		t.Errorf("path match = %v", got)
+	}
+	if got := nested(t, obj, "spec", "rules", 0, "filters", 0, "urlRewrite"); !reflect.DeepEqual(got, map[string]interface{}{
+		"hostname": "greeter.project-1-dev.svc.cluster.local",
+	}) {
+		t.Errorf("urlRewrite = %v", got)
+	}
+
+	// envoy gateway translates grpc-web itself
+	enabled, err := impl.CheckPluginEnabled("grpc-web")
+	if err != nil || !enabled {
+		t.Fatalf("CheckPluginEnabled() = %v, %v", enabled, err)
+	}
+	_, err = impl.CreateOrUpdatePlugin(&KongPluginReqDto{Name: "grpc-web", RouteId: route.Id})
+	if err != nil {
+		t.Fatal(err)
+	}
+	mustNotExist(t, impl, securityPolicyResource, routeName(route.Id))
+	mustNotExist(t, impl, trafficPolicyResource, routeName(route.Id))
+}
+
+func TestHeaderMatches(t *testing.T) {
+	got := headerMatches(map[string][]string{
+		"x-canary": {"true"},
+		"cookie":   {"~*(^|;\\s*)canary=true(;|$)"},
+		"x-env":    {"dev", "test.1"},
+	})
+	want := []interface{}{
+		map[string]interface{}{"type": "RegularExpression", "name": "cookie", "value": "(^|;\\s*)canary=true(;|$)"},
+		map[string]interface{}{"type": "Exact", "name": "x-canary", "value": "true"},
+		map[string]interface{}{"type": "RegularExpression", "name": "x-env", "value": "(^dev$)|(^test\\.1$)"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("headerMatches() = %v, want %v", got, want)
+	}
+}
+
+func TestPlugin(t *testing.T) {
+	impl := newTestAdapter()
+	service, err := impl.CreateOrUpdateService(&KongServiceReqDto{Host: "user-center", Port: 8080})
+	if err != nil {
+		t.Fatal(err)
+	}
+	route, err := impl.CreateOrUpdateRoute(&KongRouteReqDto{
+		Paths:   []string{"/user"},
+		Service: &Service{Id: service.Id},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cors, err := impl.CreateOrUpdatePlugin(&KongPluginReqDto{
+		Name:   "cors",
+		Config: map[string]interface{}{"origins": "*", "methods": []string{"GET"}, "max_age": 3600},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	policy := mustGet(t, impl, securityPolicyResource, gatewayPolicyName)
+	if got := nested(t, policy, "spec", "targetRefs", 0, "kind"); got != "Gateway" {
+		t.Errorf("targetRef kind = %v", got)
+	}
+	if got := nested(t, policy, "spec", "cors", "maxAge"); got != "3600s" {
+		t.Errorf("cors maxAge = %v", got)
+	}
+	// only global plugins, the gateway policy takes effect
+	mustNotExist(t, impl, securityPolicyResource, routeName(route.Id))
+
+	ip, err := impl.CreateOrUpdatePlugin(&KongPluginReqDto{
+		Name:    "ip-restriction",
+		RouteId: route.Id,
+		Config:  map[string]interface{}{"whitelist": []string{"10.0.0.0/8", "192.168.1.1"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	policy = mustGet(t, impl, securityPolicyResource, routeName(route.Id))
+	if got := nested(t, policy, "spec", "targetRefs", 0, "name"); got != routeName(route.Id) {
+		t.Errorf("targetRef = %v", got)
+	}
+	if got := nested(t, policy, "spec", "authorization", "defaultAction"); got != "Deny" {
+		t.Errorf("defaultAction = %v", got)
+	}
+	if got := nested(t, policy, "spec", "authorization", "rules", 0, "principal", "clientCIDRs"); !reflect.DeepEqual(got, []interface{}{"10.0.0.0/8", "192.168.1.1/32"}) {
+		t.Errorf("clientCIDRs = %v", got)
+	}
+	// global plugins are merged into route policy, which overrides the gateway one
+	if got := nested(t, policy, "spec", "cors", "allowMethods"); !reflect.DeepEqual(got, []interface{}{"GET"}) {
+		t.Errorf("cors allowMethods = %v", got)
+	}
+
+	_, err = impl.CreateOrUpdatePlugin(&KongPluginReqDto{
+		Name:      "rate-limiting",
+		ServiceId: service.Id,
+		Config:    map[string]interface{}{"minute": 600, "hour": 10000},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	traffic := mustGet(t, impl, trafficPolicyResource, routeName(route.Id))
+	if got := nested(t, traffic, "spec", "rateLimit", "local", "rules", 0, "limit"); !reflect.DeepEqual(got, map[string]interface{}{
+		"requests": float64(600), "unit": "Minute"}) {
+		t.Errorf("rateLimit = %v", got)
+	}
+
+	disabled := false
+	_, err = impl.UpdatePlugin(&KongPluginReqDto{PluginId: ip.Id, Enabled: &disabled})
+	if err != nil {
+		t.Fatal(err)
+	}
+	mustNotExist(t, impl, securityPolicyResource, routeName(route.Id))
+
+	exist, err := impl.GetPlugin(&KongPluginReqDto{Name: "ip-restriction", RouteId: route.Id})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exist == nil || exist.Id != ip.Id || exist.Enabled {
+		t.Errorf("GetPlugin() = %+v", exist)
+	}
+
+	err = impl.RemovePlugin(cors.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mustNotExist(t, impl, securityPolicyResource, gatewayPolicyName)
+
+	resp, err := impl.AddPlugin(&KongPluginReqDto{Name: "set-route-info", RouteId: route.Id})
+	if resp != nil || err != nil {
+		t.Errorf("AddPlugin() of unsupported plugin = %v, %v", resp, err)
+	}
+	_, err = impl.AddPlugin(&KongPluginReqDto{Name: "hmac-auth", RouteId: route.Id})
+	if errors.Cause(err) != ErrPluginNotSupported {
+		t.Errorf("AddPlugin() of auth plugin err = %v", err)
+	}
+	_, err = impl.AddPlugin(&KongPluginReqDto{Name: "rate-limiting", ConsumerId: "consumer"})
+	if errors.Cause(err) != ErrPluginNotSupported {
+		t.Errorf("AddPlugin() of consumer err = %v", err)
+	}
+
+	err = impl.DeleteRoute(route.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mustNotExist(t, impl, configMapResource, pluginName(ip.Id))
+	mustNotExist(t, impl, trafficPolicyResource, routeName(route.Id))
+}
+
+func TestKeyAuth(t *testing.T) {
+	impl := newTestAdapter()
+	service, err := impl.CreateOrUpdateService(&KongServiceReqDto{Host: "user-center", Port: 8080})
+	if err != nil {
+		t.Fatal(err)
+	}
+	route, err := impl.CreateOrUpdateRoute(&KongRouteReqDto{
+		Paths:   []string{"/user"},
+		Service: &Service{Id: service.Id},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var consumers []string
+	var credentials []*KongCredentialDto
+	for _, name := range []string{"app-1", "app-2"} {
+		consumer, err := impl.CreateConsumer(NewKongConsumerReqDto(name, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = impl.CreateAclGroup(consumer.Id, name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		credential, err := impl.CreateCredential(&KongCredentialReqDto{ConsumerId: consumer.Id, PluginName: "key-auth"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		consumers = append(consumers, consumer.Id)
+		credentials = append(credentials, credential)
+	}
+	routeKeys := func() map[string]interface{} {
+		return nested(t, mustGet(t, impl, secretResource, keyAuthSecretName(route.Id)), "stringData").(map[string]interface{})
+	}
+
+	// acl without key-auth rejects all requests
+	_, err = impl.CreateOrUpdatePlugin(&KongPluginReqDto{
+		Name:      "acl",
+		ServiceId: service.Id,
+		Config:    map[string]interface{}{"whitelist": "app-1"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := routeKeys(); len(got) != 0 {
+		t.Errorf("keys of acl only = %v", got)
+	}
+
+	_, err = impl.CreateOrUpdatePlugin(&KongPluginReqDto{
+		Name:    "key-auth",
+		RouteId: route.Id,
+		Config:  map[string]interface{}{"key_names": []string{"appKey", "x-app-key"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	policy := mustGet(t, impl, securityPolicyResource, routeName(route.Id))
+	if got := nested(t, policy, "spec", "apiKeyAuth", "credentialRefs", 0, "name"); got != keyAuthSecretName(route.Id) {
+		t.Errorf("credentialRef = %v", got)
+	}
+	if got := nested(t, policy, "spec", "apiKeyAuth", "extractFrom", 0, "headers"); !reflect.DeepEqual(got, []interface{}{"appKey", "x-app-key"}) {
+		t.Errorf("extractFrom = %v", got)
+	}
+	if got := routeKeys(); !reflect.DeepEqual(got, map[string]interface{}{credentials[0].Id: credentials[0].Key}) {
+		t.Errorf("keys allowed by acl = %v", got)
+	}
+
+	// groups and consumers are synced to the routes
+	err = impl.CreateAclGroup(consumers[1], "app-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := routeKeys(); len(got) != 2 {
+		t.Errorf("keys after group added = %v", got)
+	}
+	err = impl.DeleteConsumer(consumers[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := routeKeys(); !reflect.DeepEqual(got, map[string]interface{}{credentials[1].Id: credentials[1].Key}) {
+		t.Errorf("keys after consumer deleted = %v", got)
+	}
+
+	_, err = impl.AddPlugin(&KongPluginReqDto{Name: "key-auth"})
+	if errors.Cause(err) != ErrPluginNotSupported {
+		t.Errorf("AddPlugin() of global key-auth err = %v", err)
+	}
+
+	err = impl.DeleteRoute(route.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mustNotExist(t, impl, secretResource, keyAuthSecretName(route.Id))
+}
+
+func TestConsumer(t *testing.T) {
+	impl := newTestAdapter()
+	consumer, err := impl.CreateConsumer(NewKongConsumerReqDto("app-1", "app-1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = impl.CreateAclGroup(consumer.Id, "app-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	credential, err := impl.CreateCredential(&KongCredentialReqDto{
+		ConsumerId: consumer.Id,
+		PluginName: "key-auth",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if credential.Key == "" || credential.ConsumerId != consumer.Id {
+		t.Errorf("CreateCredential() = %+v", credential)
+	}
+	list, err := impl.GetCredentialList(consumer.Id, "key-auth")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if list.Total != 1 || list.Data[0].Key != credential.Key {
+		t.Errorf("GetCredentialList() = %+v", list)
+	}
+	obj := mustGet(t, impl, secretResource, consumerName(consumer.Id))
+	if got := aclGroups(obj); !reflect.DeepEqual(got, []string{"app-1"}) {
+		t.Errorf("aclGroups() = %v", got)
+	}
+
+	err = impl.DeleteConsumer(consumer.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mustNotExist(t, impl, secretResource, consumerName(consumer.Id))
+	mustNotExist(t, impl, secretResource, credentialName(credential.Id))
+}
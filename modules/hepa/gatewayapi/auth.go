@@ -0,0 +1,163 @@
+// Copyright (c) 2021 Terminus, Inc.
+//
+// This program is free software: you can use, redistribute, and/or modify
+// it under the terms of the GNU Affero General Public License, version 3
+// or later ("AGPL"), as published by the Free Software Foundation.
+//
+// This program is distributed in the hope that it will be useful, but WITHOUT
+// ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+// FITNESS FOR A PARTICULAR PURPOSE.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package gatewayapi
+
+import (
+	"github.com/pkg/errors"
+
+	. "github.com/erda-project/erda/modules/hepa/kong/dto"
+)
+
+// key-auth and acl of routes are rendered into the apiKeyAuth of SecurityPolicy,
+// the keys of consumers allowed by acl are kept in a Secret of each route, since
+// envoy gateway reads the client ids and keys from the Secrets referenced by policy
+
+const (
+	keyAuthPlugin = "key-auth"
+	aclPlugin     = "acl"
+)
+
+// kong key-auth looks for the key in headers and query args by key_names
+var defaultKeyNames = []string{"apikey"}
+
+func keyAuthSecretName(routeId string) string {
+	return routeName(routeId) + "-keys"
+}
+
+// effectivePlugin returns the last enabled plugin of name, which overrides the former ones
+func effectivePlugin(name string, plugins ...[]KongPluginRespDto) *KongPluginRespDto {
+	var res *KongPluginRespDto
+	for _, list := range plugins {
+		for i := range list {
+			if list[i].Name == name && list[i].Enabled && list[i].ConsumerId == "" {
+				res = &list[i]
+			}
+		}
+	}
+	return res
+}
+
+// aclAllowed checks the groups of consumer as kong acl does, whitelist and blacklist are for kong 0.x,
+// an allow list set but empty like "," allows nobody
+func aclAllowed(config map[string]interface{}, groups []string) bool {
+	inList := func(list []string) bool {
+		for _, group := range groups {
+			for _, item := range list {
+				if group == item {
+					return true
+				}
+			}
+		}
+		return false
+	}
+	if config["whitelist"] != nil || config["allow"] != nil {
+		return inList(append(stringList(config["whitelist"]), stringList(config["allow"])...))
+	}
+	return !inList(append(stringList(config["blacklist"]), stringList(config["deny"])...))
+}
+
+// consumerKeys returns the key-auth credentials allowed by acl, keyed by credential id as the client id
+func (impl *Adapter) consumerKeys(acl *KongPluginRespDto) (map[string]interface{}, error) {
+	objs, err := impl.list(secretResource, map[string]string{LabelPluginName: keyAuthPlugin})
+	if err != nil {
+		return nil, err
+	}
+	consumerGroups := map[string][]string{}
+	keys := map[string]interface{}{}
+	for i := range objs {
+		credential := KongCredentialDto{}
+		err = getSecretRecord(&objs[i], &credential)
+		if err != nil {
+			return nil, err
+		}
+		if credential.Key == "" {
+			continue
+		}
+		if acl != nil {
+			groups, ok := consumerGroups[credential.ConsumerId]
+			if !ok {
+				consumer, err := impl.get(secretResource, consumerName(credential.ConsumerId))
+				if err != nil {
+					return nil, err
+				}
+				if consumer != nil {
+					groups = aclGroups(consumer)
+				}
+				consumerGroups[credential.ConsumerId] = groups
+			}
+			if !aclAllowed(acl.Config, groups) {
+				continue
+			}
+		}
+		keys[credential.Id] = credential.Key
+	}
+	return keys, nil
+}
+
+// renderKeyAuth applies the key Secret of route and returns the apiKeyAuth of its SecurityPolicy,
+// nil if route needs no auth. acl without key-auth rejects all requests like kong does,
+// since no consumer can be identified, so it's rendered with no keys
+func (impl *Adapter) renderKeyAuth(routeId string, plugins ...[]KongPluginRespDto) (map[string]interface{}, error) {
+	keyAuth := effectivePlugin(keyAuthPlugin, plugins...)
+	acl := effectivePlugin(aclPlugin, plugins...)
+	secret := keyAuthSecretName(routeId)
+	if keyAuth == nil && acl == nil {
+		return nil, impl.remove(secretResource, secret)
+	}
+	keys := map[string]interface{}{}
+	keyNames := defaultKeyNames
+	if keyAuth != nil {
+		var err error
+		keys, err = impl.consumerKeys(acl)
+		if err != nil {
+			return nil, err
+		}
+		if names := stringList(keyAuth.Config["key_names"]); len(names) > 0 {
+			keyNames = names
+		}
+	}
+	obj := impl.newObject(secretResource, secret, map[string]string{LabelRouteId: routeId})
+	obj.Object["type"] = "Opaque"
+	obj.Object["stringData"] = keys
+	err := impl.apply(secretResource, obj)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{
+		"credentialRefs": []interface{}{
+			map[string]interface{}{"group": "", "kind": resourceKinds[secretResource], "name": secret},
+		},
+		"extractFrom": []interface{}{
+			map[string]interface{}{"headers": keyNames},
+			map[string]interface{}{"params": keyNames},
+		},
+	}, nil
+}
+
+// syncKeyAuth renders the routes with key-auth or acl again, after the credentials or groups of consumers changed
+func (impl *Adapter) syncKeyAuth() error {
+	for _, name := range []string{keyAuthPlugin, aclPlugin} {
+		plugins, err := impl.listPlugins(map[string]string{LabelPluginName: name})
+		if err != nil {
+			return err
+		}
+		for i := range plugins {
+			err = impl.syncPolicies(&plugins[i])
+			if err != nil {
+				return errors.WithMessagef(err, "sync %s of plugin %s failed", name, plugins[i].Id)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,265 @@
+// Copyright (c) 2021 Terminus, Inc.
+//
+// This program is free software: you can use, redistribute, and/or modify
+// it under the terms of the GNU Affero General Public License, version 3
+// or later ("AGPL"), as published by the Free Software Foundation.
+//
+// This program is distributed in the hope that it will be useful, but WITHOUT
+// ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+// FITNESS FOR A PARTICULAR PURPOSE.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package gatewayapi
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"strings"
+
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	"github.com/erda-project/erda/modules/hepa/common/util"
+	. "github.com/erda-project/erda/modules/hepa/common/vars"
+	. "github.com/erda-project/erda/modules/hepa/kong/dto"
+)
+
+// consumers and credentials are kept as Secrets, key-auth credentials of the consumers
+// allowed by acl are rendered into the key Secrets of routes, see renderKeyAuth.
+// credentials of the other auth plugins are only kept, since their plugins are rejected
+
+func consumerName(id string) string {
+	return "hepa-consumer-" + id
+}
+
+func credentialName(id string) string {
+	return "hepa-credential-" + id
+}
+
+func (impl *Adapter) CreateConsumer(req *KongConsumerReqDto) (*KongConsumerRespDto, error) {
+	if impl == nil {
+		return nil, errors.New("gateway can't be attached")
+	}
+	if req == nil || req.IsEmpty() {
+		return nil, errors.New(ERR_INVALID_ARG)
+	}
+	resp := &KongConsumerRespDto{
+		CustomId:  req.CustomId,
+		CreatedAt: now(),
+		Id:        newId(),
+	}
+	obj := impl.newObject(secretResource, consumerName(resp.Id), map[string]string{
+		LabelConsumerId: resp.Id,
+	})
+	obj.Object["type"] = "Opaque"
+	obj.Object["stringData"] = map[string]interface{}{
+		"username":  req.Username,
+		"custom_id": req.CustomId,
+	}
+	err := setRecord(obj, resp)
+	if err != nil {
+		return nil, err
+	}
+	err = impl.apply(secretResource, obj)
+	if err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// DeleteConsumer deletes consumer with its credentials
+func (impl *Adapter) DeleteConsumer(id string) error {
+	if impl == nil {
+		return errors.New("gateway can't be attached")
+	}
+	if len(id) == 0 {
+		return errors.New(ERR_INVALID_ARG)
+	}
+	objs, err := impl.list(secretResource, map[string]string{LabelConsumerId: id})
+	if err != nil {
+		return err
+	}
+	for _, obj := range objs {
+		err = impl.remove(secretResource, obj.GetName())
+		if err != nil {
+			return err
+		}
+	}
+	if len(objs) == 0 {
+		return nil
+	}
+	return impl.syncKeyAuth()
+}
+
+func (impl *Adapter) CreateCredential(req *KongCredentialReqDto) (*KongCredentialDto, error) {
+	if impl == nil {
+		return nil, errors.New("gateway can't be attached")
+	}
+	if req == nil || req.IsEmpty() {
+		return nil, errors.New(ERR_INVALID_ARG)
+	}
+	consumer, err := impl.get(secretResource, consumerName(req.ConsumerId))
+	if err != nil {
+		return nil, err
+	}
+	if consumer == nil {
+		return nil, errors.Errorf("CreateCredential failed: consumer %s not found", req.ConsumerId)
+	}
+	credential := KongCredentialDto{}
+	if req.Config != nil {
+		credential = *req.Config
+	}
+	credential.Id = newId()
+	credential.ConsumerId = req.ConsumerId
+	credential.CreatedAt = now()
+	// generate the secrets as kong does
+	switch req.PluginName {
+	case "key-auth", "sign-auth", "hmac-auth":
+		if credential.Key == "" {
+			credential.Key, err = util.GenUniqueId()
+		}
+		if err == nil && credential.Secret == "" && req.PluginName != "key-auth" {
+			credential.Secret, err = util.GenUniqueId()
+		}
+	case "oauth2":
+		if credential.ClientId == "" {
+			credential.ClientId, err = util.GenUniqueId()
+		}
+		if err == nil && credential.ClientSecret == "" {
+			credential.ClientSecret, err = util.GenUniqueId()
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	obj := impl.newObject(secretResource, credentialName(credential.Id), map[string]string{
+		LabelConsumerId: req.ConsumerId,
+		LabelPluginName: req.PluginName,
+	})
+	err = setSecretRecord(obj, credential)
+	if err != nil {
+		return nil, err
+	}
+	err = impl.apply(secretResource, obj)
+	if err != nil {
+		return nil, err
+	}
+	if req.PluginName == keyAuthPlugin {
+		err = impl.syncKeyAuth()
+		if err != nil {
+			return nil, err
+		}
+	}
+	return &credential, nil
+}
+
+func (impl *Adapter) DeleteCredential(consumerId, pluginName, credentialId string) error {
+	if impl == nil {
+		return errors.New("gateway can't be attached")
+	}
+	obj, err := impl.get(secretResource, credentialName(credentialId))
+	if err != nil || obj == nil {
+		return err
+	}
+	objLabels := obj.GetLabels()
+	if objLabels[LabelConsumerId] != consumerId || objLabels[LabelPluginName] != pluginName {
+		return nil
+	}
+	err = impl.remove(secretResource, obj.GetName())
+	if err != nil || pluginName != keyAuthPlugin {
+		return err
+	}
+	return impl.syncKeyAuth()
+}
+
+func (impl *Adapter) GetCredentialList(consumerId, pluginName string) (*KongCredentialListDto, error) {
+	if impl == nil {
+		return nil, errors.New("gateway can't be attached")
+	}
+	objs, err := impl.list(secretResource, map[string]string{
+		LabelConsumerId: consumerId,
+		LabelPluginName: pluginName,
+	})
+	if err != nil {
+		return nil, err
+	}
+	resp := &KongCredentialListDto{}
+	for i := range objs {
+		credential := KongCredentialDto{}
+		err = getSecretRecord(&objs[i], &credential)
+		if err != nil {
+			return nil, err
+		}
+		resp.Data = append(resp.Data, credential)
+	}
+	resp.Total = int64(len(resp.Data))
+	return resp, nil
+}
+
+func (impl *Adapter) CreateAclGroup(consumerId string, customId string) error {
+	if impl == nil {
+		return errors.New("gateway can't be attached")
+	}
+	if len(consumerId) == 0 || len(customId) == 0 {
+		return errors.New(ERR_INVALID_ARG)
+	}
+	consumer, err := impl.get(secretResource, consumerName(consumerId))
+	if err != nil {
+		return err
+	}
+	if consumer == nil {
+		return errors.Errorf("CreateAclGroup failed: consumer %s not found", consumerId)
+	}
+	groups := aclGroups(consumer)
+	for _, group := range groups {
+		if group == customId {
+			return nil
+		}
+	}
+	annotations := consumer.GetAnnotations()
+	annotations[AnnotationGroups] = strings.Join(append(groups, customId), ",")
+	consumer.SetAnnotations(annotations)
+	err = impl.apply(secretResource, consumer)
+	if err != nil {
+		return err
+	}
+	return impl.syncKeyAuth()
+}
+
+func aclGroups(consumer *unstructured.Unstructured) []string {
+	groups := consumer.GetAnnotations()[AnnotationGroups]
+	if groups == "" {
+		return nil
+	}
+	return strings.Split(groups, ",")
+}
+
+// setSecretRecord keeps record in the data of secret, instead of the annotation
+func setSecretRecord(obj *unstructured.Unstructured, record interface{}) error {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return errors.Wrap(err, ERR_JSON_FAIL)
+	}
+	obj.Object["type"] = "Opaque"
+	obj.Object["stringData"] = map[string]interface{}{recordDataKey: string(data)}
+	return nil
+}
+
+func getSecretRecord(obj *unstructured.Unstructured, record interface{}) error {
+	var data []byte
+	if encoded, ok, _ := unstructured.NestedString(obj.Object, "data", recordDataKey); ok {
+		decoded, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return errors.Wrapf(err, "invalid record of secret %s", obj.GetName())
+		}
+		data = decoded
+	} else if raw, ok, _ := unstructured.NestedString(obj.Object, "stringData", recordDataKey); ok {
+		// stringData is merged into data by apiserver, it is read before that
+		data = []byte(raw)
+	} else {
+		return errors.Errorf("record of secret %s missing", obj.GetName())
+	}
+	return errors.Wrapf(json.Unmarshal(data, record), "invalid record of secret %s", obj.GetName())
+}
@@ -0,0 +1,641 @@
+// Copyright (c) 2021 Terminus, Inc.
+//
+// This program is free software: you can use, redistribute, and/or modify
+// it under the terms of the GNU Affero General Public License, version 3
+// or later ("AGPL"), as published by the Free Software Foundation.
+//
+// This program is distributed in the hope that it will be useful, but WITHOUT
+// ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+// FITNESS FOR A PARTICULAR PURPOSE.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package gatewayapi
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+
+	. "github.com/erda-project/erda/modules/hepa/common/vars"
+	. "github.com/erda-project/erda/modules/hepa/kong/dto"
+)
+
+// pluginRender renders kong plugin config into the spec of envoy gateway policy
+type pluginRender func(config map[string]interface{}, spec map[string]interface{})
+
+// plugins rendered into SecurityPolicy
+var securityPlugins = map[string]pluginRender{
+	"cors":           renderCors,
+	"ip-restriction": renderIpRestriction,
+}
+
+// plugins rendered into BackendTrafficPolicy
+var trafficPlugins = map[string]pluginRender{
+	"rate-limiting": renderRateLimiting,
+}
+
+// builtinPlugins are done by envoy gateway itself, they are kept without rendering
+var builtinPlugins = map[string]bool{
+	"grpc-web": true,
+}
+
+// authPlugins are rendered into the apiKeyAuth of route SecurityPolicy with the
+// credentials of consumers, they are supported on routes and services only
+var authPlugins = map[string]bool{
+	keyAuthPlugin: true,
+	aclPlugin:     true,
+}
+
+// unsupportedPlugins can't be rendered, skipping them like kong without the plugin
+// would leave apis unprotected or change them silently, so they fail instead
+var unsupportedPlugins = map[string]bool{
+	"basic-auth":           true,
+	"hmac-auth":            true,
+	"jwt":                  true,
+	"oauth2":               true,
+	"sign-auth":            true,
+	"request-transformer":  true,
+	"response-transformer": true,
+	"csrf-token":           true,
+	"host-passthrough":     true,
+	"post-function":        true,
+}
+
+func pluginName(id string) string {
+	return "hepa-plugin-" + id
+}
+
+func pluginSupported(name string) bool {
+	_, security := securityPlugins[name]
+	_, traffic := trafficPlugins[name]
+	return security || traffic || authPlugins[name] || builtinPlugins[name]
+}
+
+func (impl *Adapter) CheckPluginEnabled(pluginName string) (bool, error) {
+	if impl == nil {
+		return false, errors.New("gateway can't be attached")
+	}
+	return pluginSupported(pluginName), nil
+}
+
+// checkPlugin returns false if plugin should be skipped
+func checkPlugin(req *KongPluginReqDto) (bool, error) {
+	if unsupportedPlugins[req.Name] {
+		return false, errors.Wrapf(ErrPluginNotSupported, "plugin:%s", req.Name)
+	}
+	if !pluginSupported(req.Name) {
+		log.Warnf("plugin %s not enabled, req:%+v", req.Name, req)
+		return false, nil
+	}
+	routeId, serviceId, consumerId := pluginScope(req)
+	if consumerId != "" {
+		return false, errors.Wrapf(ErrPluginNotSupported, "plugin %s of consumer %s", req.Name, consumerId)
+	}
+	if authPlugins[req.Name] && routeId == "" && serviceId == "" {
+		return false, errors.Wrapf(ErrPluginNotSupported, "global plugin %s", req.Name)
+	}
+	return true, nil
+}
+
+func pluginScope(req *KongPluginReqDto) (routeId, serviceId, consumerId string) {
+	routeId, serviceId, consumerId = req.RouteId, req.ServiceId, req.ConsumerId
+	if req.Route != nil {
+		routeId = req.Route.Id
+	}
+	if req.Service != nil {
+		serviceId = req.Service.Id
+	}
+	if req.Consumer != nil {
+		consumerId = req.Consumer.Id
+	}
+	return
+}
+
+func (impl *Adapter) listPlugins(selector map[string]string) ([]KongPluginRespDto, error) {
+	objs, err := impl.list(configMapResource, selector)
+	if err != nil {
+		return nil, err
+	}
+	var plugins []KongPluginRespDto
+	for i := range objs {
+		if objs[i].GetLabels()[LabelPluginId] == "" {
+			continue
+		}
+		plugin := KongPluginRespDto{}
+		err = getRecord(&objs[i], &plugin)
+		if err != nil {
+			return nil, err
+		}
+		plugins = append(plugins, plugin)
+	}
+	return plugins, nil
+}
+
+func (impl *Adapter) getPluginById(id string) (*KongPluginRespDto, error) {
+	obj, err := impl.get(configMapResource, pluginName(id))
+	if err != nil {
+		return nil, err
+	}
+	if obj == nil {
+		return nil, nil
+	}
+	plugin := &KongPluginRespDto{}
+	err = getRecord(obj, plugin)
+	if err != nil {
+		return nil, err
+	}
+	return plugin, nil
+}
+
+func (impl *Adapter) GetPlugin(req *KongPluginReqDto) (*KongPluginRespDto, error) {
+	if impl == nil {
+		return nil, errors.New("gateway can't be attached")
+	}
+	if req == nil || req.Name == "" {
+		return nil, errors.New(ERR_INVALID_ARG)
+	}
+	routeId, serviceId, consumerId := pluginScope(req)
+	plugins, err := impl.listPlugins(map[string]string{LabelPluginName: req.Name})
+	if err != nil {
+		return nil, err
+	}
+	for _, plugin := range plugins {
+		if (routeId == "" || plugin.RouteId == routeId) &&
+			(serviceId == "" || plugin.ServiceId == serviceId) &&
+			(consumerId == "" || plugin.ConsumerId == consumerId) {
+			return &plugin, nil
+		}
+	}
+	return nil, nil
+}
+
+func (impl *Adapter) GetRoutePlugins(routeId string) ([]KongPluginRespDto, error) {
+	if impl == nil {
+		return nil, errors.New("gateway can't be attached")
+	}
+	if routeId == "" {
+		return nil, errors.New(ERR_INVALID_ARG)
+	}
+	return impl.listPlugins(map[string]string{LabelRouteId: routeId})
+}
+
+// savePlugin keeps plugin as ConfigMap, then renders the policies it takes effect on
+func (impl *Adapter) savePlugin(plugin *KongPluginRespDto) (*KongPluginRespDto, error) {
+	obj := impl.newObject(configMapResource, pluginName(plugin.Id), map[string]string{
+		LabelPluginId:   plugin.Id,
+		LabelPluginName: plugin.Name,
+		LabelRouteId:    plugin.RouteId,
+		LabelServiceId:  plugin.ServiceId,
+	})
+	err := setRecord(obj, plugin)
+	if err != nil {
+		return nil, err
+	}
+	err = impl.apply(configMapResource, obj)
+	if err != nil {
+		return nil, err
+	}
+	err = impl.syncPolicies(plugin)
+	if err != nil {
+		return nil, err
+	}
+	return plugin, nil
+}
+
+func newPlugin(req *KongPluginReqDto) *KongPluginRespDto {
+	routeId, serviceId, _ := pluginScope(req)
+	plugin := &KongPluginRespDto{
+		Id:        req.Id,
+		ServiceId: serviceId,
+		RouteId:   routeId,
+		Name:      req.Name,
+		Config:    req.Config,
+		Enabled:   req.Enabled == nil || *req.Enabled,
+		CreatedAt: now(),
+	}
+	if routeId != "" {
+		plugin.Route = &KongObj{Id: routeId}
+	}
+	if serviceId != "" {
+		plugin.Service = &KongObj{Id: serviceId}
+	}
+	return plugin
+}
+
+func (impl *Adapter) AddPlugin(req *KongPluginReqDto) (*KongPluginRespDto, error) {
+	if impl == nil {
+		return nil, errors.New("gateway can't be attached")
+	}
+	if req == nil {
+		return nil, errors.New(ERR_INVALID_ARG)
+	}
+	ok, err := checkPlugin(req)
+	if !ok {
+		return nil, err
+	}
+	plugin := newPlugin(req)
+	if plugin.Id == "" {
+		plugin.Id = newId()
+	}
+	return impl.savePlugin(plugin)
+}
+
+// PutPlugin replaces the plugin of req.PluginId
+func (impl *Adapter) PutPlugin(req *KongPluginReqDto) (*KongPluginRespDto, error) {
+	if impl == nil {
+		return nil, errors.New("gateway can't be attached")
+	}
+	if req == nil || req.PluginId == "" {
+		return nil, errors.New(ERR_INVALID_ARG)
+	}
+	ok, err := checkPlugin(req)
+	if !ok {
+		return nil, err
+	}
+	exist, err := impl.getPluginById(req.PluginId)
+	if err != nil {
+		return nil, err
+	}
+	plugin := newPlugin(req)
+	plugin.Id = req.PluginId
+	if exist != nil {
+		plugin.CreatedAt = exist.CreatedAt
+		// the policies plugin was on should be rendered without it
+		if exist.RouteId != plugin.RouteId || exist.ServiceId != plugin.ServiceId {
+			err = impl.remove(configMapResource, pluginName(exist.Id))
+			if err != nil {
+				return nil, err
+			}
+			err = impl.syncPolicies(exist)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+	return impl.savePlugin(plugin)
+}
+
+// UpdatePlugin only updates the fields set in req, config is merged by keys
+func (impl *Adapter) UpdatePlugin(req *KongPluginReqDto) (*KongPluginRespDto, error) {
+	if impl == nil {
+		return nil, errors.New("gateway can't be attached")
+	}
+	if req == nil || req.PluginId == "" {
+		return nil, errors.New(ERR_INVALID_ARG)
+	}
+	plugin, err := impl.getPluginById(req.PluginId)
+	if err != nil {
+		return nil, err
+	}
+	if plugin == nil {
+		return nil, errors.Errorf("UpdatePlugin failed: plugin %s not found", req.PluginId)
+	}
+	if req.Enabled != nil {
+		plugin.Enabled = *req.Enabled
+	}
+	if len(req.Config) > 0 && plugin.Config == nil {
+		plugin.Config = map[string]interface{}{}
+	}
+	for key, value := range req.Config {
+		plugin.Config[key] = value
+	}
+	return impl.savePlugin(plugin)
+}
+
+func (impl *Adapter) CreateOrUpdatePlugin(req *KongPluginReqDto) (*KongPluginRespDto, error) {
+	if impl == nil {
+		return nil, errors.New("gateway can't be attached")
+	}
+	if req == nil {
+		return nil, errors.New(ERR_INVALID_ARG)
+	}
+	ok, err := checkPlugin(req)
+	if !ok {
+		return nil, err
+	}
+	exist, err := impl.GetPlugin(req)
+	if err != nil {
+		return nil, err
+	}
+	if exist == nil {
+		return impl.AddPlugin(req)
+	}
+	req.Id = exist.Id
+	req.PluginId = exist.Id
+	return impl.PutPlugin(req)
+}
+
+func (impl *Adapter) CreateOrUpdatePluginById(req *KongPluginReqDto) (*KongPluginRespDto, error) {
+	if impl == nil {
+		return nil, errors.New("gateway can't be attached")
+	}
+	if req == nil {
+		return nil, errors.New(ERR_INVALID_ARG)
+	}
+	if req.Id == "" {
+		return impl.AddPlugin(req)
+	}
+	req.PluginId = req.Id
+	return impl.PutPlugin(req)
+}
+
+func (impl *Adapter) DeletePluginIfExist(req *KongPluginReqDto) error {
+	if impl == nil {
+		return errors.New("gateway can't be attached")
+	}
+	if req == nil {
+		return errors.New(ERR_INVALID_ARG)
+	}
+	exist, err := impl.GetPlugin(req)
+	if err != nil {
+		return err
+	}
+	if exist == nil {
+		return nil
+	}
+	return impl.RemovePlugin(exist.Id)
+}
+
+func (impl *Adapter) RemovePlugin(id string) error {
+	if impl == nil {
+		return errors.New("gateway can't be attached")
+	}
+	if len(id) == 0 {
+		return errors.New(ERR_INVALID_ARG)
+	}
+	plugin, err := impl.getPluginById(id)
+	if err != nil {
+		return err
+	}
+	if plugin == nil {
+		return nil
+	}
+	err = impl.remove(configMapResource, pluginName(id))
+	if err != nil {
+		return err
+	}
+	return impl.syncPolicies(plugin)
+}
+
+// syncPolicies renders the policies plugin takes effect on
+func (impl *Adapter) syncPolicies(plugin *KongPluginRespDto) error {
+	var selector map[string]string
+	switch {
+	case plugin.RouteId != "":
+		selector = map[string]string{LabelRouteId: plugin.RouteId}
+	case plugin.ServiceId != "":
+		selector = map[string]string{LabelServiceId: plugin.ServiceId}
+	default:
+		err := impl.applyGatewayPolicies()
+		if err != nil {
+			return err
+		}
+	}
+	objs, err := impl.list(httpRouteResource, selector)
+	if err != nil {
+		return err
+	}
+	for i := range objs {
+		record := &routeRecord{}
+		err = getRecord(&objs[i], record)
+		if err != nil {
+			return err
+		}
+		err = impl.applyRoutePolicies(record)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// renderPolicies merges plugins by precedence, the later one overrides
+func renderPolicies(renders map[string]pluginRender, plugins ...[]KongPluginRespDto) map[string]interface{} {
+	spec := map[string]interface{}{}
+	for _, list := range plugins {
+		for _, plugin := range list {
+			render, ok := renders[plugin.Name]
+			if !ok || !plugin.Enabled || plugin.ConsumerId != "" {
+				continue
+			}
+			render(plugin.Config, spec)
+		}
+	}
+	return spec
+}
+
+func scopedPlugins(plugins []KongPluginRespDto, routeId, serviceId string) []KongPluginRespDto {
+	var res []KongPluginRespDto
+	for _, plugin := range plugins {
+		if plugin.RouteId == routeId && plugin.ServiceId == serviceId {
+			res = append(res, plugin)
+		}
+	}
+	return res
+}
+
+func (impl *Adapter) applyPolicy(gvr schema.GroupVersionResource, name string, targetRef map[string]interface{}, spec map[string]interface{}, objLabels map[string]string) error {
+	if len(spec) == 0 {
+		return impl.remove(gvr, name)
+	}
+	obj := impl.newObject(gvr, name, objLabels)
+	spec["targetRefs"] = []interface{}{targetRef}
+	err := setSpec(obj, spec)
+	if err != nil {
+		return err
+	}
+	return impl.apply(gvr, obj)
+}
+
+// applyRoutePolicies renders the plugins of route and its service, with global plugins,
+// since the policy attached to HTTPRoute overrides the one attached to Gateway
+func (impl *Adapter) applyRoutePolicies(route *routeRecord) error {
+	plugins, err := impl.listPlugins(nil)
+	if err != nil {
+		return err
+	}
+	global := scopedPlugins(plugins, "", "")
+	service := scopedPlugins(plugins, "", route.Service.Id)
+	own := scopedPlugins(plugins, route.Id, "")
+	targetRef := objectRef(httpRouteResource, routeName(route.Id))
+	objLabels := map[string]string{LabelRouteId: route.Id}
+	keyAuth, err := impl.renderKeyAuth(route.Id, service, own)
+	if err != nil {
+		return err
+	}
+	for gvr, renders := range map[schema.GroupVersionResource]map[string]pluginRender{
+		securityPolicyResource: securityPlugins,
+		trafficPolicyResource:  trafficPlugins,
+	} {
+		spec := map[string]interface{}{}
+		if len(renderPolicies(renders, service, own)) > 0 || (gvr == securityPolicyResource && keyAuth != nil) {
+			spec = renderPolicies(renders, global, service, own)
+		}
+		if gvr == securityPolicyResource && keyAuth != nil {
+			spec["apiKeyAuth"] = keyAuth
+		}
+		err = impl.applyPolicy(gvr, routeName(route.Id), targetRef, spec, objLabels)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (impl *Adapter) applyGatewayPolicies() error {
+	plugins, err := impl.listPlugins(nil)
+	if err != nil {
+		return err
+	}
+	global := scopedPlugins(plugins, "", "")
+	targetRef := map[string]interface{}{
+		"group": gatewayGroup,
+		"kind":  "Gateway",
+		"name":  impl.Gateway,
+	}
+	err = impl.applyPolicy(securityPolicyResource, gatewayPolicyName, targetRef, renderPolicies(securityPlugins, global), nil)
+	if err != nil {
+		return err
+	}
+	return impl.applyPolicy(trafficPolicyResource, gatewayPolicyName, targetRef, renderPolicies(trafficPlugins, global), nil)
+}
+
+func stringList(value interface{}) []string {
+	var res []string
+	switch v := value.(type) {
+	case string:
+		for _, item := range strings.Split(v, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				res = append(res, item)
+			}
+		}
+	case []string:
+		res = v
+	case []interface{}:
+		for _, item := range v {
+			if s, ok := item.(string); ok {
+				res = append(res, s)
+			}
+		}
+	}
+	return res
+}
+
+func intValue(value interface{}) int64 {
+	switch v := value.(type) {
+	case int:
+		return int64(v)
+	case int64:
+		return v
+	case float64:
+		return int64(v)
+	case string:
+		i, _ := strconv.ParseInt(v, 10, 64)
+		return i
+	}
+	return 0
+}
+
+func renderCors(config map[string]interface{}, spec map[string]interface{}) {
+	cors := map[string]interface{}{
+		"allowOrigins": []string{"*"},
+	}
+	if origins := stringList(config["origins"]); len(origins) > 0 {
+		cors["allowOrigins"] = origins
+	}
+	if methods := stringList(config["methods"]); len(methods) > 0 {
+		cors["allowMethods"] = methods
+	}
+	if headers := stringList(config["headers"]); len(headers) > 0 {
+		cors["allowHeaders"] = headers
+	}
+	if headers := stringList(config["exposed_headers"]); len(headers) > 0 {
+		cors["exposeHeaders"] = headers
+	}
+	if credentials, ok := config["credentials"].(bool); ok {
+		cors["allowCredentials"] = credentials
+	}
+	if maxAge := intValue(config["max_age"]); maxAge > 0 {
+		cors["maxAge"] = fmt.Sprintf("%ds", maxAge)
+	}
+	spec["cors"] = cors
+}
+
+func cidrs(ips []string) []string {
+	var res []string
+	for _, ip := range ips {
+		if !strings.Contains(ip, "/") {
+			if parsed := net.ParseIP(ip); parsed != nil && parsed.To4() == nil {
+				ip += "/128"
+			} else {
+				ip += "/32"
+			}
+		}
+		res = append(res, ip)
+	}
+	return res
+}
+
+func renderIpRestriction(config map[string]interface{}, spec map[string]interface{}) {
+	// whitelist and blacklist are for kong 0.x
+	allow := append(stringList(config["whitelist"]), stringList(config["allow"])...)
+	deny := append(stringList(config["blacklist"]), stringList(config["deny"])...)
+	defaultAction := "Allow"
+	var rules []interface{}
+	if len(deny) > 0 {
+		rules = append(rules, map[string]interface{}{
+			"action":    "Deny",
+			"principal": map[string]interface{}{"clientCIDRs": cidrs(deny)},
+		})
+	}
+	if len(allow) > 0 {
+		defaultAction = "Deny"
+		rules = append(rules, map[string]interface{}{
+			"action":    "Allow",
+			"principal": map[string]interface{}{"clientCIDRs": cidrs(allow)},
+		})
+	}
+	spec["authorization"] = map[string]interface{}{
+		"defaultAction": defaultAction,
+		"rules":         rules,
+	}
+}
+
+var rateLimitUnits = []struct {
+	key  string
+	unit string
+}{
+	{"second", "Second"},
+	{"minute", "Minute"},
+	{"hour", "Hour"},
+	{"day", "Day"},
+}
+
+// renderRateLimiting takes the limit of the smallest unit, envoy gateway
+// supports only one local limit for all the requests
+func renderRateLimiting(config map[string]interface{}, spec map[string]interface{}) {
+	for _, unit := range rateLimitUnits {
+		requests := intValue(config[unit.key])
+		if requests <= 0 {
+			continue
+		}
+		spec["rateLimit"] = map[string]interface{}{
+			"type": "Local",
+			"local": map[string]interface{}{
+				"rules": []interface{}{
+					map[string]interface{}{
+						"limit": map[string]interface{}{"requests": requests, "unit": unit.unit},
+					},
+				},
+			},
+		}
+		return
+	}
+}
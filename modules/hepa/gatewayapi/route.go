@@ -0,0 +1,489 @@
+// Copyright (c) 2021 Terminus, Inc.
+//
+// This program is free software: you can use, redistribute, and/or modify
+// it under the terms of the GNU Affero General Public License, version 3
+// or later ("AGPL"), as published by the Free Software Foundation.
+//
+// This program is distributed in the hope that it will be useful, but WITHOUT
+// ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+// FITNESS FOR A PARTICULAR PURPOSE.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package gatewayapi
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+
+	. "github.com/erda-project/erda/modules/hepa/common/vars"
+	. "github.com/erda-project/erda/modules/hepa/kong/dto"
+)
+
+// routeRecord is kong route rendered as HTTPRoute
+type routeRecord struct {
+	KongRouteRespDto
+	StripPath    *bool `json:"strip_path,omitempty"`
+	PreserveHost *bool `json:"preserve_host,omitempty"`
+}
+
+const headerRegexPrefix = "~*"
+
+func routeName(id string) string {
+	return "hepa-route-" + id
+}
+
+func routeFilterName(id string, index int) string {
+	return routeName(id) + "-" + strconv.Itoa(index)
+}
+
+// isRegexPath works like kong, which treats path with regex characters as regex
+func isRegexPath(path string) bool {
+	return strings.ContainsAny(path, `()[]{}*+?^$|\`)
+}
+
+func joinPath(prefix, path string) string {
+	if prefix == "" || prefix == "/" {
+		return path
+	}
+	if path == "" || path == "/" {
+		return prefix
+	}
+	return strings.TrimSuffix(prefix, "/") + "/" + strings.TrimPrefix(path, "/")
+}
+
+// headerMatches renders kong route headers, any of the values matches, and values
+// prefixed with ~* are regex like kong 3.x
+func headerMatches(headers map[string][]string) []interface{} {
+	var names []string
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var matches []interface{}
+	for _, name := range names {
+		values := headers[name]
+		if len(values) == 0 {
+			continue
+		}
+		if len(values) == 1 && !strings.HasPrefix(values[0], headerRegexPrefix) {
+			matches = append(matches, map[string]interface{}{
+				"type": "Exact", "name": name, "value": values[0],
+			})
+			continue
+		}
+		var patterns []string
+		for _, value := range values {
+			if strings.HasPrefix(value, headerRegexPrefix) {
+				patterns = append(patterns, strings.TrimPrefix(value, headerRegexPrefix))
+			} else {
+				patterns = append(patterns, "^"+regexp.QuoteMeta(value)+"$")
+			}
+		}
+		pattern := patterns[0]
+		if len(patterns) > 1 {
+			pattern = "(" + strings.Join(patterns, ")|(") + ")"
+		}
+		matches = append(matches, map[string]interface{}{
+			"type": "RegularExpression", "name": name, "value": pattern,
+		})
+	}
+	return matches
+}
+
+func (impl *Adapter) getRoute(id string) (*routeRecord, error) {
+	obj, err := impl.get(httpRouteResource, routeName(id))
+	if err != nil {
+		return nil, err
+	}
+	if obj == nil {
+		return nil, nil
+	}
+	record := &routeRecord{}
+	err = getRecord(obj, record)
+	if err != nil {
+		return nil, err
+	}
+	return record, nil
+}
+
+// applyRoute renders route with its service into HTTPRoute, with HTTPRouteFilters for regex paths
+func (impl *Adapter) applyRoute(record *routeRecord) error {
+	service, err := impl.getService(record.Service.Id)
+	if err != nil {
+		return err
+	}
+	if service == nil {
+		return errors.Errorf("service %s of route not found", record.Service.Id)
+	}
+	backendRefs, upstream, err := impl.backendRefs(service)
+	if err != nil {
+		return err
+	}
+	stripPath := record.StripPath == nil || *record.StripPath
+	preserveHost := record.PreserveHost != nil && *record.PreserveHost
+	paths := record.Paths
+	if len(paths) == 0 {
+		paths = []string{"/"}
+	}
+	err = impl.removeRouteFilters(record.Id)
+	if err != nil {
+		return err
+	}
+	headers := headerMatches(record.Headers)
+	var rules []interface{}
+	for i, path := range paths {
+		var filters []interface{}
+		rewrite := map[string]interface{}{}
+		if !preserveHost {
+			rewrite["hostname"] = service.Host
+		}
+		matchType := "PathPrefix"
+		matchPath := path
+		if isRegexPath(path) {
+			// kong matches regex path by prefix
+			matchType = "RegularExpression"
+			if !strings.HasSuffix(path, "$") {
+				matchPath = path + ".*"
+			}
+			substitution := strings.TrimSuffix(service.Path, "/")
+			if !stripPath {
+				substitution += `\0`
+			}
+			if substitution != `\0` {
+				name := routeFilterName(record.Id, i)
+				err = impl.applyRouteFilter(record.Id, name, "^"+strings.TrimPrefix(path, "^"), substitution)
+				if err != nil {
+					return err
+				}
+				filters = append(filters, map[string]interface{}{
+					"type":         "ExtensionRef",
+					"extensionRef": objectRef(routeFilterResource, name),
+				})
+			}
+		} else {
+			replace := joinPath(service.Path, path)
+			if stripPath {
+				replace = service.Path
+			}
+			if replace == "" {
+				replace = "/"
+			}
+			if replace != path {
+				rewrite["path"] = map[string]interface{}{
+					"type":               "ReplacePrefixMatch",
+					"replacePrefixMatch": replace,
+				}
+			}
+		}
+		if len(rewrite) > 0 {
+			filters = append(filters, map[string]interface{}{
+				"type":       "URLRewrite",
+				"urlRewrite": rewrite,
+			})
+		}
+		var matches []interface{}
+		match := map[string]interface{}{
+			"path": map[string]interface{}{"type": matchType, "value": matchPath},
+		}
+		if len(headers) > 0 {
+			match["headers"] = headers
+		}
+		if len(record.Methods) == 0 {
+			matches = append(matches, match)
+		}
+		for _, method := range record.Methods {
+			methodMatch := map[string]interface{}{"method": strings.ToUpper(method)}
+			for key, value := range match {
+				methodMatch[key] = value
+			}
+			matches = append(matches, methodMatch)
+		}
+		rule := map[string]interface{}{
+			"matches":     matches,
+			"backendRefs": backendRefs,
+		}
+		if len(filters) > 0 {
+			rule["filters"] = filters
+		}
+		if service.ReadTimeout > 0 {
+			rule["timeouts"] = map[string]interface{}{
+				"backendRequest": fmt.Sprintf("%dms", service.ReadTimeout),
+			}
+		}
+		rules = append(rules, rule)
+	}
+	spec := map[string]interface{}{
+		"parentRefs": []interface{}{map[string]interface{}{"name": impl.Gateway}},
+		"rules":      rules,
+	}
+	if len(record.Hosts) > 0 {
+		spec["hostnames"] = record.Hosts
+	}
+	objLabels := map[string]string{
+		LabelRouteId:   record.Id,
+		LabelServiceId: record.Service.Id,
+	}
+	if upstream != nil {
+		objLabels[LabelUpstreamId] = upstream.Id
+	}
+	obj := impl.newObject(httpRouteResource, routeName(record.Id), objLabels)
+	err = setSpec(obj, spec)
+	if err != nil {
+		return err
+	}
+	err = setRecord(obj, record)
+	if err != nil {
+		return err
+	}
+	return impl.apply(httpRouteResource, obj)
+}
+
+func (impl *Adapter) applyRouteFilter(routeId, name, pattern, substitution string) error {
+	obj := impl.newObject(routeFilterResource, name, map[string]string{
+		LabelRouteId: routeId,
+	})
+	err := setSpec(obj, map[string]interface{}{
+		"urlRewrite": map[string]interface{}{
+			"path": map[string]interface{}{
+				"type": "ReplaceRegexMatch",
+				"replaceRegexMatch": map[string]interface{}{
+					"pattern":      pattern,
+					"substitution": substitution,
+				},
+			},
+		},
+	})
+	if err != nil {
+		return err
+	}
+	return impl.apply(routeFilterResource, obj)
+}
+
+func (impl *Adapter) removeRouteFilters(routeId string) error {
+	filters, err := impl.list(routeFilterResource, map[string]string{LabelRouteId: routeId})
+	if err != nil {
+		return err
+	}
+	for _, filter := range filters {
+		err = impl.remove(routeFilterResource, filter.GetName())
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// syncRoutes renders the routes selected again, after services or plugins they depend on changed
+func (impl *Adapter) syncRoutes(selector map[string]string) error {
+	objs, err := impl.list(httpRouteResource, selector)
+	if err != nil {
+		return err
+	}
+	for i := range objs {
+		record := &routeRecord{}
+		err = getRecord(&objs[i], record)
+		if err != nil {
+			return err
+		}
+		err = impl.applyRoute(record)
+		if err != nil {
+			return err
+		}
+		err = impl.applyRoutePolicies(record)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (impl *Adapter) CreateOrUpdateRoute(req *KongRouteReqDto) (*KongRouteRespDto, error) {
+	if impl == nil {
+		return nil, errors.New("gateway can't be attached")
+	}
+	if req == nil {
+		return nil, errors.New(ERR_INVALID_ARG)
+	}
+	if req.IsEmpty() {
+		log.Errorf("CreateOrUpdateRoute failed: invalid req:%+v", req)
+		return nil, ErrInvalidReq
+	}
+	record := &routeRecord{
+		KongRouteRespDto: KongRouteRespDto{
+			Id:        req.RouteId,
+			CreatedAt: now(),
+			Protocols: req.Protocols,
+			Methods:   req.Methods,
+			Hosts:     req.Hosts,
+			Paths:     req.Paths,
+			Headers:   req.Headers,
+			Service:   *req.Service,
+		},
+		StripPath:    req.StripPath,
+		PreserveHost: req.PreserveHost,
+	}
+	if record.Id == "" {
+		record.Id = newId()
+	} else {
+		exist, err := impl.getRoute(record.Id)
+		if err != nil {
+			return nil, err
+		}
+		if exist != nil {
+			record.CreatedAt = exist.CreatedAt
+		}
+	}
+	return impl.saveRoute(record)
+}
+
+func (impl *Adapter) saveRoute(record *routeRecord) (*KongRouteRespDto, error) {
+	if len(record.Protocols) == 0 {
+		record.Protocols = []string{"http", "https"}
+	}
+	record.UpdatedAt = now()
+	err := impl.applyRoute(record)
+	if err != nil {
+		return nil, err
+	}
+	err = impl.applyRoutePolicies(record)
+	if err != nil {
+		return nil, err
+	}
+	resp := record.KongRouteRespDto
+	return &resp, nil
+}
+
+// UpdateRoute only updates the fields set in req
+func (impl *Adapter) UpdateRoute(req *KongRouteReqDto) (*KongRouteRespDto, error) {
+	if impl == nil {
+		return nil, errors.New("gateway can't be attached")
+	}
+	if req == nil || req.RouteId == "" {
+		return nil, errors.New(ERR_INVALID_ARG)
+	}
+	record, err := impl.getRoute(req.RouteId)
+	if err != nil {
+		return nil, err
+	}
+	if record == nil {
+		return nil, errors.Errorf("UpdateRoute failed: route %s not found", req.RouteId)
+	}
+	if req.Protocols != nil {
+		record.Protocols = req.Protocols
+	}
+	if req.Methods != nil {
+		record.Methods = req.Methods
+	}
+	if req.Hosts != nil {
+		record.Hosts = req.Hosts
+	}
+	if req.Paths != nil {
+		record.Paths = req.Paths
+	}
+	if req.Headers != nil {
+		record.Headers = req.Headers
+	}
+	if req.StripPath != nil {
+		record.StripPath = req.StripPath
+	}
+	if req.PreserveHost != nil {
+		record.PreserveHost = req.PreserveHost
+	}
+	if req.Service != nil && req.Service.Id != "" {
+		record.Service = *req.Service
+	}
+	return impl.saveRoute(record)
+}
+
+func (impl *Adapter) TouchRouteOAuthMethod(id string) error {
+	if impl == nil {
+		return errors.New("gateway can't be attached")
+	}
+	record, err := impl.getRoute(id)
+	if err != nil {
+		return err
+	}
+	if record == nil {
+		return errors.Errorf("get route info failed: route %s not found", id)
+	}
+	for _, method := range record.Methods {
+		if method == "POST" {
+			return nil
+		}
+	}
+	if len(record.Paths) == 0 {
+		return errors.Errorf("route %s has no path", id)
+	}
+	_, err = impl.CreateOrUpdateRoute(&KongRouteReqDto{
+		Methods: []string{"POST"},
+		Hosts:   record.Hosts,
+		Paths:   []string{record.Paths[0] + "/oauth2/token", record.Paths[0] + "/oauth2/authorize"},
+		Service: &record.Service,
+	})
+	return err
+}
+
+func (impl *Adapter) DeleteRoute(id string) error {
+	if impl == nil {
+		return errors.New("gateway can't be attached")
+	}
+	if len(id) == 0 {
+		return errors.New(ERR_INVALID_ARG)
+	}
+	err := impl.remove(httpRouteResource, routeName(id))
+	if err != nil {
+		return err
+	}
+	err = impl.removeRouteFilters(id)
+	if err != nil {
+		return err
+	}
+	// plugins of route are deleted with it like kong does
+	plugins, err := impl.listPlugins(map[string]string{LabelRouteId: id})
+	if err != nil {
+		return err
+	}
+	for _, plugin := range plugins {
+		err = impl.remove(configMapResource, pluginName(plugin.Id))
+		if err != nil {
+			return err
+		}
+	}
+	err = impl.remove(securityPolicyResource, routeName(id))
+	if err != nil {
+		return err
+	}
+	err = impl.remove(secretResource, keyAuthSecretName(id))
+	if err != nil {
+		return err
+	}
+	return impl.remove(trafficPolicyResource, routeName(id))
+}
+
+func (impl *Adapter) GetRoutes() ([]KongRouteRespDto, error) {
+	if impl == nil {
+		return nil, errors.New("gateway can't be attached")
+	}
+	objs, err := impl.list(httpRouteResource, nil)
+	if err != nil {
+		return nil, err
+	}
+	var routes []KongRouteRespDto
+	for i := range objs {
+		record := &routeRecord{}
+		err = getRecord(&objs[i], record)
+		if err != nil {
+			return nil, err
+		}
+		routes = append(routes, record.KongRouteRespDto)
+	}
+	return routes, nil
+}
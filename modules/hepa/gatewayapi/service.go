@@ -0,0 +1,526 @@
+// Copyright (c) 2021 Terminus, Inc.
+//
+// This program is free software: you can use, redistribute, and/or modify
+// it under the terms of the GNU Affero General Public License, version 3
+// or later ("AGPL"), as published by the Free Software Foundation.
+//
+// This program is distributed in the hope that it will be useful, but WITHOUT
+// ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+// FITNESS FOR A PARTICULAR PURPOSE.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package gatewayapi
+
+import (
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	. "github.com/erda-project/erda/modules/hepa/common/vars"
+	. "github.com/erda-project/erda/modules/hepa/kong/dto"
+)
+
+// serviceRecord is kong service rendered as envoy gateway Backend
+type serviceRecord struct {
+	KongServiceRespDto
+	ConnectTimeout int `json:"connect_timeout,omitempty"`
+	ReadTimeout    int `json:"read_timeout,omitempty"`
+	WriteTimeout   int `json:"write_timeout,omitempty"`
+}
+
+// upstreamRecord is kong upstream rendered as envoy gateway Backend, with targets as endpoints
+type upstreamRecord struct {
+	KongUpstreamDto
+	Targets []KongTargetDto `json:"targets,omitempty"`
+}
+
+func serviceName(id string) string {
+	return "hepa-service-" + id
+}
+
+func upstreamName(id string) string {
+	return "hepa-upstream-" + id
+}
+
+func upstreamTargetName(id string, index int) string {
+	return upstreamName(id) + "-" + strconv.Itoa(index)
+}
+
+func newServiceRecord(req *KongServiceReqDto) (*serviceRecord, error) {
+	record := &serviceRecord{
+		KongServiceRespDto: KongServiceRespDto{
+			Name:     req.Name,
+			Protocol: req.Protocol,
+			Host:     req.Host,
+			Port:     req.Port,
+			Path:     req.Path,
+		},
+		ConnectTimeout: req.ConnectTimeout,
+		ReadTimeout:    req.ReadTimeout,
+		WriteTimeout:   req.WriteTimeout,
+	}
+	if req.Url != "" {
+		u, err := url.Parse(req.Url)
+		if err != nil || u.Hostname() == "" {
+			return nil, errors.Errorf("invalid service url:%s", req.Url)
+		}
+		record.Protocol = u.Scheme
+		record.Host = u.Hostname()
+		record.Path = u.Path
+		record.Port = 0
+		if port := u.Port(); port != "" {
+			record.Port, err = strconv.Atoi(port)
+			if err != nil {
+				return nil, errors.Errorf("invalid service url:%s", req.Url)
+			}
+		}
+	}
+	if record.Protocol == "" {
+		record.Protocol = "http"
+	}
+	if record.Port == 0 {
+		record.Port = 80
+		if record.Protocol == "https" || record.Protocol == "grpcs" {
+			record.Port = 443
+		}
+	}
+	return record, nil
+}
+
+func endpoint(host string, port int) map[string]interface{} {
+	if net.ParseIP(host) != nil {
+		return map[string]interface{}{
+			"ip": map[string]interface{}{"address": host, "port": port},
+		}
+	}
+	return map[string]interface{}{
+		"fqdn": map[string]interface{}{"hostname": host, "port": port},
+	}
+}
+
+func backendSpec(protocol string, endpoints []interface{}) map[string]interface{} {
+	spec := map[string]interface{}{
+		"endpoints": endpoints,
+	}
+	if protocol == "https" || protocol == "grpcs" {
+		// kong doesn't verify upstream certificates either
+		spec["tls"] = map[string]interface{}{"insecureSkipVerify": true}
+	}
+	if protocol == "grpc" {
+		// grpc upstream speaks http/2 without tls
+		spec["appProtocols"] = []interface{}{"gateway.envoyproxy.io/h2c"}
+	}
+	return spec
+}
+
+func (impl *Adapter) getService(id string) (*serviceRecord, error) {
+	obj, err := impl.get(backendResource, serviceName(id))
+	if err != nil {
+		return nil, err
+	}
+	if obj == nil {
+		return nil, nil
+	}
+	record := &serviceRecord{}
+	err = getRecord(obj, record)
+	if err != nil {
+		return nil, err
+	}
+	return record, nil
+}
+
+func (impl *Adapter) CreateOrUpdateService(req *KongServiceReqDto) (*KongServiceRespDto, error) {
+	if impl == nil {
+		return nil, errors.New("gateway can't be attached")
+	}
+	if req == nil || req.IsEmpty() {
+		return nil, errors.New(ERR_INVALID_ARG)
+	}
+	record, err := newServiceRecord(req)
+	if err != nil {
+		return nil, err
+	}
+	record.Id = req.ServiceId
+	record.CreatedAt = now()
+	if record.Id == "" {
+		record.Id = newId()
+	} else {
+		exist, err := impl.getService(record.Id)
+		if err != nil {
+			return nil, err
+		}
+		if exist != nil {
+			record.CreatedAt = exist.CreatedAt
+		}
+	}
+	record.UpdatedAt = now()
+	obj := impl.newObject(backendResource, serviceName(record.Id), map[string]string{
+		LabelServiceId: record.Id,
+	})
+	err = setSpec(obj, backendSpec(record.Protocol, []interface{}{endpoint(record.Host, record.Port)}))
+	if err != nil {
+		return nil, err
+	}
+	err = setRecord(obj, record)
+	if err != nil {
+		return nil, err
+	}
+	err = impl.apply(backendResource, obj)
+	if err != nil {
+		return nil, err
+	}
+	// routes carry the path and host of service
+	err = impl.syncRoutes(map[string]string{LabelServiceId: record.Id})
+	if err != nil {
+		return nil, err
+	}
+	resp := record.KongServiceRespDto
+	return &resp, nil
+}
+
+func (impl *Adapter) DeleteService(id string) error {
+	if impl == nil {
+		return errors.New("gateway can't be attached")
+	}
+	if len(id) == 0 {
+		return errors.New(ERR_INVALID_ARG)
+	}
+	return impl.remove(backendResource, serviceName(id))
+}
+
+func (impl *Adapter) getUpstream(id string) (*upstreamRecord, error) {
+	obj, err := impl.get(backendResource, upstreamName(id))
+	if err != nil {
+		return nil, err
+	}
+	if obj == nil {
+		return nil, nil
+	}
+	record := &upstreamRecord{}
+	err = getRecord(obj, record)
+	if err != nil {
+		return nil, err
+	}
+	return record, nil
+}
+
+// findUpstream returns the upstream named name, services use it by host like kong does
+func (impl *Adapter) findUpstream(name string) (*upstreamRecord, error) {
+	objs, err := impl.list(backendResource, nil)
+	if err != nil {
+		return nil, err
+	}
+	for i := range objs {
+		id := objs[i].GetLabels()[LabelUpstreamId]
+		// backends of weighted targets are labeled with the upstream too
+		if id == "" || objs[i].GetName() != upstreamName(id) {
+			continue
+		}
+		record := &upstreamRecord{}
+		err = getRecord(&objs[i], record)
+		if err != nil {
+			return nil, err
+		}
+		if record.Name == name {
+			return record, nil
+		}
+	}
+	return nil, nil
+}
+
+func (impl *Adapter) applyUpstream(record *upstreamRecord) error {
+	obj := impl.newObject(backendResource, upstreamName(record.Id), map[string]string{
+		LabelUpstreamId: record.Id,
+	})
+	var endpoints []interface{}
+	for _, target := range record.Targets {
+		// weight 0 disables the target in kong
+		if target.Weight == 0 {
+			continue
+		}
+		host, port, err := splitTarget(target.Target)
+		if err != nil {
+			return err
+		}
+		endpoints = append(endpoints, endpoint(host, port))
+	}
+	err := setSpec(obj, backendSpec("", endpoints))
+	if err != nil {
+		return err
+	}
+	err = setRecord(obj, record)
+	if err != nil {
+		return err
+	}
+	err = impl.apply(backendResource, obj)
+	if err != nil {
+		return err
+	}
+	err = impl.applyUpstreamTargets(record)
+	if err != nil {
+		return err
+	}
+	// routes carry the weights of targets
+	return impl.syncRoutes(map[string]string{LabelUpstreamId: record.Id})
+}
+
+// weighted reports whether the targets have different weights, envoy gateway
+// Backend has no endpoint weight, so they are rendered as weighted backendRefs
+func (record *upstreamRecord) weighted() bool {
+	var weight int64
+	for _, target := range record.Targets {
+		if target.Weight == 0 {
+			continue
+		}
+		if weight != 0 && target.Weight != weight {
+			return true
+		}
+		weight = target.Weight
+	}
+	return false
+}
+
+// applyUpstreamTargets keeps a Backend for each target of weighted upstream
+func (impl *Adapter) applyUpstreamTargets(record *upstreamRecord) error {
+	names := map[string]bool{upstreamName(record.Id): true}
+	if record.weighted() {
+		for i, target := range record.Targets {
+			if target.Weight == 0 {
+				continue
+			}
+			host, port, err := splitTarget(target.Target)
+			if err != nil {
+				return err
+			}
+			name := upstreamTargetName(record.Id, i)
+			obj := impl.newObject(backendResource, name, map[string]string{
+				LabelUpstreamId: record.Id,
+			})
+			err = setSpec(obj, backendSpec("", []interface{}{endpoint(host, port)}))
+			if err != nil {
+				return err
+			}
+			err = impl.apply(backendResource, obj)
+			if err != nil {
+				return err
+			}
+			names[name] = true
+		}
+	}
+	objs, err := impl.list(backendResource, map[string]string{LabelUpstreamId: record.Id})
+	if err != nil {
+		return err
+	}
+	for _, obj := range objs {
+		if names[obj.GetName()] {
+			continue
+		}
+		err = impl.remove(backendResource, obj.GetName())
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func splitTarget(target string) (string, int, error) {
+	host, portStr, err := net.SplitHostPort(target)
+	if err != nil {
+		// port of kong target defaults to 8000
+		return strings.Trim(target, "[]"), 8000, nil
+	}
+	port, err := strconv.Atoi(portStr)
+	if err != nil {
+		return "", 0, errors.Errorf("invalid target:%s", target)
+	}
+	return host, port, nil
+}
+
+func (impl *Adapter) CreateUpstream(req *KongUpstreamDto) (*KongUpstreamDto, error) {
+	if impl == nil {
+		return nil, errors.New("gateway can't be attached")
+	}
+	if req == nil || req.Name == "" {
+		return nil, errors.New(ERR_INVALID_ARG)
+	}
+	exist, err := impl.findUpstream(req.Name)
+	if err != nil {
+		return nil, err
+	}
+	if exist != nil {
+		return nil, errors.Errorf("CreateUpstream failed: upstream %s already exists", req.Name)
+	}
+	record := &upstreamRecord{KongUpstreamDto: *req}
+	record.Id = newId()
+	err = impl.applyUpstream(record)
+	if err != nil {
+		return nil, err
+	}
+	resp := record.KongUpstreamDto
+	return &resp, nil
+}
+
+// UpdateUpstream keeps healthchecks in the record only, envoy gateway Backend doesn't probe endpoints
+func (impl *Adapter) UpdateUpstream(req *KongUpstreamDto) (*KongUpstreamDto, error) {
+	if impl == nil {
+		return nil, errors.New("gateway can't be attached")
+	}
+	if req == nil || req.Id == "" {
+		return nil, errors.New(ERR_INVALID_ARG)
+	}
+	record, err := impl.getUpstream(req.Id)
+	if err != nil {
+		return nil, err
+	}
+	if record == nil {
+		return nil, errors.Errorf("UpdateUpstream failed: upstream %s not found", req.Id)
+	}
+	if req.Name != "" {
+		record.Name = req.Name
+	}
+	record.Healthchecks = req.Healthchecks
+	err = impl.applyUpstream(record)
+	if err != nil {
+		return nil, err
+	}
+	resp := record.KongUpstreamDto
+	return &resp, nil
+}
+
+func (impl *Adapter) DeleteUpstream(upstreamId string) error {
+	if impl == nil {
+		return errors.New("gateway can't be attached")
+	}
+	if upstreamId == "" {
+		return errors.New(ERR_INVALID_ARG)
+	}
+	objs, err := impl.list(backendResource, map[string]string{LabelUpstreamId: upstreamId})
+	if err != nil {
+		return err
+	}
+	for _, obj := range objs {
+		err = impl.remove(backendResource, obj.GetName())
+		if err != nil {
+			return err
+		}
+	}
+	return impl.syncRoutes(map[string]string{LabelUpstreamId: upstreamId})
+}
+
+func (impl *Adapter) GetUpstreamStatus(upstreamId string) (*KongUpstreamStatusRespDto, error) {
+	if impl == nil {
+		return nil, errors.New("gateway can't be attached")
+	}
+	if upstreamId == "" {
+		return nil, errors.New(ERR_INVALID_ARG)
+	}
+	record, err := impl.getUpstream(upstreamId)
+	if err != nil {
+		return nil, err
+	}
+	if record == nil {
+		return nil, errors.Errorf("GetUpstreamStatus failed: upstream %s not found", upstreamId)
+	}
+	resp := &KongUpstreamStatusRespDto{}
+	for _, target := range record.Targets {
+		// health of endpoints is not reported by gateway api
+		target.Health = "HEALTHCHECKS_OFF"
+		resp.Data = append(resp.Data, target)
+	}
+	return resp, nil
+}
+
+func (impl *Adapter) AddUpstreamTarget(upstreamId string, req *KongTargetDto) (*KongTargetDto, error) {
+	if impl == nil {
+		return nil, errors.New("gateway can't be attached")
+	}
+	if upstreamId == "" || req == nil || req.Target == "" {
+		return nil, errors.New(ERR_INVALID_ARG)
+	}
+	record, err := impl.getUpstream(upstreamId)
+	if err != nil {
+		return nil, err
+	}
+	if record == nil {
+		return nil, errors.Errorf("AddUpstreamTarget failed: upstream %s not found", upstreamId)
+	}
+	target := *req
+	target.Id = newId()
+	target.UpstreamId = upstreamId
+	target.CreatedAt = now()
+	if target.Weight == 0 {
+		target.Weight = 100
+	}
+	// the latest target entry takes effect in kong, so just replace it
+	targets := []KongTargetDto{target}
+	for _, exist := range record.Targets {
+		if exist.Target != target.Target {
+			targets = append(targets, exist)
+		}
+	}
+	record.Targets = targets
+	err = impl.applyUpstream(record)
+	if err != nil {
+		return nil, err
+	}
+	return &target, nil
+}
+
+// DeleteUpstreamTarget deletes target by id or address
+func (impl *Adapter) DeleteUpstreamTarget(upstreamId, targetId string) error {
+	if impl == nil {
+		return errors.New("gateway can't be attached")
+	}
+	if upstreamId == "" || targetId == "" {
+		return errors.New(ERR_INVALID_ARG)
+	}
+	record, err := impl.getUpstream(upstreamId)
+	if err != nil {
+		return err
+	}
+	if record == nil {
+		return nil
+	}
+	var targets []KongTargetDto
+	for _, target := range record.Targets {
+		if target.Id != targetId && target.Target != targetId {
+			targets = append(targets, target)
+		}
+	}
+	if len(targets) == len(record.Targets) {
+		return nil
+	}
+	record.Targets = targets
+	return impl.applyUpstream(record)
+}
+
+// backendRefs returns the backends routes of service forward to, with the upstream they belong to
+func (impl *Adapter) backendRefs(service *serviceRecord) ([]interface{}, *upstreamRecord, error) {
+	upstream, err := impl.findUpstream(service.Host)
+	if err != nil {
+		return nil, nil, err
+	}
+	if upstream == nil {
+		ref := objectRef(backendResource, serviceName(service.Id))
+		ref["port"] = service.Port
+		return []interface{}{ref}, nil, nil
+	}
+	if !upstream.weighted() {
+		return []interface{}{objectRef(backendResource, upstreamName(upstream.Id))}, upstream, nil
+	}
+	var refs []interface{}
+	for i, target := range upstream.Targets {
+		if target.Weight == 0 {
+			continue
+		}
+		ref := objectRef(backendResource, upstreamTargetName(upstream.Id, i))
+		ref["weight"] = target.Weight
+		refs = append(refs, ref)
+	}
+	return refs, upstream, nil
+}
@@ -51,6 +51,11 @@ func (impl *KongAdapterImpl) KongExist() bool {
 	return impl != nil
 }
 
+// IngressPolicyEnforced is true since requests come to kong through the nginx ingress
+func (impl *KongAdapterImpl) IngressPolicyEnforced() bool {
+	return true
+}
+
 func (impl *KongAdapterImpl) CreateConsumer(req *KongConsumerReqDto) (*KongConsumerRespDto, error) {
 	if impl == nil {
 		return nil, errors.New("kong can't be attached")
@@ -290,6 +295,29 @@ func (impl *KongAdapterImpl) GetPlugin(req *KongPluginReqDto) (*KongPluginRespDt
 	return nil, errors.Errorf("get plugin failed: code[%d] msg[%s]", code, body)
 }
 
+func (impl *KongAdapterImpl) GetRoutePlugins(routeId string) ([]KongPluginRespDto, error) {
+	if impl == nil {
+		return nil, errors.New("kong can't be attached")
+	}
+	if routeId == "" {
+		return nil, errors.New(ERR_INVALID_ARG)
+	}
+	code, body, err := util.DoCommonRequest(impl.Client, "GET",
+		impl.KongAddr+PluginRoot+"?route_id="+routeId, nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "request failed")
+	}
+	if code == 200 {
+		respDto := &KongPluginsDto{}
+		err = json.Unmarshal(body, respDto)
+		if err != nil {
+			return nil, errors.Wrap(err, ERR_JSON_FAIL)
+		}
+		return respDto.Data, nil
+	}
+	return nil, errors.Errorf("get route plugins failed: code[%d] msg[%s]", code, body)
+}
+
 func (impl *KongAdapterImpl) CreateOrUpdatePluginById(req *KongPluginReqDto) (*KongPluginRespDto, error) {
 	if impl == nil {
 		return nil, errors.New("kong can't be attached")
@@ -625,6 +653,48 @@ func (impl *KongAdapterImpl) CreateUpstream(req *KongUpstreamDto) (*KongUpstream
 	return nil, errors.Errorf("CreateUpstream failed: code[%d] msg[%s]", code, body)
 }
 
+// UpdateUpstream patches name and healthchecks of upstream req.Id
+func (impl *KongAdapterImpl) UpdateUpstream(req *KongUpstreamDto) (*KongUpstreamDto, error) {
+	if impl == nil {
+		return nil, errors.New("kong can't be attached")
+	}
+	if req == nil || req.Id == "" {
+		return nil, errors.New(ERR_INVALID_ARG)
+	}
+	body := *req
+	body.Id = ""
+	code, respBody, err := util.DoCommonRequest(impl.Client, "PATCH", impl.KongAddr+UpstreamRoot+req.Id, &body)
+	if err != nil {
+		return nil, errors.Wrap(err, "request failed")
+	}
+	if code == 200 {
+		respDto := &KongUpstreamDto{}
+		err = json.Unmarshal(respBody, respDto)
+		if err != nil {
+			return nil, errors.Wrapf(err, "unmarshal body failed, body:%s", respBody)
+		}
+		return respDto, nil
+	}
+	return nil, errors.Errorf("UpdateUpstream failed: code[%d] msg[%s]", code, respBody)
+}
+
+func (impl *KongAdapterImpl) DeleteUpstream(upstreamId string) error {
+	if impl == nil {
+		return errors.New("kong can't be attached")
+	}
+	if upstreamId == "" {
+		return errors.New(ERR_INVALID_ARG)
+	}
+	code, body, err := util.DoCommonRequest(impl.Client, "DELETE", impl.KongAddr+UpstreamRoot+upstreamId, nil)
+	if err != nil {
+		return errors.Wrap(err, "request failed")
+	}
+	if code == 204 || code == 404 {
+		return nil
+	}
+	return errors.Errorf("DeleteUpstream failed: code[%d] msg[%s]", code, body)
+}
+
 func (impl *KongAdapterImpl) GetUpstreamStatus(upstreamId string) (*KongUpstreamStatusRespDto, error) {
 	if impl == nil {
 		return nil, errors.New("kong can't be attached")
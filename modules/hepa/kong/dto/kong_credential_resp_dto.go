@@ -35,6 +35,9 @@ type KongCredentialDto struct {
 	Secret string `json:"secret,omitempty"`
 	// hmac-auth
 	Username string `json:"username,omitempty"`
+	// jwt
+	Algorithm    string `json:"algorithm,omitempty"`
+	RsaPublicKey string `json:"rsa_public_key,omitempty"`
 }
 
 func (dto *KongCredentialDto) ToHmacReq() {
@@ -41,6 +41,11 @@ type KongRouteReqDto struct {
 	Service *Service `json:"service,omitempty"`
 	// 正则匹配优先级,当前使用路径中/的个数
 	RegexPriority int `json:"regex_priority,omitempty"`
+	// 选填，请求头匹配，同一请求头的多个值满足其一即可，kong 1.3 及以上版本支持
+	// 以~*开头的值按正则匹配，kong 3.x 及以上版本支持
+	Headers map[string][]string `json:"headers,omitempty"`
+	// 选填，路由名称，kong 1.0 及以上版本支持，可以代替路由id进行更新和删除
+	Name string `json:"name,omitempty"`
 	// 真正的路由id，更新时使用
 	RouteId string `json:"-"`
 }
@@ -14,14 +14,15 @@
 package dto
 
 type KongRouteRespDto struct {
-	Id        string   `json:"id"`
-	CreatedAt int64    `json:"created_at"`
-	UpdatedAt int64    `json:"updated_at"`
-	Protocols []string `json:"protocols"`
-	Methods   []string `json:"methods"`
-	Hosts     []string `json:"hosts"`
-	Paths     []string `json:"paths"`
-	Service   Service  `json:"service"`
+	Id        string              `json:"id"`
+	CreatedAt int64               `json:"created_at"`
+	UpdatedAt int64               `json:"updated_at"`
+	Protocols []string            `json:"protocols"`
+	Methods   []string            `json:"methods"`
+	Hosts     []string            `json:"hosts"`
+	Paths     []string            `json:"paths"`
+	Headers   map[string][]string `json:"headers,omitempty"`
+	Service   Service             `json:"service"`
 }
 
 type KongRoutesRespDto struct {
@@ -17,33 +17,65 @@ import (
 	. "github.com/erda-project/erda/modules/hepa/kong/dto"
 )
 
-type KongAdapter interface {
-	KongExist() bool
-	GetVersion() (string, error)
-	CheckPluginEnabled(pluginName string) (bool, error)
-	CreateConsumer(req *KongConsumerReqDto) (*KongConsumerRespDto, error)
-	DeleteConsumer(string) error
+// RouteBackend manages the services and routes apis are proxied by
+type RouteBackend interface {
 	CreateOrUpdateRoute(req *KongRouteReqDto) (*KongRouteRespDto, error)
 	DeleteRoute(string) error
 	UpdateRoute(req *KongRouteReqDto) (*KongRouteRespDto, error)
+	GetRoutes() ([]KongRouteRespDto, error)
+	TouchRouteOAuthMethod(string) error
 	CreateOrUpdateService(req *KongServiceReqDto) (*KongServiceRespDto, error)
 	DeleteService(string) error
+}
+
+// UpstreamBackend manages the upstreams and targets of services
+type UpstreamBackend interface {
+	CreateUpstream(req *KongUpstreamDto) (*KongUpstreamDto, error)
+	UpdateUpstream(req *KongUpstreamDto) (*KongUpstreamDto, error)
+	DeleteUpstream(string) error
+	GetUpstreamStatus(string) (*KongUpstreamStatusRespDto, error)
+	AddUpstreamTarget(string, *KongTargetDto) (*KongTargetDto, error)
+	DeleteUpstreamTarget(string, string) error
+}
+
+// PluginBackend manages plugins in kong's model, which are global, or on services, routes and consumers
+type PluginBackend interface {
+	CheckPluginEnabled(pluginName string) (bool, error)
 	DeletePluginIfExist(req *KongPluginReqDto) error
 	CreateOrUpdatePlugin(req *KongPluginReqDto) (*KongPluginRespDto, error)
 	CreateOrUpdatePluginById(req *KongPluginReqDto) (*KongPluginRespDto, error)
 	GetPlugin(req *KongPluginReqDto) (*KongPluginRespDto, error)
+	GetRoutePlugins(string) ([]KongPluginRespDto, error)
 	AddPlugin(req *KongPluginReqDto) (*KongPluginRespDto, error)
 	UpdatePlugin(req *KongPluginReqDto) (*KongPluginRespDto, error)
 	PutPlugin(req *KongPluginReqDto) (*KongPluginRespDto, error)
 	RemovePlugin(string) error
+}
+
+// ConsumerBackend manages consumers with their credentials and acl groups
+type ConsumerBackend interface {
+	CreateConsumer(req *KongConsumerReqDto) (*KongConsumerRespDto, error)
+	DeleteConsumer(string) error
 	CreateCredential(req *KongCredentialReqDto) (*KongCredentialDto, error)
 	DeleteCredential(string, string, string) error
 	GetCredentialList(string, string) (*KongCredentialListDto, error)
 	CreateAclGroup(string, string) error
-	CreateUpstream(req *KongUpstreamDto) (*KongUpstreamDto, error)
-	GetUpstreamStatus(string) (*KongUpstreamStatusRespDto, error)
-	AddUpstreamTarget(string, *KongTargetDto) (*KongTargetDto, error)
-	DeleteUpstreamTarget(string, string) error
-	TouchRouteOAuthMethod(string) error
-	GetRoutes() ([]KongRouteRespDto, error)
 }
+
+// GatewayBackend is the gateway hepa renders apis, consumers and policies into,
+// implemented by kong (base, v2), and by gateway api (gatewayapi) for clusters without kong
+type GatewayBackend interface {
+	KongExist() bool
+	GetVersion() (string, error)
+	// IngressPolicyEnforced returns whether the api policies rendered into nginx ingress take effect,
+	// requests of kong pass through the ingress, while the other backends are exposed directly,
+	// the policies are rendered into plugins by apipolicy.PluginRender for them
+	IngressPolicyEnforced() bool
+	RouteBackend
+	UpstreamBackend
+	PluginBackend
+	ConsumerBackend
+}
+
+// KongAdapter is kept for the callers written before the backends besides kong
+type KongAdapter = GatewayBackend
@@ -18,6 +18,7 @@ import (
 	"strings"
 
 	"github.com/erda-project/erda/modules/hepa/config"
+	"github.com/erda-project/erda/modules/hepa/gatewayapi"
 	"github.com/erda-project/erda/modules/hepa/kong/base"
 	v2 "github.com/erda-project/erda/modules/hepa/kong/v2"
 	"github.com/erda-project/erda/modules/hepa/repository/orm"
@@ -44,8 +45,19 @@ var (
 	ErrInvalidReq = errors.New("kongAdapter: invalid request")
 )
 
+var _ KongAdapter = &gatewayapi.Adapter{}
+
 func newKongAdapter(kongAddr string, client *http.Client) KongAdapter {
 	var empty *base.KongAdapterImpl
+	// clusters without kong use gateway api as the backend
+	if gatewayapi.IsGatewayApiAddr(kongAddr) {
+		adapter, err := gatewayapi.NewAdapter(kongAddr)
+		if err != nil {
+			log.Errorf("create gateway api adapter failed, addr:%s, err:%+v", kongAddr, err)
+			return empty
+		}
+		return adapter
+	}
 	base := &base.KongAdapterImpl{
 		KongAddr: kongAddr,
 		Client:   client,
@@ -89,6 +89,32 @@ func (impl *KongAdapterImpl) GetPlugin(req *KongPluginReqDto) (*KongPluginRespDt
 	return nil, errors.Errorf("get plugin failed: code[%d] msg[%s]", code, body)
 }
 
+func (impl *KongAdapterImpl) GetRoutePlugins(routeId string) ([]KongPluginRespDto, error) {
+	if impl == nil {
+		return nil, errors.New("kong can't be attached")
+	}
+	if routeId == "" {
+		return nil, errors.New(ERR_INVALID_ARG)
+	}
+	code, body, err := util.DoCommonRequest(impl.Client, "GET",
+		impl.KongAddr+RouteRoot+routeId+PluginRoot, nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "request failed")
+	}
+	if code == 200 {
+		respDto := &KongPluginsDto{}
+		err = json.Unmarshal(body, respDto)
+		if err != nil {
+			return nil, errors.Wrap(err, ERR_JSON_FAIL)
+		}
+		for i := range respDto.Data {
+			respDto.Data[i].Compatiable()
+		}
+		return respDto.Data, nil
+	}
+	return nil, errors.Errorf("get route plugins failed: code[%d] msg[%s]", code, body)
+}
+
 func (impl *KongAdapterImpl) CreateOrUpdatePluginById(req *KongPluginReqDto) (*KongPluginRespDto, error) {
 	if impl == nil {
 		return nil, errors.New("kong can't be attached")
@@ -302,3 +328,28 @@ func (impl *KongAdapterImpl) GetCredentialList(consumerId, pluginName string) (*
 	}
 	return nil, errors.Errorf("GetCredentialList failed: code[%d] msg[%s]", code, body)
 }
+
+// UpdateUpstream replaces upstream req.Id, thresholds omitted in healthchecks go back to defaults
+func (impl *KongAdapterImpl) UpdateUpstream(req *KongUpstreamDto) (*KongUpstreamDto, error) {
+	if impl == nil {
+		return nil, errors.New("kong can't be attached")
+	}
+	if req == nil || req.Id == "" {
+		return nil, errors.New(ERR_INVALID_ARG)
+	}
+	body := *req
+	body.Id = ""
+	code, respBody, err := util.DoCommonRequest(impl.Client, "PUT", impl.KongAddr+UpstreamRoot+req.Id, &body)
+	if err != nil {
+		return nil, errors.Wrap(err, "request failed")
+	}
+	if code == 200 || code == 201 {
+		respDto := &KongUpstreamDto{}
+		err = json.Unmarshal(respBody, respDto)
+		if err != nil {
+			return nil, errors.Wrapf(err, "unmarshal body failed, body:%s", respBody)
+		}
+		return respDto, nil
+	}
+	return nil, errors.Errorf("UpdateUpstream failed: code[%d] msg[%s]", code, respBody)
+}
@@ -23,9 +23,11 @@ import (
 	_ "github.com/erda-project/erda-infra/providers/health"
 	"github.com/erda-project/erda/modules/hepa/common"
 	"github.com/erda-project/erda/modules/hepa/config"
+	"github.com/erda-project/erda/modules/hepa/metrics"
 	"github.com/erda-project/erda/modules/hepa/repository/orm"
 	"github.com/erda-project/erda/modules/hepa/server"
 	"github.com/erda-project/erda/modules/hepa/ver"
+	"github.com/erda-project/erda/providers/metrics/query"
 )
 
 type myCfg struct {
@@ -34,8 +36,9 @@ type myCfg struct {
 }
 
 type provider struct {
-	Cfg *myCfg      // auto inject this field
-	Log logs.Logger // auto inject this field
+	Cfg         *myCfg            // auto inject this field
+	Log         logs.Logger       // auto inject this field
+	QueryClient query.MetricQuery `autowired:"metricq-client"`
 }
 
 func (p *provider) Init(ctx servicehub.Context) error {
@@ -43,6 +46,7 @@ func (p *provider) Init(ctx servicehub.Context) error {
 	config.LogConf = &p.Cfg.Log
 	common.InitLogger()
 	orm.Init()
+	metrics.Client = p.QueryClient
 	logrus.Infof("server conf: %+v", config.ServerConf)
 	logrus.Infof("log conf: %+v", config.LogConf)
 	return nil
@@ -75,11 +75,22 @@ type BaseRow struct {
 	mustCondCols map[string]bool `json:"-" xorm:"-"`
 }
 
+// Row 是新建表的公共字段，字段类型遵循数据库迁移的规范，
+// 批量查询的方法只按 BaseRow 的逻辑删除值过滤，Row 的表只能使用单行操作的方法
+type Row struct {
+	Id        string    `json:"id" xorm:"not null pk default '' comment('唯一id') VARCHAR(32)"`
+	IsDeleted bool      `json:"is_deleted" xorm:"not null default 0 comment('逻辑删除') TINYINT(1)"`
+	CreatedAt time.Time `json:"created_at" xorm:"not null default 'CURRENT_TIMESTAMP' comment('创建时间') DATETIME"`
+	UpdatedAt time.Time `json:"updated_at" xorm:"not null default 'CURRENT_TIMESTAMP' comment('更新时间') DATETIME"`
+}
+
 type BaseAbility interface {
 	SetDeleted()
 	SetRecover()
 	GetMustCondCols() map[string]bool
 	GetPK() map[string]interface{}
+	NotDeletedValue() interface{}
+	InnerCols() []string
 }
 
 func (row *BaseRow) GetPK() map[string]interface{} {
@@ -133,6 +144,60 @@ func (row *BaseRow) SetRecover() {
 	row.UpdateTime = time.Now()
 }
 
+func (row *BaseRow) NotDeletedValue() interface{} {
+	return NOT_DELETED_VALUE
+}
+
+func (row *BaseRow) InnerCols() []string {
+	return []string{"is_deleted", "update_time"}
+}
+
+func (row *Row) GetPK() map[string]interface{} {
+	res := map[string]interface{}{}
+	res["id"] = row.Id
+	return res
+}
+
+func (row *Row) GetMustCondCols() map[string]bool {
+	return nil
+}
+
+func (row *Row) BeforeInsert() {
+	if len(row.Id) == 0 {
+		uuid, err := uuid.NewRandom()
+		if err != nil {
+			log.Errorf("uuid generate failed:%s", err)
+			return
+		}
+		row.Id = strings.Replace(uuid.String(), "-", "", -1)
+	}
+	row.IsDeleted = false
+	row.CreatedAt = time.Now()
+	row.UpdatedAt = time.Now()
+}
+
+func (row *Row) BeforeUpdate() {
+	row.UpdatedAt = time.Now()
+}
+
+func (row *Row) SetDeleted() {
+	row.IsDeleted = true
+	row.UpdatedAt = time.Now()
+}
+
+func (row *Row) SetRecover() {
+	row.IsDeleted = false
+	row.UpdatedAt = time.Now()
+}
+
+func (row *Row) NotDeletedValue() interface{} {
+	return false
+}
+
+func (row *Row) InnerCols() []string {
+	return []string{"is_deleted", "updated_at"}
+}
+
 func Insert(engine xorm.Interface, rows BaseAbility) (int64, error) {
 	// rowSlice := make([]interface{}, len(rows))
 	// for _, row := range rows {
@@ -165,10 +230,10 @@ func In(engine xorm.Interface, colName string, colValues interface{}) *xorm.Sess
 }
 
 func Count(engine xorm.Interface, row BaseAbility, cond interface{}, condArgs ...interface{}) (int64, error) {
-	return engine.Where(cond, condArgs...).And("is_deleted = ?", NOT_DELETED_VALUE).Count(row)
+	return engine.Where(cond, condArgs...).And("is_deleted = ?", row.NotDeletedValue()).Count(row)
 }
 func Get(engine xorm.Interface, row BaseAbility, cond interface{}, condArgs ...interface{}) (bool, error) {
-	return engine.Where(cond, condArgs...).And("is_deleted = ?", NOT_DELETED_VALUE).Get(row)
+	return engine.Where(cond, condArgs...).And("is_deleted = ?", row.NotDeletedValue()).Get(row)
 }
 
 func GetForUpdate(session *xorm.Session, engine *OrmEngine, row BaseAbility, cond string, condArgs ...interface{}) (bool, error) {
@@ -176,7 +241,7 @@ func GetForUpdate(session *xorm.Session, engine *OrmEngine, row BaseAbility, con
 }
 
 func GetByAnyI(engine xorm.Interface, bCond builder.Cond, row BaseAbility) (bool, error) {
-	return engine.Where(bCond).And("is_deleted = ?", NOT_DELETED_VALUE).Get(row)
+	return engine.Where(bCond).And("is_deleted = ?", row.NotDeletedValue()).Get(row)
 }
 
 func GetRawByAnyI(engine xorm.Interface, bCond builder.Cond, row BaseAbility) (bool, error) {
@@ -200,7 +265,7 @@ func SelectByAnyI(engine xorm.Interface, bCond builder.Cond, rows interface{}, d
 
 func DeleteByAnyI(engine xorm.Interface, bCond builder.Cond, row BaseAbility) (int64, error) {
 	row.SetDeleted()
-	return engine.Cols("is_deleted").Where(bCond).And("is_deleted = ?", NOT_DELETED_VALUE).Update(row)
+	return engine.Cols("is_deleted").Where(bCond).And("is_deleted = ?", row.NotDeletedValue()).Update(row)
 }
 
 func SelectByAny(engine *OrmEngine, rows interface{}, cond BaseAbility, descColumn ...string) error {
@@ -304,11 +369,11 @@ func SelectWithOption(options []SelectOption, engine xorm.Interface, rows interf
 }
 
 func CountWithOption(options []SelectOption, engine xorm.Interface, row BaseAbility) (int64, error) {
-	return ParseSelectOptions(options, engine).Where("is_deleted = ?", NOT_DELETED_VALUE).Count(row)
+	return ParseSelectOptions(options, engine).Where("is_deleted = ?", row.NotDeletedValue()).Count(row)
 }
 
 func update_cols(engine xorm.Interface, row BaseAbility, columns ...string) *xorm.Session {
-	innerCols := []string{"is_deleted", "update_time"}
+	innerCols := row.InnerCols()
 	if len(columns) != 0 {
 		columns = append(columns, innerCols...)
 		return engine.Cols(columns...)
@@ -321,7 +386,7 @@ func update_cols(engine xorm.Interface, row BaseAbility, columns ...string) *xor
 	copy(colSlice, innerCols)
 	for i := 0; i < count; i++ {
 		fieldName := getType.Field(i).Name
-		if fieldName == "BaseRow" {
+		if fieldName == "BaseRow" || fieldName == "Row" {
 			continue
 		}
 		if _, ok := pk[fieldName]; ok {
@@ -351,7 +416,7 @@ func Update(engine xorm.Interface, row BaseAbility, columns ...string) (int64, e
 
 func Delete(engine xorm.Interface, row BaseAbility, cond interface{}, condArgs ...interface{}) (int64, error) {
 	row.SetDeleted()
-	return engine.Cols("is_deleted").Where(cond, condArgs...).And("is_deleted = ?", NOT_DELETED_VALUE).Update(row)
+	return engine.Cols("is_deleted").Where(cond, condArgs...).And("is_deleted = ?", row.NotDeletedValue()).Update(row)
 }
 
 func RealDelete(engine xorm.Interface, row BaseAbility, cond interface{}, condArgs ...interface{}) (int64, error) {
@@ -0,0 +1,23 @@
+// Copyright (c) 2021 Terminus, Inc.
+//
+// This program is free software: you can use, redistribute, and/or modify
+// it under the terms of the GNU Affero General Public License, version 3
+// or later ("AGPL"), as published by the Free Software Foundation.
+//
+// This program is distributed in the hope that it will be useful, but WITHOUT
+// ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+// FITNESS FOR A PARTICULAR PURPOSE.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package orm
+
+type GatewayConsumerQuota struct {
+	ConsumerId string `json:"consumer_id" xorm:"not null default '' comment('消费者id') index VARCHAR(32)"`
+	Period     string `json:"period" xorm:"not null default '' comment('计费周期') VARCHAR(16)"`
+	Quota      int64  `json:"quota" xorm:"not null default 0 comment('周期内请求配额') BIGINT(20)"`
+	Burst      int    `json:"burst" xorm:"not null default 0 comment('每秒突发请求数') INT(11)"`
+	PluginId   string `json:"plugin_id" xorm:"not null default '' comment('kong的插件id') VARCHAR(128)"`
+	Row        `xorm:"extends"`
+}
@@ -0,0 +1,22 @@
+// Copyright (c) 2021 Terminus, Inc.
+//
+// This program is free software: you can use, redistribute, and/or modify
+// it under the terms of the GNU Affero General Public License, version 3
+// or later ("AGPL"), as published by the Free Software Foundation.
+//
+// This program is distributed in the hope that it will be useful, but WITHOUT
+// ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+// FITNESS FOR A PARTICULAR PURPOSE.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package orm
+
+type GatewayMockSpec struct {
+	Az             string `json:"az" xorm:"not null default '' comment('集群名') VARCHAR(128)"`
+	HeadKey        string `json:"head_key" xorm:"not null default '' comment('mock标识') unique VARCHAR(191)"`
+	Spec           string `json:"spec" xorm:"not null comment('OpenAPI 接口规范') MEDIUMTEXT"`
+	IsSkipValidate bool   `json:"is_skip_validate" xorm:"not null default 0 comment('是否跳过请求校验') TINYINT(1)"`
+	Row            `xorm:"extends"`
+}
@@ -29,5 +29,7 @@ type GatewayPackageApi struct {
 	RuntimeServiceId string `json:"runtime_service_id" xorm:"not null default '' comment('关联的service的id') VARCHAR(32)"`
 	ZoneId           string `json:"zone_id" xorm:"comment('所属的zone') VARCHAR(32)"`
 	CloudapiApiId    string `json:"cloudapi_api_id" xorm:"not null default '' comment('阿里云API网关的api id') VARCHAR(128)"`
+	Protocol         string `json:"protocol" xorm:"not null default 'http' comment('路由协议') VARCHAR(16)"`
+	ProtocolConfig   string `json:"protocol_config" xorm:"not null comment('协议相关配置，如grpc的proto描述和websocket的空闲超时') MEDIUMTEXT"`
 	BaseRow          `xorm:"extends"`
 }
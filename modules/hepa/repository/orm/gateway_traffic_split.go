@@ -0,0 +1,23 @@
+// Copyright (c) 2021 Terminus, Inc.
+//
+// This program is free software: you can use, redistribute, and/or modify
+// it under the terms of the GNU Affero General Public License, version 3
+// or later ("AGPL"), as published by the Free Software Foundation.
+//
+// This program is distributed in the hope that it will be useful, but WITHOUT
+// ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+// FITNESS FOR A PARTICULAR PURPOSE.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package orm
+
+type GatewayTrafficSplit struct {
+	PackageId      string `json:"package_id" xorm:"not null default '' comment('所属的流量入口') VARCHAR(32)"`
+	ApiId          string `json:"api_id" xorm:"not null default '' comment('所属的流量入口api') index VARCHAR(32)"`
+	KongUpstreamId string `json:"kong_upstream_id" xorm:"not null default '' comment('kong的upstream_id') VARCHAR(128)"`
+	Config         string `json:"config" xorm:"not null comment('分流配置') TEXT"`
+	KongRoutes     string `json:"kong_routes" xorm:"not null comment('按规则分流的kong路由和服务') TEXT"`
+	Row            `xorm:"extends"`
+}
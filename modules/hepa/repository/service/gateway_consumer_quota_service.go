@@ -0,0 +1,81 @@
+// Copyright (c) 2021 Terminus, Inc.
+//
+// This program is free software: you can use, redistribute, and/or modify
+// it under the terms of the GNU Affero General Public License, version 3
+// or later ("AGPL"), as published by the Free Software Foundation.
+//
+// This program is distributed in the hope that it will be useful, but WITHOUT
+// ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+// FITNESS FOR A PARTICULAR PURPOSE.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package service
+
+import (
+	. "github.com/erda-project/erda/modules/hepa/common/vars"
+	"github.com/erda-project/erda/modules/hepa/repository/orm"
+
+	"github.com/pkg/errors"
+)
+
+type GatewayConsumerQuotaServiceImpl struct {
+	engine *orm.OrmEngine
+}
+
+func NewGatewayConsumerQuotaServiceImpl() (*GatewayConsumerQuotaServiceImpl, error) {
+	engine, err := orm.GetSingleton()
+	if err != nil {
+		return nil, errors.Wrap(err, "new GatewayConsumerQuotaServiceImpl failed")
+	}
+	return &GatewayConsumerQuotaServiceImpl{engine}, nil
+}
+
+func (impl GatewayConsumerQuotaServiceImpl) Insert(dao *orm.GatewayConsumerQuota) error {
+	if dao == nil {
+		return errors.New(ERR_INVALID_ARG)
+	}
+	_, err := orm.Insert(impl.engine, dao)
+	if err != nil {
+		return errors.Wrap(err, ERR_SQL_FAIL)
+	}
+	return nil
+}
+
+func (impl GatewayConsumerQuotaServiceImpl) Update(dao *orm.GatewayConsumerQuota) error {
+	if dao == nil || dao.Id == "" {
+		return errors.New(ERR_INVALID_ARG)
+	}
+	_, err := orm.Update(impl.engine, dao, "period", "quota", "burst", "plugin_id")
+	if err != nil {
+		return errors.Wrap(err, ERR_SQL_FAIL)
+	}
+	return nil
+}
+
+func (impl GatewayConsumerQuotaServiceImpl) DeleteById(id string) error {
+	if id == "" {
+		return errors.New(ERR_INVALID_ARG)
+	}
+	_, err := orm.Delete(impl.engine, &orm.GatewayConsumerQuota{}, "id = ?", id)
+	if err != nil {
+		return errors.Wrap(err, ERR_SQL_FAIL)
+	}
+	return nil
+}
+
+func (impl GatewayConsumerQuotaServiceImpl) GetByConsumerId(consumerId string) (*orm.GatewayConsumerQuota, error) {
+	if consumerId == "" {
+		return nil, errors.New(ERR_INVALID_ARG)
+	}
+	dao := &orm.GatewayConsumerQuota{}
+	succ, err := orm.Get(impl.engine, dao, "consumer_id = ?", consumerId)
+	if err != nil {
+		return nil, errors.Wrap(err, ERR_SQL_FAIL)
+	}
+	if !succ {
+		return nil, nil
+	}
+	return dao, nil
+}
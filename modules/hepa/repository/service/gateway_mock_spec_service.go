@@ -0,0 +1,70 @@
+// Copyright (c) 2021 Terminus, Inc.
+//
+// This program is free software: you can use, redistribute, and/or modify
+// it under the terms of the GNU Affero General Public License, version 3
+// or later ("AGPL"), as published by the Free Software Foundation.
+//
+// This program is distributed in the hope that it will be useful, but WITHOUT
+// ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+// FITNESS FOR A PARTICULAR PURPOSE.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package service
+
+import (
+	. "github.com/erda-project/erda/modules/hepa/common/vars"
+	"github.com/erda-project/erda/modules/hepa/repository/orm"
+
+	"github.com/pkg/errors"
+)
+
+type GatewayMockSpecServiceImpl struct {
+	engine *orm.OrmEngine
+}
+
+func NewGatewayMockSpecServiceImpl() (*GatewayMockSpecServiceImpl, error) {
+	engine, err := orm.GetSingleton()
+	if err != nil {
+		return nil, errors.Wrap(err, "new GatewayMockSpecServiceImpl failed")
+	}
+	return &GatewayMockSpecServiceImpl{engine}, nil
+}
+
+func (impl GatewayMockSpecServiceImpl) Insert(dao *orm.GatewayMockSpec) error {
+	if dao == nil {
+		return errors.New(ERR_INVALID_ARG)
+	}
+	_, err := orm.Insert(impl.engine, dao)
+	if err != nil {
+		return errors.Wrap(err, ERR_SQL_FAIL)
+	}
+	return nil
+}
+
+func (impl GatewayMockSpecServiceImpl) Update(dao *orm.GatewayMockSpec) error {
+	if dao == nil || dao.Id == "" {
+		return errors.New(ERR_INVALID_ARG)
+	}
+	_, err := orm.Update(impl.engine, dao, "az", "spec", "is_skip_validate")
+	if err != nil {
+		return errors.Wrap(err, ERR_SQL_FAIL)
+	}
+	return nil
+}
+
+func (impl GatewayMockSpecServiceImpl) GetByHeadKey(headKey string) (*orm.GatewayMockSpec, error) {
+	if headKey == "" {
+		return nil, errors.New(ERR_INVALID_ARG)
+	}
+	dao := &orm.GatewayMockSpec{}
+	succ, err := orm.Get(impl.engine, dao, "head_key = ?", headKey)
+	if err != nil {
+		return nil, errors.Wrap(err, ERR_SQL_FAIL)
+	}
+	if !succ {
+		return nil, nil
+	}
+	return dao, nil
+}
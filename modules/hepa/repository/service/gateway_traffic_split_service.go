@@ -0,0 +1,81 @@
+// Copyright (c) 2021 Terminus, Inc.
+//
+// This program is free software: you can use, redistribute, and/or modify
+// it under the terms of the GNU Affero General Public License, version 3
+// or later ("AGPL"), as published by the Free Software Foundation.
+//
+// This program is distributed in the hope that it will be useful, but WITHOUT
+// ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+// FITNESS FOR A PARTICULAR PURPOSE.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package service
+
+import (
+	. "github.com/erda-project/erda/modules/hepa/common/vars"
+	"github.com/erda-project/erda/modules/hepa/repository/orm"
+
+	"github.com/pkg/errors"
+)
+
+type GatewayTrafficSplitServiceImpl struct {
+	engine *orm.OrmEngine
+}
+
+func NewGatewayTrafficSplitServiceImpl() (*GatewayTrafficSplitServiceImpl, error) {
+	engine, err := orm.GetSingleton()
+	if err != nil {
+		return nil, errors.Wrap(err, "new GatewayTrafficSplitServiceImpl failed")
+	}
+	return &GatewayTrafficSplitServiceImpl{engine}, nil
+}
+
+func (impl GatewayTrafficSplitServiceImpl) Insert(dao *orm.GatewayTrafficSplit) error {
+	if dao == nil {
+		return errors.New(ERR_INVALID_ARG)
+	}
+	_, err := orm.Insert(impl.engine, dao)
+	if err != nil {
+		return errors.Wrap(err, ERR_SQL_FAIL)
+	}
+	return nil
+}
+
+func (impl GatewayTrafficSplitServiceImpl) Update(dao *orm.GatewayTrafficSplit) error {
+	if dao == nil || dao.Id == "" {
+		return errors.New(ERR_INVALID_ARG)
+	}
+	_, err := orm.Update(impl.engine, dao, "kong_upstream_id", "config", "kong_routes")
+	if err != nil {
+		return errors.Wrap(err, ERR_SQL_FAIL)
+	}
+	return nil
+}
+
+func (impl GatewayTrafficSplitServiceImpl) DeleteById(id string) error {
+	if id == "" {
+		return errors.New(ERR_INVALID_ARG)
+	}
+	_, err := orm.Delete(impl.engine, &orm.GatewayTrafficSplit{}, "id = ?", id)
+	if err != nil {
+		return errors.Wrap(err, ERR_SQL_FAIL)
+	}
+	return nil
+}
+
+func (impl GatewayTrafficSplitServiceImpl) GetByApiId(apiId string) (*orm.GatewayTrafficSplit, error) {
+	if apiId == "" {
+		return nil, errors.New(ERR_INVALID_ARG)
+	}
+	dao := &orm.GatewayTrafficSplit{}
+	succ, err := orm.Get(impl.engine, dao, "api_id = ?", apiId)
+	if err != nil {
+		return nil, errors.Wrap(err, ERR_SQL_FAIL)
+	}
+	if !succ {
+		return nil, nil
+	}
+	return dao, nil
+}
@@ -190,6 +190,12 @@ type GatewayMockService interface {
 	GetMockByAny(*GatewayMock) (*GatewayMock, error)
 }
 
+type GatewayMockSpecService interface {
+	Insert(*GatewayMockSpec) error
+	Update(*GatewayMockSpec) error
+	GetByHeadKey(string) (*GatewayMockSpec, error)
+}
+
 type GatewayExtraService interface {
 	GetByKeyAndField(key string, field string) (*GatewayExtra, error)
 }
@@ -347,6 +353,27 @@ type GatewayUpstreamLbService interface {
 	GetByKongId(string) (*GatewayUpstreamLb, error)
 }
 
+type GatewayTrafficSplitService interface {
+	Insert(*GatewayTrafficSplit) error
+	Update(*GatewayTrafficSplit) error
+	DeleteById(string) error
+	GetByApiId(string) (*GatewayTrafficSplit, error)
+}
+
+type GatewayConsumerQuotaService interface {
+	Insert(*GatewayConsumerQuota) error
+	Update(*GatewayConsumerQuota) error
+	DeleteById(string) error
+	GetByConsumerId(string) (*GatewayConsumerQuota, error)
+}
+
+type GatewayUpstreamResilienceService interface {
+	Insert(*GatewayUpstreamResilience) error
+	Update(*GatewayUpstreamResilience) error
+	DeleteById(string) error
+	GetByApiId(string) (*GatewayUpstreamResilience, error)
+}
+
 type GatewayUpstreamLbTargetService interface {
 	Insert(*GatewayUpstreamLbTarget) error
 	SelectByDeploymentId(int) ([]GatewayUpstreamLbTarget, error)
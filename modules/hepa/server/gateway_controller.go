@@ -89,6 +89,7 @@ func (ctl GatewayController) Register() {
 	BindApi(API_GATEWAY_CATEGORY, "POST", ctl.CreatePolicy())
 	BindApi(API_GATEWAY_CATEGORY_ID, "PATCH", ctl.UpdatePolicy())
 	BindApi(API_GATEWAY_CATEGORY_ID, "DELETE", ctl.DeletePolicy())
+	BindApi(API_GATEWAY_PREVIEW, "POST", ctl.PreviewPolicy())
 	BindApi(GATEWAY_PROJECT_CONSUMER_INFO, "GET", ctl.GetProjectConsumerInfo())
 	BindApi(GATEWAY_CONSUMER_LIST, "GET", ctl.GetConsumerList())
 	BindApi(GATEWAY_CONSUMER_CREATE, "POST", ctl.CreateConsumer())
@@ -100,6 +101,8 @@ func (ctl GatewayController) Register() {
 
 	BindApi(API_MOCK_REGISTER, "POST", ctl.RegisterMockApi())
 	BindApi(API_MOCK_CALL, "POST", ctl.CallMockApi())
+	BindApi(API_MOCK_SPEC_REGISTER, "POST", ctl.RegisterMockSpec())
+	BindApi(API_MOCK_SPEC_CALL, "POST", ctl.CallMockSpec())
 	BindApi(UPSTREAM_REGISTER, "PUT", ctl.UpstreamRegister())
 	BindApi(UPSTREAM_REGISTER_ASYNC, "PUT", ctl.UpstreamRegisterAsync(),
 		ctl.UpstreamValidAsync())
@@ -469,6 +472,55 @@ func (ctl GatewayController) CallMockApi() Controller {
 	}
 }
 
+func (ctl GatewayController) RegisterMockSpec() Controller {
+	return func(c *gin.Context, reqBody []byte) (int, []byte) {
+		reqDto := dto.MockSpecDto{}
+		err := json.Unmarshal(reqBody, &reqDto)
+		if err != nil {
+			log.Error(err)
+			return http.StatusBadRequest, []byte("parse request failed")
+		}
+		resp := ctl.mockService.RegisterMockSpec(&reqDto)
+		respJson, err := json.Marshal(resp)
+		if err != nil {
+			log.Error(err)
+			return http.StatusInternalServerError, []byte("encode response failed")
+		}
+		if !resp.Success {
+			return http.StatusBadRequest, respJson
+		}
+		return http.StatusOK, respJson
+	}
+}
+
+func (ctl GatewayController) CallMockSpec() Controller {
+	return func(c *gin.Context, reqBody []byte) (int, []byte) {
+		reqDto := dto.CallMockSpecDto{}
+		err := json.Unmarshal(reqBody, &reqDto)
+		if err != nil {
+			log.Error(err)
+			return http.StatusBadRequest, []byte("parse request failed")
+		}
+		// 转发方未透传场景请求头时, 使用本次调用上的请求头
+		if scenario := c.GetHeader(dto.MOCK_SCENARIO_HEADER); scenario != "" && reqDto.Scenario() == "" {
+			if reqDto.Headers == nil {
+				reqDto.Headers = map[string]string{}
+			}
+			reqDto.Headers[dto.MOCK_SCENARIO_HEADER] = scenario
+		}
+		resp := ctl.mockService.CallMockSpec(&reqDto)
+		respJson, err := json.Marshal(resp)
+		if err != nil {
+			log.Error(err)
+			return http.StatusInternalServerError, []byte("encode response failed")
+		}
+		if !resp.Success {
+			return http.StatusBadRequest, respJson
+		}
+		return http.StatusOK, respJson
+	}
+}
+
 func (ctl GatewayController) CreateConsumer() Controller {
 	return func(c *gin.Context, reqBody []byte) (int, []byte) {
 		reqDto := dto.ConsumerCreateDto{}
@@ -780,6 +832,21 @@ func (ctl GatewayController) SetCategoryInfo() Controller {
 	}
 }
 
+func (ctl GatewayController) PreviewPolicy() Controller {
+	return func(c *gin.Context, reqBody []byte) (int, []byte) {
+		resp := ctl.apiPolicyService.PreviewPolicyConfig(c.Param("category"), reqBody)
+		respJson, err := json.Marshal(resp)
+		if err != nil {
+			log.Error(err)
+			return http.StatusInternalServerError, []byte("encode response failed")
+		}
+		if !resp.Success {
+			return http.StatusBadRequest, respJson
+		}
+		return http.StatusOK, respJson
+	}
+}
+
 func (ctl GatewayController) CreatePolicy() Controller {
 	return func(c *gin.Context, reqBody []byte) (int, []byte) {
 		category := c.Param("category")
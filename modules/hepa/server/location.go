@@ -30,15 +30,22 @@ const (
 	RUNTIME_DOMAIN         = "/runtimes/:runtimeId/domains"
 	RUNTIME_SERVICE_DOMAIN = "/runtimes/:runtimeId/services/:serviceName/domains"
 
-	PACKAGES            = "/packages"
-	PACKAGE             = "/packages/:packageId"
-	PACKAGEAPIS         = "/packages/:packageId/apis"
-	PACKAGEROOTAPI      = "/packages/:packageId/root-api"
-	PACKAGEAPI          = "/packages/:packageId/apis/:apiId"
-	PACKAGELOAD         = "/packages/:packageId/loadserver"
-	PACKAGEACL          = "/packages/:packageId/consumers"
-	PACKAGEAPIACL       = "/packages/:packageId/apis/:apiId/authz"
-	PACKAGE_ALIYUN_BIND = "/packages/:packageId/aliyun-bind"
+	GATEWAY_CONFIG = "/gateway-config"
+
+	PACKAGES             = "/packages"
+	PACKAGE              = "/packages/:packageId"
+	PACKAGEAPIS          = "/packages/:packageId/apis"
+	PACKAGEROOTAPI       = "/packages/:packageId/root-api"
+	PACKAGEAPI           = "/packages/:packageId/apis/:apiId"
+	PACKAGELOAD          = "/packages/:packageId/loadserver"
+	PACKAGEACL           = "/packages/:packageId/consumers"
+	PACKAGEAPIACL        = "/packages/:packageId/apis/:apiId/authz"
+	PACKAGEAPISPLIT      = "/packages/:packageId/apis/:apiId/traffic-split"
+	PACKAGEAPIRESILIENCE = "/packages/:packageId/apis/:apiId/resilience"
+	PACKAGEAPIUPSTREAM   = "/packages/:packageId/apis/:apiId/upstream-status"
+	PACKAGEANALYTICS     = "/packages/:packageId/analytics"
+	PACKAGEAPIANALYTICS  = "/packages/:packageId/apis/:apiId/analytics"
+	PACKAGE_ALIYUN_BIND  = "/packages/:packageId/aliyun-bind"
 
 	CONSUMERS                  = "/consumers"
 	CONSUMER                   = "/consumers/:consumerId"
@@ -46,6 +53,8 @@ const (
 	CONSUMERAUTH               = "/consumers/:consumerId/credentials"
 	CONSUMER_ALIYUN_AUTH       = "/consumers/:consumerId/aliyun-credentials"
 	CONSUMER_ALIYUN_AUTH_ASYNC = "/consumers/:consumerId/aliyun-credentials-async"
+	CONSUMERQUOTA              = "/consumers/:consumerId/quota"
+	CONSUMERUSAGE              = "/consumer-usage"
 
 	CLIENTS    = "/clients"
 	CLIENT     = "/clients/:clientId"
@@ -53,6 +62,7 @@ const (
 	CLIENTAUTH = "/clients/:clientId/credentials"
 
 	CLIENTLIMIT = "/clients/:clientId/packages/:packageId/limits"
+	CLIENTQUOTA = "/clients/:clientId/quota"
 
 	PACKAGESNAME  = "/packages-name"
 	CONSUMERSNAME = "/consumers-name"
@@ -116,6 +126,7 @@ const (
 	API_GATEWAY_API_ID      = "/api/:apiId"
 	API_GATEWAY_CATEGORY    = "/policies/:category"
 	API_GATEWAY_CATEGORY_ID = "/policies/:category/:policyId"
+	API_GATEWAY_PREVIEW     = "/policy-preview/:category"
 
 	UPSTREAM_REGISTER       = "/register"
 	UPSTREAM_REGISTER_ASYNC = "/register_async"
@@ -126,6 +137,9 @@ const (
 	//mock
 	API_MOCK_REGISTER = "/api/mock/register"
 	API_MOCK_CALL     = "/api/mock/call"
+	//基于接口规范的mock
+	API_MOCK_SPEC_REGISTER = "/api/mock/spec/register"
+	API_MOCK_SPEC_CALL     = "/api/mock/spec/call"
 	//业务网关注册
 	API_TRANSFORM_REGISTER = "/api/rpc/register"
 	API_GET_TRANS_CONFIG   = "/api/rpc/conf"
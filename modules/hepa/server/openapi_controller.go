@@ -15,8 +15,10 @@ package server
 
 import (
 	"encoding/json"
+	"fmt"
 	"net/http"
 	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/pkg/errors"
@@ -30,13 +32,17 @@ import (
 )
 
 type OpenapiController struct {
-	api      service.GatewayOpenapiService
-	consumer service.GatewayOpenapiConsumerService
-	rule     service.GatewayOpenapiRuleService
-	runtime  service.GatewayRuntimeServiceService
-	domain   service.GatewayDomainService
-	client   service.GatewayOrgClientService
-	global   service.GatewayGlobalService
+	api        service.GatewayOpenapiService
+	consumer   service.GatewayOpenapiConsumerService
+	rule       service.GatewayOpenapiRuleService
+	runtime    service.GatewayRuntimeServiceService
+	domain     service.GatewayDomainService
+	client     service.GatewayOrgClientService
+	global     service.GatewayGlobalService
+	split      service.GatewayTrafficSplitService
+	quota      service.GatewayConsumerQuotaService
+	resilience service.GatewayUpstreamResilienceService
+	analytics  service.GatewayApiAnalyticsService
 }
 
 func NewOpenapiController() (*OpenapiController, error) {
@@ -47,14 +53,22 @@ func NewOpenapiController() (*OpenapiController, error) {
 	domain, _ := service.NewGatewayDomainServiceImpl()
 	client, _ := service.NewGatewayOrgClientServiceImpl()
 	global, _ := service.NewGatewayGlobalServiceImpl()
+	split, _ := service.NewGatewayTrafficSplitServiceImpl()
+	quota, _ := service.NewGatewayConsumerQuotaServiceImpl()
+	resilience, _ := service.NewGatewayUpstreamResilienceServiceImpl()
+	analytics, _ := service.NewGatewayApiAnalyticsServiceImpl()
 	return &OpenapiController{
-		api:      api,
-		consumer: consumer,
-		rule:     rule,
-		runtime:  runtime,
-		domain:   domain,
-		client:   client,
-		global:   global,
+		api:        api,
+		consumer:   consumer,
+		rule:       rule,
+		runtime:    runtime,
+		domain:     domain,
+		client:     client,
+		global:     global,
+		split:      split,
+		quota:      quota,
+		resilience: resilience,
+		analytics:  analytics,
 	}, nil
 }
 
@@ -67,6 +81,9 @@ func (ctl OpenapiController) Register() {
 	BindOpenApi(CLIENTACL, "POST", ctl.GrantClientPackage())
 	BindOpenApi(CLIENTACL, "DELETE", ctl.RevokeClientPackage())
 	BindOpenApi(CLIENTLIMIT, "PUT", ctl.CreateOrUpdateClientLimits())
+	BindOpenApi(CLIENTQUOTA, "GET", ctl.GetClientQuota())
+	BindOpenApi(CLIENTQUOTA, "PUT", ctl.SetClientQuota())
+	BindOpenApi(CLIENTQUOTA, "DELETE", ctl.DeleteClientQuota())
 
 	BindOpenApi(PACKAGEROOTAPI, "PUT", ctl.TouchPackageRootApi())
 
@@ -78,6 +95,10 @@ func (ctl OpenapiController) Register() {
 	BindOpenApi(CONSUMER_ALIYUN_AUTH, "POST", ctl.SetCloudapiCredential(false))
 	BindOpenApi(CONSUMER_ALIYUN_AUTH_ASYNC, "POST", ctl.SetCloudapiCredential(true))
 	BindOpenApi(CONSUMER_ALIYUN_AUTH, "DELETE", ctl.DeleteCloudapiCredential())
+	BindOpenApi(CONSUMERQUOTA, "GET", ctl.GetConsumerQuota())
+	BindOpenApi(CONSUMERQUOTA, "PUT", ctl.SetConsumerQuota())
+	BindOpenApi(CONSUMERQUOTA, "DELETE", ctl.DeleteConsumerQuota())
+	BindOpenApi(CONSUMERUSAGE, "GET", ctl.ExportConsumerUsage())
 
 	BindOpenApi(RUNTIME_DOMAIN, "GET", ctl.GetRuntimeDomains())
 	BindOpenApi(RUNTIME_SERVICE_DOMAIN, "PUT", ctl.UpdateRuntimeServiceDomain())
@@ -103,6 +124,19 @@ func (ctl OpenapiController) Register() {
 	BindOpenApi(PACKAGEAPIACL, "POST", ctl.UpdatePackageApiAcl())
 	BindOpenApi(PACKAGEAPIACL, "GET", ctl.GetPackageApiAcl())
 
+	BindOpenApi(GATEWAY_CONFIG, "GET", ctl.ExportGatewayConfig())
+	BindOpenApi(GATEWAY_CONFIG, "PUT", ctl.ImportGatewayConfig())
+
+	BindOpenApi(PACKAGEAPISPLIT, "GET", ctl.GetPackageApiTrafficSplit())
+	BindOpenApi(PACKAGEAPISPLIT, "PUT", ctl.SetPackageApiTrafficSplit())
+	BindOpenApi(PACKAGEAPISPLIT, "DELETE", ctl.DeletePackageApiTrafficSplit())
+	BindOpenApi(PACKAGEAPIRESILIENCE, "GET", ctl.GetPackageApiResilience())
+	BindOpenApi(PACKAGEAPIRESILIENCE, "PUT", ctl.SetPackageApiResilience())
+	BindOpenApi(PACKAGEAPIRESILIENCE, "DELETE", ctl.DeletePackageApiResilience())
+	BindOpenApi(PACKAGEAPIUPSTREAM, "GET", ctl.GetPackageApiUpstreamStatus())
+	BindOpenApi(PACKAGEANALYTICS, "GET", ctl.GetPackageAnalytics())
+	BindOpenApi(PACKAGEAPIANALYTICS, "GET", ctl.GetPackageApiAnalytics())
+
 	BindOpenApi(CONSUMERS, "POST", ctl.CreateConsumer())
 	BindOpenApi(CONSUMERS, "GET", ctl.GetConsumers())
 
@@ -586,6 +620,199 @@ func (ctl OpenapiController) UpdatePackageAcl() Controller {
 	}
 }
 
+func (ctl OpenapiController) ExportGatewayConfig() Controller {
+	return func(c *gin.Context, reqBody []byte) (int, []byte) {
+		args := dto.NewDiceArgsDto(c)
+		server, _ := service.NewGatewayConfigServiceImpl()
+		server.ReqCtx = c
+		resp := server.ExportConfig(&args)
+		respJson, err := json.Marshal(resp)
+		if err != nil {
+			log.Error(err)
+			return http.StatusInternalServerError, []byte("encode response failed")
+		}
+		if !resp.Success {
+			return http.StatusBadRequest, respJson
+		}
+		return http.StatusOK, respJson
+	}
+}
+
+func (ctl OpenapiController) ImportGatewayConfig() Controller {
+	return func(c *gin.Context, reqBody []byte) (int, []byte) {
+		args := dto.NewDiceArgsDto(c)
+		server, _ := service.NewGatewayConfigServiceImpl()
+		server.ReqCtx = c
+		resp := server.ImportConfig(&args, reqBody, c.Query("dryRun") == "true", c.Query("prune") == "true")
+		respJson, err := json.Marshal(resp)
+		if err != nil {
+			log.Error(err)
+			return http.StatusInternalServerError, []byte("encode response failed")
+		}
+		if !resp.Success {
+			return http.StatusBadRequest, respJson
+		}
+		return http.StatusOK, respJson
+	}
+}
+
+func (ctl OpenapiController) GetPackageApiTrafficSplit() Controller {
+	return func(c *gin.Context, reqBody []byte) (int, []byte) {
+		resp := ctl.split.GetPackageApiTrafficSplit(c.Param("packageId"), c.Param("apiId"))
+		respJson, err := json.Marshal(resp)
+		if err != nil {
+			log.Error(err)
+			return http.StatusInternalServerError, []byte("encode response failed")
+		}
+		if !resp.Success {
+			return http.StatusBadRequest, respJson
+		}
+		return http.StatusOK, respJson
+	}
+}
+
+func (ctl OpenapiController) SetPackageApiTrafficSplit() Controller {
+	return func(c *gin.Context, reqBody []byte) (int, []byte) {
+		reqDto := dto.TrafficSplitDto{}
+		err := json.Unmarshal(reqBody, &reqDto)
+		if err != nil {
+			log.Error(err)
+			return http.StatusBadRequest, []byte("parse request failed")
+		}
+		resp := ctl.split.SetPackageApiTrafficSplit(c.Param("packageId"), c.Param("apiId"), &reqDto)
+		respJson, err := json.Marshal(resp)
+		if err != nil {
+			log.Error(err)
+			return http.StatusInternalServerError, []byte("encode response failed")
+		}
+		if !resp.Success {
+			return http.StatusBadRequest, respJson
+		}
+		return http.StatusOK, respJson
+	}
+}
+
+func (ctl OpenapiController) DeletePackageApiTrafficSplit() Controller {
+	return func(c *gin.Context, reqBody []byte) (int, []byte) {
+		resp := ctl.split.DeletePackageApiTrafficSplit(c.Param("packageId"), c.Param("apiId"))
+		respJson, err := json.Marshal(resp)
+		if err != nil {
+			log.Error(err)
+			return http.StatusInternalServerError, []byte("encode response failed")
+		}
+		if !resp.Success {
+			return http.StatusBadRequest, respJson
+		}
+		return http.StatusOK, respJson
+	}
+}
+
+func (ctl OpenapiController) GetPackageApiResilience() Controller {
+	return func(c *gin.Context, reqBody []byte) (int, []byte) {
+		resp := ctl.resilience.GetPackageApiResilience(c.Param("packageId"), c.Param("apiId"))
+		respJson, err := json.Marshal(resp)
+		if err != nil {
+			log.Error(err)
+			return http.StatusInternalServerError, []byte("encode response failed")
+		}
+		if !resp.Success {
+			return http.StatusBadRequest, respJson
+		}
+		return http.StatusOK, respJson
+	}
+}
+
+func (ctl OpenapiController) SetPackageApiResilience() Controller {
+	return func(c *gin.Context, reqBody []byte) (int, []byte) {
+		reqDto := dto.UpstreamResilienceDto{}
+		err := json.Unmarshal(reqBody, &reqDto)
+		if err != nil {
+			log.Error(err)
+			return http.StatusBadRequest, []byte("parse request failed")
+		}
+		resp := ctl.resilience.SetPackageApiResilience(c.Param("packageId"), c.Param("apiId"), &reqDto)
+		respJson, err := json.Marshal(resp)
+		if err != nil {
+			log.Error(err)
+			return http.StatusInternalServerError, []byte("encode response failed")
+		}
+		if !resp.Success {
+			return http.StatusBadRequest, respJson
+		}
+		return http.StatusOK, respJson
+	}
+}
+
+func (ctl OpenapiController) DeletePackageApiResilience() Controller {
+	return func(c *gin.Context, reqBody []byte) (int, []byte) {
+		resp := ctl.resilience.DeletePackageApiResilience(c.Param("packageId"), c.Param("apiId"))
+		respJson, err := json.Marshal(resp)
+		if err != nil {
+			log.Error(err)
+			return http.StatusInternalServerError, []byte("encode response failed")
+		}
+		if !resp.Success {
+			return http.StatusBadRequest, respJson
+		}
+		return http.StatusOK, respJson
+	}
+}
+
+func (ctl OpenapiController) GetPackageApiUpstreamStatus() Controller {
+	return func(c *gin.Context, reqBody []byte) (int, []byte) {
+		resp := ctl.resilience.GetPackageApiUpstreamStatus(c.Param("packageId"), c.Param("apiId"))
+		respJson, err := json.Marshal(resp)
+		if err != nil {
+			log.Error(err)
+			return http.StatusInternalServerError, []byte("encode response failed")
+		}
+		if !resp.Success {
+			return http.StatusBadRequest, respJson
+		}
+		return http.StatusOK, respJson
+	}
+}
+
+func (ctl OpenapiController) GetPackageAnalytics() Controller {
+	return func(c *gin.Context, reqBody []byte) (int, []byte) {
+		reqDto, err := dto.ParseApiAnalyticsReq(c.Query("start"), c.Query("end"), c.Query("groupBy"), c.Query("limit"), time.Now())
+		if err != nil {
+			log.Error(err)
+			return http.StatusBadRequest, []byte("parse request failed")
+		}
+		resp := ctl.analytics.GetPackageAnalytics(c.Param("packageId"), reqDto)
+		respJson, err := json.Marshal(resp)
+		if err != nil {
+			log.Error(err)
+			return http.StatusInternalServerError, []byte("encode response failed")
+		}
+		if !resp.Success {
+			return http.StatusBadRequest, respJson
+		}
+		return http.StatusOK, respJson
+	}
+}
+
+func (ctl OpenapiController) GetPackageApiAnalytics() Controller {
+	return func(c *gin.Context, reqBody []byte) (int, []byte) {
+		reqDto, err := dto.ParseApiAnalyticsReq(c.Query("start"), c.Query("end"), c.Query("groupBy"), c.Query("limit"), time.Now())
+		if err != nil {
+			log.Error(err)
+			return http.StatusBadRequest, []byte("parse request failed")
+		}
+		resp := ctl.analytics.GetPackageApiAnalytics(c.Param("packageId"), c.Param("apiId"), reqDto)
+		respJson, err := json.Marshal(resp)
+		if err != nil {
+			log.Error(err)
+			return http.StatusInternalServerError, []byte("encode response failed")
+		}
+		if !resp.Success {
+			return http.StatusBadRequest, respJson
+		}
+		return http.StatusOK, respJson
+	}
+}
+
 func (ctl OpenapiController) GetPackageApiAcl() Controller {
 	return func(c *gin.Context, reqBody []byte) (int, []byte) {
 		resp := ctl.consumer.GetPackageApiAcls(c.Param("packageId"), c.Param("apiId"))
@@ -1002,3 +1229,131 @@ func (ctl OpenapiController) CreateOrUpdateClientLimits() Controller {
 		return http.StatusOK, respJson
 	}
 }
+
+func (ctl OpenapiController) GetConsumerQuota() Controller {
+	return func(c *gin.Context, reqBody []byte) (int, []byte) {
+		resp := ctl.quota.GetConsumerQuota(c.Param("consumerId"))
+		respJson, err := json.Marshal(resp)
+		if err != nil {
+			log.Error(err)
+			return http.StatusInternalServerError, []byte("encode response failed")
+		}
+		if !resp.Success {
+			return http.StatusBadRequest, respJson
+		}
+		return http.StatusOK, respJson
+	}
+}
+
+func (ctl OpenapiController) SetConsumerQuota() Controller {
+	return func(c *gin.Context, reqBody []byte) (int, []byte) {
+		reqDto := dto.ConsumerQuotaDto{}
+		err := json.Unmarshal(reqBody, &reqDto)
+		if err != nil {
+			log.Error(err)
+			return http.StatusBadRequest, []byte("parse request failed")
+		}
+		resp := ctl.quota.SetConsumerQuota(c.Param("consumerId"), &reqDto)
+		respJson, err := json.Marshal(resp)
+		if err != nil {
+			log.Error(err)
+			return http.StatusInternalServerError, []byte("encode response failed")
+		}
+		if !resp.Success {
+			return http.StatusBadRequest, respJson
+		}
+		return http.StatusOK, respJson
+	}
+}
+
+func (ctl OpenapiController) DeleteConsumerQuota() Controller {
+	return func(c *gin.Context, reqBody []byte) (int, []byte) {
+		resp := ctl.quota.DeleteConsumerQuota(c.Param("consumerId"))
+		respJson, err := json.Marshal(resp)
+		if err != nil {
+			log.Error(err)
+			return http.StatusInternalServerError, []byte("encode response failed")
+		}
+		if !resp.Success {
+			return http.StatusBadRequest, respJson
+		}
+		return http.StatusOK, respJson
+	}
+}
+
+func (ctl OpenapiController) GetClientQuota() Controller {
+	return func(c *gin.Context, reqBody []byte) (int, []byte) {
+		resp := ctl.quota.GetClientQuota(c.Param("clientId"))
+		respJson, err := json.Marshal(resp)
+		if err != nil {
+			log.Error(err)
+			return http.StatusInternalServerError, []byte("encode response failed")
+		}
+		if !resp.Success {
+			return http.StatusBadRequest, respJson
+		}
+		return http.StatusOK, respJson
+	}
+}
+
+func (ctl OpenapiController) SetClientQuota() Controller {
+	return func(c *gin.Context, reqBody []byte) (int, []byte) {
+		reqDto := dto.ConsumerQuotaDto{}
+		err := json.Unmarshal(reqBody, &reqDto)
+		if err != nil {
+			log.Error(err)
+			return http.StatusBadRequest, []byte("parse request failed")
+		}
+		resp := ctl.quota.SetClientQuota(c.Param("clientId"), &reqDto)
+		respJson, err := json.Marshal(resp)
+		if err != nil {
+			log.Error(err)
+			return http.StatusInternalServerError, []byte("encode response failed")
+		}
+		if !resp.Success {
+			return http.StatusBadRequest, respJson
+		}
+		return http.StatusOK, respJson
+	}
+}
+
+func (ctl OpenapiController) DeleteClientQuota() Controller {
+	return func(c *gin.Context, reqBody []byte) (int, []byte) {
+		resp := ctl.quota.DeleteClientQuota(c.Param("clientId"))
+		respJson, err := json.Marshal(resp)
+		if err != nil {
+			log.Error(err)
+			return http.StatusInternalServerError, []byte("encode response failed")
+		}
+		if !resp.Success {
+			return http.StatusBadRequest, respJson
+		}
+		return http.StatusOK, respJson
+	}
+}
+
+func (ctl OpenapiController) ExportConsumerUsage() Controller {
+	return func(c *gin.Context, reqBody []byte) (int, []byte) {
+		args := dto.NewDiceArgsDto(c)
+		month := c.Query("month")
+		resp := ctl.quota.ExportUsage(&args, month)
+		if !resp.Success {
+			respJson, err := json.Marshal(resp)
+			if err != nil {
+				log.Error(err)
+				return http.StatusInternalServerError, []byte("encode response failed")
+			}
+			return http.StatusBadRequest, respJson
+		}
+		data, ok := resp.Data.([]byte)
+		if !ok {
+			return http.StatusInternalServerError, []byte("encode response failed")
+		}
+		if month == "" {
+			month = time.Now().UTC().Format(dto.USAGE_MONTH_LAYOUT)
+		}
+		c.Header("Content-Type", "text/csv; charset=utf-8")
+		c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=gateway-usage-%s.csv", month))
+		return http.StatusOK, data
+	}
+}
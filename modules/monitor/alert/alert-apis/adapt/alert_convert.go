@@ -399,6 +399,9 @@ func (e *AlertExpression) ToModel(orgName string, alert *Alert, rule *AlertRule)
 	if recordPath, ok := utils.GetMapValueString(alert.Attributes, "alert_record_path"); ok {
 		attributes["record_url"] = convertRecordURL(alertDomain, orgName, recordPath)
 	}
+	if alertDomain != "" {
+		attributes["ack_url"] = convertAckURL(alertDomain, alert.AlertScope, alert.AlertScopeID)
+	}
 
 	return &db.AlertExpression{
 		ID:         e.ID,
@@ -316,3 +316,14 @@ func convertDashboardURL(domain, orgName, path, dashboardID string, groups []str
 func convertRecordURL(domain, orgName, path string) string {
 	return domain + "/" + orgName + path + "/{{alert_group_id}}"
 }
+
+// transform ack url, visiting it silences the alert of record for the default duration
+func convertAckURL(domain, scope, scopeID string) string {
+	if scope == orgScope {
+		return domain + "/api/org-alert-records/{{alert_group_id}}/ack"
+	}
+	return domain + "/api/alert-records/{{alert_group_id}}/ack?" + url.Values{
+		"scope":   []string{scope},
+		"scopeId": []string{scopeID},
+	}.Encode()
+}
@@ -27,6 +27,7 @@ const (
 	OperatorTypeOne  = "one"
 	OperatorTypeMore = "more"
 
+	// the notify samples used when the message template registry of eventbox is unavailable
 	OrgNotifyTemplateSample = `
     【机器负载异常告警】
 
@@ -41,6 +42,8 @@ const (
     [查看详情]({{display_url}})
 
     [查看记录]({{record_url}})
+
+    [确认告警]({{ack_url}})
 `
 
 	OrgNotifyTemplateSampleEn = `
@@ -57,6 +60,8 @@ const (
     [Details]({{display_url}})
 
     [History]({{record_url}})
+
+    [Acknowledge]({{ack_url}})
 `
 
 	fixedSliencePolicy = "fixed"
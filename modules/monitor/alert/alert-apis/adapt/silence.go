@@ -0,0 +1,379 @@
+// Copyright (c) 2021 Terminus, Inc.
+//
+// This program is free software: you can use, redistribute, and/or modify
+// it under the terms of the GNU Affero General Public License, version 3
+// or later ("AGPL"), as published by the Free Software Foundation.
+//
+// This program is distributed in the hope that it will be useful, but WITHOUT
+// ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+// FITNESS FOR A PARTICULAR PURPOSE.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package adapt
+
+import (
+	"encoding/json"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/erda-project/erda/modules/monitor/alert/alert-apis/db"
+	"github.com/erda-project/erda/modules/monitor/alert/silence"
+	"github.com/erda-project/erda/modules/monitor/utils"
+)
+
+// AlertGroupTag is the tag of alert notification which identifies the alert record.
+const AlertGroupTag = "alert_group"
+
+type (
+	// AlertSilence .
+	AlertSilence struct {
+		ID         uint64           `json:"id"`
+		Scope      string           `json:"scope"`
+		ScopeID    string           `json:"scopeId"`
+		Matchers   silence.Matchers `json:"matchers"`
+		StartsAt   int64            `json:"startsAt"`
+		EndsAt     int64            `json:"endsAt"`
+		Creator    string           `json:"creator"`
+		Comment    string           `json:"comment"`
+		CreateTime int64            `json:"createTime"`
+	}
+	// AlertInhibitRule .
+	AlertInhibitRule struct {
+		ID             uint64           `json:"id"`
+		Scope          string           `json:"scope"`
+		ScopeID        string           `json:"scopeId"`
+		Name           string           `json:"name"`
+		SourceMatchers silence.Matchers `json:"sourceMatchers"`
+		TargetMatchers silence.Matchers `json:"targetMatchers"`
+		Equal          []string         `json:"equal"`
+		Enable         bool             `json:"enable"`
+		CreateTime     int64            `json:"createTime"`
+		UpdateTime     int64            `json:"updateTime"`
+	}
+	// AlertGroupRule .
+	AlertGroupRule struct {
+		ID            uint64           `json:"id"`
+		Scope         string           `json:"scope"`
+		ScopeID       string           `json:"scopeId"`
+		Name          string           `json:"name"`
+		Matchers      silence.Matchers `json:"matchers"`
+		By            []string         `json:"by"`
+		GroupWait     int64            `json:"groupWait"`     // seconds
+		GroupInterval int64            `json:"groupInterval"` // seconds
+		Enable        bool             `json:"enable"`
+		CreateTime    int64            `json:"createTime"`
+		UpdateTime    int64            `json:"updateTime"`
+	}
+)
+
+// FromModel .
+func (s *AlertSilence) FromModel(m *db.AlertSilence) *AlertSilence {
+	s.ID = m.ID
+	s.Scope = m.Scope
+	s.ScopeID = m.ScopeID
+	json.Unmarshal([]byte(m.Matchers), &s.Matchers)
+	s.StartsAt = utils.ConvertTimeToMS(m.StartsAt)
+	s.EndsAt = utils.ConvertTimeToMS(m.EndsAt)
+	s.Creator = m.Creator
+	s.Comment = m.Remark
+	s.CreateTime = utils.ConvertTimeToMS(m.CreatedAt)
+	return s
+}
+
+// ToModel .
+func (s *AlertSilence) ToModel() *db.AlertSilence {
+	matchers, _ := json.Marshal(s.Matchers)
+	return &db.AlertSilence{
+		ID:       s.ID,
+		Scope:    s.Scope,
+		ScopeID:  s.ScopeID,
+		Matchers: string(matchers),
+		StartsAt: time.Unix(0, s.StartsAt*int64(time.Millisecond)),
+		EndsAt:   time.Unix(0, s.EndsAt*int64(time.Millisecond)),
+		Creator:  s.Creator,
+		Remark:   s.Comment,
+	}
+}
+
+// ToSilence .
+func (s *AlertSilence) ToSilence() *silence.Silence {
+	return &silence.Silence{
+		ID:       s.ID,
+		Scope:    silence.Scope{Scope: s.Scope, ScopeID: s.ScopeID},
+		Matchers: s.Matchers,
+		StartsAt: s.StartsAt,
+		EndsAt:   s.EndsAt,
+		Comment:  s.Comment,
+	}
+}
+
+// FromModel .
+func (r *AlertInhibitRule) FromModel(m *db.AlertInhibitRule) *AlertInhibitRule {
+	r.ID = m.ID
+	r.Scope = m.Scope
+	r.ScopeID = m.ScopeID
+	r.Name = m.Name
+	json.Unmarshal([]byte(m.SourceMatchers), &r.SourceMatchers)
+	json.Unmarshal([]byte(m.TargetMatchers), &r.TargetMatchers)
+	r.Equal = splitTags(m.EqualTags)
+	r.Enable = m.IsEnabled
+	r.CreateTime = utils.ConvertTimeToMS(m.CreatedAt)
+	r.UpdateTime = utils.ConvertTimeToMS(m.UpdatedAt)
+	return r
+}
+
+// ToModel .
+func (r *AlertInhibitRule) ToModel() *db.AlertInhibitRule {
+	source, _ := json.Marshal(r.SourceMatchers)
+	target, _ := json.Marshal(r.TargetMatchers)
+	return &db.AlertInhibitRule{
+		ID:             r.ID,
+		Scope:          r.Scope,
+		ScopeID:        r.ScopeID,
+		Name:           r.Name,
+		SourceMatchers: string(source),
+		TargetMatchers: string(target),
+		EqualTags:      strings.Join(r.Equal, ","),
+		IsEnabled:      r.Enable,
+	}
+}
+
+// ToInhibitRule .
+func (r *AlertInhibitRule) ToInhibitRule() *silence.InhibitRule {
+	return &silence.InhibitRule{
+		ID:             r.ID,
+		Scope:          silence.Scope{Scope: r.Scope, ScopeID: r.ScopeID},
+		SourceMatchers: r.SourceMatchers,
+		TargetMatchers: r.TargetMatchers,
+		Equal:          r.Equal,
+	}
+}
+
+// FromModel .
+func (r *AlertGroupRule) FromModel(m *db.AlertGroupRule) *AlertGroupRule {
+	r.ID = m.ID
+	r.Scope = m.Scope
+	r.ScopeID = m.ScopeID
+	r.Name = m.Name
+	json.Unmarshal([]byte(m.Matchers), &r.Matchers)
+	r.By = splitTags(m.GroupBy)
+	r.GroupWait = m.GroupWait
+	r.GroupInterval = m.GroupInterval
+	r.Enable = m.IsEnabled
+	r.CreateTime = utils.ConvertTimeToMS(m.CreatedAt)
+	r.UpdateTime = utils.ConvertTimeToMS(m.UpdatedAt)
+	return r
+}
+
+// ToModel .
+func (r *AlertGroupRule) ToModel() *db.AlertGroupRule {
+	matchers, _ := json.Marshal(r.Matchers)
+	return &db.AlertGroupRule{
+		ID:            r.ID,
+		Scope:         r.Scope,
+		ScopeID:       r.ScopeID,
+		Name:          r.Name,
+		Matchers:      string(matchers),
+		GroupBy:       strings.Join(r.By, ","),
+		GroupWait:     r.GroupWait,
+		GroupInterval: r.GroupInterval,
+		IsEnabled:     r.Enable,
+	}
+}
+
+// ToGroupRule .
+func (r *AlertGroupRule) ToGroupRule() *silence.GroupRule {
+	return &silence.GroupRule{
+		ID:            r.ID,
+		Scope:         silence.Scope{Scope: r.Scope, ScopeID: r.ScopeID},
+		Matchers:      r.Matchers,
+		By:            r.By,
+		GroupWait:     time.Duration(r.GroupWait) * time.Second,
+		GroupInterval: time.Duration(r.GroupInterval) * time.Second,
+	}
+}
+
+func splitTags(s string) []string {
+	var tags []string
+	for _, tag := range strings.Split(s, ",") {
+		tag = strings.TrimSpace(tag)
+		if len(tag) > 0 {
+			tags = append(tags, tag)
+		}
+	}
+	return tags
+}
+
+// QueryAlertSilences .
+func (a *Adapt) QueryAlertSilences(scope, scopeID string, activeOnly bool) ([]*AlertSilence, error) {
+	silences, err := a.db.AlertSilence.QueryByScope(scope, scopeID, activeOnly, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	result := make([]*AlertSilence, 0, len(silences))
+	for _, s := range silences {
+		result = append(result, (&AlertSilence{}).FromModel(s))
+	}
+	return result, nil
+}
+
+// CreateAlertSilence .
+func (a *Adapt) CreateAlertSilence(s *AlertSilence) (uint64, error) {
+	if s.StartsAt <= 0 {
+		s.StartsAt = utils.ConvertTimeToMS(time.Now())
+	}
+	if err := s.ToSilence().Validate(); err != nil {
+		return 0, invalidParameter("invalid silence: %s", err)
+	}
+	m := s.ToModel()
+	if err := a.db.AlertSilence.Insert(m); err != nil {
+		return 0, err
+	}
+	return m.ID, nil
+}
+
+// ExpireAlertSilence ends the silence now, returns false if it does not belong to scope.
+func (a *Adapt) ExpireAlertSilence(scope, scopeID string, id uint64) (bool, error) {
+	s, err := a.db.AlertSilence.GetByID(id)
+	if err != nil {
+		return false, err
+	} else if s == nil || s.Scope != scope || s.ScopeID != scopeID {
+		return false, nil
+	}
+	now := time.Now()
+	if s.EndsAt.Before(now) {
+		return true, nil
+	}
+	return true, a.db.AlertSilence.Expire(id, now)
+}
+
+// AckAlertRecord silences the alert of record for duration, returns 0 if the record does not exist in scope.
+func (a *Adapt) AckAlertRecord(scope, scopeID, userID, groupID string, duration time.Duration) (uint64, error) {
+	groupID, err := url.QueryUnescape(groupID)
+	if err != nil {
+		return 0, err
+	}
+	record, err := a.db.AlertRecord.GetByGroupID(groupID)
+	if err != nil {
+		return 0, err
+	} else if record == nil || record.Scope != scope || record.ScopeKey != scopeID {
+		return 0, nil
+	}
+	now := time.Now()
+	return a.CreateAlertSilence(&AlertSilence{
+		Scope:    scope,
+		ScopeID:  scopeID,
+		Matchers: silence.Matchers{{Tag: AlertGroupTag, Type: silence.MatchEqual, Value: record.AlertGroup}},
+		StartsAt: utils.ConvertTimeToMS(now),
+		EndsAt:   utils.ConvertTimeToMS(now.Add(duration)),
+		Creator:  userID,
+		Comment:  "ack " + record.Title,
+	})
+}
+
+// QueryAlertInhibitRules .
+func (a *Adapt) QueryAlertInhibitRules(scope, scopeID string) ([]*AlertInhibitRule, error) {
+	rules, err := a.db.AlertInhibitRule.QueryByScope(scope, scopeID)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]*AlertInhibitRule, 0, len(rules))
+	for _, r := range rules {
+		result = append(result, (&AlertInhibitRule{}).FromModel(r))
+	}
+	return result, nil
+}
+
+// CreateAlertInhibitRule .
+func (a *Adapt) CreateAlertInhibitRule(r *AlertInhibitRule) (uint64, error) {
+	if err := r.ToInhibitRule().Validate(); err != nil {
+		return 0, invalidParameter("invalid inhibit rule: %s", err)
+	}
+	m := r.ToModel()
+	if err := a.db.AlertInhibitRule.Insert(m); err != nil {
+		return 0, err
+	}
+	return m.ID, nil
+}
+
+// UpdateAlertInhibitRule returns false if the rule does not belong to scope.
+func (a *Adapt) UpdateAlertInhibitRule(r *AlertInhibitRule) (bool, error) {
+	if err := r.ToInhibitRule().Validate(); err != nil {
+		return false, invalidParameter("invalid inhibit rule: %s", err)
+	}
+	old, err := a.db.AlertInhibitRule.GetByID(r.ID)
+	if err != nil {
+		return false, err
+	} else if old == nil || old.Scope != r.Scope || old.ScopeID != r.ScopeID {
+		return false, nil
+	}
+	m := r.ToModel()
+	m.CreatedAt = old.CreatedAt
+	return true, a.db.AlertInhibitRule.Update(m)
+}
+
+// DeleteAlertInhibitRule .
+func (a *Adapt) DeleteAlertInhibitRule(scope, scopeID string, id uint64) error {
+	old, err := a.db.AlertInhibitRule.GetByID(id)
+	if err != nil {
+		return err
+	} else if old == nil || old.Scope != scope || old.ScopeID != scopeID {
+		return nil
+	}
+	return a.db.AlertInhibitRule.DeleteByID(id)
+}
+
+// QueryAlertGroupRules .
+func (a *Adapt) QueryAlertGroupRules(scope, scopeID string) ([]*AlertGroupRule, error) {
+	rules, err := a.db.AlertGroupRule.QueryByScope(scope, scopeID)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]*AlertGroupRule, 0, len(rules))
+	for _, r := range rules {
+		result = append(result, (&AlertGroupRule{}).FromModel(r))
+	}
+	return result, nil
+}
+
+// CreateAlertGroupRule .
+func (a *Adapt) CreateAlertGroupRule(r *AlertGroupRule) (uint64, error) {
+	if err := r.ToGroupRule().Validate(); err != nil {
+		return 0, invalidParameter("invalid group rule: %s", err)
+	}
+	m := r.ToModel()
+	if err := a.db.AlertGroupRule.Insert(m); err != nil {
+		return 0, err
+	}
+	return m.ID, nil
+}
+
+// UpdateAlertGroupRule returns false if the rule does not belong to scope.
+func (a *Adapt) UpdateAlertGroupRule(r *AlertGroupRule) (bool, error) {
+	if err := r.ToGroupRule().Validate(); err != nil {
+		return false, invalidParameter("invalid group rule: %s", err)
+	}
+	old, err := a.db.AlertGroupRule.GetByID(r.ID)
+	if err != nil {
+		return false, err
+	} else if old == nil || old.Scope != r.Scope || old.ScopeID != r.ScopeID {
+		return false, nil
+	}
+	m := r.ToModel()
+	m.CreatedAt = old.CreatedAt
+	return true, a.db.AlertGroupRule.Update(m)
+}
+
+// DeleteAlertGroupRule .
+func (a *Adapt) DeleteAlertGroupRule(scope, scopeID string, id uint64) error {
+	old, err := a.db.AlertGroupRule.GetByID(id)
+	if err != nil {
+		return err
+	} else if old == nil || old.Scope != scope || old.ScopeID != scopeID {
+		return nil
+	}
+	return a.db.AlertGroupRule.DeleteByID(id)
+}
@@ -0,0 +1,182 @@
+// Copyright (c) 2021 Terminus, Inc.
+//
+// This program is free software: you can use, redistribute, and/or modify
+// it under the terms of the GNU Affero General Public License, version 3
+// or later ("AGPL"), as published by the Free Software Foundation.
+//
+// This program is distributed in the hope that it will be useful, but WITHOUT
+// ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+// FITNESS FOR A PARTICULAR PURPOSE.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package db
+
+import (
+	"time"
+
+	"github.com/jinzhu/gorm"
+)
+
+// AlertSilenceDB .
+type AlertSilenceDB struct {
+	*gorm.DB
+}
+
+// GetByID .
+func (db *AlertSilenceDB) GetByID(id uint64) (*AlertSilence, error) {
+	var silence AlertSilence
+	if err := db.Where("id=?", id).Find(&silence).Error; err != nil {
+		if gorm.IsRecordNotFoundError(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &silence, nil
+}
+
+// QueryByScope returns the silences of scope, which are not expired at the time now if activeOnly.
+func (db *AlertSilenceDB) QueryByScope(scope, scopeID string, activeOnly bool, now time.Time) ([]*AlertSilence, error) {
+	var silences []*AlertSilence
+	s := db.Where("scope=? AND scope_id=?", scope, scopeID)
+	if activeOnly {
+		s = s.Where("ends_at>?", now)
+	}
+	if err := s.Order("starts_at DESC").Find(&silences).Error; err != nil {
+		return nil, err
+	}
+	return silences, nil
+}
+
+// QueryActive returns all silences which are not expired at the time now.
+func (db *AlertSilenceDB) QueryActive(now time.Time) ([]*AlertSilence, error) {
+	var silences []*AlertSilence
+	if err := db.Where("ends_at>?", now).Find(&silences).Error; err != nil {
+		return nil, err
+	}
+	return silences, nil
+}
+
+// Insert .
+func (db *AlertSilenceDB) Insert(silence *AlertSilence) error {
+	silence.CreatedAt = time.Now()
+	silence.UpdatedAt = time.Now()
+	return db.Create(silence).Error
+}
+
+// Expire ends the silence at the time now.
+func (db *AlertSilenceDB) Expire(id uint64, now time.Time) error {
+	return db.Model(&AlertSilence{}).
+		Where("id=?", id).
+		Update("updated_at", time.Now()).
+		Update("ends_at", now).Error
+}
+
+// AlertInhibitRuleDB .
+type AlertInhibitRuleDB struct {
+	*gorm.DB
+}
+
+// GetByID .
+func (db *AlertInhibitRuleDB) GetByID(id uint64) (*AlertInhibitRule, error) {
+	var rule AlertInhibitRule
+	if err := db.Where("id=?", id).Find(&rule).Error; err != nil {
+		if gorm.IsRecordNotFoundError(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &rule, nil
+}
+
+// QueryByScope .
+func (db *AlertInhibitRuleDB) QueryByScope(scope, scopeID string) ([]*AlertInhibitRule, error) {
+	var rules []*AlertInhibitRule
+	if err := db.Where("scope=? AND scope_id=?", scope, scopeID).
+		Order("updated_at DESC").Find(&rules).Error; err != nil {
+		return nil, err
+	}
+	return rules, nil
+}
+
+// QueryEnabled .
+func (db *AlertInhibitRuleDB) QueryEnabled() ([]*AlertInhibitRule, error) {
+	var rules []*AlertInhibitRule
+	if err := db.Where("is_enabled=?", true).Find(&rules).Error; err != nil {
+		return nil, err
+	}
+	return rules, nil
+}
+
+// Insert .
+func (db *AlertInhibitRuleDB) Insert(rule *AlertInhibitRule) error {
+	rule.CreatedAt = time.Now()
+	rule.UpdatedAt = time.Now()
+	return db.Create(rule).Error
+}
+
+// Update .
+func (db *AlertInhibitRuleDB) Update(rule *AlertInhibitRule) error {
+	rule.UpdatedAt = time.Now()
+	return db.Save(rule).Error
+}
+
+// DeleteByID .
+func (db *AlertInhibitRuleDB) DeleteByID(id uint64) error {
+	return db.Where("id=?", id).Delete(&AlertInhibitRule{}).Error
+}
+
+// AlertGroupRuleDB .
+type AlertGroupRuleDB struct {
+	*gorm.DB
+}
+
+// GetByID .
+func (db *AlertGroupRuleDB) GetByID(id uint64) (*AlertGroupRule, error) {
+	var rule AlertGroupRule
+	if err := db.Where("id=?", id).Find(&rule).Error; err != nil {
+		if gorm.IsRecordNotFoundError(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &rule, nil
+}
+
+// QueryByScope .
+func (db *AlertGroupRuleDB) QueryByScope(scope, scopeID string) ([]*AlertGroupRule, error) {
+	var rules []*AlertGroupRule
+	if err := db.Where("scope=? AND scope_id=?", scope, scopeID).
+		Order("updated_at DESC").Find(&rules).Error; err != nil {
+		return nil, err
+	}
+	return rules, nil
+}
+
+// QueryEnabled .
+func (db *AlertGroupRuleDB) QueryEnabled() ([]*AlertGroupRule, error) {
+	var rules []*AlertGroupRule
+	if err := db.Where("is_enabled=?", true).Find(&rules).Error; err != nil {
+		return nil, err
+	}
+	return rules, nil
+}
+
+// Insert .
+func (db *AlertGroupRuleDB) Insert(rule *AlertGroupRule) error {
+	rule.CreatedAt = time.Now()
+	rule.UpdatedAt = time.Now()
+	return db.Create(rule).Error
+}
+
+// Update .
+func (db *AlertGroupRuleDB) Update(rule *AlertGroupRule) error {
+	rule.UpdatedAt = time.Now()
+	return db.Save(rule).Error
+}
+
+// DeleteByID .
+func (db *AlertGroupRuleDB) DeleteByID(id uint64) error {
+	return db.Where("id=?", id).Delete(&AlertGroupRule{}).Error
+}
@@ -29,6 +29,9 @@ type DB struct {
 	AlertNotifyTemplate          AlertNotifyTemplateDB
 	AlertRule                    AlertRuleDB
 	AlertRecord                  AlertRecordDB
+	AlertSilence                 AlertSilenceDB
+	AlertInhibitRule             AlertInhibitRuleDB
+	AlertGroupRule               AlertGroupRuleDB
 }
 
 // New .
@@ -44,6 +47,9 @@ func New(db *gorm.DB) *DB {
 		AlertNotifyTemplate:          AlertNotifyTemplateDB{db},
 		AlertRule:                    AlertRuleDB{db},
 		AlertRecord:                  AlertRecordDB{db},
+		AlertSilence:                 AlertSilenceDB{db},
+		AlertInhibitRule:             AlertInhibitRuleDB{db},
+		AlertGroupRule:               AlertGroupRuleDB{db},
 	}
 }
 
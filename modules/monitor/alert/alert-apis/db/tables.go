@@ -30,6 +30,9 @@ const (
 	TableAlertNotifyTemplate          = "sp_alert_notify_template"
 	TableAlertExpression              = "sp_alert_expression"
 	TableAlert                        = "sp_alert"
+	TableAlertSilence                 = "sp_alert_silence"
+	TableAlertInhibitRule             = "sp_alert_inhibit_rule"
+	TableAlertGroupRule               = "sp_alert_group_rule"
 )
 
 type AlertRecord struct {
@@ -197,3 +200,55 @@ type Alert struct {
 
 // TableName 。
 func (Alert) TableName() string { return TableAlert }
+
+// AlertSilence .
+type AlertSilence struct {
+	ID        uint64    `gorm:"column:id"`
+	Scope     string    `gorm:"column:scope"`
+	ScopeID   string    `gorm:"column:scope_id"`
+	Matchers  string    `gorm:"column:matchers"`
+	StartsAt  time.Time `gorm:"column:starts_at"`
+	EndsAt    time.Time `gorm:"column:ends_at"`
+	Creator   string    `gorm:"column:creator"`
+	Remark    string    `gorm:"column:remark"`
+	CreatedAt time.Time `gorm:"column:created_at"`
+	UpdatedAt time.Time `gorm:"column:updated_at"`
+}
+
+// TableName .
+func (AlertSilence) TableName() string { return TableAlertSilence }
+
+// AlertInhibitRule .
+type AlertInhibitRule struct {
+	ID             uint64    `gorm:"column:id"`
+	Scope          string    `gorm:"column:scope"`
+	ScopeID        string    `gorm:"column:scope_id"`
+	Name           string    `gorm:"column:name"`
+	SourceMatchers string    `gorm:"column:source_matchers"`
+	TargetMatchers string    `gorm:"column:target_matchers"`
+	EqualTags      string    `gorm:"column:equal_tags"`
+	IsEnabled      bool      `gorm:"column:is_enabled"`
+	CreatedAt      time.Time `gorm:"column:created_at"`
+	UpdatedAt      time.Time `gorm:"column:updated_at"`
+}
+
+// TableName .
+func (AlertInhibitRule) TableName() string { return TableAlertInhibitRule }
+
+// AlertGroupRule .
+type AlertGroupRule struct {
+	ID            uint64    `gorm:"column:id"`
+	Scope         string    `gorm:"column:scope"`
+	ScopeID       string    `gorm:"column:scope_id"`
+	Name          string    `gorm:"column:name"`
+	Matchers      string    `gorm:"column:matchers"`
+	GroupBy       string    `gorm:"column:group_by"`
+	GroupWait     int64     `gorm:"column:group_wait"`
+	GroupInterval int64     `gorm:"column:group_interval"`
+	IsEnabled     bool      `gorm:"column:is_enabled"`
+	CreatedAt     time.Time `gorm:"column:created_at"`
+	UpdatedAt     time.Time `gorm:"column:updated_at"`
+}
+
+// TableName .
+func (AlertGroupRule) TableName() string { return TableAlertGroupRule }
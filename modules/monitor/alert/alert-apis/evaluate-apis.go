@@ -0,0 +1,178 @@
+// Copyright (c) 2021 Terminus, Inc.
+//
+// This program is free software: you can use, redistribute, and/or modify
+// it under the terms of the GNU Affero General Public License, version 3
+// or later ("AGPL"), as published by the Free Software Foundation.
+//
+// This program is distributed in the hope that it will be useful, but WITHOUT
+// ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+// FITNESS FOR A PARTICULAR PURPOSE.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package apis
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/olivere/elastic"
+
+	"github.com/erda-project/erda-infra/modcom/api"
+	"github.com/erda-project/erda-infra/providers/i18n"
+	"github.com/erda-project/erda/modules/monitor/alert/evaluator"
+	"github.com/erda-project/erda/modules/monitor/core/metrics/metricq"
+	"github.com/erda-project/erda/modules/monitor/core/metrics/metricq/query"
+)
+
+// maxDryRunPoints limits the raw points loaded for one dry run.
+const maxDryRunPoints = 10000
+
+var errTooManyDryRunPoints = fmt.Errorf("more than %d points matched, narrow the time range or add filters", maxDryRunPoints)
+
+type dryRunRequest struct {
+	Expression map[string]interface{} `json:"expression"`
+	Start      int64                  `json:"start"`
+	End        int64                  `json:"end"`
+	Step       string                 `json:"step"`
+}
+
+func (p *provider) dryRunAlertRule(req dryRunRequest) interface{} {
+	rule, err := evaluator.ParseRule("dry-run", req.Expression)
+	if err != nil {
+		return api.Errors.InvalidParameter(err)
+	}
+	return p.dryRun(rule, req)
+}
+
+// dryRunOrgAlertRule replaces the scope filters of rule with the ones of caller's org,
+// so that the points of other orgs can't be loaded.
+func (p *provider) dryRunOrgAlertRule(r *http.Request, req dryRunRequest) interface{} {
+	rule, err := evaluator.ParseRule("dry-run", req.Expression)
+	if err != nil {
+		return api.Errors.InvalidParameter(err)
+	}
+	org, err := p.bdl.GetOrg(api.OrgID(r))
+	if err != nil {
+		return api.Errors.Internal(err)
+	}
+	lang := i18n.LanguageCodes{{Code: ""}}
+	metricMeta, err := p.metricq.MetricMeta(lang, "org", org.Name, rule.Metric)
+	if err != nil {
+		return api.Errors.Internal(err)
+	}
+	if len(metricMeta) <= 0 {
+		return api.Errors.InvalidParameter(fmt.Errorf("metric %q not found", rule.Metric))
+	}
+	filters := make([]*evaluator.Filter, 0, len(rule.Filters)+2)
+	for _, f := range rule.Filters {
+		if p.orgFilterTags[f.Tag] || f.Tag == "org_name" {
+			continue
+		}
+		filters = append(filters, f)
+	}
+	labels := metricMeta[0].Labels
+	if scope := labels["metric_scope"]; scope != "" {
+		filters = append(filters,
+			&evaluator.Filter{Tag: "_metric_scope", Operator: "eq", Value: scope},
+			&evaluator.Filter{Tag: "_metric_scope_id", Operator: "eq", Value: labels["metric_scope_id"]},
+		)
+	} else {
+		filters = append(filters, &evaluator.Filter{Tag: "org_name", Operator: "eq", Value: org.Name})
+	}
+	rule.Filters = filters
+	return p.dryRun(rule, req)
+}
+
+func (p *provider) dryRun(rule *evaluator.Rule, req dryRunRequest) interface{} {
+	var err error
+	end := time.Now()
+	if req.End > 0 {
+		end = time.Unix(0, req.End*int64(time.Millisecond))
+	}
+	start := end.Add(-24 * time.Hour)
+	if req.Start > 0 {
+		start = time.Unix(0, req.Start*int64(time.Millisecond))
+	}
+	var step time.Duration
+	if len(req.Step) > 0 {
+		step, err = time.ParseDuration(req.Step)
+		if err != nil {
+			return api.Errors.InvalidParameter(fmt.Errorf("invalid step: %s", err))
+		}
+	}
+	if end.Sub(start) > 7*24*time.Hour {
+		return api.Errors.InvalidParameter("time range must not be longer than 7 days")
+	}
+	timeline, err := evaluator.DryRun(&metricqSource{metricq: p.metricq}, rule, start, end, step)
+	if err != nil {
+		if errors.Is(err, errTooManyDryRunPoints) {
+			return api.Errors.InvalidParameter(err)
+		}
+		return api.Errors.Internal(err)
+	}
+	return api.Success(timeline)
+}
+
+// metricqSource loads raw points of metric from metrics-query for evaluator.
+type metricqSource struct {
+	metricq metricq.Queryer
+}
+
+func (s *metricqSource) Points(metric string, filters []*evaluator.Filter, start, end int64) ([]*evaluator.Point, error) {
+	boolQuery := elastic.NewBoolQuery().
+		Filter(elastic.NewTermQuery(query.NameKey, metric)).
+		Filter(elastic.NewRangeQuery(query.TimestampKey).Gte(start * int64(time.Millisecond)).Lt(end * int64(time.Millisecond)))
+	var clusters []string
+	for _, f := range filters {
+		key := query.TagKey + "." + f.Tag
+		switch f.Operator {
+		case "eq":
+			boolQuery.Filter(elastic.NewTermQuery(key, f.Value))
+			if f.Tag == "cluster_name" {
+				clusters = append(clusters, fmt.Sprint(f.Value))
+			}
+		case "in":
+			if list, ok := f.Value.([]interface{}); ok {
+				boolQuery.Filter(elastic.NewTermsQuery(key, list...))
+			}
+		}
+	}
+	searchSource := elastic.NewSearchSource().Query(boolQuery).
+		Size(maxDryRunPoints).Sort(query.TimestampKey, true)
+	resp, err := s.metricq.QueryRaw([]string{metric}, clusters, start, end, searchSource)
+	if err != nil {
+		return nil, err
+	}
+	if resp == nil || resp.Hits == nil {
+		return nil, nil
+	}
+	// evaluating on part of the points gives a wrong timeline, so refuse it
+	if resp.Hits.TotalHits > maxDryRunPoints {
+		return nil, errTooManyDryRunPoints
+	}
+	points := make([]*evaluator.Point, 0, len(resp.Hits.Hits))
+	for _, hit := range resp.Hits.Hits {
+		if hit.Source == nil {
+			continue
+		}
+		var source struct {
+			Timestamp int64                  `json:"timestamp"`
+			Tags      map[string]string      `json:"tags"`
+			Fields    map[string]interface{} `json:"fields"`
+		}
+		if err := json.Unmarshal(*hit.Source, &source); err != nil {
+			continue
+		}
+		points = append(points, &evaluator.Point{
+			Timestamp: source.Timestamp / int64(time.Millisecond),
+			Tags:      source.Tags,
+			Fields:    source.Fields,
+		})
+	}
+	return points, nil
+}
@@ -22,6 +22,7 @@ import (
 	"github.com/erda-project/erda-infra/modcom/api"
 	"github.com/erda-project/erda-infra/providers/httpserver"
 	"github.com/erda-project/erda-infra/providers/i18n"
+	"github.com/erda-project/erda/apistructs"
 	"github.com/erda-project/erda/modules/monitor/alert/alert-apis/adapt"
 	"github.com/erda-project/erda/modules/monitor/common/permission"
 	"github.com/erda-project/erda/modules/monitor/core/metrics"
@@ -65,19 +66,56 @@ func (p *provider) queryOrgCustomizeMetric(r *http.Request, params struct {
 		}
 	}
 	cms.FilterOperators = filterOperators
-	if lang == nil {
-		cms.NotifySample = adapt.OrgNotifyTemplateSample
-	} else {
-		cms.NotifySample = adapt.OrgNotifyTemplateSampleEn
+	locale := "zh"
+	if lang != nil {
+		locale = "en"
 		for _, v := range lang {
 			if strings.HasPrefix(v.Code, "zh") {
-				cms.NotifySample = adapt.OrgNotifyTemplateSample
+				locale = "zh"
 			}
 		}
 	}
+	sample, err := p.renderOrgNotifySample(locale)
+	if err != nil {
+		p.L.Warnf("fail to render notify sample, use the local one: %s", err)
+		sample = adapt.OrgNotifyTemplateSample
+		if locale == "en" {
+			sample = adapt.OrgNotifyTemplateSampleEn
+		}
+	}
+	cms.NotifySample = sample
 	return api.Success(cms)
 }
 
+// orgNotifySampleParams are the placeholders of the alert template, which the built-in sample template of eventbox shows.
+var orgNotifySampleParams = map[string]string{
+	"load5_avg":    "{{load5_avg}}",
+	"cluster_name": "{{cluster_name}}",
+	"host_ip":      "{{host_ip}}",
+	"timestamp":    "{{timestamp}}",
+	"display_url":  "{{display_url}}",
+	"record_url":   "{{record_url}}",
+	"ack_url":      "{{ack_url}}",
+}
+
+// renderOrgNotifySample renders the notify sample by the message template registry of eventbox, it can be customized there.
+func (p *provider) renderOrgNotifySample(locale string) (string, error) {
+	rendered, err := p.bdl.PreviewMessageTemplate(&apistructs.MessageTemplatePreviewRequest{
+		Template: apistructs.MessageTemplate{
+			Event:   apistructs.OrgAlertNotifySampleTemplateEvent,
+			Channel: "default",
+			Locale:  locale,
+		},
+		Message: apistructs.EventBoxRequest{
+			Content: map[string]interface{}{"params": orgNotifySampleParams},
+		},
+	})
+	if err != nil {
+		return "", err
+	}
+	return rendered.Body, nil
+}
+
 func (p *provider) queryOrgCustomizeAlerts(r *http.Request, params struct {
 	PageNo   int `query:"pageNo" validate:"gte=1"`
 	PageSize int `query:"pageSize" validate:"gte=1,lte=100"`
@@ -0,0 +1,69 @@
+// Copyright (c) 2021 Terminus, Inc.
+//
+// This program is free software: you can use, redistribute, and/or modify
+// it under the terms of the GNU Affero General Public License, version 3
+// or later ("AGPL"), as published by the Free Software Foundation.
+//
+// This program is distributed in the hope that it will be useful, but WITHOUT
+// ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+// FITNESS FOR A PARTICULAR PURPOSE.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package apis
+
+import (
+	"fmt"
+
+	"github.com/erda-project/erda-infra/providers/httpserver"
+	"github.com/erda-project/erda/modules/monitor/common"
+	"github.com/erda-project/erda/modules/monitor/common/permission"
+)
+
+// checkAlertRecordPermission checks the permission of the scope in query, alert records of micro_service scope
+// belong to the project of the tenant group.
+func (p *provider) checkAlertRecordPermission(action permission.Action) httpserver.Interceptor {
+	return permission.Intercepter(
+		permission.ValueGetter(p.getPermissionScope), p.getPermissionScopeID,
+		permission.ValueGetter(p.getPermissionResource), action,
+	)
+}
+
+func (p *provider) getPermissionScope(ctx httpserver.Context) (string, error) {
+	switch scope := ctx.Request().URL.Query().Get("scope"); scope {
+	case permission.ScopeOrg:
+		return permission.ScopeOrg, nil
+	case permission.ScopeMicroService:
+		return permission.ScopeProject, nil
+	default:
+		return "", fmt.Errorf("not support scope %q", scope)
+	}
+}
+
+func (p *provider) getPermissionResource(ctx httpserver.Context) (string, error) {
+	if ctx.Request().URL.Query().Get("scope") == permission.ScopeOrg {
+		return common.ResourceOrgAlert, nil
+	}
+	return common.ResourceMonitorProjectAlert, nil
+}
+
+func (p *provider) getPermissionScopeID(ctx httpserver.Context) (string, error) {
+	query := ctx.Request().URL.Query()
+	scopeID := query.Get("scopeId")
+	if len(scopeID) <= 0 {
+		return "", fmt.Errorf("scopeId must not be empty")
+	}
+	switch query.Get("scope") {
+	case permission.ScopeOrg:
+		return scopeID, nil
+	case permission.ScopeMicroService:
+	default:
+		return "", fmt.Errorf("not support scope %q", query.Get("scope"))
+	}
+	tk, err := p.authDb.InstanceTenant.QueryTkByTenantGroup(scopeID)
+	if err != nil {
+		return "", err
+	}
+	return p.authDb.Monitor.SelectProjectIdByTk(tk)
+}
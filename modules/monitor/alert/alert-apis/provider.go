@@ -29,6 +29,7 @@ import (
 	"github.com/erda-project/erda/modules/monitor/alert/alert-apis/adapt"
 	"github.com/erda-project/erda/modules/monitor/alert/alert-apis/cql"
 	"github.com/erda-project/erda/modules/monitor/alert/alert-apis/db"
+	authdb "github.com/erda-project/erda/modules/monitor/common/db"
 	"github.com/erda-project/erda/modules/monitor/core/metrics/metricq"
 	block "github.com/erda-project/erda/modules/monitor/dashboard/chart-block"
 	"github.com/erda-project/erda/modules/pkg/bundle-ex/cmdb"
@@ -65,6 +66,7 @@ type provider struct {
 	metricq                     metricq.Queryer
 	t                           i18n.Translator
 	db                          *db.DB
+	authDb                      *authdb.DB
 	cql                         *cql.Cql
 	a                           *adapt.Adapt
 	bdl                         *bundle.Bundle
@@ -116,12 +118,14 @@ func (p *provider) Init(ctx servicehub.Context) error {
 
 	p.t = ctx.Service("i18n").(i18n.I18n).Translator("alert")
 	p.db = db.New(ctx.Service("mysql").(mysql.Interface).DB())
+	p.authDb = authdb.New(ctx.Service("mysql").(mysql.Interface).DB())
 	p.metricq = ctx.Service("metrics-query").(metricq.Queryer)
 	hc := httpclient.New(httpclient.WithTimeout(time.Second, time.Second*60))
 	p.cmdb = cmdb.New(cmdb.WithHTTPClient(hc))
 	p.bdl = bundle.New(
 		bundle.WithHTTPClient(hc),
 		bundle.WithCMDB(),
+		bundle.WithEventBox(),
 	)
 
 	dashapi := ctx.Service("chart-block").(block.DashboardAPI)
@@ -64,6 +64,13 @@ func (p *provider) intRoutes(routes httpserver.Router) error {
 		common.ResourceOrgAlert, permission.ActionCreate,
 	))
 
+	// Alarm rule dry run
+	routes.POST("/api/alerts/rules/dry-run", p.dryRunAlertRule)
+	routes.POST("/api/orgs/alerts/rules/dry-run", p.dryRunOrgAlertRule, permission.Intercepter(
+		permission.ScopeOrg, permission.OrgIDFromHeader(),
+		common.ResourceOrgAlert, permission.ActionList,
+	))
+
 	// alert
 	routes.GET("/api/alerts/rules", p.queryAlertRule)
 	routes.GET("/api/alerts", p.queryAlert)
@@ -104,11 +111,43 @@ func (p *provider) intRoutes(routes httpserver.Router) error {
 		common.ResourceOrgAlert, permission.ActionDelete,
 	))
 
+	// Alarm silences, inhibit rules and group rules
+	routes.GET("/api/alerts/silences", p.queryAlertSilences)
+	routes.POST("/api/alerts/silences", p.createAlertSilence)
+	routes.DELETE("/api/alerts/silences/:id", p.expireAlertSilence)
+	routes.GET("/api/alerts/inhibit-rules", p.queryAlertInhibitRules)
+	routes.POST("/api/alerts/inhibit-rules", p.createAlertInhibitRule)
+	routes.PUT("/api/alerts/inhibit-rules/:id", p.updateAlertInhibitRule)
+	routes.DELETE("/api/alerts/inhibit-rules/:id", p.deleteAlertInhibitRule)
+	routes.GET("/api/alerts/group-rules", p.queryAlertGroupRules)
+	routes.POST("/api/alerts/group-rules", p.createAlertGroupRule)
+	routes.PUT("/api/alerts/group-rules/:id", p.updateAlertGroupRule)
+	routes.DELETE("/api/alerts/group-rules/:id", p.deleteAlertGroupRule)
+
+	// Enterprise alarm silences, inhibit rules and group rules
+	checkUpdateOrgAlertPermission := permission.Intercepter(
+		permission.ScopeOrg, permission.OrgIDFromHeader(),
+		common.ResourceOrgAlert, permission.ActionUpdate,
+	)
+	routes.GET("/api/orgs/alerts/silences", p.queryOrgAlertSilences, checkListOrgAlertPermission)
+	routes.POST("/api/orgs/alerts/silences", p.createOrgAlertSilence, checkUpdateOrgAlertPermission)
+	routes.DELETE("/api/orgs/alerts/silences/:id", p.expireOrgAlertSilence, checkUpdateOrgAlertPermission)
+	routes.GET("/api/orgs/alerts/inhibit-rules", p.queryOrgAlertInhibitRules, checkListOrgAlertPermission)
+	routes.POST("/api/orgs/alerts/inhibit-rules", p.createOrgAlertInhibitRule, checkUpdateOrgAlertPermission)
+	routes.PUT("/api/orgs/alerts/inhibit-rules/:id", p.updateOrgAlertInhibitRule, checkUpdateOrgAlertPermission)
+	routes.DELETE("/api/orgs/alerts/inhibit-rules/:id", p.deleteOrgAlertInhibitRule, checkUpdateOrgAlertPermission)
+	routes.GET("/api/orgs/alerts/group-rules", p.queryOrgAlertGroupRules, checkListOrgAlertPermission)
+	routes.POST("/api/orgs/alerts/group-rules", p.createOrgAlertGroupRule, checkUpdateOrgAlertPermission)
+	routes.PUT("/api/orgs/alerts/group-rules/:id", p.updateOrgAlertGroupRule, checkUpdateOrgAlertPermission)
+	routes.DELETE("/api/orgs/alerts/group-rules/:id", p.deleteOrgAlertGroupRule, checkUpdateOrgAlertPermission)
+
 	// Alarm record
 	routes.GET("/api/alert-record-attrs", p.getAlertRecordAttr)
 	routes.GET("/api/alert-records", p.queryAlertRecord)
 	routes.GET("/api/alert-records/:groupId", p.getAlertRecord)
 	routes.GET("/api/alert-records/:groupId/histories", p.queryAlertHistory)
+	routes.POST("/api/alert-records/:groupId/ack", p.ackAlertRecord, p.checkAlertRecordPermission(permission.ActionUpdate))
+	routes.GET("/api/alert-records/:groupId/ack", p.ackConfirmPage) // linked by ack_url of notifications
 	routes.POST("/api/alert-records/:groupId/issues", p.createAlertIssue)
 	routes.PUT("/api/alert-records/:groupId/issues/:issueId", p.updateAlertIssue)
 
@@ -133,6 +172,8 @@ func (p *provider) intRoutes(routes httpserver.Router) error {
 		permission.ScopeOrg, permission.OrgIDFromHeader(),
 		common.ResourceOrgAlert, permission.ActionList,
 	))
+	routes.POST("/api/org-alert-records/:groupId/ack", p.ackOrgAlertRecord, checkUpdateOrgAlertPermission)
+	routes.GET("/api/org-alert-records/:groupId/ack", p.ackConfirmPage) // linked by ack_url of notifications
 	routes.POST("/api/org-alert-records/:groupId/issues", p.createOrgAlertIssue, permission.Intercepter(
 		permission.ScopeOrg, permission.OrgIDFromHeader(),
 		common.ResourceOrgAlert, permission.ActionCreate,
@@ -0,0 +1,349 @@
+// Copyright (c) 2021 Terminus, Inc.
+//
+// This program is free software: you can use, redistribute, and/or modify
+// it under the terms of the GNU Affero General Public License, version 3
+// or later ("AGPL"), as published by the Free Software Foundation.
+//
+// This program is distributed in the hope that it will be useful, but WITHOUT
+// ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+// FITNESS FOR A PARTICULAR PURPOSE.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package apis
+
+import (
+	"fmt"
+	"html/template"
+	"net/http"
+	"time"
+
+	"github.com/erda-project/erda-infra/modcom/api"
+	"github.com/erda-project/erda/modules/monitor/alert/alert-apis/adapt"
+)
+
+const (
+	orgScope           = "org"
+	defaultAckDuration = time.Hour
+)
+
+type scopeParams struct {
+	Scope   string `query:"scope" validate:"required"`
+	ScopeID string `query:"scopeId" validate:"required"`
+}
+
+// silences
+
+func (p *provider) queryAlertSilences(params struct {
+	scopeParams
+	Active bool `query:"active"`
+}) interface{} {
+	return p.listAlertSilences(params.Scope, params.ScopeID, params.Active)
+}
+
+func (p *provider) queryOrgAlertSilences(r *http.Request, params struct {
+	Active bool `query:"active"`
+}) interface{} {
+	return p.listAlertSilences(orgScope, api.OrgID(r), params.Active)
+}
+
+func (p *provider) listAlertSilences(scope, scopeID string, active bool) interface{} {
+	list, err := p.a.QueryAlertSilences(scope, scopeID, active)
+	if err != nil {
+		return api.Errors.Internal(err)
+	}
+	return api.Success(&listResult{list, len(list)})
+}
+
+func (p *provider) createAlertSilence(r *http.Request, params scopeParams, silence adapt.AlertSilence) interface{} {
+	silence.Scope, silence.ScopeID = params.Scope, params.ScopeID
+	return p.saveAlertSilence(r, &silence)
+}
+
+func (p *provider) createOrgAlertSilence(r *http.Request, silence adapt.AlertSilence) interface{} {
+	silence.Scope, silence.ScopeID = orgScope, api.OrgID(r)
+	return p.saveAlertSilence(r, &silence)
+}
+
+func (p *provider) saveAlertSilence(r *http.Request, silence *adapt.AlertSilence) interface{} {
+	silence.ID = 0
+	silence.Creator = api.UserID(r)
+	id, err := p.a.CreateAlertSilence(silence)
+	if err != nil {
+		if adapt.IsInvalidParameterError(err) {
+			return api.Errors.InvalidParameter(err)
+		}
+		return api.Errors.Internal(err)
+	}
+	return api.Success(id)
+}
+
+func (p *provider) expireAlertSilence(params struct {
+	scopeParams
+	ID uint64 `param:"id" validate:"required,gt=0"`
+}) interface{} {
+	return p.doExpireAlertSilence(params.Scope, params.ScopeID, params.ID)
+}
+
+func (p *provider) expireOrgAlertSilence(r *http.Request, params struct {
+	ID uint64 `param:"id" validate:"required,gt=0"`
+}) interface{} {
+	return p.doExpireAlertSilence(orgScope, api.OrgID(r), params.ID)
+}
+
+func (p *provider) doExpireAlertSilence(scope, scopeID string, id uint64) interface{} {
+	ok, err := p.a.ExpireAlertSilence(scope, scopeID, id)
+	if err != nil {
+		return api.Errors.Internal(err)
+	} else if !ok {
+		return api.Errors.NotFound(fmt.Sprintf("silence %d", id))
+	}
+	return api.Success(nil)
+}
+
+type ackParams struct {
+	GroupID  string `param:"groupId" validate:"required"`
+	Duration string `query:"duration"`
+}
+
+// ackAlertRecord silences the alert of record.
+func (p *provider) ackAlertRecord(r *http.Request, params struct {
+	scopeParams
+	ackParams
+}) interface{} {
+	return p.doAckAlertRecord(r, params.Scope, params.ScopeID, &params.ackParams)
+}
+
+func (p *provider) ackOrgAlertRecord(r *http.Request, params ackParams) interface{} {
+	return p.doAckAlertRecord(r, orgScope, api.OrgID(r), &params)
+}
+
+func (p *provider) doAckAlertRecord(r *http.Request, scope, scopeID string, params *ackParams) interface{} {
+	duration := defaultAckDuration
+	if len(params.Duration) > 0 {
+		d, err := time.ParseDuration(params.Duration)
+		if err != nil || d <= 0 {
+			return api.Errors.InvalidParameter(fmt.Errorf("invalid duration %q", params.Duration))
+		}
+		duration = d
+	}
+	id, err := p.a.AckAlertRecord(scope, scopeID, api.UserID(r), params.GroupID, duration)
+	if err != nil {
+		if adapt.IsInvalidParameterError(err) {
+			return api.Errors.InvalidParameter(err)
+		}
+		return api.Errors.Internal(err)
+	} else if id == 0 {
+		return api.Errors.NotFound("alert record " + params.GroupID)
+	}
+	return api.Success(id)
+}
+
+// ackConfirmTemplate acks the record by POST to the same url, it carries the csrf token of openapi from the cookie.
+var ackConfirmTemplate = template.Must(template.New("ack").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>确认告警</title>
+</head>
+<body>
+<p>确认告警 {{.GroupID}} 后, 该告警在{{if .Duration}} {{.Duration}} {{else}}默认时长{{end}}内不再通知。</p>
+<button id="ack" onclick="ack()">确认</button>
+<p id="result"></p>
+<script>
+function ack() {
+	var token = document.cookie.match(/(?:^|;\s*)OPENAPI-CSRF-TOKEN=([^;]*)/);
+	var result = document.getElementById("result");
+	document.getElementById("ack").disabled = true;
+	fetch(location.pathname + location.search, {
+		method: "POST",
+		credentials: "same-origin",
+		headers: {"OPENAPI-CSRF-TOKEN": token ? decodeURIComponent(token[1]) : ""}
+	}).then(function (resp) {
+		return resp.json();
+	}).then(function (body) {
+		result.textContent = body.success ? "已确认" : "确认失败: " + (body.err && body.err.msg);
+	}).catch(function (err) {
+		result.textContent = "确认失败: " + err;
+	});
+}
+</script>
+</body>
+</html>
+`))
+
+// ackConfirmPage is linked by the "ack" action of notifications, opening the link only shows the page,
+// the record is acked by the POST of the page.
+func (p *provider) ackConfirmPage(w http.ResponseWriter, params ackParams) interface{} {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := ackConfirmTemplate.Execute(w, &params); err != nil {
+		p.L.Errorf("fail to render ack confirm page: %s", err)
+	}
+	return nil
+}
+
+// inhibit rules
+
+func (p *provider) queryAlertInhibitRules(params scopeParams) interface{} {
+	return p.listAlertInhibitRules(params.Scope, params.ScopeID)
+}
+
+func (p *provider) queryOrgAlertInhibitRules(r *http.Request) interface{} {
+	return p.listAlertInhibitRules(orgScope, api.OrgID(r))
+}
+
+func (p *provider) listAlertInhibitRules(scope, scopeID string) interface{} {
+	list, err := p.a.QueryAlertInhibitRules(scope, scopeID)
+	if err != nil {
+		return api.Errors.Internal(err)
+	}
+	return api.Success(&listResult{list, len(list)})
+}
+
+func (p *provider) createAlertInhibitRule(params scopeParams, rule adapt.AlertInhibitRule) interface{} {
+	rule.ID, rule.Scope, rule.ScopeID = 0, params.Scope, params.ScopeID
+	return p.saveAlertInhibitRule(&rule)
+}
+
+func (p *provider) createOrgAlertInhibitRule(r *http.Request, rule adapt.AlertInhibitRule) interface{} {
+	rule.ID, rule.Scope, rule.ScopeID = 0, orgScope, api.OrgID(r)
+	return p.saveAlertInhibitRule(&rule)
+}
+
+func (p *provider) updateAlertInhibitRule(params struct {
+	scopeParams
+	ID uint64 `param:"id" validate:"required,gt=0"`
+}, rule adapt.AlertInhibitRule) interface{} {
+	rule.ID, rule.Scope, rule.ScopeID = params.ID, params.Scope, params.ScopeID
+	return p.saveAlertInhibitRule(&rule)
+}
+
+func (p *provider) updateOrgAlertInhibitRule(r *http.Request, params struct {
+	ID uint64 `param:"id" validate:"required,gt=0"`
+}, rule adapt.AlertInhibitRule) interface{} {
+	rule.ID, rule.Scope, rule.ScopeID = params.ID, orgScope, api.OrgID(r)
+	return p.saveAlertInhibitRule(&rule)
+}
+
+func (p *provider) saveAlertInhibitRule(rule *adapt.AlertInhibitRule) interface{} {
+	if rule.ID == 0 {
+		id, err := p.a.CreateAlertInhibitRule(rule)
+		if err != nil {
+			return saveError(err)
+		}
+		return api.Success(id)
+	}
+	ok, err := p.a.UpdateAlertInhibitRule(rule)
+	if err != nil {
+		return saveError(err)
+	} else if !ok {
+		return api.Errors.NotFound(fmt.Sprintf("inhibit rule %d", rule.ID))
+	}
+	return api.Success(rule.ID)
+}
+
+func (p *provider) deleteAlertInhibitRule(params struct {
+	scopeParams
+	ID uint64 `param:"id" validate:"required,gt=0"`
+}) interface{} {
+	if err := p.a.DeleteAlertInhibitRule(params.Scope, params.ScopeID, params.ID); err != nil {
+		return api.Errors.Internal(err)
+	}
+	return api.Success(nil)
+}
+
+func (p *provider) deleteOrgAlertInhibitRule(r *http.Request, params struct {
+	ID uint64 `param:"id" validate:"required,gt=0"`
+}) interface{} {
+	if err := p.a.DeleteAlertInhibitRule(orgScope, api.OrgID(r), params.ID); err != nil {
+		return api.Errors.Internal(err)
+	}
+	return api.Success(nil)
+}
+
+// group rules
+
+func (p *provider) queryAlertGroupRules(params scopeParams) interface{} {
+	return p.listAlertGroupRules(params.Scope, params.ScopeID)
+}
+
+func (p *provider) queryOrgAlertGroupRules(r *http.Request) interface{} {
+	return p.listAlertGroupRules(orgScope, api.OrgID(r))
+}
+
+func (p *provider) listAlertGroupRules(scope, scopeID string) interface{} {
+	list, err := p.a.QueryAlertGroupRules(scope, scopeID)
+	if err != nil {
+		return api.Errors.Internal(err)
+	}
+	return api.Success(&listResult{list, len(list)})
+}
+
+func (p *provider) createAlertGroupRule(params scopeParams, rule adapt.AlertGroupRule) interface{} {
+	rule.ID, rule.Scope, rule.ScopeID = 0, params.Scope, params.ScopeID
+	return p.saveAlertGroupRule(&rule)
+}
+
+func (p *provider) createOrgAlertGroupRule(r *http.Request, rule adapt.AlertGroupRule) interface{} {
+	rule.ID, rule.Scope, rule.ScopeID = 0, orgScope, api.OrgID(r)
+	return p.saveAlertGroupRule(&rule)
+}
+
+func (p *provider) updateAlertGroupRule(params struct {
+	scopeParams
+	ID uint64 `param:"id" validate:"required,gt=0"`
+}, rule adapt.AlertGroupRule) interface{} {
+	rule.ID, rule.Scope, rule.ScopeID = params.ID, params.Scope, params.ScopeID
+	return p.saveAlertGroupRule(&rule)
+}
+
+func (p *provider) updateOrgAlertGroupRule(r *http.Request, params struct {
+	ID uint64 `param:"id" validate:"required,gt=0"`
+}, rule adapt.AlertGroupRule) interface{} {
+	rule.ID, rule.Scope, rule.ScopeID = params.ID, orgScope, api.OrgID(r)
+	return p.saveAlertGroupRule(&rule)
+}
+
+func (p *provider) saveAlertGroupRule(rule *adapt.AlertGroupRule) interface{} {
+	if rule.ID == 0 {
+		id, err := p.a.CreateAlertGroupRule(rule)
+		if err != nil {
+			return saveError(err)
+		}
+		return api.Success(id)
+	}
+	ok, err := p.a.UpdateAlertGroupRule(rule)
+	if err != nil {
+		return saveError(err)
+	} else if !ok {
+		return api.Errors.NotFound(fmt.Sprintf("group rule %d", rule.ID))
+	}
+	return api.Success(rule.ID)
+}
+
+func (p *provider) deleteAlertGroupRule(params struct {
+	scopeParams
+	ID uint64 `param:"id" validate:"required,gt=0"`
+}) interface{} {
+	if err := p.a.DeleteAlertGroupRule(params.Scope, params.ScopeID, params.ID); err != nil {
+		return api.Errors.Internal(err)
+	}
+	return api.Success(nil)
+}
+
+func (p *provider) deleteOrgAlertGroupRule(r *http.Request, params struct {
+	ID uint64 `param:"id" validate:"required,gt=0"`
+}) interface{} {
+	if err := p.a.DeleteAlertGroupRule(orgScope, api.OrgID(r), params.ID); err != nil {
+		return api.Errors.Internal(err)
+	}
+	return api.Success(nil)
+}
+
+func saveError(err error) interface{} {
+	if adapt.IsInvalidParameterError(err) {
+		return api.Errors.InvalidParameter(err)
+	}
+	return api.Errors.Internal(err)
+}
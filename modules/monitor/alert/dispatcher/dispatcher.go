@@ -0,0 +1,117 @@
+// Copyright (c) 2021 Terminus, Inc.
+//
+// This program is free software: you can use, redistribute, and/or modify
+// it under the terms of the GNU Affero General Public License, version 3
+// or later ("AGPL"), as published by the Free Software Foundation.
+//
+// This program is distributed in the hope that it will be useful, but WITHOUT
+// ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+// FITNESS FOR A PARTICULAR PURPOSE.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package dispatcher
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/erda-project/erda/apistructs"
+	"github.com/erda-project/erda/modules/monitor/alert/alert-apis/adapt"
+	"github.com/erda-project/erda/modules/monitor/alert/silence"
+	"github.com/erda-project/erda/modules/monitor/utils"
+)
+
+func (p *provider) invoke(key []byte, value []byte, topic *string, timestamp time.Time) error {
+	// forwarded by eventbox, Message carries the ALERT-DISPATCHED label to be delivered when sent back
+	n := &apistructs.AlertNotification{}
+	if err := json.Unmarshal(value, n); err != nil {
+		return err
+	}
+	if n.Message == nil {
+		return nil
+	}
+	alert := &silence.Alert{
+		Key:       n.Tags[adapt.AlertGroupTag],
+		Tags:      n.Tags,
+		Firing:    n.AlertState != string(adapt.AlertStateRecover),
+		Timestamp: n.Timestamp,
+		Payload:   n.Message,
+	}
+	if len(alert.Key) <= 0 {
+		alert.Key = fmt.Sprint(n.Tags)
+	}
+	p.muter.Observe(alert)
+	if reason, muted := p.muter.Mutes(alert, utils.ConvertTimeToMS(time.Now())); muted {
+		p.L.Debugf("alert %s is muted: %s", alert.Key, reason)
+		return nil
+	}
+	p.grouper.Add(alert, time.Now())
+	return nil
+}
+
+// dispatch sends one message for the group, the contents of text messages are merged.
+func (p *provider) dispatch(g *silence.Group) {
+	var texts []string
+	var first *apistructs.MessageCreateRequest
+	for _, a := range g.Alerts {
+		msg := a.Payload.(*apistructs.MessageCreateRequest)
+		content, ok := msg.Content.(string)
+		if !ok {
+			p.send(msg)
+			continue
+		}
+		if first == nil {
+			first = msg
+		}
+		texts = append(texts, content)
+	}
+	if first == nil {
+		return
+	}
+	merged := *first
+	merged.Content = strings.Join(texts, "\n\n---\n\n")
+	p.send(&merged)
+}
+
+func (p *provider) send(msg *apistructs.MessageCreateRequest) {
+	if err := p.bdl.CreateMessage(msg); err != nil {
+		p.L.Errorf("fail to send alert message to eventbox: %s", err)
+	}
+}
+
+func (p *provider) reload() error {
+	silences, err := p.db.AlertSilence.QueryActive(time.Now())
+	if err != nil {
+		return err
+	}
+	var ss []*silence.Silence
+	for _, s := range silences {
+		ss = append(ss, (&adapt.AlertSilence{}).FromModel(s).ToSilence())
+	}
+	p.muter.SetSilences(ss)
+
+	inhibits, err := p.db.AlertInhibitRule.QueryEnabled()
+	if err != nil {
+		return err
+	}
+	var irs []*silence.InhibitRule
+	for _, r := range inhibits {
+		irs = append(irs, (&adapt.AlertInhibitRule{}).FromModel(r).ToInhibitRule())
+	}
+	p.muter.SetInhibitRules(irs)
+
+	groups, err := p.db.AlertGroupRule.QueryEnabled()
+	if err != nil {
+		return err
+	}
+	var grs []*silence.GroupRule
+	for _, r := range groups {
+		grs = append(grs, (&adapt.AlertGroupRule{}).FromModel(r).ToGroupRule())
+	}
+	p.grouper.SetRules(grs)
+	return nil
+}
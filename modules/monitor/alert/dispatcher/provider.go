@@ -0,0 +1,109 @@
+// Copyright (c) 2021 Terminus, Inc.
+//
+// This program is free software: you can use, redistribute, and/or modify
+// it under the terms of the GNU Affero General Public License, version 3
+// or later ("AGPL"), as published by the Free Software Foundation.
+//
+// This program is distributed in the hope that it will be useful, but WITHOUT
+// ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+// FITNESS FOR A PARTICULAR PURPOSE.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package dispatcher
+
+import (
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/erda-project/erda-infra/base/logs"
+	"github.com/erda-project/erda-infra/base/servicehub"
+	"github.com/erda-project/erda-infra/providers/kafka"
+	"github.com/erda-project/erda-infra/providers/mysql"
+	"github.com/erda-project/erda/bundle"
+	"github.com/erda-project/erda/modules/monitor/alert/alert-apis/db"
+	"github.com/erda-project/erda/modules/monitor/alert/silence"
+	"github.com/erda-project/erda/pkg/http/httpclient"
+)
+
+type define struct{}
+
+func (d *define) Services() []string     { return []string{"alert-dispatcher"} }
+func (d *define) Dependencies() []string { return []string{"kafka", "mysql"} }
+func (d *define) Summary() string        { return "alert notification dispatcher" }
+func (d *define) Description() string    { return d.Summary() }
+func (d *define) Config() interface{}    { return &config{} }
+func (d *define) Creator() servicehub.Creator {
+	return func() servicehub.Provider {
+		return &provider{}
+	}
+}
+
+type config struct {
+	Input          kafka.ConsumerConfig `file:"input"`
+	ReloadInterval time.Duration        `file:"reload_interval" default:"30s"`
+	TickInterval   time.Duration        `file:"tick_interval" default:"5s"`
+}
+
+// provider keeps the firing alerts and groups in memory, the collector sends the alert notifications of one scope
+// to the same partition, so each scope is dispatched by one replica. Inhibit rules and group rules without scope
+// only work on the alerts consumed by the same replica.
+type provider struct {
+	C       *config
+	L       logs.Logger
+	db      *db.DB
+	kafka   kafka.Interface
+	bdl     *bundle.Bundle
+	muter   *silence.Muter
+	grouper *silence.Grouper
+	closeCh chan struct{}
+}
+
+func (p *provider) Init(ctx servicehub.Context) error {
+	p.db = db.New(ctx.Service("mysql").(mysql.Interface).DB())
+	p.kafka = ctx.Service("kafka").(kafka.Interface)
+	p.bdl = bundle.New(
+		bundle.WithHTTPClient(httpclient.New(httpclient.WithTimeout(time.Second, time.Second*60))),
+		bundle.WithEventBox(),
+	)
+	p.muter = silence.NewMuter()
+	p.grouper = silence.NewGrouper(p.dispatch)
+	p.closeCh = make(chan struct{})
+	return p.reload()
+}
+
+func (p *provider) Start() error {
+	go p.run()
+	return p.kafka.NewConsumer(&p.C.Input, p.invoke)
+}
+
+func (p *provider) Close() error {
+	close(p.closeCh)
+	logrus.Debug("not support close kafka consumer")
+	return nil
+}
+
+func (p *provider) run() {
+	reload := time.NewTicker(p.C.ReloadInterval)
+	tick := time.NewTicker(p.C.TickInterval)
+	defer reload.Stop()
+	defer tick.Stop()
+	for {
+		select {
+		case <-reload.C:
+			if err := p.reload(); err != nil {
+				p.L.Errorf("fail to reload alert silences and rules: %s", err)
+			}
+		case now := <-tick.C:
+			p.grouper.Tick(now)
+		case <-p.closeCh:
+			return
+		}
+	}
+}
+
+func init() {
+	servicehub.RegisterProvider("alert-dispatcher", &define{})
+}
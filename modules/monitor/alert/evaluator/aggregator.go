@@ -0,0 +1,120 @@
+// Copyright (c) 2021 Terminus, Inc.
+//
+// This program is free software: you can use, redistribute, and/or modify
+// it under the terms of the GNU Affero General Public License, version 3
+// or later ("AGPL"), as published by the Free Software Foundation.
+//
+// This program is distributed in the hope that it will be useful, but WITHOUT
+// ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+// FITNESS FOR A PARTICULAR PURPOSE.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package evaluator
+
+import (
+	"fmt"
+	"math"
+	"sort"
+
+	"github.com/erda-project/erda/modules/monitor/utils"
+)
+
+// aggregator reduce the values of a field in one window, values are sorted by time.
+type aggregator func(values []interface{}) interface{}
+
+var aggregators = map[string]aggregator{
+	"sum": func(values []interface{}) interface{} {
+		nums := numbers(values)
+		if len(nums) <= 0 {
+			return nil
+		}
+		var sum float64
+		for _, n := range nums {
+			sum += n
+		}
+		return sum
+	},
+	"avg": func(values []interface{}) interface{} {
+		nums := numbers(values)
+		if len(nums) <= 0 {
+			return nil
+		}
+		var sum float64
+		for _, n := range nums {
+			sum += n
+		}
+		return sum / float64(len(nums))
+	},
+	"max": func(values []interface{}) interface{} {
+		nums := numbers(values)
+		if len(nums) <= 0 {
+			return nil
+		}
+		max := -math.MaxFloat64
+		for _, n := range nums {
+			max = math.Max(max, n)
+		}
+		return max
+	},
+	"min": func(values []interface{}) interface{} {
+		nums := numbers(values)
+		if len(nums) <= 0 {
+			return nil
+		}
+		min := math.MaxFloat64
+		for _, n := range nums {
+			min = math.Min(min, n)
+		}
+		return min
+	},
+	"count": func(values []interface{}) interface{} {
+		return float64(len(values))
+	},
+	"distinct": func(values []interface{}) interface{} {
+		set := make(map[string]struct{})
+		for _, v := range values {
+			set[fmt.Sprint(v)] = struct{}{}
+		}
+		return float64(len(set))
+	},
+	"value": func(values []interface{}) interface{} {
+		if len(values) <= 0 {
+			return nil
+		}
+		return values[len(values)-1]
+	},
+	"values": func(values []interface{}) interface{} {
+		return values
+	},
+	"p99": percentile(99),
+	"p95": percentile(95),
+	"p90": percentile(90),
+	"p75": percentile(75),
+	"p50": percentile(50),
+}
+
+func percentile(p float64) aggregator {
+	return func(values []interface{}) interface{} {
+		nums := numbers(values)
+		if len(nums) <= 0 {
+			return nil
+		}
+		sort.Float64s(nums)
+		rank := p / 100 * float64(len(nums)-1)
+		lower := int(math.Floor(rank))
+		upper := int(math.Ceil(rank))
+		return nums[lower] + (nums[upper]-nums[lower])*(rank-float64(lower))
+	}
+}
+
+func numbers(values []interface{}) []float64 {
+	nums := make([]float64, 0, len(values))
+	for _, v := range values {
+		if n, ok := utils.ConvertFloat64(v); ok {
+			nums = append(nums, n)
+		}
+	}
+	return nums
+}
@@ -0,0 +1,74 @@
+// Copyright (c) 2021 Terminus, Inc.
+//
+// This program is free software: you can use, redistribute, and/or modify
+// it under the terms of the GNU Affero General Public License, version 3
+// or later ("AGPL"), as published by the Free Software Foundation.
+//
+// This program is distributed in the hope that it will be useful, but WITHOUT
+// ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+// FITNESS FOR A PARTICULAR PURPOSE.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package evaluator
+
+import (
+	"fmt"
+	"time"
+)
+
+// DefaultStep is the evaluation interval used by DryRun when step is not set.
+const DefaultStep = time.Minute
+
+// Timeline is the result of DryRun.
+type Timeline struct {
+	RuleID string   `json:"ruleId"`
+	Start  int64    `json:"start"`
+	End    int64    `json:"end"`
+	Step   int64    `json:"step"`
+	Events []*Alert `json:"events"`
+}
+
+// DryRun replays the rule over [start, end] every step, and returns the state changes
+// the rule would have produced. The points are loaded from source only once.
+func DryRun(source Source, rule *Rule, start, end time.Time, step time.Duration) (*Timeline, error) {
+	if step <= 0 {
+		step = DefaultStep
+	}
+	if !end.After(start) {
+		return nil, fmt.Errorf("end must be after start")
+	}
+	const ms = int64(time.Millisecond)
+	from := start.Add(-rule.Window).UnixNano() / ms
+	points, err := source.Points(rule.Metric, rule.Filters, from, end.UnixNano()/ms)
+	if err != nil {
+		return nil, fmt.Errorf("fail to query points of %q: %w", rule.Metric, err)
+	}
+	mem := NewMemorySource()
+	mem.Add(rule.Metric, points...)
+
+	tl := &Timeline{
+		RuleID: rule.ID,
+		Start:  start.UnixNano() / ms,
+		End:    end.UnixNano() / ms,
+		Step:   int64(step / time.Millisecond),
+	}
+	last := make(map[string]State)
+	e := New(mem)
+	for t := start; !t.After(end); t = t.Add(step) {
+		alerts, err := e.Eval(rule, t)
+		if err != nil {
+			return nil, err
+		}
+		current := make(map[string]State, len(alerts))
+		for _, a := range alerts {
+			current[a.GroupKey] = a.State
+			if last[a.GroupKey] != a.State {
+				tl.Events = append(tl.Events, a)
+			}
+		}
+		last = current
+	}
+	return tl, nil
+}
@@ -0,0 +1,200 @@
+// Copyright (c) 2021 Terminus, Inc.
+//
+// This program is free software: you can use, redistribute, and/or modify
+// it under the terms of the GNU Affero General Public License, version 3
+// or later ("AGPL"), as published by the Free Software Foundation.
+//
+// This program is distributed in the hope that it will be useful, but WITHOUT
+// ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+// FITNESS FOR A PARTICULAR PURPOSE.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package evaluator
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// State of alert
+type State string
+
+// State values
+const (
+	StatePending  State = "pending"
+	StateFiring   State = "firing"
+	StateResolved State = "resolved"
+)
+
+// Alert is the evaluated state of a rule for one group.
+type Alert struct {
+	RuleID     string                 `json:"ruleId"`
+	GroupKey   string                 `json:"groupKey"`
+	Group      map[string]string      `json:"group"`
+	State      State                  `json:"state"`
+	Values     map[string]interface{} `json:"values"`
+	ActiveAt   int64                  `json:"activeAt"`
+	FiredAt    int64                  `json:"firedAt,omitempty"`
+	ResolvedAt int64                  `json:"resolvedAt,omitempty"`
+}
+
+// Evaluator evaluates rules against a Source and keeps the alert states between evaluations.
+type Evaluator struct {
+	source Source
+	lock   sync.Mutex
+	states map[string]map[string]*Alert
+}
+
+// New .
+func New(source Source) *Evaluator {
+	return &Evaluator{
+		source: source,
+		states: make(map[string]map[string]*Alert),
+	}
+}
+
+// Eval evaluates the rule at the time now, and returns the alerts which are pending, firing,
+// or resolved in this evaluation. Resolved alerts are forgotten after being returned once.
+func (e *Evaluator) Eval(rule *Rule, now time.Time) ([]*Alert, error) {
+	end := now.UnixNano() / int64(time.Millisecond)
+	start := end - int64(rule.Window/time.Millisecond)
+	points, err := e.source.Points(rule.Metric, rule.Filters, start, end)
+	if err != nil {
+		return nil, fmt.Errorf("fail to query points of %q: %s", rule.Metric, err)
+	}
+	matched := matchGroups(rule, points)
+
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	states := e.states[rule.ID]
+	if states == nil {
+		states = make(map[string]*Alert)
+		e.states[rule.ID] = states
+	}
+	var alerts []*Alert
+	for key, alert := range states {
+		if alert.State == StateResolved {
+			delete(states, key)
+			continue
+		}
+		if _, ok := matched[key]; ok {
+			continue
+		}
+		if alert.State == StatePending {
+			delete(states, key)
+			continue
+		}
+		alert.State = StateResolved
+		alert.ResolvedAt = end
+		alerts = append(alerts, copyAlert(alert))
+	}
+	for key, g := range matched {
+		alert, ok := states[key]
+		if !ok {
+			alert = &Alert{
+				RuleID:   rule.ID,
+				GroupKey: key,
+				Group:    g.tags,
+				State:    StatePending,
+				ActiveAt: end,
+			}
+			states[key] = alert
+		}
+		alert.Values = g.values
+		if alert.State == StatePending && end-alert.ActiveAt >= int64(rule.For/time.Millisecond) {
+			alert.State = StateFiring
+			alert.FiredAt = end
+		}
+		alerts = append(alerts, copyAlert(alert))
+	}
+	sort.Slice(alerts, func(i, j int) bool { return alerts[i].GroupKey < alerts[j].GroupKey })
+	return alerts, nil
+}
+
+// Reset forgets the states of the rule.
+func (e *Evaluator) Reset(ruleID string) {
+	e.lock.Lock()
+	delete(e.states, ruleID)
+	e.lock.Unlock()
+}
+
+func copyAlert(a *Alert) *Alert {
+	c := *a
+	return &c
+}
+
+type groupResult struct {
+	tags   map[string]string
+	values map[string]interface{}
+}
+
+// matchGroups returns the groups whose aggregated values satisfy all functions of the rule.
+func matchGroups(rule *Rule, points []*Point) map[string]*groupResult {
+	groups := make(map[string][]*Point)
+	tags := make(map[string]map[string]string)
+	for _, p := range points {
+		if !matchFilters(rule.Filters, p.Tags) {
+			continue
+		}
+		key, gtags := groupKey(rule.Group, p.Tags)
+		groups[key] = append(groups[key], p)
+		tags[key] = gtags
+	}
+	matched := make(map[string]*groupResult)
+	for key, list := range groups {
+		values := make(map[string]interface{})
+		ok := true
+		for _, fn := range rule.Functions {
+			var fieldValues []interface{}
+			for _, p := range list {
+				if v, exist := p.Fields[fn.Field]; exist {
+					fieldValues = append(fieldValues, v)
+				}
+			}
+			result := aggregators[fn.Aggregator](fieldValues)
+			values[fn.Aggregator+"."+fn.Field] = result
+			if result == nil || !functionOperators[fn.Operator](result, fn.Value) {
+				ok = false
+			}
+		}
+		if ok {
+			matched[key] = &groupResult{tags: tags[key], values: values}
+		}
+	}
+	return matched
+}
+
+func matchFilters(filters []*Filter, tags map[string]string) bool {
+	for _, f := range filters {
+		if !filterOperators[f.Operator](tags, f.Tag, f.Value) {
+			return false
+		}
+	}
+	return true
+}
+
+func groupKey(group []string, tags map[string]string) (string, map[string]string) {
+	if len(group) <= 0 {
+		return "", nil
+	}
+	keys := make([]string, len(group))
+	copy(keys, group)
+	sort.Strings(keys)
+	gtags := make(map[string]string, len(keys))
+	sb := strings.Builder{}
+	for i, k := range keys {
+		if i > 0 {
+			sb.WriteString(",")
+		}
+		gtags[k] = tags[k]
+		sb.WriteString(k)
+		sb.WriteString("=")
+		sb.WriteString(tags[k])
+	}
+	return sb.String(), gtags
+}
@@ -0,0 +1,143 @@
+// Copyright (c) 2021 Terminus, Inc.
+//
+// This program is free software: you can use, redistribute, and/or modify
+// it under the terms of the GNU Affero General Public License, version 3
+// or later ("AGPL"), as published by the Free Software Foundation.
+//
+// This program is distributed in the hope that it will be useful, but WITHOUT
+// ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+// FITNESS FOR A PARTICULAR PURPOSE.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package evaluator
+
+import (
+	"testing"
+	"time"
+)
+
+var base = time.Date(2021, 6, 1, 0, 0, 0, 0, time.UTC)
+
+func ms(t time.Time) int64 { return t.UnixNano() / int64(time.Millisecond) }
+
+func testRule(t *testing.T, forSeconds int64) *Rule {
+	rule, err := ParseRule("1", map[string]interface{}{
+		"metric": "host_summary",
+		"window": float64(1),
+		"for":    float64(forSeconds),
+		"functions": []interface{}{
+			map[string]interface{}{"field": "load5", "aggregator": "avg", "operator": "gt", "value": float64(2)},
+		},
+		"filters": []interface{}{
+			map[string]interface{}{"tag": "cluster_name", "operator": "eq", "value": "prod"},
+		},
+		"group": []interface{}{"host_ip"},
+	})
+	if err != nil {
+		t.Fatalf("ParseRule() error: %s", err)
+	}
+	return rule
+}
+
+// loads adds one point per minute for each host, starting at base.
+func loads(src *MemorySource, host, cluster string, values ...float64) {
+	for i, v := range values {
+		src.Add("host_summary", &Point{
+			Timestamp: ms(base.Add(time.Duration(i)*time.Minute)) + 1,
+			Tags:      map[string]string{"host_ip": host, "cluster_name": cluster},
+			Fields:    map[string]interface{}{"load5": v},
+		})
+	}
+}
+
+func TestParseRule(t *testing.T) {
+	_, err := ParseRule("1", map[string]interface{}{
+		"metric": "host_summary",
+		"window": 1,
+		"functions": []interface{}{
+			map[string]interface{}{"field": "load5", "aggregator": "unknown", "operator": "gt", "value": 1},
+		},
+	})
+	if err == nil {
+		t.Errorf("ParseRule() want error for unknown aggregator")
+	}
+	_, err = ParseRule("1", map[string]interface{}{"metric": "host_summary"})
+	if err == nil {
+		t.Errorf("ParseRule() want error for missing window")
+	}
+}
+
+func TestEvaluator_Eval(t *testing.T) {
+	src := NewMemorySource()
+	loads(src, "10.0.0.1", "prod", 1, 3, 3, 3, 1)
+	loads(src, "10.0.0.2", "test", 5, 5, 5, 5, 5)
+	rule := testRule(t, 60)
+	e := New(src)
+
+	want := []State{"", StatePending, StateFiring, StateFiring, StateResolved, ""}
+	for i, state := range want {
+		alerts, err := e.Eval(rule, base.Add(time.Duration(i+1)*time.Minute))
+		if err != nil {
+			t.Fatalf("Eval() error: %s", err)
+		}
+		if state == "" {
+			if len(alerts) != 0 {
+				t.Errorf("minute %d: got %d alerts, want none", i, len(alerts))
+			}
+			continue
+		}
+		if len(alerts) != 1 {
+			t.Fatalf("minute %d: got %d alerts, want 1", i, len(alerts))
+		}
+		if alerts[0].State != state {
+			t.Errorf("minute %d: got state %s, want %s", i, alerts[0].State, state)
+		}
+		if alerts[0].Group["host_ip"] != "10.0.0.1" {
+			t.Errorf("minute %d: got group %v", i, alerts[0].Group)
+		}
+	}
+}
+
+func TestDryRun(t *testing.T) {
+	src := NewMemorySource()
+	loads(src, "10.0.0.1", "prod", 1, 3, 3, 3, 1, 3, 1)
+	rule := testRule(t, 0)
+
+	tl, err := DryRun(src, rule, base, base.Add(7*time.Minute), 0)
+	if err != nil {
+		t.Fatalf("DryRun() error: %s", err)
+	}
+	want := []State{StateFiring, StateResolved, StateFiring, StateResolved}
+	if len(tl.Events) != len(want) {
+		t.Fatalf("DryRun() got %d events, want %d", len(tl.Events), len(want))
+	}
+	for i, state := range want {
+		if tl.Events[i].State != state {
+			t.Errorf("event %d: got state %s, want %s", i, tl.Events[i].State, state)
+		}
+	}
+	if tl.Events[0].FiredAt != ms(base.Add(2*time.Minute)) {
+		t.Errorf("got firedAt %d, want %d", tl.Events[0].FiredAt, ms(base.Add(2*time.Minute)))
+	}
+}
+
+func TestAggregators(t *testing.T) {
+	values := []interface{}{1, 2, 3, 4, float64(5)}
+	tests := map[string]interface{}{
+		"sum":      float64(15),
+		"avg":      float64(3),
+		"max":      float64(5),
+		"min":      float64(1),
+		"count":    float64(5),
+		"distinct": float64(5),
+		"value":    float64(5),
+		"p50":      float64(3),
+	}
+	for name, want := range tests {
+		if got := aggregators[name](values); got != want {
+			t.Errorf("%s() = %v, want %v", name, got, want)
+		}
+	}
+}
@@ -0,0 +1,172 @@
+// Copyright (c) 2021 Terminus, Inc.
+//
+// This program is free software: you can use, redistribute, and/or modify
+// it under the terms of the GNU Affero General Public License, version 3
+// or later ("AGPL"), as published by the Free Software Foundation.
+//
+// This program is distributed in the hope that it will be useful, but WITHOUT
+// ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+// FITNESS FOR A PARTICULAR PURPOSE.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package evaluator
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"strings"
+
+	"github.com/erda-project/erda/modules/monitor/utils"
+)
+
+type (
+	filterOperator   func(tags map[string]string, tag string, value interface{}) bool
+	functionOperator func(result, value interface{}) bool
+)
+
+var filterOperators = map[string]filterOperator{
+	"any": func(tags map[string]string, tag string, value interface{}) bool { return true },
+	"eq": func(tags map[string]string, tag string, value interface{}) bool {
+		v, ok := tags[tag]
+		return ok && v == fmt.Sprint(value)
+	},
+	"neq": func(tags map[string]string, tag string, value interface{}) bool {
+		v, ok := tags[tag]
+		return !ok || v != fmt.Sprint(value)
+	},
+	"in": func(tags map[string]string, tag string, value interface{}) bool {
+		v, ok := tags[tag]
+		if !ok {
+			return false
+		}
+		for _, item := range toList(value) {
+			if v == fmt.Sprint(item) {
+				return true
+			}
+		}
+		return false
+	},
+	"like": func(tags map[string]string, tag string, value interface{}) bool {
+		v, ok := tags[tag]
+		return ok && strings.Contains(v, fmt.Sprint(value))
+	},
+	"match": func(tags map[string]string, tag string, value interface{}) bool {
+		v, ok := tags[tag]
+		return ok && matchRegexp(fmt.Sprint(value), v)
+	},
+	"notMatch": func(tags map[string]string, tag string, value interface{}) bool {
+		v, ok := tags[tag]
+		return !ok || !matchRegexp(fmt.Sprint(value), v)
+	},
+	"null": func(tags map[string]string, tag string, value interface{}) bool {
+		v, ok := tags[tag]
+		return !ok || len(v) <= 0
+	},
+	"false": func(tags map[string]string, tag string, value interface{}) bool {
+		v, ok := tags[tag]
+		return !ok || v == "false"
+	},
+}
+
+var functionOperators = map[string]functionOperator{
+	"any": func(result, value interface{}) bool { return true },
+	"eq": func(result, value interface{}) bool {
+		return compare(result, value) == 0
+	},
+	"neq": func(result, value interface{}) bool {
+		return compare(result, value) != 0
+	},
+	"gt": func(result, value interface{}) bool {
+		c := compare(result, value)
+		return c != incomparable && c > 0
+	},
+	"gte": func(result, value interface{}) bool {
+		c := compare(result, value)
+		return c != incomparable && c >= 0
+	},
+	"lt": func(result, value interface{}) bool {
+		c := compare(result, value)
+		return c != incomparable && c < 0
+	},
+	"lte": func(result, value interface{}) bool {
+		c := compare(result, value)
+		return c != incomparable && c <= 0
+	},
+	"like": func(result, value interface{}) bool {
+		return strings.Contains(fmt.Sprint(result), fmt.Sprint(value))
+	},
+	"contains": func(result, value interface{}) bool {
+		for _, item := range toList(result) {
+			if compare(item, value) == 0 {
+				return true
+			}
+		}
+		return false
+	},
+	"all": func(result, value interface{}) bool {
+		list := toList(result)
+		if len(list) <= 0 {
+			return false
+		}
+		for _, item := range list {
+			if compare(item, value) != 0 {
+				return false
+			}
+		}
+		return true
+	},
+}
+
+const incomparable = -2
+
+// compare returns -1, 0, 1, or incomparable if the values can not be ordered.
+func compare(a, b interface{}) int {
+	if a == nil || b == nil {
+		return incomparable
+	}
+	fa, aok := utils.ConvertFloat64(a)
+	fb, bok := utils.ConvertFloat64(b)
+	if aok && bok {
+		switch {
+		case fa < fb:
+			return -1
+		case fa > fb:
+			return 1
+		}
+		return 0
+	}
+	sa, sb := fmt.Sprint(a), fmt.Sprint(b)
+	switch {
+	case sa < sb:
+		return -1
+	case sa > sb:
+		return 1
+	}
+	return 0
+}
+
+func toList(value interface{}) []interface{} {
+	if value == nil {
+		return nil
+	}
+	val := reflect.ValueOf(value)
+	if val.Kind() != reflect.Slice && val.Kind() != reflect.Array {
+		return []interface{}{value}
+	}
+	list := make([]interface{}, val.Len())
+	for i := 0; i < val.Len(); i++ {
+		list[i] = val.Index(i).Interface()
+	}
+	return list
+}
+
+func matchRegexp(pattern, s string) bool {
+	reg, err := regexp.Compile(pattern)
+	if err != nil {
+		return false
+	}
+	return reg.MatchString(s)
+}
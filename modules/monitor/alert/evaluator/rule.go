@@ -0,0 +1,118 @@
+// Copyright (c) 2021 Terminus, Inc.
+//
+// This program is free software: you can use, redistribute, and/or modify
+// it under the terms of the GNU Affero General Public License, version 3
+// or later ("AGPL"), as published by the Free Software Foundation.
+//
+// This program is distributed in the hope that it will be useful, but WITHOUT
+// ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+// FITNESS FOR A PARTICULAR PURPOSE.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package evaluator
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/erda-project/erda/modules/monitor/utils"
+)
+
+// Rule is an alert expression that can be evaluated in process.
+type Rule struct {
+	ID        string        `json:"id"`
+	Metric    string        `json:"metric"`
+	Window    time.Duration `json:"window"`
+	For       time.Duration `json:"for"`
+	Functions []*Function   `json:"functions"`
+	Filters   []*Filter     `json:"filters"`
+	Group     []string      `json:"group"`
+}
+
+// Function .
+type Function struct {
+	Field      string      `json:"field"`
+	Aggregator string      `json:"aggregator"`
+	Operator   string      `json:"operator"`
+	Value      interface{} `json:"value"`
+}
+
+// Filter .
+type Filter struct {
+	Tag      string      `json:"tag"`
+	Operator string      `json:"operator"`
+	Value    interface{} `json:"value"`
+}
+
+// ParseRule parse rule from the stored alert expression,
+// the window of expression is in minutes, and "for" is in seconds.
+func ParseRule(id string, expression map[string]interface{}) (*Rule, error) {
+	r := &Rule{ID: id}
+	metric, ok := utils.GetMapValueString(expression, "metric")
+	if !ok || len(metric) <= 0 {
+		return nil, fmt.Errorf("metric must not be empty")
+	}
+	r.Metric = metric
+	window, ok := utils.GetMapValueInt64(expression, "window")
+	if !ok || window <= 0 {
+		return nil, fmt.Errorf("invalid window")
+	}
+	r.Window = time.Duration(window) * time.Minute
+	if sec, ok := utils.GetMapValueInt64(expression, "for"); ok && sec > 0 {
+		r.For = time.Duration(sec) * time.Second
+	}
+
+	functions, _ := utils.GetMapValueArr(expression, "functions")
+	for _, item := range functions {
+		m, ok := item.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		fn := &Function{Value: m["value"]}
+		fn.Field, _ = utils.GetMapValueString(m, "field")
+		fn.Aggregator, _ = utils.GetMapValueString(m, "aggregator")
+		fn.Operator, _ = utils.GetMapValueString(m, "operator")
+		r.Functions = append(r.Functions, fn)
+	}
+	filters, _ := utils.GetMapValueArr(expression, "filters")
+	for _, item := range filters {
+		m, ok := item.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		f := &Filter{Value: m["value"]}
+		f.Tag, _ = utils.GetMapValueString(m, "tag")
+		f.Operator, _ = utils.GetMapValueString(m, "operator")
+		r.Filters = append(r.Filters, f)
+	}
+	group, _ := utils.GetMapValueArr(expression, "group")
+	for _, item := range group {
+		if s, ok := item.(string); ok && len(s) > 0 {
+			r.Group = append(r.Group, s)
+		}
+	}
+	return r, r.Validate()
+}
+
+// Validate .
+func (r *Rule) Validate() error {
+	if len(r.Functions) <= 0 {
+		return fmt.Errorf("functions must not be empty")
+	}
+	for _, fn := range r.Functions {
+		if _, ok := aggregators[fn.Aggregator]; !ok {
+			return fmt.Errorf("not support aggregator %q", fn.Aggregator)
+		}
+		if _, ok := functionOperators[fn.Operator]; !ok {
+			return fmt.Errorf("not support function operator %q", fn.Operator)
+		}
+	}
+	for _, f := range r.Filters {
+		if _, ok := filterOperators[f.Operator]; !ok {
+			return fmt.Errorf("not support filter operator %q", f.Operator)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,66 @@
+// Copyright (c) 2021 Terminus, Inc.
+//
+// This program is free software: you can use, redistribute, and/or modify
+// it under the terms of the GNU Affero General Public License, version 3
+// or later ("AGPL"), as published by the Free Software Foundation.
+//
+// This program is distributed in the hope that it will be useful, but WITHOUT
+// ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+// FITNESS FOR A PARTICULAR PURPOSE.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package evaluator
+
+import (
+	"sort"
+	"sync"
+)
+
+// Point is a single metric data point, timestamp is in milliseconds.
+type Point struct {
+	Timestamp int64                  `json:"timestamp"`
+	Tags      map[string]string      `json:"tags"`
+	Fields    map[string]interface{} `json:"fields"`
+}
+
+// Source provides the raw points of a metric in the time range [start, end) in milliseconds.
+// Implementations may apply the filters to reduce the data returned,
+// the evaluator will always apply them again.
+type Source interface {
+	Points(metric string, filters []*Filter, start, end int64) ([]*Point, error)
+}
+
+// MemorySource is a Source that keeps points in memory, for testing rules locally.
+type MemorySource struct {
+	lock   sync.RWMutex
+	points map[string][]*Point
+}
+
+// NewMemorySource .
+func NewMemorySource() *MemorySource {
+	return &MemorySource{points: make(map[string][]*Point)}
+}
+
+// Add .
+func (s *MemorySource) Add(metric string, points ...*Point) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	list := append(s.points[metric], points...)
+	sort.SliceStable(list, func(i, j int) bool { return list[i].Timestamp < list[j].Timestamp })
+	s.points[metric] = list
+}
+
+// Points .
+func (s *MemorySource) Points(metric string, filters []*Filter, start, end int64) ([]*Point, error) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	var list []*Point
+	for _, p := range s.points[metric] {
+		if p.Timestamp >= start && p.Timestamp < end {
+			list = append(list, p)
+		}
+	}
+	return list, nil
+}
@@ -0,0 +1,153 @@
+// Copyright (c) 2021 Terminus, Inc.
+//
+// This program is free software: you can use, redistribute, and/or modify
+// it under the terms of the GNU Affero General Public License, version 3
+// or later ("AGPL"), as published by the Free Software Foundation.
+//
+// This program is distributed in the hope that it will be useful, but WITHOUT
+// ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+// FITNESS FOR A PARTICULAR PURPOSE.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package silence
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// GroupRule groups the alerts of Scope matched by Matchers with the same values of By tags into one notification.
+// The first notification of a group waits GroupWait for more alerts,
+// and the following ones are sent at most once every GroupInterval.
+type GroupRule struct {
+	ID uint64 `json:"id"`
+	Scope
+	Matchers      Matchers      `json:"matchers"`
+	By            []string      `json:"by"`
+	GroupWait     time.Duration `json:"groupWait"`
+	GroupInterval time.Duration `json:"groupInterval"`
+}
+
+// Validate .
+func (r *GroupRule) Validate() error {
+	if len(r.By) <= 0 {
+		return fmt.Errorf("group by tags must not be empty")
+	}
+	if r.GroupWait < 0 || r.GroupInterval < 0 {
+		return fmt.Errorf("groupWait and groupInterval must not be negative")
+	}
+	return r.Matchers.Init()
+}
+
+func (r *GroupRule) key(a *Alert) (string, map[string]string) {
+	by := make([]string, len(r.By))
+	copy(by, r.By)
+	sort.Strings(by)
+	tags := make(map[string]string, len(by))
+	parts := make([]string, len(by))
+	for i, k := range by {
+		tags[k] = a.Tags[k]
+		parts[i] = k + "=" + a.Tags[k]
+	}
+	return fmt.Sprintf("%d:%s", r.ID, strings.Join(parts, ",")), tags
+}
+
+// Group is a batch of alerts to be notified together.
+type Group struct {
+	Key    string            `json:"key"`
+	Tags   map[string]string `json:"tags"`
+	Alerts []*Alert          `json:"alerts"`
+}
+
+type groupState struct {
+	group     *Group
+	interval  time.Duration
+	nextFlush time.Time
+}
+
+// Grouper batches alerts by GroupRule. Alerts not matched by any rule are flushed immediately.
+type Grouper struct {
+	lock   sync.Mutex
+	rules  []*GroupRule
+	groups map[string]*groupState
+	flush  func(g *Group)
+}
+
+// NewGrouper .
+func NewGrouper(flush func(g *Group)) *Grouper {
+	return &Grouper{
+		groups: make(map[string]*groupState),
+		flush:  flush,
+	}
+}
+
+// SetRules replaces the group rules, invalid rules are ignored.
+func (g *Grouper) SetRules(rules []*GroupRule) {
+	var list []*GroupRule
+	for _, r := range rules {
+		if r.Validate() == nil {
+			list = append(list, r)
+		}
+	}
+	g.lock.Lock()
+	g.rules = list
+	g.lock.Unlock()
+}
+
+// Add .
+func (g *Grouper) Add(a *Alert, now time.Time) {
+	g.lock.Lock()
+	var rule *GroupRule
+	for _, r := range g.rules {
+		if r.Contains(a) && r.Matchers.Match(a.Tags) {
+			rule = r
+			break
+		}
+	}
+	if rule == nil {
+		g.lock.Unlock()
+		g.flush(&Group{Key: a.Key, Tags: a.Tags, Alerts: []*Alert{a}})
+		return
+	}
+	key, tags := rule.key(a)
+	state, ok := g.groups[key]
+	if !ok {
+		state = &groupState{
+			group:     &Group{Key: key, Tags: tags},
+			interval:  rule.GroupInterval,
+			nextFlush: now.Add(rule.GroupWait),
+		}
+		g.groups[key] = state
+	}
+	state.group.Alerts = append(state.group.Alerts, a)
+	g.lock.Unlock()
+}
+
+// Tick flushes the groups which are due at the time now, it should be called periodically.
+func (g *Grouper) Tick(now time.Time) {
+	var due []*Group
+	g.lock.Lock()
+	for key, state := range g.groups {
+		if now.Before(state.nextFlush) {
+			continue
+		}
+		if len(state.group.Alerts) <= 0 {
+			// nothing happened in the last interval
+			delete(g.groups, key)
+			continue
+		}
+		due = append(due, state.group)
+		state.group = &Group{Key: state.group.Key, Tags: state.group.Tags}
+		state.nextFlush = now.Add(state.interval)
+	}
+	g.lock.Unlock()
+	sort.Slice(due, func(i, j int) bool { return due[i].Key < due[j].Key })
+	for _, group := range due {
+		g.flush(group)
+	}
+}
@@ -0,0 +1,97 @@
+// Copyright (c) 2021 Terminus, Inc.
+//
+// This program is free software: you can use, redistribute, and/or modify
+// it under the terms of the GNU Affero General Public License, version 3
+// or later ("AGPL"), as published by the Free Software Foundation.
+//
+// This program is distributed in the hope that it will be useful, but WITHOUT
+// ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+// FITNESS FOR A PARTICULAR PURPOSE.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package silence
+
+import (
+	"fmt"
+	"regexp"
+)
+
+// MatchType .
+type MatchType string
+
+// MatchType values
+const (
+	MatchEqual    MatchType = "eq"
+	MatchNotEqual MatchType = "neq"
+	MatchRegexp   MatchType = "match"
+	MatchNotRegex MatchType = "notMatch"
+)
+
+// Matcher matches a tag of alert.
+type Matcher struct {
+	Tag   string    `json:"tag"`
+	Type  MatchType `json:"type"`
+	Value string    `json:"value"`
+
+	reg *regexp.Regexp
+}
+
+// Init validates the matcher and compiles the regexp.
+func (m *Matcher) Init() error {
+	if len(m.Tag) <= 0 {
+		return fmt.Errorf("matcher tag must not be empty")
+	}
+	switch m.Type {
+	case "":
+		m.Type = MatchEqual
+	case MatchEqual, MatchNotEqual:
+	case MatchRegexp, MatchNotRegex:
+		reg, err := regexp.Compile("^(?:" + m.Value + ")$")
+		if err != nil {
+			return fmt.Errorf("invalid regexp %q: %s", m.Value, err)
+		}
+		m.reg = reg
+	default:
+		return fmt.Errorf("not support match type %q", m.Type)
+	}
+	return nil
+}
+
+// Match .
+func (m *Matcher) Match(tags map[string]string) bool {
+	v := tags[m.Tag]
+	switch m.Type {
+	case MatchNotEqual:
+		return v != m.Value
+	case MatchRegexp:
+		return m.reg != nil && m.reg.MatchString(v)
+	case MatchNotRegex:
+		return m.reg == nil || !m.reg.MatchString(v)
+	}
+	return v == m.Value
+}
+
+// Matchers is a list of Matcher which matches only if all of them match.
+type Matchers []*Matcher
+
+// Init .
+func (ms Matchers) Init() error {
+	for _, m := range ms {
+		if err := m.Init(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Match .
+func (ms Matchers) Match(tags map[string]string) bool {
+	for _, m := range ms {
+		if !m.Match(tags) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,174 @@
+// Copyright (c) 2021 Terminus, Inc.
+//
+// This program is free software: you can use, redistribute, and/or modify
+// it under the terms of the GNU Affero General Public License, version 3
+// or later ("AGPL"), as published by the Free Software Foundation.
+//
+// This program is distributed in the hope that it will be useful, but WITHOUT
+// ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+// FITNESS FOR A PARTICULAR PURPOSE.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package silence
+
+import (
+	"fmt"
+	"sync"
+)
+
+// the tags of alert which identify the scope it belongs to
+const (
+	ScopeTag   = "alert_scope"
+	ScopeIDTag = "alert_scope_id"
+)
+
+// Alert is the notification of an alert to be dispatched.
+type Alert struct {
+	Key       string            `json:"key"`
+	Tags      map[string]string `json:"tags"`
+	Firing    bool              `json:"firing"`
+	Timestamp int64             `json:"timestamp"`
+	Payload   interface{}       `json:"payload,omitempty"`
+}
+
+// Scope restricts silences and rules to the alerts of one scope, the empty Scope matches alerts of all scopes.
+type Scope struct {
+	Scope   string `json:"scope"`
+	ScopeID string `json:"scopeId"`
+}
+
+// Contains .
+func (s Scope) Contains(a *Alert) bool {
+	return len(s.Scope) <= 0 || (a.Tags[ScopeTag] == s.Scope && a.Tags[ScopeIDTag] == s.ScopeID)
+}
+
+// Silence mutes the alerts of Scope matched by Matchers in [StartsAt, EndsAt), timestamps are in milliseconds.
+type Silence struct {
+	ID uint64 `json:"id"`
+	Scope
+	Matchers Matchers `json:"matchers"`
+	StartsAt int64    `json:"startsAt"`
+	EndsAt   int64    `json:"endsAt"`
+	Comment  string   `json:"comment"`
+}
+
+// Validate .
+func (s *Silence) Validate() error {
+	if len(s.Matchers) <= 0 {
+		return fmt.Errorf("matchers must not be empty")
+	}
+	if s.EndsAt <= s.StartsAt {
+		return fmt.Errorf("endsAt must be after startsAt")
+	}
+	return s.Matchers.Init()
+}
+
+// Active .
+func (s *Silence) Active(now int64) bool {
+	return s.StartsAt <= now && now < s.EndsAt
+}
+
+// InhibitRule mutes the alerts matched by TargetMatchers while any alert matched by SourceMatchers
+// is firing, and both of them belong to Scope and have the same values of Equal tags.
+type InhibitRule struct {
+	ID uint64 `json:"id"`
+	Scope
+	SourceMatchers Matchers `json:"sourceMatchers"`
+	TargetMatchers Matchers `json:"targetMatchers"`
+	Equal          []string `json:"equal"`
+}
+
+// Validate .
+func (r *InhibitRule) Validate() error {
+	if len(r.SourceMatchers) <= 0 || len(r.TargetMatchers) <= 0 {
+		return fmt.Errorf("source and target matchers must not be empty")
+	}
+	if err := r.SourceMatchers.Init(); err != nil {
+		return err
+	}
+	return r.TargetMatchers.Init()
+}
+
+func (r *InhibitRule) inhibits(source, target *Alert) bool {
+	if source.Key == target.Key || !r.Contains(source) || !r.Contains(target) ||
+		!r.SourceMatchers.Match(source.Tags) || !r.TargetMatchers.Match(target.Tags) {
+		return false
+	}
+	for _, tag := range r.Equal {
+		if source.Tags[tag] != target.Tags[tag] {
+			return false
+		}
+	}
+	return true
+}
+
+// Muter decides whether an alert should be muted by silences or inhibit rules.
+type Muter struct {
+	lock     sync.RWMutex
+	silences []*Silence
+	inhibits []*InhibitRule
+	firing   map[string]*Alert
+}
+
+// NewMuter .
+func NewMuter() *Muter {
+	return &Muter{firing: make(map[string]*Alert)}
+}
+
+// SetSilences replaces the silences, invalid silences are ignored.
+func (m *Muter) SetSilences(silences []*Silence) {
+	var list []*Silence
+	for _, s := range silences {
+		if s.Validate() == nil {
+			list = append(list, s)
+		}
+	}
+	m.lock.Lock()
+	m.silences = list
+	m.lock.Unlock()
+}
+
+// SetInhibitRules replaces the inhibit rules, invalid rules are ignored.
+func (m *Muter) SetInhibitRules(rules []*InhibitRule) {
+	var list []*InhibitRule
+	for _, r := range rules {
+		if r.Validate() == nil {
+			list = append(list, r)
+		}
+	}
+	m.lock.Lock()
+	m.inhibits = list
+	m.lock.Unlock()
+}
+
+// Observe records the state of alert, firing alerts may inhibit others.
+func (m *Muter) Observe(a *Alert) {
+	m.lock.Lock()
+	if a.Firing {
+		m.firing[a.Key] = a
+	} else {
+		delete(m.firing, a.Key)
+	}
+	m.lock.Unlock()
+}
+
+// Mutes returns the reason if alert should not be notified at the time now in milliseconds.
+func (m *Muter) Mutes(a *Alert, now int64) (string, bool) {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+	for _, s := range m.silences {
+		if s.Active(now) && s.Contains(a) && s.Matchers.Match(a.Tags) {
+			return fmt.Sprintf("silenced by %d", s.ID), true
+		}
+	}
+	for _, r := range m.inhibits {
+		for _, source := range m.firing {
+			if r.inhibits(source, a) {
+				return fmt.Sprintf("inhibited by rule %d, source %s", r.ID, source.Key), true
+			}
+		}
+	}
+	return "", false
+}
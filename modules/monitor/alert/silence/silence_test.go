@@ -0,0 +1,160 @@
+// Copyright (c) 2021 Terminus, Inc.
+//
+// This program is free software: you can use, redistribute, and/or modify
+// it under the terms of the GNU Affero General Public License, version 3
+// or later ("AGPL"), as published by the Free Software Foundation.
+//
+// This program is distributed in the hope that it will be useful, but WITHOUT
+// ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+// FITNESS FOR A PARTICULAR PURPOSE.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package silence
+
+import (
+	"testing"
+	"time"
+)
+
+func TestMuter_Silence(t *testing.T) {
+	m := NewMuter()
+	m.SetSilences([]*Silence{
+		{
+			ID:       1,
+			Matchers: Matchers{{Tag: "cluster_name", Value: "terminus-dev"}, {Tag: "host_ip", Type: MatchRegexp, Value: "10\\.0\\..*"}},
+			StartsAt: 100,
+			EndsAt:   200,
+		},
+		{
+			// invalid, ignored
+			ID:       2,
+			Matchers: Matchers{{Tag: "cluster_name", Value: "terminus-dev"}},
+			StartsAt: 200,
+			EndsAt:   100,
+		},
+	})
+	a := &Alert{Key: "a", Tags: map[string]string{"cluster_name": "terminus-dev", "host_ip": "10.0.0.1"}, Firing: true}
+	tests := []struct {
+		now  int64
+		tags map[string]string
+		want bool
+	}{
+		{now: 150, tags: a.Tags, want: true},
+		{now: 200, tags: a.Tags, want: false},
+		{now: 50, tags: a.Tags, want: false},
+		{now: 150, tags: map[string]string{"cluster_name": "terminus-dev", "host_ip": "192.168.0.1"}, want: false},
+	}
+	for _, tt := range tests {
+		if _, got := m.Mutes(&Alert{Key: "a", Tags: tt.tags}, tt.now); got != tt.want {
+			t.Errorf("Mutes(%v, %d) = %v, want %v", tt.tags, tt.now, got, tt.want)
+		}
+	}
+}
+
+func TestMuter_Inhibit(t *testing.T) {
+	m := NewMuter()
+	m.SetInhibitRules([]*InhibitRule{
+		{
+			ID:             1,
+			SourceMatchers: Matchers{{Tag: "alert_index", Value: "machine_down"}},
+			TargetMatchers: Matchers{{Tag: "alert_type", Value: "app_resource"}},
+			Equal:          []string{"host_ip"},
+		},
+	})
+	down := &Alert{Key: "down", Tags: map[string]string{"alert_index": "machine_down", "host_ip": "10.0.0.1"}, Firing: true}
+	target := &Alert{Key: "svc", Tags: map[string]string{"alert_type": "app_resource", "host_ip": "10.0.0.1"}, Firing: true}
+	other := &Alert{Key: "svc2", Tags: map[string]string{"alert_type": "app_resource", "host_ip": "10.0.0.2"}, Firing: true}
+
+	if _, muted := m.Mutes(target, 0); muted {
+		t.Errorf("target must not be muted before source fires")
+	}
+	m.Observe(down)
+	if _, muted := m.Mutes(target, 0); !muted {
+		t.Errorf("target must be muted while source is firing")
+	}
+	if _, muted := m.Mutes(other, 0); muted {
+		t.Errorf("alert on another host must not be muted")
+	}
+	if _, muted := m.Mutes(down, 0); muted {
+		t.Errorf("source must not inhibit itself")
+	}
+	m.Observe(&Alert{Key: "down", Tags: down.Tags, Firing: false})
+	if _, muted := m.Mutes(target, 0); muted {
+		t.Errorf("target must not be muted after source resolved")
+	}
+}
+
+func TestGrouper(t *testing.T) {
+	var flushed []*Group
+	g := NewGrouper(func(group *Group) { flushed = append(flushed, group) })
+	g.SetRules([]*GroupRule{
+		{
+			ID:            1,
+			Matchers:      Matchers{{Tag: "alert_type", Value: "machine"}},
+			By:            []string{"cluster_name"},
+			GroupWait:     30 * time.Second,
+			GroupInterval: 5 * time.Minute,
+		},
+	})
+	now := time.Date(2021, 6, 1, 0, 0, 0, 0, time.UTC)
+	alert := func(key, cluster string) *Alert {
+		return &Alert{Key: key, Tags: map[string]string{"alert_type": "machine", "cluster_name": cluster}}
+	}
+
+	g.Add(&Alert{Key: "ungrouped", Tags: map[string]string{"alert_type": "other"}}, now)
+	if len(flushed) != 1 {
+		t.Fatalf("ungrouped alert must be flushed immediately, got %d", len(flushed))
+	}
+	g.Add(alert("a", "c1"), now)
+	g.Add(alert("b", "c1"), now.Add(10*time.Second))
+	g.Add(alert("c", "c2"), now.Add(10*time.Second))
+	g.Tick(now.Add(20 * time.Second))
+	if len(flushed) != 1 {
+		t.Fatalf("groups must wait for groupWait, got %d", len(flushed))
+	}
+	g.Tick(now.Add(30 * time.Second))
+	if len(flushed) != 2 || len(flushed[1].Alerts) != 2 || flushed[1].Tags["cluster_name"] != "c1" {
+		t.Fatalf("group c1 must be flushed with 2 alerts, got %+v", flushed)
+	}
+	g.Tick(now.Add(40 * time.Second))
+	if len(flushed) != 3 || len(flushed[2].Alerts) != 1 {
+		t.Fatalf("group c2 must be flushed with 1 alert, got %d flushes", len(flushed))
+	}
+	g.Add(alert("d", "c1"), now.Add(time.Minute))
+	g.Tick(now.Add(2 * time.Minute))
+	if len(flushed) != 3 {
+		t.Fatalf("group c1 must wait for groupInterval, got %d", len(flushed))
+	}
+	g.Tick(now.Add(30*time.Second + 5*time.Minute))
+	if len(flushed) != 4 || flushed[3].Alerts[0].Key != "d" {
+		t.Fatalf("group c1 must be flushed after groupInterval, got %d", len(flushed))
+	}
+}
+
+func TestMuter_Scope(t *testing.T) {
+	m := NewMuter()
+	m.SetSilences([]*Silence{
+		{
+			ID:       1,
+			Scope:    Scope{Scope: "org", ScopeID: "1"},
+			Matchers: Matchers{{Tag: "cluster_name", Value: "terminus-dev"}},
+			StartsAt: 100,
+			EndsAt:   200,
+		},
+	})
+	tests := []struct {
+		tags map[string]string
+		want bool
+	}{
+		{tags: map[string]string{ScopeTag: "org", ScopeIDTag: "1", "cluster_name": "terminus-dev"}, want: true},
+		{tags: map[string]string{ScopeTag: "org", ScopeIDTag: "2", "cluster_name": "terminus-dev"}, want: false},
+		{tags: map[string]string{"cluster_name": "terminus-dev"}, want: false},
+	}
+	for _, tt := range tests {
+		if _, got := m.Mutes(&Alert{Key: "a", Tags: tt.tags}, 150); got != tt.want {
+			t.Errorf("Mutes(%v) = %v, want %v", tt.tags, got, tt.want)
+		}
+	}
+}
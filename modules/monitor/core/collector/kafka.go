@@ -14,6 +14,7 @@
 package collector
 
 import (
+	"github.com/buger/jsonparser"
 	"github.com/pkg/errors"
 
 	"github.com/erda-project/erda-infra/providers/kafka"
@@ -29,6 +30,7 @@ var (
 		// white list
 		"alert":                topicPrefix + "alert",
 		"alert-event":          topicPrefix + "alert-event",
+		"alert-notify":         topicPrefix + "alert-notify",
 		"error":                topicPrefix + "error",
 		"metaserver_container": topicPrefix + "metaserver_container",
 		"metaserver_host":      topicPrefix + "metaserver_host",
@@ -37,6 +39,11 @@ var (
 	}
 )
 
+// keys of messages, the messages with the same key are sent to the same partition.
+var keys = map[string]func(data []byte) []byte{
+	"alert-notify": alertNotifyKey,
+}
+
 const (
 	topicPrefix = "spot-"
 )
@@ -46,12 +53,28 @@ func (c *collector) send(name string, data []byte) error {
 	if err != nil {
 		return err
 	}
+	var key []byte
+	if fn, ok := keys[name]; ok {
+		key = fn(data)
+	}
 	return c.writer.Write(&kafka.Message{
 		Topic: &topic,
 		Data:  data,
+		Key:   key,
 	})
 }
 
+// alertNotifyKey routes the alert notifications of one scope to one consumer of alert-dispatcher,
+// so that its silences, inhibit rules and groups, which are kept in memory, see all the alerts of the scope.
+func alertNotifyKey(data []byte) []byte {
+	scope, _ := jsonparser.GetString(data, "tags", "alert_scope")
+	scopeID, _ := jsonparser.GetString(data, "tags", "alert_scope_id")
+	if len(scope) <= 0 && len(scopeID) <= 0 {
+		return nil
+	}
+	return []byte(scope + "/" + scopeID)
+}
+
 func (c *collector) getTopic(typ string) (string, error) {
 	if topic, ok := topics[typ]; ok {
 		return topic, nil
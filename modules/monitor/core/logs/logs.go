@@ -15,13 +15,14 @@ package logs
 
 // Log .
 type Log struct {
-	Source    string            `json:"source"`
-	ID        string            `json:"id"`
-	Stream    string            `json:"stream"`
-	Content   string            `json:"content"`
-	Offset    int64             `json:"offset"`
-	Timestamp int64             `json:"timestamp"`
-	Tags      map[string]string `json:"tags"`
+	Source    string              `json:"source"`
+	ID        string              `json:"id"`
+	Stream    string              `json:"stream"`
+	Content   string              `json:"content"`
+	Offset    int64               `json:"offset"`
+	Timestamp int64               `json:"timestamp"`
+	Tags      map[string]string   `json:"tags"`
+	Highlight map[string][]string `json:"highlight,omitempty"`
 }
 
 // LogMeta .
@@ -0,0 +1,357 @@
+// Copyright (c) 2021 Terminus, Inc.
+//
+// This program is free software: you can use, redistribute, and/or modify
+// it under the terms of the GNU Affero General Public License, version 3
+// or later ("AGPL"), as published by the Free Software Foundation.
+//
+// This program is distributed in the hope that it will be useful, but WITHOUT
+// ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+// FITNESS FOR A PARTICULAR PURPOSE.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package query
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/confluentinc/confluent-kafka-go/kafka"
+	"github.com/gorilla/websocket"
+
+	"github.com/erda-project/erda-infra/modcom/api"
+	"github.com/erda-project/erda/modules/monitor/core/logs"
+	"github.com/erda-project/erda/modules/monitor/core/logs/tail"
+)
+
+// FollowRequest .
+type FollowRequest struct {
+	RequestID     string `query:"requestId"`
+	Source        string `query:"source"`
+	ID            string `query:"id"`
+	Stream        string `query:"stream"`
+	Contains      string `query:"contains"`
+	Regexp        string `query:"regexp"`
+	ApplicationID string `query:"applicationId"`
+	ClusterName   string `query:"clusterName"`
+}
+
+// follow events
+const (
+	followEventLog     = "log"
+	followEventDropped = "dropped"
+	followEventPing    = "ping"
+	followEventEnd     = "end"
+
+	maxFollowBatch = 100
+)
+
+// followConsumer runs the consumer of log follow only while there are sessions following on this instance,
+// because the consumer reads all the logs of the topics.
+type followConsumer struct {
+	lock     sync.Mutex
+	sessions int
+	stop     chan struct{}
+}
+
+func (p *provider) acquireFollowConsumer() error {
+	p.follow.lock.Lock()
+	defer p.follow.lock.Unlock()
+	if p.follow.sessions <= 0 {
+		consumer, err := p.newFollowConsumer()
+		if err != nil {
+			return err
+		}
+		p.follow.stop = make(chan struct{})
+		go p.consumeFollow(consumer, p.follow.stop)
+	}
+	p.follow.sessions++
+	return nil
+}
+
+func (p *provider) releaseFollowConsumer() {
+	p.follow.lock.Lock()
+	defer p.follow.lock.Unlock()
+	p.follow.sessions--
+	if p.follow.sessions <= 0 {
+		close(p.follow.stop)
+		p.follow.stop = nil
+		p.follow.sessions = 0
+	}
+}
+
+// newFollowConsumer assigns all partitions of the topics from the latest offsets instead of joining the consumer group,
+// so that every instance receives all logs for the sessions following on it, without a group for each instance left in kafka.
+// It costs every instance with followers to read all the logs of the topics.
+func (p *provider) newFollowConsumer() (*kafka.Consumer, error) {
+	consumer, err := kafka.NewConsumer(&kafka.ConfigMap{
+		"bootstrap.servers":  p.Cfg.Follow.Input.Servers,
+		"group.id":           p.Cfg.Follow.Input.Group,
+		"enable.auto.commit": false,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("fail to create log follow consumer: %s", err)
+	}
+	var partitions []kafka.TopicPartition
+	for _, topic := range p.Cfg.Follow.Input.Topics {
+		topic := topic
+		meta, err := consumer.GetMetadata(&topic, false, 10000)
+		if err != nil {
+			consumer.Close()
+			return nil, fmt.Errorf("fail to get metadata of topic %s: %s", topic, err)
+		}
+		for _, part := range meta.Topics[topic].Partitions {
+			partitions = append(partitions, kafka.TopicPartition{Topic: &topic, Partition: part.ID, Offset: kafka.OffsetEnd})
+		}
+	}
+	if err := consumer.Assign(partitions); err != nil {
+		consumer.Close()
+		return nil, fmt.Errorf("fail to assign partitions for log follow: %s", err)
+	}
+	return consumer, nil
+}
+
+func (p *provider) consumeFollow(consumer *kafka.Consumer, stop chan struct{}) {
+	defer consumer.Close()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-p.closeCh:
+			return
+		default:
+		}
+		switch e := consumer.Poll(100).(type) {
+		case *kafka.Message:
+			if err := p.invokeFollow(e.Key, e.Value, e.TopicPartition.Topic, e.Timestamp); err != nil {
+				p.Logger.Debugf("fail to process log for follow: %s", err)
+			}
+		case kafka.Error:
+			p.Logger.Errorf("log follow consumer error: %s", e)
+		}
+	}
+}
+
+// checkOrigin accepts the websocket requests from the same host, or the allowed origins for cross-origin requests.
+// The requests without Origin are not sent by browsers, and they are authenticated as usual.
+func (p *provider) checkOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if len(origin) <= 0 {
+		return true
+	}
+	u, err := url.Parse(origin)
+	if err != nil || len(u.Host) <= 0 {
+		return false
+	}
+	for _, host := range []string{r.Host, r.Header.Get("X-Forwarded-Host")} {
+		if len(host) > 0 && strings.EqualFold(u.Host, host) {
+			return true
+		}
+	}
+	for _, allowed := range p.Cfg.Follow.AllowedOrigins {
+		if strings.EqualFold(origin, allowed) ||
+			(strings.HasPrefix(allowed, "*.") && strings.HasSuffix(strings.ToLower(u.Hostname()), strings.ToLower(allowed[1:]))) {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *provider) invokeFollow(key []byte, value []byte, topic *string, timestamp time.Time) error {
+	if p.tails.Len() <= 0 {
+		return nil
+	}
+	log := &logs.Log{}
+	if err := json.Unmarshal(value, log); err != nil {
+		return err
+	}
+	for _, key := range p.Cfg.Follow.IDKeys {
+		if val, ok := log.Tags[key]; ok {
+			log.ID = val
+			break
+		}
+	}
+	if log.Stream == "" {
+		log.Stream = defaultStream
+	}
+	p.tails.Publish(log)
+	return nil
+}
+
+func (p *provider) followRuntimeLog(w http.ResponseWriter, r *http.Request, params FollowRequest) interface{} {
+	return p.followLog(w, r, &params, map[string]string{"dice_application_id": params.ApplicationID})
+}
+
+func (p *provider) followOrgLog(w http.ResponseWriter, r *http.Request, params FollowRequest) interface{} {
+	return p.followLog(w, r, &params, map[string]string{"dice_cluster_name": params.ClusterName})
+}
+
+// followLog streams the logs being consumed, by websocket if the request asks to upgrade, otherwise by server-sent events.
+func (p *provider) followLog(w http.ResponseWriter, r *http.Request, params *FollowRequest, tags map[string]string) interface{} {
+	if !p.Cfg.Follow.Enable {
+		return api.Errors.Internal("log follow is not enabled")
+	}
+	if len(params.RequestID) <= 0 && (len(params.Source) <= 0 || len(params.ID) <= 0) {
+		return api.Errors.InvalidParameter("missing parameter requestId, or source and id")
+	}
+	filter := &tail.Filter{
+		Source:    params.Source,
+		ID:        params.ID,
+		Stream:    params.Stream,
+		RequestID: params.RequestID,
+		Tags:      tags,
+		Contains:  params.Contains,
+	}
+	if len(params.Regexp) > 0 {
+		reg, err := regexp.Compile(params.Regexp)
+		if err != nil {
+			return api.Errors.InvalidParameter(fmt.Errorf("invalid regexp: %s", err))
+		}
+		filter.Regexp = reg
+	}
+	sub, err := p.tails.Subscribe(filter, p.Cfg.Follow.BufferSize)
+	if err != nil {
+		return api.Errors.Internal(err)
+	}
+	defer sub.Close()
+	if err := p.acquireFollowConsumer(); err != nil {
+		return api.Errors.Internal(err)
+	}
+	defer p.releaseFollowConsumer()
+
+	ctx, cancel := context.WithTimeout(r.Context(), p.Cfg.Follow.MaxDuration)
+	defer cancel()
+	var stream followStream
+	if websocket.IsWebSocketUpgrade(r) {
+		upgrader := websocket.Upgrader{CheckOrigin: p.checkOrigin}
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			// the response has been written by upgrader
+			return nil
+		}
+		defer conn.Close()
+		go func() {
+			// the client sends nothing but close, read it to know when the session is closed.
+			defer cancel()
+			for {
+				if _, _, err := conn.NextReader(); err != nil {
+					return
+				}
+			}
+		}()
+		stream = &wsStream{conn: conn, timeout: p.Cfg.Follow.WriteTimeout}
+	} else {
+		flusher, ok := w.(http.Flusher)
+		if !ok {
+			return api.Errors.Internal("streaming is not supported")
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Header().Set("Connection", "keep-alive")
+		w.Header().Set("X-Accel-Buffering", "no")
+		w.WriteHeader(http.StatusOK)
+		flusher.Flush()
+		stream = &sseStream{w: w, flusher: flusher}
+	}
+	if err := p.runFollow(ctx, sub, stream); err != nil {
+		p.Logger.Debugf("log follow session closed: %s", err)
+	}
+	return nil
+}
+
+func (p *provider) runFollow(ctx context.Context, sub *tail.Subscription, stream followStream) error {
+	heartbeat := time.NewTicker(p.Cfg.Follow.HeartbeatInterval)
+	defer heartbeat.Stop()
+	lines := make([]*Log, 0, maxFollowBatch)
+	for {
+		select {
+		case log := <-sub.C():
+			lines = append(lines[:0], convertFollowLog(log))
+		drain:
+			for len(lines) < maxFollowBatch {
+				select {
+				case log := <-sub.C():
+					lines = append(lines, convertFollowLog(log))
+				default:
+					break drain
+				}
+			}
+			if err := stream.send(followEventLog, &Response{Lines: lines}); err != nil {
+				return err
+			}
+		case <-heartbeat.C:
+			if dropped := sub.TakeDropped(); dropped > 0 {
+				if err := stream.send(followEventDropped, map[string]int64{"count": dropped}); err != nil {
+					return err
+				}
+				continue
+			}
+			if err := stream.send(followEventPing, nil); err != nil {
+				return err
+			}
+		case <-ctx.Done():
+			reason := "closed"
+			if ctx.Err() == context.DeadlineExceeded {
+				reason = "timeout"
+			}
+			stream.send(followEventEnd, map[string]string{"reason": reason})
+			return ctx.Err()
+		}
+	}
+}
+
+func convertFollowLog(log *logs.Log) *Log {
+	return &Log{
+		Source:    log.Source,
+		ID:        log.ID,
+		Stream:    log.Stream,
+		Timestamp: strconv.FormatInt(log.Timestamp, 10),
+		Offset:    strconv.FormatInt(log.Offset, 10),
+		Content:   log.Content,
+		Level:     log.Tags["level"],
+		RequestID: log.Tags[tail.RequestIDKey],
+	}
+}
+
+type followStream interface {
+	send(event string, data interface{}) error
+}
+
+type sseStream struct {
+	w       http.ResponseWriter
+	flusher http.Flusher
+}
+
+func (s *sseStream) send(event string, data interface{}) error {
+	byts, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	if _, err := fmt.Fprintf(s.w, "event: %s\ndata: %s\n\n", event, byts); err != nil {
+		return err
+	}
+	s.flusher.Flush()
+	return nil
+}
+
+type wsStream struct {
+	conn    *websocket.Conn
+	timeout time.Duration
+}
+
+func (s *wsStream) send(event string, data interface{}) error {
+	s.conn.SetWriteDeadline(time.Now().Add(s.timeout))
+	return s.conn.WriteJSON(map[string]interface{}{
+		"event": event,
+		"data":  data,
+	})
+}
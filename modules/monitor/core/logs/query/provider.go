@@ -24,6 +24,7 @@ import (
 	"github.com/erda-project/erda-infra/providers/cassandra"
 	"github.com/erda-project/erda-infra/providers/httpserver"
 	"github.com/erda-project/erda-infra/providers/httpserver/interceptors"
+	"github.com/erda-project/erda/modules/monitor/core/logs/tail"
 )
 
 type define struct{}
@@ -46,12 +47,30 @@ type config struct {
 	Download  struct {
 		TimeSpan time.Duration `file:"time_span" default:"5m"`
 	} `file:"download"`
+	Follow struct {
+		Enable bool `file:"enable"`
+		Input  struct {
+			Servers string   `file:"servers" env:"BOOTSTRAP_SERVERS"`
+			Topics  []string `file:"topics"`
+			Group   string   `file:"group"`
+		} `file:"input"`
+		AllowedOrigins    []string      `file:"allowed_origins"`
+		IDKeys            []string      `file:"id_keys"`
+		MaxSessions       int           `file:"max_sessions" default:"500"`
+		MaxDuration       time.Duration `file:"max_duration" default:"30m"`
+		BufferSize        int           `file:"buffer_size" default:"1000"`
+		HeartbeatInterval time.Duration `file:"heartbeat_interval" default:"15s"`
+		WriteTimeout      time.Duration `file:"write_timeout" default:"10s"`
+	} `file:"follow"`
 }
 
 type provider struct {
 	Cfg              *config
 	Logger           logs.Logger
 	session          *gocql.Session
+	closeCh          chan struct{}
+	tails            *tail.Hub
+	follow           followConsumer
 	checkOrgCluster  func(ctx httpserver.Context) (string, error)
 	getApplicationID func(ctx httpserver.Context) (string, error)
 }
@@ -63,6 +82,7 @@ func (p *provider) Init(ctx servicehub.Context) error {
 		return fmt.Errorf("fail to create cassandra session: %s", err)
 	}
 	p.session = session
+	p.tails = tail.NewHub(p.Cfg.Follow.MaxSessions)
 	routes := ctx.Service("http-server", interceptors.Recover(p.Logger)).(httpserver.Router)
 	err = p.intRoutes(routes)
 	if err != nil {
@@ -71,6 +91,21 @@ func (p *provider) Init(ctx servicehub.Context) error {
 	return nil
 }
 
+func (p *provider) Start() error {
+	if !p.Cfg.Follow.Enable {
+		return nil
+	}
+	p.closeCh = make(chan struct{})
+	return nil
+}
+
+func (p *provider) Close() error {
+	if p.closeCh != nil {
+		close(p.closeCh)
+	}
+	return nil
+}
+
 func init() {
 	servicehub.RegisterProvider("logs-query", &define{})
 }
@@ -40,6 +40,10 @@ func (p *provider) intRoutes(routes httpserver.Router) error {
 		permission.ScopeApp, p.getApplicationID,
 		common.ResourceRuntime, permission.ActionGet,
 	))
+	routes.GET("/api/runtime/logs/actions/follow", p.followRuntimeLog, permission.Intercepter(
+		permission.ScopeApp, p.getApplicationID,
+		common.ResourceRuntime, permission.ActionGet,
+	))
 	// org
 	p.checkOrgCluster = permission.OrgIDByCluster("clusterName")
 	routes.GET("/api/orgCenter/logs", p.queryOrgLog, permission.Intercepter(
@@ -50,6 +54,10 @@ func (p *provider) intRoutes(routes httpserver.Router) error {
 		permission.ScopeOrg, p.checkContainerLog,
 		common.ResourceOrgCenter, permission.ActionGet,
 	))
+	routes.GET("/api/orgCenter/logs/actions/follow", p.followOrgLog, permission.Intercepter(
+		permission.ScopeOrg, p.checkContainerLog,
+		common.ResourceOrgCenter, permission.ActionGet,
+	))
 	return nil
 }
 
@@ -0,0 +1,150 @@
+// Copyright (c) 2021 Terminus, Inc.
+//
+// This program is free software: you can use, redistribute, and/or modify
+// it under the terms of the GNU Affero General Public License, version 3
+// or later ("AGPL"), as published by the Free Software Foundation.
+//
+// This program is distributed in the hope that it will be useful, but WITHOUT
+// ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+// FITNESS FOR A PARTICULAR PURPOSE.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+// Package tail fans out the logs being consumed to the sessions following them.
+package tail
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/erda-project/erda/modules/monitor/core/logs"
+)
+
+// RequestIDKey is the tag of log which holds the request id.
+const RequestIDKey = "request-id"
+
+// ErrTooManySessions .
+var ErrTooManySessions = errors.New("too many log follow sessions")
+
+// Filter selects the logs of a session, empty fields match any log.
+type Filter struct {
+	Source    string
+	ID        string
+	Stream    string
+	RequestID string
+	Tags      map[string]string
+	Contains  string
+	Regexp    *regexp.Regexp
+}
+
+// Match .
+func (f *Filter) Match(log *logs.Log) bool {
+	if (len(f.Source) > 0 && f.Source != log.Source) ||
+		(len(f.ID) > 0 && f.ID != log.ID) ||
+		(len(f.Stream) > 0 && f.Stream != log.Stream) {
+		return false
+	}
+	if len(f.RequestID) > 0 && log.Tags[RequestIDKey] != f.RequestID {
+		return false
+	}
+	for k, v := range f.Tags {
+		if log.Tags[k] != v {
+			return false
+		}
+	}
+	if len(f.Contains) > 0 && !strings.Contains(log.Content, f.Contains) {
+		return false
+	}
+	if f.Regexp != nil && !f.Regexp.MatchString(log.Content) {
+		return false
+	}
+	return true
+}
+
+// Hub dispatches the published logs to the matched subscriptions.
+// Publish never blocks, the logs are dropped for the subscriptions which can not keep up.
+type Hub struct {
+	maxSessions int
+	lock        sync.RWMutex
+	subs        map[*Subscription]struct{}
+}
+
+// NewHub creates a Hub which allows maxSessions subscriptions at most, 0 means unlimited.
+func NewHub(maxSessions int) *Hub {
+	return &Hub{
+		maxSessions: maxSessions,
+		subs:        make(map[*Subscription]struct{}),
+	}
+}
+
+// Subscribe .
+func (h *Hub) Subscribe(filter *Filter, bufferSize int) (*Subscription, error) {
+	if bufferSize <= 0 {
+		bufferSize = 1
+	}
+	s := &Subscription{
+		hub:    h,
+		filter: filter,
+		ch:     make(chan *logs.Log, bufferSize),
+	}
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	if h.maxSessions > 0 && len(h.subs) >= h.maxSessions {
+		return nil, ErrTooManySessions
+	}
+	h.subs[s] = struct{}{}
+	return s, nil
+}
+
+// Len returns the number of subscriptions.
+func (h *Hub) Len() int {
+	h.lock.RLock()
+	defer h.lock.RUnlock()
+	return len(h.subs)
+}
+
+// Publish .
+func (h *Hub) Publish(log *logs.Log) {
+	h.lock.RLock()
+	defer h.lock.RUnlock()
+	for s := range h.subs {
+		if !s.filter.Match(log) {
+			continue
+		}
+		select {
+		case s.ch <- log:
+		default:
+			atomic.AddInt64(&s.dropped, 1)
+		}
+	}
+}
+
+func (h *Hub) remove(s *Subscription) {
+	h.lock.Lock()
+	delete(h.subs, s)
+	h.lock.Unlock()
+}
+
+// Subscription .
+type Subscription struct {
+	hub     *Hub
+	filter  *Filter
+	ch      chan *logs.Log
+	dropped int64
+	once    sync.Once
+}
+
+// C returns the channel of matched logs.
+func (s *Subscription) C() <-chan *logs.Log { return s.ch }
+
+// TakeDropped returns the number of logs dropped since last call.
+func (s *Subscription) TakeDropped() int64 { return atomic.SwapInt64(&s.dropped, 0) }
+
+// Close removes the subscription from hub.
+func (s *Subscription) Close() {
+	s.once.Do(func() { s.hub.remove(s) })
+}
@@ -0,0 +1,94 @@
+// Copyright (c) 2021 Terminus, Inc.
+//
+// This program is free software: you can use, redistribute, and/or modify
+// it under the terms of the GNU Affero General Public License, version 3
+// or later ("AGPL"), as published by the Free Software Foundation.
+//
+// This program is distributed in the hope that it will be useful, but WITHOUT
+// ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+// FITNESS FOR A PARTICULAR PURPOSE.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package tail
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/erda-project/erda/modules/monitor/core/logs"
+)
+
+func TestFilterMatch(t *testing.T) {
+	log := &logs.Log{
+		Source:  "container",
+		ID:      "c1",
+		Stream:  "stdout",
+		Content: "GET /api/orders 500 timeout",
+		Tags: map[string]string{
+			"dice_application_id": "1",
+			RequestIDKey:          "r1",
+		},
+	}
+	tests := []struct {
+		filter *Filter
+		want   bool
+	}{
+		{&Filter{}, true},
+		{&Filter{Source: "container", ID: "c1", Stream: "stdout"}, true},
+		{&Filter{Source: "container", ID: "c2"}, false},
+		{&Filter{Stream: "stderr"}, false},
+		{&Filter{RequestID: "r1"}, true},
+		{&Filter{RequestID: "r2"}, false},
+		{&Filter{Tags: map[string]string{"dice_application_id": "1"}}, true},
+		{&Filter{Tags: map[string]string{"dice_application_id": "2"}}, false},
+		{&Filter{Contains: "timeout"}, true},
+		{&Filter{Contains: "refused"}, false},
+		{&Filter{Regexp: regexp.MustCompile(`\s5\d\d\s`)}, true},
+		{&Filter{Regexp: regexp.MustCompile(`\s4\d\d\s`)}, false},
+	}
+	for i, tt := range tests {
+		if got := tt.filter.Match(log); got != tt.want {
+			t.Errorf("case %d: Match() = %v, want %v", i, got, tt.want)
+		}
+	}
+}
+
+func TestHub(t *testing.T) {
+	h := NewHub(2)
+	s1, err := h.Subscribe(&Filter{ID: "c1"}, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s2, err := h.Subscribe(&Filter{ID: "c2"}, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := h.Subscribe(&Filter{}, 2); err != ErrTooManySessions {
+		t.Fatalf("want ErrTooManySessions, got %v", err)
+	}
+
+	for i := 0; i < 5; i++ {
+		h.Publish(&logs.Log{ID: "c1"})
+	}
+	h.Publish(&logs.Log{ID: "c2"})
+	if len(s1.C()) != 2 || len(s2.C()) != 1 {
+		t.Fatalf("got %d and %d buffered logs, want 2 and 1", len(s1.C()), len(s2.C()))
+	}
+	if n := s1.TakeDropped(); n != 3 {
+		t.Errorf("TakeDropped() = %d, want 3", n)
+	}
+	if n := s1.TakeDropped(); n != 0 {
+		t.Errorf("TakeDropped() = %d after taken, want 0", n)
+	}
+
+	s1.Close()
+	s1.Close()
+	if h.Len() != 1 {
+		t.Errorf("Len() = %d, want 1", h.Len())
+	}
+	if _, err := h.Subscribe(&Filter{}, 2); err != nil {
+		t.Errorf("Subscribe() after Close got error: %s", err)
+	}
+}
@@ -23,6 +23,17 @@ type Formater struct{}
 
 // Format .
 func (f *Formater) Format(q tsql.Query, rs *tsql.ResultSet, params map[string]interface{}) (interface{}, error) {
+	data, err := f.format(q, rs, params)
+	if m, ok := data.(map[string]interface{}); ok && len(rs.Rollup) > 0 {
+		m["rollup"] = rs.Rollup
+		if rs.RollupEnd > 0 {
+			m["rollup_end"] = rs.RollupEnd
+		}
+	}
+	return data, err
+}
+
+func (f *Formater) format(q tsql.Query, rs *tsql.ResultSet, params map[string]interface{}) (interface{}, error) {
 	typ := "table"
 	if t, ok := params["type"].(string); ok {
 		typ = t
@@ -36,19 +36,24 @@ func (f *Formater) Format(q tsql.Query, rs *tsql.ResultSet, params map[string]in
 	for _, c := range rs.Columns {
 		columns = append(columns, c.Name)
 	}
-	return api.SuccessRaw(&Response{
-		Results: []interface{}{
+	result := map[string]interface{}{
+		"statement_id": 0,
+		"series": []interface{}{
 			map[string]interface{}{
-				"statement_id": 0,
-				"series": []interface{}{
-					map[string]interface{}{
-						"name":    getSourceName(q),
-						"columns": columns,
-						"values":  rs.Rows,
-					},
-				},
+				"name":    getSourceName(q),
+				"columns": columns,
+				"values":  rs.Rows,
 			},
 		},
+	}
+	if len(rs.Rollup) > 0 {
+		result["rollup"] = rs.Rollup
+		if rs.RollupEnd > 0 {
+			result["rollup_end"] = rs.RollupEnd
+		}
+	}
+	return api.SuccessRaw(&Response{
+		Results: []interface{}{result},
 	}), nil
 }
 
@@ -30,6 +30,7 @@ import (
 type Parser struct {
 	ql     *influxql.Parser
 	filter *elastic.BoolQuery
+	rollup tsql.RollupPlanner
 	ctx    *Context
 }
 
@@ -90,6 +91,12 @@ func (p *Parser) SetMaxTimePoints(points int64) tsql.Parser {
 	return p
 }
 
+// SetRollupPlanner .
+func (p *Parser) SetRollupPlanner(planner tsql.RollupPlanner) tsql.Parser {
+	p.rollup = planner
+	return p
+}
+
 // ParseQuery .
 func (p *Parser) ParseQuery() ([]tsql.Query, error) {
 	q, err := p.ql.ParseQuery()
@@ -102,10 +109,22 @@ func (p *Parser) ParseQuery() ([]tsql.Query, error) {
 		if !ok {
 			return nil, tsql.ErrNotSupportNonQueryStatement
 		}
+		var tier *tsql.RollupTier
+		var split int64
+		raw := s
+		if p.rollup != nil {
+			s, tier, split = p.planRollup(s)
+		}
 		q, err := p.parseSelectStatement(s)
 		if err != nil {
 			return nil, err
 		}
+		q.rollup = tier
+		if split > 0 {
+			if _, err := p.parseSplitQuery(q, raw, split); err != nil {
+				return nil, err
+			}
+		}
 		qs = append(qs, q)
 	}
 	return qs, nil
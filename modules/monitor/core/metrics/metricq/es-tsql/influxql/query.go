@@ -35,6 +35,10 @@ type Query struct {
 	flag         queryFlag
 	aggs         map[string]elastic.Aggregation
 	ctx          *Context
+	rollup       *tsql.RollupTier
+	raw          *Query // the query of the raw data after split
+	split        int64
+	rowKeys      []rowKey // the keys of result rows, to merge the results of the rollup tier and the raw data
 	allColumnsFn func(start, end int64, sources []*tsql.Source) ([]*tsql.Column, error)
 }
 
@@ -80,6 +84,17 @@ func (q *Query) SetAllColumnsCallback(fn func(start, end int64, sources []*tsql.
 // Context .
 func (q *Query) Context() tsql.Context { return q.ctx }
 
+// Rollup .
+func (q *Query) Rollup() *tsql.RollupTier { return q.rollup }
+
+// RawQuery .
+func (q *Query) RawQuery() tsql.Query {
+	if q.raw == nil {
+		return nil
+	}
+	return q.raw
+}
+
 // ParseResult .
 func (q *Query) ParseResult(resp *elastic.SearchResult) (*tsql.ResultSet, error) {
 	if resp != nil {
@@ -88,6 +103,9 @@ func (q *Query) ParseResult(resp *elastic.SearchResult) (*tsql.ResultSet, error)
 	rs := &tsql.ResultSet{
 		Interval: q.ctx.Interval(),
 	}
+	if q.rollup != nil {
+		rs.Rollup = q.rollup.Name
+	}
 	if resp != nil {
 		rs.Total = resp.TotalHits()
 	}
@@ -315,6 +333,9 @@ func (q *Query) parseDimensionsAggsData(rs *tsql.ResultSet, aggs elastic.Aggrega
 		}
 		q.ctx.row++
 		rs.Rows = append(rs.Rows, values)
+		if q.split > 0 {
+			q.rowKeys = append(q.rowKeys, newRowKey(buckets))
+		}
 	}
 	return nil
 }
@@ -0,0 +1,352 @@
+// Copyright (c) 2021 Terminus, Inc.
+//
+// This program is free software: you can use, redistribute, and/or modify
+// it under the terms of the GNU Affero General Public License, version 3
+// or later ("AGPL"), as published by the Free Software Foundation.
+//
+// This program is distributed in the hope that it will be useful, but WITHOUT
+// ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+// FITNESS FOR A PARTICULAR PURPOSE.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package esinfluxql
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/influxdata/influxql"
+	"github.com/olivere/elastic"
+
+	tsql "github.com/erda-project/erda/modules/monitor/core/metrics/metricq/es-tsql"
+)
+
+// rollupFunctions maps the aggregate functions to the rollup aggregates they read.
+var rollupFunctions = map[string][]string{
+	"max":   {tsql.RollupMax},
+	"min":   {tsql.RollupMin},
+	"sum":   {tsql.RollupSum},
+	"count": {tsql.RollupCount},
+	"avg":   {tsql.RollupSum, tsql.RollupCount},
+	"mean":  {tsql.RollupSum, tsql.RollupCount},
+	"last":  {tsql.RollupLast},
+}
+
+// rollupRequirement collects what the statement needs from a rollup tier, it returns false if the statement can not be answered by rollups.
+func (p *Parser) rollupRequirement(s *influxql.SelectStatement) (*tsql.RollupRequirement, bool) {
+	if p.ctx.timeKey != tsql.TimestampKey || p.ctx.originalTimeUnit != tsql.Nanosecond || len(s.Sources) != 1 {
+		return nil, false
+	}
+	m, ok := s.Sources[0].(*influxql.Measurement)
+	if !ok || m.Regex != nil || len(m.Name) <= 0 {
+		return nil, false
+	}
+	req := &tsql.RollupRequirement{
+		Start:  p.ctx.start,
+		End:    p.ctx.end,
+		Fields: make(map[string][]string),
+	}
+	tags := make(map[string]bool)
+	if len(m.Database) > 0 || len(m.RetentionPolicy) > 0 {
+		tags["cluster_name"] = true
+	}
+	var aggs int
+	for _, field := range s.Fields {
+		n, ok := collectRollupExpr(field.Expr, req.Fields, tags)
+		if !ok {
+			return nil, false
+		}
+		aggs += n
+	}
+	if aggs <= 0 {
+		return nil, false // raw data
+	}
+	for _, sort := range s.SortFields {
+		if sort.Expr == nil {
+			continue
+		}
+		// avg is rewritten to an expression, which is not supported in order by.
+		if call, ok := unparen(sort.Expr).(*influxql.Call); ok && (call.Name == "avg" || call.Name == "mean") {
+			return nil, false
+		}
+		if _, ok := collectRollupExpr(sort.Expr, req.Fields, tags); !ok {
+			return nil, false
+		}
+	}
+	if s.Condition != nil && !collectRollupTags(s.Condition, tags) {
+		return nil, false
+	}
+	var grouped bool
+	for _, dim := range s.Dimensions {
+		switch expr := dim.Expr.(type) {
+		case *influxql.Call:
+			if expr.Name != "time" || grouped {
+				return nil, false
+			}
+			var interval int64
+			if len(expr.Args) == 1 {
+				d, ok := expr.Args[0].(*influxql.DurationLiteral)
+				if !ok {
+					return nil, false
+				}
+				interval = int64(d.Val)
+			}
+			req.Interval = adjustInterval(p.ctx.start, p.ctx.end, interval, p.ctx.maxTimePoints)
+			grouped = true
+		case *influxql.VarRef:
+			if !collectRollupTags(expr, tags) {
+				return nil, false
+			}
+		default:
+			return nil, false
+		}
+	}
+	// the results of time buckets can be merged by the series and time, if the order of series is not from the aggregations.
+	req.Splittable = grouped && len(s.SortFields) <= 0
+	for tag := range tags {
+		req.Tags = append(req.Tags, tag)
+	}
+	return req, true
+}
+
+// collectRollupExpr collects the fields and tags used in a select expression, and returns the number of aggregate functions.
+func collectRollupExpr(expr influxql.Expr, fields map[string][]string, tags map[string]bool) (int, bool) {
+	switch expr := expr.(type) {
+	case *influxql.Call:
+		if aggs, ok := rollupFunctions[expr.Name]; ok {
+			if len(expr.Args) != 1 {
+				return 0, false
+			}
+			ref, ok := expr.Args[0].(*influxql.VarRef)
+			if !ok || (ref.Type != influxql.Unknown && ref.Type != influxql.Float && ref.Type != influxql.Integer) || isBuiltinKey(ref.Val) {
+				return 0, false
+			}
+			fields[ref.Val] = append(fields[ref.Val], aggs...)
+			return 1, true
+		}
+		if _, ok := AggFunctions[expr.Name]; ok || expr.Name == "scope" {
+			return 0, false
+		}
+		if _, ok := tsql.BuildInFunctions[expr.Name]; !ok {
+			return 0, false
+		}
+		var num int
+		for _, arg := range expr.Args {
+			n, ok := collectRollupExpr(arg, fields, tags)
+			if !ok {
+				return 0, false
+			}
+			num += n
+		}
+		return num, true
+	case *influxql.BinaryExpr:
+		l, ok := collectRollupExpr(expr.LHS, fields, tags)
+		if !ok {
+			return 0, false
+		}
+		r, ok := collectRollupExpr(expr.RHS, fields, tags)
+		return l + r, ok
+	case *influxql.ParenExpr:
+		return collectRollupExpr(expr.Expr, fields, tags)
+	case *influxql.VarRef:
+		// the columns out of aggregate functions must be tags.
+		if isBuiltinKey(expr.Val) {
+			return 0, true
+		}
+		if expr.Type != influxql.Tag {
+			return 0, false
+		}
+		tags[expr.Val] = true
+		return 0, true
+	case *influxql.Wildcard:
+		return 0, false
+	}
+	return 0, true
+}
+
+// collectRollupTags collects the tags used in a condition or a dimension, it returns false if there are any fields.
+func collectRollupTags(expr influxql.Expr, tags map[string]bool) bool {
+	ok := true
+	influxql.WalkFunc(expr, func(n influxql.Node) {
+		switch n := n.(type) {
+		case *influxql.VarRef:
+			if isBuiltinKey(n.Val) {
+				return
+			}
+			if n.Type != influxql.Unknown && n.Type != influxql.Tag {
+				ok = false
+				return
+			}
+			tags[n.Val] = true
+		case *influxql.Call:
+			if _, agg := AggFunctions[n.Name]; agg {
+				ok = false
+			}
+		}
+	})
+	return ok
+}
+
+func unparen(expr influxql.Expr) influxql.Expr {
+	for {
+		paren, ok := expr.(*influxql.ParenExpr)
+		if !ok {
+			return expr
+		}
+		expr = paren.Expr
+	}
+}
+
+func isBuiltinKey(key string) bool {
+	return key == tsql.TimestampKey || key == tsql.TimeKey || key == tsql.NameKey || key == nameKey
+}
+
+// planRollup returns the statement to execute, the rollup tier that answers it,
+// and the time from which the raw data answers it, 0 means the tier answers the whole statement.
+func (p *Parser) planRollup(s *influxql.SelectStatement) (*influxql.SelectStatement, *tsql.RollupTier, int64) {
+	req, ok := p.rollupRequirement(s)
+	if !ok {
+		return s, nil, 0
+	}
+	metric := s.Sources[0].(*influxql.Measurement).Name
+	tier, split := tsql.SelectRollupTier(p.rollup.RollupTiers(metric), req, p.ctx.Now())
+	if tier == nil {
+		return s, nil, 0
+	}
+	return rewriteForRollup(s), tier, split
+}
+
+// parseSplitQuery parses the statement for the raw data after the split, with the same time range and interval as q,
+// the rollup tier answers the windows which start before split, and the raw data answers the points after these windows.
+func (p *Parser) parseSplitQuery(q *Query, s *influxql.SelectStatement, split int64) (*Query, error) {
+	ctx := *p.ctx
+	ctx.calls, ctx.dimensions, ctx.scopes = nil, nil, nil
+	raw, err := (&Parser{filter: p.filter, ctx: &ctx}).parseSelectStatement(s)
+	if err != nil {
+		return nil, err
+	}
+	q.split, raw.split = split, split
+	q.boolQuery.Filter(elastic.NewRangeQuery(tsql.TimestampKey).Lt(split))
+	raw.boolQuery.Filter(elastic.NewRangeQuery(tsql.TimestampKey).Gte(tsql.RollupWindowEnd(split, q.rollup.Resolution)))
+	q.raw = raw
+	return raw, nil
+}
+
+// rowKey is the series and the time bucket of a row.
+type rowKey struct {
+	series string
+	time   int64
+}
+
+func newRowKey(buckets []interface{}) rowKey {
+	var key rowKey
+	var sb strings.Builder
+	for _, bucket := range buckets {
+		switch b := bucket.(type) {
+		case *elastic.AggregationBucketHistogramItem:
+			key.time = int64(b.Key)
+		case *elastic.AggregationBucketKeyItem:
+			fmt.Fprintf(&sb, "%v\x00", b.Key)
+		}
+	}
+	key.series = sb.String()
+	return key
+}
+
+// MergeResult merges the result of the raw query into the result of the rollup tier,
+// the time buckets before split are from the rollup tier, and the others are from the raw data.
+func (q *Query) MergeResult(rs, raw *tsql.ResultSet) (*tsql.ResultSet, error) {
+	if q.raw == nil || raw == nil {
+		return rs, nil
+	}
+	if len(rs.Rows) != len(q.rowKeys) || len(raw.Rows) != len(q.raw.rowKeys) {
+		return nil, fmt.Errorf("fail to merge the results of rollup and raw data")
+	}
+	var order []string
+	series := make(map[string]map[int64][]interface{})
+	add := func(key rowKey, row []interface{}, replace bool) {
+		rows, ok := series[key.series]
+		if !ok {
+			rows = make(map[int64][]interface{})
+			series[key.series] = rows
+			order = append(order, key.series)
+		}
+		if _, ok := rows[key.time]; !ok || replace {
+			rows[key.time] = row
+		}
+	}
+	// the empty buckets of a side are kept if the other side has no row of them.
+	for i, key := range q.rowKeys {
+		add(key, rs.Rows[i], key.time < q.split)
+	}
+	for i, key := range q.raw.rowKeys {
+		add(key, raw.Rows[i], key.time >= q.split)
+	}
+	rows := make([][]interface{}, 0, len(rs.Rows))
+	for _, key := range order {
+		times := make([]int64, 0, len(series[key]))
+		for t := range series[key] {
+			times = append(times, t)
+		}
+		sort.Slice(times, func(i, j int) bool { return times[i] < times[j] })
+		for _, t := range times {
+			rows = append(rows, series[key][t])
+		}
+	}
+	rs.Rows = rows
+	rs.Total += raw.Total
+	rs.RollupEnd = q.split
+	return rs, nil
+}
+
+// rewriteForRollup rewrites the aggregate functions of the statement to read the rollup fields.
+func rewriteForRollup(s *influxql.SelectStatement) *influxql.SelectStatement {
+	sorts := s.SortFields
+	s = s.Clone()
+	for _, field := range s.Fields {
+		// keep the column names of the original statement.
+		name := getColumnName(field)
+		field.Expr = influxql.RewriteExpr(field.Expr, rewriteRollupCall)
+		if len(field.Alias) <= 0 && field.String() != name {
+			field.Alias = name
+		}
+	}
+	for i, sort := range s.SortFields {
+		// Clone does not copy the expressions of sort fields.
+		if expr := sorts[i].Expr; expr != nil {
+			sort.Expr = influxql.RewriteExpr(influxql.CloneExpr(expr), rewriteRollupCall)
+		}
+	}
+	return s
+}
+
+func rewriteRollupCall(expr influxql.Expr) influxql.Expr {
+	call, ok := expr.(*influxql.Call)
+	if !ok {
+		return expr
+	}
+	if _, ok := rollupFunctions[call.Name]; !ok || len(call.Args) != 1 {
+		return expr
+	}
+	ref, ok := call.Args[0].(*influxql.VarRef)
+	if !ok {
+		return expr
+	}
+	field := func(agg string) *influxql.VarRef {
+		return &influxql.VarRef{Val: tsql.RollupField(ref.Val, agg), Type: ref.Type}
+	}
+	switch call.Name {
+	case "count":
+		return &influxql.Call{Name: "sum", Args: []influxql.Expr{field(tsql.RollupCount)}}
+	case "avg", "mean":
+		return &influxql.ParenExpr{Expr: &influxql.BinaryExpr{
+			Op:  influxql.DIV,
+			LHS: &influxql.Call{Name: "sum", Args: []influxql.Expr{field(tsql.RollupSum)}},
+			RHS: &influxql.Call{Name: "sum", Args: []influxql.Expr{field(tsql.RollupCount)}},
+		}}
+	}
+	return &influxql.Call{Name: call.Name, Args: []influxql.Expr{field(rollupFunctions[call.Name][0])}}
+}
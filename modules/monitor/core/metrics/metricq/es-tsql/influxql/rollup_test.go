@@ -0,0 +1,139 @@
+// Copyright (c) 2021 Terminus, Inc.
+//
+// This program is free software: you can use, redistribute, and/or modify
+// it under the terms of the GNU Affero General Public License, version 3
+// or later ("AGPL"), as published by the Free Software Foundation.
+//
+// This program is distributed in the hope that it will be useful, but WITHOUT
+// ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+// FITNESS FOR A PARTICULAR PURPOSE.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package esinfluxql
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	tsql "github.com/erda-project/erda/modules/monitor/core/metrics/metricq/es-tsql"
+)
+
+type testPlanner []*tsql.RollupTier
+
+func (p testPlanner) RollupTiers(metric string) []*tsql.RollupTier {
+	if metric != "host_summary" {
+		return nil
+	}
+	return p
+}
+
+func TestParserRollup(t *testing.T) {
+	now := time.Now()
+	planner := testPlanner{
+		{
+			Name:       "5m",
+			Resolution: 5 * time.Minute,
+			Watermark:  now.Truncate(5 * time.Minute).UnixNano(),
+			Indices:    []string{"spot_rollup_5m-host_summary-*"},
+			Tags:       map[string]bool{"host_ip": true, "cluster_name": true},
+			Fields:     map[string]bool{"mem_used": true, "cpu_usage": true},
+			Aggregates: map[string]bool{"min": true, "max": true, "sum": true, "count": true, "last": true},
+		},
+	}
+	end := now.Add(-time.Hour).UnixNano()
+	start := end - int64(24*time.Hour)
+	tests := []struct {
+		stmt   string
+		rollup bool
+		fields string
+	}{
+		{
+			stmt:   "SELECT max(mem_used), avg(cpu_usage) FROM host_summary WHERE host_ip=$ip GROUP BY time(10m)",
+			rollup: true,
+			fields: "max(mem_used_max) AS \"max(mem_used)\", (sum(cpu_usage_sum) / sum(cpu_usage_count)) AS \"avg(cpu_usage)\"",
+		},
+		{
+			stmt:   "SELECT host_ip::tag, count(mem_used) AS c FROM host_summary GROUP BY host_ip ORDER BY count(mem_used) DESC",
+			rollup: true,
+			fields: "host_ip::tag, sum(mem_used_count) AS c",
+		},
+		{stmt: "SELECT mem_used FROM host_summary"},
+		{stmt: "SELECT max(mem_used) FROM host_summary GROUP BY time(7m)"},
+		{stmt: "SELECT max(mem_used) FROM docker_container_summary GROUP BY time(10m)"},
+		{stmt: "SELECT max(mem_used) FROM host_summary WHERE mem_used::field>1 GROUP BY time(10m)"},
+		{stmt: "SELECT max(mem_used) FROM host_summary WHERE disk=$ip GROUP BY time(10m)"},
+		{stmt: "SELECT distinct(mem_used) FROM host_summary GROUP BY time(10m)"},
+		{stmt: "SELECT host_ip::tag, avg(mem_used) FROM host_summary GROUP BY host_ip ORDER BY avg(mem_used)"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.stmt, func(t *testing.T) {
+			qs, err := New(start, end, tt.stmt).SetParams(map[string]interface{}{"ip": "127.0.0.1"}).SetRollupPlanner(planner).ParseQuery()
+			if err != nil {
+				t.Fatalf("ParseQuery() error: %s", err)
+			}
+			q := qs[0].(*Query)
+			if (q.Rollup() != nil) != tt.rollup {
+				t.Fatalf("Rollup() = %v, want rollup %v", q.Rollup(), tt.rollup)
+			}
+			if tt.rollup && q.stmt.Fields.String() != tt.fields {
+				t.Errorf("rewritten fields = %s, want %s", q.stmt.Fields.String(), tt.fields)
+			}
+		})
+	}
+}
+
+func TestParserRollup_Split(t *testing.T) {
+	now := time.Now()
+	planner := testPlanner{
+		{
+			Name:       "5m",
+			Resolution: 5 * time.Minute,
+			Watermark:  now.Add(-time.Hour).Truncate(5 * time.Minute).UnixNano(),
+			Indices:    []string{"spot_rollup_5m-host_summary-*"},
+			Tags:       map[string]bool{"host_ip": true},
+			Fields:     map[string]bool{"mem_used": true},
+			Aggregates: map[string]bool{"max": true},
+		},
+	}
+	start := now.Add(-24 * time.Hour).Truncate(time.Hour).UnixNano()
+	parse := func(stmt string) *Query {
+		qs, err := New(start, now.UnixNano(), stmt).SetRollupPlanner(planner).ParseQuery()
+		if err != nil {
+			t.Fatalf("ParseQuery() error: %s", err)
+		}
+		return qs[0].(*Query)
+	}
+
+	q := parse("SELECT host_ip::tag, max(mem_used) FROM host_summary GROUP BY time(1h), host_ip")
+	if q.Rollup() == nil || q.RawQuery() == nil {
+		t.Fatalf("query after watermark is not split, rollup: %v, raw query: %v", q.Rollup(), q.RawQuery())
+	}
+	split := now.Add(-time.Hour).Truncate(5*time.Minute).UnixNano() - start
+	split = start + split - split%int64(time.Hour)
+	if q.split != split {
+		t.Errorf("split = %s, want %s", time.Unix(0, q.split), time.Unix(0, split))
+	}
+	if raw := q.RawQuery().(*Query); raw.Rollup() != nil || raw.ctx.Interval() != q.ctx.Interval() {
+		t.Errorf("raw query is not consistent with the rollup query")
+	}
+
+	if q := parse("SELECT host_ip::tag, max(mem_used) FROM host_summary GROUP BY time(1h), host_ip ORDER BY max(mem_used)"); q.Rollup() != nil {
+		t.Errorf("query ordered by aggregations should not be split")
+	}
+
+	// merge
+	hour := int64(time.Hour)
+	q.rowKeys = []rowKey{{"a", split - hour}, {"a", split}, {"b", split - hour}}
+	q.raw.rowKeys = []rowKey{{"a", split - hour}, {"a", split}, {"c", split}}
+	rs, err := q.MergeResult(&tsql.ResultSet{Total: 2, Rows: [][]interface{}{{"a", 1}, {"a", 0}, {"b", 3}}},
+		&tsql.ResultSet{Total: 3, Rows: [][]interface{}{{"a", 0}, {"a", 2}, {"c", 4}}})
+	if err != nil {
+		t.Fatalf("MergeResult() error: %s", err)
+	}
+	if want := "[[a 1] [a 2] [b 3] [c 4]]"; fmt.Sprint(rs.Rows) != want || rs.Total != 5 || rs.RollupEnd != split {
+		t.Errorf("MergeResult() = %v, total %d, want %s", rs.Rows, rs.Total, want)
+	}
+}
@@ -101,8 +101,11 @@ func ConvertTimestamp(t int64, from, to TimeUnit) int64 {
 type ResultSet struct {
 	Total    int64
 	Interval int64
-	Columns  []*Column
-	Rows     [][]interface{}
+	Rollup   string // name of the rollup tier, empty means the raw data.
+	// RollupEnd is the time in nanosecond from which the raw data answers, 0 means the rollup tier answers the whole range.
+	RollupEnd int64
+	Columns   []*Column
+	Rows      [][]interface{}
 }
 
 // Column .
@@ -199,6 +202,12 @@ type Query interface {
 	SetAllColumnsCallback(fn func(start, end int64, sources []*Source) ([]*Column, error))
 	ParseResult(resp *elastic.SearchResult) (*ResultSet, error)
 	Context() Context
+	// Rollup returns the rollup tier that answers the query, nil means the raw data.
+	Rollup() *RollupTier
+	// RawQuery returns the query of the raw data after the watermark of the rollup tier, nil if the tier answers the whole query.
+	RawQuery() Query
+	// MergeResult merges the result of RawQuery into the result of the query.
+	MergeResult(rs, raw *ResultSet) (*ResultSet, error)
 }
 
 // ErrNotSupportNonQueryStatement .
@@ -212,6 +221,7 @@ type Parser interface {
 	SetTargetTimeUnit(unit TimeUnit) Parser
 	SetTimeKey(key string) Parser
 	SetMaxTimePoints(points int64) Parser
+	SetRollupPlanner(planner RollupPlanner) Parser
 	ParseQuery() ([]Query, error)
 	ParseRawQuery() ([]*Source, *elastic.BoolQuery, *elastic.SearchSource, error)
 }
@@ -0,0 +1,165 @@
+// Copyright (c) 2021 Terminus, Inc.
+//
+// This program is free software: you can use, redistribute, and/or modify
+// it under the terms of the GNU Affero General Public License, version 3
+// or later ("AGPL"), as published by the Free Software Foundation.
+//
+// This program is distributed in the hope that it will be useful, but WITHOUT
+// ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+// FITNESS FOR A PARTICULAR PURPOSE.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package tsql
+
+import (
+	"fmt"
+	"time"
+)
+
+// rollup aggregates
+const (
+	RollupMin   = "min"
+	RollupMax   = "max"
+	RollupSum   = "sum"
+	RollupCount = "count"
+	RollupLast  = "last"
+)
+
+// RollupAggregates are all the aggregates that a rollup tier can keep.
+var RollupAggregates = []string{RollupMin, RollupMax, RollupSum, RollupCount, RollupLast}
+
+// RollupTier is a resolution of pre-aggregated metrics.
+// A rollup document keeps the name and the tags of the raw points,
+// and stores every aggregate of a field as "<field>_<aggregate>", such as cpu_usage_max.
+type RollupTier struct {
+	Name       string
+	Resolution time.Duration
+	Retention  time.Duration
+	// Watermark is the time in nanosecond before which all windows of the tier are rolled up.
+	Watermark int64
+	// Indices are the indices to read for the metric.
+	Indices    []string
+	Tags       map[string]bool
+	Fields     map[string]bool
+	Aggregates map[string]bool
+}
+
+// RollupField returns the key of the field aggregated by agg in the rollup document.
+func RollupField(field, agg string) string {
+	return field + "_" + agg
+}
+
+// FormatResolution formats d in short form, such as 5m, 1h.
+func FormatResolution(d time.Duration) string {
+	switch {
+	case d >= time.Hour && d%time.Hour == 0:
+		return fmt.Sprintf("%dh", d/time.Hour)
+	case d >= time.Minute && d%time.Minute == 0:
+		return fmt.Sprintf("%dm", d/time.Minute)
+	}
+	return fmt.Sprintf("%ds", d/time.Second)
+}
+
+// RollupPlanner provides the rollup tiers of metrics.
+type RollupPlanner interface {
+	// RollupTiers returns the tiers of the metric, from fine to coarse.
+	RollupTiers(metric string) []*RollupTier
+}
+
+// RollupRequirement is what a query needs from a rollup tier.
+type RollupRequirement struct {
+	Start, End int64 // nanosecond
+	// Interval is the interval of group by time in nanosecond, 0 means the query is not grouped by time.
+	Interval int64
+	// Splittable means the time buckets can be answered by the rollup tier and the raw data separately.
+	Splittable bool
+	Tags       []string
+	// Fields maps a field to the aggregates needed.
+	Fields map[string][]string
+}
+
+// SelectRollupTier returns the coarsest tier which can answer the query, or nil if the raw data should be read.
+// A tier only answers the time before its watermark, the split is the start of the time buckets after the watermark,
+// which should be answered by the raw data, 0 means the tier answers the whole query.
+func SelectRollupTier(tiers []*RollupTier, req *RollupRequirement, now time.Time) (selected *RollupTier, split int64) {
+	for _, tier := range tiers {
+		res := int64(tier.Resolution)
+		if res <= 0 {
+			continue
+		}
+		if req.Interval > 0 {
+			// every time bucket must contain the same number of rollup windows.
+			if res > req.Interval || req.Interval%res != 0 {
+				continue
+			}
+		} else if res > req.End-req.Start {
+			continue
+		}
+		if tier.Retention > 0 && req.Start < now.Add(-tier.Retention).UnixNano() {
+			continue
+		}
+		if !tier.satisfy(req) {
+			continue
+		}
+		s, ok := tier.split(req)
+		if !ok {
+			continue
+		}
+		if selected == nil || tier.Resolution > selected.Resolution {
+			selected, split = tier, s
+		}
+	}
+	return selected, split
+}
+
+// split returns the start of the time buckets which are not covered by the watermark.
+func (t *RollupTier) split(req *RollupRequirement) (int64, bool) {
+	res := int64(t.Resolution)
+	// the rollup documents are at the start of windows, and the window of end must be complete.
+	if req.End-req.End%res+res <= t.Watermark {
+		return 0, true
+	}
+	if !req.Splittable || req.Interval <= 0 {
+		return 0, false
+	}
+	// the windows before split are read from the tier, and the points from the next window on are read from the raw data,
+	// so the split must be at a time bucket, and the window of it must be complete.
+	n := (t.Watermark - req.Start) / req.Interval
+	if n > 0 && RollupWindowEnd(req.Start+n*req.Interval, t.Resolution) > t.Watermark {
+		n--
+	}
+	if n <= 0 {
+		return 0, false
+	}
+	return req.Start + n*req.Interval, true
+}
+
+// RollupWindowEnd returns the end of the last window of the tier which starts before t.
+func RollupWindowEnd(t int64, resolution time.Duration) int64 {
+	res := int64(resolution)
+	if r := t % res; r != 0 {
+		return t - r + res
+	}
+	return t
+}
+
+func (t *RollupTier) satisfy(req *RollupRequirement) bool {
+	for _, tag := range req.Tags {
+		if !t.Tags[tag] {
+			return false
+		}
+	}
+	for field, aggs := range req.Fields {
+		if !t.Fields[field] {
+			return false
+		}
+		for _, agg := range aggs {
+			if !t.Aggregates[agg] {
+				return false
+			}
+		}
+	}
+	return true
+}
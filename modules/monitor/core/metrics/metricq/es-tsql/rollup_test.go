@@ -0,0 +1,196 @@
+// Copyright (c) 2021 Terminus, Inc.
+//
+// This program is free software: you can use, redistribute, and/or modify
+// it under the terms of the GNU Affero General Public License, version 3
+// or later ("AGPL"), as published by the Free Software Foundation.
+//
+// This program is distributed in the hope that it will be useful, but WITHOUT
+// ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+// FITNESS FOR A PARTICULAR PURPOSE.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package tsql
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSelectRollupTier(t *testing.T) {
+	now := time.Unix(1618300000, 0)
+	newTier := func(res, retention time.Duration) *RollupTier {
+		return &RollupTier{
+			Name:       FormatResolution(res),
+			Resolution: res,
+			Retention:  retention,
+			Watermark:  now.UnixNano(),
+			Tags:       map[string]bool{"host_ip": true},
+			Fields:     map[string]bool{"mem_used": true},
+			Aggregates: map[string]bool{RollupMax: true, RollupSum: true, RollupCount: true},
+		}
+	}
+	tiers := []*RollupTier{
+		newTier(time.Minute, 7*24*time.Hour),
+		newTier(5*time.Minute, 30*24*time.Hour),
+		newTier(time.Hour, 365*24*time.Hour),
+	}
+	day := int64(24 * time.Hour)
+	end := now.Add(-2 * time.Hour).UnixNano()
+	tests := []struct {
+		name string
+		req  *RollupRequirement
+		want string
+	}{
+		{
+			name: "coarsest",
+			req: &RollupRequirement{
+				Start: end - day, End: end, Interval: int64(2 * time.Hour),
+				Tags: []string{"host_ip"}, Fields: map[string][]string{"mem_used": {RollupMax}},
+			},
+			want: "1h",
+		},
+		{
+			name: "interval not divisible",
+			req: &RollupRequirement{
+				Start: end - day, End: end, Interval: int64(10 * time.Minute),
+				Fields: map[string][]string{"mem_used": {RollupSum, RollupCount}},
+			},
+			want: "5m",
+		},
+		{
+			name: "retention",
+			req: &RollupRequirement{
+				Start: end - 10*day, End: end, Interval: int64(10 * time.Minute),
+				Fields: map[string][]string{"mem_used": {RollupMax}},
+			},
+			want: "5m",
+		},
+		{
+			name: "without group by time",
+			req: &RollupRequirement{
+				Start: end - 90*day, End: end,
+				Fields: map[string][]string{"mem_used": {RollupMax}},
+			},
+			want: "1h",
+		},
+		{
+			name: "unknown tag",
+			req: &RollupRequirement{
+				Start: end - day, End: end, Interval: int64(time.Hour),
+				Tags: []string{"cluster_name"}, Fields: map[string][]string{"mem_used": {RollupMax}},
+			},
+		},
+		{
+			name: "unknown aggregate",
+			req: &RollupRequirement{
+				Start: end - day, End: end, Interval: int64(time.Hour),
+				Fields: map[string][]string{"mem_used": {RollupLast}},
+			},
+		},
+		{
+			name: "interval too small",
+			req: &RollupRequirement{
+				Start: end - day, End: end, Interval: int64(30 * time.Second),
+				Fields: map[string][]string{"mem_used": {RollupMax}},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got string
+			if tier, _ := SelectRollupTier(tiers, tt.req, now); tier != nil {
+				got = tier.Name
+			}
+			if got != tt.want {
+				t.Errorf("SelectRollupTier() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSelectRollupTier_Watermark(t *testing.T) {
+	now := time.Unix(1618300800, 0).Add(30 * time.Minute) // 08:30
+	newTier := func(res time.Duration, watermark time.Time) *RollupTier {
+		return &RollupTier{
+			Name:       FormatResolution(res),
+			Resolution: res,
+			Watermark:  watermark.UnixNano(),
+			Fields:     map[string]bool{"mem_used": true},
+			Aggregates: map[string]bool{RollupMax: true},
+		}
+	}
+	tiers := []*RollupTier{
+		newTier(time.Minute, now.Add(-3*time.Minute)),
+		newTier(5*time.Minute, now.Add(-10*time.Minute)),
+		newTier(time.Hour, now.Add(-90*time.Minute)),
+	}
+	fields := map[string][]string{"mem_used": {RollupMax}}
+	tests := []struct {
+		name  string
+		req   *RollupRequirement
+		want  string
+		split time.Time
+	}{
+		{
+			name: "split at the bucket before watermark",
+			req: &RollupRequirement{
+				Start: now.Add(-24 * time.Hour).UnixNano(), End: now.UnixNano(), Interval: int64(time.Hour),
+				Splittable: true, Fields: fields,
+			},
+			want:  "1h",
+			split: now.Add(-2 * time.Hour),
+		},
+		{
+			name: "not splittable",
+			req: &RollupRequirement{
+				Start: now.Add(-24 * time.Hour).UnixNano(), End: now.UnixNano(), Interval: int64(time.Hour),
+				Fields: fields,
+			},
+		},
+		{
+			name: "covered by watermark",
+			req: &RollupRequirement{
+				Start: now.Add(-24 * time.Hour).UnixNano(), End: now.Add(-90 * time.Minute).UnixNano(), Interval: int64(time.Hour),
+				Fields: fields,
+			},
+			want: "5m",
+		},
+		{
+			name: "watermark before start",
+			req: &RollupRequirement{
+				Start: now.Add(-60 * time.Minute).UnixNano(), End: now.UnixNano(), Interval: int64(10 * time.Minute),
+				Splittable: true, Fields: fields,
+			},
+			want:  "5m",
+			split: now.Add(-10 * time.Minute),
+		},
+		{
+			name: "without group by time",
+			req: &RollupRequirement{
+				Start: now.Add(-24 * time.Hour).UnixNano(), End: now.UnixNano(),
+				Splittable: true, Fields: fields,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got string
+			tier, split := SelectRollupTier(tiers, tt.req, now)
+			if tier != nil {
+				got = tier.Name
+			}
+			if got != tt.want {
+				t.Errorf("SelectRollupTier() = %q, want %q", got, tt.want)
+			}
+			var want int64
+			if !tt.split.IsZero() {
+				want = tt.split.UnixNano()
+			}
+			if split != want {
+				t.Errorf("SelectRollupTier() split = %s, want %s", time.Unix(0, split), time.Unix(0, want))
+			}
+		})
+	}
+}
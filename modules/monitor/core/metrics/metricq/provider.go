@@ -27,6 +27,7 @@ import (
 	"github.com/erda-project/erda-infra/providers/mysql"
 	indexmanager "github.com/erda-project/erda/modules/monitor/core/metrics/index"
 	"github.com/erda-project/erda/modules/monitor/core/metrics/metricq/chartmeta"
+	tsql "github.com/erda-project/erda/modules/monitor/core/metrics/metricq/es-tsql"
 	"github.com/erda-project/erda/modules/monitor/core/metrics/metricq/metricmeta"
 	"github.com/erda-project/erda/modules/monitor/core/metrics/metricq/query"
 
@@ -50,9 +51,10 @@ func (d *define) Service() []string { return []string{"metrics-query"} }
 func (d *define) Dependencies() []string {
 	return []string{"mysql", "metrics-index-manager", "http-server", "i18n", "i18n@metric"}
 }
-func (d *define) Summary() string     { return "metrics query api" }
-func (d *define) Description() string { return d.Summary() }
-func (d *define) Config() interface{} { return &config{} }
+func (d *define) OptionalDependencies() []string { return []string{"metrics-rollup"} }
+func (d *define) Summary() string                { return "metrics query api" }
+func (d *define) Description() string            { return d.Summary() }
+func (d *define) Config() interface{}            { return &config{} }
 func (d *define) Creator() servicehub.Creator {
 	return func() servicehub.Provider {
 		return &provider{}
@@ -81,6 +83,7 @@ func (p *provider) Init(ctx servicehub.Context) error {
 
 	db := ctx.Service("mysql").(mysql.Interface).DB()
 	index := ctx.Service("metrics-index-manager").(indexmanager.Index)
+	rollup, _ := ctx.Service("metrics-rollup").(tsql.RollupPlanner)
 	trans := ctx.Service("i18n").(i18n.I18n).Translator("charts")
 	charts := chartmeta.NewManager(db, p.C.ChartMeta.ReloadInterval, p.C.ChartMeta.Path, trans, p.L)
 
@@ -95,7 +98,7 @@ func (p *provider) Init(ctx servicehub.Context) error {
 	)
 
 	p.q = &metricq{
-		Queryer:   query.New(index),
+		Queryer:   query.New(index, rollup),
 		queryv1:   queryv1.New(index, charts, meta, trans),
 		index:     index,
 		meta:      meta,
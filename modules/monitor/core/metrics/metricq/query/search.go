@@ -30,13 +30,15 @@ import (
 )
 
 type queryer struct {
-	index indexmanager.Index
+	index  indexmanager.Index
+	rollup tsql.RollupPlanner
 }
 
-// New .
-func New(index indexmanager.Index) Queryer {
+// New rollup is optional, the queries are answered by the raw data if it is nil.
+func New(index indexmanager.Index, rollup tsql.RollupPlanner) Queryer {
 	return &queryer{
-		index: index,
+		index:  index,
+		rollup: rollup,
 	}
 }
 
@@ -87,6 +89,10 @@ func (q *queryer) buildTSQLParser(ql, statement string, params map[string]interf
 		params = others
 	}
 	parser.SetParams(params)
+	if q.rollup != nil && len(filters) <= 0 {
+		// the rollup tiers only keep some tags, so the queries with extra filters read the raw data.
+		parser.SetRollupPlanner(q.rollup)
+	}
 	unit := options.Get("epoch") // Keep the same parameters as the influxdb.
 	if len(unit) > 0 {
 		unit, err := tsql.ParseTimeUnit(unit)
@@ -133,14 +139,24 @@ func (q *queryer) doQuery(ql, statement string, params map[string]interface{}, o
 	query := querys[0]
 	metrics, clusters := getMetricsAndClustersFromSources(query.Sources())
 	indices := q.index.GetReadIndices(metrics, clusters, start, end)
+	rawQuery, rawIndices := query.RawQuery(), indices
+	if tier := query.Rollup(); tier != nil {
+		indices = tier.Indices
+	}
 	for _, c := range clusters {
 		query.BoolQuery().Filter(elastic.NewTermQuery(TagKey+".cluster_name", c))
+		if rawQuery != nil {
+			rawQuery.BoolQuery().Filter(elastic.NewTermQuery(TagKey+".cluster_name", c))
+		}
 	}
 	if len(indices) == 1 {
 		if strings.HasSuffix(indices[0], "-empty") {
 			query.BoolQuery().Filter(elastic.NewTermQuery(TagKey+".not_exist", "_not_exist"))
 		}
 	}
+	if rawQuery != nil && len(rawIndices) == 1 && strings.HasSuffix(rawIndices[0], "-empty") {
+		rawQuery.BoolQuery().Filter(elastic.NewTermQuery(TagKey+".not_exist", "_not_exist"))
+	}
 	searchSource := query.SearchSource()
 	result := &ResultSet{}
 	if _, ok := options["debug"]; ok {
@@ -168,6 +184,26 @@ func (q *queryer) doQuery(ql, statement string, params map[string]interface{}, o
 	if err != nil {
 		return nil, nil, nil, err
 	}
+	if rawQuery != nil {
+		// the time after the watermark of rollup tier is answered by the raw data.
+		var rawResp *elastic.SearchResult
+		if searchSource := rawQuery.SearchSource(); searchSource != nil {
+			now := time.Now()
+			rawResp, err = q.esRequest(rawIndices, searchSource)
+			if err != nil {
+				return nil, nil, nil, err
+			}
+			result.Elapsed.Search += time.Now().Sub(now)
+		}
+		raw, err := rawQuery.ParseResult(rawResp)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		rs, err = query.MergeResult(rs, raw)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+	}
 	result.ResultSet = rs
 	return result, query, others, nil
 }
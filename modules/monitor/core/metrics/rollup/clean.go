@@ -0,0 +1,86 @@
+// Copyright (c) 2021 Terminus, Inc.
+//
+// This program is free software: you can use, redistribute, and/or modify
+// it under the terms of the GNU Affero General Public License, version 3
+// or later ("AGPL"), as published by the Free Software Foundation.
+//
+// This program is distributed in the hope that it will be useful, but WITHOUT
+// ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+// FITNESS FOR A PARTICULAR PURPOSE.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package rollup
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"time"
+)
+
+func (m *Manager) startClean() {
+	m.log.Infof("enable rollup indices clean, interval: %v", m.cfg.CleanInterval)
+	tick := time.Tick(m.cfg.CleanInterval)
+	for {
+		if err := m.cleanIndices(time.Now()); err != nil {
+			m.log.Errorf("fail to clean rollup indices: %s", err)
+		}
+		select {
+		case <-tick:
+		case <-m.closeCh:
+			return
+		}
+	}
+}
+
+// cleanIndices deletes the indices of tiers which are out of the ttl.
+func (m *Manager) cleanIndices(now time.Time) error {
+	ttls := make(map[string]time.Duration)
+	for _, t := range m.tiers {
+		ttls[m.cfg.IndexPrefix+"_"+t.name] = t.ttl
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), m.cfg.RequestTimeout)
+	resps, err := m.client.CatIndices().Index(m.cfg.IndexPrefix + "_*").Columns("index").Do(ctx)
+	cancel()
+	if err != nil {
+		return err
+	}
+	var removeList []string
+	for _, item := range resps {
+		// spot_rollup_<tier>-<metric>-<day timestamp>
+		parts := strings.Split(item.Index, "-")
+		if len(parts) < 3 {
+			continue
+		}
+		ttl, ok := ttls[parts[0]]
+		if !ok || ttl <= 0 {
+			continue
+		}
+		day, err := strconv.ParseInt(parts[len(parts)-1], 10, 64)
+		if err != nil {
+			continue
+		}
+		maxT := time.Unix(0, day*int64(time.Millisecond)).Add(24 * time.Hour)
+		if now.After(maxT.Add(ttl)) {
+			removeList = append(removeList, item.Index)
+		}
+	}
+	const size = 10 // Delete too much at once and the request will be rejected
+	for len(removeList) > 0 {
+		n := size
+		if len(removeList) < n {
+			n = len(removeList)
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), m.cfg.RequestTimeout)
+		_, err := m.client.DeleteIndex(removeList[:n]...).Do(ctx)
+		cancel()
+		if err != nil {
+			return err
+		}
+		m.log.Infof("clean rollup indices: %v", removeList[:n])
+		removeList = removeList[n:]
+	}
+	return nil
+}
@@ -0,0 +1,309 @@
+// Copyright (c) 2021 Terminus, Inc.
+//
+// This program is free software: you can use, redistribute, and/or modify
+// it under the terms of the GNU Affero General Public License, version 3
+// or later ("AGPL"), as published by the Free Software Foundation.
+//
+// This program is distributed in the hope that it will be useful, but WITHOUT
+// ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+// FITNESS FOR A PARTICULAR PURPOSE.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package rollup
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/olivere/elastic"
+	"github.com/recallsong/go-utils/encoding/md5x"
+
+	mutex "github.com/erda-project/erda-infra/providers/etcd-mutex"
+	"github.com/erda-project/erda/modules/monitor/core/metrics"
+	tsql "github.com/erda-project/erda/modules/monitor/core/metrics/metricq/es-tsql"
+)
+
+// Start .
+func (m *Manager) Start(lock mutex.Mutex) error {
+	if len(m.tiers) <= 0 || len(m.rules) <= 0 {
+		m.log.Infof("no rollup tiers or rules, rollup disabled")
+		return nil
+	}
+	if int64(m.cfg.CheckInterval) <= 0 {
+		return fmt.Errorf("invalid CheckInterval: %v", m.cfg.CheckInterval)
+	}
+	if int64(m.cfg.CleanInterval) > 0 {
+		go m.startClean()
+	}
+	go m.syncWatermarks()
+	go func() {
+		if lock != nil {
+			defer lock.Close()
+		}
+		m.log.Infof("enable metrics rollup, tiers: %d, metrics: %d", len(m.tiers), len(m.rules))
+		tick := time.Tick(m.cfg.CheckInterval)
+		for {
+			if lock != nil {
+				err := lock.Lock(context.Background())
+				if err == nil {
+					m.rollupTiers(time.Now())
+				}
+				lock.Unlock(context.Background())
+			} else {
+				m.rollupTiers(time.Now())
+			}
+			select {
+			case <-tick:
+			case <-m.closeCh:
+				return
+			}
+		}
+	}()
+	return nil
+}
+
+// Close .
+func (m *Manager) Close() error {
+	close(m.closeCh)
+	return nil
+}
+
+func (m *Manager) rollupTiers(now time.Time) {
+	// continue from the persisted watermarks, which may be moved by the previous holder of the lock.
+	next, err := m.loadWatermarks()
+	if err != nil {
+		m.log.Errorf("fail to load rollup watermarks: %s", err)
+		return
+	}
+	begin := now.Add(-m.cfg.Delay - m.cfg.Backfill)
+	limit := now.Add(-m.cfg.Delay).UnixNano()
+	for i, t := range m.tiers {
+		if next[i] <= 0 {
+			next[i] = begin.Truncate(t.resolution).UnixNano()
+		} else if t.ttl > 0 && next[i] < now.Add(-t.ttl).UnixNano() {
+			next[i] = now.Add(-t.ttl).Truncate(t.resolution).UnixNano()
+		}
+		res := int64(t.resolution)
+		if i > 0 {
+			// only the windows covered by the previous tier are complete.
+			limit = next[i-1]
+		}
+		for next[i]+res <= limit {
+			if err := m.rollupWindow(i, next[i]); err != nil {
+				m.log.Errorf("fail to rollup tier %s at %s: %s", t.name, time.Unix(0, next[i]).Format(time.RFC3339), err)
+				break
+			}
+			if err := m.saveWatermark(i, next[i]+res); err != nil {
+				m.log.Errorf("fail to save watermark of tier %s: %s", t.name, err)
+				break
+			}
+			next[i] += res
+		}
+	}
+}
+
+func (m *Manager) rollupWindow(i int, start int64) error {
+	for metric, r := range m.rules {
+		if err := m.rollupMetric(i, metric, r, start); err != nil {
+			return fmt.Errorf("metric %s: %s", metric, err)
+		}
+	}
+	return nil
+}
+
+func (m *Manager) rollupMetric(i int, metric string, r *rule, start int64) error {
+	t := m.tiers[i]
+	end := start + int64(t.resolution)
+	var indices []string
+	var source func(field, agg string) string
+	if i == 0 {
+		indices = m.rawIndices([]string{metric}, nil, start/int64(time.Millisecond), end/int64(time.Millisecond))
+		source = func(field, agg string) string { return tsql.FieldsKey + field }
+	} else {
+		indices = []string{m.readIndex(m.tiers[i-1], metric)}
+		source = func(field, agg string) string { return tsql.FieldsKey + tsql.RollupField(field, agg) }
+	}
+	if len(indices) <= 0 {
+		return nil
+	}
+	query := elastic.NewBoolQuery().
+		Filter(elastic.NewTermQuery(tsql.NameKey, metric)).
+		Filter(elastic.NewRangeQuery(tsql.TimestampKey).Gte(start).Lt(end))
+
+	sources := []elastic.CompositeAggregationValuesSource{
+		elastic.NewCompositeAggregationTermsValuesSource(tsql.NameKey).Field(tsql.NameKey),
+	}
+	for _, tag := range r.tags {
+		sources = append(sources, elastic.NewCompositeAggregationTermsValuesSource(tag).Field(tsql.TagsKey+tag).MissingBucket(true))
+	}
+	composite := elastic.NewCompositeAggregation().Sources(sources...).Size(m.cfg.PageSize)
+	var lasts []string
+	for _, field := range r.fields {
+		for _, agg := range r.aggregates {
+			name := tsql.RollupField(field, agg)
+			switch agg {
+			case tsql.RollupMin:
+				composite.SubAggregation(name, elastic.NewMinAggregation().Field(source(field, agg)))
+			case tsql.RollupMax:
+				composite.SubAggregation(name, elastic.NewMaxAggregation().Field(source(field, agg)))
+			case tsql.RollupSum:
+				composite.SubAggregation(name, elastic.NewSumAggregation().Field(source(field, agg)))
+			case tsql.RollupCount:
+				if i == 0 {
+					composite.SubAggregation(name, elastic.NewValueCountAggregation().Field(source(field, agg)))
+				} else {
+					composite.SubAggregation(name, elastic.NewSumAggregation().Field(source(field, agg)))
+				}
+			case tsql.RollupLast:
+				lasts = append(lasts, source(field, agg))
+			}
+		}
+	}
+	if len(lasts) > 0 {
+		composite.SubAggregation(tsql.RollupLast, elastic.NewTopHitsAggregation().Sort(tsql.TimestampKey, false).Size(1).
+			FetchSourceContext(elastic.NewFetchSourceContext(true).Include(lasts...)))
+	}
+
+	var after map[string]interface{}
+	for {
+		if after != nil {
+			composite.AggregateAfter(after)
+		}
+		searchSource := elastic.NewSearchSource().Query(query).Size(0).Aggregation("rollup", composite)
+		ctx, cancel := context.WithTimeout(context.Background(), m.cfg.RequestTimeout)
+		resp, err := m.client.Search(indices...).IgnoreUnavailable(true).AllowNoIndices(true).
+			SearchSource(searchSource).Do(ctx)
+		cancel()
+		if err != nil {
+			return err
+		}
+		result, ok := resp.Aggregations.Composite("rollup")
+		if !ok || len(result.Buckets) <= 0 {
+			return nil
+		}
+		var docs []*metrics.Metric
+		for _, bucket := range result.Buckets {
+			if doc := m.newDocument(metric, start, r, bucket, i == 0); doc != nil {
+				docs = append(docs, doc)
+			}
+		}
+		if err := m.writeDocuments(t, docs); err != nil {
+			return err
+		}
+		if len(result.Buckets) < m.cfg.PageSize || result.AfterKey == nil {
+			return nil
+		}
+		after = result.AfterKey
+	}
+}
+
+func (m *Manager) newDocument(metric string, start int64, r *rule, bucket *elastic.AggregationBucketCompositeItem, raw bool) *metrics.Metric {
+	doc := &metrics.Metric{
+		Name:      metric,
+		Timestamp: start,
+		Tags:      make(map[string]string),
+		Fields:    make(map[string]interface{}),
+	}
+	for _, tag := range r.tags {
+		if v, ok := bucket.Key[tag]; ok && v != nil {
+			doc.Tags[tag] = fmt.Sprint(v)
+		}
+	}
+	var last map[string]interface{}
+	if hits, ok := bucket.Aggregations.TopHits(tsql.RollupLast); ok && hits.Hits != nil && len(hits.Hits.Hits) > 0 && hits.Hits.Hits[0].Source != nil {
+		var source struct {
+			Fields map[string]interface{} `json:"fields"`
+		}
+		if err := json.Unmarshal(*hits.Hits.Hits[0].Source, &source); err == nil {
+			last = source.Fields
+		}
+	}
+	for _, field := range r.fields {
+		for _, agg := range r.aggregates {
+			name := tsql.RollupField(field, agg)
+			if agg == tsql.RollupLast {
+				key := name
+				if raw {
+					key = field
+				}
+				if v, ok := last[key]; ok && v != nil {
+					doc.Fields[name] = v
+				}
+				continue
+			}
+			var value *elastic.AggregationValueMetric
+			switch agg {
+			case tsql.RollupMin:
+				value, _ = bucket.Aggregations.Min(name)
+			case tsql.RollupMax:
+				value, _ = bucket.Aggregations.Max(name)
+			case tsql.RollupSum:
+				value, _ = bucket.Aggregations.Sum(name)
+			case tsql.RollupCount:
+				if raw {
+					value, _ = bucket.Aggregations.ValueCount(name)
+				} else {
+					value, _ = bucket.Aggregations.Sum(name)
+				}
+			}
+			if value == nil || value.Value == nil || (agg == tsql.RollupCount && *value.Value == 0) {
+				continue
+			}
+			doc.Fields[name] = *value.Value
+		}
+	}
+	if len(doc.Fields) <= 0 {
+		return nil
+	}
+	return doc
+}
+
+func (m *Manager) writeDocuments(t *tier, docs []*metrics.Metric) error {
+	if len(docs) <= 0 {
+		return nil
+	}
+	// the documents must be searchable before the watermark is moved.
+	bulk := m.client.Bulk().Refresh("wait_for")
+	for _, doc := range docs {
+		bulk.Add(elastic.NewBulkIndexRequest().
+			Index(m.writeIndex(t, doc.Name, doc.Timestamp)).
+			Type(m.cfg.IndexType).
+			Id(documentID(t, doc)).
+			Doc(doc))
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), m.cfg.RequestTimeout)
+	defer cancel()
+	resp, err := bulk.Do(ctx)
+	if err != nil {
+		return err
+	}
+	if resp.Errors {
+		for _, item := range resp.Failed() {
+			if item.Error != nil {
+				return fmt.Errorf("fail to write rollup documents: %s", item.Error.Reason)
+			}
+		}
+	}
+	return nil
+}
+
+// documentID makes the rollup idempotent, the same window of a series always has the same id.
+func documentID(t *tier, doc *metrics.Metric) string {
+	keys := make([]string, 0, len(doc.Tags))
+	for k := range doc.Tags {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "%s/%s/%d", t.name, doc.Name, doc.Timestamp)
+	for _, k := range keys {
+		fmt.Fprintf(&sb, "/%s=%s", k, doc.Tags[k])
+	}
+	return md5x.SumString(sb.String()).String()
+}
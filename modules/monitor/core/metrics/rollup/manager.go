@@ -0,0 +1,194 @@
+// Copyright (c) 2021 Terminus, Inc.
+//
+// This program is free software: you can use, redistribute, and/or modify
+// it under the terms of the GNU Affero General Public License, version 3
+// or later ("AGPL"), as published by the Free Software Foundation.
+//
+// This program is distributed in the hope that it will be useful, but WITHOUT
+// ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+// FITNESS FOR A PARTICULAR PURPOSE.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package rollup
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/olivere/elastic"
+
+	"github.com/erda-project/erda-infra/base/logs"
+	tsql "github.com/erda-project/erda/modules/monitor/core/metrics/metricq/es-tsql"
+)
+
+// ReadIndicesFunc returns the raw indices of metrics in the time range in milliseconds.
+type ReadIndicesFunc func(metrics []string, namespaces []string, start, end int64) []string
+
+type tier struct {
+	name       string
+	resolution time.Duration
+	ttl        time.Duration
+}
+
+type rule struct {
+	tags       []string
+	fields     []string
+	aggregates []string
+}
+
+// Manager rolls up the raw metrics into tiers, and plans the tiers for queries.
+type Manager struct {
+	cfg        *config
+	client     *elastic.Client
+	rawIndices ReadIndicesFunc
+	tiers      []*tier
+	rules      map[string]*rule
+	plans      map[string][]*tsql.RollupTier
+	watermarks []int64 // by tier, accessed atomically
+	log        logs.Logger
+	closeCh    chan struct{}
+}
+
+var _ tsql.RollupPlanner = (*Manager)(nil)
+
+// NewManager .
+func NewManager(cfg *config, client *elastic.Client, rawIndices ReadIndicesFunc, log logs.Logger) (*Manager, error) {
+	m := &Manager{
+		cfg:        cfg,
+		client:     client,
+		rawIndices: rawIndices,
+		rules:      make(map[string]*rule),
+		plans:      make(map[string][]*tsql.RollupTier),
+		log:        log,
+		closeCh:    make(chan struct{}),
+	}
+	if err := m.initTiers(); err != nil {
+		return nil, err
+	}
+	if err := m.initRules(); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (m *Manager) initTiers() error {
+	sort.Slice(m.cfg.Tiers, func(i, j int) bool {
+		return m.cfg.Tiers[i].Resolution < m.cfg.Tiers[j].Resolution
+	})
+	for i, tc := range m.cfg.Tiers {
+		if tc.Resolution < time.Minute {
+			return fmt.Errorf("too small resolution %v, at least 1m", tc.Resolution)
+		}
+		if i > 0 {
+			// every window of a tier is rolled up from the whole windows of the previous tier.
+			prev := m.cfg.Tiers[i-1].Resolution
+			if tc.Resolution == prev || tc.Resolution%prev != 0 {
+				return fmt.Errorf("resolution %v is not a multiple of %v", tc.Resolution, prev)
+			}
+		}
+		m.tiers = append(m.tiers, &tier{
+			name:       tsql.FormatResolution(tc.Resolution),
+			resolution: tc.Resolution,
+			ttl:        tc.TTL,
+		})
+	}
+	m.watermarks = make([]int64, len(m.tiers))
+	return nil
+}
+
+func (m *Manager) initRules() error {
+	for _, rc := range m.cfg.Rules {
+		if len(rc.Metrics) <= 0 || len(rc.Fields) <= 0 {
+			return fmt.Errorf("metrics and fields of rollup rule are required")
+		}
+		r := &rule{
+			tags:       rc.Tags,
+			fields:     rc.Fields,
+			aggregates: rc.Aggregates,
+		}
+		if len(r.aggregates) <= 0 {
+			r.aggregates = tsql.RollupAggregates
+		}
+		for _, agg := range r.aggregates {
+			if !contains(tsql.RollupAggregates, agg) {
+				return fmt.Errorf("invalid rollup aggregate %q", agg)
+			}
+		}
+		for _, metric := range rc.Metrics {
+			if _, ok := m.rules[metric]; ok {
+				return fmt.Errorf("duplicate rollup rule for metric %q", metric)
+			}
+			m.rules[metric] = r
+			m.plans[metric] = m.newPlans(metric, r)
+		}
+	}
+	return nil
+}
+
+func (m *Manager) newPlans(metric string, r *rule) (list []*tsql.RollupTier) {
+	tags, fields, aggs := toSet(r.tags), toSet(r.fields), toSet(r.aggregates)
+	for _, t := range m.tiers {
+		list = append(list, &tsql.RollupTier{
+			Name:       t.name,
+			Resolution: t.resolution,
+			Retention:  t.ttl,
+			Indices:    []string{m.readIndex(t, metric)},
+			Tags:       tags,
+			Fields:     fields,
+			Aggregates: aggs,
+		})
+	}
+	return list
+}
+
+// RollupTiers .
+func (m *Manager) RollupTiers(metric string) []*tsql.RollupTier {
+	plans := m.plans[metric]
+	list := make([]*tsql.RollupTier, 0, len(plans))
+	for i, plan := range plans {
+		tier := *plan
+		tier.Watermark = atomic.LoadInt64(&m.watermarks[i])
+		list = append(list, &tier)
+	}
+	return list
+}
+
+// spot_rollup_<tier>-<metric>-<day timestamp>
+func (m *Manager) writeIndex(t *tier, metric string, timestamp int64) string {
+	day := time.Unix(0, timestamp).UTC().Truncate(24 * time.Hour)
+	return fmt.Sprintf("%s-%d", m.indexPrefix(t, metric), day.UnixNano()/int64(time.Millisecond))
+}
+
+func (m *Manager) readIndex(t *tier, metric string) string {
+	return m.indexPrefix(t, metric) + "-*"
+}
+
+func (m *Manager) indexPrefix(t *tier, metric string) string {
+	return m.cfg.IndexPrefix + "_" + t.name + "-" + normalizeIndexPart(metric)
+}
+
+func normalizeIndexPart(s string) string {
+	return strings.Replace(strings.ToLower(s), "-", "_", -1)
+}
+
+func toSet(list []string) map[string]bool {
+	set := make(map[string]bool)
+	for _, item := range list {
+		set[item] = true
+	}
+	return set
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
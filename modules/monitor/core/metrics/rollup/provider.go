@@ -0,0 +1,105 @@
+// Copyright (c) 2021 Terminus, Inc.
+//
+// This program is free software: you can use, redistribute, and/or modify
+// it under the terms of the GNU Affero General Public License, version 3
+// or later ("AGPL"), as published by the Free Software Foundation.
+//
+// This program is distributed in the hope that it will be useful, but WITHOUT
+// ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+// FITNESS FOR A PARTICULAR PURPOSE.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package rollup
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/erda-project/erda-infra/base/logs"
+	"github.com/erda-project/erda-infra/base/servicehub"
+	"github.com/erda-project/erda-infra/providers/elasticsearch"
+	mutex "github.com/erda-project/erda-infra/providers/etcd-mutex"
+	indexmanager "github.com/erda-project/erda/modules/monitor/core/metrics/index"
+)
+
+type tierConfig struct {
+	Resolution time.Duration `file:"resolution"`
+	TTL        time.Duration `file:"ttl"`
+}
+
+type ruleConfig struct {
+	Metrics    []string `file:"metrics"`
+	Tags       []string `file:"tags"`
+	Fields     []string `file:"fields"`
+	Aggregates []string `file:"aggregates"`
+}
+
+type config struct {
+	RequestTimeout time.Duration `file:"request_timeout" default:"60s"`
+	IndexType      string        `file:"index_type" default:"spot"`
+	IndexPrefix    string        `file:"index_prefix" default:"spot_rollup"`
+
+	CheckInterval time.Duration `file:"check_interval" default:"30s"`
+	Delay         time.Duration `file:"delay" default:"2m"`    // wait for the late points before rolling up a window
+	Backfill      time.Duration `file:"backfill" default:"1h"` // the windows to roll up when a tier has no watermark
+	PageSize      int           `file:"page_size" default:"1000"`
+	CleanInterval time.Duration `file:"clean_interval" default:"1h"`
+
+	Tiers []*tierConfig `file:"tiers"`
+	Rules []*ruleConfig `file:"rules"`
+
+	LockKey string `file:"lock_key" default:"metric-rollup-task-lock"`
+}
+
+type provider struct {
+	C   *config
+	L   logs.Logger
+	m   *Manager
+	ctx servicehub.Context
+}
+
+func (p *provider) Init(ctx servicehub.Context) error {
+	p.ctx = ctx
+	es := ctx.Service("elasticsearch").(elasticsearch.Interface)
+	index := ctx.Service("metrics-index-manager").(indexmanager.Index)
+	m, err := NewManager(p.C, es.Client(), index.GetReadIndices, p.L)
+	if err != nil {
+		return fmt.Errorf("fail to create rollup manager: %s", err)
+	}
+	p.m = m
+	return nil
+}
+
+func (p *provider) Start() error {
+	mu, _ := p.ctx.Service("etcd-mutex").(mutex.Interface)
+	var lock mutex.Mutex
+	if mu != nil {
+		lk, err := mu.New(context.Background(), p.C.LockKey)
+		if err != nil {
+			p.L.Error(err)
+		}
+		lock = lk
+	}
+	return p.m.Start(lock)
+}
+
+func (p *provider) Close() error { return p.m.Close() }
+
+func (p *provider) Provide(ctx servicehub.DependencyContext, options ...interface{}) interface{} {
+	return p.m
+}
+
+func init() {
+	servicehub.Register("metrics-rollup", &servicehub.Spec{
+		Services:     []string{"metrics-rollup"},
+		Dependencies: []string{"elasticsearch", "metrics-index-manager"},
+		Description:  "roll up metrics into coarser resolutions",
+		ConfigFunc:   func() interface{} { return &config{} },
+		Creator: func() servicehub.Provider {
+			return &provider{}
+		},
+	})
+}
@@ -0,0 +1,101 @@
+// Copyright (c) 2021 Terminus, Inc.
+//
+// This program is free software: you can use, redistribute, and/or modify
+// it under the terms of the GNU Affero General Public License, version 3
+// or later ("AGPL"), as published by the Free Software Foundation.
+//
+// This program is distributed in the hope that it will be useful, but WITHOUT
+// ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+// FITNESS FOR A PARTICULAR PURPOSE.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package rollup
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/olivere/elastic"
+)
+
+// The watermark of a tier is the start of the next window to roll up, all windows before it are done.
+// It is persisted in the watermark index, so the rollup continues from it after restart or lock handoff,
+// and the queries of all instances read the tier only before it.
+type watermarkDocument struct {
+	Tier      string `json:"tier"`
+	Watermark int64  `json:"watermark"`
+	Timestamp int64  `json:"timestamp"`
+}
+
+// spot_rollup-watermark, it is not matched by the pattern of tier indices to clean.
+func (m *Manager) watermarkIndex() string {
+	return m.cfg.IndexPrefix + "-watermark"
+}
+
+// loadWatermarks returns the persisted watermarks of tiers, 0 means the tier has never been rolled up.
+func (m *Manager) loadWatermarks() ([]int64, error) {
+	mget := m.client.Mget()
+	for _, t := range m.tiers {
+		mget.Add(elastic.NewMultiGetItem().Index(m.watermarkIndex()).Type(m.cfg.IndexType).Id(t.name))
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), m.cfg.RequestTimeout)
+	resp, err := mget.Do(ctx)
+	cancel()
+	if err != nil {
+		return nil, err
+	}
+	list := make([]int64, len(m.tiers))
+	for i, doc := range resp.Docs {
+		if i >= len(list) || doc == nil || !doc.Found || doc.Source == nil {
+			continue
+		}
+		var w watermarkDocument
+		if err := json.Unmarshal(*doc.Source, &w); err != nil {
+			return nil, fmt.Errorf("invalid watermark of tier %s: %s", m.tiers[i].name, err)
+		}
+		list[i] = w.Watermark
+	}
+	return list, nil
+}
+
+func (m *Manager) saveWatermark(i int, watermark int64) error {
+	t := m.tiers[i]
+	ctx, cancel := context.WithTimeout(context.Background(), m.cfg.RequestTimeout)
+	defer cancel()
+	_, err := m.client.Index().Index(m.watermarkIndex()).Type(m.cfg.IndexType).Id(t.name).
+		BodyJson(&watermarkDocument{
+			Tier:      t.name,
+			Watermark: watermark,
+			Timestamp: time.Now().UnixNano(),
+		}).Do(ctx)
+	if err != nil {
+		return err
+	}
+	atomic.StoreInt64(&m.watermarks[i], watermark)
+	return nil
+}
+
+// syncWatermarks refreshes the watermarks for queries, which are moved by the instance holding the lock.
+func (m *Manager) syncWatermarks() {
+	tick := time.Tick(m.cfg.CheckInterval)
+	for {
+		list, err := m.loadWatermarks()
+		if err != nil {
+			m.log.Errorf("fail to load rollup watermarks: %s", err)
+		} else {
+			for i, w := range list {
+				atomic.StoreInt64(&m.watermarks[i], w)
+			}
+		}
+		select {
+		case <-tick:
+		case <-m.closeCh:
+			return
+		}
+	}
+}
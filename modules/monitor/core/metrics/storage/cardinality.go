@@ -0,0 +1,202 @@
+// Copyright (c) 2021 Terminus, Inc.
+//
+// This program is free software: you can use, redistribute, and/or modify
+// it under the terms of the GNU Affero General Public License, version 3
+// or later ("AGPL"), as published by the Free Software Foundation.
+//
+// This program is distributed in the hope that it will be useful, but WITHOUT
+// ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+// FITNESS FOR A PARTICULAR PURPOSE.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/olivere/elastic"
+
+	"github.com/erda-project/erda/modules/monitor/common"
+	"github.com/erda-project/erda/modules/monitor/core/metrics"
+	"github.com/erda-project/erda/modules/monitor/core/metrics/storage/cardinality"
+)
+
+const cardinalitySnapshotType = "spot"
+
+// checkCardinality returns false if the metric should be dropped.
+func (p *provider) checkCardinality(m *Metric) bool {
+	if len(m.Name) <= 0 || m.Name[0:1] == MetricInternalPrefix {
+		return true
+	}
+	// the documents with id are updated in place, they are not series.
+	if _, ok := m.Tags[MetricTagMetricID]; ok || m.Tags[MetricTagTTL] == MetricTagTTLFixed {
+		return true
+	}
+	r := p.guard.Check(m.Name, metricScope(m.Tags), m.Tags)
+	switch r.Action {
+	case cardinality.ActionDrop:
+		return false
+	case cardinality.ActionStrip:
+		p.L.Debugf("strip tags %v of metric %s over the %s cardinality limit", r.Stripped, m.Name, r.Type)
+	}
+	return true
+}
+
+// metricScope returns the scope of the metric, such as "org/erda", it's empty if the metric has no scope.
+func metricScope(tags map[string]string) string {
+	if scope, ok := tags[MetricInternalPrefix+MetricTagScope]; ok {
+		return scope + "/" + tags[MetricInternalPrefix+MetricTagScopeID]
+	}
+	if org, ok := tags[MetricTagOrgName]; ok {
+		return "org/" + org
+	}
+	return ""
+}
+
+// reportCardinality writes the violations of cardinality limits as metrics periodically.
+func (p *provider) reportCardinality() {
+	tick := time.NewTicker(p.C.Cardinality.ReportInterval)
+	defer tick.Stop()
+	for {
+		select {
+		case <-tick.C:
+		case <-p.closeCh:
+			return
+		}
+		if err := p.saveCardinalitySnapshots(time.Now()); err != nil {
+			p.L.Errorf("fail to save cardinality snapshots: %s", err)
+		}
+		now := time.Now().UnixNano()
+		for _, o := range p.guard.Violations() {
+			p.L.Warnf("%s %s is over the cardinality limit %d, cardinality: %d, dropped: %d, stripped: %d",
+				o.Type, o.Name, o.Limit, o.Cardinality, o.Dropped, o.Stripped)
+			m := &Metric{
+				Metric: &metrics.Metric{
+					Name:      MetricCardinality,
+					Timestamp: now,
+					Tags: map[string]string{
+						"type":   o.Type,
+						"name":   o.Name,
+						"action": p.C.Cardinality.Action,
+					},
+					Fields: map[string]interface{}{
+						"cardinality": o.Cardinality,
+						"accepted":    o.Accepted,
+						"limit":       o.Limit,
+						"dropped":     o.Dropped,
+						"stripped":    o.Stripped,
+					},
+				},
+			}
+			if o.Type == cardinality.TypeMetric {
+				m.Tags[MetricTagMetricName] = o.Name
+			}
+			processTimestampDateFormat(m)
+			if err := p.write(m); err != nil {
+				p.L.Errorf("fail to write cardinality metric: %s", err)
+			}
+		}
+	}
+}
+
+// saveCardinalitySnapshots shares the top trackers of the instance, and removes the expired snapshots.
+func (p *provider) saveCardinalitySnapshots(now time.Time) error {
+	cfg := &p.C.Cardinality
+	client := p.index.Client()
+	if err := p.createCardinalitySnapshotIndex(); err != nil {
+		return err
+	}
+	bulk := client.Bulk()
+	for _, typ := range []string{cardinality.TypeMetric, cardinality.TypeScope} {
+		for _, s := range p.guard.Snapshots(common.InstanceID(), typ, cfg.SnapshotSize, now.UnixNano()) {
+			bulk.Add(elastic.NewBulkIndexRequest().Index(cfg.SnapshotIndex).Type(cardinalitySnapshotType).
+				Id(s.Instance + "/" + s.Type + "/" + s.Name).Doc(s))
+		}
+	}
+	if bulk.NumberOfActions() > 0 {
+		ctx, cancel := context.WithTimeout(context.Background(), p.index.RequestTimeout())
+		_, err := bulk.Do(ctx)
+		cancel()
+		if err != nil {
+			return err
+		}
+	}
+	// the snapshots of the stopped instances and the trackers out of top are not updated any more.
+	ctx, cancel := context.WithTimeout(context.Background(), p.index.RequestTimeout())
+	defer cancel()
+	_, err := client.DeleteByQuery(cfg.SnapshotIndex).Type(cardinalitySnapshotType).
+		Query(elastic.NewRangeQuery("timestamp").Lt(now.Add(-cfg.Window - cfg.ReportInterval).UnixNano())).
+		Do(ctx)
+	return err
+}
+
+// createCardinalitySnapshotIndex creates the index of snapshots, the registers of sketches are not indexed.
+func (p *provider) createCardinalitySnapshotIndex() error {
+	if p.snapshotIndexCreated {
+		return nil
+	}
+	client := p.index.Client()
+	ctx, cancel := context.WithTimeout(context.Background(), p.index.RequestTimeout())
+	defer cancel()
+	exists, err := client.IndexExists(p.C.Cardinality.SnapshotIndex).Do(ctx)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		mapping := map[string]interface{}{
+			"mappings": map[string]interface{}{
+				cardinalitySnapshotType: map[string]interface{}{
+					"dynamic": false,
+					"properties": map[string]interface{}{
+						"instance":  map[string]interface{}{"type": "keyword"},
+						"type":      map[string]interface{}{"type": "keyword"},
+						"name":      map[string]interface{}{"type": "keyword"},
+						"timestamp": map[string]interface{}{"type": "long"},
+					},
+				},
+			},
+		}
+		if _, err := client.CreateIndex(p.C.Cardinality.SnapshotIndex).BodyJson(mapping).Do(ctx); err != nil {
+			// it may be created by another instance at the same time.
+			if exists, _ := client.IndexExists(p.C.Cardinality.SnapshotIndex).Do(ctx); !exists {
+				return err
+			}
+		}
+	}
+	p.snapshotIndexCreated = true
+	return nil
+}
+
+// loadCardinalitySnapshots returns the latest snapshots of all instances.
+func (p *provider) loadCardinalitySnapshots(typ string, now time.Time) ([]*cardinality.Snapshot, error) {
+	cfg := &p.C.Cardinality
+	query := elastic.NewBoolQuery().
+		Filter(elastic.NewTermQuery("type", typ)).
+		Filter(elastic.NewRangeQuery("timestamp").Gte(now.Add(-2 * cfg.ReportInterval).UnixNano()))
+	ctx, cancel := context.WithTimeout(context.Background(), p.index.RequestTimeout())
+	defer cancel()
+	resp, err := p.index.Client().Search(cfg.SnapshotIndex).IgnoreUnavailable(true).AllowNoIndices(true).
+		Query(query).Size(10000).Do(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var list []*cardinality.Snapshot
+	if resp.Hits == nil {
+		return list, nil
+	}
+	for _, hit := range resp.Hits.Hits {
+		if hit.Source == nil {
+			continue
+		}
+		var s cardinality.Snapshot
+		if err := json.Unmarshal(*hit.Source, &s); err != nil {
+			continue
+		}
+		list = append(list, &s)
+	}
+	return list, nil
+}
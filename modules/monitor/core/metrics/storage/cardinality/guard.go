@@ -0,0 +1,428 @@
+// Copyright (c) 2021 Terminus, Inc.
+//
+// This program is free software: you can use, redistribute, and/or modify
+// it under the terms of the GNU Affero General Public License, version 3
+// or later ("AGPL"), as published by the Free Software Foundation.
+//
+// This program is distributed in the hope that it will be useful, but WITHOUT
+// ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+// FITNESS FOR A PARTICULAR PURPOSE.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package cardinality
+
+import (
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// actions for the series over the limit
+const (
+	ActionDrop  = "drop"
+	ActionStrip = "strip"
+)
+
+// offender types
+const (
+	TypeMetric = "metric"
+	TypeScope  = "scope"
+	TypeTotal  = "total" // the series tracked by the instance
+)
+
+// numShards is the number of shards of trackers, the trackers are sharded by name to reduce the contention of locks.
+const numShards = 32
+
+// Config .
+// All the limits are per instance, every instance only tracks the series it consumes,
+// so the total series of a metric or a scope may be up to the limit multiplied by the number of instances.
+type Config struct {
+	Enable             bool          `file:"enable"`
+	Precision          uint8         `file:"precision" default:"12"`
+	Window             time.Duration `file:"window" default:"1h"`
+	Action             string        `file:"action" default:"drop"`
+	MaxSeriesPerMetric int64         `file:"max_series_per_metric" default:"100000"`
+	MaxSeriesPerScope  int64         `file:"max_series_per_scope" default:"500000"`
+	MaxTagValues       int64         `file:"max_tag_values" default:"10000"`
+	Metrics            []struct {
+		Name      string `file:"name"`
+		MaxSeries int64  `file:"max_series"`
+	} `file:"metrics"`
+	// the exact sets of the accepted series take about 40 bytes per series,
+	// the series over it are dropped to bound the memory of an instance, 0 means no limit.
+	MaxTrackedSeries int64         `file:"max_tracked_series" default:"2000000"`
+	ReportInterval   time.Duration `file:"report_interval" default:"1m"`
+	// the snapshots of the top trackers are shared by instances, to list the offenders of all instances.
+	SnapshotIndex string `file:"snapshot_index" default:"spot_cardinality"`
+	SnapshotSize  int    `file:"snapshot_size" default:"100"`
+}
+
+// Result is the result of checking a series.
+type Result struct {
+	Action   string // empty means the series is accepted as it is
+	Type     string // the limit which the series is over
+	Stripped []string
+}
+
+// Offender .
+type Offender struct {
+	Type        string           `json:"type"`
+	Name        string           `json:"name"`
+	Cardinality uint64           `json:"cardinality"`
+	Accepted    uint64           `json:"accepted"`
+	Limit       int64            `json:"limit"`
+	Dropped     int64            `json:"dropped"`
+	Stripped    int64            `json:"stripped"`
+	Tags        map[string]int64 `json:"tags,omitempty"`
+}
+
+type tracker struct {
+	typ      string
+	name     string
+	limit    int64
+	observed *Sketch // all series
+	// the series accepted under the limit, the set is exact to reject all unknown series when it is full,
+	// and its size is bounded by the limit.
+	accepted map[uint64]struct{}
+	// the series accepted after stripped, they are limited separately, or the limit will be full of them.
+	strippedSeries map[uint64]struct{}
+	tags           map[string]*Sketch
+	dropped        int64
+	stripped       int64
+	// violations since the last report
+	reportDropped  int64
+	reportStripped int64
+}
+
+// shard is a part of trackers with its own lock.
+type shard struct {
+	lock     sync.Mutex
+	trackers map[string]*tracker
+	window   int64
+	tracked  int64 // the series in the exact sets of trackers
+}
+
+// Guard tracks the cardinality of series per metric and per scope, and limits the series over the limits.
+type Guard struct {
+	cfg     *Config
+	metrics [numShards]*shard
+	scopes  [numShards]*shard
+	limits  map[string]int64
+	start   time.Time
+	tracked int64 // the series in the exact sets of all shards, accessed atomically
+}
+
+// NewGuard .
+func NewGuard(cfg *Config) *Guard {
+	g := &Guard{
+		cfg:    cfg,
+		limits: make(map[string]int64),
+		start:  time.Now(),
+	}
+	for i := 0; i < numShards; i++ {
+		g.metrics[i] = &shard{trackers: make(map[string]*tracker)}
+		g.scopes[i] = &shard{trackers: make(map[string]*tracker)}
+	}
+	for _, item := range cfg.Metrics {
+		g.limits[item.Name] = item.MaxSeries
+	}
+	return g
+}
+
+func (g *Guard) shards(typ string) *[numShards]*shard {
+	if typ == TypeScope {
+		return &g.scopes
+	}
+	return &g.metrics
+}
+
+func (g *Guard) shard(typ, name string) *shard {
+	return g.shards(typ)[Hash(name)%numShards]
+}
+
+// Check tracks the series, and strips the tags if the series is over the limits and the action is strip.
+func (g *Guard) Check(name, scope string, tags map[string]string) *Result {
+	keys := seriesKeys(tags)
+	series := Hash(append([]string{name}, keys...)...)
+
+	window := g.window(time.Now())
+
+	// the shard of metric is always locked before the shard of scope, so there is no deadlock.
+	ms := g.shard(TypeMetric, name)
+	ms.lock.Lock()
+	defer ms.lock.Unlock()
+	g.rotate(ms, window)
+	mt := g.tracker(ms, TypeMetric, name)
+	mt.observed.Insert(series)
+	for _, key := range keys {
+		k := key[:strings.IndexByte(key, '=')]
+		ts, ok := mt.tags[k]
+		if !ok {
+			ts = NewSketch(g.cfg.Precision)
+			mt.tags[k] = ts
+		}
+		ts.Insert(Hash(key))
+	}
+	var ss *shard
+	var st *tracker
+	if len(scope) > 0 {
+		ss = g.shard(TypeScope, scope)
+		ss.lock.Lock()
+		defer ss.lock.Unlock()
+		g.rotate(ss, window)
+		st = g.tracker(ss, TypeScope, scope)
+		st.observed.Insert(series)
+	}
+
+	if over := overLimit(mt, st, series); over != nil {
+		return g.limit(over, g.shard(over.typ, over.name), mt, name, tags)
+	}
+	if (unknown(mt, series) || unknown(st, series)) && g.totalFull() {
+		// it is not stripped, the stripped series take the space too.
+		mt.dropped++
+		mt.reportDropped++
+		return &Result{Action: ActionDrop, Type: TypeTotal}
+	}
+	g.accept(ms, mt, series)
+	if st != nil {
+		g.accept(ss, st, series)
+	}
+	return &Result{}
+}
+
+// limit handles the series over the limit of tracker over.
+func (g *Guard) limit(over *tracker, s *shard, mt *tracker, name string, tags map[string]string) *Result {
+	if g.cfg.Action == ActionStrip {
+		if stripped := g.strip(mt, tags); len(stripped) > 0 {
+			// the stripped series are limited too, in case they still explode.
+			series := Hash(append([]string{name}, seriesKeys(tags)...)...)
+			_, ok := over.strippedSeries[series]
+			if ok || (int64(len(over.strippedSeries)) < over.limit && !g.totalFull()) {
+				if !ok {
+					over.strippedSeries[series] = struct{}{}
+					g.track(s, 1)
+				}
+				over.stripped++
+				over.reportStripped++
+				return &Result{Action: ActionStrip, Type: over.typ, Stripped: stripped}
+			}
+		}
+	}
+	over.dropped++
+	over.reportDropped++
+	return &Result{Action: ActionDrop, Type: over.typ}
+}
+
+// overLimit returns the tracker which is full if the series is unknown, st may be nil.
+func overLimit(mt, st *tracker, series uint64) *tracker {
+	if mt.full(series) {
+		return mt
+	}
+	if st != nil && st.full(series) {
+		return st
+	}
+	return nil
+}
+
+func (t *tracker) full(series uint64) bool {
+	if t.limit <= 0 {
+		return false
+	}
+	if _, ok := t.accepted[series]; ok {
+		return false
+	}
+	return int64(len(t.accepted)) >= t.limit
+}
+
+// unknown returns whether the series should be added to the exact set of the tracker, t may be nil.
+func unknown(t *tracker, series uint64) bool {
+	if t == nil || t.limit <= 0 {
+		return false
+	}
+	_, ok := t.accepted[series]
+	return !ok
+}
+
+func (g *Guard) accept(s *shard, t *tracker, series uint64) {
+	if unknown(t, series) {
+		t.accepted[series] = struct{}{}
+		g.track(s, 1)
+	}
+}
+
+// totalFull returns whether the series tracked by the instance reach the limit.
+func (g *Guard) totalFull() bool {
+	return g.cfg.MaxTrackedSeries > 0 && atomic.LoadInt64(&g.tracked) >= g.cfg.MaxTrackedSeries
+}
+
+// track counts the series added to the exact sets of the shard s, the lock of s must be held.
+func (g *Guard) track(s *shard, n int64) {
+	s.tracked += n
+	atomic.AddInt64(&g.tracked, n)
+}
+
+// strip removes the tags with too many values, the tag with the most values is removed if there are no such tags.
+func (g *Guard) strip(t *tracker, tags map[string]string) []string {
+	var stripped []string
+	var top string
+	var max uint64
+	for k := range tags {
+		ts, ok := t.tags[k]
+		if !ok {
+			continue
+		}
+		n := ts.Estimate()
+		if g.cfg.MaxTagValues > 0 && int64(n) > g.cfg.MaxTagValues {
+			stripped = append(stripped, k)
+		}
+		if n > max {
+			top, max = k, n
+		}
+	}
+	if len(stripped) <= 0 && len(top) > 0 {
+		stripped = append(stripped, top)
+	}
+	for _, k := range stripped {
+		delete(tags, k)
+	}
+	sort.Strings(stripped)
+	return stripped
+}
+
+func (g *Guard) tracker(s *shard, typ, name string) *tracker {
+	t, ok := s.trackers[name]
+	if !ok {
+		limit := g.cfg.MaxSeriesPerScope
+		if typ == TypeMetric {
+			limit = g.cfg.MaxSeriesPerMetric
+			if l, ok := g.limits[name]; ok {
+				limit = l
+			}
+		}
+		t = &tracker{
+			typ:            typ,
+			name:           name,
+			limit:          limit,
+			observed:       NewSketch(g.cfg.Precision),
+			accepted:       make(map[uint64]struct{}),
+			strippedSeries: make(map[uint64]struct{}),
+			tags:           make(map[string]*Sketch),
+		}
+		s.trackers[name] = t
+	}
+	return t
+}
+
+// window returns the sequence of the window at now, the cardinalities are counted in every window.
+func (g *Guard) window(now time.Time) int64 {
+	if g.cfg.Window <= 0 {
+		return 0
+	}
+	return int64(now.Sub(g.start) / g.cfg.Window)
+}
+
+// rotate resets the trackers of shard s if the window changes, the lock of s must be held.
+func (g *Guard) rotate(s *shard, window int64) {
+	if s.window == window {
+		return
+	}
+	s.window = window
+	s.trackers = make(map[string]*tracker)
+	g.track(s, -s.tracked)
+}
+
+// each calls fn with the trackers of the type in the current window, shard by shard.
+func (g *Guard) each(typ string, fn func(t *tracker)) {
+	window := g.window(time.Now())
+	for _, s := range g.shards(typ) {
+		s.lock.Lock()
+		g.rotate(s, window)
+		for _, t := range s.trackers {
+			fn(t)
+		}
+		s.lock.Unlock()
+	}
+}
+
+// Top returns the n offenders with the highest cardinality of the type.
+func (g *Guard) Top(typ string, n int) []*Offender {
+	var list []*Offender
+	g.each(typ, func(t *tracker) {
+		o := t.offender()
+		o.Dropped, o.Stripped = t.dropped, t.stripped
+		list = append(list, o)
+	})
+	sortOffenders(list)
+	if n > 0 && len(list) > n {
+		list = list[:n]
+	}
+	return list
+}
+
+func sortOffenders(list []*Offender) {
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].Cardinality == list[j].Cardinality {
+			return list[i].Name < list[j].Name
+		}
+		return list[i].Cardinality > list[j].Cardinality
+	})
+}
+
+// Violations returns the offenders which have dropped or stripped series since the last call.
+func (g *Guard) Violations() []*Offender {
+	var list []*Offender
+	for _, typ := range []string{TypeMetric, TypeScope} {
+		g.each(typ, func(t *tracker) {
+			if t.reportDropped <= 0 && t.reportStripped <= 0 {
+				return
+			}
+			o := t.offender()
+			o.Dropped, o.Stripped = t.reportDropped, t.reportStripped
+			t.reportDropped, t.reportStripped = 0, 0
+			list = append(list, o)
+		})
+	}
+	return list
+}
+
+func (t *tracker) offender() *Offender {
+	o := &Offender{
+		Type:        t.typ,
+		Name:        t.name,
+		Cardinality: t.observed.Estimate(),
+		Accepted:    t.acceptedCount(),
+		Limit:       t.limit,
+	}
+	if len(t.tags) > 0 {
+		o.Tags = make(map[string]int64, len(t.tags))
+		for k, s := range t.tags {
+			o.Tags[k] = int64(s.Estimate())
+		}
+	}
+	return o
+}
+
+// acceptedCount returns the number of accepted series, all series are accepted if there is no limit.
+func (t *tracker) acceptedCount() uint64 {
+	if t.limit <= 0 {
+		return t.observed.Estimate()
+	}
+	return uint64(len(t.accepted))
+}
+
+// seriesKeys returns the sorted tag pairs of a series, the internal tags with prefix "_" are ignored.
+func seriesKeys(tags map[string]string) []string {
+	keys := make([]string, 0, len(tags))
+	for k, v := range tags {
+		if strings.HasPrefix(k, "_") {
+			continue
+		}
+		keys = append(keys, k+"="+v)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,186 @@
+// Copyright (c) 2021 Terminus, Inc.
+//
+// This program is free software: you can use, redistribute, and/or modify
+// it under the terms of the GNU Affero General Public License, version 3
+// or later ("AGPL"), as published by the Free Software Foundation.
+//
+// This program is distributed in the hope that it will be useful, but WITHOUT
+// ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+// FITNESS FOR A PARTICULAR PURPOSE.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package cardinality
+
+import (
+	"math"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestSketch(t *testing.T) {
+	for _, n := range []int{10, 1000, 100000} {
+		s := NewSketch(12)
+		for i := 0; i < n; i++ {
+			s.Insert(Hash("id", strconv.Itoa(i)))
+			s.Insert(Hash("id", strconv.Itoa(i)))
+		}
+		got := float64(s.Estimate())
+		if math.Abs(got-float64(n))/float64(n) > 0.05 {
+			t.Errorf("Estimate() = %v, want about %d", got, n)
+		}
+	}
+}
+
+func TestGuard(t *testing.T) {
+	cfg := &Config{
+		Precision:          12,
+		Window:             time.Hour,
+		Action:             ActionDrop,
+		MaxSeriesPerMetric: 100,
+		MaxSeriesPerScope:  1000,
+		MaxTagValues:       50,
+	}
+	g := NewGuard(cfg)
+	var dropped int
+	for i := 0; i < 300; i++ {
+		r := g.Check("http", "org/a", map[string]string{"service": "s", "request_id": strconv.Itoa(i)})
+		if r.Action == ActionDrop {
+			dropped++
+		}
+	}
+	if dropped != 200 {
+		t.Errorf("dropped %d series, want 200", dropped)
+	}
+	// the accepted series are still accepted.
+	if r := g.Check("http", "org/a", map[string]string{"service": "s", "request_id": "1"}); r.Action != "" {
+		t.Errorf("Check() action = %q for accepted series", r.Action)
+	}
+	top := g.Top(TypeMetric, 1)
+	if len(top) != 1 || top[0].Name != "http" || top[0].Dropped != int64(dropped) || top[0].Tags["request_id"] < 250 {
+		t.Errorf("Top() = %+v", top[0])
+	}
+	if v := g.Violations(); len(v) != 1 || v[0].Dropped != int64(dropped) {
+		t.Errorf("Violations() = %v", v)
+	}
+	if v := g.Violations(); len(v) != 0 {
+		t.Errorf("Violations() = %v, want reset", v)
+	}
+
+	cfg.Action = ActionStrip
+	g = NewGuard(cfg)
+	for i := 0; i < 300; i++ {
+		tags := map[string]string{"service": "s" + strconv.Itoa(i%3), "request_id": strconv.Itoa(i)}
+		r := g.Check("http", "", tags)
+		if r.Action == ActionStrip {
+			if len(r.Stripped) != 1 || r.Stripped[0] != "request_id" || len(tags) != 1 {
+				t.Fatalf("Check() = %+v, tags %v", r, tags)
+			}
+		} else if r.Action != "" {
+			t.Fatalf("Check() action = %q, want strip", r.Action)
+		}
+	}
+}
+
+func TestGuard_ManySeries(t *testing.T) {
+	cfg := &Config{
+		Precision:          12,
+		Window:             time.Hour,
+		Action:             ActionDrop,
+		MaxSeriesPerMetric: 1000,
+		MaxSeriesPerScope:  1500,
+	}
+	g := NewGuard(cfg)
+	// far more series than the limit, the sketch can not tell whether a series is new.
+	for round := 0; round < 2; round++ {
+		var accepted int
+		for i := 0; i < 100000; i++ {
+			if r := g.Check("http", "org/a", map[string]string{"request_id": strconv.Itoa(i)}); r.Action == "" {
+				accepted++
+			}
+		}
+		if accepted != 1000 {
+			t.Errorf("round %d accepted %d series, want 1000", round, accepted)
+		}
+	}
+	// the scope is full after another metric adds 500 series.
+	var accepted int
+	for i := 0; i < 10000; i++ {
+		if r := g.Check("rpc", "org/a", map[string]string{"request_id": strconv.Itoa(i)}); r.Action == "" {
+			accepted++
+		} else if r.Type != TypeScope {
+			t.Fatalf("Check() over %s limit, want %s", r.Type, TypeScope)
+		}
+	}
+	if accepted != 500 {
+		t.Errorf("accepted %d series in scope, want 500", accepted)
+	}
+	if top := g.Top(TypeMetric, 1); top[0].Accepted != 1000 || top[0].Dropped != 2*99000 {
+		t.Errorf("Top() = %+v", top[0])
+	}
+}
+
+func TestGuard_MaxTrackedSeries(t *testing.T) {
+	cfg := &Config{
+		Precision:          12,
+		Window:             time.Hour,
+		Action:             ActionStrip,
+		MaxSeriesPerMetric: 1000,
+		MaxSeriesPerScope:  1000,
+		MaxTrackedSeries:   3000,
+	}
+	g := NewGuard(cfg)
+	// every series takes two entries, one of the metric and one of the scope.
+	var accepted int
+	for i := 0; i < 10; i++ {
+		for j := 0; j < 1000; j++ {
+			r := g.Check("m"+strconv.Itoa(i), "org/"+strconv.Itoa(i), map[string]string{"id": strconv.Itoa(j)})
+			if r.Action == "" {
+				accepted++
+			} else if r.Action != ActionDrop || r.Type != TypeTotal {
+				t.Fatalf("Check() = %+v, want drop over %s", r, TypeTotal)
+			}
+		}
+	}
+	if accepted != 1500 || g.tracked != 3000 {
+		t.Errorf("accepted %d series and tracked %d, want 1500 and 3000", accepted, g.tracked)
+	}
+	// the tracked series are released in a new window.
+	g.start = g.start.Add(-cfg.Window)
+	g.Top(TypeMetric, 0)
+	g.Top(TypeScope, 0)
+	if g.tracked != 0 {
+		t.Errorf("tracked %d series after rotated, want 0", g.tracked)
+	}
+}
+
+func TestMergeSnapshots(t *testing.T) {
+	cfg := &Config{Precision: 12, Window: time.Hour, Action: ActionDrop, MaxSeriesPerMetric: 100000}
+	a, b := NewGuard(cfg), NewGuard(cfg)
+	for i := 0; i < 20000; i++ {
+		tags := map[string]string{"request_id": strconv.Itoa(i)}
+		if i < 15000 {
+			a.Check("http", "", tags)
+		}
+		if i >= 5000 {
+			b.Check("http", "", tags)
+		}
+		b.Check("rpc", "", map[string]string{"id": strconv.Itoa(i % 10)})
+	}
+	snapshots := append(a.Snapshots("a", TypeMetric, 10, 1), b.Snapshots("b", TypeMetric, 10, 1)...)
+	list := MergeSnapshots(snapshots, 1)
+	if len(list) != 1 || list[0].Name != "http" {
+		t.Fatalf("MergeSnapshots() = %v", list)
+	}
+	if got := float64(list[0].Cardinality); math.Abs(got-20000)/20000 > 0.05 {
+		t.Errorf("merged cardinality = %v, want about 20000", got)
+	}
+	if got := float64(list[0].Tags["request_id"]); math.Abs(got-20000)/20000 > 0.05 {
+		t.Errorf("merged tag values = %v, want about 20000", got)
+	}
+	if list[0].Accepted != 30000 {
+		t.Errorf("merged accepted = %d, want 30000", list[0].Accepted)
+	}
+}
@@ -0,0 +1,145 @@
+// Copyright (c) 2021 Terminus, Inc.
+//
+// This program is free software: you can use, redistribute, and/or modify
+// it under the terms of the GNU Affero General Public License, version 3
+// or later ("AGPL"), as published by the Free Software Foundation.
+//
+// This program is distributed in the hope that it will be useful, but WITHOUT
+// ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+// FITNESS FOR A PARTICULAR PURPOSE.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package cardinality
+
+import (
+	"hash/fnv"
+	"math"
+	"math/bits"
+)
+
+// Sketch is a HyperLogLog sketch which estimates the number of distinct items.
+type Sketch struct {
+	p    uint8
+	regs []uint8
+}
+
+// NewSketch creates a sketch with 2^precision registers, the standard error is about 1.04/sqrt(2^precision).
+func NewSketch(precision uint8) *Sketch {
+	if precision < 4 {
+		precision = 4
+	} else if precision > 16 {
+		precision = 16
+	}
+	return &Sketch{
+		p:    precision,
+		regs: make([]uint8, 1<<precision),
+	}
+}
+
+func (s *Sketch) position(h uint64) (uint64, uint8) {
+	idx := h >> (64 - s.p)
+	w := h<<s.p | 1<<(s.p-1)
+	return idx, uint8(bits.LeadingZeros64(w)) + 1
+}
+
+// Insert adds the hash of an item, and returns whether the sketch is changed.
+// A changed sketch means the item is new for sure, an unchanged one means the item has probably been seen.
+func (s *Sketch) Insert(h uint64) bool {
+	idx, rank := s.position(h)
+	if rank > s.regs[idx] {
+		s.regs[idx] = rank
+		return true
+	}
+	return false
+}
+
+// Estimate returns the estimated number of distinct items.
+func (s *Sketch) Estimate() uint64 {
+	m := float64(len(s.regs))
+	var sum float64
+	var zeros int
+	for _, r := range s.regs {
+		sum += 1 / float64(uint64(1)<<r)
+		if r == 0 {
+			zeros++
+		}
+	}
+	e := alpha(len(s.regs)) * m * m / sum
+	if e <= 2.5*m && zeros > 0 {
+		// linear counting for small cardinalities
+		e = m * math.Log(m/float64(zeros))
+	}
+	return uint64(e + 0.5)
+}
+
+// Merge merges the items of other into the sketch, the sketches must have the same precision.
+func (s *Sketch) Merge(other *Sketch) bool {
+	if len(other.regs) != len(s.regs) {
+		return false
+	}
+	for i, r := range other.regs {
+		if r > s.regs[i] {
+			s.regs[i] = r
+		}
+	}
+	return true
+}
+
+// Registers returns a copy of the registers, to restore the sketch by SketchFromRegisters.
+func (s *Sketch) Registers() []byte {
+	regs := make([]byte, len(s.regs))
+	copy(regs, s.regs)
+	return regs
+}
+
+// SketchFromRegisters restores a sketch from the registers, it returns nil if the registers are invalid.
+func SketchFromRegisters(regs []byte) *Sketch {
+	p := bits.TrailingZeros(uint(len(regs)))
+	if p < 4 || p > 16 || len(regs) != 1<<p {
+		return nil
+	}
+	s := &Sketch{p: uint8(p), regs: make([]uint8, len(regs))}
+	copy(s.regs, regs)
+	return s
+}
+
+// Reset clears the sketch.
+func (s *Sketch) Reset() {
+	for i := range s.regs {
+		s.regs[i] = 0
+	}
+}
+
+func alpha(m int) float64 {
+	switch m {
+	case 16:
+		return 0.673
+	case 32:
+		return 0.697
+	case 64:
+		return 0.709
+	}
+	return 0.7213 / (1 + 1.079/float64(m))
+}
+
+// Hash returns the 64 bits hash of the strings.
+func Hash(items ...string) uint64 {
+	h := fnv.New64a()
+	for _, item := range items {
+		h.Write([]byte(item))
+		h.Write([]byte{0})
+	}
+	return mix(h.Sum64())
+}
+
+// mix spreads the bits of fnv, the finalizer of splitmix64.
+func mix(x uint64) uint64 {
+	x ^= x >> 30
+	x *= 0xbf58476d1ce4e5b9
+	x ^= x >> 27
+	x *= 0x94d049bb133111eb
+	x ^= x >> 31
+	return x
+}
@@ -0,0 +1,151 @@
+// Copyright (c) 2021 Terminus, Inc.
+//
+// This program is free software: you can use, redistribute, and/or modify
+// it under the terms of the GNU Affero General Public License, version 3
+// or later ("AGPL"), as published by the Free Software Foundation.
+//
+// This program is distributed in the hope that it will be useful, but WITHOUT
+// ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+// FITNESS FOR A PARTICULAR PURPOSE.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package cardinality
+
+import (
+	"sort"
+)
+
+// Snapshot is the state of a tracker in an instance.
+// Every instance only sees the series it consumes, so the snapshots of all instances are merged to get the total cardinality.
+type Snapshot struct {
+	Instance  string            `json:"instance"`
+	Type      string            `json:"type"`
+	Name      string            `json:"name"`
+	Limit     int64             `json:"limit"`
+	Observed  []byte            `json:"observed"` // the registers of sketch
+	Accepted  uint64            `json:"accepted"`
+	Dropped   int64             `json:"dropped"`
+	Stripped  int64             `json:"stripped"`
+	Tags      map[string][]byte `json:"tags,omitempty"`
+	Timestamp int64             `json:"timestamp"`
+}
+
+// Snapshots returns the snapshots of the n trackers with the highest cardinality of the type.
+func (g *Guard) Snapshots(instance, typ string, n int, timestamp int64) []*Snapshot {
+	type item struct {
+		name        string
+		cardinality uint64
+	}
+	var list []item
+	g.each(typ, func(t *tracker) {
+		list = append(list, item{t.name, t.observed.Estimate()})
+	})
+	sort.Slice(list, func(i, j int) bool { return list[i].cardinality > list[j].cardinality })
+	if n > 0 && len(list) > n {
+		list = list[:n]
+	}
+	top := make(map[string]int, len(list))
+	for i, it := range list {
+		top[it.name] = i
+	}
+	// the registers are copied only for the top trackers.
+	snapshots := make([]*Snapshot, len(list))
+	g.each(typ, func(t *tracker) {
+		i, ok := top[t.name]
+		if !ok {
+			return
+		}
+		s := &Snapshot{
+			Instance:  instance,
+			Type:      t.typ,
+			Name:      t.name,
+			Limit:     t.limit,
+			Observed:  t.observed.Registers(),
+			Accepted:  t.acceptedCount(),
+			Dropped:   t.dropped,
+			Stripped:  t.stripped,
+			Timestamp: timestamp,
+		}
+		if len(t.tags) > 0 {
+			s.Tags = make(map[string][]byte, len(t.tags))
+			for k, ts := range t.tags {
+				s.Tags[k] = ts.Registers()
+			}
+		}
+		snapshots[i] = s
+	})
+	// the trackers may be reset by a new window between the two passes.
+	result := snapshots[:0]
+	for _, s := range snapshots {
+		if s != nil {
+			result = append(result, s)
+		}
+	}
+	return result
+}
+
+// MergeSnapshots merges the snapshots of instances, and returns the n offenders with the highest cardinality.
+// The series accepted by different instances may be the same one, so the accepted is an upper bound.
+func MergeSnapshots(snapshots []*Snapshot, n int) []*Offender {
+	type merged struct {
+		o        *Offender
+		observed *Sketch
+		tags     map[string]*Sketch
+	}
+	index := make(map[string]*merged)
+	var list []*merged
+	for _, s := range snapshots {
+		observed := SketchFromRegisters(s.Observed)
+		if observed == nil {
+			continue
+		}
+		key := s.Type + "/" + s.Name
+		m, ok := index[key]
+		if !ok {
+			m = &merged{
+				o:        &Offender{Type: s.Type, Name: s.Name},
+				observed: observed,
+				tags:     make(map[string]*Sketch),
+			}
+			index[key] = m
+			list = append(list, m)
+		} else if !m.observed.Merge(observed) {
+			continue
+		}
+		if s.Limit > m.o.Limit {
+			m.o.Limit = s.Limit
+		}
+		m.o.Accepted += s.Accepted
+		m.o.Dropped += s.Dropped
+		m.o.Stripped += s.Stripped
+		for k, regs := range s.Tags {
+			ts := SketchFromRegisters(regs)
+			if ts == nil {
+				continue
+			}
+			if prev, ok := m.tags[k]; ok {
+				prev.Merge(ts)
+			} else {
+				m.tags[k] = ts
+			}
+		}
+	}
+	offenders := make([]*Offender, 0, len(list))
+	for _, m := range list {
+		m.o.Cardinality = m.observed.Estimate()
+		if len(m.tags) > 0 {
+			m.o.Tags = make(map[string]int64, len(m.tags))
+			for k, ts := range m.tags {
+				m.o.Tags[k] = int64(ts.Estimate())
+			}
+		}
+		offenders = append(offenders, m.o)
+	}
+	sortOffenders(offenders)
+	if n > 0 && len(offenders) > n {
+		offenders = offenders[:n]
+	}
+	return offenders
+}
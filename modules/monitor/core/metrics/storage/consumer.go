@@ -46,6 +46,9 @@ func (p *provider) invoke(key []byte, value []byte, topic *string, timestamp tim
 	if m.Tags == nil || m.Tags[MetricTagLifetime] == MetricTagTransient {
 		return nil
 	}
+	if p.guard != nil && !p.checkCardinality(m) {
+		return nil
+	}
 	if p.C.Output.Features.GenerateMeta {
 		if err := p.metaProcessor.add(m); err != nil {
 			// Convert Meta failed, do not block stored original metric.
@@ -62,6 +65,10 @@ func (p *provider) invoke(key []byte, value []byte, topic *string, timestamp tim
 			return p.output.es.Write(doc)
 		}
 	}
+	return p.write(m)
+}
+
+func (p *provider) write(m *Metric) error {
 	var documentIndex, documentID string
 	if ttl, ok := m.Tags[MetricTagTTL]; ok && ttl == MetricTagTTLFixed {
 		documentIndex = p.index.GetWriteFixedIndex(m.Metric)
@@ -109,6 +116,7 @@ func (p *provider) handleCreatingIndexMetric(key []byte, value []byte, topic *st
 const (
 	MetricInternalPrefix = "_"
 	MetricMeta           = "_metric_meta"
+	MetricCardinality    = "_metric_cardinality"
 
 	MetricTagMetricName  = "metric_name"
 	MetricTagScope       = "metric_scope"
@@ -22,15 +22,18 @@ import (
 	"github.com/erda-project/erda-infra/base/servicehub"
 	writer "github.com/erda-project/erda-infra/pkg/parallel-writer"
 	"github.com/erda-project/erda-infra/providers/elasticsearch"
+	"github.com/erda-project/erda-infra/providers/httpserver"
+	"github.com/erda-project/erda-infra/providers/httpserver/interceptors"
 	"github.com/erda-project/erda-infra/providers/kafka"
 	indexmanager "github.com/erda-project/erda/modules/monitor/core/metrics/index"
+	"github.com/erda-project/erda/modules/monitor/core/metrics/storage/cardinality"
 )
 
 type define struct{}
 
 func (d *define) Services() []string { return []string{"metrics-storage"} }
 func (d *define) Dependencies() []string {
-	return []string{"kafka", "elasticsearch", "metrics-index-manager"}
+	return []string{"kafka", "elasticsearch", "metrics-index-manager", "http-server"}
 }
 func (d *define) Summary() string     { return "metrics store" }
 func (d *define) Description() string { return d.Summary() }
@@ -58,6 +61,7 @@ type config struct {
 		} `file:"elasticsearch"`
 		Kafka kafka.ProducerConfig `file:"kafka"`
 	} `file:"output"`
+	Cardinality cardinality.Config `file:"cardinality"`
 }
 
 type provider struct {
@@ -71,6 +75,10 @@ type provider struct {
 	}
 	counter       *prometheus.Counter
 	metaProcessor *metaProcessor
+	guard         *cardinality.Guard
+	closeCh       chan struct{}
+
+	snapshotIndexCreated bool
 }
 
 func (p *provider) Init(ctx servicehub.Context) error {
@@ -99,6 +107,13 @@ func (p *provider) Init(ctx servicehub.Context) error {
 	if p.C.Output.Features.GenerateMeta {
 		p.metaProcessor = createMetaProcess(p.output.es, p.index, p.counter)
 	}
+	if p.C.Cardinality.Enable {
+		p.guard = cardinality.NewGuard(&p.C.Cardinality)
+	}
+	p.closeCh = make(chan struct{})
+
+	routes := ctx.Service("http-server", interceptors.Recover(p.L)).(httpserver.Router)
+	p.initRoutes(routes)
 	return nil
 }
 
@@ -108,10 +123,14 @@ func (p *provider) Start() error {
 	if p.index.EnableRollover() && len(p.C.Inputs.CreatingIndexMetric.Topics) > 0 {
 		p.kafka.NewConsumer(&p.C.Inputs.CreatingIndexMetric, p.handleCreatingIndexMetric)
 	}
+	if p.guard != nil && p.C.Cardinality.ReportInterval > 0 {
+		go p.reportCardinality()
+	}
 	return nil
 }
 
 func (p *provider) Close() error {
+	close(p.closeCh)
 	p.L.Debug("not support close kafka consumer")
 	return nil
 }
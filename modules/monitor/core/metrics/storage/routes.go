@@ -0,0 +1,47 @@
+// Copyright (c) 2021 Terminus, Inc.
+//
+// This program is free software: you can use, redistribute, and/or modify
+// it under the terms of the GNU Affero General Public License, version 3
+// or later ("AGPL"), as published by the Free Software Foundation.
+//
+// This program is distributed in the hope that it will be useful, but WITHOUT
+// ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+// FITNESS FOR A PARTICULAR PURPOSE.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package storage
+
+import (
+	"time"
+
+	"github.com/erda-project/erda-infra/modcom/api"
+	"github.com/erda-project/erda-infra/providers/httpserver"
+	"github.com/erda-project/erda/apistructs"
+	"github.com/erda-project/erda/modules/monitor/common/permission"
+	"github.com/erda-project/erda/modules/monitor/core/metrics/storage/cardinality"
+)
+
+func (p *provider) initRoutes(routes httpserver.Router) {
+	// the offenders of all orgs are only for the administrators of system.
+	routes.GET("/api/metrics-storage/cardinality/offenders", p.listCardinalityOffenders, permission.Intercepter(
+		permission.ScopeSys, permission.FiexdValue("1"),
+		apistructs.OrgResource, permission.ActionCreate,
+	))
+}
+
+func (p *provider) listCardinalityOffenders(params struct {
+	Type  string `query:"type" default:"metric" validate:"oneof=metric scope"`
+	Limit int    `query:"limit" default:"20"`
+}) interface{} {
+	if p.guard == nil {
+		return api.Success([]*cardinality.Offender{})
+	}
+	// every instance only tracks the series it consumes, so the snapshots of all instances are merged.
+	list, err := p.loadCardinalitySnapshots(params.Type, time.Now())
+	if err != nil {
+		return api.Errors.Internal(err)
+	}
+	return api.Success(cardinality.MergeSnapshots(list, params.Limit))
+}
@@ -16,6 +16,7 @@ package engine
 import (
 	"context"
 	"fmt"
+	"io/ioutil"
 
 	"github.com/go-playground/validator"
 
@@ -48,6 +49,11 @@ type config struct {
 	DomainAddr   string `file:"domain_addr" env:"ACTION_DOMAIN_ADDR" validate:"required"`
 	ReportID     string `file:"report_id" env:"ACTION_REPORT_ID" validate:"required"`
 	OrgName      string `file:"org_name" env:"ACTION_ORG_NAME" validate:"required"`
+	// Format is the format of the rendered report attached to emails, one of pdf, png and svg.
+	Format string `file:"format" env:"ACTION_REPORT_FORMAT" default:"pdf" validate:"oneof=pdf png svg"`
+	// FontFile is a TrueType font (.ttf or .ttc) to draw the text of reports. It is optional for pdf reports,
+	// which use the Chinese font provided by pdf viewers without it, and it is required by png reports with Chinese text.
+	FontFile string `file:"font_file" env:"ACTION_REPORT_FONT_FILE"`
 }
 
 type provider struct {
@@ -62,7 +68,17 @@ func (p *provider) Init(ctx servicehub.Context) error {
 	if err != nil {
 		return fmt.Errorf("invalid config: %s", err)
 	}
+	if p.Cfg.Format == "png" && len(p.Cfg.FontFile) <= 0 {
+		return fmt.Errorf("invalid config: font_file is required by png format")
+	}
 	p.report = New(p.Cfg)
+	if len(p.Cfg.FontFile) > 0 {
+		font, err := ioutil.ReadFile(p.Cfg.FontFile)
+		if err != nil {
+			return fmt.Errorf("fail to read font file: %s", err)
+		}
+		p.report.font = font
+	}
 	return nil
 }
 
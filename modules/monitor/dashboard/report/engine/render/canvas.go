@@ -0,0 +1,85 @@
+// Copyright (c) 2021 Terminus, Inc.
+//
+// This program is free software: you can use, redistribute, and/or modify
+// it under the terms of the GNU Affero General Public License, version 3
+// or later ("AGPL"), as published by the Free Software Foundation.
+//
+// This program is distributed in the hope that it will be useful, but WITHOUT
+// ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+// FITNESS FOR A PARTICULAR PURPOSE.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package render
+
+import (
+	"image/color"
+)
+
+// Align is the horizontal alignment of text.
+type Align int
+
+// aligns
+const (
+	AlignLeft Align = iota
+	AlignCenter
+	AlignRight
+)
+
+// Point .
+type Point struct {
+	X, Y float64
+}
+
+// Canvas is the drawing surface of a chart, the origin is the top left corner.
+type Canvas interface {
+	FillRect(x, y, w, h float64, c color.RGBA)
+	Line(x1, y1, x2, y2, width float64, c color.RGBA)
+	Polyline(points []Point, width float64, c color.RGBA)
+	// Text draws s with its vertical middle at y.
+	Text(x, y, size float64, align Align, c color.RGBA, s string)
+	TextWidth(size float64, s string) float64
+}
+
+var (
+	colorBackground = color.RGBA{R: 0xff, G: 0xff, B: 0xff, A: 0xff}
+	colorBorder     = color.RGBA{R: 0xe5, G: 0xe5, B: 0xe5, A: 0xff}
+	colorGrid       = color.RGBA{R: 0xf0, G: 0xf0, B: 0xf0, A: 0xff}
+	colorAxis       = color.RGBA{R: 0xbf, G: 0xbf, B: 0xbf, A: 0xff}
+	colorText       = color.RGBA{R: 0x33, G: 0x33, B: 0x33, A: 0xff}
+	colorSubText    = color.RGBA{R: 0x88, G: 0x88, B: 0x88, A: 0xff}
+	colorHeader     = color.RGBA{R: 0xf5, G: 0xf5, B: 0xf7, A: 0xff}
+	colorStripe     = color.RGBA{R: 0xfa, G: 0xfa, B: 0xfb, A: 0xff}
+
+	palette = []color.RGBA{
+		{R: 0x5b, G: 0x8f, B: 0xf9, A: 0xff},
+		{R: 0x5a, G: 0xd8, B: 0xa6, A: 0xff},
+		{R: 0xf6, G: 0xbd, B: 0x16, A: 0xff},
+		{R: 0xe8, G: 0x68, B: 0x4a, A: 0xff},
+		{R: 0x6d, G: 0xc8, B: 0xec, A: 0xff},
+		{R: 0x92, G: 0x70, B: 0xca, A: 0xff},
+		{R: 0xff, G: 0x9d, B: 0x4d, A: 0xff},
+		{R: 0x26, G: 0x9a, B: 0x99, A: 0xff},
+	}
+)
+
+// fitText truncates s with "..." to make it no wider than width.
+func fitText(c Canvas, size, width float64, s string) string {
+	if c.TextWidth(size, s) <= width {
+		return s
+	}
+	runes := []rune(s)
+	for len(runes) > 0 {
+		runes = runes[:len(runes)-1]
+		if t := string(runes) + "..."; c.TextWidth(size, t) <= width {
+			return t
+		}
+	}
+	return ""
+}
+
+// isWide returns whether r is drawn in full width, such as CJK characters.
+func isWide(r rune) bool {
+	return r >= 0x1100
+}
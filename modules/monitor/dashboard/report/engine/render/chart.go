@@ -0,0 +1,244 @@
+// Copyright (c) 2021 Terminus, Inc.
+//
+// This program is free software: you can use, redistribute, and/or modify
+// it under the terms of the GNU Affero General Public License, version 3
+// or later ("AGPL"), as published by the Free Software Foundation.
+//
+// This program is distributed in the hope that it will be useful, but WITHOUT
+// ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+// FITNESS FOR A PARTICULAR PURPOSE.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+// Package render draws the chartv2 outputs of dashboard views to PNG, SVG and PDF without a browser.
+package render
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// chart types
+const (
+	TypeLine  = "line"
+	TypeBar   = "bar"
+	TypeCard  = "card"
+	TypeTable = "table"
+)
+
+// Chart is the normalized data of a dashboard view.
+type Chart struct {
+	Title string
+	Type  string
+	// XLabels and Series are used by line and bar charts, a null value in Series is NaN.
+	XLabels []string
+	Series  []*Series
+	// Cards are used by card charts.
+	Cards []*Card
+	// Columns and Rows are used by tables.
+	Columns []string
+	Rows    [][]string
+}
+
+// Series .
+type Series struct {
+	Name   string
+	Values []float64
+}
+
+// Card .
+type Card struct {
+	Name  string
+	Value string
+}
+
+// Parse converts the chartv2 data of a view into a Chart, chartType is the chartType of the view, such as chart:line, chart:bar, card, table.
+func Parse(chartType, title string, data []byte) (*Chart, error) {
+	var v map[string]interface{}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return nil, fmt.Errorf("invalid chartv2 data: %s", err)
+	}
+	ch := &Chart{Title: title}
+	if ch.Title == "" {
+		ch.Title, _ = v["title"].(string)
+	}
+	metricData, _ := v["metricData"].([]interface{})
+	switch {
+	case chartType == "card":
+		ch.Type = TypeCard
+		for _, item := range metricData {
+			m, _ := item.(map[string]interface{})
+			ch.Cards = append(ch.Cards, &Card{
+				Name:  formatValue(m["name"]),
+				Value: strings.TrimSpace(formatValue(m["value"]) + " " + unitOf(m)),
+			})
+		}
+	case chartType == "table":
+		ch.Type = TypeTable
+		parseTable(ch, v["cols"], metricData)
+	case v["metricData"] != nil:
+		// lists and pies, such as [{"title": "a", "value": 1}], are drawn as bar charts.
+		ch.Type = TypeBar
+		s := &Series{}
+		for _, item := range metricData {
+			m, _ := item.(map[string]interface{})
+			ch.XLabels = append(ch.XLabels, formatValue(m["title"]))
+			s.Values = append(s.Values, toFloat(m["value"]))
+		}
+		ch.Series = []*Series{s}
+	default:
+		ch.Type = TypeLine
+		if chartType == "chart:bar" {
+			ch.Type = TypeBar
+		}
+		if xdata, ok := v["xdata"].([]interface{}); ok {
+			for _, x := range xdata {
+				ch.XLabels = append(ch.XLabels, formatValue(x))
+			}
+		} else if times, ok := v["times"].([]interface{}); ok {
+			for _, t := range times {
+				ch.XLabels = append(ch.XLabels, formatTime(toFloat(t)))
+			}
+		}
+		switch data := v["data"].(type) {
+		case map[string]interface{}:
+			ch.Series = append(ch.Series, parseSeries(data)...)
+		case []interface{}:
+			for _, item := range data {
+				if m, ok := item.(map[string]interface{}); ok {
+					ch.Series = append(ch.Series, parseSeries(m)...)
+				}
+			}
+		}
+	}
+	return ch, nil
+}
+
+func parseTable(ch *Chart, cols interface{}, rows []interface{}) {
+	var keys []string
+	if list, ok := cols.([]interface{}); ok {
+		for _, item := range list {
+			col, _ := item.(map[string]interface{})
+			key := formatValue(col["dataIndex"])
+			title := formatValue(col["title"])
+			if unit := unitOf(col); unit != "" {
+				title += " (" + unit + ")"
+			}
+			keys = append(keys, key)
+			ch.Columns = append(ch.Columns, title)
+		}
+	} else if len(rows) > 0 {
+		first, _ := rows[0].(map[string]interface{})
+		for key := range first {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		ch.Columns = keys
+	}
+	for _, item := range rows {
+		m, _ := item.(map[string]interface{})
+		row := make([]string, len(keys))
+		for i, key := range keys {
+			row[i] = formatValue(m[key])
+		}
+		ch.Rows = append(ch.Rows, row)
+	}
+}
+
+func parseSeries(data map[string]interface{}) []*Series {
+	keys := make([]string, 0, len(data))
+	for key := range data {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	var list []*Series
+	for _, key := range keys {
+		col, ok := data[key].(map[string]interface{})
+		if !ok {
+			continue
+		}
+		name := formatValue(col["name"])
+		if name == "" || name == "-" {
+			name = key
+		}
+		if tag := col["tag"]; tag != nil {
+			name = formatValue(tag) + " " + name
+		}
+		s := &Series{Name: name}
+		switch values := col["data"].(type) {
+		case []interface{}:
+			for _, val := range values {
+				s.Values = append(s.Values, toFloat(val))
+			}
+		default:
+			s.Values = append(s.Values, toFloat(values))
+		}
+		list = append(list, s)
+	}
+	return list
+}
+
+func unitOf(m map[string]interface{}) string {
+	if unit, ok := m["unit"].(string); ok {
+		return unit
+	}
+	return ""
+}
+
+func toFloat(v interface{}) float64 {
+	switch val := v.(type) {
+	case float64:
+		return val
+	case string:
+		if f, err := strconv.ParseFloat(val, 64); err == nil {
+			return f
+		}
+	}
+	return math.NaN()
+}
+
+func formatValue(v interface{}) string {
+	switch val := v.(type) {
+	case nil:
+		return "-"
+	case string:
+		return val
+	case float64:
+		return formatNumber(val)
+	}
+	return fmt.Sprint(v)
+}
+
+func formatNumber(f float64) string {
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return "-"
+	}
+	return strconv.FormatFloat(math.Round(f*100)/100, 'f', -1, 64)
+}
+
+// formatAxis formats the value of an axis tick in short form.
+func formatAxis(f float64) string {
+	abs := math.Abs(f)
+	switch {
+	case abs >= 1e9:
+		return formatNumber(f/1e9) + "G"
+	case abs >= 1e6:
+		return formatNumber(f/1e6) + "M"
+	case abs >= 1e4:
+		return formatNumber(f/1e3) + "K"
+	}
+	return formatNumber(f)
+}
+
+func formatTime(ms float64) string {
+	if math.IsNaN(ms) {
+		return "-"
+	}
+	return time.Unix(0, int64(ms)*int64(time.Millisecond)).Format("01-02 15:04")
+}
@@ -0,0 +1,253 @@
+// Copyright (c) 2021 Terminus, Inc.
+//
+// This program is free software: you can use, redistribute, and/or modify
+// it under the terms of the GNU Affero General Public License, version 3
+// or later ("AGPL"), as published by the Free Software Foundation.
+//
+// This program is distributed in the hope that it will be useful, but WITHOUT
+// ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+// FITNESS FOR A PARTICULAR PURPOSE.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package render
+
+import (
+	"image/color"
+	"math"
+)
+
+const (
+	titleHeight  = 32
+	rowHeight    = 24
+	cardHeight   = 110
+	axisHeight   = 300
+	legendHeight = 20
+	padding      = 12
+	fontSize     = 12
+	titleSize    = 14
+	cardSize     = 22
+)
+
+// Height returns the height needed to draw the chart completely.
+func Height(ch *Chart) float64 {
+	switch ch.Type {
+	case TypeCard:
+		return cardHeight
+	case TypeTable:
+		return tableHeight(len(ch.Rows))
+	}
+	return axisHeight
+}
+
+func tableHeight(rows int) float64 {
+	return titleHeight + float64(rows+1)*rowHeight + padding
+}
+
+// Draw draws the chart in the box at (x, y) with size w * h.
+func Draw(c Canvas, ch *Chart, x, y, w, h float64) {
+	if ch.Type == TypeTable {
+		drawTable(c, ch, 0, x, y, w, h)
+		return
+	}
+	drawPanel(c, ch.Title, x, y, w, h)
+	y, h = y+titleHeight, h-titleHeight-padding
+	switch ch.Type {
+	case TypeCard:
+		drawCards(c, ch, x, y, w, h)
+	default:
+		drawAxisChart(c, ch, x, y, w, h)
+	}
+}
+
+func drawPanel(c Canvas, title string, x, y, w, h float64) {
+	c.FillRect(x, y, w, h, colorBorder)
+	c.FillRect(x+1, y+1, w-2, h-2, colorBackground)
+	c.Text(x+padding, y+titleHeight/2, titleSize, AlignLeft, colorText, fitText(c, titleSize, w-2*padding, title))
+}
+
+func drawNoData(c Canvas, x, y, w, h float64) {
+	c.Text(x+w/2, y+h/2, fontSize, AlignCenter, colorSubText, "No Data")
+}
+
+func drawCards(c Canvas, ch *Chart, x, y, w, h float64) {
+	if len(ch.Cards) == 0 {
+		drawNoData(c, x, y, w, h)
+		return
+	}
+	cw := w / float64(len(ch.Cards))
+	for i, card := range ch.Cards {
+		cx := x + cw*(float64(i)+0.5)
+		c.Text(cx, y+h/2-8, cardSize, AlignCenter, colorText, fitText(c, cardSize, cw-padding, card.Value))
+		c.Text(cx, y+h/2+cardSize-4, fontSize, AlignCenter, colorSubText, fitText(c, fontSize, cw-padding, card.Name))
+	}
+}
+
+// drawTable draws the rows from start that fit in the box, and returns the index of the next row to draw.
+func drawTable(c Canvas, ch *Chart, start int, x, y, w, h float64) int {
+	drawPanel(c, ch.Title, x, y, w, h)
+	x, y, w = x+padding, y+titleHeight, w-2*padding
+	if len(ch.Columns) == 0 {
+		drawNoData(c, x, y, w, h-titleHeight)
+		return len(ch.Rows)
+	}
+	cw := w / float64(len(ch.Columns))
+	drawRow := func(y float64, row []string, bg *color.RGBA) {
+		if bg != nil {
+			c.FillRect(x, y, w, rowHeight, *bg)
+		}
+		for i, cell := range row {
+			c.Text(x+cw*float64(i)+6, y+rowHeight/2, fontSize, AlignLeft, colorText, fitText(c, fontSize, cw-12, cell))
+		}
+	}
+	drawRow(y, ch.Columns, &colorHeader)
+	end := start + int((h-titleHeight-padding)/rowHeight) - 1
+	if end > len(ch.Rows) {
+		end = len(ch.Rows)
+	}
+	for i := start; i < end; i++ {
+		var bg *color.RGBA
+		if (i-start)%2 == 1 {
+			bg = &colorStripe
+		}
+		drawRow(y+float64(i-start+1)*rowHeight, ch.Rows[i], bg)
+	}
+	return end
+}
+
+func drawAxisChart(c Canvas, ch *Chart, x, y, w, h float64) {
+	n := len(ch.XLabels)
+	min, max := 0.0, math.Inf(-1)
+	for _, s := range ch.Series {
+		if len(s.Values) > n {
+			n = len(s.Values)
+		}
+		for _, v := range s.Values {
+			if math.IsNaN(v) {
+				continue
+			}
+			min, max = math.Min(min, v), math.Max(max, v)
+		}
+	}
+	if n == 0 || math.IsInf(max, -1) {
+		drawNoData(c, x, y, w, h)
+		return
+	}
+	ticks := niceTicks(min, max, 4)
+	lo, hi := ticks[0], ticks[len(ticks)-1]
+
+	var labelWidth float64
+	for _, t := range ticks {
+		labelWidth = math.Max(labelWidth, c.TextWidth(fontSize, formatAxis(t)))
+	}
+	left, right := x+padding+labelWidth+6, x+w-padding-6
+	top, bottom := y+6, y+h-fontSize-8
+	if len(ch.Series) > 1 || (len(ch.Series) == 1 && ch.Series[0].Name != "") {
+		bottom -= legendHeight
+		drawLegend(c, ch.Series, left, bottom+fontSize+8+legendHeight/2, right-left)
+	}
+	valueY := func(v float64) float64 {
+		return bottom - (v-lo)/(hi-lo)*(bottom-top)
+	}
+	for _, t := range ticks {
+		ty := valueY(t)
+		c.Line(left, ty, right, ty, 1, colorGrid)
+		c.Text(left-6, ty, fontSize, AlignRight, colorSubText, formatAxis(t))
+	}
+	c.Line(left, bottom, right, bottom, 1, colorAxis)
+
+	slot := (right - left) / float64(n)
+	centerX := func(i int) float64 {
+		return left + slot*(float64(i)+0.5)
+	}
+	var xlabelWidth float64
+	for _, label := range ch.XLabels {
+		xlabelWidth = math.Max(xlabelWidth, c.TextWidth(fontSize, label))
+	}
+	step := 1
+	if xlabelWidth > 0 {
+		step = int(math.Ceil(float64(n) * (xlabelWidth + 12) / (right - left)))
+		if step < 1 {
+			step = 1
+		}
+	}
+	for i := 0; i < len(ch.XLabels); i += step {
+		c.Text(centerX(i), bottom+fontSize/2+6, fontSize, AlignCenter, colorSubText, fitText(c, fontSize, slot*float64(step), ch.XLabels[i]))
+	}
+
+	if ch.Type == TypeBar {
+		bw := slot * 0.7 / float64(len(ch.Series))
+		zero := valueY(math.Max(lo, 0))
+		for si, s := range ch.Series {
+			for i, v := range s.Values {
+				if math.IsNaN(v) {
+					continue
+				}
+				bx := centerX(i) - slot*0.35 + bw*float64(si)
+				vy := valueY(v)
+				c.FillRect(bx, math.Min(vy, zero), math.Max(bw-1, 1), math.Abs(zero-vy), palette[si%len(palette)])
+			}
+		}
+		return
+	}
+	for si, s := range ch.Series {
+		clr := palette[si%len(palette)]
+		var points []Point
+		flush := func() {
+			if len(points) == 1 {
+				c.FillRect(points[0].X-2, points[0].Y-2, 4, 4, clr)
+			} else if len(points) > 1 {
+				c.Polyline(points, 2, clr)
+			}
+			points = nil
+		}
+		for i, v := range s.Values {
+			if math.IsNaN(v) {
+				flush()
+				continue
+			}
+			points = append(points, Point{X: centerX(i), Y: valueY(v)})
+		}
+		flush()
+	}
+}
+
+func drawLegend(c Canvas, series []*Series, x, y, w float64) {
+	end := x + w
+	for i, s := range series {
+		if x >= end {
+			break
+		}
+		name := fitText(c, fontSize, 160, s.Name)
+		c.FillRect(x, y-5, 10, 10, palette[i%len(palette)])
+		c.Text(x+14, y, fontSize, AlignLeft, colorText, name)
+		x += 14 + c.TextWidth(fontSize, name) + 16
+	}
+}
+
+// niceTicks returns about n+1 ticks with round steps covering [min, max].
+func niceTicks(min, max float64, n int) []float64 {
+	if max <= min {
+		max = min + 1
+	}
+	raw := (max - min) / float64(n)
+	mag := math.Pow(10, math.Floor(math.Log10(raw)))
+	var step float64
+	switch norm := raw / mag; {
+	case norm < 1.5:
+		step = mag
+	case norm < 3:
+		step = 2 * mag
+	case norm < 7:
+		step = 5 * mag
+	default:
+		step = 10 * mag
+	}
+	lo, hi := math.Floor(min/step)*step, math.Ceil(max/step)*step
+	var ticks []float64
+	for t := lo; t <= hi+step/2; t += step {
+		ticks = append(ticks, math.Round(t/step)*step)
+	}
+	return ticks
+}
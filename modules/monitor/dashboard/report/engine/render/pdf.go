@@ -0,0 +1,310 @@
+// Copyright (c) 2021 Terminus, Inc.
+//
+// This program is free software: you can use, redistribute, and/or modify
+// it under the terms of the GNU Affero General Public License, version 3
+// or later ("AGPL"), as published by the Free Software Foundation.
+//
+// This program is distributed in the hope that it will be useful, but WITHOUT
+// ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+// FITNESS FOR A PARTICULAR PURPOSE.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package render
+
+import (
+	"bytes"
+	"compress/zlib"
+	"errors"
+	"fmt"
+	"image/color"
+	"sort"
+	"strings"
+)
+
+// ErrUnsupportedText is returned when the text can not be drawn by the font.
+var ErrUnsupportedText = errors.New("unsupported characters in text")
+
+// Document is a report of multiple pages, the first page is the cover.
+type Document struct {
+	Title    string
+	Subtitle string
+	Charts   []*Chart
+	// Font is the TrueType font file to be embedded for the text, such as a CJK font.
+	Font []byte
+}
+
+// the page is A4 in points, and charts are laid out in a page of logical width.
+const (
+	pageWidth    = 595.0
+	pageHeight   = 842.0
+	logicalWidth = 800.0
+	pageMargin   = 40.0
+)
+
+// PDF draws the document into a pdf file, long tables are split into multiple pages.
+// Text is drawn by the embedded Font, or the predefined Chinese font STSong-Light provided by pdf viewers without it,
+// ErrUnsupportedText is returned if any character is not in the font.
+func PDF(doc *Document) ([]byte, error) {
+	font := &pdfFont{used: make(map[uint16]bool), unsupported: make(map[rune]bool)}
+	if len(doc.Font) > 0 {
+		ttf, err := parseTrueType(doc.Font)
+		if err != nil {
+			return nil, fmt.Errorf("invalid font: %s", err)
+		}
+		font.ttf = ttf
+	}
+	k := pageWidth / logicalWidth
+	height := pageHeight / k
+	var pages []*pdfCanvas
+	newPage := func() *pdfCanvas {
+		p := &pdfCanvas{k: k, font: font}
+		pages = append(pages, p)
+		return p
+	}
+
+	cover := newPage()
+	cover.FillRect(0, height/3-80, logicalWidth, 6, palette[0])
+	cover.Text(logicalWidth/2, height/3, 32, AlignCenter, colorText, doc.Title)
+	cover.Text(logicalWidth/2, height/3+48, 16, AlignCenter, colorSubText, doc.Subtitle)
+
+	width := logicalWidth - 2*pageMargin
+	bottom := height - pageMargin
+	var page *pdfCanvas
+	var y float64
+	for _, ch := range doc.Charts {
+		if ch.Type != TypeTable {
+			h := Height(ch)
+			if page == nil || y+h > bottom {
+				page, y = newPage(), pageMargin
+			}
+			Draw(page, ch, pageMargin, y, width, h)
+			y += h + gap
+			continue
+		}
+		for start := 0; ; {
+			if page == nil || y+tableHeight(1) > bottom {
+				page, y = newPage(), pageMargin
+			}
+			rows := int((bottom-y-titleHeight-padding)/rowHeight) - 1
+			if remain := len(ch.Rows) - start; rows > remain {
+				rows = remain
+			}
+			h := tableHeight(rows)
+			start = drawTable(page, ch, start, pageMargin, y, width, h)
+			y += h + gap
+			if start >= len(ch.Rows) {
+				break
+			}
+			page = nil
+		}
+	}
+	for i, p := range pages {
+		p.Text(logicalWidth/2, height-pageMargin/2, 10, AlignCenter, colorSubText, fmt.Sprintf("%d / %d", i+1, len(pages)))
+	}
+	if err := font.err(); err != nil {
+		return nil, err
+	}
+	return writePDF(doc.Title, pages, font)
+}
+
+type pdfCanvas struct {
+	k    float64
+	font *pdfFont
+	buf  bytes.Buffer
+}
+
+func (c *pdfCanvas) x(x float64) string { return num(x * c.k) }
+func (c *pdfCanvas) y(y float64) string { return num(pageHeight - y*c.k) }
+
+func (c *pdfCanvas) FillRect(x, y, w, h float64, clr color.RGBA) {
+	fmt.Fprintf(&c.buf, "%s rg %s %s %s %s re f\n", rgb(clr), c.x(x), c.y(y+h), num(w*c.k), num(h*c.k))
+}
+
+func (c *pdfCanvas) Line(x1, y1, x2, y2, width float64, clr color.RGBA) {
+	fmt.Fprintf(&c.buf, "%s w %s RG %s %s m %s %s l S\n", num(width*c.k), rgb(clr), c.x(x1), c.y(y1), c.x(x2), c.y(y2))
+}
+
+func (c *pdfCanvas) Polyline(points []Point, width float64, clr color.RGBA) {
+	fmt.Fprintf(&c.buf, "%s w 1 J 1 j %s RG", num(width*c.k), rgb(clr))
+	for i, p := range points {
+		op := "l"
+		if i == 0 {
+			op = "m"
+		}
+		fmt.Fprintf(&c.buf, " %s %s %s", c.x(p.X), c.y(p.Y), op)
+	}
+	c.buf.WriteString(" S 0 J 0 j\n")
+}
+
+func (c *pdfCanvas) Text(x, y, size float64, align Align, clr color.RGBA, s string) {
+	if s == "" {
+		return
+	}
+	switch align {
+	case AlignCenter:
+		x -= c.TextWidth(size, s) / 2
+	case AlignRight:
+		x -= c.TextWidth(size, s)
+	}
+	fmt.Fprintf(&c.buf, "BT /F1 %s Tf %s rg %s %s Td <%s> Tj ET\n", num(size*c.k), rgb(clr), c.x(x), c.y(y+size*0.35), c.font.encode(s))
+}
+
+func (c *pdfCanvas) TextWidth(size float64, s string) float64 {
+	if c.font.ttf != nil {
+		return c.font.ttf.textWidth(size, s)
+	}
+	return textWidth(size, s)
+}
+
+// pdfFont encodes text by the glyphs of the embedded font, or in UCS-2 of the predefined CJK font without it.
+type pdfFont struct {
+	ttf         *trueType
+	used        map[uint16]bool
+	unsupported map[rune]bool
+}
+
+func (f *pdfFont) encode(s string) string {
+	var sb strings.Builder
+	for _, r := range s {
+		if f.ttf != nil {
+			gid, ok := f.ttf.glyphs[r]
+			if !ok {
+				f.unsupported[r] = true
+			}
+			f.used[gid] = true
+			fmt.Fprintf(&sb, "%04X", gid)
+			continue
+		}
+		if r < ' ' || r > 0xffff {
+			f.unsupported[r] = true
+			r = '?'
+		}
+		fmt.Fprintf(&sb, "%04X", r)
+	}
+	return sb.String()
+}
+
+func (f *pdfFont) err() error {
+	return unsupportedError(f.unsupported)
+}
+
+func unsupportedError(unsupported map[rune]bool) error {
+	if len(unsupported) <= 0 {
+		return nil
+	}
+	runes := make([]rune, 0, len(unsupported))
+	for r := range unsupported {
+		runes = append(runes, r)
+	}
+	sort.Slice(runes, func(i, j int) bool { return runes[i] < runes[j] })
+	return fmt.Errorf("%w: %q", ErrUnsupportedText, string(runes))
+}
+
+// objects returns the objects of the font starting from the object id, the first one is the font.
+func (f *pdfFont) objects(id int) ([]string, error) {
+	if f.ttf == nil {
+		// the proportional and half-width Latin characters of Adobe-GB1 take the width estimated by textWidth.
+		return []string{
+			fmt.Sprintf("<< /Type /Font /Subtype /Type0 /BaseFont /STSong-Light /Encoding /UniGB-UCS2-H /DescendantFonts [%d 0 R] >>", id+1),
+			fmt.Sprintf("<< /Type /Font /Subtype /CIDFontType0 /BaseFont /STSong-Light /CIDSystemInfo << /Registry (Adobe) /Ordering (GB1) /Supplement 2 >> /FontDescriptor %d 0 R /DW 1000 /W [1 95 550 814 939 550] >>", id+2),
+			"<< /Type /FontDescriptor /FontName /STSong-Light /Flags 6 /FontBBox [-25 -254 1000 880] /ItalicAngle 0 /Ascent 880 /Descent -120 /CapHeight 880 /StemV 93 >>",
+		}, nil
+	}
+	gids := make([]int, 0, len(f.used))
+	for gid := range f.used {
+		gids = append(gids, int(gid))
+	}
+	sort.Ints(gids)
+	var widths strings.Builder
+	for _, gid := range gids {
+		fmt.Fprintf(&widths, "%d [%s] ", gid, num(f.ttf.scale(float64(f.ttf.advances[gid]))))
+	}
+	file, err := deflate(f.ttf.data)
+	if err != nil {
+		return nil, err
+	}
+	bbox := f.ttf.bbox
+	return []string{
+		fmt.Sprintf("<< /Type /Font /Subtype /Type0 /BaseFont /ReportFont /Encoding /Identity-H /DescendantFonts [%d 0 R] >>", id+1),
+		fmt.Sprintf("<< /Type /Font /Subtype /CIDFontType2 /BaseFont /ReportFont /CIDSystemInfo << /Registry (Adobe) /Ordering (Identity) /Supplement 0 >> /FontDescriptor %d 0 R /DW 1000 /W [%s] /CIDToGIDMap /Identity >>", id+2, strings.TrimSpace(widths.String())),
+		fmt.Sprintf("<< /Type /FontDescriptor /FontName /ReportFont /Flags 4 /FontBBox [%s %s %s %s] /ItalicAngle 0 /Ascent %s /Descent %s /CapHeight %s /StemV 80 /FontFile2 %d 0 R >>",
+			num(f.ttf.scale(float64(bbox[0]))), num(f.ttf.scale(float64(bbox[1]))), num(f.ttf.scale(float64(bbox[2]))), num(f.ttf.scale(float64(bbox[3]))),
+			num(f.ttf.scale(float64(f.ttf.ascent))), num(f.ttf.scale(float64(f.ttf.descent))), num(f.ttf.scale(float64(f.ttf.ascent))), id+3),
+		fmt.Sprintf("<< /Length %d /Length1 %d /Filter /FlateDecode >>\nstream\n%s\nendstream", len(file), len(f.ttf.data), file),
+	}, nil
+}
+
+func deflate(data []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	w := zlib.NewWriter(&buf)
+	if _, err := w.Write(data); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func rgb(c color.RGBA) string {
+	return num(float64(c.R)/255) + " " + num(float64(c.G)/255) + " " + num(float64(c.B)/255)
+}
+
+// ucs2 encodes s in hex of UCS-2 big endian, the characters out of BMP are replaced by '?'.
+func ucs2(s string) string {
+	var sb strings.Builder
+	for _, r := range s {
+		if r > 0xffff {
+			r = '?'
+		}
+		fmt.Fprintf(&sb, "%04X", r)
+	}
+	return sb.String()
+}
+
+func writePDF(title string, pages []*pdfCanvas, font *pdfFont) ([]byte, error) {
+	// objects 1 to 3 are the catalog, pages and info, followed by the font objects,
+	// and each page takes two objects for itself and its contents.
+	fontID := 4
+	fontObjects, err := font.objects(fontID)
+	if err != nil {
+		return nil, err
+	}
+	objects := append([]string{
+		"",
+		"",
+		fmt.Sprintf("<< /Title <FEFF%s> /Producer (erda report engine) >>", ucs2(title)),
+	}, fontObjects...)
+	kids := make([]string, len(pages))
+	for i, p := range pages {
+		content, err := deflate(p.buf.Bytes())
+		if err != nil {
+			return nil, err
+		}
+		id := len(objects) + 1
+		kids[i] = fmt.Sprintf("%d 0 R", id)
+		objects = append(objects,
+			fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %s %s] /Resources << /Font << /F1 %d 0 R >> >> /Contents %d 0 R >>", num(pageWidth), num(pageHeight), fontID, id+1),
+			fmt.Sprintf("<< /Length %d /Filter /FlateDecode >>\nstream\n%s\nendstream", len(content), content),
+		)
+	}
+	objects[0] = "<< /Type /Catalog /Pages 2 0 R >>"
+	objects[1] = fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(pages))
+
+	var buf bytes.Buffer
+	buf.WriteString("%PDF-1.4\n%\xe2\xe3\xcf\xd3\n")
+	offsets := make([]int, len(objects))
+	for i, obj := range objects {
+		offsets[i] = buf.Len()
+		fmt.Fprintf(&buf, "%d 0 obj\n%s\nendobj\n", i+1, obj)
+	}
+	xref := buf.Len()
+	fmt.Fprintf(&buf, "xref\n0 %d\n0000000000 65535 f \n", len(objects)+1)
+	for _, off := range offsets {
+		fmt.Fprintf(&buf, "%010d 00000 n \n", off)
+	}
+	fmt.Fprintf(&buf, "trailer\n<< /Size %d /Root 1 0 R /Info 3 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(objects)+1, xref)
+	return buf.Bytes(), nil
+}
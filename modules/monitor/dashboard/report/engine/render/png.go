@@ -0,0 +1,184 @@
+// Copyright (c) 2021 Terminus, Inc.
+//
+// This program is free software: you can use, redistribute, and/or modify
+// it under the terms of the GNU Affero General Public License, version 3
+// or later ("AGPL"), as published by the Free Software Foundation.
+//
+// This program is distributed in the hope that it will be useful, but WITHOUT
+// ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+// FITNESS FOR A PARTICULAR PURPOSE.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package render
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"math"
+)
+
+const (
+	margin = 16
+	gap    = 16
+)
+
+// PNG draws the charts from top to bottom into a png image with the width.
+// Text is drawn by the TrueType font, or the builtin bitmap font which supports only ASCII characters without it,
+// ErrUnsupportedText is returned if any character is not in the font.
+func PNG(charts []*Chart, width int, font []byte) ([]byte, error) {
+	c := newPNGCanvas(width, int(math.Ceil(stackHeight(charts))))
+	if len(font) > 0 {
+		ttf, err := parseTrueType(font)
+		if err != nil {
+			return nil, fmt.Errorf("invalid font: %s", err)
+		}
+		c.font = ttf
+	}
+	stack(c, charts, float64(width))
+	if err := unsupportedError(c.unsupported); err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, c.img); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func stackHeight(charts []*Chart) float64 {
+	h := float64(2 * margin)
+	for i, ch := range charts {
+		if i > 0 {
+			h += gap
+		}
+		h += Height(ch)
+	}
+	return h
+}
+
+func stack(c Canvas, charts []*Chart, width float64) {
+	c.FillRect(0, 0, width, stackHeight(charts), colorBackground)
+	y := float64(margin)
+	for _, ch := range charts {
+		h := Height(ch)
+		Draw(c, ch, margin, y, width-2*margin, h)
+		y += h + gap
+	}
+}
+
+type pngCanvas struct {
+	img         *image.RGBA
+	font        *trueType
+	unsupported map[rune]bool
+}
+
+func newPNGCanvas(w, h int) *pngCanvas {
+	return &pngCanvas{img: image.NewRGBA(image.Rect(0, 0, w, h)), unsupported: make(map[rune]bool)}
+}
+
+func (c *pngCanvas) FillRect(x, y, w, h float64, clr color.RGBA) {
+	r := image.Rect(int(math.Round(x)), int(math.Round(y)), int(math.Round(x+w)), int(math.Round(y+h)))
+	draw.Draw(c.img, r, image.NewUniform(clr), image.Point{}, draw.Src)
+}
+
+func (c *pngCanvas) Line(x1, y1, x2, y2, width float64, clr color.RGBA) {
+	steps := math.Max(math.Abs(x2-x1), math.Abs(y2-y1))
+	if steps < 1 {
+		steps = 1
+	}
+	half := width / 2
+	for i := 0.0; i <= steps; i++ {
+		x, y := x1+(x2-x1)*i/steps, y1+(y2-y1)*i/steps
+		c.FillRect(x-half, y-half, width, width, clr)
+	}
+}
+
+func (c *pngCanvas) Polyline(points []Point, width float64, clr color.RGBA) {
+	for i := 1; i < len(points); i++ {
+		c.Line(points[i-1].X, points[i-1].Y, points[i].X, points[i].Y, width, clr)
+	}
+}
+
+func (c *pngCanvas) Text(x, y, size float64, align Align, clr color.RGBA, s string) {
+	scale := glyphScale(size)
+	switch align {
+	case AlignCenter:
+		x -= c.TextWidth(size, s) / 2
+	case AlignRight:
+		x -= c.TextWidth(size, s)
+	}
+	if c.font != nil {
+		c.drawGlyphs(x, y, size, clr, s)
+		return
+	}
+	x, top := math.Round(x), math.Round(y-3.5*scale)
+	for _, r := range s {
+		if r < ' ' || r > '~' {
+			c.unsupported[r] = true
+			w := 5 * scale
+			if isWide(r) {
+				w = 11 * scale
+			}
+			// draw a box for the characters not in the font.
+			c.FillRect(x, top, w, 7*scale, clr)
+			c.FillRect(x+scale, top+scale, w-2*scale, 5*scale, colorBackground)
+			x += w + scale
+			continue
+		}
+		glyph := font5x7[r-' ']
+		for col, bits := range glyph {
+			for row := 0; row < 7; row++ {
+				if bits&(1<<uint(row)) != 0 {
+					c.FillRect(x+float64(col)*scale, top+float64(row)*scale, scale, scale, clr)
+				}
+			}
+		}
+		x += 6 * scale
+	}
+}
+
+// drawGlyphs draws the text by the outlines of glyphs, the text is vertically centered at y as the bitmap font.
+func (c *pngCanvas) drawGlyphs(x, y, size float64, clr color.RGBA, s string) {
+	f := c.font
+	scale := size / f.unitsPerEm
+	baseline := y + float64(f.ascent+f.descent)/2*scale
+	for _, r := range s {
+		gid, ok := f.glyphs[r]
+		if !ok {
+			c.unsupported[r] = true
+			w := textWidth(size, string(r))
+			c.FillRect(x, y-size/2, w, size, clr)
+			c.FillRect(x+1, y-size/2+1, w-2, size-2, colorBackground)
+			x += w
+			continue
+		}
+		fillPath(c.img, flatten(f.contours(gid, 0), x, baseline, scale), clr)
+		x += float64(f.advances[gid]) * scale
+	}
+}
+
+func (c *pngCanvas) TextWidth(size float64, s string) float64 {
+	if c.font != nil {
+		return c.font.textWidth(size, s)
+	}
+	scale := glyphScale(size)
+	var w float64
+	for _, r := range s {
+		if isWide(r) {
+			w += 12 * scale
+		} else {
+			w += 6 * scale
+		}
+	}
+	return w
+}
+
+func glyphScale(size float64) float64 {
+	return math.Max(1, math.Round(size/9))
+}
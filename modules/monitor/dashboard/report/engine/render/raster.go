@@ -0,0 +1,305 @@
+// Copyright (c) 2021 Terminus, Inc.
+//
+// This program is free software: you can use, redistribute, and/or modify
+// it under the terms of the GNU Affero General Public License, version 3
+// or later ("AGPL"), as published by the Free Software Foundation.
+//
+// This program is distributed in the hope that it will be useful, but WITHOUT
+// ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+// FITNESS FOR A PARTICULAR PURPOSE.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package render
+
+import (
+	"image"
+	"image/color"
+	"math"
+	"sort"
+)
+
+// outlinePoint is a point of glyph outline in font units.
+type outlinePoint struct {
+	x, y    float64
+	onCurve bool
+}
+
+// maxComponentDepth limits the nesting of composite glyphs.
+const maxComponentDepth = 8
+
+// glyphData returns the data of the glyph in glyf table, it is empty for the glyphs without outline, such as space.
+func (f *trueType) glyphData(gid uint16) []byte {
+	var start, end int
+	if f.longLoca {
+		start, end = int(be.Uint32(f.loca[4*int(gid):])), int(be.Uint32(f.loca[4*int(gid)+4:]))
+	} else {
+		start, end = 2*int(be.Uint16(f.loca[2*int(gid):])), 2*int(be.Uint16(f.loca[2*int(gid)+2:]))
+	}
+	if start >= end || end > len(f.glyf) {
+		return nil
+	}
+	return f.glyf[start:end]
+}
+
+// contours returns the contours of the glyph, the components of composite glyphs are transformed and merged.
+func (f *trueType) contours(gid uint16, depth int) [][]outlinePoint {
+	data := f.glyphData(gid)
+	if len(data) < 10 || depth > maxComponentDepth {
+		return nil
+	}
+	n := int(int16(be.Uint16(data)))
+	if n >= 0 {
+		return simpleContours(data, n)
+	}
+	var list [][]outlinePoint
+	for pos := 10; pos+4 <= len(data); {
+		flags, component := be.Uint16(data[pos:]), be.Uint16(data[pos+2:])
+		pos += 4
+		var dx, dy float64
+		if flags&0x01 != 0 { // ARG_1_AND_2_ARE_WORDS
+			if pos+4 > len(data) {
+				return list
+			}
+			dx, dy = float64(int16(be.Uint16(data[pos:]))), float64(int16(be.Uint16(data[pos+2:])))
+			pos += 4
+		} else {
+			if pos+2 > len(data) {
+				return list
+			}
+			dx, dy = float64(int8(data[pos])), float64(int8(data[pos+1]))
+			pos += 2
+		}
+		if flags&0x02 == 0 { // the arguments are points to match instead of offsets
+			dx, dy = 0, 0
+		}
+		a, b, c, d := 1.0, 0.0, 0.0, 1.0
+		f2dot14 := func(i int) float64 { return float64(int16(be.Uint16(data[pos+2*i:]))) / 16384 }
+		switch {
+		case flags&0x08 != 0 && pos+2 <= len(data): // WE_HAVE_A_SCALE
+			a, d = f2dot14(0), f2dot14(0)
+			pos += 2
+		case flags&0x40 != 0 && pos+4 <= len(data): // WE_HAVE_AN_X_AND_Y_SCALE
+			a, d = f2dot14(0), f2dot14(1)
+			pos += 4
+		case flags&0x80 != 0 && pos+8 <= len(data): // WE_HAVE_A_TWO_BY_TWO
+			a, b, c, d = f2dot14(0), f2dot14(1), f2dot14(2), f2dot14(3)
+			pos += 8
+		}
+		for _, contour := range f.contours(component, depth+1) {
+			for i, p := range contour {
+				contour[i].x, contour[i].y = a*p.x+c*p.y+dx, b*p.x+d*p.y+dy
+			}
+			list = append(list, contour)
+		}
+		if flags&0x20 == 0 { // MORE_COMPONENTS
+			break
+		}
+	}
+	return list
+}
+
+func simpleContours(data []byte, n int) [][]outlinePoint {
+	pos := 10 + 2*n
+	if pos+2 > len(data) {
+		return nil
+	}
+	ends := make([]int, n)
+	for i := range ends {
+		ends[i] = int(be.Uint16(data[10+2*i:]))
+	}
+	if n <= 0 {
+		return nil
+	}
+	numPoints := ends[n-1] + 1
+	pos += 2 + int(be.Uint16(data[pos:])) // skip instructions
+	flags := make([]byte, 0, numPoints)
+	for len(flags) < numPoints && pos < len(data) {
+		flag := data[pos]
+		pos++
+		flags = append(flags, flag)
+		if flag&0x08 != 0 && pos < len(data) { // REPEAT_FLAG
+			for repeat := int(data[pos]); repeat > 0 && len(flags) < numPoints; repeat-- {
+				flags = append(flags, flag)
+			}
+			pos++
+		}
+	}
+	if len(flags) < numPoints {
+		return nil
+	}
+	points := make([]outlinePoint, numPoints)
+	// the coordinates are deltas, short ones are a byte with the sign in flag, and the same flag keeps the previous one for long ones.
+	readCoords := func(short, same byte, set func(i int, v float64)) bool {
+		var v float64
+		for i, flag := range flags {
+			switch {
+			case flag&short != 0:
+				if pos >= len(data) {
+					return false
+				}
+				delta := float64(data[pos])
+				if flag&same == 0 {
+					delta = -delta
+				}
+				v += delta
+				pos++
+			case flag&same == 0:
+				if pos+2 > len(data) {
+					return false
+				}
+				v += float64(int16(be.Uint16(data[pos:])))
+				pos += 2
+			}
+			set(i, v)
+		}
+		return true
+	}
+	if !readCoords(0x02, 0x10, func(i int, v float64) { points[i].x = v }) ||
+		!readCoords(0x04, 0x20, func(i int, v float64) { points[i].y = v }) {
+		return nil
+	}
+	contours := make([][]outlinePoint, 0, n)
+	start := 0
+	for i, end := range ends {
+		if end < start || end >= numPoints {
+			return nil
+		}
+		contour := points[start : end+1]
+		for j := range contour {
+			contour[j].onCurve = flags[start+j]&0x01 != 0
+		}
+		contours = append(contours, contour)
+		start = ends[i] + 1
+	}
+	return contours
+}
+
+// segment is a line of the flattened outline in pixels.
+type segment struct {
+	x1, y1, x2, y2 float64
+}
+
+// flatten converts the quadratic contours into lines in pixels, transformed by x = ox + px*scale and y = oy - py*scale.
+func flatten(contours [][]outlinePoint, ox, oy, scale float64) []segment {
+	const steps = 8
+	var segs []segment
+	for _, contour := range contours {
+		if len(contour) < 2 {
+			continue
+		}
+		// start from an on-curve point, or the midpoint of the last and first points if all are off-curve,
+		// and two consecutive off-curve points imply an on-curve point at their midpoint.
+		last := contour[len(contour)-1]
+		first := outlinePoint{x: (last.x + contour[0].x) / 2, y: (last.y + contour[0].y) / 2, onCurve: true}
+		rest := append(make([]outlinePoint, 0, len(contour)+1), contour...)
+		for i, p := range contour {
+			if p.onCurve {
+				first = p
+				rest = append(append(rest[:0], contour[i+1:]...), contour[:i]...)
+				break
+			}
+		}
+		x, y := ox+first.x*scale, oy-first.y*scale
+		lineTo := func(x2, y2 float64) {
+			segs = append(segs, segment{x, y, x2, y2})
+			x, y = x2, y2
+		}
+		var ctrl outlinePoint
+		hasCtrl := false
+		for _, p := range append(rest, first) {
+			if !p.onCurve {
+				if hasCtrl {
+					quadTo(lineTo, x, y, ox+ctrl.x*scale, oy-ctrl.y*scale, ox+(ctrl.x+p.x)/2*scale, oy-(ctrl.y+p.y)/2*scale, steps)
+				}
+				ctrl, hasCtrl = p, true
+				continue
+			}
+			if hasCtrl {
+				quadTo(lineTo, x, y, ox+ctrl.x*scale, oy-ctrl.y*scale, ox+p.x*scale, oy-p.y*scale, steps)
+				hasCtrl = false
+			} else {
+				lineTo(ox+p.x*scale, oy-p.y*scale)
+			}
+		}
+	}
+	return segs
+}
+
+func quadTo(lineTo func(x, y float64), x0, y0, cx, cy, x1, y1 float64, steps int) {
+	for i := 1; i <= steps; i++ {
+		t := float64(i) / float64(steps)
+		u := 1 - t
+		lineTo(u*u*x0+2*u*t*cx+t*t*x1, u*u*y0+2*u*t*cy+t*t*y1)
+	}
+}
+
+// fillPath fills the area of segments by the non-zero winding rule, the edges are anti-aliased by sub-scanlines.
+func fillPath(img *image.RGBA, segs []segment, clr color.RGBA) {
+	if len(segs) <= 0 {
+		return
+	}
+	minY, maxY := math.Inf(1), math.Inf(-1)
+	for _, s := range segs {
+		minY, maxY = math.Min(minY, math.Min(s.y1, s.y2)), math.Max(maxY, math.Max(s.y1, s.y2))
+	}
+	bounds := img.Bounds()
+	top, bottom := int(math.Max(math.Floor(minY), float64(bounds.Min.Y))), int(math.Min(math.Ceil(maxY), float64(bounds.Max.Y)))
+	const subScanlines = 4
+	type crossing struct {
+		x   float64
+		dir int
+	}
+	coverage := make([]float64, bounds.Dx())
+	var crossings []crossing
+	for y := top; y < bottom; y++ {
+		for i := range coverage {
+			coverage[i] = 0
+		}
+		for sub := 0; sub < subScanlines; sub++ {
+			sy := float64(y) + (float64(sub)+0.5)/subScanlines
+			crossings = crossings[:0]
+			for _, s := range segs {
+				if (s.y1 <= sy) == (s.y2 <= sy) {
+					continue
+				}
+				dir := 1
+				if s.y2 < s.y1 {
+					dir = -1
+				}
+				crossings = append(crossings, crossing{x: s.x1 + (sy-s.y1)*(s.x2-s.x1)/(s.y2-s.y1), dir: dir})
+			}
+			sort.Slice(crossings, func(i, j int) bool { return crossings[i].x < crossings[j].x })
+			winding := 0
+			for i, c := range crossings {
+				winding += c.dir
+				if winding != 0 && i+1 < len(crossings) {
+					addSpan(coverage, c.x-float64(bounds.Min.X), crossings[i+1].x-float64(bounds.Min.X), 1.0/subScanlines)
+				}
+			}
+		}
+		for i, cov := range coverage {
+			if cov > 0 {
+				blend(img, bounds.Min.X+i, y, clr, math.Min(cov, 1))
+			}
+		}
+	}
+}
+
+// addSpan adds the coverage of [x1, x2) to the pixels, the pixels partially covered get the fraction.
+func addSpan(coverage []float64, x1, x2, weight float64) {
+	x1, x2 = math.Max(x1, 0), math.Min(x2, float64(len(coverage)))
+	for x1 < x2 {
+		px := math.Floor(x1)
+		end := math.Min(px+1, x2)
+		coverage[int(px)] += (end - x1) * weight
+		x1 = end
+	}
+}
+
+func blend(img *image.RGBA, x, y int, clr color.RGBA, alpha float64) {
+	bg := img.RGBAAt(x, y)
+	mix := func(a, b uint8) uint8 { return uint8(math.Round(float64(a)*(1-alpha) + float64(b)*alpha)) }
+	img.SetRGBA(x, y, color.RGBA{R: mix(bg.R, clr.R), G: mix(bg.G, clr.G), B: mix(bg.B, clr.B), A: 0xff})
+}
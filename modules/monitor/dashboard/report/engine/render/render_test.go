@@ -0,0 +1,303 @@
+// Copyright (c) 2021 Terminus, Inc.
+//
+// This program is free software: you can use, redistribute, and/or modify
+// it under the terms of the GNU Affero General Public License, version 3
+// or later ("AGPL"), as published by the Free Software Foundation.
+//
+// This program is distributed in the hope that it will be useful, but WITHOUT
+// ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+// FITNESS FOR A PARTICULAR PURPOSE.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package render
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"image/png"
+	"io"
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func testCharts(t *testing.T, rows int) []*Chart {
+	var tableRows []string
+	for i := 0; i < rows; i++ {
+		tableRows = append(tableRows, fmt.Sprintf(`{"last.tags.service_name":"service-%d","cardinality.tags.container_id":%d}`, i, i))
+	}
+	views := []struct {
+		typ, title, data string
+	}{
+		{"card", "异常概况", `{"metricData":[{"name":"cardinality.tags.host_ip","value":3},{"name":"cpu","value":12.345,"unit":"%"}]}`},
+		{"chart:line", "CPU", `{"times":[1618300800000,1618304400000,1618308000000],"data":[{"avg.cpu":{"name":"cpu","tag":"host-1","data":[1,null,3]}},{"avg.cpu":{"name":"cpu","tag":"host-2","data":[2,2.5,1]}}]}`},
+		{"chart:bar", "", `{"title":"Requests","xdata":["a","b"],"data":[{"count.requests":{"name":"requests","data":[10,20]}}]}`},
+		{"chart:pie", "Alerts", `{"metricData":[{"title":"kafka_lag","value":1440},{"title":"container_mem","value":720}]}`},
+		{"table", "OOM Top", `{"cols":[{"dataIndex":"last.tags.service_name","title":"Service"},{"dataIndex":"cardinality.tags.container_id","title":"OOM","unit":"times"}],"metricData":[` + strings.Join(tableRows, ",") + `]}`},
+	}
+	var list []*Chart
+	for _, v := range views {
+		ch, err := Parse(v.typ, v.title, []byte(v.data))
+		if err != nil {
+			t.Fatal(err)
+		}
+		list = append(list, ch)
+	}
+	return list
+}
+
+func TestParse(t *testing.T) {
+	charts := testCharts(t, 2)
+	card, line, bar, pie, table := charts[0], charts[1], charts[2], charts[3], charts[4]
+
+	if want := []*Card{{Name: "cardinality.tags.host_ip", Value: "3"}, {Name: "cpu", Value: "12.35 %"}}; card.Type != TypeCard || !reflect.DeepEqual(card.Cards, want) {
+		t.Errorf("card = %+v", card.Cards)
+	}
+	if line.Type != TypeLine || len(line.XLabels) != 3 || len(line.Series) != 2 || line.Series[0].Name != "host-1 cpu" || !math.IsNaN(line.Series[0].Values[1]) {
+		t.Errorf("line = %+v", line)
+	}
+	if bar.Type != TypeBar || bar.Title != "Requests" || !reflect.DeepEqual(bar.XLabels, []string{"a", "b"}) || !reflect.DeepEqual(bar.Series[0].Values, []float64{10, 20}) {
+		t.Errorf("bar = %+v", bar)
+	}
+	if pie.Type != TypeBar || !reflect.DeepEqual(pie.XLabels, []string{"kafka_lag", "container_mem"}) {
+		t.Errorf("pie = %+v", pie)
+	}
+	if want := [][]string{{"service-0", "0"}, {"service-1", "1"}}; table.Type != TypeTable || !reflect.DeepEqual(table.Columns, []string{"Service", "OOM (times)"}) || !reflect.DeepEqual(table.Rows, want) {
+		t.Errorf("table = %+v", table)
+	}
+
+	if _, err := Parse("card", "", []byte("[")); err == nil {
+		t.Errorf("Parse() want error, got nil")
+	}
+}
+
+func TestNiceTicks(t *testing.T) {
+	if got, want := niceTicks(0, 87, 4), []float64{0, 20, 40, 60, 80, 100}; !reflect.DeepEqual(got, want) {
+		t.Errorf("niceTicks() = %v, want %v", got, want)
+	}
+	if got := niceTicks(5, 5, 4); got[0] > 5 || got[len(got)-1] < 5 {
+		t.Errorf("niceTicks() = %v does not cover 5", got)
+	}
+}
+
+func TestPNG(t *testing.T) {
+	charts := testCharts(t, 5)
+	if _, err := PNG(charts, 800, nil); !errors.Is(err, ErrUnsupportedText) {
+		t.Errorf("PNG() with Chinese text and no font got error %v, want %v", err, ErrUnsupportedText)
+	}
+	if _, err := PNG(charts, 800, testFont("异常", false)); !errors.Is(err, ErrUnsupportedText) {
+		t.Errorf("PNG() with characters missing in font got error %v, want %v", err, ErrUnsupportedText)
+	}
+	for _, font := range [][]byte{nil, testFont(testChars, false)} {
+		if font == nil {
+			charts[0].Title = "Overview"
+		} else {
+			charts[0].Title = "异常概况"
+		}
+		byts, err := PNG(charts, 800, font)
+		if err != nil {
+			t.Fatal(err)
+		}
+		img, err := png.Decode(bytes.NewReader(byts))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if b := img.Bounds(); b.Dx() != 800 || b.Dy() != int(math.Ceil(stackHeight(charts))) {
+			t.Errorf("PNG() bounds = %v", b)
+		}
+	}
+}
+
+func TestDrawGlyphs(t *testing.T) {
+	ttf, err := parseTrueType(testFont("监", false))
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := newPNGCanvas(40, 40)
+	c.font = ttf
+	c.FillRect(0, 0, 40, 40, colorBackground)
+	// the rectangle of glyph is from x 10+2 to 10+8, and from baseline 20+7.6 up to 20+7.6-14.
+	c.Text(10, 20, 20, AlignLeft, colorText, "监")
+	if len(c.unsupported) > 0 {
+		t.Fatalf("unsupported characters: %v", c.unsupported)
+	}
+	for _, p := range []struct {
+		x, y   int
+		filled bool
+	}{
+		{15, 20, true}, {12, 14, true}, {17, 26, true},
+		{10, 20, false}, {19, 20, false}, {15, 12, false}, {15, 28, false},
+	} {
+		if filled := c.img.RGBAAt(p.x, p.y) == colorText; filled != p.filled {
+			t.Errorf("pixel (%d, %d) filled = %v, want %v", p.x, p.y, filled, p.filled)
+		}
+	}
+}
+
+func TestSVG(t *testing.T) {
+	byts, err := SVG(testCharts(t, 5), 800)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dec := xml.NewDecoder(bytes.NewReader(byts))
+	for {
+		_, err := dec.Token()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatalf("SVG() is not valid xml: %s", err)
+		}
+	}
+	if !bytes.Contains(byts, []byte("异常概况")) {
+		t.Errorf("SVG() does not contain the title")
+	}
+}
+
+// testFont builds a TrueType font with a glyph for each character of chars, in a collection if ttc is true.
+// All the glyphs except the first one are the outline of a rectangle from (100, 0) to (400, 700).
+func testFont(chars string, ttc bool) []byte {
+	runes := []rune(" ?" + chars)
+	numGlyphs := len(runes) + 1
+	u16 := func(vs ...int) []byte {
+		b := make([]byte, 2*len(vs))
+		for i, v := range vs {
+			binary.BigEndian.PutUint16(b[2*i:], uint16(v))
+		}
+		return b
+	}
+	u32 := func(vs ...int) []byte {
+		b := make([]byte, 4*len(vs))
+		for i, v := range vs {
+			binary.BigEndian.PutUint32(b[4*i:], uint32(v))
+		}
+		return b
+	}
+	head := make([]byte, 54)
+	copy(head[18:], u16(1000))
+	copy(head[36:], u16(0, 0xff88, 1000, 880)) // bbox 0, -120, 1000, 880
+	hhea := make([]byte, 36)
+	copy(hhea[4:], u16(880, 0xff88))
+	copy(hhea[34:], u16(numGlyphs))
+	maxp := append(u32(0x5000), u16(numGlyphs)...)
+	var hmtx []byte
+	for gid := 0; gid < numGlyphs; gid++ {
+		hmtx = append(hmtx, u16(500, 0)...)
+	}
+	// a contour of 4 on-curve points, the coordinates are deltas in int16
+	rect := append(u16(1, 100, 0, 400, 700, 3, 0), 1, 1, 1, 1)
+	rect = append(rect, u16(100, 300, 0, 0xfed4, 0, 0, 700, 0)...)
+	var glyf []byte
+	loca := u16(0, 0)
+	for gid := 1; gid < numGlyphs; gid++ {
+		glyf = append(glyf, rect...)
+		loca = append(loca, u16(len(glyf)/2)...)
+	}
+	// format 12, a group for each character
+	sub := append(u16(12, 0), u32(16+12*len(runes), 0, len(runes))...)
+	for i, r := range runes {
+		sub = append(sub, u32(int(r), int(r), i+1)...)
+	}
+	cmap := append(u16(0, 1, 3, 10), u32(12)...)
+	cmap = append(cmap, sub...)
+	tables := []struct {
+		tag  string
+		data []byte
+	}{{"cmap", cmap}, {"glyf", glyf}, {"head", head}, {"hhea", hhea}, {"hmtx", hmtx}, {"loca", loca}, {"maxp", maxp}}
+
+	offset := 0
+	var buf bytes.Buffer
+	if ttc {
+		offset = 16
+		buf.WriteString("ttcf")
+		buf.Write(u32(0x10000, 1, offset))
+	}
+	buf.Write(append(u32(0x10000), u16(len(tables), 0, 0, 0)...))
+	pos := offset + 12 + 16*len(tables)
+	for _, t := range tables {
+		buf.WriteString(t.tag)
+		buf.Write(u32(0, pos, len(t.data)))
+		pos += (len(t.data) + 3) &^ 3
+	}
+	for _, t := range tables {
+		buf.Write(t.data)
+		buf.Write(make([]byte, (4-len(t.data)%4)%4))
+	}
+	return buf.Bytes()
+}
+
+func TestParseTrueType(t *testing.T) {
+	for _, ttc := range []bool{false, true} {
+		f, err := parseTrueType(testFont("监控", ttc))
+		if err != nil {
+			t.Fatalf("parseTrueType(ttc: %v) got error: %s", ttc, err)
+		}
+		if gid, ok := f.glyphs['控']; !ok || gid != 4 {
+			t.Errorf("parseTrueType(ttc: %v) glyph of '控' = %d, %v", ttc, gid, ok)
+		}
+		if got := f.textWidth(10, "监控"); got != 10 {
+			t.Errorf("textWidth() = %v, want 10", got)
+		}
+		if ttc {
+			if _, err := parseTrueType(f.data); err != nil {
+				t.Errorf("font extracted from collection is invalid: %s", err)
+			}
+		}
+	}
+	if _, err := parseTrueType([]byte("OTTO\x00\x00\x00\x00\x00\x00\x00\x00")); err == nil {
+		t.Errorf("parseTrueType() of CFF font want error, got nil")
+	}
+}
+
+// testChars are the characters of testCharts and the title of TestPDF.
+const testChars = "terminus 监控周报04月05日-04月12日异常概况0123456789abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ-_.:/()%"
+
+func TestPDF(t *testing.T) {
+	title, subtitle := "terminus 监控周报", "04月05日-04月12日"
+	if _, err := PDF(&Document{Title: title, Subtitle: subtitle, Charts: testCharts(t, 5), Font: testFont("监控周报", false)}); !errors.Is(err, ErrUnsupportedText) {
+		t.Errorf("PDF() with characters missing in font got error %v, want %v", err, ErrUnsupportedText)
+	}
+
+	font := testFont(testChars, false)
+	for _, c := range []struct {
+		rows  int
+		pages int
+	}{
+		{rows: 5, pages: 3},
+		{rows: 100, pages: 5},
+	} {
+		byts, err := PDF(&Document{Title: title, Subtitle: subtitle, Charts: testCharts(t, c.rows), Font: font})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.HasPrefix(byts, []byte("%PDF-1.4")) || !bytes.HasSuffix(byts, []byte("%%EOF\n")) {
+			t.Errorf("PDF() is not a pdf file")
+		}
+		if !bytes.Contains(byts, []byte("/FontFile2")) {
+			t.Errorf("PDF() does not embed the font")
+		}
+		if got := bytes.Count(byts, []byte("/Type /Page /Parent")); got != c.pages {
+			t.Errorf("PDF() with %d rows got %d pages, want %d", c.rows, got, c.pages)
+		}
+	}
+
+	// the report title is Chinese, it is drawn by the predefined font of pdf viewers by default.
+	byts, err := PDF(&Document{Title: title, Subtitle: subtitle, Charts: testCharts(t, 5)})
+	if err != nil {
+		t.Fatalf("PDF() with Chinese text and no font got error: %s", err)
+	}
+	if !bytes.Contains(byts, []byte("/BaseFont /STSong-Light")) || bytes.Contains(byts, []byte("/FontFile2")) {
+		t.Errorf("PDF() without font does not use the predefined font")
+	}
+	if got := (&pdfFont{unsupported: make(map[rune]bool)}).encode(title); got != ucs2(title) {
+		t.Errorf("encode() without font = %s, want UCS-2 %s", got, ucs2(title))
+	}
+	if _, err := PDF(&Document{Title: "监控 \U0001F600", Charts: testCharts(t, 5)}); !errors.Is(err, ErrUnsupportedText) {
+		t.Errorf("PDF() with characters out of BMP got error %v, want %v", err, ErrUnsupportedText)
+	}
+}
@@ -0,0 +1,94 @@
+// Copyright (c) 2021 Terminus, Inc.
+//
+// This program is free software: you can use, redistribute, and/or modify
+// it under the terms of the GNU Affero General Public License, version 3
+// or later ("AGPL"), as published by the Free Software Foundation.
+//
+// This program is distributed in the hope that it will be useful, but WITHOUT
+// ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+// FITNESS FOR A PARTICULAR PURPOSE.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package render
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"image/color"
+	"math"
+	"strconv"
+)
+
+// SVG draws the charts from top to bottom into a svg image with the width.
+func SVG(charts []*Chart, width int) ([]byte, error) {
+	height := int(math.Ceil(stackHeight(charts)))
+	c := &svgCanvas{}
+	fmt.Fprintf(&c.buf, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d" font-family="Helvetica, Arial, 'PingFang SC', 'Microsoft YaHei', sans-serif">`, width, height, width, height)
+	stack(c, charts, float64(width))
+	c.buf.WriteString("</svg>")
+	return c.buf.Bytes(), nil
+}
+
+type svgCanvas struct {
+	buf bytes.Buffer
+}
+
+func (c *svgCanvas) FillRect(x, y, w, h float64, clr color.RGBA) {
+	fmt.Fprintf(&c.buf, `<rect x="%s" y="%s" width="%s" height="%s" fill="%s"/>`, num(x), num(y), num(w), num(h), hex(clr))
+}
+
+func (c *svgCanvas) Line(x1, y1, x2, y2, width float64, clr color.RGBA) {
+	fmt.Fprintf(&c.buf, `<line x1="%s" y1="%s" x2="%s" y2="%s" stroke="%s" stroke-width="%s"/>`, num(x1), num(y1), num(x2), num(y2), hex(clr), num(width))
+}
+
+func (c *svgCanvas) Polyline(points []Point, width float64, clr color.RGBA) {
+	c.buf.WriteString(`<polyline points="`)
+	for i, p := range points {
+		if i > 0 {
+			c.buf.WriteByte(' ')
+		}
+		c.buf.WriteString(num(p.X) + "," + num(p.Y))
+	}
+	fmt.Fprintf(&c.buf, `" fill="none" stroke="%s" stroke-width="%s" stroke-linejoin="round"/>`, hex(clr), num(width))
+}
+
+func (c *svgCanvas) Text(x, y, size float64, align Align, clr color.RGBA, s string) {
+	anchor := "start"
+	switch align {
+	case AlignCenter:
+		anchor = "middle"
+	case AlignRight:
+		anchor = "end"
+	}
+	fmt.Fprintf(&c.buf, `<text x="%s" y="%s" font-size="%s" fill="%s" text-anchor="%s" dominant-baseline="central">`, num(x), num(y), num(size), hex(clr), anchor)
+	xml.EscapeText(&c.buf, []byte(s))
+	c.buf.WriteString("</text>")
+}
+
+func (c *svgCanvas) TextWidth(size float64, s string) float64 {
+	return textWidth(size, s)
+}
+
+// textWidth estimates the width of s in a proportional font.
+func textWidth(size float64, s string) float64 {
+	var w float64
+	for _, r := range s {
+		if isWide(r) {
+			w += size
+		} else {
+			w += size * 0.55
+		}
+	}
+	return w
+}
+
+func num(f float64) string {
+	return strconv.FormatFloat(math.Round(f*100)/100, 'f', -1, 64)
+}
+
+func hex(c color.RGBA) string {
+	return fmt.Sprintf("#%02x%02x%02x", c.R, c.G, c.B)
+}
@@ -0,0 +1,244 @@
+// Copyright (c) 2021 Terminus, Inc.
+//
+// This program is free software: you can use, redistribute, and/or modify
+// it under the terms of the GNU Affero General Public License, version 3
+// or later ("AGPL"), as published by the Free Software Foundation.
+//
+// This program is distributed in the hope that it will be useful, but WITHOUT
+// ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+// FITNESS FOR A PARTICULAR PURPOSE.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package render
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"sort"
+)
+
+// trueType is a TrueType font to be embedded in pdf or drawn in png, only the tables to map characters to glyphs,
+// measure and outline them are parsed.
+type trueType struct {
+	data       []byte // the font file, the first font of a collection is extracted
+	unitsPerEm float64
+	ascent     int16
+	descent    int16
+	bbox       [4]int16
+	glyphs     map[rune]uint16
+	advances   []uint16 // by glyph id
+	glyf       []byte
+	loca       []byte
+	longLoca   bool // the offsets in loca are uint32 instead of uint16 divided by 2
+}
+
+var be = binary.BigEndian
+
+// parseTrueType parses a TrueType font file (.ttf), or the first font of a TrueType collection (.ttc).
+func parseTrueType(data []byte) (*trueType, error) {
+	if len(data) < 12 {
+		return nil, fmt.Errorf("invalid font file")
+	}
+	offset := 0
+	if string(data[:4]) == "ttcf" {
+		if len(data) < 16 {
+			return nil, fmt.Errorf("invalid font collection")
+		}
+		offset = int(be.Uint32(data[12:]))
+	}
+	if offset+12 > len(data) {
+		return nil, fmt.Errorf("invalid font file")
+	}
+	switch version := string(data[offset : offset+4]); version {
+	case "\x00\x01\x00\x00", "true":
+	case "OTTO":
+		return nil, fmt.Errorf("fonts with CFF outlines are not supported, use a TrueType font")
+	default:
+		return nil, fmt.Errorf("unknown font version %q", version)
+	}
+	numTables := int(be.Uint16(data[offset+4:]))
+	if offset+12+numTables*16 > len(data) {
+		return nil, fmt.Errorf("invalid table directory")
+	}
+	type record struct {
+		tag                string
+		checksum, off, len uint32
+	}
+	records := make([]record, 0, numTables)
+	tables := make(map[string][]byte, numTables)
+	for i := 0; i < numTables; i++ {
+		rec := data[offset+12+i*16:]
+		r := record{tag: string(rec[:4]), checksum: be.Uint32(rec[4:]), off: be.Uint32(rec[8:]), len: be.Uint32(rec[12:])}
+		if uint64(r.off)+uint64(r.len) > uint64(len(data)) {
+			return nil, fmt.Errorf("table %s is out of range", r.tag)
+		}
+		records = append(records, r)
+		tables[r.tag] = data[r.off : r.off+r.len]
+	}
+	for _, tag := range []string{"head", "hhea", "hmtx", "maxp", "cmap", "glyf", "loca"} {
+		if _, ok := tables[tag]; !ok {
+			return nil, fmt.Errorf("table %s is missing", tag)
+		}
+	}
+	head, hhea, maxp := tables["head"], tables["hhea"], tables["maxp"]
+	if len(head) < 54 || len(hhea) < 36 || len(maxp) < 6 {
+		return nil, fmt.Errorf("invalid head, hhea or maxp table")
+	}
+	f := &trueType{
+		data:       data,
+		unitsPerEm: float64(be.Uint16(head[18:])),
+		ascent:     int16(be.Uint16(hhea[4:])),
+		descent:    int16(be.Uint16(hhea[6:])),
+		glyf:       tables["glyf"],
+		loca:       tables["loca"],
+		longLoca:   be.Uint16(head[50:]) != 0,
+	}
+	if f.unitsPerEm <= 0 {
+		return nil, fmt.Errorf("invalid unitsPerEm")
+	}
+	for i := range f.bbox {
+		f.bbox[i] = int16(be.Uint16(head[36+2*i:]))
+	}
+
+	numGlyphs, numMetrics := int(be.Uint16(maxp[4:])), int(be.Uint16(hhea[34:]))
+	hmtx := tables["hmtx"]
+	if numMetrics <= 0 || numMetrics > numGlyphs || len(hmtx) < numMetrics*4 {
+		return nil, fmt.Errorf("invalid hmtx table")
+	}
+	locaSize := 2
+	if f.longLoca {
+		locaSize = 4
+	}
+	if len(f.loca) < locaSize*(numGlyphs+1) {
+		return nil, fmt.Errorf("invalid loca table")
+	}
+	f.advances = make([]uint16, numGlyphs)
+	for i := range f.advances {
+		if i < numMetrics {
+			f.advances[i] = be.Uint16(hmtx[i*4:])
+		} else {
+			// the glyphs after the metrics have the advance of the last one
+			f.advances[i] = f.advances[numMetrics-1]
+		}
+	}
+
+	glyphs, err := parseCmap(tables["cmap"])
+	if err != nil {
+		return nil, err
+	}
+	for r, gid := range glyphs {
+		if int(gid) >= numGlyphs {
+			delete(glyphs, r)
+		}
+	}
+	f.glyphs = glyphs
+
+	if offset > 0 {
+		// rebuild a standalone font file with the tables of the first font in the collection
+		sort.Slice(records, func(i, j int) bool { return records[i].tag < records[j].tag })
+		var buf bytes.Buffer
+		buf.Write(data[offset : offset+12])
+		pos := 12 + 16*len(records)
+		for _, r := range records {
+			binary.Write(&buf, be, []byte(r.tag))
+			binary.Write(&buf, be, []uint32{r.checksum, uint32(pos), r.len})
+			pos += int(r.len+3) &^ 3
+		}
+		for _, r := range records {
+			buf.Write(tables[r.tag])
+			buf.Write(make([]byte, (4-int(r.len)%4)%4))
+		}
+		f.data = buf.Bytes()
+	}
+	return f, nil
+}
+
+// parseCmap maps the characters to glyphs by the unicode subtable of format 12 or 4.
+func parseCmap(cmap []byte) (map[rune]uint16, error) {
+	if len(cmap) < 4 {
+		return nil, fmt.Errorf("invalid cmap table")
+	}
+	var format4, format12 []byte
+	for i, n := 0, int(be.Uint16(cmap[2:])); i < n && 4+i*8+8 <= len(cmap); i++ {
+		rec := cmap[4+i*8:]
+		platform, encoding, off := be.Uint16(rec), be.Uint16(rec[2:]), be.Uint32(rec[4:])
+		if platform != 0 && !(platform == 3 && (encoding == 1 || encoding == 10)) || int(off)+4 > len(cmap) {
+			continue
+		}
+		switch sub := cmap[off:]; be.Uint16(sub) {
+		case 4:
+			format4 = sub
+		case 12:
+			format12 = sub
+		}
+	}
+	glyphs := make(map[rune]uint16)
+	switch {
+	case format12 != nil:
+		if len(format12) < 16 {
+			return nil, fmt.Errorf("invalid cmap subtable")
+		}
+		n := int(be.Uint32(format12[12:]))
+		if 16+n*12 > len(format12) {
+			return nil, fmt.Errorf("invalid cmap subtable")
+		}
+		for i := 0; i < n; i++ {
+			g := format12[16+i*12:]
+			start, end, gid := be.Uint32(g), be.Uint32(g[4:]), be.Uint32(g[8:])
+			for c := start; c <= end && c <= 0x10ffff; c++ {
+				glyphs[rune(c)] = uint16(gid + c - start)
+			}
+		}
+	case format4 != nil:
+		if len(format4) < 14 {
+			return nil, fmt.Errorf("invalid cmap subtable")
+		}
+		segX2 := int(be.Uint16(format4[6:]))
+		ends, starts, deltas, ranges := 14, 16+segX2, 16+2*segX2, 16+3*segX2
+		if ranges+segX2 > len(format4) {
+			return nil, fmt.Errorf("invalid cmap subtable")
+		}
+		for i := 0; i < segX2; i += 2 {
+			start, end := be.Uint16(format4[starts+i:]), be.Uint16(format4[ends+i:])
+			delta, rangeOffset := be.Uint16(format4[deltas+i:]), int(be.Uint16(format4[ranges+i:]))
+			for c := uint32(start); c <= uint32(end) && c != 0xffff; c++ {
+				gid := uint16(c) + delta
+				if rangeOffset != 0 {
+					addr := ranges + i + rangeOffset + 2*int(c-uint32(start))
+					if addr+2 > len(format4) {
+						continue
+					}
+					if gid = be.Uint16(format4[addr:]); gid != 0 {
+						gid += delta
+					}
+				}
+				if gid != 0 {
+					glyphs[rune(c)] = gid
+				}
+			}
+		}
+	default:
+		return nil, fmt.Errorf("no unicode cmap subtable")
+	}
+	return glyphs, nil
+}
+
+// scale converts the units of the font to the units of text space, in which the size of font is 1000.
+func (f *trueType) scale(v float64) float64 {
+	return v * 1000 / f.unitsPerEm
+}
+
+func (f *trueType) textWidth(size float64, s string) float64 {
+	var w float64
+	for _, r := range s {
+		if gid, ok := f.glyphs[r]; ok {
+			w += f.scale(float64(f.advances[gid])) * size / 1000
+		} else {
+			w += textWidth(size, string(r))
+		}
+	}
+	return w
+}
@@ -17,10 +17,12 @@ import (
 	"bufio"
 	"bytes"
 	"context"
+	"encoding/base64"
 	"encoding/json"
 	"fmt"
 	"net/http"
 	"net/url"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
@@ -32,12 +34,14 @@ import (
 	"github.com/pkg/errors"
 	"github.com/sirupsen/logrus"
 
+	"github.com/erda-project/erda/modules/monitor/dashboard/report/engine/render"
 	"github.com/erda-project/erda/pkg/http/httpclient"
 )
 
 const (
-	ns2ms   = 1000000
-	datefmt = "01月02日"
+	ns2ms      = 1000000
+	datefmt    = "01月02日"
+	imageWidth = 1000
 )
 
 var (
@@ -94,6 +98,7 @@ type Report struct {
 	cfg        *config
 	resource   *Resource
 	DataConfig []*viewData
+	font       []byte // the font to draw the text of pdf and png
 }
 
 func New(cfg *config) *Report {
@@ -102,19 +107,19 @@ func New(cfg *config) *Report {
 	}
 }
 
-// 1. fetch report template
-// 2. get screenshot from headless
-// 3. fetch telemetry to decode view_config add a history record
-// 4. combine history URI and image as email html
-// 5. send email to notify group
+// 1. fetch report template and telemetry data
+// 2. render the report to pdf or image
+// 3. add a history record
+// 4. combine history URI and the rendered report as notifications
+// 5. send to notify group
 func (r *Report) Run(ctx context.Context) error {
 	if err := r.getResource(ctx); err != nil {
 		return errors.Wrap(err, "create resource error")
 	}
 
-	img, err := r.getImage(ctx)
+	file, err := r.renderReport(ctx)
 	if err != nil {
-		return errors.Wrap(err, "get screenshot image error")
+		return errors.Wrap(err, "render report error")
 	}
 
 	history, err := r.record(ctx)
@@ -122,7 +127,7 @@ func (r *Report) Run(ctx context.Context) error {
 		return errors.Wrap(err, "push record error")
 	}
 
-	event, err := r.createEventbox(ctx, img, history)
+	event, err := r.createEventbox(ctx, file, history)
 	if err != nil {
 		return errors.Wrap(err, "createEventbox error")
 	}
@@ -164,11 +169,7 @@ type tmplParams struct {
 	OrgName     string
 }
 
-func (r *Report) createEventbox(ctx context.Context, img []byte, history *historyEntity) (body *eventboxEntity, err error) {
-	var (
-		rt          string
-		dateDisplay string
-	)
+func (r *Report) reportType() (rt, dateDisplay string, err error) {
 	switch r.resource.ReportTask.Type {
 	case "daily":
 		rt = "日报"
@@ -180,7 +181,15 @@ func (r *Report) createEventbox(ctx context.Context, img []byte, history *histor
 	// 	rt = "月报"
 	// 	dateDisplay = fmt.Sprintf("%s-%s", rightNow.AddDate(0, -1, 0).Format(datefmt), rightNow.Format(datefmt))
 	default:
-		return nil, errors.New("unsupported report type")
+		return "", "", errors.New("unsupported report type")
+	}
+	return rt, dateDisplay, nil
+}
+
+func (r *Report) createEventbox(ctx context.Context, file *attachment, history *historyEntity) (body *eventboxEntity, err error) {
+	rt, dateDisplay, err := r.reportType()
+	if err != nil {
+		return nil, err
 	}
 	target := fmt.Sprintf("%s/r/report/%d?recordId=%d", r.cfg.DomainAddr, r.resource.ReportTask.ID, history.ID)
 	allType := strings.Split(r.resource.ReportTask.Notifier.GroupType, ",")
@@ -200,6 +209,9 @@ func (r *Report) createEventbox(ctx context.Context, img []byte, history *histor
 			Template: d,
 			Params:   make(map[string]interface{}),
 		}
+		if allType[i] == "email" && file != nil {
+			channels[i].Attachments = []*attachment{file}
+		}
 	}
 
 	orgID, err := strconv.Atoi(r.resource.ReportTask.ScopeID)
@@ -296,8 +308,57 @@ func (r *Report) record(ctx context.Context) (historyID *historyEntity, err erro
 	return &historyResp, nil
 }
 
-func (r *Report) getImage(ctx context.Context) ([]byte, error) {
-	return nil, nil
+// renderReport draws the views with their data natively, so that reports work without a headless browser.
+func (r *Report) renderReport(ctx context.Context) (*attachment, error) {
+	rt, dateDisplay, err := r.reportType()
+	if err != nil {
+		return nil, err
+	}
+	views, _ := r.resource.Block.ViewConfig.([]*viewConfig)
+	idx := make([]int, 0, len(views))
+	for i := range views {
+		if i < len(r.DataConfig) && r.DataConfig[i] != nil && r.DataConfig[i].StaticData != nil {
+			idx = append(idx, i)
+		}
+	}
+	// in the order of the dashboard layout
+	sort.SliceStable(idx, func(i, j int) bool {
+		a, b := views[idx[i]], views[idx[j]]
+		return a.Y < b.Y || (a.Y == b.Y && a.X < b.X)
+	})
+	var charts []*render.Chart
+	for _, i := range idx {
+		view := views[i]
+		ch, err := render.Parse(view.View.ChartType, view.View.Title, *r.DataConfig[i].StaticData)
+		if err != nil {
+			logrus.WithError(err).Errorf("fail to parse data of view %s", view.I)
+			continue
+		}
+		charts = append(charts, ch)
+	}
+
+	title := fmt.Sprintf("%s监控%s", r.cfg.OrgName, rt)
+	filename := fmt.Sprintf("%s-%s.%s", title, endTimestamp.Format("20060102"), r.cfg.Format)
+	var content []byte
+	switch r.cfg.Format {
+	case "png":
+		content, err = render.PNG(charts, imageWidth, r.font)
+	case "svg":
+		content, err = render.SVG(charts, imageWidth)
+	default:
+		content, err = render.PDF(&render.Document{Title: title, Subtitle: dateDisplay, Charts: charts, Font: r.font})
+	}
+	if errors.Is(err, render.ErrUnsupportedText) {
+		return nil, errors.Wrap(err, "set a font containing the characters by ACTION_REPORT_FONT_FILE, or use svg format")
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &attachment{
+		Filename: filename,
+		Content:  base64.StdEncoding.EncodeToString(content),
+		Encoding: "base64",
+	}, nil
 }
 
 func (r *Report) fetch(api *apiEntity) (data *json.RawMessage, err error) {
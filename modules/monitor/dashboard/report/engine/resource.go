@@ -24,7 +24,6 @@ const (
 	// systemBlocksPath  = "/api/dashboard/system/blocks"
 	userBlocksPath    = "/api/dashboard/blocks"
 	reportHistoryPath = "/api/report/histories"
-	headlessPath      = "/api/cdp/screenshot"
 
 	eventboxPath = "/api/dice/eventbox/message/create"
 )
@@ -60,9 +59,16 @@ type eventboxEntity struct {
 }
 
 type notifyChannel struct {
-	Name     string                 `json:"name"`
-	Template string                 `json:"template"`
-	Params   map[string]interface{} `json:"params"`
+	Name        string                 `json:"name"`
+	Template    string                 `json:"template"`
+	Params      map[string]interface{} `json:"params"`
+	Attachments []*attachment          `json:"attachments,omitempty"`
+}
+
+type attachment struct {
+	Filename string `json:"filename"`
+	Content  string `json:"content"`
+	Encoding string `json:"encoding"`
 }
 
 type reportTaskEntity struct {
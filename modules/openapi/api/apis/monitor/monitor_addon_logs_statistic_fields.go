@@ -0,0 +1,27 @@
+// Copyright (c) 2021 Terminus, Inc.
+//
+// This program is free software: you can use, redistribute, and/or modify
+// it under the terms of the GNU Affero General Public License, version 3
+// or later ("AGPL"), as published by the Free Software Foundation.
+//
+// This program is distributed in the hope that it will be useful, but WITHOUT
+// ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+// FITNESS FOR A PARTICULAR PURPOSE.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package monitor
+
+import "github.com/erda-project/erda/modules/openapi/api/apis"
+
+var MONITOR_ADDON_LOGS_STATISTIC_FIELDS = apis.ApiSpec{
+	Path:        "/api/log-analytics/<addon>/statistic/fields",
+	BackendPath: "/api/micro_service/<addon>/logs/statistic/fields",
+	Host:        "monitor.marathon.l4lb.thisdcos.directory:7096",
+	Scheme:      "http",
+	Method:      "GET",
+	CheckLogin:  true,
+	CheckToken:  true,
+	Doc:         "summary: 日志字段值统计接口",
+}
@@ -0,0 +1,27 @@
+// Copyright (c) 2021 Terminus, Inc.
+//
+// This program is free software: you can use, redistribute, and/or modify
+// it under the terms of the GNU Affero General Public License, version 3
+// or later ("AGPL"), as published by the Free Software Foundation.
+//
+// This program is distributed in the hope that it will be useful, but WITHOUT
+// ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+// FITNESS FOR A PARTICULAR PURPOSE.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package monitor
+
+import "github.com/erda-project/erda/modules/openapi/api/apis"
+
+var MONITOR_ORG_ALERT_RECORD_ACK = apis.ApiSpec{
+	Path:        "/api/org-alert-records/<groupId>/ack",
+	BackendPath: "/api/org-alert-records/<groupId>/ack",
+	Host:        "monitor.marathon.l4lb.thisdcos.directory:7096",
+	Scheme:      "http",
+	Method:      "POST",
+	CheckLogin:  true,
+	CheckToken:  true,
+	Doc:         "summary: 确认企业告警, 在默认时长内静默该告警",
+}
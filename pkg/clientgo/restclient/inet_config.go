@@ -0,0 +1,59 @@
+// Copyright (c) 2021 Terminus, Inc.
+//
+// This program is free software: you can use, redistribute, and/or modify
+// it under the terms of the GNU Affero General Public License, version 3
+// or later ("AGPL"), as published by the Free Software Foundation.
+//
+// This program is distributed in the hope that it will be useful, but WITHOUT
+// ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+// FITNESS FOR A PARTICULAR PURPOSE.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package restclient
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/pkg/errors"
+	. "k8s.io/client-go/rest"
+)
+
+// NewInetConfig returns a copy of config pointing to addr, for clients which
+// build their own RESTClient, e.g. the dynamic client.
+// Requests to inet addr are sent through netportal like InetRESTClient does.
+func NewInetConfig(addr string, config *Config) (*Config, error) {
+	config = CopyConfig(config)
+	if !strings.HasPrefix(addr, "inet://") {
+		config.Host = addr
+		return config, nil
+	}
+	if inetAddr == "" {
+		return nil, errors.WithStack(ErrAddrMiss)
+	}
+	portalHost, portalDest, _, portalArgs, err := parseInetUrl(addr)
+	if err != nil {
+		return nil, err
+	}
+	config.Host = strings.TrimPrefix(inetAddr, "http://")
+	headers := portalHeaders(portalHost, portalDest, portalArgs)
+	config.Wrap(func(rt http.RoundTripper) http.RoundTripper {
+		return &portalRoundTripper{headers: headers, rt: rt}
+	})
+	return config, nil
+}
+
+type portalRoundTripper struct {
+	headers map[string]string
+	rt      http.RoundTripper
+}
+
+func (p *portalRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	for key, value := range p.headers {
+		req.Header.Set(key, value)
+	}
+	return p.rt.RoundTrip(req)
+}
@@ -141,20 +141,25 @@ func NewInetRESTClient(addr string, config *Config) (*InetRESTClient, error) {
 	}
 	inetClient := &InetRESTClient{
 		RESTClient:  restClient,
-		inetHeaders: map[string]string{},
+		inetHeaders: portalHeaders(portalHost, portalDest, portalArgs),
 	}
-	inetClient.inetHeaders[portalHostHeader] = portalHost
-	inetClient.inetHeaders[portalDestHeader] = portalDest
+	return inetClient, nil
+}
+
+func portalHeaders(portalHost, portalDest string, portalArgs map[string]string) map[string]string {
+	headers := map[string]string{}
+	headers[portalHostHeader] = portalHost
+	headers[portalDestHeader] = portalDest
 	if portalArgs["direct"] == "on" {
-		inetClient.inetHeaders[portalDirectHeader] = "on"
+		headers[portalDirectHeader] = "on"
 	}
 	if portalArgs["ssl"] == "on" {
-		inetClient.inetHeaders[portalSSLHeader] = "on"
+		headers[portalSSLHeader] = "on"
 	}
 	if portalArgs["passthrough"] == "on" {
-		inetClient.inetHeaders[portalPassthroughHeader] = "on"
+		headers[portalPassthroughHeader] = "on"
 	}
-	return inetClient, nil
+	return headers
 }
 
 func (c *InetRESTClient) Get() *Request {
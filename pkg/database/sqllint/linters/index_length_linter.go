@@ -34,6 +34,9 @@ const (
 	charDefaultFlen    = 255
 )
 
+// IndexLengthLinter checks the storage length of indexes, and the types of the columns in indexes.
+// The columns out of indexes are not checked, so TEXT or BLOB columns can be defined as long as they are not indexed,
+// which is what VarcharLengthLinter asks for a VARCHAR longer than 5000.
 type IndexLengthLinter struct {
 	baseLinter
 }
@@ -56,13 +59,30 @@ func (l *IndexLengthLinter) Enter(in ast.Node) (ast.Node, bool) {
 		// colStorage key is col name, value is col storage size
 		colStorage = make(map[string]int, len(stmt.Cols))
 		colNames   = make(map[string]*ast.ColumnDef, len(stmt.Cols))
+		// indexed key is the name of col in any index, the cols out of indexes are not checked
+		indexed = make(map[string]bool, len(stmt.Cols))
 	)
+	for _, c := range stmt.Constraints {
+		for _, key := range c.Keys {
+			if key.Column != nil {
+				indexed[key.Column.Name.String()] = true
+			}
+		}
+	}
 	for _, col := range stmt.Cols {
 		colName := ddlconv.ExtractColName(col)
 		if colName == "" || col.Tp == nil {
 			continue
 		}
 		colNames[colName] = col
+		for _, opt := range col.Options {
+			if opt != nil && (opt.Tp == ast.ColumnOptionPrimaryKey || opt.Tp == ast.ColumnOptionUniqKey) {
+				indexed[colName] = true
+			}
+		}
+		if !indexed[colName] {
+			continue
+		}
 
 		switch col.Tp.Tp {
 		case mysql.TypeDecimal, mysql.TypeNewDecimal:
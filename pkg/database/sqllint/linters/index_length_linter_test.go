@@ -54,3 +54,40 @@ func TestNewIndexLengthLinter(t *testing.T) {
 		t.Fatal("failed")
 	}
 }
+
+const indexLengthLinterTextSQL = `
+create table some_table (
+	id bigint(20) unsigned NOT NULL AUTO_INCREMENT,
+	name varchar(191) NOT NULL,
+	content mediumtext NOT NULL,
+	PRIMARY KEY (id),
+	UNIQUE KEY uk_name (name)
+);
+`
+
+func TestIndexLengthLinter_TextOutOfIndex(t *testing.T) {
+	linter := sqllint.New(linters.NewIndexLengthLinter)
+	if err := linter.Input([]byte(indexLengthLinterTextSQL), "indexLengthLinterTextSQL"); err != nil {
+		t.Error(err)
+	}
+	if errors := linter.Errors(); len(errors) != 0 {
+		t.Fatalf("text column out of indexes should be allowed, errors: %v", errors)
+	}
+}
+
+const indexLengthLinterBlobSQL = `
+create table some_table (
+	content blob,
+	index idx_content (content)
+);
+`
+
+func TestIndexLengthLinter_BlobInIndex(t *testing.T) {
+	linter := sqllint.New(linters.NewIndexLengthLinter)
+	if err := linter.Input([]byte(indexLengthLinterBlobSQL), "indexLengthLinterBlobSQL"); err != nil {
+		t.Error(err)
+	}
+	if errors := linter.Errors(); len(errors) == 0 {
+		t.Fatal("blob column in index should not be allowed")
+	}
+}
@@ -16,11 +16,15 @@ package stm
 
 import (
 	"encoding/json"
+	"errors"
 
 	"github.com/coreos/etcd/clientv3"
 	"github.com/coreos/etcd/clientv3/concurrency"
 )
 
+// NotFoundErr 在 STM 中 Get 的 key 不存在
+var NotFoundErr = errors.New("not found")
+
 // JSONStoreSTMOP 包括了在 STM 中能使用的 API
 type JSONStoreSTMOP interface {
 	Get(key string, object interface{}) error
@@ -41,6 +45,9 @@ type JSONStoreSTMImpl struct {
 // Get 作用与JSONStore.Get 相同，在STM中使用
 func (j *JSONStoreSTMImpl) Get(key string, object interface{}) error {
 	v := j.stm.Get(key)
+	if v == "" {
+		return NotFoundErr
+	}
 	if err := json.Unmarshal([]byte(v), object); err != nil {
 		return err
 	}
@@ -25,7 +25,8 @@ import (
 )
 
 var (
-	NotFoundErr = errors.New("not found")
+	// NotFoundErr 与 STM 中 Get 的 key 不存在时返回的错误相同
+	NotFoundErr = stm.NotFoundErr
 )
 
 type JsonStore interface {